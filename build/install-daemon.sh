@@ -19,6 +19,9 @@ NC='\033[0m' # No Color
 DAEMON_NAME="vpn-managerd"
 INSTALL_DIR="/usr/bin"
 SERVICE_DIR="/etc/systemd/system"
+# D-Bus system service policy and polkit actions (com.vpnmanager.Daemon1)
+DBUS_POLICY_DIR="/usr/share/dbus-1/system.d"
+POLKIT_ACTIONS_DIR="/usr/share/polkit-1/actions"
 SOCKET_DIR="/var/run/vpn-manager"
 # System group granted access to the daemon socket (root:GROUP 0660).
 # Must match daemon.DefaultSocketGroup.
@@ -107,6 +110,7 @@ install_binary() {
     # Stop service if running
     if systemctl is-active --quiet "$DAEMON_NAME" 2>/dev/null; then
         log_info "Stopping running daemon..."
+        systemctl stop "$DAEMON_NAME.socket" 2>/dev/null || true
         systemctl stop "$DAEMON_NAME"
     fi
     
@@ -122,8 +126,13 @@ install_binary() {
 install_service() {
     log_info "Installing systemd service..."
     
-    # Copy service file
+    # Copy service and socket files
     install -m 644 "$SCRIPT_DIR/systemd/vpn-managerd.service" "$SERVICE_DIR/$DAEMON_NAME.service"
+    install -m 644 "$SCRIPT_DIR/systemd/vpn-managerd.socket" "$SERVICE_DIR/$DAEMON_NAME.socket"
+    
+    # D-Bus bus policy and polkit actions for the com.vpnmanager.Daemon1 service
+    install -D -m 644 "$SCRIPT_DIR/dbus/com.vpnmanager.Daemon1.conf" "$DBUS_POLICY_DIR/com.vpnmanager.Daemon1.conf"
+    install -D -m 644 "$SCRIPT_DIR/polkit/com.vpnmanager.daemon.policy" "$POLKIT_ACTIONS_DIR/com.vpnmanager.daemon.policy"
     
     # Reload systemd
     systemctl daemon-reload
@@ -164,7 +173,7 @@ create_socket_group() {
 enable_service() {
     log_info "Enabling and starting service..."
     
-    # Enable service to start on boot
+    # Enable service to start on boot (and its socket, via Also=)
     systemctl enable "$DAEMON_NAME"
     
     # Start service
@@ -19,6 +19,8 @@ NC='\033[0m' # No Color
 DAEMON_NAME="vpn-managerd"
 INSTALL_DIR="/usr/bin"
 SERVICE_DIR="/etc/systemd/system"
+DBUS_POLICY_FILE="/usr/share/dbus-1/system.d/com.vpnmanager.Daemon1.conf"
+POLKIT_POLICY_FILE="/usr/share/polkit-1/actions/com.vpnmanager.daemon.policy"
 SOCKET_DIR="/var/run/vpn-manager"
 
 # =============================================================================
@@ -55,6 +57,8 @@ check_root() {
 stop_service() {
     log_info "Stopping service..."
     
+    # The socket first, so a client cannot start the daemon again
+    systemctl stop "$DAEMON_NAME.socket" 2>/dev/null || true
     if systemctl is-active --quiet "$DAEMON_NAME" 2>/dev/null; then
         systemctl stop "$DAEMON_NAME"
         log_success "Service stopped"
@@ -67,7 +71,7 @@ disable_service() {
     log_info "Disabling service..."
     
     if systemctl is-enabled --quiet "$DAEMON_NAME" 2>/dev/null; then
-        systemctl disable "$DAEMON_NAME"
+        systemctl disable "$DAEMON_NAME" "$DAEMON_NAME.socket"
         log_success "Service disabled"
     else
         log_info "Service was not enabled"
@@ -80,12 +84,15 @@ remove_service_file() {
     SERVICE_FILE="$SERVICE_DIR/$DAEMON_NAME.service"
     
     if [[ -f "$SERVICE_FILE" ]]; then
-        rm -f "$SERVICE_FILE"
+        rm -f "$SERVICE_FILE" "$SERVICE_DIR/$DAEMON_NAME.socket"
         systemctl daemon-reload
         log_success "Service file removed"
     else
         log_info "Service file not found"
     fi
+    
+    # D-Bus bus policy and polkit actions
+    rm -f "$DBUS_POLICY_FILE" "$POLKIT_POLICY_FILE"
 }
 
 remove_binary() {
@@ -136,6 +143,8 @@ print_status() {
     echo "The following were removed:"
     echo "  - Binary:  $INSTALL_DIR/$DAEMON_NAME"
     echo "  - Service: $SERVICE_DIR/$DAEMON_NAME.service"
+    echo "  - D-Bus:   $DBUS_POLICY_FILE"
+    echo "  - Polkit:  $POLKIT_POLICY_FILE"
     echo "  - Socket:  $SOCKET_DIR/vpn-managerd.sock"
     echo ""
     echo "Note: The VPN Manager GUI/CLI will now use pkexec"
@@ -0,0 +1,120 @@
+// VPN Manager Agent - headless per-user session service.
+//
+// The agent runs as a systemd user unit and owns the user's VPN connections:
+// auto-reconnect, trusted-network rules, profile auto-connect and traffic
+// statistics keep working with no window open, on a minimal window manager or
+// over SSH. The GUI and vpnctl talk to it over an owner-only Unix socket in
+// $XDG_RUNTIME_DIR; privileged work still goes through vpn-managerd.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yllada/vpn-manager/internal/agent"
+	"github.com/yllada/vpn-manager/internal/config"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	applog "github.com/yllada/vpn-manager/internal/logger"
+	"github.com/yllada/vpn-manager/internal/metrics"
+	"github.com/yllada/vpn-manager/internal/vpn"
+)
+
+// Version information (set at build time via ldflags)
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+func main() {
+	socketPath := flag.String("socket", daemon.AgentSocketPath(), "Unix socket path")
+	verbose := flag.Bool("verbose", false, "Enable debug logging")
+	showVersion := flag.Bool("version", false, "Show version and exit")
+	metricsListen := flag.String("metrics-listen", "", "Serve Prometheus/OpenMetrics metrics on this loopback address (e.g. 127.0.0.1:9586)")
+	metricsTextfile := flag.String("metrics-textfile", "", "Write metrics to this .prom file for node_exporter's textfile collector")
+	metricsInterval := flag.Duration("metrics-interval", metrics.DefaultTextfileInterval, "How often the metrics textfile is rewritten")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("vpn-manager-agent %s (commit: %s, built: %s)\n", Version, GitCommit, BuildTime)
+		os.Exit(0)
+	}
+
+	// The agent acts for one desktop user; as root it would own root's
+	// profiles and keyring, which is never what was meant.
+	if os.Geteuid() == 0 {
+		log.Fatal("vpn-manager-agent runs as the desktop user, not root (use `systemctl --user`)")
+	}
+
+	logger := log.New(os.Stdout, "[vpn-manager-agent] ", log.LstdFlags|log.Lmsgprefix)
+	logger.Printf("Starting vpn-manager-agent %s", Version)
+	setupAppLogging(*verbose)
+
+	if daemon.IsAgentAvailable() && *socketPath == daemon.AgentSocketPath() {
+		logger.Fatalf("Another agent is already listening on %s", *socketPath)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Printf("WARN: failed to load config, using defaults: %v", err)
+		cfg = config.DefaultConfig()
+	}
+
+	manager, err := vpn.NewManager()
+	if err != nil {
+		logger.Fatalf("Failed to create VPN manager: %v", err)
+	}
+
+	a := agent.New(manager, cfg,
+		agent.WithSocketPath(*socketPath),
+		agent.WithLogger(logger),
+		agent.WithVersion(Version),
+		agent.WithMetricsListen(*metricsListen),
+		agent.WithMetricsTextfile(*metricsTextfile, *metricsInterval),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := a.Start(ctx); err != nil {
+		logger.Fatalf("Failed to start agent: %v", err)
+	}
+
+	// SIGHUP reloads the configuration; SIGINT/SIGTERM stop the agent.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			if err := a.Reload(); err != nil {
+				logger.Printf("Reload failed: %v", err)
+			}
+			continue
+		}
+		logger.Printf("Received signal %v, shutting down...", sig)
+		break
+	}
+
+	cancel()
+	if err := a.Stop(); err != nil {
+		logger.Printf("Error during shutdown: %v", err)
+	}
+	if sm := manager.StatsManager(); sm != nil {
+		_ = sm.Close()
+	}
+	logger.Println("Goodbye!")
+}
+
+// setupAppLogging sends the internal packages' logging to the journal (via
+// stdout) instead of the GUI's log file.
+func setupAppLogging(verbose bool) {
+	appLogger := applog.GetLogger()
+	appLogger.SetOutput(os.Stdout)
+	if verbose {
+		appLogger.SetLevel(applog.LevelDebug)
+	}
+}
@@ -215,14 +215,7 @@ func handleRecoverKillSwitch() {
 	// Set mode and enable
 	ks.SetMode(security.KillSwitchMode(state.Mode))
 
-	var enableErr error
-	if state.AllowLAN && len(state.LANRanges) > 0 {
-		enableErr = ks.EnableWithLAN(state.VPNIface, state.VPNServerIP, state.LANRanges)
-	} else {
-		enableErr = ks.Enable(state.VPNIface, state.VPNServerIP)
-	}
-
-	if enableErr != nil {
+	if enableErr := ks.EnableForServers(state.VPNIface, state.ServerIPs()); enableErr != nil {
 		logger.LogError("Failed to enable kill switch: %v", enableErr)
 		os.Exit(1)
 	}
@@ -8,8 +8,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -17,7 +19,6 @@ import (
 
 	"github.com/yllada/vpn-manager/daemon"
 	"github.com/yllada/vpn-manager/daemon/privileged"
-	"github.com/yllada/vpn-manager/daemon/privileged/tailscale"
 	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
@@ -32,7 +33,17 @@ func main() {
 	// Parse flags
 	socketPath := flag.String("socket", protocol.DefaultSocketPath, "Unix socket path")
 	socketGroup := flag.String("socket-group", daemon.DefaultSocketGroup, "System group granted access to the socket (mode 0660)")
+	identityPolicyPath := flag.String("caller-identity", daemon.DefaultIdentityPolicyPath, "Caller-identity (executable allowlist) policy file; ignored if absent")
+	methodPolicyPath := flag.String("policy", daemon.DefaultMethodPolicyPath, "Per-method authorization policy file (reloaded on SIGHUP); ignored if absent")
+	auditLogPath := flag.String("audit-log", daemon.DefaultAuditLogPath, "Audit trail of privileged calls (JSON lines); empty disables it")
+	auditMaxSize := flag.Int64("audit-max-size", daemon.DefaultAuditMaxSize, "Size in bytes at which the audit trail is rotated")
+	auditKeep := flag.Int("audit-keep", daemon.DefaultAuditKeep, "Number of rotated audit files to keep")
+	statePath := flag.String("state", daemon.DefaultStatePath, "File the daemon state is persisted to across restarts")
+	sessionPolicy := flag.String("session-policy", string(privileged.DefaultSessionPolicy), "What happens to a user's tunnels, split tunnel and LAN gateway when they log out or another user takes the seat: keep (leave them up), disconnect or killswitch (disconnect but keep the kill switch)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Exit after this long with no clients, tunnels or firewall rules (socket activation only; 0 never exits)")
+	enableDBus := flag.Bool("dbus", true, "Also serve the API on the system bus as "+daemon.DBusName)
 	showVersion := flag.Bool("version", false, "Show version and exit")
+	describe := flag.Bool("describe", false, "Print the JSON Schema description of the RPC methods (system.describe) and exit")
 	flag.Parse()
 
 	// Allow overriding the socket group via environment (packaging / systemd unit).
@@ -45,6 +56,18 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *describe {
+		if err := writeDescription(os.Stdout); err != nil {
+			log.Fatalf("describe: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	onLogout, err := privileged.ParseSessionPolicy(*sessionPolicy)
+	if err != nil {
+		log.Fatalf("--session-policy: %v", err)
+	}
+
 	// Check if running as root
 	if os.Geteuid() != 0 {
 		log.Fatal("vpn-managerd must run as root")
@@ -54,15 +77,74 @@ func main() {
 	logger := log.New(os.Stdout, "[vpn-managerd] ", log.LstdFlags|log.Lmsgprefix)
 	logger.Printf("Starting vpn-managerd %s", Version)
 
+	// Load the optional caller-identity policy. A broken policy is fatal: falling
+	// back to "no verification" would silently drop a security control.
+	identityPolicy, err := daemon.LoadIdentityPolicy(*identityPolicyPath)
+	if err != nil {
+		logger.Fatalf("Failed to load caller identity policy: %v", err)
+	}
+	if identityPolicy != nil {
+		logger.Printf("Caller identity verification: public=%s privileged=%s (%d allowed executables)",
+			identityPolicy.Public, identityPolicy.Privileged, len(identityPolicy.Allow))
+	}
+
+	// Same for the per-method policy: a broken file must not open every method.
+	methodPolicy, err := daemon.LoadMethodPolicy(*methodPolicyPath)
+	if err != nil {
+		logger.Fatalf("Failed to load method policy: %v", err)
+	}
+	logMethodPolicy(logger, methodPolicy)
+
+	// The audit trail is a record, not a gate: if it cannot be opened the
+	// daemon still serves, with privileged calls only in the journal.
+	var auditLog *daemon.AuditLog
+	if *auditLogPath != "" {
+		auditLog, err = daemon.OpenAuditLog(*auditLogPath, *auditMaxSize, *auditKeep)
+		if err != nil {
+			logger.Printf("WARN: audit trail not available: %v", err)
+		} else {
+			defer func() { _ = auditLog.Close() }()
+			logger.Printf("Audit trail: %s", *auditLogPath)
+		}
+	}
+
+	// Under socket activation systemd has already created and secured the
+	// socket (vpn-managerd.socket); otherwise the server creates its own.
+	activated, err := daemon.SystemdListener()
+	if err != nil {
+		logger.Fatalf("Socket activation: %v", err)
+	}
+
 	// Create server
-	server := daemon.NewServer(
+	serverOpts := []daemon.ServerOption{
 		daemon.WithSocketPath(*socketPath),
 		daemon.WithSocketGroup(*socketGroup),
 		daemon.WithLogger(logger),
-	)
+		daemon.WithCallerIdentity(identityPolicy),
+		daemon.WithMethodPolicy(methodPolicy),
+		daemon.WithAuditLog(auditLog),
+		daemon.WithStatePath(*statePath),
+		daemon.WithVersion(Version),
+		daemon.WithCapabilities(privileged.Capabilities),
+	}
+	if activated != nil {
+		serverOpts = append(serverOpts, daemon.WithListener(activated))
+	}
+	server := daemon.NewServer(serverOpts...)
 
 	// Register privileged operation handlers
-	registerPrivilegedHandlers(server)
+	privileged.RegisterHandlers(server.Handlers(), server.State())
+
+	// Record asynchronous state changes (OpenVPN connect/drop) and push them
+	// to subscribers
+	privileged.PublishEvents(server.State(), server.Events(), logger)
+
+	// Re-adopt tunnels and rules left by a previous instance (or tear down the
+	// orphans) before clients can observe the state.
+	privileged.Reconcile(server.State(), logger)
+
+	// Undo a transaction a crash interrupted halfway
+	privileged.RecoverTransaction(server.State(), server.Events(), logger)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -73,18 +155,64 @@ func main() {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 
+	// Keep the system in line with the posture set by security.apply
+	privileged.StartSecurityReconciler(ctx, server.State(), server.Events(), logger)
+
+	// Take down what a user leaves behind when they log out or switch away
+	privileged.StartSessionWatcher(ctx, server.State(), server.Events(), logger, onLogout)
+
+	// Serve the same API on the system bus for desktop integrations. Optional:
+	// the socket works without it (no bus, policy not installed).
+	var dbusService *daemon.DBusService
+	if *enableDBus {
+		dbusService = daemon.NewDBusService(server)
+		if err := dbusService.Start(); err != nil {
+			logger.Printf("WARN: D-Bus service not available: %v", err)
+			dbusService = nil
+		}
+	}
+
+	// Only a socket-activated daemon may exit when idle: systemd starts it
+	// again on the next connection. Without activation nothing would.
+	if *idleTimeout > 0 && activated == nil {
+		logger.Printf("WARN: --idle-timeout ignored: the daemon was not socket-activated")
+		*idleTimeout = 0
+	}
+	idle := server.Supervise(ctx, *idleTimeout)
+
+	if err := daemon.Notify("READY=1\nSTATUS=Serving"); err != nil {
+		logger.Printf("WARN: %v", err)
+	}
+
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Wait for shutdown signal
-	sig := <-sigChan
-	logger.Printf("Received signal %v, shutting down...", sig)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	// Wait for a shutdown signal or the idle timeout; SIGHUP reloads the
+	// method policy
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reloadMethodPolicy(logger, server, *methodPolicyPath)
+				continue
+			}
+			logger.Printf("Received signal %v, shutting down...", sig)
+			break wait
+		case <-idle:
+			break wait
+		}
+	}
+	_ = daemon.Notify("STOPPING=1")
 
 	// Cancel context
 	cancel()
 
 	// Stop server
+	if dbusService != nil {
+		dbusService.Stop()
+	}
 	if err := server.Stop(); err != nil {
 		logger.Printf("Error during shutdown: %v", err)
 	}
@@ -92,55 +220,34 @@ func main() {
 	logger.Println("Goodbye!")
 }
 
-// registerPrivilegedHandlers registers all handlers for privileged operations.
-func registerPrivilegedHandlers(server *daemon.Server) {
-	handlers := server.Handlers()
-	state := server.State()
-
-	// Kill switch handlers
-	handlers.Register("killswitch.enable", privileged.KillSwitchEnableHandler(state))
-	handlers.Register("killswitch.disable", privileged.KillSwitchDisableHandler(state))
-	handlers.Register("killswitch.status", privileged.KillSwitchStatusHandler(state))
-	handlers.Register("killswitch.block_all", privileged.KillSwitchBlockAllHandler(state))
-
-	// DNS protection handlers
-	handlers.Register("dns.enable", privileged.DNSEnableHandler(state))
-	handlers.Register("dns.disable", privileged.DNSDisableHandler(state))
-	handlers.Register("dns.status", privileged.DNSStatusHandler(state))
-
-	// IPv6 protection handlers
-	handlers.Register("ipv6.enable", privileged.IPv6EnableHandler(state))
-	handlers.Register("ipv6.disable", privileged.IPv6DisableHandler(state))
-	handlers.Register("ipv6.status", privileged.IPv6StatusHandler(state))
-
-	// Split tunnel handlers
-	handlers.Register("tunnel.setup", privileged.TunnelSetupHandler(state))
-	handlers.Register("tunnel.cleanup", privileged.TunnelCleanupHandler(state))
-	handlers.Register("tunnel.status", privileged.TunnelStatusHandler(state))
-
-	// LAN gateway handlers
-	handlers.Register("gateway.enable", privileged.GatewayEnableHandler(state))
-	handlers.Register("gateway.disable", privileged.GatewayDisableHandler(state))
-	handlers.Register("gateway.status", privileged.GatewayStatusHandler(state))
-
-	// OpenVPN handlers
-	handlers.Register("openvpn.connect", privileged.OpenVPNConnectHandler(state))
-	handlers.Register("openvpn.disconnect", privileged.OpenVPNDisconnectHandler(state))
-	handlers.Register("openvpn.status", privileged.OpenVPNStatusHandler(state))
-	handlers.Register("openvpn.list", privileged.OpenVPNListHandler(state))
-
-	// WireGuard handlers
-	handlers.Register("wireguard.connect", privileged.WireGuardConnectHandler(state))
-	handlers.Register("wireguard.disconnect", privileged.WireGuardDisconnectHandler(state))
-	handlers.Register("wireguard.status", privileged.WireGuardStatusHandler(state))
-	handlers.Register("wireguard.list", privileged.WireGuardListHandler(state))
-
-	// Tailscale handlers
-	handlers.Register("tailscale.up", tailscale.UpHandler(state))
-	handlers.Register("tailscale.down", tailscale.DownHandler(state))
-	handlers.Register("tailscale.set", tailscale.SetHandler(state))
-	handlers.Register("tailscale.login", tailscale.LoginHandler(state))
-	handlers.Register("tailscale.logout", tailscale.LogoutHandler(state))
-	handlers.Register("tailscale.set_operator", tailscale.SetOperatorHandler(state))
-	handlers.Register("taildrop.send", tailscale.TaildropSendHandler(state))
+// writeDescription prints what system.describe returns without starting the
+// daemon. pkg/protocol/api is generated from this output.
+func writeDescription(w io.Writer) error {
+	server := daemon.NewServer(daemon.WithLogger(log.New(io.Discard, "", 0)))
+	privileged.RegisterHandlers(server.Handlers(), server.State())
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(server.Handlers().Describe())
+}
+
+// reloadMethodPolicy re-reads the method policy. A file that fails to load
+// keeps the previous policy in force rather than opening every method.
+func reloadMethodPolicy(logger *log.Logger, server *daemon.Server, path string) {
+	policy, err := daemon.LoadMethodPolicy(path)
+	if err != nil {
+		logger.Printf("WARN: method policy not reloaded, keeping the previous one: %v", err)
+		return
+	}
+	server.SetMethodPolicy(policy)
+	logger.Printf("Method policy reloaded")
+	logMethodPolicy(logger, policy)
+}
+
+func logMethodPolicy(logger *log.Logger, policy *daemon.MethodPolicy) {
+	if policy == nil {
+		logger.Printf("Method policy: none, every method open to the socket group")
+		return
+	}
+	logger.Printf("Method policy: %d rules, default %s", len(policy.Rules), policy.Default)
 }
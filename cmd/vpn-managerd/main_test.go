@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDescriptionUpToDate fails when a handler's param or result types changed
+// without regenerating pkg/protocol/api, i.e. when the typed client would no
+// longer match the daemon.
+func TestDescriptionUpToDate(t *testing.T) {
+	var got bytes.Buffer
+	if err := writeDescription(&got); err != nil {
+		t.Fatalf("writeDescription: %v", err)
+	}
+
+	want, err := os.ReadFile(filepath.Join("..", "..", "pkg", "protocol", "api", "describe.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Error("pkg/protocol/api/describe.json is stale; run go generate ./pkg/protocol/api")
+	}
+}
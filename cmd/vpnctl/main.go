@@ -0,0 +1,224 @@
+// Package main provides vpnctl, the headless command-line client for VPN
+// Manager. It drives the same daemon (vpn-managerd) and profile store as the
+// GTK application, so servers and SSH sessions can connect, inspect and
+// control VPNs without a desktop session.
+//
+// Usage:
+//
+//	vpnctl [--json] [--verbose] <command> [arguments]
+//
+// Every command prints human-readable text by default and a single JSON
+// document with --json. The exit status tells scripts what happened; see the
+// exit* constants below (also listed by "vpnctl help").
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/logger"
+	"github.com/yllada/vpn-manager/internal/vpn"
+)
+
+// Build-time variables injected via ldflags (-X main.appVersion=x.y.z)
+var (
+	appVersion = "dev"
+	buildTime  = "unknown"
+	commitSHA  = "unknown"
+)
+
+// Exit codes. They are part of the scripting interface: do not renumber.
+const (
+	exitOK       = 0 // success
+	exitFailure  = 1 // the operation failed
+	exitUsage    = 2 // invalid command line
+	exitNoDaemon = 3 // vpn-managerd is not running or unreachable
+	exitNotFound = 4 // no such profile, rule, node or connection
+	exitDenied   = 5 // the daemon refused the call (socket group, policy)
+	exitAuth     = 6 // the VPN server rejected the credentials
+	exitTimeout  = 7 // gave up waiting (e.g. connect --timeout)
+)
+
+// command is a top-level vpnctl command.
+type command struct {
+	usage   string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+// commands is filled in init to break the initialization cycle with
+// cmdHelp, which lists them.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"connect":    {"connect <profile> [flags]", "Connect an OpenVPN profile", cmdConnect},
+		"disconnect": {"disconnect [<profile> | --all]", "Disconnect a VPN connection", cmdDisconnect},
+		"status":     {"status", "Show connections and protection status", cmdStatus},
+		"profiles":   {"profiles list | import <file> | delete <profile>", "Manage OpenVPN profiles", cmdProfiles},
+		"killswitch": {"killswitch status | enable | block-all | disable", "Control the kill switch", cmdKillSwitch},
+		"dns":        {"dns status | enable | disable", "Control DNS leak protection", cmdDNS},
+		"ipv6":       {"ipv6 status | enable | disable", "Control IPv6 leak protection", cmdIPv6},
+		"trust":      {"trust rules [list | add | remove <id>]", "Manage network trust rules", cmdTrust},
+		"stats":      {"stats [--sessions N] [--days N]", "Show traffic statistics", cmdStats},
+		"tailscale":  {"tailscale exit-node [list | set <node> | clear | suggest]", "Choose the Tailscale exit node", cmdTailscale},
+		"version":    {"version", "Show version information", cmdVersion},
+		"help":       {"help", "Show this help", cmdHelp},
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes one vpnctl invocation and returns its exit code.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("vpnctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&c.json, "json", false, "Print machine-readable JSON")
+	fs.BoolVar(&c.verbose, "verbose", false, "Log diagnostics to stderr")
+	showVersion := fs.Bool("version", false, "Show version and exit")
+	fs.Usage = func() { c.printUsage(stderr) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	c.setupLogging()
+	defer c.close()
+
+	args = fs.Args()
+	if *showVersion {
+		args = []string{"version"}
+	}
+	if len(args) == 0 {
+		c.printUsage(stderr)
+		return exitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return c.fail(usageErrorf("unknown command %q (see 'vpnctl help')", args[0]))
+	}
+	if err := cmd.run(c, args[1:]); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// setupLogging keeps library logging off stdout, which belongs to command
+// output (and must stay valid JSON with --json). Diagnostics go to stderr
+// with --verbose and are dropped otherwise.
+func (c *cli) setupLogging() {
+	appLogger := logger.GetLogger()
+	if c.verbose {
+		appLogger.SetOutput(c.stderr)
+		appLogger.SetLevel(logger.LevelDebug)
+		log.SetOutput(c.stderr)
+		return
+	}
+	appLogger.SetOutput(io.Discard)
+	log.SetOutput(io.Discard)
+}
+
+// close releases what the invocation opened (the stats database).
+func (c *cli) close() {
+	if c.manager != nil {
+		if sm := c.manager.StatsManager(); sm != nil {
+			_ = sm.Close()
+		}
+	}
+}
+
+// vpnManager returns the VPN manager, creating it on first use. Commands that
+// only talk to the daemon never pay for loading profiles and the stats store.
+func (c *cli) vpnManager() (*vpn.Manager, error) {
+	if c.manager != nil {
+		return c.manager, nil
+	}
+	m, err := vpn.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	// With the session agent running it owns the connections; vpnctl only
+	// asks it to connect and follows the result.
+	if daemon.IsAgentAvailable() {
+		m.UseSessionAgent()
+	}
+	c.manager = m
+	return m, nil
+}
+
+func (c *cli) printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, _ = fmt.Fprintf(w, "Usage: vpnctl [--json] [--verbose] <command> [arguments]\n\nCommands:\n")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].summary)
+	}
+	_, _ = fmt.Fprintf(w, `
+Run 'vpnctl <command> -h' for the flags of a command.
+
+Exit status:
+  %d  success
+  %d  the operation failed
+  %d  invalid command line
+  %d  vpn-managerd is not running
+  %d  no such profile, rule, node or connection
+  %d  permission denied by the daemon
+  %d  VPN authentication failed
+  %d  timed out
+`, exitOK, exitFailure, exitUsage, exitNoDaemon, exitNotFound, exitDenied, exitAuth, exitTimeout)
+}
+
+func cmdHelp(c *cli, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			_, _ = fmt.Fprintf(c.stdout, "Usage: vpnctl %s\n\n%s\n", cmd.usage, cmd.summary)
+			return nil
+		}
+		return usageErrorf("unknown command %q", args[0])
+	}
+	c.printUsage(c.stdout)
+	return nil
+}
+
+// versionInfo is the --json form of "vpnctl version".
+type versionInfo struct {
+	Version   string `json:"version"`
+	BuildTime string `json:"build_time,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+func cmdVersion(c *cli, args []string) error {
+	info := versionInfo{Version: appVersion}
+	if buildTime != "unknown" {
+		info.BuildTime, info.Commit = buildTime, commitSHA
+	}
+	return c.emit(info, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "vpnctl v%s\n", info.Version)
+		if info.BuildTime != "" {
+			_, _ = fmt.Fprintf(w, "  Build:  %s\n  Commit: %s\n", info.BuildTime, info.Commit)
+		}
+	})
+}
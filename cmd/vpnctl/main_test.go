@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/internal/vpn/trust"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"explicit", usageErrorf("bad"), exitUsage},
+		{"wrapped explicit", fmt.Errorf("ctx: %w", notFoundErrorf("gone")), exitNotFound},
+		{"profile not found", fmt.Errorf("x: %w", profile.ErrProfileNotFound), exitNotFound},
+		{"rule not found", trust.ErrRuleNotFound, exitNotFound},
+		{"invalid rule", fmt.Errorf("%w: SSID is required", trust.ErrInvalidRule), exitUsage},
+		{"daemon down", fmt.Errorf("daemon not available: %w", protocol.ErrDaemonUnavailable), exitNoDaemon},
+		{"unauthorized", &protocol.RPCError{Code: protocol.ErrCodeUnauthorized}, exitDenied},
+		{"daemon timeout", &protocol.RPCError{Code: protocol.ErrCodeTimeout}, exitTimeout},
+		{"operation failed", &protocol.RPCError{Code: protocol.ErrCodeOperationFailed}, exitFailure},
+		{"deadline", context.DeadlineExceeded, exitTimeout},
+		{"other", errors.New("boom"), exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgsInterspersed(t *testing.T) {
+	c := &cli{stderr: io.Discard}
+	tests := []struct {
+		args     []string
+		wantPos  []string
+		wantWait bool
+	}{
+		{[]string{"work", "--no-wait"}, []string{"work"}, true},
+		{[]string{"--no-wait", "work"}, []string{"work"}, true},
+		{[]string{"a", "--no-wait", "b"}, []string{"a", "b"}, true},
+		{[]string{"--", "--no-wait"}, []string{"--no-wait"}, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		fs := c.newFlagSet("test", "test")
+		noWait := fs.Bool("no-wait", false, "")
+		pos, err := c.parseArgs(fs, tt.args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", tt.args, err)
+		}
+		if strings.Join(pos, ",") != strings.Join(tt.wantPos, ",") || *noWait != tt.wantWait {
+			t.Errorf("parseArgs(%q) = %q, no-wait=%v; want %q, %v", tt.args, pos, *noWait, tt.wantPos, tt.wantWait)
+		}
+	}
+
+	fs := c.newFlagSet("test", "test")
+	if _, err := c.parseArgs(fs, []string{"--bogus"}); exitCode(err) != exitUsage {
+		t.Errorf("unknown flag: err = %v, want a usage error", err)
+	}
+}
+
+type fakeProfiles []*profile.Profile
+
+func (f fakeProfiles) List() []*profile.Profile { return f }
+
+func TestResolveProfile(t *testing.T) {
+	profiles := fakeProfiles{
+		{ID: "3f2a9c10-aaaa", Name: "Work"},
+		{ID: "7b81d2e4-bbbb", Name: "work"},
+		{ID: "c09e55aa-cccc", Name: "Home"},
+	}
+	tests := []struct {
+		ref     string
+		wantID  string
+		wantErr int
+	}{
+		{ref: "c09e55aa-cccc", wantID: "c09e55aa-cccc"},
+		{ref: "Work", wantID: "3f2a9c10-aaaa"}, // exact name beats case-insensitive
+		{ref: "home", wantID: "c09e55aa-cccc"},
+		{ref: "7b81", wantID: "7b81d2e4-bbbb"},
+		{ref: "WORK", wantErr: exitUsage}, // ambiguous
+		{ref: "7b8", wantErr: exitNotFound},
+		{ref: "office", wantErr: exitNotFound},
+	}
+	for _, tt := range tests {
+		p, err := resolveProfile(profiles, tt.ref)
+		if tt.wantErr != 0 {
+			if exitCode(err) != tt.wantErr {
+				t.Errorf("resolveProfile(%q) err = %v, want exit %d", tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || p.ID != tt.wantID {
+			t.Errorf("resolveProfile(%q) = %v, %v; want %s", tt.ref, p, err, tt.wantID)
+		}
+	}
+}
+
+func TestRunJSONErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"--json", "frobnicate"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitUsage {
+		t.Errorf("exit code = %d, want %d", code, exitUsage)
+	}
+	var res errorResult
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		t.Fatalf("stdout is not a JSON error: %q", stdout.String())
+	}
+	if res.ExitCode != exitUsage || !strings.Contains(res.Error, "frobnicate") {
+		t.Errorf("error result = %+v", res)
+	}
+}
+
+func TestReadLineStopsAtNewline(t *testing.T) {
+	r := strings.NewReader("secret\r\nrest")
+	line, err := readLine(r)
+	if err != nil || line != "secret" {
+		t.Fatalf("readLine = %q, %v", line, err)
+	}
+	tail, _ := io.ReadAll(r)
+	if string(tail) != "rest" {
+		t.Errorf("readLine consumed past the line: left %q", tail)
+	}
+}
+
+// TestChallengeAnswerer checks --otp answers the server's challenge once, and
+// that without a terminal a second one is a usage error.
+func TestChallengeAnswerer(t *testing.T) {
+	c := &cli{stdin: strings.NewReader("")}
+	ch := &daemon.OpenVPNChallenge{Text: "Enter your token code"}
+
+	answer := c.challengeAnswerer(&profile.Profile{Name: "Work", StaticChallenge: "Enter your token code"}, "123456", false)
+	if got, err := answer(ch); err != nil || got != "123456" {
+		t.Fatalf("first answer = %q, %v; want the --otp code", got, err)
+	}
+	if _, err := answer(ch); exitCode(err) != exitUsage {
+		t.Errorf("second answer err = %v, want a usage error", err)
+	}
+
+	// The code went out appended to the password: not reused.
+	answer = c.challengeAnswerer(&profile.Profile{Name: "Work", RequiresOTP: true}, "123456", false)
+	if _, err := answer(ch); exitCode(err) != exitUsage {
+		t.Errorf("answer after an appended code err = %v, want a usage error", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/internal/vpn/trust"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// cli is the state of one vpnctl invocation.
+type cli struct {
+	ctx     context.Context
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	json    bool
+	verbose bool
+
+	manager *vpn.Manager // lazily created, see vpnManager
+}
+
+// =============================================================================
+// OUTPUT
+// =============================================================================
+
+// emit prints a command result: v as indented JSON with --json, otherwise
+// whatever human writes.
+func (c *cli) emit(v any, human func(w io.Writer)) error {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	human(c.stdout)
+	return nil
+}
+
+// table returns a tabwriter for aligned human output. Callers must Flush it.
+func table(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+// onOff renders a boolean for human output.
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// runningOrNot renders a service's availability for human output.
+func runningOrNot(b bool) string {
+	if b {
+		return "running"
+	}
+	return "not running"
+}
+
+// orDash renders an optional value for human output.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// =============================================================================
+// ERRORS AND EXIT CODES
+// =============================================================================
+
+// cliError carries an explicit exit code.
+type cliError struct {
+	code int
+	err  error
+	// reported is set when the command already told the user (flag parse
+	// errors print themselves with the usage; status prints its result).
+	reported bool
+}
+
+func (e *cliError) Error() string { return e.err.Error() }
+func (e *cliError) Unwrap() error { return e.err }
+
+func usageErrorf(format string, args ...any) error {
+	return &cliError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func notFoundErrorf(format string, args ...any) error {
+	return &cliError{code: exitNotFound, err: fmt.Errorf(format, args...)}
+}
+
+// errorResult is the --json form of a failed command.
+type errorResult struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// fail reports err and returns the exit code for it. With --json the error is
+// printed to stdout as a JSON document so scripts always get one.
+func (c *cli) fail(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	code := exitCode(err)
+	var ce *cliError
+	if errors.As(err, &ce) && ce.reported {
+		return code
+	}
+	if c.json {
+		_ = c.emit(errorResult{Error: err.Error(), ExitCode: code}, nil)
+		return code
+	}
+	_, _ = fmt.Fprintf(c.stderr, "vpnctl: %v\n", err)
+	if code == exitNoDaemon {
+		_, _ = fmt.Fprintln(c.stderr, "Is vpn-managerd running? Check: systemctl status vpn-managerd")
+	}
+	return code
+}
+
+// exitCode maps an error to the documented exit status.
+func exitCode(err error) int {
+	var ce *cliError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+
+	var rpcErr *protocol.RPCError
+	if errors.As(err, &rpcErr) {
+		switch rpcErr.Code {
+		case protocol.ErrCodeUnauthorized:
+			return exitDenied
+		case protocol.ErrCodeTimeout:
+			return exitTimeout
+		}
+	}
+
+	switch {
+	case errors.Is(err, trust.ErrInvalidRule):
+		return exitUsage
+	case errors.Is(err, profile.ErrProfileNotFound), errors.Is(err, trust.ErrRuleNotFound):
+		return exitNotFound
+	case errors.Is(err, protocol.ErrDaemonUnavailable), errors.Is(err, syscall.ECONNREFUSED):
+		return exitNoDaemon
+	case errors.Is(err, protocol.ErrUnauthorized), errors.Is(err, os.ErrPermission):
+		// os.ErrPermission: the socket is group-restricted and the caller is
+		// not in the vpn-manager group.
+		return exitDenied
+	case errors.Is(err, protocol.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	}
+	return exitFailure
+}
+
+// requireDaemon fails fast with exitNoDaemon when vpn-managerd is not running,
+// instead of letting each call time out or fall back.
+func requireDaemon() error {
+	if !daemon.IsDaemonAvailable() {
+		return &cliError{code: exitNoDaemon, err: errors.New("vpn-managerd is not running")}
+	}
+	return nil
+}
+
+// =============================================================================
+// ARGUMENT PARSING
+// =============================================================================
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead
+// of exiting.
+func (c *cli) newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(c.stderr, "Usage: vpnctl %s\n", usage)
+		if hasFlags(fs) {
+			_, _ = fmt.Fprintln(c.stderr, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+// parseArgs parses args with fs, allowing flags before, between and after the
+// positional arguments ("connect work --timeout 2m" as well as
+// "connect --timeout 2m work"). A literal "--" ends flag parsing.
+func (c *cli) parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			// The flag package already printed the error and usage to
+			// stderr; with --json the error still goes out as JSON.
+			return nil, &cliError{code: exitUsage, err: err, reported: !c.json}
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// expectArgs checks the number of positional arguments.
+func expectArgs(args []string, min, max int, usage string) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return usageErrorf("usage: vpnctl %s", usage)
+	}
+	return nil
+}
+
+// splitList parses a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
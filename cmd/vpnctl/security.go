@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+)
+
+// The protection commands talk to vpn-managerd directly. Changes made here are
+// imperative: like the equivalent GUI toggles they take over from any
+// declarative posture the daemon was enforcing.
+
+// protectionStatus prints a daemon status map: as-is with --json, as sorted
+// key/value lines otherwise.
+func (c *cli) protectionStatus(status map[string]any) error {
+	return c.emit(status, func(w io.Writer) {
+		keys := make([]string, 0, len(status))
+		for k := range status {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		tw := table(w)
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(tw, "%s:\t%v\n", k, status[k])
+		}
+		_ = tw.Flush()
+	})
+}
+
+// toggleResult is the --json form of an enable/disable command.
+type toggleResult struct {
+	Feature string `json:"feature"`
+	Enabled bool   `json:"enabled"`
+	Backend string `json:"backend,omitempty"`
+}
+
+func (c *cli) toggled(res toggleResult, label string) error {
+	return c.emit(res, func(w io.Writer) {
+		state := "disabled"
+		if res.Enabled {
+			state = "enabled"
+		}
+		_, _ = fmt.Fprintf(w, "%s %s\n", label, state)
+	})
+}
+
+// =============================================================================
+// KILL SWITCH
+// =============================================================================
+
+func cmdKillSwitch(c *cli, args []string) error {
+	sub, rest := "status", args
+	if len(args) > 0 {
+		sub, rest = args[0], args[1:]
+	}
+
+	switch sub {
+	case "status":
+		return c.simpleProtection(rest, "killswitch status", func() error {
+			st, err := (&daemon.KillSwitchClient{}).Status()
+			if err != nil {
+				return err
+			}
+			return c.protectionStatus(st)
+		})
+
+	case "enable":
+		const usage = "killswitch enable --iface IFACE [--server IP] [--allow-lan] [--lan-ranges CIDR,...]"
+		fs := c.newFlagSet("killswitch enable", usage)
+		iface := fs.String("iface", "", "VPN interface whose traffic is allowed (e.g. tun0)")
+		server := fs.String("server", "", "VPN server IP to keep reachable")
+		allowLAN := fs.Bool("allow-lan", false, "Allow traffic to the local network")
+		lanRanges := fs.String("lan-ranges", "", "Comma-separated LAN ranges (default: private ranges)")
+		pos, err := c.parseArgs(fs, rest)
+		if err != nil {
+			return err
+		}
+		if err := expectArgs(pos, 0, 0, usage); err != nil {
+			return err
+		}
+		if *iface == "" {
+			return usageErrorf("--iface is required")
+		}
+		if err := requireDaemon(); err != nil {
+			return err
+		}
+		res, err := (&daemon.KillSwitchClient{}).Enable(daemon.KillSwitchEnableParams{
+			VPNInterface: *iface,
+			VPNServerIP:  *server,
+			AllowLAN:     *allowLAN,
+			LANRanges:    splitList(*lanRanges),
+		})
+		if err != nil {
+			return err
+		}
+		return c.toggled(toggleResult{Feature: "killswitch", Enabled: res.Enabled, Backend: res.Backend}, "Kill switch")
+
+	case "block-all":
+		return c.simpleProtection(rest, "killswitch block-all", func() error {
+			res, err := (&daemon.KillSwitchClient{}).EnableBlockAll()
+			if err != nil {
+				return err
+			}
+			return c.toggled(toggleResult{Feature: "killswitch", Enabled: res.Enabled, Backend: res.Backend}, "Kill switch (block all)")
+		})
+
+	case "disable":
+		return c.simpleProtection(rest, "killswitch disable", func() error {
+			if err := (&daemon.KillSwitchClient{}).Disable(); err != nil {
+				return err
+			}
+			return c.toggled(toggleResult{Feature: "killswitch"}, "Kill switch")
+		})
+	}
+	return usageErrorf("unknown killswitch command %q (status, enable, block-all, disable)", sub)
+}
+
+// simpleProtection runs a subcommand that takes no arguments and needs the
+// daemon.
+func (c *cli) simpleProtection(args []string, usage string, run func() error) error {
+	fs := c.newFlagSet(usage, usage)
+	pos, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(pos, 0, 0, usage); err != nil {
+		return err
+	}
+	if err := requireDaemon(); err != nil {
+		return err
+	}
+	return run()
+}
+
+// =============================================================================
+// DNS PROTECTION
+// =============================================================================
+
+func cmdDNS(c *cli, args []string) error {
+	sub, rest := "status", args
+	if len(args) > 0 {
+		sub, rest = args[0], args[1:]
+	}
+
+	switch sub {
+	case "status":
+		return c.simpleProtection(rest, "dns status", func() error {
+			st, err := (&daemon.DNSProtectionClient{}).Status()
+			if err != nil {
+				return err
+			}
+			return c.protectionStatus(st)
+		})
+
+	case "enable":
+		const usage = "dns enable --iface IFACE --servers IP,... [--mode MODE] [--leak-block] [--block-dot] [--block-doh]"
+		fs := c.newFlagSet("dns enable", usage)
+		iface := fs.String("iface", "", "VPN interface DNS must go through (e.g. tun0)")
+		servers := fs.String("servers", "", "Comma-separated DNS servers")
+		mode := fs.String("mode", "custom", "Protection mode: auto, strict or custom")
+		leakBlock := fs.Bool("leak-block", true, "Block DNS traffic outside the VPN interface")
+		blockDoT := fs.Bool("block-dot", false, "Block DNS-over-TLS")
+		blockDoH := fs.Bool("block-doh", false, "Block known DNS-over-HTTPS resolvers")
+		pos, err := c.parseArgs(fs, rest)
+		if err != nil {
+			return err
+		}
+		if err := expectArgs(pos, 0, 0, usage); err != nil {
+			return err
+		}
+		if *iface == "" {
+			return usageErrorf("--iface is required")
+		}
+		if err := requireDaemon(); err != nil {
+			return err
+		}
+		err = (&daemon.DNSProtectionClient{}).Enable(daemon.DNSEnableParams{
+			VPNInterface: *iface,
+			Servers:      splitList(*servers),
+			Mode:         *mode,
+			BlockDoT:     *blockDoT,
+			BlockDoH:     *blockDoH,
+			LeakBlocking: *leakBlock,
+		})
+		if err != nil {
+			return err
+		}
+		return c.toggled(toggleResult{Feature: "dns", Enabled: true}, "DNS protection")
+
+	case "disable":
+		return c.simpleProtection(rest, "dns disable", func() error {
+			if err := (&daemon.DNSProtectionClient{}).Disable(); err != nil {
+				return err
+			}
+			return c.toggled(toggleResult{Feature: "dns"}, "DNS protection")
+		})
+	}
+	return usageErrorf("unknown dns command %q (status, enable, disable)", sub)
+}
+
+// =============================================================================
+// IPV6 PROTECTION
+// =============================================================================
+
+func cmdIPv6(c *cli, args []string) error {
+	sub, rest := "status", args
+	if len(args) > 0 {
+		sub, rest = args[0], args[1:]
+	}
+
+	switch sub {
+	case "status":
+		return c.simpleProtection(rest, "ipv6 status", func() error {
+			st, err := (&daemon.IPv6ProtectionClient{}).Status()
+			if err != nil {
+				return err
+			}
+			return c.protectionStatus(st)
+		})
+
+	case "enable":
+		const usage = "ipv6 enable [--mode block|auto]"
+		fs := c.newFlagSet("ipv6 enable", usage)
+		mode := fs.String("mode", "block", "Protection mode: block or auto")
+		pos, err := c.parseArgs(fs, rest)
+		if err != nil {
+			return err
+		}
+		if err := expectArgs(pos, 0, 0, usage); err != nil {
+			return err
+		}
+		if err := requireDaemon(); err != nil {
+			return err
+		}
+		if err := (&daemon.IPv6ProtectionClient{}).Enable(daemon.IPv6EnableParams{Mode: *mode}); err != nil {
+			return err
+		}
+		return c.toggled(toggleResult{Feature: "ipv6", Enabled: true}, "IPv6 protection")
+
+	case "disable":
+		return c.simpleProtection(rest, "ipv6 disable", func() error {
+			if err := (&daemon.IPv6ProtectionClient{}).Disable(); err != nil {
+				return err
+			}
+			return c.toggled(toggleResult{Feature: "ipv6"}, "IPv6 protection")
+		})
+	}
+	return usageErrorf("unknown ipv6 command %q (status, enable, disable)", sub)
+}
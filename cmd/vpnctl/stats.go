@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/internal/vpn/stats"
+)
+
+// statsResult is the --json form of "vpnctl stats".
+type statsResult struct {
+	Total    totalStats     `json:"total"`
+	Sessions []sessionStats `json:"sessions"`
+	Daily    []dailyStats   `json:"daily"`
+}
+
+type totalStats struct {
+	BytesIn         uint64     `json:"bytes_in"`
+	BytesOut        uint64     `json:"bytes_out"`
+	Sessions        int        `json:"sessions"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	FirstSession    *time.Time `json:"first_session,omitempty"`
+	LastSession     *time.Time `json:"last_session,omitempty"`
+}
+
+type sessionStats struct {
+	SessionID       string    `json:"session_id"`
+	ProfileID       string    `json:"profile_id"`
+	ProfileName     string    `json:"profile_name,omitempty"`
+	Provider        string    `json:"provider"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	BytesIn         uint64    `json:"bytes_in"`
+	BytesOut        uint64    `json:"bytes_out"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+type dailyStats struct {
+	Date            string `json:"date"`
+	BytesIn         uint64 `json:"bytes_in"`
+	BytesOut        uint64 `json:"bytes_out"`
+	Sessions        int    `json:"sessions"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+func cmdStats(c *cli, args []string) error {
+	const usage = "stats [--sessions N] [--days N] [--profile PROFILE]"
+	fs := c.newFlagSet("stats", usage)
+	sessions := fs.Int("sessions", 10, "Number of recent sessions to show")
+	days := fs.Int("days", 7, "Number of days of daily totals to show")
+	profileRef := fs.String("profile", "", "Only count this profile")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, usage); err != nil {
+		return err
+	}
+	if *sessions < 0 || *days < 0 {
+		return usageErrorf("--sessions and --days must not be negative")
+	}
+
+	var profileID string
+	if *profileRef != "" {
+		pm, err := profile.NewProfileManager()
+		if err != nil {
+			return err
+		}
+		p, err := resolveProfile(pm, *profileRef)
+		if err != nil {
+			return err
+		}
+		profileID = p.ID
+	}
+
+	sm, err := stats.NewStatsManager("")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sm.Close() }()
+
+	var total *stats.TotalStats
+	if profileID != "" {
+		total, err = sm.GetTotalStatsForProfile(profileID)
+	} else {
+		total, err = sm.GetTotalStats()
+	}
+	if err != nil {
+		return err
+	}
+
+	res := statsResult{
+		Total: totalStats{
+			BytesIn:         total.TotalBytesIn,
+			BytesOut:        total.TotalBytesOut,
+			Sessions:        total.TotalSessions,
+			DurationSeconds: int64(total.TotalDuration.Seconds()),
+		},
+		Sessions: []sessionStats{},
+		Daily:    []dailyStats{},
+	}
+	if !total.FirstSessionAt.IsZero() {
+		res.Total.FirstSession, res.Total.LastSession = &total.FirstSessionAt, &total.LastSessionAt
+	}
+
+	if *sessions > 0 {
+		// The profile filter is applied here, so over-fetch to still fill
+		// the requested number of rows.
+		limit := *sessions
+		if profileID != "" {
+			limit *= 10
+		}
+		recent, err := sm.GetRecentSessions(limit)
+		if err != nil {
+			return err
+		}
+		names := profileNames()
+		for _, s := range recent {
+			if profileID != "" && s.ProfileID != profileID {
+				continue
+			}
+			if len(res.Sessions) == *sessions {
+				break
+			}
+			res.Sessions = append(res.Sessions, sessionStats{
+				SessionID:       s.SessionID,
+				ProfileID:       s.ProfileID,
+				ProfileName:     names[s.ProfileID],
+				Provider:        string(s.ProviderType),
+				Start:           s.StartTime,
+				End:             s.EndTime,
+				BytesIn:         s.TotalBytesIn,
+				BytesOut:        s.TotalBytesOut,
+				DurationSeconds: int64(s.Duration.Seconds()),
+			})
+		}
+	}
+
+	// Daily totals cover every profile; they are omitted with --profile
+	// rather than shown misleadingly.
+	if *days > 0 && profileID == "" {
+		daily, err := sm.GetDailySummaries(*days)
+		if err != nil {
+			return err
+		}
+		for _, d := range daily {
+			res.Daily = append(res.Daily, dailyStats{
+				Date:            d.Date.Format(time.DateOnly),
+				BytesIn:         d.TotalBytesIn,
+				BytesOut:        d.TotalBytesOut,
+				Sessions:        d.SessionCount,
+				DurationSeconds: int64(d.TotalDuration.Seconds()),
+			})
+		}
+	}
+
+	return c.emit(res, func(w io.Writer) { printStats(w, &res) })
+}
+
+func printStats(w io.Writer, res *statsResult) {
+	t := res.Total
+	_, _ = fmt.Fprintf(w, "Total: ↓ %s  ↑ %s  %d sessions  %s connected\n",
+		formatBytes(t.BytesIn), formatBytes(t.BytesOut), t.Sessions, formatSeconds(t.DurationSeconds))
+
+	if len(res.Sessions) > 0 {
+		_, _ = fmt.Fprintln(w, "\nRecent sessions:")
+		tw := table(w)
+		_, _ = fmt.Fprintln(tw, "STARTED\tPROFILE\tDURATION\tDOWN\tUP")
+		for _, s := range res.Sessions {
+			name := s.ProfileName
+			if name == "" {
+				name = s.Provider
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.Start.Local().Format("2006-01-02 15:04"),
+				name, formatSeconds(s.DurationSeconds), formatBytes(s.BytesIn), formatBytes(s.BytesOut))
+		}
+		_ = tw.Flush()
+	}
+
+	if len(res.Daily) > 0 {
+		_, _ = fmt.Fprintln(w, "\nDaily:")
+		tw := table(w)
+		_, _ = fmt.Fprintln(tw, "DATE\tSESSIONS\tDURATION\tDOWN\tUP")
+		for _, d := range res.Daily {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", d.Date, d.Sessions,
+				formatSeconds(d.DurationSeconds), formatBytes(d.BytesIn), formatBytes(d.BytesOut))
+		}
+		_ = tw.Flush()
+	}
+}
+
+// formatBytes renders a byte count with a binary unit (1.5 MiB).
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatSeconds(s int64) string {
+	return (time.Duration(s) * time.Second).String()
+}
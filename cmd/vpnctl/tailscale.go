@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yllada/vpn-manager/internal/vpn/tailscale"
+)
+
+func cmdTailscale(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "exit-node" {
+		return usageErrorf("usage: vpnctl tailscale exit-node [list | set <node> | clear | suggest]")
+	}
+	sub, rest := "list", args[1:]
+	if len(rest) > 0 {
+		sub, rest = rest[0], rest[1:]
+	}
+
+	switch sub {
+	case "list":
+		return exitNodeList(c, rest)
+	case "set":
+		return exitNodeSet(c, rest)
+	case "clear":
+		return exitNodeClear(c, rest)
+	case "suggest":
+		return exitNodeSuggest(c, rest)
+	}
+	return usageErrorf("unknown tailscale exit-node command %q (list, set, clear, suggest)", sub)
+}
+
+// tailscaleClient wraps tailscale.NewClient so a missing binary is reported
+// as "not found" rather than a generic failure.
+func tailscaleClient() (*tailscale.Client, error) {
+	client, err := tailscale.NewClient()
+	if err != nil {
+		return nil, &cliError{code: exitNotFound, err: fmt.Errorf("tailscale: %w", err)}
+	}
+	return client, nil
+}
+
+func exitNodeList(c *cli, args []string) error {
+	fs := c.newFlagSet("tailscale exit-node list", "tailscale exit-node list")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "tailscale exit-node list"); err != nil {
+		return err
+	}
+	client, err := tailscaleClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := client.ExitNodeList(c.ctx)
+	if err != nil {
+		return err
+	}
+	if nodes == nil {
+		nodes = []tailscale.ExitNodeListEntry{}
+	}
+
+	return c.emit(nodes, func(w io.Writer) {
+		if len(nodes) == 0 {
+			_, _ = fmt.Fprintln(w, "No exit nodes available.")
+			return
+		}
+		tw := table(w)
+		_, _ = fmt.Fprintln(tw, "\tNAME\tLOCATION\tSTATUS\tID")
+		for _, n := range nodes {
+			mark := ""
+			if n.Selected {
+				mark = "*"
+			}
+			status := "offline"
+			if n.Online {
+				status = "online"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", mark, n.Name, orDash(nodeLocation(n.City, n.Country)), status, n.ID)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func nodeLocation(city, country string) string {
+	switch {
+	case city != "" && country != "":
+		return city + ", " + country
+	case country != "":
+		return country
+	}
+	return city
+}
+
+// exitNodeResult is the --json form of set/clear.
+type exitNodeResult struct {
+	ExitNode string `json:"exit_node"`
+	AllowLAN bool   `json:"allow_lan"`
+}
+
+func exitNodeSet(c *cli, args []string) error {
+	const usage = "tailscale exit-node set <node> [--allow-lan]"
+	fs := c.newFlagSet("tailscale exit-node set", usage)
+	allowLAN := fs.Bool("allow-lan", false, "Keep the local network reachable while using the exit node")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 1, 1, usage); err != nil {
+		return err
+	}
+	client, err := tailscaleClient()
+	if err != nil {
+		return err
+	}
+
+	// Accept the node's ID, its name, or its short (first label) name.
+	nodes, err := client.ExitNodeList(c.ctx)
+	if err != nil {
+		return err
+	}
+	node, err := resolveExitNode(nodes, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := client.SetExitNodeWithOptions(c.ctx, node.ID, *allowLAN); err != nil {
+		return err
+	}
+	res := exitNodeResult{ExitNode: node.Name, AllowLAN: *allowLAN}
+	return c.emit(res, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Exit node set to %s\n", node.Name)
+	})
+}
+
+// resolveExitNode finds a node by ID, name, or unique short name.
+func resolveExitNode(nodes []tailscale.ExitNodeListEntry, ref string) (*tailscale.ExitNodeListEntry, error) {
+	var matches []*tailscale.ExitNodeListEntry
+	for i := range nodes {
+		n := &nodes[i]
+		if n.ID == ref || strings.EqualFold(n.Name, ref) {
+			return n, nil
+		}
+		if short, _, _ := strings.Cut(n.Name, "."); strings.EqualFold(short, ref) {
+			matches = append(matches, n)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, notFoundErrorf("no exit node %q (see 'vpnctl tailscale exit-node list')", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, usageErrorf("%q matches %d exit nodes; use the full name or ID", ref, len(matches))
+}
+
+func exitNodeClear(c *cli, args []string) error {
+	fs := c.newFlagSet("tailscale exit-node clear", "tailscale exit-node clear")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "tailscale exit-node clear"); err != nil {
+		return err
+	}
+	client, err := tailscaleClient()
+	if err != nil {
+		return err
+	}
+	if err := client.SetExitNode(c.ctx, ""); err != nil {
+		return err
+	}
+	return c.emit(exitNodeResult{}, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "Exit node cleared")
+	})
+}
+
+func exitNodeSuggest(c *cli, args []string) error {
+	fs := c.newFlagSet("tailscale exit-node suggest", "tailscale exit-node suggest")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "tailscale exit-node suggest"); err != nil {
+		return err
+	}
+	client, err := tailscaleClient()
+	if err != nil {
+		return err
+	}
+	node, err := client.ExitNodeSuggest(c.ctx)
+	if err != nil {
+		return err
+	}
+	return c.emit(node, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Suggested exit node: %s", node.Name)
+		if loc := nodeLocation(node.City, node.Country); loc != "" {
+			_, _ = fmt.Fprintf(w, " (%s)", loc)
+		}
+		_, _ = fmt.Fprintf(w, "\nUse it with: vpnctl tailscale exit-node set %s\n", node.ID)
+	})
+}
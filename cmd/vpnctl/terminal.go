@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// readSecret reads one line for a password or OTP. From a terminal it prompts
+// on stderr with echo turned off; from a pipe (--password-stdin) it reads the
+// first line silently.
+func (c *cli) readSecret(prompt string) (string, error) {
+	if f, ok := c.stdin.(*os.File); ok && isTerminal(f) {
+		_, _ = fmt.Fprint(c.stderr, prompt)
+		restore, err := disableEcho(f)
+		if err == nil {
+			defer restore()
+		}
+		line, err := readLine(f)
+		_, _ = fmt.Fprintln(c.stderr)
+		return line, err
+	}
+	return readLine(c.stdin)
+}
+
+// readLine reads up to the first newline. It reads byte by byte so nothing
+// past the line is consumed from a shared stdin.
+func readLine(r io.Reader) (string, error) {
+	var sb strings.Builder
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			sb.WriteByte(buf[0])
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && sb.Len() > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimRight(sb.String(), "\r"), nil
+}
+
+func isTerminal(f *os.File) bool {
+	var t syscall.Termios
+	return ioctl(f.Fd(), syscall.TCGETS, &t) == nil
+}
+
+// disableEcho turns off terminal echo and returns a function restoring the
+// previous settings.
+func disableEcho(f *os.File) (func(), error) {
+	var orig syscall.Termios
+	if err := ioctl(f.Fd(), syscall.TCGETS, &orig); err != nil {
+		return nil, err
+	}
+	noEcho := orig
+	noEcho.Lflag &^= syscall.ECHO
+	noEcho.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := ioctl(f.Fd(), syscall.TCSETS, &noEcho); err != nil {
+		return nil, err
+	}
+	return func() { _ = ioctl(f.Fd(), syscall.TCSETS, &orig) }, nil
+}
+
+func ioctl(fd uintptr, req uint, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/internal/vpn/trust"
+)
+
+// Trust rules live in the user's configuration, so these commands edit the
+// file directly and do not need the daemon. A running GUI picks the change up
+// the next time it reloads its trust configuration.
+
+func cmdTrust(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "rules" {
+		return usageErrorf("usage: vpnctl trust rules [list | add | remove <id>]")
+	}
+	sub, rest := "list", args[1:]
+	if len(rest) > 0 {
+		sub, rest = rest[0], rest[1:]
+	}
+
+	switch sub {
+	case "list":
+		return trustList(c, rest)
+	case "add":
+		return trustAdd(c, rest)
+	case "remove", "delete":
+		return trustRemove(c, rest)
+	}
+	return usageErrorf("unknown trust rules command %q (list, add, remove)", sub)
+}
+
+func trustList(c *cli, args []string) error {
+	fs := c.newFlagSet("trust rules list", "trust rules list")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "trust rules list"); err != nil {
+		return err
+	}
+	cfg, err := trust.LoadTrustConfig()
+	if err != nil {
+		return err
+	}
+
+	rules := cfg.GetRules()
+	if rules == nil {
+		rules = []*trust.TrustRule{}
+	}
+	return c.emit(rules, func(w io.Writer) {
+		if len(rules) == 0 {
+			_, _ = fmt.Fprintln(w, "No trust rules.")
+			return
+		}
+		tw := table(w)
+		_, _ = fmt.Fprintln(tw, "SSID\tLEVEL\tBSSID\tPROFILE\tID")
+		for _, r := range rules {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.SSID, r.TrustLevel, orDash(r.BSSID), orDash(r.VPNProfile), r.ID)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func trustAdd(c *cli, args []string) error {
+	const usage = "trust rules add --ssid SSID --level trusted|untrusted [--bssid MAC] [--profile PROFILE] [--description TEXT]"
+	fs := c.newFlagSet("trust rules add", usage)
+	ssid := fs.String("ssid", "", "Network name to match")
+	level := fs.String("level", "", "Trust level: trusted or untrusted")
+	bssid := fs.String("bssid", "", "Only match this access point")
+	profileRef := fs.String("profile", "", "VPN profile to use on this network")
+	description := fs.String("description", "", "Note shown with the rule")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, usage); err != nil {
+		return err
+	}
+
+	rule := &trust.TrustRule{
+		SSID:        *ssid,
+		BSSID:       *bssid,
+		TrustLevel:  trust.TrustLevel(*level),
+		Description: *description,
+		Created:     time.Now(),
+	}
+	if *profileRef != "" {
+		pm, err := profile.NewProfileManager()
+		if err != nil {
+			return err
+		}
+		p, err := resolveProfile(pm, *profileRef)
+		if err != nil {
+			return err
+		}
+		rule.VPNProfile = p.ID
+	}
+
+	cfg, err := trust.LoadTrustConfig()
+	if err != nil {
+		return err
+	}
+	if err := cfg.AddRule(rule); err != nil {
+		return err
+	}
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	return c.emit(rule, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Added rule %s: %s is %s\n", rule.ID, rule.SSID, rule.TrustLevel)
+	})
+}
+
+func trustRemove(c *cli, args []string) error {
+	fs := c.newFlagSet("trust rules remove", "trust rules remove <id>")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 1, 1, "trust rules remove <id>"); err != nil {
+		return err
+	}
+	cfg, err := trust.LoadTrustConfig()
+	if err != nil {
+		return err
+	}
+	rule, err := cfg.GetRule(args[0])
+	if err != nil {
+		return fmt.Errorf("rule %q: %w", args[0], err)
+	}
+	if err := cfg.RemoveRule(rule.ID); err != nil {
+		return err
+	}
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	return c.emit(rule, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Removed rule %s (%s)\n", rule.ID, rule.SSID)
+	})
+}
@@ -0,0 +1,720 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/config"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/keyring"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+)
+
+// connectPollInterval is how often connect re-checks the connection status
+// while waiting for it to come up.
+const connectPollInterval = 250 * time.Millisecond
+
+// =============================================================================
+// CONNECT
+// =============================================================================
+
+// connectResult is the --json form of "vpnctl connect".
+type connectResult struct {
+	ProfileID   string `json:"profile_id"`
+	ProfileName string `json:"profile_name"`
+	Status      string `json:"status"`
+	IPAddress   string `json:"ip_address,omitempty"`
+}
+
+func cmdConnect(c *cli, args []string) error {
+	const usage = "connect <profile> [--username USER] [--password-stdin] [--otp CODE] [--timeout 60s] [--no-wait]"
+	fs := c.newFlagSet("connect", usage)
+	username := fs.String("username", "", "Username (default: the one saved in the profile)")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password from the first line of stdin")
+	otp := fs.String("otp", "", "One-time code for profiles that require 2FA, or the response to the server's challenge")
+	timeout := fs.Duration("timeout", 60*time.Second, "How long to wait for the connection")
+	noWait := fs.Bool("no-wait", false, "Return as soon as the connection is started")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 1, 1, usage); err != nil {
+		return err
+	}
+	if err := requireDaemon(); err != nil {
+		return err
+	}
+
+	m, err := c.vpnManager()
+	if err != nil {
+		return err
+	}
+	prof, err := resolveProfile(m.ProfileManager(), args[0])
+	if err != nil {
+		return err
+	}
+
+	user, password, err := c.credentials(prof, *username, *passwordStdin, *otp)
+	if err != nil {
+		return err
+	}
+
+	// Arm the protections the user configured, as the GUI does at startup.
+	// The session agent arms its own from the same config.
+	if cfg, err := config.Load(); err == nil && !m.AgentMode() {
+		m.ApplyKillSwitchConfig(cfg.Security.KillSwitchMode, cfg.Security.KillSwitchLAN)
+		m.ApplyDNSConfig(cfg.Security.DNSMode, cfg.Security.CustomDNS, cfg.Security.BlockDoH, cfg.Security.BlockDoT)
+		m.ApplyIPv6Config(cfg.Security.IPv6Mode)
+	}
+
+	if err := m.Connect(prof.ID, user, password); err != nil {
+		if errors.Is(err, vpn.ErrAlreadyConnected) {
+			return &cliError{code: exitFailure, err: fmt.Errorf("%s is already connected", prof.Name)}
+		}
+		return err
+	}
+	conn, ok := m.GetConnection(prof.ID)
+	if !ok {
+		return fmt.Errorf("connection to %s was not registered", prof.Name)
+	}
+
+	res := connectResult{ProfileID: prof.ID, ProfileName: prof.Name}
+	if !*noWait {
+		answer := c.challengeAnswerer(prof, *otp, *passwordStdin)
+		if err := c.waitConnected(m, conn, *timeout, answer); err != nil {
+			return err
+		}
+	}
+	status := conn.GetStatus()
+	res.Status = strings.ToLower(status.String())
+	res.IPAddress = conn.GetIPAddress()
+
+	return c.emit(res, func(w io.Writer) {
+		if status == vpn.StatusConnected {
+			_, _ = fmt.Fprintf(w, "Connected to %s", prof.Name)
+			if res.IPAddress != "" {
+				_, _ = fmt.Fprintf(w, " (%s)", res.IPAddress)
+			}
+			_, _ = fmt.Fprintln(w)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "Connecting to %s\n", prof.Name)
+	})
+}
+
+// credentials works out the username and password for prof: flags and stdin
+// first, then the keyring, then an interactive prompt. The OTP, when needed, is
+// appended to the password as the GUI does.
+func (c *cli) credentials(prof *profile.Profile, username string, passwordStdin bool, otp string) (string, string, error) {
+	if username == "" {
+		username = prof.Username
+	}
+
+	var password string
+	switch {
+	case passwordStdin:
+		p, err := readLine(c.stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("read password from stdin: %w", err)
+		}
+		password = p
+	case prof.SavePassword:
+		if p, err := keyring.Get(prof.ID); err == nil {
+			password = p
+		}
+	}
+
+	interactive := isInteractive(c.stdin)
+	if password == "" && interactive {
+		if username == "" {
+			u, err := c.readLine("Username: ")
+			if err != nil {
+				return "", "", err
+			}
+			username = u
+		}
+		p, err := c.readSecret(fmt.Sprintf("Password for %s: ", prof.Name))
+		if err != nil {
+			return "", "", err
+		}
+		password = p
+	}
+
+	// A static challenge is answered when the server asks, not appended.
+	if prof.StaticChallenge != "" {
+		return username, password, nil
+	}
+	if prof.RequiresOTP && otp == "" {
+		if !interactive || passwordStdin {
+			return "", "", usageErrorf("%s requires a one-time code: pass --otp", prof.Name)
+		}
+		code, err := c.readSecret("One-time code: ")
+		if err != nil {
+			return "", "", err
+		}
+		otp = code
+	}
+	return username, password + otp, nil
+}
+
+// readLine prompts on stderr and reads a line with echo on.
+func (c *cli) readLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(c.stderr, prompt)
+	return readLine(c.stdin)
+}
+
+func isInteractive(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// challengeAnswerer returns how to answer the challenges prof's server asks
+// while connecting: the --otp code the first time, then an interactive prompt
+// when stdin allows one.
+func (c *cli) challengeAnswerer(prof *profile.Profile, otp string, passwordStdin bool) func(*daemon.OpenVPNChallenge) (string, error) {
+	// The code was appended to the password unless the profile has a
+	// static challenge, so only that one may be reused here.
+	unused := otp != "" && (prof.StaticChallenge != "" || !prof.RequiresOTP)
+	return func(ch *daemon.OpenVPNChallenge) (string, error) {
+		if unused {
+			unused = false
+			return otp, nil
+		}
+		if !isInteractive(c.stdin) || passwordStdin {
+			return "", usageErrorf("%s: the server asks %q: pass the response with --otp", prof.Name, ch.Text)
+		}
+		prompt := strings.TrimSpace(ch.Text) + " "
+		if ch.Echo {
+			return c.readLine(prompt)
+		}
+		return c.readSecret(prompt)
+	}
+}
+
+// pendingChallenge returns the challenge the server of conn's profile waits
+// on. In agent mode the connection is the agent's, so the daemon is asked.
+func pendingChallenge(m *vpn.Manager, conn *vpn.Connection) *daemon.OpenVPNChallenge {
+	if !m.AgentMode() {
+		return conn.GetChallenge()
+	}
+	status, err := (&daemon.OpenVPNClient{}).Status(conn.Profile.ID)
+	if err != nil {
+		return nil
+	}
+	return status.Challenge
+}
+
+// waitConnected blocks until conn is up with its protections applied, fails,
+// or the timeout or an interrupt ends the wait. Challenges the server asks on
+// the way are answered with answer. A connection that does not come up in
+// time is torn down rather than left half-connected behind the user's back.
+func (c *cli) waitConnected(m *vpn.Manager, conn *vpn.Connection, timeout time.Duration, answer func(*daemon.OpenVPNChallenge) (string, error)) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(connectPollInterval)
+	defer poll.Stop()
+
+	name := conn.Profile.Name
+	for {
+		select {
+		case <-conn.Ready():
+			return nil
+
+		case <-poll.C:
+			if ch := pendingChallenge(m, conn); ch != nil {
+				response, err := answer(ch)
+				if err == nil {
+					err = m.RespondToChallenge(conn.Profile.ID, response)
+				}
+				if err != nil {
+					_ = m.Disconnect(conn.Profile.ID)
+					return err
+				}
+				continue
+			}
+			switch conn.GetStatus() {
+			case vpn.StatusError:
+				msg := conn.GetLastError()
+				if strings.Contains(msg, "Authentication failed") {
+					return &cliError{code: exitAuth, err: fmt.Errorf("%s: authentication failed", name)}
+				}
+				return fmt.Errorf("%s: connection failed: %s", name, orDash(msg))
+			case vpn.StatusDisconnected:
+				return fmt.Errorf("%s: connection closed before it came up", name)
+			}
+
+		case <-deadline.C:
+			_ = m.Disconnect(conn.Profile.ID)
+			return &cliError{code: exitTimeout, err: fmt.Errorf("%s: not connected after %s; gave up", name, timeout)}
+
+		case <-c.ctx.Done():
+			_ = m.Disconnect(conn.Profile.ID)
+			return &cliError{code: exitFailure, err: fmt.Errorf("%s: interrupted; disconnected", name)}
+		}
+	}
+}
+
+// =============================================================================
+// DISCONNECT
+// =============================================================================
+
+// disconnectResult is the --json form of "vpnctl disconnect".
+type disconnectResult struct {
+	Disconnected []string `json:"disconnected"`
+}
+
+func cmdDisconnect(c *cli, args []string) error {
+	const usage = "disconnect [<profile> | <wireguard-interface> | --all]"
+	fs := c.newFlagSet("disconnect", usage)
+	all := fs.Bool("all", false, "Disconnect every VPN")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 1, usage); err != nil {
+		return err
+	}
+	if *all && len(args) > 0 {
+		return usageErrorf("--all takes no profile")
+	}
+	if err := requireDaemon(); err != nil {
+		return err
+	}
+
+	ovpn := &daemon.OpenVPNClient{}
+	wg := &daemon.WireGuardClient{}
+	// Connections started through the session agent are stopped through it,
+	// so it does not read the drop as a failure and reconnect.
+	disconnectOpenVPN := ovpn.DisconnectWithContext
+	if daemon.IsAgentAvailable() {
+		disconnectOpenVPN = (&daemon.AgentClient{}).DisconnectWithContext
+	}
+	active, err := ovpn.List()
+	if err != nil {
+		return err
+	}
+	tunnels, err := wg.List()
+	if err != nil {
+		return err
+	}
+
+	// Work out what to take down. Without arguments a single active VPN is
+	// unambiguous; several need a name (or --all).
+	var ovpnIDs, wgIfaces []string
+	switch {
+	case len(args) == 1:
+		target := args[0]
+		for _, t := range tunnels {
+			if t.InterfaceName == target {
+				wgIfaces = append(wgIfaces, target)
+			}
+		}
+		if len(wgIfaces) == 0 {
+			pm, err := profile.NewProfileManager()
+			if err != nil {
+				return err
+			}
+			prof, err := resolveProfile(pm, target)
+			if err != nil {
+				return err
+			}
+			if !hasOpenVPN(active, prof.ID) {
+				return notFoundErrorf("%s is not connected", prof.Name)
+			}
+			ovpnIDs = append(ovpnIDs, prof.ID)
+		}
+	default:
+		for _, a := range active {
+			ovpnIDs = append(ovpnIDs, a.ProfileID)
+		}
+		for _, t := range tunnels {
+			wgIfaces = append(wgIfaces, t.InterfaceName)
+		}
+		if n := len(ovpnIDs) + len(wgIfaces); n == 0 {
+			return notFoundErrorf("no VPN is connected")
+		} else if n > 1 && !*all {
+			return usageErrorf("%d VPNs are connected: name one or pass --all", n)
+		}
+	}
+
+	res := disconnectResult{Disconnected: []string{}}
+	var errs []error
+	for _, id := range ovpnIDs {
+		if err := disconnectOpenVPN(c.ctx, id); err != nil && !strings.Contains(err.Error(), "no connection found") {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		res.Disconnected = append(res.Disconnected, id)
+	}
+	for _, iface := range wgIfaces {
+		if err := wg.DisconnectWithContext(c.ctx, iface); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", iface, err))
+			continue
+		}
+		res.Disconnected = append(res.Disconnected, iface)
+	}
+
+	// Like vpn.Manager.Disconnect: once no tunnel remains, restore the system
+	// to its pre-VPN state.
+	if len(errs) == 0 && len(ovpnIDs) == len(active) && len(wgIfaces) == len(tunnels) {
+		errs = append(errs, teardownProtections()...)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	return c.emit(res, func(w io.Writer) {
+		for _, id := range res.Disconnected {
+			_, _ = fmt.Fprintf(w, "Disconnected %s\n", id)
+		}
+	})
+}
+
+// teardownProtections turns off the kill switch, DNS and IPv6 protection and
+// the split tunnel after the last VPN went down.
+func teardownProtections() []error {
+	var errs []error
+	if err := (&daemon.KillSwitchClient{}).Disable(); err != nil {
+		errs = append(errs, fmt.Errorf("kill switch: %w", err))
+	}
+	if err := (&daemon.DNSProtectionClient{}).Disable(); err != nil {
+		errs = append(errs, fmt.Errorf("DNS protection: %w", err))
+	}
+	if err := (&daemon.IPv6ProtectionClient{}).Disable(); err != nil {
+		errs = append(errs, fmt.Errorf("IPv6 protection: %w", err))
+	}
+	if st, err := (&daemon.SplitTunnelClient{}).Status(); err == nil && st["enabled"] == true {
+		if err := (&daemon.SplitTunnelClient{}).Cleanup(); err != nil {
+			errs = append(errs, fmt.Errorf("split tunnel: %w", err))
+		}
+	}
+	return errs
+}
+
+func hasOpenVPN(active []daemon.OpenVPNStatusResult, profileID string) bool {
+	for _, a := range active {
+		if a.ProfileID == profileID {
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// STATUS
+// =============================================================================
+
+// statusResult is the --json form of "vpnctl status".
+type statusResult struct {
+	Daemon         bool                           `json:"daemon"`
+	Agent          bool                           `json:"agent"`
+	OpenVPN        []openVPNStatus                `json:"openvpn"`
+	WireGuard      []daemon.WireGuardStatusResult `json:"wireguard"`
+	KillSwitch     map[string]any                 `json:"killswitch,omitempty"`
+	DNSProtection  map[string]any                 `json:"dns,omitempty"`
+	IPv6Protection map[string]any                 `json:"ipv6,omitempty"`
+}
+
+// openVPNStatus is a daemon connection with the profile name resolved.
+type openVPNStatus struct {
+	daemon.OpenVPNStatusResult
+	ProfileName string `json:"profile_name,omitempty"`
+}
+
+func cmdStatus(c *cli, args []string) error {
+	fs := c.newFlagSet("status", "status")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "status"); err != nil {
+		return err
+	}
+
+	res := statusResult{Daemon: daemon.IsDaemonAvailable(), Agent: daemon.IsAgentAvailable(), OpenVPN: []openVPNStatus{}, WireGuard: []daemon.WireGuardStatusResult{}}
+	if !res.Daemon {
+		// Still a valid answer for scripts, but flagged through the exit code.
+		_ = c.emit(res, func(w io.Writer) {
+			_, _ = fmt.Fprintln(w, "Daemon: not running (check: systemctl status vpn-managerd)")
+		})
+		return &cliError{code: exitNoDaemon, err: errors.New("vpn-managerd is not running"), reported: true}
+	}
+
+	active, err := (&daemon.OpenVPNClient{}).List()
+	if err != nil {
+		return err
+	}
+	names := profileNames()
+	for _, a := range active {
+		res.OpenVPN = append(res.OpenVPN, openVPNStatus{OpenVPNStatusResult: a, ProfileName: names[a.ProfileID]})
+	}
+	if tunnels, err := (&daemon.WireGuardClient{}).List(); err == nil {
+		res.WireGuard = tunnels
+	}
+	res.KillSwitch, _ = (&daemon.KillSwitchClient{}).Status()
+	res.DNSProtection, _ = (&daemon.DNSProtectionClient{}).Status()
+	res.IPv6Protection, _ = (&daemon.IPv6ProtectionClient{}).Status()
+
+	return c.emit(res, func(w io.Writer) {
+		tw := table(w)
+		_, _ = fmt.Fprintf(tw, "Daemon:\trunning\n")
+		_, _ = fmt.Fprintf(tw, "Agent:\t%s\n", runningOrNot(res.Agent))
+		if len(res.OpenVPN)+len(res.WireGuard) == 0 {
+			_, _ = fmt.Fprintf(tw, "VPN:\tdisconnected\n")
+		}
+		for _, o := range res.OpenVPN {
+			_, _ = fmt.Fprintf(tw, "OpenVPN:\t%s\t%s\t%s\n", orDash(o.ProfileName), o.Status, orDash(o.IPAddress))
+		}
+		for _, t := range res.WireGuard {
+			_, _ = fmt.Fprintf(tw, "WireGuard:\t%s\t%s\t%s\n", t.InterfaceName, t.Status, orDash(t.IPAddress))
+		}
+		_, _ = fmt.Fprintf(tw, "Kill switch:\t%s\n", onOff(res.KillSwitch["enabled"] == true))
+		_, _ = fmt.Fprintf(tw, "DNS protection:\t%s\n", onOff(res.DNSProtection["enabled"] == true))
+		_, _ = fmt.Fprintf(tw, "IPv6 protection:\t%s\n", onOff(res.IPv6Protection["enabled"] == true))
+		_ = tw.Flush()
+	})
+}
+
+// profileNames maps profile IDs to names for display; best effort.
+func profileNames() map[string]string {
+	names := make(map[string]string)
+	pm, err := profile.NewProfileManager()
+	if err != nil {
+		return names
+	}
+	for _, p := range pm.List() {
+		names[p.ID] = p.Name
+	}
+	return names
+}
+
+// =============================================================================
+// PROFILES
+// =============================================================================
+
+func cmdProfiles(c *cli, args []string) error {
+	sub, rest := "list", args
+	if len(args) > 0 {
+		sub, rest = args[0], args[1:]
+	}
+	switch sub {
+	case "list":
+		return profilesList(c, rest)
+	case "import":
+		return profilesImport(c, rest)
+	case "delete", "remove":
+		return profilesDelete(c, rest)
+	}
+	return usageErrorf("unknown profiles command %q (list, import, delete)", sub)
+}
+
+// profileInfo is the --json form of a profile.
+type profileInfo struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Username     string     `json:"username,omitempty"`
+	ConfigPath   string     `json:"config_path"`
+	RequiresOTP  bool       `json:"requires_otp"`
+	SavePassword bool       `json:"save_password"`
+	AutoConnect  bool       `json:"auto_connect"`
+	SplitTunnel  bool       `json:"split_tunnel"`
+	LastUsed     *time.Time `json:"last_used,omitempty"`
+
+	Certificates []profile.Certificate `json:"certificates,omitempty"`
+}
+
+func newProfileInfo(p *profile.Profile) profileInfo {
+	info := profileInfo{
+		ID:           p.ID,
+		Name:         p.Name,
+		Username:     p.Username,
+		ConfigPath:   p.ConfigPath,
+		RequiresOTP:  p.RequiresOTP || p.StaticChallenge != "",
+		SavePassword: p.SavePassword,
+		AutoConnect:  p.AutoConnect,
+		SplitTunnel:  p.SplitTunnelEnabled,
+		Certificates: p.Certificates,
+	}
+	if !p.LastUsed.IsZero() {
+		lastUsed := p.LastUsed
+		info.LastUsed = &lastUsed
+	}
+	return info
+}
+
+func profilesList(c *cli, args []string) error {
+	fs := c.newFlagSet("profiles list", "profiles list")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 0, 0, "profiles list"); err != nil {
+		return err
+	}
+	pm, err := profile.NewProfileManager()
+	if err != nil {
+		return err
+	}
+
+	infos := []profileInfo{}
+	for _, p := range pm.List() {
+		infos = append(infos, newProfileInfo(p))
+	}
+	return c.emit(infos, func(w io.Writer) {
+		if len(infos) == 0 {
+			_, _ = fmt.Fprintln(w, "No profiles. Add one with: vpnctl profiles import <file.ovpn>")
+			return
+		}
+		tw := table(w)
+		_, _ = fmt.Fprintln(tw, "NAME\tUSERNAME\tOTP\tID")
+		for _, p := range infos {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, orDash(p.Username), onOff(p.RequiresOTP), p.ID)
+		}
+		_ = tw.Flush()
+	})
+}
+
+func profilesImport(c *cli, args []string) error {
+	const usage = "profiles import <file.ovpn> [--name NAME] [--username USER] [--save-password --password-stdin]"
+	fs := c.newFlagSet("profiles import", usage)
+	name := fs.String("name", "", "Profile name (default: the file name)")
+	username := fs.String("username", "", "Username to save with the profile")
+	savePassword := fs.Bool("save-password", false, "Save the password in the keyring")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password to save from stdin")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 1, 1, usage); err != nil {
+		return err
+	}
+	if *passwordStdin && !*savePassword {
+		return usageErrorf("--password-stdin needs --save-password")
+	}
+
+	pm, err := profile.NewProfileManager()
+	if err != nil {
+		return err
+	}
+	path := args[0]
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if pm.NameExists(*name) {
+		return usageErrorf("a profile named %q already exists (use --name)", *name)
+	}
+
+	var password string
+	if *savePassword {
+		if *passwordStdin {
+			password, err = readLine(c.stdin)
+		} else {
+			password, err = c.readSecret(fmt.Sprintf("Password for %s: ", *name))
+		}
+		if err != nil {
+			return fmt.Errorf("read password: %w", err)
+		}
+	}
+
+	p := &profile.Profile{Name: *name, ConfigPath: path, Username: *username, SavePassword: *savePassword}
+	if err := pm.Add(p); err != nil {
+		return err
+	}
+	if *savePassword && password != "" {
+		if err := keyring.Store(p.ID, password); err != nil {
+			return fmt.Errorf("profile added, but saving the password failed: %w", err)
+		}
+	}
+
+	info := newProfileInfo(p)
+	return c.emit(info, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Imported %s (%s)\n", info.Name, info.ID)
+		if info.RequiresOTP {
+			_, _ = fmt.Fprintln(w, "This profile asks for a one-time code: connect with --otp.")
+		}
+		for _, cert := range info.Certificates {
+			if !cert.Expires() {
+				continue
+			}
+			switch left := time.Until(cert.NotAfter); {
+			case left <= 0:
+				_, _ = fmt.Fprintf(w, "Warning: the %s expired on %s.\n", cert.Name(), cert.NotAfter.Local().Format(time.DateOnly))
+			case left <= time.Duration(profile.ExpiryWarningDays[0])*24*time.Hour:
+				_, _ = fmt.Fprintf(w, "Warning: the %s expires on %s.\n", cert.Name(), cert.NotAfter.Local().Format(time.DateOnly))
+			}
+		}
+	})
+}
+
+func profilesDelete(c *cli, args []string) error {
+	fs := c.newFlagSet("profiles delete", "profiles delete <profile>")
+	args, err := c.parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := expectArgs(args, 1, 1, "profiles delete <profile>"); err != nil {
+		return err
+	}
+	pm, err := profile.NewProfileManager()
+	if err != nil {
+		return err
+	}
+	p, err := resolveProfile(pm, args[0])
+	if err != nil {
+		return err
+	}
+
+	// As in the GUI: the saved password goes with the profile.
+	_ = keyring.Delete(p.ID)
+	if err := pm.Remove(p.ID); err != nil {
+		return err
+	}
+	info := newProfileInfo(p)
+	return c.emit(info, func(w io.Writer) {
+		_, _ = fmt.Fprintf(w, "Deleted %s\n", info.Name)
+	})
+}
+
+// profileLister is the part of profile.ProfileManager resolveProfile needs.
+type profileLister interface {
+	List() []*profile.Profile
+}
+
+// resolveProfile finds a profile by ID, exact name, case-insensitive name or
+// unique ID prefix, in that order.
+func resolveProfile(pm profileLister, ref string) (*profile.Profile, error) {
+	profiles := pm.List()
+	for _, p := range profiles {
+		if p.ID == ref || p.Name == ref {
+			return p, nil
+		}
+	}
+
+	var matches []*profile.Profile
+	for _, p := range profiles {
+		if strings.EqualFold(p.Name, ref) {
+			matches = append(matches, p)
+		}
+	}
+	if len(matches) == 0 && len(ref) >= 4 {
+		for _, p := range profiles {
+			if strings.HasPrefix(p.ID, ref) {
+				matches = append(matches, p)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%q: %w", ref, profile.ErrProfileNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, usageErrorf("%q matches %d profiles; use the profile ID", ref, len(matches))
+}
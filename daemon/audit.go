@@ -0,0 +1,504 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// This file implements the audit trail: one JSON line per privileged call
+// (the methods isPrivilegedMethod selects), with who made it, what it asked
+// for and how it ended, so "who enabled the LAN gateway, and when" has an
+// answer. Calls rejected by authorization are recorded too.
+//
+// The log is append-only from the daemon's side and rotated by size: audit.jsonl
+// becomes audit.jsonl.1, .1 becomes .2 and so on, and the oldest beyond the
+// configured count is deleted. audit.query reads across the rotated files.
+// Secrets in params (passwords, auth keys, OTPs) are replaced before anything
+// is written; see redactParams.
+
+const (
+	// DefaultAuditLogPath is where the daemon writes its audit trail.
+	DefaultAuditLogPath = "/var/log/vpn-manager/audit.jsonl"
+
+	// DefaultAuditMaxSize is the size at which the audit log is rotated.
+	DefaultAuditMaxSize = 10 << 20
+
+	// DefaultAuditKeep is how many rotated files are kept besides the live one.
+	DefaultAuditKeep = 5
+
+	// MethodAuditQuery reads the audit trail.
+	MethodAuditQuery = "audit.query"
+
+	// defaultAuditQueryLimit and maxAuditQueryLimit bound audit.query results.
+	defaultAuditQueryLimit = 200
+	maxAuditQueryLimit     = 5000
+
+	// maxAuditParams is the largest params object recorded verbatim (after
+	// redaction). Bigger ones are replaced by a note of their size.
+	maxAuditParams = 4096
+
+	// redacted replaces secret values in recorded params.
+	redacted = "[REDACTED]"
+)
+
+// Audit results.
+const (
+	AuditResultOK          = "ok"
+	AuditResultError       = "error"
+	AuditResultDenied      = "denied"
+	AuditResultCancelled   = "cancelled"
+	AuditResultTimeout     = "timeout"
+	AuditResultRateLimited = "rate_limited"
+)
+
+var (
+	// errAuditDenied marks a call rejected before its handler ran.
+	errAuditDenied = errors.New("not authorized")
+
+	// errAuditDisabled is returned by audit.query when no log is configured.
+	errAuditDisabled = errors.New("audit trail is not enabled")
+)
+
+// secretParamKeys are the param names whose values are never recorded.
+// "response" covers answers to two-factor challenges. Names are matched
+// exactly, so flags such as use_auth_token are kept.
+var secretParamKeys = map[string]bool{
+	"password":      true,
+	"passphrase":    true,
+	"secret":        true,
+	"token":         true,
+	"auth_token":    true,
+	"auth_key":      true,
+	"private_key":   true,
+	"preshared_key": true,
+	"otp":           true,
+	"otp_code":      true,
+	"credentials":   true,
+	"response":      true,
+}
+
+// AuditRecord is one line of the audit trail.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Caller, from SO_PEERCRED (socket) or the bus (D-Bus). Exe is the
+	// caller's executable when /proc could resolve it.
+	UID uint32 `json:"uid"`
+	PID int32  `json:"pid"`
+	Exe string `json:"exe,omitempty"`
+	// Transport is "socket" or "dbus".
+	Transport string `json:"transport"`
+	Method    string `json:"method"`
+	// Params are the call's params with secrets redacted.
+	Params json.RawMessage `json:"params,omitempty"`
+	// Result is one of the AuditResult* values; Error says why for the
+	// others.
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// AuditQueryParams filters audit.query. Every filter is optional.
+type AuditQueryParams struct {
+	// Since and Until bound the record time (inclusive).
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// Method is a path.Match glob: "gateway.*".
+	Method string `json:"method,omitempty"`
+	// UID keeps only calls by this user.
+	UID *uint32 `json:"uid,omitempty"`
+	// Limit caps the result at the most recent records (default 200).
+	Limit int `json:"limit,omitempty"`
+}
+
+// AuditQueryResult is the result of audit.query.
+type AuditQueryResult struct {
+	// Records match the filters, newest first.
+	Records []AuditRecord `json:"records"`
+	// Truncated is set when older matches were cut off by the limit.
+	Truncated bool `json:"truncated"`
+}
+
+// AuditLog is the append-only, size-rotated audit trail. It is safe for
+// concurrent use.
+type AuditLog struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// rotateMu is held for reading while Query opens the files and for
+	// writing while rotate renames them, so a query sees a consistent set
+	// and only delays an Append that rotates.
+	rotateMu sync.RWMutex
+}
+
+// OpenAuditLog opens (creating if needed) the audit log at path. It is rotated
+// once it would grow past maxSize, keeping keep older files.
+func OpenAuditLog(path string, maxSize int64, keep int) (*AuditLog, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultAuditMaxSize
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create audit log directory: %w", err)
+	}
+
+	a := &AuditLog{path: path, maxSize: maxSize, keep: keep}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	a.file = f
+	a.size = info.Size()
+	return nil
+}
+
+// Append writes one record, rotating first if it would not fit.
+func (a *AuditLog) Append(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return errors.New("audit log closed")
+	}
+	if a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts the rotated files up by one, drops the oldest and starts a new
+// live file. Caller holds a.mu.
+func (a *AuditLog) rotate() error {
+	a.rotateMu.Lock()
+	defer a.rotateMu.Unlock()
+
+	_ = a.file.Close()
+	a.file = nil
+
+	if a.keep == 0 {
+		_ = os.Remove(a.path)
+	} else {
+		_ = os.Remove(a.rotated(a.keep))
+		for i := a.keep - 1; i >= 1; i-- {
+			if err := os.Rename(a.rotated(i), a.rotated(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("rotate audit log: %w", err)
+			}
+		}
+		if err := os.Rename(a.path, a.rotated(1)); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+	return a.open()
+}
+
+func (a *AuditLog) rotated(n int) string {
+	return a.path + "." + strconv.Itoa(n)
+}
+
+// Close closes the live file. Appends after Close fail.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// Query returns the records matching params, newest first. Lines that do not
+// parse (a torn write after a crash, or a line being appended) are skipped.
+func (a *AuditLog) Query(params AuditQueryParams) (*AuditQueryResult, error) {
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultAuditQueryLimit
+	}
+	if limit > maxAuditQueryLimit {
+		limit = maxAuditQueryLimit
+	}
+	if params.Method != "" {
+		if _, err := path.Match(params.Method, ""); err != nil {
+			return nil, fmt.Errorf("method filter %q: %w", params.Method, err)
+		}
+	}
+
+	// The files are opened together under the lock, so they are one
+	// consistent set, and scanned after it is released: a rotation meanwhile
+	// renames or removes them, but the open files still read the same data.
+	a.rotateMu.RLock()
+	files, err := a.openFiles()
+	a.rotateMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	// The scan runs oldest first; keep the last limit matches in a ring, next
+	// being the slot of the oldest once it is full.
+	result := &AuditQueryResult{}
+	var ring []AuditRecord
+	next := 0
+	for _, f := range files {
+		err := scanAuditFile(f, func(rec AuditRecord) {
+			if !params.matches(rec) {
+				return
+			}
+			if len(ring) < limit {
+				ring = append(ring, rec)
+				return
+			}
+			ring[next] = rec
+			next = (next + 1) % limit
+			result.Truncated = true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Records = make([]AuditRecord, 0, len(ring))
+	for i := range ring {
+		result.Records = append(result.Records, ring[(next-1-i+len(ring))%len(ring)])
+	}
+	return result, nil
+}
+
+// openFiles opens the rotated files and the live file, oldest first. Missing
+// files are skipped. Caller holds a.rotateMu.
+func (a *AuditLog) openFiles() ([]*os.File, error) {
+	names := make([]string, 0, a.keep+1)
+	for i := a.keep; i >= 1; i-- {
+		names = append(names, a.rotated(i))
+	}
+	names = append(names, a.path)
+
+	files := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// scanAuditFile calls fn for every record in f.
+func scanAuditFile(f *os.File, fn func(AuditRecord)) error {
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) == nil {
+			fn(rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read audit log %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+func (p AuditQueryParams) matches(rec AuditRecord) bool {
+	if p.Since != nil && rec.Time.Before(*p.Since) {
+		return false
+	}
+	if p.Until != nil && rec.Time.After(*p.Until) {
+		return false
+	}
+	if p.UID != nil && rec.UID != *p.UID {
+		return false
+	}
+	if p.Method != "" {
+		if ok, _ := path.Match(p.Method, rec.Method); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// redactParams returns params with the value of every secret-looking key
+// replaced, at any depth (tx.commit nests other calls' params). Params that
+// are not JSON are dropped; oversized ones are replaced by their size.
+func redactParams(params json.RawMessage) json.RawMessage {
+	if len(params) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil
+	}
+	if len(out) > maxAuditParams {
+		out, _ = json.Marshal(fmt.Sprintf("[%d bytes omitted]", len(out)))
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if isSecretParam(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
+
+func isSecretParam(key string) bool {
+	return secretParamKeys[strings.ToLower(key)]
+}
+
+// WithAuditLog records privileged calls to log (see audit.go) and serves them
+// through audit.query. Without it, calls are only logged.
+func WithAuditLog(log *AuditLog) ServerOption {
+	return func(s *Server) {
+		s.auditLog = log
+	}
+}
+
+// recordAudit writes the audit record of one privileged call. outcome is the
+// handler's error, or errAuditDenied if authorization rejected the call (a
+// handler wrapping protocol.ErrUnauthorized is recorded as denied too). A
+// failed write is logged; it never fails the call.
+func (s *Server) recordAudit(client *clientConn, req *protocol.Request, start time.Time, outcome error) {
+	if s.auditLog == nil || !isPrivilegedMethod(req.Method) {
+		return
+	}
+
+	rec := AuditRecord{
+		Time:       start.UTC(),
+		UID:        client.uid,
+		PID:        client.pid,
+		Exe:        client.executable(s.procRoot),
+		Transport:  "socket",
+		Method:     req.Method,
+		Params:     redactParams(req.Params),
+		Result:     AuditResultOK,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if client.conn == nil {
+		rec.Transport = "dbus"
+	}
+	switch {
+	case outcome == nil:
+	case errors.Is(outcome, errAuditDenied), errors.Is(outcome, protocol.ErrUnauthorized):
+		rec.Result = AuditResultDenied
+	case errors.Is(outcome, protocol.ErrRateLimited):
+		rec.Result = AuditResultRateLimited
+		rec.Error = outcome.Error()
+	case errors.Is(outcome, context.Canceled):
+		rec.Result = AuditResultCancelled
+	case errors.Is(outcome, context.DeadlineExceeded):
+		rec.Result = AuditResultTimeout
+	default:
+		rec.Result = AuditResultError
+		rec.Error = outcome.Error()
+	}
+
+	if err := s.auditLog.Append(rec); err != nil {
+		s.logger.Printf("WARN: audit record for %s by uid=%d not written: %v", req.Method, client.uid, err)
+	}
+}
+
+// executable returns the caller's executable: the one the identity check
+// resolved, else a read of /proc/<pid>/exe on first use. Empty if unknown.
+func (c *clientConn) executable(procRoot string) string {
+	if c.identity.exe != "" {
+		return c.identity.exe
+	}
+	c.exeOnce.Do(func() {
+		if c.pid <= 0 {
+			return
+		}
+		if procRoot == "" {
+			procRoot = defaultProcRoot
+		}
+		c.exe, _ = os.Readlink(filepath.Join(procRoot, strconv.Itoa(int(c.pid)), "exe"))
+	})
+	return c.exe
+}
+
+// handleAuditQuery returns audit records matching the params. Only root sees
+// every user's calls; anyone else gets their own, whatever UID they ask for.
+func (s *Server) handleAuditQuery(ctx *HandlerContext) (any, error) {
+	if s.auditLog == nil {
+		return nil, errAuditDisabled
+	}
+	var params AuditQueryParams
+	if err := ctx.UnmarshalParams(&params); err != nil {
+		return nil, err
+	}
+	if ctx.UID != 0 {
+		uid := ctx.UID
+		params.UID = &uid
+	}
+	return s.auditLog.Query(params)
+}
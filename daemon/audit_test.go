@@ -0,0 +1,264 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func openTestAuditLog(t *testing.T, maxSize int64, keep int) (*AuditLog, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	a, err := OpenAuditLog(path, maxSize, keep)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Close() })
+	return a, path
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	// Each record is over 100 bytes: a 400-byte limit fits a few per file.
+	a, path := openTestAuditLog(t, 400, 2)
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		rec := AuditRecord{Time: base.Add(time.Duration(i) * time.Minute), UID: 1000, Method: "gateway.enable", Result: AuditResultOK}
+		if err := a.Append(rec); err != nil {
+			t.Fatalf("Append %d: %v", i, err)
+		}
+	}
+
+	kept := 0
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		kept += strings.Count(string(data), "\n")
+		info, _ := os.Stat(name)
+		if info.Size() > 400 {
+			t.Errorf("%s is %d bytes, over the 400-byte limit", name, info.Size())
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", name, info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s.3 should have been dropped (keep = 2), stat err = %v", path, err)
+	}
+
+	// Query reads across the rotated files, newest first.
+	result, err := a.Query(AuditQueryParams{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if n := len(result.Records); n != kept || n >= 10 {
+		t.Fatalf("got %d records, want the %d kept in three files", n, kept)
+	}
+	if !result.Records[0].Time.Equal(base.Add(9 * time.Minute)) {
+		t.Errorf("first record at %v, want the newest", result.Records[0].Time)
+	}
+	for i := 1; i < len(result.Records); i++ {
+		if !result.Records[i].Time.Before(result.Records[i-1].Time) {
+			t.Fatalf("records not newest first: %v", result.Records)
+		}
+	}
+
+	// A limit keeps the newest records even when they span files.
+	result, err = a.Query(AuditQueryParams{Limit: 3})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(result.Records) != 3 || !result.Truncated {
+		t.Fatalf("got %d records (truncated %v), want 3 truncated", len(result.Records), result.Truncated)
+	}
+	for i, rec := range result.Records {
+		if want := base.Add(time.Duration(9-i) * time.Minute); !rec.Time.Equal(want) {
+			t.Errorf("record %d at %v, want %v", i, rec.Time, want)
+		}
+	}
+}
+
+func TestAuditLogQueryFilters(t *testing.T) {
+	a, path := openTestAuditLog(t, 0, DefaultAuditKeep)
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	records := []AuditRecord{
+		{Time: base, UID: 1000, Method: "openvpn.connect", Result: AuditResultOK},
+		{Time: base.Add(time.Hour), UID: 1001, Method: "gateway.enable", Result: AuditResultOK},
+		{Time: base.Add(2 * time.Hour), UID: 1000, Method: "gateway.disable", Result: AuditResultDenied},
+		{Time: base.Add(3 * time.Hour), UID: 1000, Method: "gateway.enable", Result: AuditResultError, Error: "boom"},
+	}
+	for _, rec := range records {
+		if err := a.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A torn line from a crash mid-write is skipped, not fatal.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"time":"2026-10-01T1`)
+	_ = f.Close()
+
+	since := base.Add(30 * time.Minute)
+	until := base.Add(150 * time.Minute)
+	uid := uint32(1000)
+
+	tests := []struct {
+		name          string
+		params        AuditQueryParams
+		wantMethods   []string
+		wantTruncated bool
+	}{
+		{"all", AuditQueryParams{}, []string{"gateway.enable", "gateway.disable", "gateway.enable", "openvpn.connect"}, false},
+		{"method glob", AuditQueryParams{Method: "gateway.*"}, []string{"gateway.enable", "gateway.disable", "gateway.enable"}, false},
+		{"uid", AuditQueryParams{UID: &uid, Method: "gateway.enable"}, []string{"gateway.enable"}, false},
+		{"time window", AuditQueryParams{Since: &since, Until: &until}, []string{"gateway.disable", "gateway.enable"}, false},
+		{"limit keeps the newest", AuditQueryParams{Limit: 2}, []string{"gateway.enable", "gateway.disable"}, true},
+		{"limit of every match", AuditQueryParams{Limit: 4}, []string{"gateway.enable", "gateway.disable", "gateway.enable", "openvpn.connect"}, false},
+		{"limit of a filter", AuditQueryParams{Method: "gateway.*", Limit: 2}, []string{"gateway.enable", "gateway.disable"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := a.Query(tt.params)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			var got []string
+			for _, rec := range result.Records {
+				got = append(got, rec.Method)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantMethods, ",") {
+				t.Errorf("methods = %v, want %v", got, tt.wantMethods)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+
+	if _, err := a.Query(AuditQueryParams{Method: "gateway.["}); err == nil {
+		t.Error("expected an error for a malformed method glob")
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	in := json.RawMessage(`{"profile_id":"work","password":"hunter2","auth_key":"tskey-123","response":"424242",` +
+		`"use_auth_token":true,"steps":[{"method":"x","params":{"otp_code":"123456","port":1194}}]}`)
+	out := string(redactParams(in))
+
+	for _, secret := range []string{"hunter2", "tskey-123", "123456", "424242"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q recorded: %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"profile_id":"work"`, `"port":1194`, `"method":"x"`, `"use_auth_token":true`} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%s missing from %s", kept, out)
+		}
+	}
+
+	if got := redactParams(json.RawMessage(`not json`)); got != nil {
+		t.Errorf("invalid params recorded as %s, want nothing", got)
+	}
+	big := json.RawMessage(`{"routes":"` + strings.Repeat("x", maxAuditParams) + `"}`)
+	if got := string(redactParams(big)); !strings.Contains(got, "bytes omitted") {
+		t.Errorf("oversized params recorded as %.40s..., want a size note", got)
+	}
+}
+
+// TestServerRecordsAudit runs calls through processRequest and checks what
+// lands in the trail: privileged calls with redacted params and their outcome,
+// not read-only ones.
+func TestServerRecordsAudit(t *testing.T) {
+	a, _ := openTestAuditLog(t, 0, 1)
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)), WithAuditLog(a))
+	s.handlers.Register("test.enable", func(ctx *HandlerContext) (any, error) {
+		return "done", nil
+	})
+	s.handlers.Register("test.fail", func(ctx *HandlerContext) (any, error) {
+		return nil, errors.New("rule insert failed")
+	})
+	s.handlers.Register("test.status", func(ctx *HandlerContext) (any, error) {
+		return "up", nil
+	})
+
+	conn, peer := net.Pipe()
+	defer func() { _ = conn.Close(); _ = peer.Close() }()
+	user := &clientConn{conn: conn, uid: 1000, pid: 4242, server: s}
+	system := &clientConn{conn: conn, uid: 500, pid: 4243, server: s}
+
+	call := func(client *clientConn, id int, method string, params any) {
+		req, _ := protocol.NewRequest(id, method, params)
+		s.processRequest(context.Background(), client, req)
+	}
+	call(user, 1, "test.enable", map[string]string{"password": "hunter2", "iface": "eth0"})
+	call(user, 2, "test.fail", nil)
+	call(user, 3, "test.status", nil)
+	call(system, 4, "test.enable", nil)
+
+	result, err := a.Query(AuditQueryParams{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(result.Records) != 3 {
+		t.Fatalf("got %d records, want 3 (test.status is read-only): %+v", len(result.Records), result.Records)
+	}
+
+	denied, failed, ok := result.Records[0], result.Records[1], result.Records[2]
+	if denied.UID != 500 || denied.Result != AuditResultDenied {
+		t.Errorf("denied call recorded as %+v", denied)
+	}
+	if failed.Method != "test.fail" || failed.Result != AuditResultError || failed.Error != "rule insert failed" {
+		t.Errorf("failed call recorded as %+v", failed)
+	}
+	if ok.Method != "test.enable" || ok.Result != AuditResultOK || ok.UID != 1000 || ok.PID != 4242 || ok.Transport != "socket" {
+		t.Errorf("successful call recorded as %+v", ok)
+	}
+	if params := string(ok.Params); strings.Contains(params, "hunter2") || !strings.Contains(params, `"iface":"eth0"`) {
+		t.Errorf("params recorded as %s, want the password redacted and the rest kept", params)
+	}
+
+	// audit.query serves the same records: all of them to root, and only
+	// their own to anyone else, whatever UID they ask for.
+	root := &clientConn{conn: conn, uid: 0, pid: 1, server: s}
+	other := uint32(500)
+	for _, tt := range []struct {
+		client  *clientConn
+		uid     *uint32
+		wantUID []uint32
+	}{
+		{root, nil, []uint32{500, 1000}},
+		{user, nil, []uint32{1000}},
+		{user, &other, []uint32{1000}},
+	} {
+		req, _ := protocol.NewRequest(5, MethodAuditQuery, AuditQueryParams{Method: "test.enable", UID: tt.uid})
+		resp := s.processRequest(context.Background(), tt.client, req)
+		var served AuditQueryResult
+		if err := resp.UnmarshalResult(&served); err != nil {
+			t.Errorf("audit.query by uid=%d: %v", tt.client.uid, err)
+			continue
+		}
+		var got []uint32
+		for _, rec := range served.Records {
+			got = append(got, rec.UID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.wantUID) {
+			t.Errorf("audit.query by uid=%d served records of %v, want %v", tt.client.uid, got, tt.wantUID)
+		}
+	}
+}
@@ -15,22 +15,37 @@ import "strings"
 //     UIDs; allow root and regular users). This is a sanity check layered on top of
 //     the socket boundary — it is NOT a per-method access control.
 //
-//  3. The method classification below is used ONLY for audit logging (privileged
-//     invocations are logged with the caller's UID/PID). It does NOT restrict which
-//     methods a connected, group-authorized user may call: by design every such
-//     user drives all operations through the GUI, so there is no method to withhold
-//     from them.
+//  3. The method classification below is used for audit logging (privileged
+//     invocations are logged with the caller's UID/PID) and to pick the
+//     caller-identity mode (see 4). It does NOT by itself restrict which methods
+//     a connected, group-authorized user may call: by default every such user
+//     drives all operations through the GUI. Withholding methods is the job of
+//     the optional method policy (see 5).
 //
-// RESIDUAL RISK (deliberate, per the chosen deployment model): the group model
-// cannot distinguish the legitimate GUI from another process running as the same
-// group member (a malicious postinstall script, a compromised browser extension).
-// Any process of a group member can drive the root daemon. Fully closing this gap
-// requires a second layer that verifies caller identity — e.g. allowlisting the GUI
-// binary via /proc/<pid>/exe, or a per-session credential — which is intentionally
-// out of scope for the socket-group model. Do not describe classification as if it
-// closed this gap; it does not.
+//  4. OPTIONALLY, a caller-identity policy (identity.go) resolves the peer PID
+//     to its executable via /proc/<pid>/exe and checks it against an allowlist
+//     of paths and SHA-256 digests. Per method class, mismatches are rejected
+//     ("enforce") or only logged ("audit"). It is off unless
+//     /etc/vpn-manager/caller-identity.yaml exists.
+//
+//  5. OPTIONALLY, a method policy (policy.go) reserves methods, by glob, for
+//     named users and groups — e.g. gateway.* for an admin group. It applies
+//     to the socket and D-Bus alike, never to root or classPublic methods, and
+//     is reloaded on SIGHUP. It is off unless /etc/vpn-manager/policy.yaml
+//     exists.
+//
+// RESIDUAL RISK (deliberate, per the chosen deployment model): without a
+// caller-identity policy the group model cannot distinguish the legitimate GUI
+// from another process running as the same group member (a malicious
+// postinstall script, a compromised browser extension). Any process of a group
+// member can drive the root daemon. With the policy in enforce mode, an
+// attacker must instead get code running inside an allowlisted binary (e.g. by
+// ptrace or LD_PRELOAD of the user's own GUI process), which the executable
+// check cannot see. Do not describe classification as if it closed this gap;
+// it does not.
 
-// methodClass classifies an RPC method for audit-logging purposes only.
+// methodClass classifies an RPC method for audit logging and caller-identity
+// policy.
 type methodClass int
 
 const (
@@ -46,9 +61,14 @@ const (
 // methodClasses maps known RPC methods to their class. Any method not present is
 // treated as classPrivileged.
 var methodClasses = map[string]methodClass{
-	"system.ping":    classPublic,
-	"system.version": classPublic,
-	"state.get":      classPublic,
+	"system.ping":     classPublic,
+	"system.version":  classPublic,
+	"system.hello":    classPublic,
+	"system.describe": classPublic,
+	"state.get":       classPublic,
+
+	"events.subscribe":   classPublic,
+	"events.unsubscribe": classPublic,
 }
 
 // classOf returns the classification for a method, defaulting to classPrivileged.
@@ -60,14 +80,16 @@ func classOf(method string) methodClass {
 }
 
 // isPrivilegedMethod reports whether a method mutates system state (for audit
-// logs). Read-only queries (methods ending in .status/.list) are privileged for
-// access control but change nothing, and the UI polls them every ~2s — auditing
-// them would bury the real state-changing events (connect, enable, disconnect…)
-// in the trail. Every mutating method uses a different verb, so the suffix check
-// never excludes one. state.get is already classPublic.
+// logs). Read-only queries (methods ending in .status/.list/.query/.logs) are
+// privileged for access control but change nothing, and clients call them
+// often (or hold one open, following a log) — auditing them would bury the
+// real state-changing events (connect, enable, disconnect…) in the trail.
+// Every mutating method uses a different verb, so the suffix check never
+// excludes one. state.get is already classPublic.
 func isPrivilegedMethod(method string) bool {
 	if classOf(method) != classPrivileged {
 		return false
 	}
-	return !strings.HasSuffix(method, ".status") && !strings.HasSuffix(method, ".list")
+	return !strings.HasSuffix(method, ".status") && !strings.HasSuffix(method, ".list") &&
+		!strings.HasSuffix(method, ".query") && !strings.HasSuffix(method, ".logs")
 }
@@ -23,7 +23,7 @@ func TestClassOf(t *testing.T) {
 	for _, m := range []string{
 		"openvpn.status", "wireguard.status", "tailscale.status",
 		"killswitch.status", "dns.status", "gateway.status", "tunnel.status",
-		"openvpn.list", "wireguard.list",
+		"openvpn.list", "wireguard.list", "openvpn.logs",
 	} {
 		if isPrivilegedMethod(m) {
 			t.Errorf("read-only query %q must not be audited as a privileged (mutating) call", m)
@@ -70,3 +70,14 @@ func TestIsAuthorized(t *testing.T) {
 		})
 	}
 }
+
+// TestIsAuthorizedOwnerOnly checks that an owner-only server (the session
+// agent) accepts its owner alone — root included in the denials.
+func TestIsAuthorizedOwnerOnly(t *testing.T) {
+	s := &Server{ownerOnly: true, ownerUID: 1000}
+	for uid, want := range map[uint32]bool{1000: true, 1001: false, 0: false, 65534: false} {
+		if got := s.isAuthorized(&clientConn{uid: uid}, "system.ping"); got != want {
+			t.Errorf("owner-only isAuthorized(uid=%d) = %v, want %v", uid, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package daemontest runs vpn-managerd in-process for end-to-end tests. Start
+// serves the full privileged API on a temporary socket, with every external
+// program (iptables, nft, ip, wg, resolvectl, openvpn, ...) replaced by an
+// Executor that records the invocation and plays a scripted Response, and
+// every host file the handlers write moved under a temporary root. Clients,
+// the GUI's internal/daemon package and vpn.Manager included, can then drive
+// connect and protection flows without root and without touching the host.
+//
+// The sysexec executor and the file roots are process-wide, so tests using
+// daemontest must not run in parallel with each other.
+package daemontest
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/daemon/privileged"
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// DefaultOpenVPNAddress is the tunnel address the default openvpn fake reports.
+const DefaultOpenVPNAddress = "10.8.0.2"
+
+// Daemon is a vpn-managerd serving on a temporary socket.
+type Daemon struct {
+	// Server is the daemon, with the privileged handlers registered.
+	Server *daemon.Server
+
+	// Exec fakes the external programs. openvpn is scripted to come up with
+	// DefaultOpenVPNAddress and systemd-resolved to be running (so DNS goes
+	// through resolvectl); Script another Response to change either.
+	Exec *Executor
+
+	// SocketPath is where the daemon listens.
+	SocketPath string
+
+	// Root holds the files the handlers write, at their absolute paths
+	// (Root/run/vpn-manager/ovpn, Root/var/lib/vpn-manager/daemon.state, ...).
+	Root string
+
+	logs *syncBuffer
+}
+
+// Start starts a daemon for the duration of the test. The socket is private
+// to the test's user (as the session agent's is), so no group or root is
+// needed to connect.
+func Start(t testing.TB) *Daemon {
+	t.Helper()
+
+	executor := NewExecutor()
+	executor.Script(OpenVPNConnected(DefaultOpenVPNAddress), "openvpn")
+	executor.Script(Response{Stdout: "active\n"}, "systemctl", "is-active", "systemd-resolved")
+	restoreExec := sysexec.SetExecutor(executor)
+
+	// Not t.TempDir: a Unix socket path (the daemon's, and the openvpn
+	// management sockets under Root) must fit in 108 bytes and test names can
+	// be long.
+	root, err := os.MkdirTemp("", "daemontest")
+	if err != nil {
+		restoreExec()
+		t.Fatal(err)
+	}
+	privileged.SetRoot(root)
+
+	d := &Daemon{
+		Exec:       executor,
+		SocketPath: filepath.Join(root, "vpn-managerd.sock"),
+		Root:       root,
+		logs:       &syncBuffer{},
+	}
+	logger := log.New(d.logs, "[vpn-managerd] ", log.Lmsgprefix)
+
+	d.Server = daemon.NewServer(
+		daemon.WithSocketPath(d.SocketPath),
+		daemon.WithOwnerOnly(),
+		daemon.WithLogger(logger),
+		daemon.WithStatePath(filepath.Join(root, daemon.DefaultStatePath)),
+		daemon.WithVersion("daemontest"),
+		daemon.WithCapabilities(privileged.Capabilities),
+	)
+	privileged.RegisterHandlers(d.Server.Handlers(), d.Server.State())
+	privileged.PublishEvents(d.Server.State(), d.Server.Events(), logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.Server.Start(ctx); err != nil {
+		cancel()
+		restoreExec()
+		privileged.SetRoot("/")
+		_ = os.RemoveAll(root)
+		t.Fatalf("daemontest: start daemon: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// Stop the fake openvpn processes the test left running.
+		_ = privileged.GetOpenVPNManager(logger).DisconnectAll()
+		cancel()
+		if err := d.Server.Stop(); err != nil {
+			t.Errorf("daemontest: stop daemon: %v", err)
+		}
+		restoreExec()
+		privileged.SetRoot("/")
+		_ = os.RemoveAll(root)
+		if t.Failed() {
+			t.Logf("daemon log:\n%s", d.Logs())
+		}
+	})
+	return d
+}
+
+// Dial returns a client connected to the daemon, closed when the test ends.
+func (d *Daemon) Dial(t testing.TB) *protocol.Client {
+	t.Helper()
+	client := protocol.NewClient(protocol.WithSocketPath(d.SocketPath))
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("daemontest: connect: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// Logs returns what the daemon has logged so far. It is also printed when the
+// test fails.
+func (d *Daemon) Logs() string {
+	return d.logs.String()
+}
+
+// syncBuffer is a bytes.Buffer the daemon's goroutines can log to concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,311 @@
+package daemontest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon/privileged"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
+	"github.com/yllada/vpn-manager/daemon/privileged/vpn"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func TestExecutor(t *testing.T) {
+	e := NewExecutor()
+	defer sysexec.SetExecutor(e)()
+
+	e.Script(Response{Stdout: "Chain OUTPUT\n"}, "iptables", "-L")
+	e.Script(Response{Stderr: "no such chain", ExitCode: 1}, "iptables", "-L", "VPN_KILLSWITCH")
+	e.Missing("ip6tables")
+
+	out, err := sysexec.Command("iptables", "-L", "OUTPUT", "-n").Output()
+	if err != nil || string(out) != "Chain OUTPUT\n" {
+		t.Errorf("iptables -L OUTPUT = %q, %v", out, err)
+	}
+
+	out, err = sysexec.Command("iptables", "-L", "VPN_KILLSWITCH", "-n").CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || string(out) != "no such chain" {
+		t.Errorf("the later, narrower rule should win: %q, %v", out, err)
+	}
+
+	if err := sysexec.CommandContext(context.Background(), "/usr/sbin/nft", "flush", "ruleset").Run(); err != nil {
+		t.Errorf("unscripted command: %v, want exit 0", err)
+	}
+
+	if _, err := sysexec.LookPath("ip6tables"); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("LookPath(ip6tables) = %v, want not found", err)
+	}
+	if path, err := sysexec.LookPath("nft"); err != nil || path == "" {
+		t.Errorf("LookPath(nft) = %q, %v", path, err)
+	}
+
+	want := []string{
+		"iptables -L OUTPUT -n",
+		"iptables -L VPN_KILLSWITCH -n",
+		"/usr/sbin/nft flush ruleset",
+	}
+	var got []string
+	for _, call := range e.Invocations() {
+		got = append(got, call.String())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("invocations = %q, want %q", got, want)
+	}
+	if calls := e.Calls("nft"); len(calls) != 1 {
+		t.Errorf("Calls(nft) = %v", calls)
+	}
+
+	e.Reset()
+	if calls := e.Invocations(); len(calls) != 0 {
+		t.Errorf("after Reset: %v", calls)
+	}
+}
+
+func TestExecutorBlockingProcess(t *testing.T) {
+	e := NewExecutor()
+	defer sysexec.SetExecutor(e)()
+
+	logFile := filepath.Join(t.TempDir(), "openvpn.log")
+	e.Script(OpenVPNConnected("10.9.0.5"), "openvpn")
+
+	cmd := sysexec.Command("openvpn", "--config", "x.conf", "--log", logFile)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	// The log is written before the process settles down to wait.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(logFile)
+		if strings.Contains(string(data), "Initialization Sequence Completed") {
+			if !strings.Contains(string(data), "net_addr_v4_add: 10.9.0.5/24") {
+				t.Errorf("log = %q", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("log never written: %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-exited:
+		t.Fatalf("a blocking fake exited on its own: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed fake did not exit")
+	}
+}
+
+// TestOpenVPNConnect drives a full connection through the socket: the daemon
+// stages the config under Root, starts the fake openvpn, follows its
+// management interface to "connected" and stops it again.
+func TestOpenVPNConnect(t *testing.T) {
+	d := Start(t)
+	client := d.Dial(t)
+	ctx := context.Background()
+
+	config := filepath.Join(t.TempDir(), "office.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote vpn.example.com 1194\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var result vpn.OpenVPNConnectResult
+	err := client.Call(ctx, "openvpn.connect", vpn.OpenVPNConnectParams{
+		ProfileID:  "office",
+		ConfigPath: config,
+		Username:   "alice",
+		Password:   "secret",
+	}, &result)
+	if err != nil {
+		t.Fatalf("openvpn.connect: %v", err)
+	}
+	if result.PID <= 0 {
+		t.Errorf("PID = %d", result.PID)
+	}
+
+	var status vpn.OpenVPNStatusResult
+	deadline := time.Now().Add(5 * time.Second)
+	for status.Status != vpn.StatusConnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("never connected, last status %+v", status)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := client.Call(ctx, "openvpn.status", privileged.OpenVPNProfileParams{ProfileID: "office"}, &status); err != nil {
+			t.Fatalf("openvpn.status: %v", err)
+		}
+	}
+	if status.IPAddress != DefaultOpenVPNAddress {
+		t.Errorf("IP = %q, want %q", status.IPAddress, DefaultOpenVPNAddress)
+	}
+	if want := OpenVPNServerAddress + ":1194"; status.RemoteAddress != want {
+		t.Errorf("remote = %q, want %q", status.RemoteAddress, want)
+	}
+	if status.State != "CONNECTED" {
+		t.Errorf("state = %q, want CONNECTED", status.State)
+	}
+	// The hook's status and the handler's runtime details end up together,
+	// whichever was recorded first.
+	conn, ok := d.Server.State().GetOpenVPNConnection("office")
+	if !ok || conn.Status != vpn.StatusConnected || conn.PID != result.PID || conn.ManagementSocket == "" {
+		t.Errorf("recorded connection = %+v (tracked %v), want connected with its runtime details", conn, ok)
+	}
+
+	calls := d.Exec.Calls("openvpn")
+	if len(calls) != 1 {
+		t.Fatalf("openvpn started %d times", len(calls))
+	}
+	args := calls[0].Args
+	staged := args[slices.Index(args, "--config")+1]
+	if !strings.HasPrefix(staged, d.Root) {
+		t.Errorf("config %q should be staged under the test root %q", staged, d.Root)
+	}
+	if strings.Contains(calls[0].String(), "secret") {
+		t.Errorf("the password reached argv: %s", calls[0])
+	}
+
+	// The kill switch reads its chain back to verify it is enforcing.
+	d.Exec.Script(Response{Stdout: "chain output { type filter hook output priority 0; policy drop; }\n"},
+		"nft", "list", "chain", "inet", firewall.NftablesTableName, "output")
+	if err := client.Call(ctx, "killswitch.enable", privileged.KillSwitchEnableParams{VPNInterface: "tun0"}, nil); err != nil {
+		t.Fatalf("killswitch.enable: %v", err)
+	}
+	if len(d.Exec.Calls("nft")) == 0 {
+		t.Error("the kill switch ran no nft commands")
+	}
+
+	if err := client.Call(ctx, "openvpn.disconnect", privileged.OpenVPNProfileParams{ProfileID: "office"}, nil); err != nil {
+		t.Fatalf("openvpn.disconnect: %v", err)
+	}
+	var list []vpn.OpenVPNStatusResult
+	if err := client.Call(ctx, "openvpn.list", nil, &list); err != nil {
+		t.Fatalf("openvpn.list: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("connections after disconnect: %+v", list)
+	}
+}
+
+func TestOpenVPNConnectExitsAtOnce(t *testing.T) {
+	d := Start(t)
+	client := d.Dial(t)
+	d.Exec.Script(Response{Log: []string{"Options error: bad config"}, ExitCode: 1}, "openvpn")
+
+	config := filepath.Join(t.TempDir(), "broken.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(context.Background(), "openvpn.connect", vpn.OpenVPNConnectParams{ProfileID: "broken", ConfigPath: config}, nil); err != nil {
+		t.Fatalf("openvpn.connect: %v", err)
+	}
+
+	// However the exit and the handler interleave, the dead connection must
+	// not stay recorded as connecting.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, ok := d.Server.State().GetOpenVPNConnection("broken")
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("connection still recorded after openvpn exited: %+v", conn)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Nothing may add it back once the handler returned.
+	time.Sleep(100 * time.Millisecond)
+	if conn, ok := d.Server.State().GetOpenVPNConnection("broken"); ok {
+		t.Errorf("exited connection recorded again: %+v", conn)
+	}
+}
+
+// TestOpenVPNLogs follows a connection attempt's log through the socket and
+// reads it back once the process is gone.
+func TestOpenVPNLogs(t *testing.T) {
+	d := Start(t)
+	client := d.Dial(t)
+	ctx := context.Background()
+	// The OpenVPN manager, and each profile's log with it, outlives the
+	// daemon of one run (go test -count), so use a profile no run has logged.
+	profile := "lab-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	// Following holds its connection, so it gets one of its own.
+	follower := d.Dial(t)
+	followCtx, stopFollowing := context.WithTimeout(ctx, 5*time.Second)
+	defer stopFollowing()
+	followed := make(chan error, 1)
+	var lines []string
+	go func() {
+		followed <- follower.Stream(followCtx, "openvpn.logs", privileged.OpenVPNLogsParams{ProfileID: profile, Follow: true},
+			func(ev protocol.Event) {
+				var line vpn.OpenVPNLogLine
+				_ = ev.UnmarshalData(&line)
+				lines = append(lines, line.Text)
+				if line.Text == "Initialization Sequence Completed" {
+					stopFollowing()
+				}
+			}, protocol.TopicOpenVPNLog)
+	}()
+
+	config := filepath.Join(t.TempDir(), "lab.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote vpn.example.com 1194\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Let the follower register before the session starts.
+	time.Sleep(50 * time.Millisecond)
+	if err := client.Call(ctx, "openvpn.connect", vpn.OpenVPNConnectParams{ProfileID: profile, ConfigPath: config}, nil); err != nil {
+		t.Fatalf("openvpn.connect: %v", err)
+	}
+
+	if err := <-followed; err != nil {
+		t.Fatalf("following: %v", err)
+	}
+	if len(lines) == 0 || lines[0] != "--- OpenVPN session started ---" || lines[len(lines)-1] != "Initialization Sequence Completed" {
+		t.Errorf("followed %q, want the session from its start to completion", lines)
+	}
+
+	if err := client.Call(ctx, "openvpn.disconnect", privileged.OpenVPNProfileParams{ProfileID: profile}, nil); err != nil {
+		t.Fatalf("openvpn.disconnect: %v", err)
+	}
+	var result privileged.OpenVPNLogsResult
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := client.Call(ctx, "openvpn.logs", privileged.OpenVPNLogsParams{ProfileID: profile}, &result); err != nil {
+			t.Fatalf("openvpn.logs: %v", err)
+		}
+		if n := len(result.Lines); n > 0 && strings.HasPrefix(result.Lines[n-1].Text, "--- OpenVPN session ended") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the session's end was not logged: %+v", result.Lines)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(d.Root, "var/log/vpn-manager/openvpn", profile, "*.log"))
+	if len(files) != 1 {
+		t.Errorf("session files under the test root = %v, want one", files)
+	}
+	if err := client.Call(ctx, "openvpn.logs", privileged.OpenVPNLogsParams{ProfileID: profile, Level: "debug"}, nil); err == nil {
+		t.Error("an unknown level should be rejected")
+	}
+}
@@ -0,0 +1,374 @@
+package daemontest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// helperEnv carries a faked command's Response to the helper process.
+const helperEnv = "VPN_MANAGER_DAEMONTEST_FAKE"
+
+// The fake commands are this test binary started again: init notices the
+// helper variable, plays the scripted Response and exits before any test runs.
+// A real process keeps the daemon's code paths intact (pipes, PIDs, Wait,
+// Process.Kill) for programs such as openvpn that it supervises.
+func init() {
+	if spec, ok := os.LookupEnv(helperEnv); ok {
+		os.Exit(runHelper(spec, os.Args[1:]))
+	}
+}
+
+// Invocation is one command the daemon started.
+type Invocation struct {
+	Name string
+	Args []string
+}
+
+// String returns the command line, for test failure messages.
+func (i Invocation) String() string {
+	return strings.Join(append([]string{i.Name}, i.Args...), " ")
+}
+
+// Response is what a faked command does.
+type Response struct {
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+
+	// Log lines are appended to the file named by the command's --log
+	// argument, as openvpn writes its log.
+	Log []string `json:"log,omitempty"`
+
+	// Management lines are the notifications (">STATE:...", ">LOG:...",
+	// ">PASSWORD:...") sent on the socket named by the command's --management
+	// argument once the daemon releases the hold. An empty time field in a
+	// >STATE or >LOG line is filled in when it is sent. Every command is
+	// answered with SUCCESS; "signal SIGTERM" also ends a blocking fake.
+	Management []string `json:"management,omitempty"`
+
+	// Replies are further notifications, sent after a management command
+	// starting with the key (`password "Auth" "SCRV1:`, say) is answered.
+	Replies map[string][]string `json:"replies,omitempty"`
+
+	// Block keeps the process running until it is signalled, like a daemon
+	// (openvpn). SIGTERM and SIGINT end it with ExitCode.
+	Block bool `json:"block,omitempty"`
+}
+
+// OpenVPNConnected is the Response of an openvpn that brings the tunnel up
+// with address ip, reports some traffic and keeps running until it is
+// stopped.
+func OpenVPNConnected(ip string) Response {
+	return Response{
+		Log: []string{
+			"OpenVPN 2.6.0 x86_64-pc-linux-gnu",
+			"net_addr_v4_add: " + ip + "/24 dev tun0",
+			"Initialization Sequence Completed",
+		},
+		Management: []string{
+			">STATE:,CONNECTING,,,,,,",
+			">LOG:,I,OpenVPN 2.6.0 x86_64-pc-linux-gnu",
+			">STATE:,ASSIGN_IP,," + ip + ",,,,",
+			">LOG:,I,net_addr_v4_add: " + ip + "/24 dev tun0",
+			">LOG:,I,Initialization Sequence Completed",
+			">STATE:,CONNECTED,SUCCESS," + ip + "," + OpenVPNServerAddress + ",1194,,",
+			">BYTECOUNT:4096,1024",
+		},
+		Block: true,
+	}
+}
+
+// OpenVPNServerAddress is the server OpenVPNConnected reports connecting to.
+const OpenVPNServerAddress = "198.51.100.1"
+
+type rule struct {
+	name   string
+	prefix []string
+	resp   Response
+}
+
+// Executor is a sysexec.Executor that fakes every program: it records each
+// invocation and answers with the Response scripted for it. Every program is
+// reported as installed unless marked Missing. It is safe for concurrent use.
+type Executor struct {
+	mu      sync.Mutex
+	rules   []rule
+	missing map[string]bool
+	calls   []Invocation
+}
+
+// NewExecutor returns an Executor with nothing scripted: every command exits 0
+// without output.
+func NewExecutor() *Executor {
+	return &Executor{missing: make(map[string]bool)}
+}
+
+// Script makes commands named name whose arguments start with prefix answer
+// with resp. The rule added last wins when several match, so a test can
+// override a broader rule or a default.
+func (e *Executor) Script(resp Response, name string, prefix ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule{name: name, prefix: prefix, resp: resp})
+}
+
+// Missing makes LookPath report the named programs as not installed.
+func (e *Executor) Missing(names ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range names {
+		e.missing[name] = true
+	}
+}
+
+// Invocations returns every command started so far, in order.
+func (e *Executor) Invocations() []Invocation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.calls)
+}
+
+// Calls returns the invocations of the named program, in order.
+func (e *Executor) Calls(name string) []Invocation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var calls []Invocation
+	for _, call := range e.calls {
+		if filepath.Base(call.Name) == name {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded invocations; scripted responses are kept.
+func (e *Executor) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = nil
+}
+
+// LookPath implements sysexec.Executor.
+func (e *Executor) LookPath(file string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.missing[filepath.Base(file)] {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	if strings.Contains(file, "/") {
+		return file, nil
+	}
+	return filepath.Join("/usr/sbin", file), nil
+}
+
+// CommandContext implements sysexec.Executor. The command runs the helper
+// process under name as argv[0], so /proc/<pid>/cmdline reads like the real
+// program's.
+func (e *Executor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	e.mu.Lock()
+	e.calls = append(e.calls, Invocation{Name: name, Args: slices.Clone(args)})
+	resp := e.responseLocked(name, args)
+	e.mu.Unlock()
+
+	spec, err := json.Marshal(resp)
+	if err != nil {
+		panic(fmt.Sprintf("daemontest: encode response: %v", err))
+	}
+	cmd := exec.CommandContext(ctx, helperPath)
+	cmd.Args = append([]string{name}, args...)
+	cmd.Env = append(os.Environ(), helperEnv+"="+string(spec))
+	// A blocking fake must not outlive the test binary.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	return cmd
+}
+
+func (e *Executor) responseLocked(name string, args []string) Response {
+	for _, r := range slices.Backward(e.rules) {
+		if r.name == filepath.Base(name) && len(args) >= len(r.prefix) && slices.Equal(args[:len(r.prefix)], r.prefix) {
+			return r.resp
+		}
+	}
+	return Response{}
+}
+
+// helperPath is the test binary, started again for every faked command.
+var helperPath = func() string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return os.Args[0]
+}()
+
+// runHelper plays the Response encoded in spec for a command with args and
+// returns its exit code.
+func runHelper(spec string, args []string) int {
+	var resp Response
+	if err := json.Unmarshal([]byte(spec), &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "daemontest: bad response: %v\n", err)
+		return 127
+	}
+
+	if len(resp.Log) > 0 {
+		if err := appendLog(args, resp.Log); err != nil {
+			fmt.Fprintf(os.Stderr, "daemontest: %v\n", err)
+			return 127
+		}
+	}
+	stopped := make(chan struct{})
+	if i := slices.Index(args, "--management"); i >= 0 && i+1 < len(args) {
+		mgmt := &fakeManagement{events: resp.Management, replies: resp.Replies, stopped: stopped}
+		if err := mgmt.listen(args[i+1]); err != nil {
+			fmt.Fprintf(os.Stderr, "daemontest: %v\n", err)
+			return 127
+		}
+	}
+	_, _ = os.Stdout.WriteString(resp.Stdout)
+	_, _ = os.Stderr.WriteString(resp.Stderr)
+
+	if resp.Block {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+		select {
+		case <-sig:
+		case <-stopped:
+		}
+	}
+	return resp.ExitCode
+}
+
+// fakeManagement plays openvpn's management interface in a faked command.
+type fakeManagement struct {
+	events  []string
+	replies map[string][]string
+	stopped chan struct{} // closed by "signal SIGTERM"
+
+	mu       sync.Mutex
+	released bool
+	states   []string // history for "state on all"
+	logs     []string // history for "log on all"
+	stopOnce sync.Once
+}
+
+// listen opens the management socket and serves one daemon connection at a
+// time, as openvpn does.
+func (f *fakeManagement) listen(path string) error {
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("management socket: %w", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.serve(conn)
+		}
+	}()
+	return nil
+}
+
+func (f *fakeManagement) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	send := func(lines ...string) {
+		for _, line := range lines {
+			_, _ = io.WriteString(conn, line+"\r\n")
+		}
+	}
+
+	send(">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info")
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		switch cmd := scanner.Text(); cmd {
+		case "state on all", "log on all":
+			f.mu.Lock()
+			history := f.states
+			if cmd == "log on all" {
+				history = f.logs
+			}
+			send("SUCCESS: real-time notification set to ON")
+			send(history...)
+			send("END")
+			f.mu.Unlock()
+		case "hold release":
+			send("SUCCESS: hold release succeeded")
+			f.mu.Lock()
+			if !f.released {
+				f.released = true
+				for _, event := range f.events {
+					send(f.record(stampEvent(event)))
+				}
+			}
+			f.mu.Unlock()
+		case "signal SIGTERM":
+			send("SUCCESS: signal SIGTERM thrown")
+			send(">STATE:" + strconv.FormatInt(time.Now().Unix(), 10) + ",EXITING,SIGTERM,,,,,")
+			f.stopOnce.Do(func() { close(f.stopped) })
+		default:
+			send("SUCCESS: " + cmd)
+			f.mu.Lock()
+			for prefix, events := range f.replies {
+				if strings.HasPrefix(cmd, prefix) {
+					for _, event := range events {
+						send(f.record(stampEvent(event)))
+					}
+				}
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// record adds a sent notification to the history a reconnecting daemon asks
+// for, and returns it. Must be called with f.mu held.
+func (f *fakeManagement) record(event string) string {
+	if payload, ok := strings.CutPrefix(event, ">STATE:"); ok {
+		f.states = append(f.states, payload)
+	} else if payload, ok := strings.CutPrefix(event, ">LOG:"); ok {
+		f.logs = append(f.logs, payload)
+	}
+	return event
+}
+
+// stampEvent fills in the empty time field of a >STATE or >LOG line.
+func stampEvent(event string) string {
+	for _, prefix := range []string{">STATE:,", ">LOG:,"} {
+		if rest, ok := strings.CutPrefix(event, prefix); ok {
+			return prefix[:len(prefix)-1] + strconv.FormatInt(time.Now().Unix(), 10) + "," + rest
+		}
+	}
+	return event
+}
+
+// appendLog writes lines to the file named by the --log argument.
+func appendLog(args, lines []string) error {
+	i := slices.Index(args, "--log")
+	if i < 0 || i+1 >= len(args) {
+		return fmt.Errorf("log lines scripted for a command without --log")
+	}
+	f, err := os.OpenFile(args[i+1], os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
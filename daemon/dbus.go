@@ -0,0 +1,558 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// This file exposes the daemon on the system bus as com.vpnmanager.Daemon1, for
+// desktop components that cannot speak the newline-JSON socket protocol
+// (GNOME Shell extensions, Plasma widgets, busctl scripts).
+//
+//   - Every registered RPC method becomes a D-Bus method named in CamelCase
+//     ("killswitch.enable" → KillswitchEnable). It takes the JSON-RPC params
+//     as a JSON string and returns the result as a JSON string, so the two
+//     front ends share one set of handlers, timeouts and audit logging.
+//   - StateSnapshot is published as properties (one a{sv} per feature), with
+//     PropertiesChanged emitted whenever an event changes them.
+//   - Every event-hub event is also emitted as an Event(topic, data) signal.
+//
+// AUTHORIZATION: a call over the bus passes the same checks as one over the
+// socket (see processRequest): the UID floor of isAuthorized, the
+// caller-identity policy and the method policy, so the bus is no way around
+// them. The socket's group boundary is kept too: members of the socket group
+// could use the socket anyway, so they skip polkit. Everyone else is checked
+// with polkit against the action for the method's class (see polkitActionFor
+// and build/polkit/com.vpnmanager.daemon.policy), which asks for an
+// administrator's password before reading or changing anything. Root callers
+// skip polkit, as it would grant them anyway. The bus policy
+// (build/dbus/com.vpnmanager.Daemon1.conf) likewise keeps the Event signal and
+// the properties to root and the socket group.
+
+const (
+	// DBusName is the well-known bus name the daemon owns.
+	DBusName = "com.vpnmanager.Daemon1"
+
+	// DBusPath is the object the daemon's interface is exported on.
+	DBusPath = dbus.ObjectPath("/com/vpnmanager/Daemon1")
+
+	// DBusInterface is the daemon's D-Bus interface.
+	DBusInterface = "com.vpnmanager.Daemon1"
+)
+
+// Polkit actions, one per method class. The policy file defines who may do
+// what without a password.
+const (
+	PolkitActionRead      = "com.vpnmanager.daemon.read"
+	PolkitActionConnect   = "com.vpnmanager.daemon.connect"
+	PolkitActionConfigure = "com.vpnmanager.daemon.configure"
+)
+
+// D-Bus error names returned by mapped methods.
+const (
+	dbusErrFailed       = DBusInterface + ".Error.Failed"
+	dbusErrTimeout      = DBusInterface + ".Error.Timeout"
+	dbusErrInvalidArgs  = DBusInterface + ".Error.InvalidArgs"
+	dbusErrAccessDenied = DBusInterface + ".Error.AccessDenied"
+	dbusErrRateLimited  = DBusInterface + ".Error.RateLimited"
+)
+
+// dbusExcludedMethods are RPC methods with no D-Bus equivalent: subscriptions
+// and streams belong to a socket connection, and D-Bus clients get the Event
+// signal and PropertiesChanged instead. The audit trail, which records every
+// user's calls, stays behind the socket's group boundary.
+var dbusExcludedMethods = map[string]bool{
+	"events.subscribe":   true,
+	"events.unsubscribe": true,
+	MethodAuditQuery:     true,
+	"openvpn.logs":       true,
+}
+
+// connectMethodPrefixes are the method families that bring tunnels up or down.
+var connectMethodPrefixes = []string{"openvpn.", "wireguard.", "tailscale.up", "tailscale.down"}
+
+// polkitActionFor returns the polkit action that guards method.
+func polkitActionFor(method string) string {
+	if !isPrivilegedMethod(method) {
+		return PolkitActionRead
+	}
+	for _, prefix := range connectMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return PolkitActionConnect
+		}
+	}
+	return PolkitActionConfigure
+}
+
+// dbusMethodName maps an RPC method name to its D-Bus member name:
+// "tailscale.set_operator" → "TailscaleSetOperator".
+func dbusMethodName(method string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range method {
+		if r == '.' || r == '_' || r == '-' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dbusCaller is what the bus reports about a method call's sender.
+type dbusCaller struct {
+	uid uint32
+	pid uint32
+}
+
+// DBusService publishes a Server on the system bus. Start it once the Server
+// is started and all handlers are registered: the method table is fixed then.
+type DBusService struct {
+	server *Server
+	conn   *dbus.Conn
+
+	// Seams for tests; default to bus and polkit queries on conn.
+	lookupCaller func(sender string) (dbusCaller, error)
+	authorize    func(ctx context.Context, sender, action string) (bool, error)
+
+	// Last published property values, to emit only real changes.
+	propsMu sync.Mutex
+	props   map[string]any
+
+	sub  *eventSubscriber
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDBusService creates the D-Bus front end for server.
+func NewDBusService(server *Server) *DBusService {
+	d := &DBusService{server: server, done: make(chan struct{})}
+	d.lookupCaller = d.busCaller
+	d.authorize = d.polkitAuthorize
+	return d
+}
+
+// Start connects to the system bus, exports the interface and claims DBusName.
+// On failure the service is stopped and cannot be restarted.
+func (d *DBusService) Start() (err error) {
+	if d.conn == nil {
+		conn, err := dbus.ConnectSystemBus()
+		if err != nil {
+			return fmt.Errorf("connect to system bus: %w", err)
+		}
+		d.conn = conn
+	}
+	defer func() {
+		if err != nil {
+			d.Stop()
+		}
+	}()
+
+	methods := d.methodTable()
+	if err := d.conn.ExportMethodTable(methods, DBusPath, DBusInterface); err != nil {
+		return fmt.Errorf("export methods: %w", err)
+	}
+	if err := d.conn.Export(dbusProperties{d}, DBusPath, "org.freedesktop.DBus.Properties"); err != nil {
+		return fmt.Errorf("export properties: %w", err)
+	}
+	if err := d.conn.Export(d.introspection(methods), DBusPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return fmt.Errorf("export introspection: %w", err)
+	}
+
+	// Track state before the name is visible, so the first PropertiesChanged
+	// only reports real changes.
+	d.props = d.snapshotProperties()
+	d.sub = d.server.events.subscribe(nil)
+	d.wg.Add(1)
+	go d.forwardEvents()
+
+	reply, err := d.conn.RequestName(DBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("request name %s: %w", DBusName, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return fmt.Errorf("bus name %s is already owned", DBusName)
+	}
+
+	d.server.logger.Printf("D-Bus service %s registered (%d methods)", DBusName, len(methods))
+	return nil
+}
+
+// Stop releases the bus name and closes the connection.
+func (d *DBusService) Stop() {
+	select {
+	case <-d.done:
+		return
+	default:
+		close(d.done)
+	}
+	if d.sub != nil {
+		d.server.events.unsubscribe(d.sub)
+	}
+	d.wg.Wait()
+	if d.conn != nil {
+		_, _ = d.conn.ReleaseName(DBusName)
+		_ = d.conn.Close()
+	}
+}
+
+// methodTable maps every D-Bus exposable RPC method to a bus method.
+func (d *DBusService) methodTable() map[string]any {
+	table := make(map[string]any)
+	for _, method := range d.server.handlers.Methods() {
+		if dbusExcludedMethods[method] {
+			continue
+		}
+		table[dbusMethodName(method)] = func(sender dbus.Sender, params string) (string, *dbus.Error) {
+			return d.call(string(sender), method, params)
+		}
+	}
+	return table
+}
+
+// call authorizes and runs one mapped method.
+func (d *DBusService) call(sender, method, params string) (string, *dbus.Error) {
+	s := d.server
+	handler, ok := s.handlers.Get(method)
+	if !ok {
+		return "", dbus.NewError(dbusErrFailed, []any{"unknown method " + method})
+	}
+	if params != "" && !json.Valid([]byte(params)) {
+		return "", dbus.NewError(dbusErrInvalidArgs, []any{"params must be a JSON value"})
+	}
+
+	caller, err := d.lookupCaller(sender)
+	if err != nil {
+		s.logger.Printf("D-Bus: cannot identify sender %s: %v", sender, err)
+		return "", dbus.NewError(dbusErrAccessDenied, []any{"cannot identify caller"})
+	}
+
+	start := time.Now()
+	req := &protocol.Request{JSONRPC: protocol.JSONRPCVersion, Method: method}
+	if params != "" {
+		req.Params = json.RawMessage(params)
+	}
+	// A D-Bus caller has no socket connection; the GID is not known.
+	client := &clientConn{uid: caller.uid, pid: int32(caller.pid), server: s}
+	if s.identity != nil && client.uid != 0 {
+		client.identity = s.identity.verify(client.pid)
+	}
+
+	if !s.isAuthorized(client, method) {
+		s.logger.Printf("Unauthorized D-Bus request from uid=%d: %s", caller.uid, method)
+		s.recordAudit(client, req, start, errAuditDenied)
+		return "", dbus.NewError(dbusErrAccessDenied, []any{"not authorized"})
+	}
+
+	if caller.uid != 0 && !s.inSocketGroup(client) {
+		ctx, cancel := context.WithTimeout(context.Background(), getMethodTimeout(method))
+		allowed, err := d.authorize(ctx, sender, polkitActionFor(method))
+		cancel()
+		if err != nil {
+			s.logger.Printf("D-Bus: polkit check for %s by uid=%d failed: %v", method, caller.uid, err)
+			return "", dbus.NewError(dbusErrAccessDenied, []any{"authorization check failed"})
+		}
+		if !allowed {
+			s.logger.Printf("Unauthorized D-Bus request from uid=%d: %s", caller.uid, method)
+			s.recordAudit(client, req, start, errAuditDenied)
+			return "", dbus.NewError(dbusErrAccessDenied, []any{"not authorized"})
+		}
+	}
+
+	if !s.checkCallerIdentity(client, method) || !s.checkMethodPolicy(client, method) {
+		s.recordAudit(client, req, start, errAuditDenied)
+		return "", dbus.NewError(dbusErrAccessDenied, []any{"denied by policy"})
+	}
+
+	release, limitErr := s.admitRequest(client, method)
+	if limitErr != nil {
+		s.recordAudit(client, req, start, limitErr)
+		return "", dbus.NewError(dbusErrRateLimited, []any{limitErr.reason})
+	}
+	defer release()
+
+	if isPrivilegedMethod(method) {
+		s.logger.Printf("AUDIT: privileged call %s by uid=%d pid=%d (D-Bus)", method, caller.uid, caller.pid)
+	}
+
+	result, err := s.invoke(context.Background(), client, handler, req)
+	s.recordAudit(client, req, start, err)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", dbus.NewError(dbusErrTimeout, []any{"operation timed out"})
+		}
+		if errors.Is(err, protocol.ErrRateLimited) {
+			return "", dbus.NewError(dbusErrRateLimited, []any{err.Error()})
+		}
+		if errors.Is(err, protocol.ErrUnauthorized) {
+			return "", dbus.NewError(dbusErrAccessDenied, []any{"denied by policy"})
+		}
+		s.logger.Printf("Handler error for %s: %v", method, err)
+		return "", dbus.NewError(dbusErrFailed, []any{err.Error()})
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", dbus.NewError(dbusErrFailed, []any{err.Error()})
+	}
+	// Mapped methods may change state without publishing an event.
+	d.refreshProperties()
+	return string(data), nil
+}
+
+// inSocketGroup reports whether client is a member of the socket group, and so
+// could reach every method through the socket without polkit.
+func (s *Server) inSocketGroup(client *clientConn) bool {
+	grp, err := lookupGroup(s.socketGroup)
+	if err != nil {
+		return false
+	}
+	gid, err := strconv.ParseUint(grp.Gid, 10, 32)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(client.groups(s.procRoot), uint32(gid))
+}
+
+// busCaller asks the bus daemon who sent a message.
+func (d *DBusService) busCaller(sender string) (dbusCaller, error) {
+	var creds map[string]dbus.Variant
+	err := d.conn.BusObject().Call("org.freedesktop.DBus.GetConnectionCredentials", 0, sender).Store(&creds)
+	if err != nil {
+		return dbusCaller{}, err
+	}
+	uid, ok := creds["UnixUserID"].Value().(uint32)
+	if !ok {
+		return dbusCaller{}, errors.New("bus did not report the caller's UID")
+	}
+	pid, _ := creds["ProcessID"].Value().(uint32)
+	return dbusCaller{uid: uid, pid: pid}, nil
+}
+
+// polkitAuthorize asks polkit whether sender may perform action, letting it
+// prompt through the user's authentication agent when the policy says so.
+func (d *DBusService) polkitAuthorize(ctx context.Context, sender, action string) (bool, error) {
+	subject := struct {
+		Kind    string
+		Details map[string]dbus.Variant
+	}{"system-bus-name", map[string]dbus.Variant{"name": dbus.MakeVariant(sender)}}
+
+	const allowUserInteraction = uint32(1)
+	var result struct {
+		IsAuthorized bool
+		IsChallenge  bool
+		Details      map[string]string
+	}
+	authority := d.conn.Object("org.freedesktop.PolicyKit1", "/org/freedesktop/PolicyKit1/Authority")
+	err := authority.CallWithContext(ctx, "org.freedesktop.PolicyKit1.Authority.CheckAuthorization", 0,
+		subject, action, map[string]string{}, allowUserInteraction, "").Store(&result)
+	if err != nil {
+		return false, err
+	}
+	return result.IsAuthorized, nil
+}
+
+// forwardEvents re-emits hub events as signals and keeps the properties current.
+func (d *DBusService) forwardEvents() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.sub.done:
+			return
+		case n := <-d.sub.queue:
+			var ev protocol.Event
+			if err := n.UnmarshalParams(&ev); err != nil {
+				continue
+			}
+			if err := d.conn.Emit(DBusPath, DBusInterface+".Event", ev.Topic, string(ev.Data)); err != nil {
+				d.server.logger.Printf("D-Bus: failed to emit %s event: %v", ev.Topic, err)
+			}
+			d.refreshProperties()
+		}
+	}
+}
+
+// refreshProperties emits PropertiesChanged for properties whose value changed
+// since the last call.
+func (d *DBusService) refreshProperties() {
+	current := d.snapshotProperties()
+
+	d.propsMu.Lock()
+	changed := make(map[string]dbus.Variant)
+	for name, value := range current {
+		if dbusPropertyEmits[name] && !reflect.DeepEqual(d.props[name], value) {
+			changed[name] = dbus.MakeVariant(value)
+		}
+	}
+	d.props = current
+	d.propsMu.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+	if err := d.conn.Emit(DBusPath, "org.freedesktop.DBus.Properties.PropertiesChanged",
+		DBusInterface, changed, []string{}); err != nil {
+		d.server.logger.Printf("D-Bus: failed to emit PropertiesChanged: %v", err)
+	}
+}
+
+// dbusPropertyEmits lists the properties that emit PropertiesChanged. The
+// uptime changes every second and is read on demand.
+var dbusPropertyEmits = map[string]bool{
+	"KillSwitch":     true,
+	"DNSProtection":  true,
+	"IPv6Protection": true,
+	"SplitTunnel":    true,
+	"LANGateway":     true,
+	"Tailscale":      true,
+	"UptimeSeconds":  false,
+}
+
+// snapshotProperties maps the current StateSnapshot to D-Bus property values.
+func (d *DBusService) snapshotProperties() map[string]any {
+	snap := d.server.state.Snapshot()
+	return map[string]any{
+		"KillSwitch":     structToVariantMap(snap.KillSwitch),
+		"DNSProtection":  structToVariantMap(snap.DNSProtection),
+		"IPv6Protection": structToVariantMap(snap.IPv6Protection),
+		"SplitTunnel":    structToVariantMap(snap.SplitTunnel),
+		"LANGateway":     structToVariantMap(snap.LANGateway),
+		"Tailscale":      structToVariantMap(snap.Tailscale),
+		"UptimeSeconds":  snap.UptimeSeconds,
+	}
+}
+
+// structToVariantMap turns a state struct into an a{sv} dictionary keyed by
+// its JSON field names, so D-Bus and socket clients see the same keys. Fields
+// hidden from JSON are hidden here too; nil slices become empty arrays. A
+// pointer to a struct (the owner) becomes a nested dictionary, and is left out
+// when nil.
+func structToVariantMap(v any) map[string]dbus.Variant {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	out := make(map[string]dbus.Variant, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		field := rv.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if !field.IsNil() {
+				out[name] = dbus.MakeVariant(structToVariantMap(field.Elem().Interface()))
+			}
+			continue
+		}
+		if field.Kind() == reflect.Slice && field.IsNil() {
+			field = reflect.MakeSlice(field.Type(), 0, 0)
+		}
+		out[name] = dbus.MakeVariant(field.Interface())
+	}
+	return out
+}
+
+// dbusProperties implements org.freedesktop.DBus.Properties with live values.
+type dbusProperties struct{ d *DBusService }
+
+func (p dbusProperties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	if iface != DBusInterface {
+		return dbus.Variant{}, prop.ErrIfaceNotFound
+	}
+	value, ok := p.d.snapshotProperties()[name]
+	if !ok {
+		return dbus.Variant{}, prop.ErrPropNotFound
+	}
+	return dbus.MakeVariant(value), nil
+}
+
+func (p dbusProperties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	if iface != DBusInterface {
+		return nil, prop.ErrIfaceNotFound
+	}
+	out := make(map[string]dbus.Variant)
+	for name, value := range p.d.snapshotProperties() {
+		out[name] = dbus.MakeVariant(value)
+	}
+	return out, nil
+}
+
+func (p dbusProperties) Set(iface, name string, _ dbus.Variant) *dbus.Error {
+	if iface != DBusInterface {
+		return prop.ErrIfaceNotFound
+	}
+	return prop.ErrReadOnly
+}
+
+// introspection describes the exported object.
+func (d *DBusService) introspection(methods map[string]any) introspect.Introspectable {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	iface := introspect.Interface{Name: DBusInterface}
+	for _, name := range names {
+		iface.Methods = append(iface.Methods, introspect.Method{
+			Name: name,
+			Args: []introspect.Arg{
+				{Name: "params", Type: "s", Direction: "in"},
+				{Name: "result", Type: "s", Direction: "out"},
+			},
+		})
+	}
+	iface.Signals = []introspect.Signal{{
+		Name: "Event",
+		Args: []introspect.Arg{
+			{Name: "topic", Type: "s", Direction: "out"},
+			{Name: "data", Type: "s", Direction: "out"},
+		},
+	}}
+
+	props := d.snapshotProperties()
+	propNames := make([]string, 0, len(props))
+	for name := range props {
+		propNames = append(propNames, name)
+	}
+	sort.Strings(propNames)
+	for _, name := range propNames {
+		emits := "true"
+		if !dbusPropertyEmits[name] {
+			emits = "false"
+		}
+		iface.Properties = append(iface.Properties, introspect.Property{
+			Name:   name,
+			Type:   dbus.SignatureOf(props[name]).String(),
+			Access: "read",
+			Annotations: []introspect.Annotation{
+				{Name: "org.freedesktop.DBus.Property.EmitsChangedSignal", Value: emits},
+			},
+		})
+	}
+
+	return introspect.NewIntrospectable(&introspect.Node{
+		Name:       string(DBusPath),
+		Interfaces: []introspect.Interface{iface, prop.IntrospectData},
+	})
+}
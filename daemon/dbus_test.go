@@ -0,0 +1,302 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func TestDBusMethodName(t *testing.T) {
+	tests := map[string]string{
+		"system.ping":            "SystemPing",
+		"killswitch.block_all":   "KillswitchBlockAll",
+		"tailscale.set_operator": "TailscaleSetOperator",
+		"openvpn.connect":        "OpenvpnConnect",
+	}
+	for method, want := range tests {
+		if got := dbusMethodName(method); got != want {
+			t.Errorf("dbusMethodName(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+// TestPolkitActionFor pins which polkit action guards each method class:
+// queries must never need more than the read action, and tunnel control is
+// separate from changing the system's protections.
+func TestPolkitActionFor(t *testing.T) {
+	tests := map[string]string{
+		"system.ping":          PolkitActionRead,
+		"state.get":            PolkitActionRead,
+		"killswitch.status":    PolkitActionRead,
+		"openvpn.list":         PolkitActionRead,
+		"openvpn.connect":      PolkitActionConnect,
+		"wireguard.disconnect": PolkitActionConnect,
+		"tailscale.up":         PolkitActionConnect,
+		"killswitch.enable":    PolkitActionConfigure,
+		"security.apply":       PolkitActionConfigure,
+		"tailscale.login":      PolkitActionConfigure,
+		"brand.new_method":     PolkitActionConfigure,
+	}
+	for method, want := range tests {
+		if got := polkitActionFor(method); got != want {
+			t.Errorf("polkitActionFor(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestStructToVariantMap(t *testing.T) {
+	got := structToVariantMap(IPv6ProtectionState{Enabled: true, Mode: "block", OriginalSysctl: map[string]string{"a": "1"}})
+	if v, ok := got["enabled"].Value().(bool); !ok || !v {
+		t.Errorf("enabled = %v, want true", got["enabled"])
+	}
+	if v, _ := got["mode"].Value().(string); v != "block" {
+		t.Errorf("mode = %v, want block", got["mode"])
+	}
+	if _, ok := got["OriginalSysctl"]; ok {
+		t.Error("a field hidden from JSON leaked into the D-Bus dictionary")
+	}
+
+	// A nil slice must still encode (as an empty array).
+	ks := structToVariantMap(KillSwitchState{})
+	if v, ok := ks["lan_ranges"].Value().([]string); !ok || v == nil {
+		t.Errorf("lan_ranges = %#v, want an empty []string", ks["lan_ranges"].Value())
+	}
+	// The owner is a nested dictionary, and absent when unknown.
+	if _, ok := structToVariantMap(LANGatewayState{})["owner"]; ok {
+		t.Error("a nil owner should be left out")
+	}
+	gw := structToVariantMap(LANGatewayState{Owner: &SessionOwner{UID: 1000, Session: "3"}})
+	owner, ok := gw["owner"].Value().(map[string]dbus.Variant)
+	if !ok || owner["uid"].Value() != uint32(1000) || owner["session"].Value() != "3" {
+		t.Errorf("owner = %#v, want a dictionary with uid and session", gw["owner"].Value())
+	}
+}
+
+// startTestBus runs a private dbus-daemon and returns its address.
+func startTestBus(t *testing.T) string {
+	t.Helper()
+	bin, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "bus")
+	config := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(config, []byte(`<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=`+sock+`</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow user="*"/>
+    <allow own="*"/>
+    <allow send_destination="*"/>
+    <allow receive_sender="*"/>
+  </policy>
+</busconfig>
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(bin, "--config-file="+config, "--nofork")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(sock); err == nil {
+			return "unix:path=" + sock
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dbus-daemon did not create its socket")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// fakePolkit records the actions checked and denies the listed ones.
+type fakePolkit struct {
+	mu      sync.Mutex
+	checked []string
+	deny    map[string]bool
+}
+
+func (f *fakePolkit) authorize(_ context.Context, _ string, action string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checked = append(f.checked, action)
+	return !f.deny[action], nil
+}
+
+// TestDBusServiceEndToEnd drives the service over a real (private) bus: a
+// mapped method runs the shared handler, polkit is consulted for the method's
+// action, state changes arrive as PropertiesChanged and Event signals, and a
+// denied action is refused without running the handler. System accounts are
+// refused outright, and members of the socket group skip polkit.
+func TestDBusServiceEndToEnd(t *testing.T) {
+	addr := startTestBus(t)
+	fakeLookups(t)
+
+	server := NewServer(
+		WithSocketPath(filepath.Join(t.TempDir(), "daemon.sock")),
+		WithLogger(log.New(io.Discard, "", 0)),
+		WithSocketGroup("vpn-admins"),
+	)
+	server.procRoot = filepath.Join(t.TempDir(), "proc")
+	if err := os.MkdirAll(filepath.Join(server.procRoot, "42"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setGroups := func(groups string) {
+		status := "Uid:\t1000\t1000\t1000\t1000\nGid:\t1000\t1000\t1000\t1000\nGroups:\t" + groups + "\n"
+		if err := os.WriteFile(filepath.Join(server.procRoot, "42", "status"), []byte(status), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setGroups("4 27")
+	var enableCalls atomic.Int32
+	server.Handlers().Register("killswitch.enable", func(ctx *HandlerContext) (any, error) {
+		enableCalls.Add(1)
+		ctx.State.SetKillSwitch(KillSwitchState{Enabled: true, Mode: "always"})
+		ctx.Events.Publish(protocol.TopicKillSwitch, ctx.State.GetKillSwitch())
+		return map[string]bool{"success": true}, nil
+	})
+	server.Handlers().Register("dns.enable", func(ctx *HandlerContext) (any, error) {
+		return nil, fmt.Errorf("must not run")
+	})
+
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("server Start: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	svcConn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect service: %v", err)
+	}
+	svc := NewDBusService(server)
+	svc.conn = svcConn
+	pk := &fakePolkit{deny: map[string]bool{}}
+	svc.authorize = pk.authorize
+	// Everyone on the test bus is root; pretend to be a desktop user so polkit
+	// is consulted.
+	var callerUID atomic.Uint32
+	callerUID.Store(1000)
+	svc.lookupCaller = func(string) (dbusCaller, error) { return dbusCaller{uid: callerUID.Load(), pid: 42}, nil }
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer svc.Stop()
+
+	client, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatalf("connect client: %v", err)
+	}
+	defer client.Close()
+	obj := client.Object(DBusName, DBusPath)
+
+	var pong string
+	if err := obj.Call(DBusInterface+".SystemPing", 0, "").Store(&pong); err != nil {
+		t.Fatalf("SystemPing: %v", err)
+	}
+	if pong != `"pong"` {
+		t.Errorf("SystemPing = %s, want \"pong\"", pong)
+	}
+
+	ks, err := obj.GetProperty(DBusInterface + ".KillSwitch")
+	if err != nil {
+		t.Fatalf("Get KillSwitch: %v", err)
+	}
+	if m, ok := ks.Value().(map[string]dbus.Variant); !ok || m["enabled"].Value() != false {
+		t.Fatalf("KillSwitch = %v, want enabled=false", ks)
+	}
+
+	if err := client.AddMatchSignal(dbus.WithMatchObjectPath(DBusPath)); err != nil {
+		t.Fatalf("AddMatchSignal: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	client.Signal(signals)
+
+	var res string
+	if err := obj.Call(DBusInterface+".KillswitchEnable", 0, `{"mode":"always"}`).Store(&res); err != nil {
+		t.Fatalf("KillswitchEnable: %v", err)
+	}
+	if n := enableCalls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+
+	var gotEvent, gotChange bool
+	timeout := time.After(5 * time.Second)
+	for !gotEvent || !gotChange {
+		select {
+		case sig := <-signals:
+			switch sig.Name {
+			case DBusInterface + ".Event":
+				gotEvent = sig.Body[0] == protocol.TopicKillSwitch
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				changed := sig.Body[1].(map[string]dbus.Variant)
+				if v, ok := changed["KillSwitch"]; ok {
+					m := v.Value().(map[string]dbus.Variant)
+					gotChange = m["enabled"].Value() == true
+				}
+			}
+		case <-timeout:
+			t.Fatalf("signals: event=%v propertiesChanged=%v, want both", gotEvent, gotChange)
+		}
+	}
+
+	pk.mu.Lock()
+	pk.deny[PolkitActionConfigure] = true
+	pk.mu.Unlock()
+	err = obj.Call(DBusInterface+".DnsEnable", 0, "{}").Err
+	var dbusErr dbus.Error
+	if err == nil || !strings.HasSuffix(fmt.Sprint(err), "not authorized") {
+		t.Errorf("denied call: err = %v, want not authorized", err)
+	}
+	if e, ok := err.(dbus.Error); ok {
+		dbusErr = e
+	}
+	if dbusErr.Name != dbusErrAccessDenied {
+		t.Errorf("error name = %q, want %q", dbusErr.Name, dbusErrAccessDenied)
+	}
+
+	// A system account is refused before polkit is asked.
+	callerUID.Store(999)
+	if err := obj.Call(DBusInterface+".SystemPing", 0, "").Err; err == nil {
+		t.Error("SystemPing from uid 999 succeeded, want access denied")
+	}
+	callerUID.Store(1000)
+
+	// A member of the socket group could use the socket, so polkit (which
+	// still denies configure) is not asked.
+	setGroups("4 27 2000")
+	if err := obj.Call(DBusInterface+".KillswitchEnable", 0, `{"mode":"always"}`).Err; err != nil {
+		t.Errorf("KillswitchEnable by a group member: %v", err)
+	}
+
+	pk.mu.Lock()
+	defer pk.mu.Unlock()
+	want := []string{PolkitActionRead, PolkitActionConfigure, PolkitActionConfigure}
+	if strings.Join(pk.checked, ",") != strings.Join(want, ",") {
+		t.Errorf("polkit checks = %v, want %v", pk.checked, want)
+	}
+}
@@ -0,0 +1,256 @@
+// Package daemon provides the event hub that pushes state changes to clients.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// eventQueueSize bounds the notifications buffered per subscribed connection.
+// A client that stops reading falls behind by at most this many events; after
+// that new events for it are dropped so a stuck GUI can never block a handler
+// (or the OpenVPN output reader) that publishes.
+const eventQueueSize = 64
+
+// errEventsUnavailable is returned by events.subscribe when the handler runs
+// outside a live client connection (e.g. invoked directly in tests).
+var errEventsUnavailable = errors.New("event streaming is not available on this connection")
+
+// EventHub fans out daemon events to connections that called events.subscribe.
+// It replaces status polling: instead of the GUI asking *.status every couple of
+// seconds, the daemon pushes a notification the moment something changes.
+// It is safe for concurrent use; a nil *EventHub discards everything.
+type EventHub struct {
+	mu     sync.RWMutex
+	subs   map[*eventSubscriber]struct{}
+	logger *log.Logger
+}
+
+// eventSubscriber is one connection's subscription.
+type eventSubscriber struct {
+	mu     sync.RWMutex
+	all    bool
+	topics map[string]struct{}
+
+	queue chan *protocol.Notification
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewEventHub creates an empty event hub.
+func NewEventHub(logger *log.Logger) *EventHub {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &EventHub{
+		subs:   make(map[*eventSubscriber]struct{}),
+		logger: logger,
+	}
+}
+
+// Publish sends an event to every subscriber following topic. data is marshaled
+// to JSON once and shared; a marshal failure is logged and the event dropped.
+// Publish never blocks on a slow subscriber.
+func (h *EventHub) Publish(topic string, data any) {
+	if h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.subs) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		h.logger.Printf("Warning: dropping %s event: %v", topic, err)
+		return
+	}
+
+	n, err := protocol.NewNotification(protocol.NotifyEvent, protocol.Event{
+		Topic: topic,
+		Time:  time.Now(),
+		Data:  payload,
+	})
+	if err != nil {
+		h.logger.Printf("Warning: dropping %s event: %v", topic, err)
+		return
+	}
+
+	for sub := range h.subs {
+		if !sub.wants(topic) {
+			continue
+		}
+		select {
+		case sub.queue <- n:
+		default:
+			h.logger.Printf("Warning: event queue full, dropping %s event for a subscriber", topic)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *EventHub) Subscribers() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// subscribe registers a new subscriber for the given topics (all topics when
+// empty). The caller must eventually call unsubscribe.
+func (h *EventHub) subscribe(topics []string) *eventSubscriber {
+	sub := &eventSubscriber{
+		topics: make(map[string]struct{}),
+		queue:  make(chan *protocol.Notification, eventQueueSize),
+		done:   make(chan struct{}),
+	}
+	sub.addTopics(topics)
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// unsubscribe removes a subscriber and stops its writer. Idempotent.
+func (h *EventHub) unsubscribe(sub *eventSubscriber) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// addTopics widens the subscription. An empty list means every topic, and once
+// a subscription follows every topic it stays that way: several GUI components
+// share one connection, each filtering locally for the topics it cares about.
+func (s *eventSubscriber) addTopics(topics []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(topics) == 0 {
+		s.all = true
+		return
+	}
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+}
+
+// wants reports whether the subscriber follows topic.
+func (s *eventSubscriber) wants(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.all {
+		return true
+	}
+	_, ok := s.topics[topic]
+	return ok
+}
+
+// handleSubscribe implements events.subscribe. It turns the calling connection
+// into an event stream: after the response, the daemon interleaves NotifyEvent
+// notifications with any further responses on the same socket. Calling it again
+// on the same connection widens the topic set instead of creating a second stream.
+func handleSubscribe(ctx *HandlerContext) (any, error) {
+	var params protocol.SubscribeParams
+	if err := ctx.UnmarshalParams(&params); err != nil {
+		return nil, err
+	}
+
+	client := ctx.client
+	if client == nil || ctx.Events == nil {
+		return nil, errEventsUnavailable
+	}
+
+	client.subMu.Lock()
+	defer client.subMu.Unlock()
+
+	if client.sub != nil {
+		client.sub.addTopics(params.Topics)
+	} else {
+		client.sub = ctx.Events.subscribe(params.Topics)
+		client.server.startEventWriter(client, client.sub)
+	}
+
+	return protocol.SubscribeResult{Subscribed: true, Topics: params.Topics}, nil
+}
+
+// handleUnsubscribe implements events.unsubscribe, stopping the connection's
+// event stream. It is a no-op when the connection is not subscribed.
+func handleUnsubscribe(ctx *HandlerContext) (any, error) {
+	client := ctx.client
+	if client == nil || ctx.Events == nil {
+		return protocol.SubscribeResult{}, nil
+	}
+
+	client.subMu.Lock()
+	defer client.subMu.Unlock()
+
+	if client.sub != nil {
+		ctx.Events.unsubscribe(client.sub)
+		client.sub = nil
+	}
+
+	return protocol.SubscribeResult{}, nil
+}
+
+// Notify sends an event to the caller's connection alone, bypassing the hub,
+// for handlers that stream to their caller while they run (openvpn.logs with
+// follow). Unlike Publish it blocks while the client is slow to read. It fails
+// when the request did not arrive on a socket connection (D-Bus, direct
+// invocation).
+func (ctx *HandlerContext) Notify(topic string, data any) error {
+	if ctx.client == nil {
+		return errEventsUnavailable
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	n, err := protocol.NewNotification(protocol.NotifyEvent, protocol.Event{
+		Topic: topic,
+		Time:  time.Now(),
+		Data:  payload,
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.client.codec.WriteNotification(n)
+}
+
+// startEventWriter runs the goroutine that drains a subscriber's queue onto the
+// client's socket. Codec writes are serialized with responses by the codec's
+// write lock, so the two never interleave mid-message.
+func (s *Server) startEventWriter(client *clientConn, sub *eventSubscriber) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case <-sub.done:
+				return
+			case <-s.done:
+				return
+			case n := <-sub.queue:
+				if err := client.codec.WriteNotification(n); err != nil {
+					s.logger.Printf("Event write error to uid=%d: %v", client.uid, err)
+					s.events.unsubscribe(sub)
+					return
+				}
+			}
+		}
+	}()
+}
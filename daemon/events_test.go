@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func TestEventHubPublishFiltersTopics(t *testing.T) {
+	hub := NewEventHub(nil)
+
+	dns := hub.subscribe([]string{protocol.TopicDNS})
+	all := hub.subscribe(nil)
+	defer hub.unsubscribe(dns)
+	defer hub.unsubscribe(all)
+
+	if got := hub.Subscribers(); got != 2 {
+		t.Fatalf("Subscribers() = %d, want 2", got)
+	}
+
+	hub.Publish(protocol.TopicKillSwitch, map[string]bool{"enabled": true})
+
+	if len(dns.queue) != 0 {
+		t.Errorf("dns subscriber got %d events, want 0", len(dns.queue))
+	}
+	if len(all.queue) != 1 {
+		t.Fatalf("catch-all subscriber got %d events, want 1", len(all.queue))
+	}
+
+	n := <-all.queue
+	var ev protocol.Event
+	if err := n.UnmarshalParams(&ev); err != nil {
+		t.Fatalf("UnmarshalParams failed: %v", err)
+	}
+	if ev.Topic != protocol.TopicKillSwitch {
+		t.Errorf("Topic = %q, want %q", ev.Topic, protocol.TopicKillSwitch)
+	}
+}
+
+func TestEventHubDropsWhenQueueFull(t *testing.T) {
+	hub := NewEventHub(nil)
+	sub := hub.subscribe(nil)
+	defer hub.unsubscribe(sub)
+
+	// Publish must never block, even for a subscriber that stopped reading.
+	for i := 0; i < eventQueueSize+10; i++ {
+		hub.Publish(protocol.TopicDNS, i)
+	}
+
+	if len(sub.queue) != eventQueueSize {
+		t.Errorf("queue length = %d, want %d", len(sub.queue), eventQueueSize)
+	}
+}
+
+func TestEventHubUnsubscribe(t *testing.T) {
+	hub := NewEventHub(nil)
+	sub := hub.subscribe(nil)
+
+	hub.unsubscribe(sub)
+	hub.unsubscribe(sub) // idempotent
+
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d, want 0", got)
+	}
+	select {
+	case <-sub.done:
+	default:
+		t.Error("done channel should be closed after unsubscribe")
+	}
+
+	// Nil hub discards silently
+	var nilHub *EventHub
+	nilHub.Publish(protocol.TopicDNS, nil)
+}
+
+func TestEventSubscriberAddTopics(t *testing.T) {
+	hub := NewEventHub(nil)
+	sub := hub.subscribe([]string{protocol.TopicDNS})
+	defer hub.unsubscribe(sub)
+
+	if sub.wants(protocol.TopicIPv6) {
+		t.Error("should not want ipv6 before widening")
+	}
+	sub.addTopics([]string{protocol.TopicIPv6})
+	if !sub.wants(protocol.TopicIPv6) || !sub.wants(protocol.TopicDNS) {
+		t.Error("widened subscription should want both topics")
+	}
+	sub.addTopics(nil)
+	if !sub.wants(protocol.TopicGateway) {
+		t.Error("empty topic list should subscribe to everything")
+	}
+}
+
+func TestServerEventsSubscribe(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(WithSocketPath(socketPath))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	codec := protocol.NewCodec(conn)
+
+	req, _ := protocol.NewRequest(1, "events.subscribe", protocol.SubscribeParams{
+		Topics: []string{protocol.TopicKillSwitch},
+	})
+	if err := codec.WriteRequest(req); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+
+	resp, notif, err := codec.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if notif != nil || !resp.IsSuccess() {
+		t.Fatalf("events.subscribe failed: resp=%v notif=%v", resp, notif)
+	}
+
+	server.Events().Publish(protocol.TopicDNS, map[string]bool{"enabled": true})
+	server.Events().Publish(protocol.TopicKillSwitch, map[string]bool{"enabled": true})
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, notif, err = codec.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	if resp != nil || notif == nil {
+		t.Fatalf("expected notification, got resp=%v", resp)
+	}
+
+	var ev protocol.Event
+	if err := notif.UnmarshalParams(&ev); err != nil {
+		t.Fatalf("UnmarshalParams failed: %v", err)
+	}
+	if ev.Topic != protocol.TopicKillSwitch {
+		t.Errorf("Topic = %q, want %q", ev.Topic, protocol.TopicKillSwitch)
+	}
+}
+
+// TestHandlerNotifyStream follows a handler that streams to its caller until
+// the client cancels the call.
+func TestHandlerNotifyStream(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(WithSocketPath(socketPath), WithLogger(log.New(io.Discard, "", 0)))
+	stopped := make(chan struct{})
+	server.Handlers().Register("test.follow", func(ctx *HandlerContext) (any, error) {
+		defer close(stopped)
+		for i := range 2 {
+			if err := ctx.Notify(protocol.TopicOpenVPNLog, map[string]int{"seq": i}); err != nil {
+				return nil, err
+			}
+		}
+		<-ctx.Context.Done()
+		return nil, ctx.Context.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	client := protocol.NewClient(protocol.WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+
+	streamCtx, stopStream := context.WithTimeout(ctx, 5*time.Second)
+	defer stopStream()
+	var seqs []int
+	err := client.Stream(streamCtx, "test.follow", nil, func(ev protocol.Event) {
+		var data map[string]int
+		_ = ev.UnmarshalData(&data)
+		seqs = append(seqs, data["seq"])
+		if len(seqs) == 2 {
+			stopStream()
+		}
+	}, protocol.TopicOpenVPNLog)
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if len(seqs) != 2 || seqs[0] != 0 || seqs[1] != 1 {
+		t.Errorf("streamed %v, want [0 1]", seqs)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler kept streaming after the client cancelled")
+	}
+
+	if err := (&HandlerContext{}).Notify(protocol.TopicOpenVPNLog, nil); err == nil {
+		t.Error("Notify without a connection should fail")
+	}
+}
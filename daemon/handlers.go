@@ -4,6 +4,7 @@ package daemon
 import (
 	"context"
 	"log"
+	"reflect"
 	"sync"
 
 	"github.com/yllada/vpn-manager/pkg/protocol"
@@ -33,8 +34,21 @@ type HandlerContext struct {
 	// State provides access to daemon state.
 	State *State
 
+	// Events publishes state changes to subscribed clients. May be nil
+	// (Publish on a nil hub is a no-op).
+	Events *EventHub
+
 	// Logger for handler logging.
 	Logger *log.Logger
+
+	// MethodAllowed reports whether the method policy lets the caller call
+	// method. Nil allows every method, as for the calls the daemon makes
+	// itself. Use Allows.
+	MethodAllowed func(method string) bool
+
+	// client is the connection the request arrived on. Only built-in handlers
+	// that manage per-connection resources (events.subscribe) use it.
+	client *clientConn
 }
 
 // UnmarshalParams extracts request parameters into the target struct.
@@ -42,23 +56,68 @@ func (ctx *HandlerContext) UnmarshalParams(target any) error {
 	return ctx.Request.UnmarshalParams(target)
 }
 
+// Allows reports whether the caller may call method under the method policy.
+// Handlers that run other methods on the caller's behalf (tx.commit,
+// security.apply) check each of them, so the policy cannot be bypassed by
+// wrapping a method in another.
+func (ctx *HandlerContext) Allows(method string) bool {
+	return ctx.MethodAllowed == nil || ctx.MethodAllowed(method)
+}
+
 // HandlerRegistry manages registered RPC method handlers.
 // It is safe for concurrent use.
 type HandlerRegistry struct {
 	mu       sync.RWMutex
 	handlers map[string]HandlerFunc
+	info     map[string]*methodInfo
+}
+
+// methodInfo is what Register records about a method for system.describe.
+type methodInfo struct {
+	summary string
+	params  reflect.Type
+	result  reflect.Type
+}
+
+// MethodOption annotates a method at registration. The annotations are
+// published by system.describe; they do not change how the method is called.
+type MethodOption func(*methodInfo)
+
+// Params declares the type the method's params unmarshal into. Pass a zero
+// value: Params(KillSwitchEnableParams{}).
+func Params(v any) MethodOption {
+	return func(m *methodInfo) {
+		m.params = reflect.TypeOf(v)
+	}
+}
+
+// Result declares the type of the method's result. Pass a zero value:
+// Result(KillSwitchEnableResult{}), Result([]OpenVPNStatusResult{}).
+func Result(v any) MethodOption {
+	return func(m *methodInfo) {
+		m.result = reflect.TypeOf(v)
+	}
+}
+
+// Summary gives the one-line description of the method.
+func Summary(s string) MethodOption {
+	return func(m *methodInfo) {
+		m.summary = s
+	}
 }
 
 // NewHandlerRegistry creates a new handler registry.
 func NewHandlerRegistry() *HandlerRegistry {
 	return &HandlerRegistry{
 		handlers: make(map[string]HandlerFunc),
+		info:     make(map[string]*methodInfo),
 	}
 }
 
-// Register adds a handler for the given method.
+// Register adds a handler for the given method, with optional Params, Result
+// and Summary annotations for system.describe.
 // Panics if a handler is already registered for the method.
-func (r *HandlerRegistry) Register(method string, handler HandlerFunc) {
+func (r *HandlerRegistry) Register(method string, handler HandlerFunc, opts ...MethodOption) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -67,6 +126,13 @@ func (r *HandlerRegistry) Register(method string, handler HandlerFunc) {
 	}
 
 	r.handlers[method] = handler
+	if len(opts) > 0 {
+		info := &methodInfo{}
+		for _, opt := range opts {
+			opt(info)
+		}
+		r.info[method] = info
+	}
 }
 
 // Get returns the handler for the given method.
@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the optional caller-identity layer described in authz.go.
+//
+// SECURITY (C4): the socket-group model admits any process of a group member.
+// When a caller-identity policy is configured, the daemon additionally resolves
+// the SO_PEERCRED PID to its executable via /proc/<pid>/exe and checks it
+// against an allowlist of paths (and, optionally, SHA-256 digests). Mismatches
+// are rejected or only audited depending on the method class.
+//
+// The binary is hashed through the /proc/<pid>/exe link itself, not through the
+// resolved path, so swapping the file at that path after the caller started
+// cannot make an unlisted binary hash as a listed one. A binary deleted or
+// replaced on disk since the caller started (e.g. by a package upgrade) shows as
+// "<path> (deleted)" and fails verification until the client is restarted.
+//
+// Root callers are exempt: root can already rewrite the allowlist, ptrace the
+// GUI, or program the firewall directly, so verifying it adds nothing.
+
+// DefaultIdentityPolicyPath is where the daemon looks for a caller-identity
+// policy. A missing file leaves the layer disabled.
+const DefaultIdentityPolicyPath = "/etc/vpn-manager/caller-identity.yaml"
+
+// defaultProcRoot is the procfs mount used to resolve caller executables.
+const defaultProcRoot = "/proc"
+
+// deletedSuffix is appended by the kernel to /proc/<pid>/exe targets whose file
+// has been unlinked.
+const deletedSuffix = " (deleted)"
+
+// IdentityMode controls what the daemon does when a caller's executable is not
+// on the allowlist.
+type IdentityMode string
+
+const (
+	// IdentityOff skips verification for the method class.
+	IdentityOff IdentityMode = "off"
+	// IdentityAudit logs unverified callers but lets the request through.
+	IdentityAudit IdentityMode = "audit"
+	// IdentityEnforce rejects requests from unverified callers.
+	IdentityEnforce IdentityMode = "enforce"
+)
+
+// AllowedExecutable is one allowlist entry.
+type AllowedExecutable struct {
+	// Path is the absolute path of the client binary (e.g. /usr/bin/vpn-manager).
+	Path string `yaml:"path"`
+	// SHA256 is the hex digest the binary must match. Empty accepts any content
+	// at Path, which is weaker: anyone able to write Path defeats the check.
+	SHA256 string `yaml:"sha256,omitempty"`
+}
+
+// IdentityPolicy is the caller-identity configuration, loaded from
+// DefaultIdentityPolicyPath. Example:
+//
+//	public: audit
+//	privileged: enforce
+//	allow:
+//	  - path: /usr/bin/vpn-manager
+//	    sha256: 3f0c...e1
+type IdentityPolicy struct {
+	// Public applies to read-only methods (classPublic).
+	Public IdentityMode `yaml:"public"`
+	// Privileged applies to every other method (classPrivileged).
+	Privileged IdentityMode `yaml:"privileged"`
+	// Allow lists the executables permitted to drive the daemon.
+	Allow []AllowedExecutable `yaml:"allow"`
+}
+
+// LoadIdentityPolicy reads and validates a caller-identity policy. It returns
+// (nil, nil) when the file does not exist, meaning the layer is disabled.
+func LoadIdentityPolicy(path string) (*IdentityPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read caller identity policy: %w", err)
+	}
+
+	var policy IdentityPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse caller identity policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid caller identity policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+// Validate checks the policy and fills in defaults: an unset mode is "off".
+func (p *IdentityPolicy) Validate() error {
+	for _, m := range []*IdentityMode{&p.Public, &p.Privileged} {
+		switch *m {
+		case "":
+			*m = IdentityOff
+		case IdentityOff, IdentityAudit, IdentityEnforce:
+		default:
+			return fmt.Errorf("unknown mode %q (want off, audit or enforce)", *m)
+		}
+	}
+
+	for i := range p.Allow {
+		entry := &p.Allow[i]
+		if !filepath.IsAbs(entry.Path) || filepath.Clean(entry.Path) != entry.Path {
+			return fmt.Errorf("allow[%d]: path %q must be absolute and clean", i, entry.Path)
+		}
+		entry.SHA256 = strings.ToLower(entry.SHA256)
+		if entry.SHA256 != "" {
+			if b, err := hex.DecodeString(entry.SHA256); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("allow[%d]: sha256 must be %d hex characters", i, sha256.Size*2)
+			}
+		}
+	}
+
+	if len(p.Allow) == 0 && (p.Public == IdentityEnforce || p.Privileged == IdentityEnforce) {
+		return errors.New("enforce mode with an empty allowlist would reject every non-root caller")
+	}
+
+	return nil
+}
+
+// modeFor returns the mode for a method class.
+func (p *IdentityPolicy) modeFor(class methodClass) IdentityMode {
+	if class == classPublic {
+		return p.Public
+	}
+	return p.Privileged
+}
+
+// callerIdentity is the verification outcome for one connection. It is computed
+// once when the connection is accepted, while the SO_PEERCRED PID is fresh.
+type callerIdentity struct {
+	exe string // resolved executable path, if it could be read
+	err error  // nil when the executable matched the allowlist
+}
+
+// hashKey identifies a file version for the digest cache.
+type hashKey struct {
+	dev, ino  uint64
+	size      int64
+	mtimeNsec int64
+}
+
+// identityVerifier resolves and checks caller executables.
+type identityVerifier struct {
+	policy   *IdentityPolicy
+	procRoot string
+
+	// Digest cache: GUI binaries are tens of MB and every connection would
+	// otherwise re-hash one.
+	mu     sync.Mutex
+	hashes map[hashKey]string
+}
+
+// newIdentityVerifier creates a verifier reading caller executables from procRoot.
+func newIdentityVerifier(policy *IdentityPolicy, procRoot string) *identityVerifier {
+	if procRoot == "" {
+		procRoot = defaultProcRoot
+	}
+	return &identityVerifier{
+		policy:   policy,
+		procRoot: procRoot,
+		hashes:   make(map[hashKey]string),
+	}
+}
+
+// verify resolves pid's executable and checks it against the allowlist.
+func (v *identityVerifier) verify(pid int32) callerIdentity {
+	if pid <= 0 {
+		return callerIdentity{err: fmt.Errorf("invalid peer pid %d", pid)}
+	}
+
+	link := filepath.Join(v.procRoot, strconv.Itoa(int(pid)), "exe")
+	exe, err := os.Readlink(link)
+	if err != nil {
+		return callerIdentity{err: fmt.Errorf("resolve executable: %w", err)}
+	}
+	if strings.HasSuffix(exe, deletedSuffix) {
+		return callerIdentity{exe: exe, err: errors.New("executable was deleted or replaced since the process started")}
+	}
+
+	var candidates []AllowedExecutable
+	for _, entry := range v.policy.Allow {
+		if entry.Path == exe {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return callerIdentity{exe: exe, err: errors.New("executable is not on the allowlist")}
+	}
+
+	var digest string
+	for _, entry := range candidates {
+		if entry.SHA256 == "" {
+			return callerIdentity{exe: exe}
+		}
+		if digest == "" {
+			if digest, err = v.digest(link); err != nil {
+				return callerIdentity{exe: exe, err: fmt.Errorf("hash executable: %w", err)}
+			}
+		}
+		if digest == entry.SHA256 {
+			return callerIdentity{exe: exe}
+		}
+	}
+
+	return callerIdentity{exe: exe, err: errors.New("executable digest does not match the allowlist")}
+}
+
+// digest returns the SHA-256 of the file behind link, using the cache when the
+// file's identity (device, inode, size, mtime) is unchanged.
+func (v *identityVerifier) digest(link string) (string, error) {
+	f, err := os.Open(link)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	var key hashKey
+	cacheable := false
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		key = hashKey{dev: st.Dev, ino: st.Ino, size: info.Size(), mtimeNsec: info.ModTime().UnixNano()}
+		cacheable = true
+
+		v.mu.Lock()
+		sum, hit := v.hashes[key]
+		v.mu.Unlock()
+		if hit {
+			return sum, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if cacheable {
+		v.mu.Lock()
+		v.hashes[key] = sum
+		v.mu.Unlock()
+	}
+
+	return sum, nil
+}
+
+// checkCallerIdentity applies the caller-identity policy to one request. It
+// returns false when the request must be rejected. Unverified callers are
+// logged in both audit and enforce mode, so a rollout can start in audit mode
+// and be tightened once the logs are clean.
+func (s *Server) checkCallerIdentity(client *clientConn, method string) bool {
+	if s.identity == nil || client.uid == 0 {
+		return true
+	}
+
+	mode := s.identity.policy.modeFor(classOf(method))
+	if mode == IdentityOff || client.identity.err == nil {
+		return true
+	}
+
+	if mode == IdentityEnforce {
+		s.logger.Printf("SECURITY: rejected %s from unverified caller uid=%d pid=%d exe=%q: %v",
+			method, client.uid, client.pid, client.identity.exe, client.identity.err)
+		return false
+	}
+
+	// Audit mode: always record state-changing calls, but log read-only polls
+	// only once per connection so they do not flood the journal.
+	if isPrivilegedMethod(method) || client.identityWarned.CompareAndSwap(false, true) {
+		s.logger.Printf("AUDIT: unverified caller uid=%d pid=%d exe=%q called %s: %v",
+			client.uid, client.pid, client.identity.exe, method, client.identity.err)
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeProc builds a fake procfs under a temp dir: for each pid, <root>/<pid>/exe
+// is a symlink to target. It returns the proc root.
+func fakeProc(t *testing.T, exes map[int32]string) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "proc")
+	for pid, target := range exes {
+		dir := filepath.Join(root, strconv.Itoa(int(pid)))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(dir, "exe")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// fakeBinary writes an executable file and returns its path and SHA-256.
+func fakeBinary(t *testing.T, name, content string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return path, hex.EncodeToString(sum[:])
+}
+
+func TestIdentityVerifierVerify(t *testing.T) {
+	gui, guiSum := fakeBinary(t, "vpn-manager", "legitimate gui")
+	other, _ := fakeBinary(t, "python3", "some other program")
+	pathOnly, _ := fakeBinary(t, "vpnctl", "cli")
+
+	procRoot := fakeProc(t, map[int32]string{
+		100: gui,
+		200: other,
+		300: gui + deletedSuffix,
+		400: pathOnly,
+	})
+
+	policy := &IdentityPolicy{
+		Privileged: IdentityEnforce,
+		Allow: []AllowedExecutable{
+			{Path: gui, SHA256: guiSum},
+			{Path: pathOnly},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	v := newIdentityVerifier(policy, procRoot)
+
+	tests := []struct {
+		name    string
+		pid     int32
+		wantErr bool
+	}{
+		{"allowlisted with matching digest", 100, false},
+		{"not on allowlist", 200, true},
+		{"binary replaced since start", 300, true},
+		{"path-only entry", 400, false},
+		{"no such process", 999, true},
+		{"invalid pid", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := v.verify(tt.pid)
+			if (id.err != nil) != tt.wantErr {
+				t.Errorf("verify(%d) err = %v, wantErr %v", tt.pid, id.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIdentityVerifierDigestMismatch(t *testing.T) {
+	gui, _ := fakeBinary(t, "vpn-manager", "tampered build")
+	procRoot := fakeProc(t, map[int32]string{100: gui})
+
+	policy := &IdentityPolicy{
+		Privileged: IdentityEnforce,
+		Allow:      []AllowedExecutable{{Path: gui, SHA256: strings.Repeat("ab", sha256.Size)}},
+	}
+	v := newIdentityVerifier(policy, procRoot)
+
+	id := v.verify(100)
+	if id.err == nil {
+		t.Fatal("expected digest mismatch to fail verification")
+	}
+	if id.exe != gui {
+		t.Errorf("exe = %q, want %q", id.exe, gui)
+	}
+}
+
+func TestIdentityVerifierDigestCache(t *testing.T) {
+	gui, guiSum := fakeBinary(t, "vpn-manager", "legitimate gui")
+	procRoot := fakeProc(t, map[int32]string{100: gui, 101: gui})
+
+	policy := &IdentityPolicy{Allow: []AllowedExecutable{{Path: gui, SHA256: guiSum}}}
+	v := newIdentityVerifier(policy, procRoot)
+
+	if id := v.verify(100); id.err != nil {
+		t.Fatalf("verify(100): %v", id.err)
+	}
+	if id := v.verify(101); id.err != nil {
+		t.Fatalf("verify(101): %v", id.err)
+	}
+	if len(v.hashes) != 1 {
+		t.Errorf("digest cache has %d entries, want 1 (same file)", len(v.hashes))
+	}
+}
+
+func TestLoadIdentityPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("missing file disables the layer", func(t *testing.T) {
+		policy, err := LoadIdentityPolicy(filepath.Join(dir, "absent.yaml"))
+		if err != nil || policy != nil {
+			t.Errorf("LoadIdentityPolicy = %v, %v; want nil, nil", policy, err)
+		}
+	})
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("valid policy", func(t *testing.T) {
+		path := write("valid.yaml", `
+privileged: enforce
+allow:
+  - path: /usr/bin/vpn-manager
+    sha256: `+strings.Repeat("AB", sha256.Size)+`
+`)
+		policy, err := LoadIdentityPolicy(path)
+		if err != nil {
+			t.Fatalf("LoadIdentityPolicy: %v", err)
+		}
+		if policy.Public != IdentityOff {
+			t.Errorf("Public = %q, want default %q", policy.Public, IdentityOff)
+		}
+		if policy.Privileged != IdentityEnforce {
+			t.Errorf("Privileged = %q, want %q", policy.Privileged, IdentityEnforce)
+		}
+		if policy.Allow[0].SHA256 != strings.Repeat("ab", sha256.Size) {
+			t.Errorf("digest not normalized to lower case: %q", policy.Allow[0].SHA256)
+		}
+	})
+
+	invalid := map[string]string{
+		"unknown mode":    "public: strict\n",
+		"relative path":   "allow:\n  - path: bin/vpn-manager\n",
+		"short digest":    "allow:\n  - path: /usr/bin/vpn-manager\n    sha256: abcd\n",
+		"enforce, no one": "privileged: enforce\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			path := write(strings.ReplaceAll(name, " ", "_")+".yaml", content)
+			if _, err := LoadIdentityPolicy(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCheckCallerIdentity(t *testing.T) {
+	var logBuf bytes.Buffer
+	policy := &IdentityPolicy{
+		Public:     IdentityAudit,
+		Privileged: IdentityEnforce,
+		Allow:      []AllowedExecutable{{Path: "/usr/bin/vpn-manager"}},
+	}
+	s := NewServer(WithLogger(log.New(&logBuf, "", 0)), WithCallerIdentity(policy))
+
+	verified := &clientConn{uid: 1000, pid: 10}
+	unverified := &clientConn{uid: 1000, pid: 11, identity: callerIdentity{exe: "/usr/bin/python3", err: io.EOF}}
+	root := &clientConn{uid: 0, pid: 1, identity: callerIdentity{err: io.EOF}}
+
+	if !s.checkCallerIdentity(verified, "killswitch.enable") {
+		t.Error("verified caller should be allowed")
+	}
+	if s.checkCallerIdentity(unverified, "killswitch.enable") {
+		t.Error("unverified caller should be rejected for an enforced (privileged) method")
+	}
+	if !s.checkCallerIdentity(unverified, "state.get") {
+		t.Error("unverified caller should be allowed for an audited (public) method")
+	}
+	if !s.checkCallerIdentity(root, "killswitch.enable") {
+		t.Error("root should be exempt")
+	}
+
+	if !strings.Contains(logBuf.String(), "AUDIT: unverified caller") {
+		t.Errorf("audit mode should log the mismatch, log:\n%s", logBuf.String())
+	}
+
+	// Without a policy, everything passes.
+	plain := NewServer()
+	if !plain.checkCallerIdentity(unverified, "killswitch.enable") {
+		t.Error("no policy should mean no identity check")
+	}
+}
@@ -0,0 +1,248 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// This file tracks who a tunnel or gateway belongs to. The daemon is shared by
+// every user of the machine, so a connection left behind when its user logs
+// out keeps carrying the traffic of whoever logs in next. Handlers record a
+// SessionOwner — the caller's UID and logind session — with what they set up,
+// and WatchLogind reports the logind changes after which that owner is gone
+// or no longer in front of the machine. What to do about it is the session
+// policy's business (daemon/privileged/sessions.go).
+
+// logind's D-Bus names.
+const (
+	logindName             = "org.freedesktop.login1"
+	logindManagerInterface = logindName + ".Manager"
+	logindSeatInterface    = logindName + ".Seat"
+)
+
+// Seams for tests: where callers' cgroups and logind's session records are read.
+var (
+	logindProcRoot    = defaultProcRoot
+	logindSessionsDir = "/run/systemd/sessions"
+)
+
+// SessionOwner identifies who set something up.
+type SessionOwner struct {
+	UID uint32 `json:"uid"`
+	// Session is the caller's logind session ID. Empty when the caller runs
+	// outside a session, e.g. the session agent under the user's service
+	// manager or a system service.
+	Session string `json:"session,omitempty"`
+	// Seat is the session's seat, empty for remote and seatless sessions.
+	Seat string `json:"seat,omitempty"`
+}
+
+// Owner returns the SessionOwner of the request's caller, or nil for calls the
+// daemon makes itself (reconcile loops, the watchdog), which have no PID.
+func (ctx *HandlerContext) Owner() *SessionOwner {
+	if ctx.PID == 0 {
+		return nil
+	}
+	return LookupSessionOwner(ctx.UID, ctx.PID)
+}
+
+// LookupSessionOwner resolves pid's logind session the way sd_pid_get_session
+// does, from the session scope in its cgroup path. A process outside any
+// session gets an owner with only the UID.
+func LookupSessionOwner(uid uint32, pid int32) *SessionOwner {
+	owner := &SessionOwner{UID: uid}
+	data, err := os.ReadFile(filepath.Join(logindProcRoot, strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return owner
+	}
+	owner.Session = sessionFromCgroup(string(data))
+	if owner.Session != "" {
+		owner.Seat = readSessionRecord(owner.Session)["SEAT"]
+	}
+	return owner
+}
+
+// sessionFromCgroup finds the session-<id>.scope unit in /proc/<pid>/cgroup,
+// which lists one "hierarchy:controllers:path" line per hierarchy (a single
+// "0::path" line on cgroup v2).
+func sessionFromCgroup(cgroups string) string {
+	for line := range strings.SplitSeq(cgroups, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for unit := range strings.SplitSeq(parts[2], "/") {
+			if id, ok := strings.CutPrefix(unit, "session-"); ok {
+				if id, ok := strings.CutSuffix(id, ".scope"); ok && id != "" {
+					return id
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// readSessionRecord reads logind's KEY=VALUE record of a session. A missing
+// record (logind not running, the session already gone) yields an empty map.
+func readSessionRecord(id string) map[string]string {
+	record := make(map[string]string)
+	if id == "" || strings.ContainsAny(id, "/.") {
+		return record
+	}
+	f, err := os.Open(filepath.Join(logindSessionsDir, id))
+	if err != nil {
+		return record
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			record[key] = value
+		}
+	}
+	return record
+}
+
+// SessionEventKind is the kind of logind change a SessionEvent reports.
+type SessionEventKind string
+
+const (
+	// SessionRemoved: a session ended, e.g. its user logged out.
+	SessionRemoved SessionEventKind = "session_removed"
+
+	// UserRemoved: a user's last session ended and their service manager
+	// stopped, taking the session agent with it.
+	UserRemoved SessionEventKind = "user_removed"
+
+	// SeatSwitched: another user's session became the active one on a seat.
+	SeatSwitched SessionEventKind = "seat_switched"
+)
+
+// SessionEvent is a logind change that may leave owners behind.
+type SessionEvent struct {
+	Kind SessionEventKind
+	// Session is the removed session, or the newly active one for SeatSwitched.
+	Session string
+	// UID is the removed user, or the newly active session's user for
+	// SeatSwitched.
+	UID  uint32
+	Seat string
+}
+
+// String describes the event for logs.
+func (e SessionEvent) String() string {
+	switch e.Kind {
+	case SessionRemoved:
+		return fmt.Sprintf("session %s ended", e.Session)
+	case UserRemoved:
+		return fmt.Sprintf("uid %d logged out", e.UID)
+	case SeatSwitched:
+		return fmt.Sprintf("%s switched to session %s of uid %d", e.Seat, e.Session, e.UID)
+	}
+	return string(e.Kind)
+}
+
+// WatchLogind calls handle for every SessionEvent until ctx is cancelled. It
+// returns an error, without starting, when the system bus or logind is not
+// available. handle runs on the watcher's goroutine, one event at a time.
+func WatchLogind(ctx context.Context, handle func(SessionEvent)) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("connect to system bus: %w", err)
+	}
+	matches := [][]dbus.MatchOption{
+		{dbus.WithMatchSender(logindName), dbus.WithMatchInterface(logindManagerInterface), dbus.WithMatchMember("SessionRemoved")},
+		{dbus.WithMatchSender(logindName), dbus.WithMatchInterface(logindManagerInterface), dbus.WithMatchMember("UserRemoved")},
+		{dbus.WithMatchSender(logindName), dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"), dbus.WithMatchArg(0, logindSeatInterface)},
+	}
+	for _, match := range matches {
+		if err := conn.AddMatchSignal(match...); err != nil {
+			_ = conn.Close()
+			return fmt.Errorf("subscribe to logind: %w", err)
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if event, ok := sessionEventOf(sig); ok {
+					handle(event)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// sessionEventOf translates a logind signal. Seat switches to sessions that
+// are not a user's desktop (the greeter on the way to another user, a lock
+// screen) are not reported: the previous user may still come back.
+func sessionEventOf(sig *dbus.Signal) (SessionEvent, bool) {
+	switch sig.Name {
+	case logindManagerInterface + ".SessionRemoved":
+		// SessionRemoved(s session_id, o object_path)
+		if len(sig.Body) >= 1 {
+			if id, ok := sig.Body[0].(string); ok {
+				return SessionEvent{Kind: SessionRemoved, Session: id}, true
+			}
+		}
+
+	case logindManagerInterface + ".UserRemoved":
+		// UserRemoved(u uid, o object_path)
+		if len(sig.Body) >= 1 {
+			if uid, ok := sig.Body[0].(uint32); ok {
+				return SessionEvent{Kind: UserRemoved, UID: uid}, true
+			}
+		}
+
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		// PropertiesChanged(s interface, a{sv} changed, as invalidated) on
+		// /org/freedesktop/login1/seat/<seat>; ActiveSession is (so).
+		if len(sig.Body) < 2 || sig.Body[0] != logindSeatInterface {
+			break
+		}
+		changed, ok := sig.Body[1].(map[string]dbus.Variant)
+		if !ok {
+			break
+		}
+		active, ok := changed["ActiveSession"].Value().([]any)
+		if !ok || len(active) == 0 {
+			break
+		}
+		id, _ := active[0].(string)
+		record := readSessionRecord(id)
+		if id == "" || record["CLASS"] != "user" {
+			break
+		}
+		uid, err := strconv.ParseUint(record["UID"], 10, 32)
+		if err != nil {
+			break
+		}
+		return SessionEvent{
+			Kind:    SeatSwitched,
+			Session: id,
+			UID:     uint32(uid),
+			Seat:    path.Base(string(sig.Path)),
+		}, true
+	}
+	return SessionEvent{}, false
+}
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeLogind points the session lookups at a scratch /proc and
+// /run/systemd/sessions.
+func fakeLogind(t *testing.T) (procRoot, sessionsDir string) {
+	t.Helper()
+	procRoot, sessionsDir = t.TempDir(), t.TempDir()
+	origProc, origSessions := logindProcRoot, logindSessionsDir
+	logindProcRoot, logindSessionsDir = procRoot, sessionsDir
+	t.Cleanup(func() { logindProcRoot, logindSessionsDir = origProc, origSessions })
+	return procRoot, sessionsDir
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionFromCgroup(t *testing.T) {
+	tests := map[string]string{
+		"cgroup v2":       "0::/user.slice/user-1000.slice/session-3.scope\n",
+		"cgroup v1":       "12:cpuset:/\n1:name=systemd:/user.slice/user-1000.slice/session-c2.scope\n",
+		"user service":    "0::/user.slice/user-1000.slice/user@1000.service/app.slice/vpn-manager-agent.service\n",
+		"system service":  "0::/system.slice/cron.service\n",
+		"unreadable line": "garbage",
+	}
+	want := map[string]string{"cgroup v2": "3", "cgroup v1": "c2"}
+	for name, cgroups := range tests {
+		if got := sessionFromCgroup(cgroups); got != want[name] {
+			t.Errorf("%s: sessionFromCgroup() = %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+func TestLookupSessionOwner(t *testing.T) {
+	procRoot, sessionsDir := fakeLogind(t)
+	writeFile(t, filepath.Join(procRoot, "42", "cgroup"), "0::/user.slice/user-1000.slice/session-3.scope\n")
+	writeFile(t, filepath.Join(procRoot, "43", "cgroup"), "0::/user.slice/user-1000.slice/user@1000.service/app.slice/agent.service\n")
+	writeFile(t, filepath.Join(sessionsDir, "3"), "# This is private data. Do not parse.\nUID=1000\nSEAT=seat0\nCLASS=user\n")
+
+	if got := LookupSessionOwner(1000, 42); *got != (SessionOwner{UID: 1000, Session: "3", Seat: "seat0"}) {
+		t.Errorf("desktop caller = %+v", *got)
+	}
+	if got := LookupSessionOwner(1000, 43); *got != (SessionOwner{UID: 1000}) {
+		t.Errorf("agent caller = %+v", *got)
+	}
+	if got := LookupSessionOwner(1000, 44); *got != (SessionOwner{UID: 1000}) {
+		t.Errorf("vanished caller = %+v", *got)
+	}
+	if got := (&HandlerContext{UID: 1000}).Owner(); got != nil {
+		t.Errorf("a call without a PID has owner %+v, want nil", *got)
+	}
+}
+
+func TestSessionEventOf(t *testing.T) {
+	_, sessionsDir := fakeLogind(t)
+	writeFile(t, filepath.Join(sessionsDir, "5"), "UID=1001\nSEAT=seat0\nCLASS=user\n")
+	writeFile(t, filepath.Join(sessionsDir, "c1"), "UID=120\nSEAT=seat0\nCLASS=greeter\n")
+
+	seatChanged := func(session string) *dbus.Signal {
+		return &dbus.Signal{
+			Path: "/org/freedesktop/login1/seat/seat0",
+			Name: "org.freedesktop.DBus.Properties.PropertiesChanged",
+			Body: []any{
+				logindSeatInterface,
+				map[string]dbus.Variant{"ActiveSession": dbus.MakeVariant([]any{session, dbus.ObjectPath("/org/freedesktop/login1/session/_" + session)})},
+				[]string{},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		signal *dbus.Signal
+		want   SessionEvent
+		ok     bool
+	}{
+		{
+			name:   "session removed",
+			signal: &dbus.Signal{Name: logindManagerInterface + ".SessionRemoved", Body: []any{"3", dbus.ObjectPath("/org/freedesktop/login1/session/_33")}},
+			want:   SessionEvent{Kind: SessionRemoved, Session: "3"},
+			ok:     true,
+		},
+		{
+			name:   "user removed",
+			signal: &dbus.Signal{Name: logindManagerInterface + ".UserRemoved", Body: []any{uint32(1000), dbus.ObjectPath("/org/freedesktop/login1/user/_1000")}},
+			want:   SessionEvent{Kind: UserRemoved, UID: 1000},
+			ok:     true,
+		},
+		{
+			name:   "seat switched to a user",
+			signal: seatChanged("5"),
+			want:   SessionEvent{Kind: SeatSwitched, Session: "5", UID: 1001, Seat: "seat0"},
+			ok:     true,
+		},
+		{name: "seat switched to the greeter", signal: seatChanged("c1")},
+		{name: "seat switched to an unknown session", signal: seatChanged("9")},
+		{name: "session added", signal: &dbus.Signal{Name: logindManagerInterface + ".SessionNew", Body: []any{"7"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sessionEventOf(tt.signal)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("sessionEventOf() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
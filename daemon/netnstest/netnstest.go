@@ -0,0 +1,281 @@
+// Package netnstest runs the privileged firewall and routing code against real
+// packets. Run builds three network namespaces on the local machine:
+//
+//	         lan0 192.168.77.2 ── eth0 192.168.77.1            (LAN)
+//	Client   wan0 198.51.100.2 ── eth0 198.51.100.1            (Server)
+//	         wg0  10.66.0.2    ══ wg0  10.66.0.1  (WireGuard over wan0)
+//
+// The Server namespace is both the VPN server and "the internet": it also
+// holds InternetAddr, reached from the client over its default route through
+// wan0. Every address in LAN and Server answers TCP on ServicePort and UDP and
+// TCP on port 53 with the source address it saw, so a test can tell not only
+// whether a packet got through but which way it went.
+//
+// The test body runs in a copy of the test binary started inside the Client
+// namespace, so the code under test (iptables, nft, ip, sysctl writes under
+// /proc/sys/net) changes that namespace and never the host's.
+//
+// The suite is opt-in: it needs root, iproute2, wireguard-tools and kernel
+// WireGuard support, and runs only when VPN_MANAGER_NETNS_TESTS=1 is set:
+//
+//	sudo VPN_MANAGER_NETNS_TESTS=1 go test -run Netns ./daemon/privileged/...
+package netnstest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// EnableEnv must be set to 1 for Run to build namespaces; otherwise it skips.
+const EnableEnv = "VPN_MANAGER_NETNS_TESTS"
+
+// Addresses of the topology.
+const (
+	ClientLANAddr  = "192.168.77.2"
+	LANPeerAddr    = "192.168.77.1"
+	LANNetwork     = "192.168.77.0/24"
+	ClientWANAddr  = "198.51.100.2"
+	ServerAddr     = "198.51.100.1" // the WireGuard endpoint and the client's default gateway
+	InternetAddr   = "203.0.113.53" // a host (and resolver) outside the tunnel
+	TunnelAddr     = "10.66.0.2"
+	TunnelPeerAddr = "10.66.0.1" // the server end of the tunnel, also a resolver
+
+	ServicePort   = 8080
+	WireGuardPort = 51820
+)
+
+// topologyEnv carries the Topology to the test binary running inside Client.
+const topologyEnv = "VPN_MANAGER_NETNS_TOPOLOGY"
+
+// Topology names the namespaces and client interfaces of one Run.
+type Topology struct {
+	Client string `json:"client"`
+	LAN    string `json:"lan"`
+	Server string `json:"server"`
+
+	// LANInterface and WANInterface are the client's veths.
+	LANInterface string `json:"lan_interface"`
+	WANInterface string `json:"wan_interface"`
+
+	// Tunnel is the client's WireGuard interface.
+	Tunnel string `json:"tunnel"`
+
+	// CgroupV2 is set when the host uses the unified cgroup hierarchy. It is
+	// then mounted at /sys/fs/cgroup for the test body, as on the host.
+	CgroupV2 bool `json:"cgroup_v2"`
+}
+
+// Run builds the topology with the client's WireGuard interface named tunnel,
+// then runs body inside the Client namespace. Call it once per test or
+// subtest: the body runs in a child process that repeats the test by name.
+func Run(t *testing.T, tunnel string, body func(t *testing.T, n *Topology)) {
+	t.Helper()
+
+	if spec, ok := os.LookupEnv(topologyEnv); ok {
+		var n Topology
+		if err := json.Unmarshal([]byte(spec), &n); err != nil {
+			t.Fatalf("netnstest: bad topology: %v", err)
+		}
+		if n.CgroupV2 {
+			// ip netns exec mounted a fresh /sys without the cgroup
+			// hierarchy; put it back for code that moves processes.
+			if err := syscall.Mount("cgroup2", "/sys/fs/cgroup", "cgroup2", 0, ""); err != nil {
+				t.Fatalf("netnstest: mount cgroup2: %v", err)
+			}
+		}
+		body(t, &n)
+		return
+	}
+
+	if os.Getenv(EnableEnv) != "1" {
+		t.Skipf("network namespace test; set %s=1 and run as root", EnableEnv)
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("network namespace tests need root")
+	}
+	for _, tool := range []string{"ip", "wg"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+
+	n := setUp(t, tunnel)
+	spec, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"netns", "exec", n.Client, helperPath,
+		"-test.run", runPattern(t.Name()), "-test.count=1"}
+	if testing.Verbose() {
+		args = append(args, "-test.v")
+	}
+	cmd := exec.Command("ip", args...)
+	cmd.Env = append(os.Environ(), topologyEnv+"="+string(spec))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("in namespace %s: %v\n%s", n.Client, err, out)
+	}
+	if testing.Verbose() {
+		t.Logf("in namespace %s:\n%s", n.Client, out)
+	}
+}
+
+// runPattern is the -test.run pattern that selects exactly the test name.
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(p) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// setUp creates the namespaces, links and servers; t.Cleanup removes them.
+func setUp(t *testing.T, tunnel string) *Topology {
+	t.Helper()
+	prefix := fmt.Sprintf("vmt%d", os.Getpid())
+	n := &Topology{
+		Client:       prefix + "-client",
+		LAN:          prefix + "-lan",
+		Server:       prefix + "-server",
+		LANInterface: "lan0",
+		WANInterface: "wan0",
+		Tunnel:       tunnel,
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		n.CgroupV2 = true
+	}
+
+	for _, ns := range []string{n.Client, n.LAN, n.Server} {
+		mustRun(t, "ip", "netns", "add", ns)
+		t.Cleanup(func() { _ = exec.Command("ip", "netns", "del", ns).Run() })
+		mustRun(t, "ip", "-n", ns, "link", "set", "lo", "up")
+	}
+
+	// LAN
+	mustRun(t, "ip", "link", "add", n.LANInterface, "netns", n.Client, "type", "veth", "peer", "name", "eth0", "netns", n.LAN)
+	mustRun(t, "ip", "-n", n.Client, "addr", "add", ClientLANAddr+"/24", "dev", n.LANInterface)
+	mustRun(t, "ip", "-n", n.Client, "link", "set", n.LANInterface, "up")
+	mustRun(t, "ip", "-n", n.LAN, "addr", "add", LANPeerAddr+"/24", "dev", "eth0")
+	mustRun(t, "ip", "-n", n.LAN, "link", "set", "eth0", "up")
+	mustRun(t, "ip", "-n", n.LAN, "route", "add", "default", "via", ClientLANAddr)
+
+	// WAN
+	mustRun(t, "ip", "link", "add", n.WANInterface, "netns", n.Client, "type", "veth", "peer", "name", "eth0", "netns", n.Server)
+	mustRun(t, "ip", "-n", n.Client, "addr", "add", ClientWANAddr+"/24", "dev", n.WANInterface)
+	mustRun(t, "ip", "-n", n.Client, "link", "set", n.WANInterface, "up")
+	mustRun(t, "ip", "-n", n.Client, "route", "add", "default", "via", ServerAddr)
+	mustRun(t, "ip", "-n", n.Server, "addr", "add", ServerAddr+"/24", "dev", "eth0")
+	mustRun(t, "ip", "-n", n.Server, "link", "set", "eth0", "up")
+	mustRun(t, "ip", "-n", n.Server, "addr", "add", InternetAddr+"/32", "dev", "lo")
+
+	// Tunnel
+	if err := exec.Command("ip", "-n", n.Client, "link", "add", tunnel, "type", "wireguard").Run(); err != nil {
+		t.Skipf("kernel WireGuard not available: %v", err)
+	}
+	keys := t.TempDir()
+	clientKey, clientPub := genKey(t, filepath.Join(keys, "client"))
+	serverKey, serverPub := genKey(t, filepath.Join(keys, "server"))
+	mustRun(t, "ip", "netns", "exec", n.Client, "wg", "set", tunnel,
+		"private-key", clientKey,
+		"peer", serverPub, "endpoint", fmt.Sprintf("%s:%d", ServerAddr, WireGuardPort), "allowed-ips", "0.0.0.0/0")
+	mustRun(t, "ip", "-n", n.Client, "addr", "add", TunnelAddr+"/24", "dev", tunnel)
+	mustRun(t, "ip", "-n", n.Client, "link", "set", tunnel, "up")
+	mustRun(t, "ip", "-n", n.Server, "link", "add", "wg0", "type", "wireguard")
+	mustRun(t, "ip", "netns", "exec", n.Server, "wg", "set", "wg0",
+		"listen-port", fmt.Sprint(WireGuardPort), "private-key", serverKey,
+		"peer", clientPub, "allowed-ips", TunnelAddr+"/32")
+	mustRun(t, "ip", "-n", n.Server, "addr", "add", TunnelPeerAddr+"/24", "dev", "wg0")
+	mustRun(t, "ip", "-n", n.Server, "link", "set", "wg0", "up")
+
+	startServer(t, n.LAN, LANPeerAddr)
+	startServer(t, n.Server, ServerAddr, InternetAddr, TunnelPeerAddr)
+
+	if from, err := n.Probe(n.Client, "tcp", Service(TunnelPeerAddr)); err != nil || from != TunnelAddr {
+		t.Fatalf("netnstest: tunnel not up: %q, %v", from, err)
+	}
+	return n
+}
+
+// genKey writes a WireGuard private key to path and returns the path and the
+// public key.
+func genKey(t *testing.T, path string) (string, string) {
+	t.Helper()
+	key, err := exec.Command("wg", "genkey").Output()
+	if err != nil {
+		t.Fatalf("wg genkey: %v", err)
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("wg", "pubkey")
+	cmd.Stdin = strings.NewReader(string(key))
+	pub, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("wg pubkey: %v", err)
+	}
+	return path, strings.TrimSpace(string(pub))
+}
+
+// startServer runs the answering server for addrs in namespace ns until the
+// test ends.
+func startServer(t *testing.T, ns string, addrs ...string) {
+	t.Helper()
+	cmd := exec.Command("ip", "netns", "exec", ns, helperPath)
+	cmd.Env = append(os.Environ(), serveEnv+"="+strings.Join(addrs, ","))
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start server in %s: %v", ns, err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	ready := make(chan bool, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		ready <- line == "ready\n"
+	}()
+	select {
+	case ok := <-ready:
+		if !ok {
+			t.Fatalf("server in %s failed to start", ns)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("server in %s did not start", ns)
+	}
+}
+
+func mustRun(t *testing.T, name string, args ...string) {
+	t.Helper()
+	if out, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		t.Fatalf("%s %s: %v\n%s", name, strings.Join(args, " "), err, out)
+	}
+}
+
+// Exec runs a command in namespace ns and returns its combined output, for
+// setup the code under test expects from elsewhere (a route another daemon
+// adds) and for inspecting the rules it left behind.
+func (n *Topology) Exec(t testing.TB, ns string, name string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("ip", append([]string{"netns", "exec", ns, name}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s in %s: %v\n%s", name, strings.Join(args, " "), ns, err, out)
+	}
+	return string(out)
+}
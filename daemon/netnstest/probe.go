@@ -0,0 +1,193 @@
+package netnstest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The servers and probes are this test binary started again in the right
+// namespace: init notices the helper variable, does the one job and exits
+// before any test runs.
+const (
+	serveEnv  = "VPN_MANAGER_NETNS_SERVE"
+	probeEnv  = "VPN_MANAGER_NETNS_PROBE"
+	cgroupEnv = "VPN_MANAGER_NETNS_CGROUP"
+)
+
+// probeTimeout bounds one probe; a dropped packet costs this much.
+const probeTimeout = 2 * time.Second
+
+func init() {
+	if addrs, ok := os.LookupEnv(serveEnv); ok {
+		os.Exit(serve(strings.Split(addrs, ",")))
+	}
+	if spec, ok := os.LookupEnv(probeEnv); ok {
+		os.Exit(probe(spec, os.Getenv(cgroupEnv)))
+	}
+}
+
+// helperPath is the test binary.
+var helperPath = func() string {
+	if path, err := os.Executable(); err == nil {
+		return path
+	}
+	return os.Args[0]
+}()
+
+// Service is the address of host's TCP service.
+func Service(host string) string {
+	return net.JoinHostPort(host, strconv.Itoa(ServicePort))
+}
+
+// DNS is the address of host's DNS port.
+func DNS(host string) string {
+	return net.JoinHostPort(host, "53")
+}
+
+// Probe connects from namespace ns to addr over network ("tcp" or "udp") and
+// returns the source address the far end saw. An error means no answer came
+// back within a couple of seconds.
+func (n *Topology) Probe(ns, network, addr string) (string, error) {
+	return runProbe(exec.Command("ip", "netns", "exec", ns, helperPath), network, addr, "")
+}
+
+// ProbeInCgroup is Probe from a client process that first moves itself into
+// the cgroup v2 directory cgroup, as a split-tunnelled application would be.
+// It must be called from the test body, which is already in the Client
+// namespace and has the cgroup hierarchy mounted.
+func (n *Topology) ProbeInCgroup(cgroup, network, addr string) (string, error) {
+	return runProbe(exec.Command(helperPath), network, addr, cgroup)
+}
+
+func runProbe(cmd *exec.Cmd, network, addr, cgroup string) (string, error) {
+	cmd.Env = append(os.Environ(), probeEnv+"="+network+" "+addr, cgroupEnv+"="+cgroup)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %s", network, addr, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ExpectReach fails the test unless addr answers a probe from ns, seen as
+// coming from the address from.
+func (n *Topology) ExpectReach(t testing.TB, ns, network, addr, from string) {
+	t.Helper()
+	got, err := n.Probe(ns, network, addr)
+	if err != nil {
+		t.Errorf("%s %s from %s should get through: %v", network, addr, ns, err)
+		return
+	}
+	if got != from {
+		t.Errorf("%s %s from %s arrived from %s, want %s", network, addr, ns, got, from)
+	}
+}
+
+// ExpectBlocked fails the test if addr answers a probe from ns.
+func (n *Topology) ExpectBlocked(t testing.TB, ns, network, addr string) {
+	t.Helper()
+	if got, err := n.Probe(ns, network, addr); err == nil {
+		t.Errorf("%s %s from %s should be blocked, but got through (from %s)", network, addr, ns, got)
+	}
+}
+
+// probe is the helper side of Probe: it prints the source address the server
+// reports.
+func probe(spec, cgroup string) int {
+	network, addr, _ := strings.Cut(spec, " ")
+	if cgroup != "" {
+		procs := filepath.Join(cgroup, "cgroup.procs")
+		if err := os.WriteFile(procs, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "join cgroup: %v\n", err)
+			return 1
+		}
+	}
+
+	conn, err := net.DialTimeout(network, addr, probeTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	var reply []byte
+	if network == "udp" {
+		if _, err = conn.Write([]byte("probe")); err == nil {
+			buf := make([]byte, 64)
+			var n int
+			n, err = conn.Read(buf)
+			reply = buf[:n]
+		}
+	} else {
+		reply, err = io.ReadAll(conn)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(reply))
+	return 0
+}
+
+// serve is the helper side of startServer: every addr answers TCP on
+// ServicePort and port 53, and UDP on port 53, with the peer's address. It
+// prints "ready" once listening and runs until killed.
+func serve(addrs []string) int {
+	for _, addr := range addrs {
+		for _, port := range []string{strconv.Itoa(ServicePort), "53"} {
+			ln, err := net.Listen("tcp", net.JoinHostPort(addr, port))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			go acceptLoop(ln)
+		}
+		pc, err := net.ListenPacket("udp", DNS(addr))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		go echoLoop(pc)
+	}
+	fmt.Println("ready")
+
+	// Run until killed; the parent also sets a death signal.
+	for {
+		syscall.Pause()
+	}
+}
+
+func acceptLoop(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		_, _ = conn.Write([]byte(host))
+		_ = conn.Close()
+	}
+}
+
+func echoLoop(pc net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		_, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		host, _, _ := net.SplitHostPort(from.String())
+		_, _ = pc.WriteTo([]byte(host), from)
+	}
+}
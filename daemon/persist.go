@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/atomicfile"
+	"github.com/yllada/vpn-manager/internal/paths"
+)
+
+// DefaultStatePath is where the daemon persists its State between restarts.
+const DefaultStatePath = paths.StateDir + "/daemon.state"
+
+// stateFileVersion is bumped when persistedState changes incompatibly. A file
+// with another version is ignored rather than half-applied.
+const stateFileVersion = 1
+
+// persistedState is the on-disk form of State. It differs from StateSnapshot in
+// carrying the connection maps and the internal IPv6 sysctl backup, which are
+// exactly what a restarted daemon needs to re-adopt or tear down what the
+// previous instance set up.
+type persistedState struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"saved_at"`
+
+	KillSwitch         KillSwitchState     `json:"kill_switch"`
+	DNSProtection      DNSProtectionState  `json:"dns_protection"`
+	IPv6Protection     IPv6ProtectionState `json:"ipv6_protection"`
+	IPv6OriginalSysctl map[string]string   `json:"ipv6_original_sysctl,omitempty"`
+	SplitTunnel        SplitTunnelState    `json:"split_tunnel"`
+	LANGateway         LANGatewayState     `json:"lan_gateway"`
+	Tailscale          TailscaleState      `json:"tailscale"`
+	SecurityPosture    json.RawMessage     `json:"security_posture,omitempty"`
+
+	OpenVPN   map[string]VPNConnectionState `json:"openvpn,omitempty"`
+	WireGuard map[string]VPNConnectionState `json:"wireguard,omitempty"`
+}
+
+// LoadState creates a State backed by the file at path. If the file holds state
+// saved by a previous daemon instance it is restored, and Restored reports true
+// so the caller can reconcile it against the live system. A missing file yields
+// a fresh State; an unreadable one is logged and ignored (the daemon must still
+// start). Every later mutation is written back to path atomically.
+func LoadState(path string) *State {
+	s := NewState()
+	s.persistPath = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[state] warning: cannot read %s: %v", path, err)
+		}
+		return s
+	}
+
+	var ps persistedState
+	if err := json.Unmarshal(data, &ps); err != nil {
+		log.Printf("[state] warning: ignoring unreadable state %s: %v", path, err)
+		return s
+	}
+	if ps.Version != stateFileVersion {
+		log.Printf("[state] warning: ignoring state %s with version %d (want %d)", path, ps.Version, stateFileVersion)
+		return s
+	}
+
+	s.mu.Lock()
+	s.killSwitch = ps.KillSwitch
+	s.dnsProtection = ps.DNSProtection
+	s.ipv6Protection = ps.IPv6Protection
+	s.ipv6Protection.OriginalSysctl = ps.IPv6OriginalSysctl
+	s.splitTunnel = ps.SplitTunnel
+	s.lanGateway = ps.LANGateway
+	s.tailscale = ps.Tailscale
+	s.securityPosture = ps.SecurityPosture
+	for id, c := range ps.OpenVPN {
+		s.openvpnConnections[id] = c
+	}
+	for name, c := range ps.WireGuard {
+		s.wireguardConnections[name] = c
+	}
+	s.restored = true
+	s.mu.Unlock()
+
+	log.Printf("[state] restored daemon state saved at %s (%d OpenVPN, %d WireGuard connections)",
+		ps.SavedAt.Format(time.RFC3339), len(ps.OpenVPN), len(ps.WireGuard))
+	return s
+}
+
+// Restored reports whether the State was loaded from a previous instance's
+// file. When false there is no record of what the previous instance owned, so
+// reconciliation must not tear anything down.
+func (s *State) Restored() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.restored
+}
+
+// Save writes the state to its backing file now. It is a no-op for a State
+// created with NewState.
+func (s *State) Save() error {
+	if s.persistPath == "" {
+		return nil
+	}
+
+	// persistMu serializes writers and is taken before the snapshot, so the
+	// last write always carries the newest state.
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	s.mu.RLock()
+	ps := persistedState{
+		Version:            stateFileVersion,
+		SavedAt:            time.Now(),
+		KillSwitch:         s.killSwitch,
+		DNSProtection:      s.dnsProtection,
+		IPv6Protection:     s.ipv6Protection,
+		IPv6OriginalSysctl: s.ipv6Protection.OriginalSysctl,
+		SplitTunnel:        s.splitTunnel,
+		LANGateway:         s.lanGateway,
+		Tailscale:          s.tailscale,
+		SecurityPosture:    s.securityPosture,
+		OpenVPN:            make(map[string]VPNConnectionState, len(s.openvpnConnections)),
+		WireGuard:          make(map[string]VPNConnectionState, len(s.wireguardConnections)),
+	}
+	for id, c := range s.openvpnConnections {
+		ps.OpenVPN[id] = c
+	}
+	for name, c := range s.wireguardConnections {
+		ps.WireGuard[name] = c
+	}
+	s.mu.RUnlock()
+
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.persistPath), 0700); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	// 0600: the file names credential and config paths; root-only.
+	if err := atomicfile.Write(s.persistPath, data, 0600); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	return nil
+}
+
+// persist saves the state after a mutation. Best-effort: a failed write must
+// never fail the operation that changed the state, so it only logs. Setters
+// defer it before taking s.mu, so it runs after the lock is released.
+func (s *State) persist() {
+	if err := s.Save(); err != nil {
+		log.Printf("[state] warning: cannot persist daemon state: %v", err)
+	}
+}
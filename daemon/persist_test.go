@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStateMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.state")
+
+	s := LoadState(path)
+	if s.Restored() {
+		t.Error("a missing file must not count as restored")
+	}
+	if s.GetKillSwitch().Enabled {
+		t.Error("fresh state should have the kill switch disabled")
+	}
+}
+
+func TestStatePersistRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib", "daemon.state")
+
+	s := LoadState(path)
+	s.SetKillSwitch(KillSwitchState{Enabled: true, VPNIface: "tun0", Backend: "iptables"})
+	s.SetIPv6Protection(IPv6ProtectionState{
+		Enabled:        true,
+		OriginalSysctl: map[string]string{"net.ipv6.conf.all.disable_ipv6": "0"},
+	})
+	s.SetOpenVPNConnection("p1", VPNConnectionState{
+		ProfileID:    "p1",
+		Status:       "connected",
+		PID:          4242,
+		StagedConfig: "/run/vpn-manager/ovpn/ovpn-1.conf",
+	})
+	s.SetWireGuardConnection("wg0", VPNConnectionState{ProfileID: "wg0", InterfaceName: "wg0"})
+
+	// Every setter writes through, so the file is current without a Save.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("state file mode = %o, want 0600", perm)
+	}
+
+	r := LoadState(path)
+	if !r.Restored() {
+		t.Fatal("state should be restored from the file")
+	}
+	if ks := r.GetKillSwitch(); !ks.Enabled || ks.VPNIface != "tun0" {
+		t.Errorf("kill switch = %+v, want enabled on tun0", ks)
+	}
+	if got := r.GetIPv6Protection().OriginalSysctl["net.ipv6.conf.all.disable_ipv6"]; got != "0" {
+		t.Errorf("IPv6 sysctl backup = %q, want \"0\"", got)
+	}
+	conn, ok := r.GetOpenVPNConnection("p1")
+	if !ok || conn.PID != 4242 || conn.StagedConfig == "" {
+		t.Errorf("OpenVPN connection = %+v, %v; want PID and staged config restored", conn, ok)
+	}
+	if len(r.ListWireGuardConnections()) != 1 {
+		t.Errorf("WireGuard connections = %d, want 1", len(r.ListWireGuardConnections()))
+	}
+
+	// A removal is persisted too.
+	r.RemoveOpenVPNConnection("p1")
+	if _, ok := LoadState(path).GetOpenVPNConnection("p1"); ok {
+		t.Error("removed connection came back after reload")
+	}
+}
+
+func TestLoadStateIgnoresBadFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"corrupt":       "{not json",
+		"other version": `{"version": 99, "kill_switch": {"enabled": true}}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			s := LoadState(path)
+			if s.Restored() {
+				t.Error("an unusable file must not count as restored")
+			}
+			if s.GetKillSwitch().Enabled {
+				t.Error("nothing from an unusable file should be applied")
+			}
+		})
+	}
+}
+
+func TestNewStateDoesNotPersist(t *testing.T) {
+	s := NewState()
+	if err := s.Save(); err != nil {
+		t.Errorf("Save on an in-memory state = %v, want nil", err)
+	}
+}
@@ -0,0 +1,283 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the optional per-method authorization policy (layer 5
+// in authz.go).
+//
+// Without a policy every socket-group member may call every method. A policy
+// reserves methods for chosen users and groups — for example, regular users
+// connect and disconnect while the LAN gateway, IPv6 sysctls and the Tailscale
+// operator stay with an admin group. Rules are tried in order and the first
+// whose method glob matches decides; a method no rule matches gets the
+// default. Root and the read-only classPublic methods are never restricted: the
+// GUI needs the latter to start at all, and root can rewrite the policy.
+//
+// The caller's groups are its primary GID plus the effective and supplementary
+// groups the kernel lists in /proc/<pid>/status, read once per connection while the
+// SO_PEERCRED PID is fresh — the groups the process actually holds, not what
+// /etc/group says now.
+
+// DefaultMethodPolicyPath is where the daemon looks for a method policy. A
+// missing file leaves every method open to the socket group.
+const DefaultMethodPolicyPath = "/etc/vpn-manager/policy.yaml"
+
+// PolicyDecision is what a policy does with a caller.
+type PolicyDecision string
+
+const (
+	// PolicyAllow lets the request through.
+	PolicyAllow PolicyDecision = "allow"
+	// PolicyDeny rejects the request as unauthorized.
+	PolicyDeny PolicyDecision = "deny"
+)
+
+// MethodRule grants the methods matching any of Methods to the listed users
+// and groups. Everyone else is denied those methods.
+type MethodRule struct {
+	// Methods are globs in path.Match syntax: "gateway.*", "tailscale.set_*".
+	Methods []string `yaml:"methods"`
+	// Users are user names or numeric UIDs.
+	Users []string `yaml:"users,omitempty"`
+	// Groups are group names or numeric GIDs.
+	Groups []string `yaml:"groups,omitempty"`
+
+	uids map[uint32]bool
+	gids map[uint32]bool
+}
+
+// MethodPolicy is the per-method authorization policy, loaded from
+// DefaultMethodPolicyPath. Example:
+//
+//	default: allow
+//	rules:
+//	  - methods: ["gateway.*", "ipv6.enable", "ipv6.disable", "tailscale.set_operator"]
+//	    groups: [vpn-admins]
+type MethodPolicy struct {
+	// Default applies to methods no rule matches. Unset means allow.
+	Default PolicyDecision `yaml:"default"`
+	// Rules are tried in order; the first matching one decides.
+	Rules []MethodRule `yaml:"rules"`
+}
+
+// Indirection for tests.
+var (
+	lookupUser  = user.Lookup
+	lookupGroup = user.LookupGroup
+)
+
+// LoadMethodPolicy reads and validates a method policy. It returns (nil, nil)
+// when the file does not exist, meaning every method is open.
+func LoadMethodPolicy(path string) (*MethodPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read method policy: %w", err)
+	}
+
+	var policy MethodPolicy
+	// Unknown keys are errors: a misspelt "group:" must not silently
+	// drop a restriction.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("parse method policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid method policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+// Validate checks the policy, fills in defaults and resolves user and group
+// names. A name that does not resolve is an error rather than a rule that
+// silently matches nobody.
+func (p *MethodPolicy) Validate() error {
+	switch p.Default {
+	case "":
+		p.Default = PolicyAllow
+	case PolicyAllow, PolicyDeny:
+	default:
+		return fmt.Errorf("unknown default %q (want allow or deny)", p.Default)
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if len(rule.Methods) == 0 {
+			return fmt.Errorf("rules[%d]: no methods", i)
+		}
+		for _, glob := range rule.Methods {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("rules[%d]: method %q: %w", i, glob, err)
+			}
+		}
+
+		rule.uids = make(map[uint32]bool, len(rule.Users))
+		for _, name := range rule.Users {
+			id, err := resolveID(name, func(n string) (string, error) {
+				u, err := lookupUser(n)
+				if err != nil {
+					return "", err
+				}
+				return u.Uid, nil
+			})
+			if err != nil {
+				return fmt.Errorf("rules[%d]: user %q: %w", i, name, err)
+			}
+			rule.uids[id] = true
+		}
+
+		rule.gids = make(map[uint32]bool, len(rule.Groups))
+		for _, name := range rule.Groups {
+			id, err := resolveID(name, func(n string) (string, error) {
+				g, err := lookupGroup(n)
+				if err != nil {
+					return "", err
+				}
+				return g.Gid, nil
+			})
+			if err != nil {
+				return fmt.Errorf("rules[%d]: group %q: %w", i, name, err)
+			}
+			rule.gids[id] = true
+		}
+	}
+
+	return nil
+}
+
+// resolveID parses a numeric ID or looks the name up.
+func resolveID(name string, lookup func(string) (string, error)) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	s, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("non-numeric id %q", s)
+	}
+	return uint32(id), nil
+}
+
+// Allows reports whether a caller with the given UID and groups may call
+// method, and which rule decided (-1 for the default).
+func (p *MethodPolicy) Allows(method string, uid uint32, groups []uint32) (bool, int) {
+	for i, rule := range p.Rules {
+		if !rule.matches(method) {
+			continue
+		}
+		if rule.uids[uid] {
+			return true, i
+		}
+		for _, gid := range groups {
+			if rule.gids[gid] {
+				return true, i
+			}
+		}
+		return false, i
+	}
+	return p.Default == PolicyAllow, -1
+}
+
+func (r *MethodRule) matches(method string) bool {
+	for _, glob := range r.Methods {
+		if ok, _ := path.Match(glob, method); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMethodPolicy applies the method policy to one request. It returns false
+// when the request must be rejected.
+func (s *Server) checkMethodPolicy(client *clientConn, method string) bool {
+	policy := s.policy.Load()
+	if policy == nil || client.uid == 0 || classOf(method) == classPublic {
+		return true
+	}
+
+	allowed, rule := policy.Allows(method, client.uid, client.groups(s.procRoot))
+	if !allowed {
+		which := "the default"
+		if rule >= 0 {
+			which = fmt.Sprintf("rule %d", rule)
+		}
+		s.logger.Printf("POLICY: denied %s to uid=%d pid=%d by %s", method, client.uid, client.pid, which)
+	}
+	return allowed
+}
+
+// groups returns the caller's primary and supplementary GIDs, reading them on
+// first use. A socket caller's primary GID comes from SO_PEERCRED; a D-Bus
+// caller has none, so its groups come from /proc alone.
+func (c *clientConn) groups(procRoot string) []uint32 {
+	c.groupsOnce.Do(func() {
+		if c.conn != nil {
+			c.gids = append(c.gids, c.gid)
+		}
+		c.gids = append(c.gids, callerGroups(procRoot, c.pid)...)
+	})
+	return c.gids
+}
+
+// callerGroups returns the effective GID and supplementary groups of pid, or
+// nil if its status cannot be read.
+func callerGroups(procRoot string, pid int32) []uint32 {
+	if pid <= 0 {
+		return nil
+	}
+	if procRoot == "" {
+		procRoot = defaultProcRoot
+	}
+
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(int(pid)), "status"))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var groups []uint32
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		switch key {
+		case "Gid":
+			// Real, effective, saved set, filesystem: keep the effective one.
+			if len(fields) > 1 {
+				fields = fields[1:2]
+			}
+		case "Groups":
+		default:
+			continue
+		}
+		for _, field := range fields {
+			if id, err := strconv.ParseUint(field, 10, 32); err == nil {
+				groups = append(groups, uint32(id))
+			}
+		}
+	}
+	return groups
+}
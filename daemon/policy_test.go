@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeLookups resolves user "alice" to 1000 and group "vpn-admins" to 2000.
+func fakeLookups(t *testing.T) {
+	t.Helper()
+	origUser, origGroup := lookupUser, lookupGroup
+	t.Cleanup(func() { lookupUser, lookupGroup = origUser, origGroup })
+
+	lookupUser = func(name string) (*user.User, error) {
+		if name == "alice" {
+			return &user.User{Username: name, Uid: "1000"}, nil
+		}
+		return nil, user.UnknownUserError(name)
+	}
+	lookupGroup = func(name string) (*user.Group, error) {
+		if name == "vpn-admins" {
+			return &user.Group{Name: name, Gid: "2000"}, nil
+		}
+		return nil, user.UnknownGroupError(name)
+	}
+}
+
+func TestLoadMethodPolicy(t *testing.T) {
+	fakeLookups(t)
+	dir := t.TempDir()
+
+	t.Run("missing file disables the layer", func(t *testing.T) {
+		policy, err := LoadMethodPolicy(filepath.Join(dir, "absent.yaml"))
+		if err != nil || policy != nil {
+			t.Errorf("LoadMethodPolicy = %v, %v; want nil, nil", policy, err)
+		}
+	})
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("valid policy", func(t *testing.T) {
+		path := write("valid.yaml", `
+rules:
+  - methods: ["gateway.*", "ipv6.enable"]
+    users: [alice, "1001"]
+    groups: [vpn-admins]
+`)
+		policy, err := LoadMethodPolicy(path)
+		if err != nil {
+			t.Fatalf("LoadMethodPolicy: %v", err)
+		}
+		if policy.Default != PolicyAllow {
+			t.Errorf("Default = %q, want %q", policy.Default, PolicyAllow)
+		}
+		rule := policy.Rules[0]
+		if !rule.uids[1000] || !rule.uids[1001] || !rule.gids[2000] {
+			t.Errorf("names not resolved: uids=%v gids=%v", rule.uids, rule.gids)
+		}
+	})
+
+	invalid := map[string]string{
+		"unknown default": "default: maybe\n",
+		"no methods":      "rules:\n  - groups: [vpn-admins]\n",
+		"bad glob":        "rules:\n  - methods: [\"gateway.[\"]\n",
+		"unknown user":    "rules:\n  - methods: [gateway.*]\n    users: [mallory]\n",
+		"unknown group":   "rules:\n  - methods: [gateway.*]\n    groups: [nobody-here]\n",
+		"misspelt key":    "rules:\n  - methods: [gateway.*]\n    group: [vpn-admins]\n",
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			path := write(strings.ReplaceAll(name, " ", "_")+".yaml", content)
+			if _, err := LoadMethodPolicy(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMethodPolicyAllows(t *testing.T) {
+	policy := &MethodPolicy{
+		Default: PolicyDeny,
+		Rules: []MethodRule{
+			{Methods: []string{"gateway.*"}, Groups: []string{"2000"}},
+			{Methods: []string{"gateway.status", "vpn.*"}, Users: []string{"1000"}},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		uid      uint32
+		groups   []uint32
+		want     bool
+		wantRule int
+	}{
+		{"group member", "gateway.enable", 1000, []uint32{1000, 2000}, true, 0},
+		{"first match decides", "gateway.status", 1000, []uint32{1000}, false, 0},
+		{"user rule", "vpn.openvpn.connect", 1000, nil, true, 1},
+		{"other user", "vpn.openvpn.connect", 1001, []uint32{2000}, false, 1},
+		{"unmatched method gets the default", "killswitch.enable", 1000, []uint32{2000}, false, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule := policy.Allows(tt.method, tt.uid, tt.groups)
+			if got != tt.want || rule != tt.wantRule {
+				t.Errorf("Allows(%s) = %v, %d; want %v, %d", tt.method, got, rule, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestCallerGroups(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "proc")
+	dir := filepath.Join(root, "42")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	status := "Name:\tvpn-manager\nUid:\t1000\t1000\t1000\t1000\nGid:\t1000\t1001\t1000\t1000\nGroups:\t4 27 2000 \n"
+	if err := os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := callerGroups(root, 42), []uint32{1001, 4, 27, 2000}; !reflect.DeepEqual(got, want) {
+		t.Errorf("callerGroups = %v, want %v", got, want)
+	}
+	if got := callerGroups(root, 43); got != nil {
+		t.Errorf("callerGroups(missing) = %v, want nil", got)
+	}
+
+	// A socket caller adds its SO_PEERCRED GID; a D-Bus caller (no conn)
+	// must not be credited with GID 0.
+	server, peer := net.Pipe()
+	defer func() { _ = server.Close(); _ = peer.Close() }()
+	socket := &clientConn{conn: server, gid: 1000, pid: 43}
+	if got := socket.groups(root); !reflect.DeepEqual(got, []uint32{1000}) {
+		t.Errorf("socket groups = %v, want [1000]", got)
+	}
+	bus := &clientConn{pid: 43}
+	if got := bus.groups(root); len(got) != 0 {
+		t.Errorf("D-Bus groups = %v, want none", got)
+	}
+}
+
+func TestCheckMethodPolicy(t *testing.T) {
+	var logBuf bytes.Buffer
+	policy := &MethodPolicy{
+		Default: PolicyAllow,
+		Rules:   []MethodRule{{Methods: []string{"gateway.*", "state.*"}, Groups: []string{"2000"}}},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s := NewServer(WithLogger(log.New(&logBuf, "", 0)), WithMethodPolicy(policy))
+	s.procRoot = filepath.Join(t.TempDir(), "proc")
+
+	caller := &clientConn{uid: 1000, pid: 10}
+	root := &clientConn{uid: 0, pid: 1}
+
+	if s.checkMethodPolicy(caller, "gateway.enable") {
+		t.Error("caller outside the group should be denied")
+	}
+	if !s.checkMethodPolicy(caller, "killswitch.enable") {
+		t.Error("unmatched method should get the default (allow)")
+	}
+	if !s.checkMethodPolicy(caller, "state.get") {
+		t.Error("public methods should never be restricted")
+	}
+	if !s.checkMethodPolicy(root, "gateway.enable") {
+		t.Error("root should be exempt")
+	}
+	if !strings.Contains(logBuf.String(), "POLICY: denied gateway.enable to uid=1000") {
+		t.Errorf("denial should be logged, log:\n%s", logBuf.String())
+	}
+
+	// Reloading to no policy opens everything again.
+	s.SetMethodPolicy(nil)
+	if !s.checkMethodPolicy(caller, "gateway.enable") {
+		t.Error("no policy should mean no method check")
+	}
+}
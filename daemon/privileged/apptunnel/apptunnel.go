@@ -14,12 +14,12 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 )
 
@@ -159,6 +159,33 @@ func (m *Manager) Disable() error {
 	return nil
 }
 
+// Adopt records split tunneling that a previous daemon instance enabled, without
+// touching the system: the cgroup, routes and iptables rules are still in place.
+// Afterwards Disable tears them down as usual, which is also how a restarted
+// daemon removes rules whose VPN interface has gone.
+func (m *Manager) Adopt(params EnableParams) error {
+	// SECURITY (C2): the adopted values come from the daemon's own state file,
+	// but Disable passes them to exec, so they are held to the same standard.
+	if err := validateEnableParams(params); err != nil {
+		return fmt.Errorf("invalid split-tunnel parameters: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mode = params.Mode
+	m.vpnInterface = params.VPNInterface
+	m.vpnGateway = params.VPNGateway
+	m.splitDNSEnabled = params.SplitDNSEnabled
+	m.vpnDNS = params.VPNDNS
+	if params.SystemDNS != "" {
+		m.systemDNS = params.SystemDNS
+	}
+	m.enabled = true
+
+	return nil
+}
+
 // Status returns the current status.
 type Status struct {
 	Enabled      bool   `json:"enabled"`
@@ -372,7 +399,7 @@ func (m *Manager) evacuateCgroup() error {
 // run executes a command in argv form (no shell) and returns an error that
 // includes trimmed command output on failure.
 func run(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
+	cmd := sysexec.Command(name, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
@@ -383,5 +410,5 @@ func run(name string, args ...string) error {
 // runIgnore executes a command and discards the result. Used for idempotent
 // cleanup operations where a "not found" failure is expected and harmless.
 func runIgnore(name string, args ...string) {
-	_ = exec.Command(name, args...).Run()
+	_ = sysexec.Command(name, args...).Run()
 }
@@ -0,0 +1,76 @@
+package apptunnel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon/netnstest"
+)
+
+// TestNetnsIncludeMode routes an application's cgroup through the tunnel, with
+// its DNS redirected to the tunnel's resolver, while everything else keeps
+// the normal route. Opt-in, see package netnstest.
+func TestNetnsIncludeMode(t *testing.T) {
+	netnstest.Run(t, "wg0", func(t *testing.T, n *netnstest.Topology) {
+		if !IsCgroupV2() {
+			t.Skip("needs cgroup v2")
+		}
+		if _, err := exec.LookPath("iptables"); err != nil {
+			t.Skip("iptables not installed")
+		}
+		// A full-tunnel client NATs to its tunnel address; the fwmark reroute
+		// keeps the source address chosen for the main table.
+		n.Exec(t, n.Client, "iptables", "-t", "nat", "-A", "POSTROUTING", "-o", n.Tunnel, "-j", "MASQUERADE")
+
+		m := NewManager()
+		m.cgroupPath = fmt.Sprintf("/sys/fs/cgroup/vpn_tunnel_netnstest_%d", os.Getpid())
+		_, err := m.Enable(EnableParams{
+			Mode:            "include",
+			VPNInterface:    n.Tunnel,
+			VPNGateway:      netnstest.TunnelPeerAddr,
+			SplitDNSEnabled: true,
+			VPNDNS:          []string{netnstest.TunnelPeerAddr},
+		})
+		if err != nil {
+			t.Fatalf("Enable: %v", err)
+		}
+		t.Cleanup(func() { _ = m.Disable() })
+
+		probe := func(network, addr, want string) {
+			t.Helper()
+			if got, err := n.ProbeInCgroup(m.cgroupPath, network, addr); err != nil || got != want {
+				t.Errorf("%s %s from the cgroup arrived from %q (%v), want %s", network, addr, got, err, want)
+			}
+		}
+		// The application goes through the tunnel; its DNS is answered by
+		// the tunnel's resolver even when it asks another.
+		probe("tcp", netnstest.Service(netnstest.InternetAddr), netnstest.TunnelAddr)
+		probe("udp", netnstest.DNS(netnstest.InternetAddr), netnstest.TunnelAddr)
+		// Everything else does not.
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr), netnstest.ClientWANAddr)
+		n.ExpectReach(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr), netnstest.ClientWANAddr)
+
+		if err := m.Disable(); err != nil {
+			t.Fatalf("Disable: %v", err)
+		}
+		if _, err := os.Stat(m.cgroupPath); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("cgroup %s left behind: %v", m.cgroupPath, err)
+		}
+		if rules := n.Exec(t, n.Client, "ip", "rule", "list"); strings.Contains(rules, "fwmark") {
+			t.Errorf("policy rule left behind:\n%s", rules)
+		}
+		if routes := n.Exec(t, n.Client, "ip", "route", "show", "table", m.tableStr()); strings.TrimSpace(routes) != "" {
+			t.Errorf("routes left in table %s:\n%s", m.tableStr(), routes)
+		}
+		if rules := n.Exec(t, n.Client, "iptables", "-t", "mangle", "-S", "OUTPUT"); strings.Contains(rules, "MARK") {
+			t.Errorf("mangle rule left behind:\n%s", rules)
+		}
+		if rules := n.Exec(t, n.Client, "iptables", "-t", "nat", "-S", "OUTPUT"); strings.Contains(rules, "DNAT") {
+			t.Errorf("DNS redirect left behind:\n%s", rules)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package privileged
+
+import (
+	"os"
+	"strings"
+
+	"github.com/yllada/vpn-manager/daemon/privileged/apptunnel"
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// =============================================================================
+// HOST CAPABILITIES (system.hello)
+// =============================================================================
+//
+// Capabilities tells clients up front which features this host can support,
+// so the UI greys out what would only fail at runtime (no nft, cgroup v1, no
+// wireguard module). Each probe is read-only and cheap; none loads a module or
+// starts a service.
+
+// Indirection for tests.
+var (
+	lookPath  = sysexec.LookPath
+	statPath  = os.Stat
+	runProbe  = func(name string, args ...string) error { return sysexec.Command(name, args...).Run() }
+	probeUnit = func(unit string) bool {
+		out, _ := sysexec.Command("systemctl", "is-active", unit).Output()
+		return strings.TrimSpace(string(out)) == "active"
+	}
+)
+
+// Capabilities probes the host and returns every protocol.Cap* flag.
+func Capabilities() map[string]bool {
+	has := func(bins ...string) bool {
+		for _, bin := range bins {
+			if _, err := lookPath(bin); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	return map[string]bool{
+		protocol.CapNftables:        has("nft"),
+		protocol.CapIptables:        has("iptables"),
+		protocol.CapIP6tables:       has("ip6tables"),
+		protocol.CapCgroupV2:        apptunnel.IsCgroupV2(),
+		protocol.CapWireGuardKernel: wireGuardModuleAvailable(),
+		protocol.CapWireGuardTools:  has("wg", "wg-quick"),
+		protocol.CapOpenVPN:         has("openvpn"),
+		protocol.CapTailscale:       has("tailscale"),
+		protocol.CapResolved:        has("resolvectl") && probeUnit("systemd-resolved"),
+		protocol.CapNetworkManager:  has("nmcli") && probeUnit("NetworkManager"),
+	}
+}
+
+// wireGuardModuleAvailable reports whether the wireguard module is loaded (or
+// built in), or modprobe could load it. The dry run does not touch the kernel.
+func wireGuardModuleAvailable() bool {
+	if _, err := statPath("/sys/module/wireguard"); err == nil {
+		return true
+	}
+	if _, err := lookPath("modprobe"); err != nil {
+		return false
+	}
+	return runProbe("modprobe", "--dry-run", "--quiet", "wireguard") == nil
+}
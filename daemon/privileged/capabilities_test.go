@@ -0,0 +1,93 @@
+package privileged
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// fakeHost replaces the capability probes with a host that has the given
+// binaries and active units, and no loaded wireguard module.
+func fakeHost(t *testing.T, bins []string, units []string, modprobeOK bool) {
+	t.Helper()
+	origLook, origStat, origRun, origUnit := lookPath, statPath, runProbe, probeUnit
+	t.Cleanup(func() {
+		lookPath, statPath, runProbe, probeUnit = origLook, origStat, origRun, origUnit
+	})
+
+	lookPath = func(name string) (string, error) {
+		for _, b := range bins {
+			if b == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	statPath = func(string) (os.FileInfo, error) { return nil, fs.ErrNotExist }
+	runProbe = func(string, ...string) error {
+		if modprobeOK {
+			return nil
+		}
+		return errors.New("module not found")
+	}
+	probeUnit = func(unit string) bool {
+		for _, u := range units {
+			if u == unit {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	fakeHost(t, []string{"nft", "iptables", "wg", "openvpn", "resolvectl", "nmcli", "modprobe"},
+		[]string{"systemd-resolved"}, true)
+
+	caps := Capabilities()
+	want := map[string]bool{
+		protocol.CapNftables:        true,
+		protocol.CapIptables:        true,
+		protocol.CapIP6tables:       false,
+		protocol.CapWireGuardKernel: true,
+		protocol.CapWireGuardTools:  false, // wg without wg-quick
+		protocol.CapOpenVPN:         true,
+		protocol.CapTailscale:       false,
+		protocol.CapResolved:        true,
+		protocol.CapNetworkManager:  false, // nmcli installed, service inactive
+	}
+	for name, v := range want {
+		got, ok := caps[name]
+		if !ok {
+			t.Errorf("%s not reported", name)
+			continue
+		}
+		if got != v {
+			t.Errorf("%s = %v, want %v", name, got, v)
+		}
+	}
+	if _, ok := caps[protocol.CapCgroupV2]; !ok {
+		t.Errorf("%s not reported", protocol.CapCgroupV2)
+	}
+}
+
+func TestWireGuardModuleAvailable(t *testing.T) {
+	fakeHost(t, nil, nil, true)
+	if wireGuardModuleAvailable() {
+		t.Error("without modprobe the module must be reported unavailable")
+	}
+
+	fakeHost(t, []string{"modprobe"}, nil, false)
+	if wireGuardModuleAvailable() {
+		t.Error("a failing modprobe dry run must report the module unavailable")
+	}
+
+	fakeHost(t, nil, nil, false)
+	statPath = func(string) (os.FileInfo, error) { return nil, nil }
+	if !wireGuardModuleAvailable() {
+		t.Error("a loaded module must be reported available")
+	}
+}
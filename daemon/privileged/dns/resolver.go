@@ -22,12 +22,12 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/internal/paths"
 )
 
@@ -52,13 +52,23 @@ const resolvConfMarker = "# Generated by vpn-managerd"
 // connect. It is a var so tests redirect it to a temp file.
 var resolverStatePath = paths.StateDir + "/dns-resolver.state"
 
+// SetRoot moves the files this package reads and writes (resolv.conf, the
+// NetworkManager drop-in, the restore state) under root, keeping their
+// absolute paths. SetRoot("/") restores the defaults. The daemon never calls
+// it; daemontest does, so a test cannot rewrite the host's resolver files.
+func SetRoot(root string) {
+	nmDNSConfPath = filepath.Join(root, "/etc/NetworkManager/conf.d/vpn-manager-dns.conf")
+	resolvConfPath = filepath.Join(root, "/etc/resolv.conf")
+	resolverStatePath = filepath.Join(root, paths.StateDir, "dns-resolver.state")
+}
+
 // Command seams. Declared as vars so tests substitute recorders and assert the
 // exact resolvectl/nmcli invocations without touching the real resolver.
 var (
-	lookPath = exec.LookPath
+	lookPath = sysexec.LookPath
 
 	runCmd = func(name string, args ...string) error {
-		cmd := exec.Command(name, args...)
+		cmd := sysexec.Command(name, args...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
 		}
@@ -66,7 +76,7 @@ var (
 	}
 
 	runCmdOutput = func(name string, args ...string) (string, error) {
-		out, err := exec.Command(name, args...).Output()
+		out, err := sysexec.Command(name, args...).Output()
 		return string(out), err
 	}
 
@@ -159,6 +169,9 @@ type Resolver struct {
 	// the resolv.conf backend, replayed verbatim by Restore.
 	resolvBackup    []byte
 	hadResolvBackup bool
+	// servers are the DNS servers the current override assigns, which Verify
+	// checks are still in place.
+	servers []string
 }
 
 // persistedResolverState is the on-disk form of the restore backup. It captures
@@ -168,11 +181,12 @@ type persistedResolverState struct {
 	Applied bool `json:"applied"`
 	// AppliedBackend is the backend that installed the override (the JSON key
 	// stays "backend" for on-disk compatibility).
-	AppliedBackend  Backend `json:"backend"`
-	Iface           string  `json:"iface"`
-	Ifindex         int     `json:"ifindex,omitempty"`
-	ResolvBackup    []byte  `json:"resolv_backup,omitempty"`
-	HadResolvBackup bool    `json:"had_resolv_backup"`
+	AppliedBackend  Backend  `json:"backend"`
+	Iface           string   `json:"iface"`
+	Ifindex         int      `json:"ifindex,omitempty"`
+	ResolvBackup    []byte   `json:"resolv_backup,omitempty"`
+	HadResolvBackup bool     `json:"had_resolv_backup"`
+	Servers         []string `json:"servers,omitempty"`
 }
 
 // NewResolver detects the active backend and returns a ready Resolver. If a
@@ -215,6 +229,7 @@ func (r *Resolver) loadState() {
 	r.ifindex = st.Ifindex
 	r.resolvBackup = st.ResolvBackup
 	r.hadResolvBackup = st.HadResolvBackup
+	r.servers = st.Servers
 	log.Printf("[dns] adopted resolver restore state from disk (backend: %s, iface: %s)", r.appliedBackend, r.iface)
 }
 
@@ -233,6 +248,7 @@ func (r *Resolver) saveStateLocked() {
 		Ifindex:         r.ifindex,
 		ResolvBackup:    r.resolvBackup,
 		HadResolvBackup: r.hadResolvBackup,
+		Servers:         r.servers,
 	}
 	data, err := json.Marshal(st)
 	if err != nil {
@@ -317,6 +333,7 @@ func (r *Resolver) Apply(vpnInterface string, servers []string, mode string) err
 		// detected backend and is no longer adopted-and-unvalidated.
 		r.appliedBackend = r.backend
 		r.adopted = false
+		r.servers = append([]string(nil), servers...)
 		r.saveStateLocked()
 	}
 	return err
@@ -346,6 +363,7 @@ func (r *Resolver) Restore() error {
 	r.ifindex = 0
 	r.resolvBackup = nil
 	r.hadResolvBackup = false
+	r.servers = nil
 	r.appliedBackend = ""
 	// The override is gone; drop the persisted backup so a later restart does not
 	// try to revert an already-reverted link.
@@ -353,6 +371,55 @@ func (r *Resolver) Restore() error {
 	return nil
 }
 
+// Verify reports whether the override installed by Apply is still in place,
+// returning nil when nothing is applied. It lets the daemon notice another
+// program (NetworkManager, a DHCP hook, the user) rewriting DNS behind its back.
+func (r *Resolver) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.applied {
+		return nil
+	}
+
+	switch r.appliedBackend {
+	case BackendSystemdResolved:
+		if _, ok := ifaceIndex(r.iface); !ok {
+			return fmt.Errorf("interface %s no longer exists", r.iface)
+		}
+		if len(r.servers) == 0 {
+			// Only the routing domain was set (strict mode without servers).
+			return nil
+		}
+		out, err := runCmdOutput("resolvectl", "dns", r.iface)
+		if err != nil {
+			return fmt.Errorf("resolvectl dns %s: %w", r.iface, err)
+		}
+		// Output is "Link N (iface): server server ..."; compare whole fields
+		// so 10.8.0.1 does not match 10.8.0.10.
+		assigned := make(map[string]bool)
+		for _, field := range strings.Fields(out) {
+			assigned[field] = true
+		}
+		for _, server := range r.servers {
+			if !assigned[server] {
+				return fmt.Errorf("server %s is no longer assigned to %s", server, r.iface)
+			}
+		}
+		return nil
+	case BackendNetworkManager:
+		if _, err := os.Stat(nmDNSConfPath); err != nil {
+			return fmt.Errorf("NetworkManager DNS drop-in is gone: %w", err)
+		}
+		return nil
+	default:
+		if !currentResolvConfIsOurs() {
+			return fmt.Errorf("%s was rewritten by another program", resolvConfPath)
+		}
+		return nil
+	}
+}
+
 // revertLocked performs the backend-specific revert using the backend that
 // installed the override. For state adopted from a previous daemon instance it
 // first checks the override is still ours to revert (the interface still exists;
@@ -765,3 +765,64 @@ func hasCmdPrefix(recorded [][]string, prefix []string) bool {
 	}
 	return false
 }
+
+func TestVerifyDetectsRewrittenResolvConf(t *testing.T) {
+	captureCmds(t)
+
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("nameserver 192.168.1.1\n"), 0644); err != nil {
+		t.Fatalf("seed resolv.conf: %v", err)
+	}
+	origPath := resolvConfPath
+	resolvConfPath = path
+	t.Cleanup(func() { resolvConfPath = origPath })
+
+	r := &Resolver{backend: BackendResolvConf}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify() with nothing applied = %v, want nil", err)
+	}
+	if err := r.Apply("tun0", []string{"9.9.9.9"}, "custom"); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify() right after Apply = %v, want nil", err)
+	}
+
+	// Another program (e.g. a DHCP hook) replaces the file.
+	if err := os.WriteFile(path, []byte("nameserver 192.168.1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Verify(); err == nil {
+		t.Error("Verify() should report the rewritten resolv.conf")
+	}
+}
+
+func TestVerifySystemdResolvedComparesServers(t *testing.T) {
+	captureCmds(t)
+
+	var output string
+	origOut := runCmdOutput
+	runCmdOutput = func(string, ...string) (string, error) { return output, nil }
+	t.Cleanup(func() { runCmdOutput = origOut })
+
+	r := &Resolver{backend: BackendSystemdResolved}
+	if err := r.Apply("tun0", []string{"10.8.0.1"}, "custom"); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	output = "Link 10 (tun0): 10.8.0.1\n"
+	if err := r.Verify(); err != nil {
+		t.Errorf("Verify() with the server assigned = %v, want nil", err)
+	}
+
+	// A prefix of another address must not count as a match.
+	output = "Link 10 (tun0): 10.8.0.10\n"
+	if err := r.Verify(); err == nil {
+		t.Error("Verify() should report the server as gone")
+	}
+
+	stubIfaceIndex(t, 0, false)
+	if err := r.Verify(); err == nil {
+		t.Error("Verify() should report the missing interface")
+	}
+}
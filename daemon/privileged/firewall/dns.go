@@ -4,8 +4,9 @@ package firewall
 import (
 	"fmt"
 	"log"
-	"os/exec"
 	"strings"
+
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 )
 
 // =============================================================================
@@ -36,7 +37,7 @@ type DNSProtectionParams struct {
 // This ensures all DNS queries go through the VPN tunnel.
 func EnableDNSFirewall(vpnInterface string) error {
 	// Check if iptables is available
-	if _, err := exec.LookPath("iptables"); err != nil {
+	if _, err := sysexec.LookPath("iptables"); err != nil {
 		return fmt.Errorf("iptables not available: %w", err)
 	}
 
@@ -144,7 +145,7 @@ func UnblockDNSOverTLS() error {
 
 // IsDNSFirewallActive checks if DNS firewall rules are present.
 func IsDNSFirewallActive() bool {
-	cmd := exec.Command("iptables", "-L", "OUTPUT", "-n")
+	cmd := sysexec.Command("iptables", "-L", "OUTPUT", "-n")
 	output, err := cmd.Output()
 	if err != nil {
 		return false
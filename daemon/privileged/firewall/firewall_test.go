@@ -21,6 +21,8 @@ func TestValidateKillSwitchParams(t *testing.T) {
 		{"empty interface", KillSwitchParams{VPNInterface: ""}, true},
 		{"interface flag injection", KillSwitchParams{VPNInterface: "-j"}, true},
 		{"bad server ip", KillSwitchParams{VPNInterface: "tun0", VPNServerIP: "1.2.3.4; rm -rf /"}, true},
+		{"several server ips", KillSwitchParams{VPNInterface: "tun0", VPNServerIP: "1.2.3.4", VPNServerIPs: []string{"5.6.7.8", "2001:db8::1"}}, false},
+		{"bad further server ip", KillSwitchParams{VPNInterface: "tun0", VPNServerIP: "1.2.3.4", VPNServerIPs: []string{"-j"}}, true},
 		{"lan default route rejected", KillSwitchParams{VPNInterface: "tun0", LANRanges: []string{"0.0.0.0/0"}}, true},
 		{"lan bad cidr", KillSwitchParams{VPNInterface: "tun0", LANRanges: []string{"nonsense"}}, true},
 	}
@@ -82,6 +84,25 @@ func TestBuildAllowedIPs(t *testing.T) {
 			wantLen:  3, // VPN server IP + 1 custom range + loopback
 			contains: "10.0.0.0/8",
 		},
+		{
+			name: "several servers",
+			params: KillSwitchParams{
+				VPNInterface: "tun0",
+				VPNServerIP:  "1.2.3.4",
+				VPNServerIPs: []string{"5.6.7.8", "9.10.11.12"},
+			},
+			wantLen:  4, // 3 VPN server IPs + loopback
+			contains: "9.10.11.12",
+		},
+		{
+			name: "several servers without a current one",
+			params: KillSwitchParams{
+				VPNInterface: "tun0",
+				VPNServerIPs: []string{"5.6.7.8", "2001:db8::1"},
+			},
+			wantLen:  3, // 2 VPN server IPs + loopback, no empty entry
+			contains: "2001:db8::1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -261,6 +282,24 @@ func TestKillSwitchNormalModeKeepsScopedAccepts(t *testing.T) {
 			t.Error("missing allowed destination accept rule")
 		}
 	})
+
+	t.Run("IPv6 servers", func(t *testing.T) {
+		recorded := captureCommands(t)
+		if err := enableKillSwitchNftables("tun0", []string{"1.2.3.4", "2001:db8::1"}); err != nil {
+			t.Fatalf("enableKillSwitchNftables() error = %v", err)
+		}
+		if !containsArgs(*recorded, "ip6", "daddr", "2001:db8::1", "accept") {
+			t.Error("nftables: missing ip6 accept rule for an IPv6 server")
+		}
+
+		recorded = captureCommands(t)
+		if err := enableKillSwitchIptables("tun0", []string{"1.2.3.4", "2001:db8::1"}); err != nil {
+			t.Fatalf("enableKillSwitchIptables() error = %v", err)
+		}
+		if containsArgs(*recorded, "-d", "2001:db8::1") {
+			t.Error("iptables: an IPv6 server was passed to the IPv4 chain")
+		}
+	})
 }
 
 // TestBlockAllKeepsLANAccepts guards against over-removal in block-all mode:
@@ -5,9 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"os/exec"
 	"regexp"
 	"strings"
+
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 )
 
 // =============================================================================
@@ -152,7 +153,7 @@ func DetectLANGatewayConfig() (*LANGatewayParams, error) {
 
 // detectWiFiInterface detects the active network interface used for internet access.
 func detectWiFiInterface() (string, error) {
-	cmd := exec.Command("ip", "route", "show", "default")
+	cmd := sysexec.Command("ip", "route", "show", "default")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to run 'ip route': %w", err)
@@ -170,7 +171,7 @@ func detectWiFiInterface() (string, error) {
 
 // detectLANNetwork detects the LAN network CIDR for the given interface.
 func detectLANNetwork(iface string) (string, error) {
-	cmd := exec.Command("ip", "-o", "-f", "inet", "addr", "show", iface)
+	cmd := sysexec.Command("ip", "-o", "-f", "inet", "addr", "show", iface)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get IP address for %s: %w", iface, err)
@@ -200,7 +201,7 @@ func detectLANNetwork(iface string) (string, error) {
 
 // IsLANGatewayActive checks if LAN Gateway rules are currently active.
 func IsLANGatewayActive() bool {
-	cmd := exec.Command("ip", "rule", "list")
+	cmd := sysexec.Command("ip", "rule", "list")
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -6,8 +6,10 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 )
 
 // =============================================================================
@@ -93,6 +95,32 @@ func DisableIPv6Protection(originalSysctl map[string]string) error {
 	return nil
 }
 
+// IPv6ProtectionStatus reports which of the mechanisms installed by
+// EnableIPv6Protection are currently in effect.
+type IPv6ProtectionStatus struct {
+	Sysctl   bool `json:"sysctl"`   // net.ipv6.conf.all.disable_ipv6 is 1
+	Firewall bool `json:"firewall"` // the nftables table or ip6tables chain is present
+}
+
+// Active reports whether IPv6 is blocked by at least one mechanism.
+func (s IPv6ProtectionStatus) Active() bool {
+	return s.Sysctl || s.Firewall
+}
+
+// CheckIPv6Protection inspects the live system for IPv6 protection.
+func CheckIPv6Protection() IPv6ProtectionStatus {
+	var status IPv6ProtectionStatus
+	if v, err := getSysctl("net.ipv6.conf.all.disable_ipv6"); err == nil && v == "1" {
+		status.Sysctl = true
+	}
+	if err := sysexec.Command("nft", "list", "table", "inet", IPv6NftablesTableName).Run(); err == nil {
+		status.Firewall = true
+	} else {
+		status.Firewall = isIPv6IptablesActive()
+	}
+	return status
+}
+
 // =============================================================================
 // SYSCTL OPERATIONS
 // =============================================================================
@@ -156,7 +184,7 @@ func restoreIPv6Sysctl(original map[string]string) error {
 		// Its /proc/sys entry then no longer exists and there is nothing to
 		// restore, so skip it silently instead of logging a spurious warning on
 		// every disconnect.
-		path := "/proc/sys/" + strings.ReplaceAll(key, ".", "/")
+		path := sysctlPath(key)
 		if _, err := os.Stat(path); err != nil {
 			continue
 		}
@@ -169,9 +197,24 @@ func restoreIPv6Sysctl(original map[string]string) error {
 	return lastErr
 }
 
+// procSys is where sysctls are read and written. A var so SetRoot can move it.
+var procSys = "/proc/sys"
+
+// SetRoot moves the sysctl tree this package reads and writes under root
+// (root/proc/sys). SetRoot("/") restores the default. The daemon never calls
+// it; daemontest does, so a test cannot change the host's kernel settings.
+func SetRoot(root string) {
+	procSys = filepath.Join(root, "/proc/sys")
+}
+
+// sysctlPath returns the file under procSys for a dotted sysctl key.
+func sysctlPath(key string) string {
+	return filepath.Join(procSys, strings.ReplaceAll(key, ".", "/"))
+}
+
 // getSysctl reads a sysctl value from /proc/sys.
 func getSysctl(key string) (string, error) {
-	path := "/proc/sys/" + strings.ReplaceAll(key, ".", "/")
+	path := sysctlPath(key)
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
@@ -182,7 +225,7 @@ func getSysctl(key string) (string, error) {
 // setSysctl writes a sysctl value (daemon has root privileges).
 func setSysctl(key, value string) error {
 	// Try direct write first
-	path := "/proc/sys/" + strings.ReplaceAll(key, ".", "/")
+	path := sysctlPath(key)
 	if err := os.WriteFile(path, []byte(value), 0644); err == nil {
 		return nil
 	}
@@ -197,7 +240,7 @@ func setSysctl(key, value string) error {
 
 // blockIPv6Iptables blocks IPv6 using ip6tables.
 func blockIPv6Iptables() error {
-	if _, err := exec.LookPath("ip6tables"); err != nil {
+	if _, err := sysexec.LookPath("ip6tables"); err != nil {
 		return fmt.Errorf("ip6tables not found: %w", err)
 	}
 
@@ -226,7 +269,7 @@ func blockIPv6Iptables() error {
 // isIPv6IptablesActive reports whether the IPv6 protection chain is hooked into
 // the ip6tables OUTPUT chain.
 func isIPv6IptablesActive() bool {
-	out, err := exec.Command("ip6tables", "-L", "OUTPUT", "-n").Output()
+	out, err := sysexec.Command("ip6tables", "-L", "OUTPUT", "-n").Output()
 	if err != nil {
 		return false
 	}
@@ -256,7 +299,7 @@ func unblockIPv6Iptables() error {
 
 // blockIPv6Nftables blocks IPv6 using nftables inet family.
 func blockIPv6Nftables() error {
-	if _, err := exec.LookPath("nft"); err != nil {
+	if _, err := sysexec.LookPath("nft"); err != nil {
 		return fmt.Errorf("nft not found: %w", err)
 	}
 
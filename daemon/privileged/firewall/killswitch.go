@@ -10,9 +10,9 @@ package firewall
 import (
 	"fmt"
 	"log"
-	"os/exec"
 	"strings"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 )
 
@@ -56,11 +56,11 @@ const (
 // Prefers nftables if available, falls back to iptables.
 func DetectBackend() FirewallBackend {
 	// Prefer nftables if available
-	if _, err := exec.LookPath("nft"); err == nil {
+	if _, err := sysexec.LookPath("nft"); err == nil {
 		return BackendNftables
 	}
 	// Fall back to iptables
-	if _, err := exec.LookPath("iptables"); err == nil {
+	if _, err := sysexec.LookPath("iptables"); err == nil {
 		return BackendIptables
 	}
 	return BackendNone
@@ -74,6 +74,7 @@ func DetectBackend() FirewallBackend {
 type KillSwitchParams struct {
 	VPNInterface string   // VPN interface name (e.g., "tun0", "tailscale0")
 	VPNServerIP  string   // VPN server IP to allow
+	VPNServerIPs []string // Further server IPs to allow (profiles with several remotes)
 	AllowLAN     bool     // Whether to allow LAN access
 	LANRanges    []string // Custom LAN ranges (uses defaults if empty)
 }
@@ -91,6 +92,11 @@ func validateKillSwitchParams(params KillSwitchParams) error {
 			return fmt.Errorf("vpn_server_ip: %w", err)
 		}
 	}
+	for _, ip := range params.VPNServerIPs {
+		if err := validate.IP(ip); err != nil {
+			return fmt.Errorf("vpn_server_ips %q: %w", ip, err)
+		}
+	}
 	for _, r := range params.LANRanges {
 		if err := validate.CIDRNotDefault(r); err != nil {
 			return fmt.Errorf("lan_range %q: %w", r, err)
@@ -159,7 +165,12 @@ func failClosed(backend FirewallBackend, cause error) error {
 
 // buildAllowedIPs constructs the list of IPs that bypass the kill switch.
 func buildAllowedIPs(params KillSwitchParams) []string {
-	allowed := []string{params.VPNServerIP}
+	var allowed []string
+	for _, ip := range append([]string{params.VPNServerIP}, params.VPNServerIPs...) {
+		if ip != "" {
+			allowed = append(allowed, ip)
+		}
+	}
 
 	if params.AllowLAN {
 		lanRanges := params.LANRanges
@@ -175,6 +186,11 @@ func buildAllowedIPs(params KillSwitchParams) []string {
 	return allowed
 }
 
+// isIPv6 reports whether an address or CIDR is IPv6.
+func isIPv6(ip string) bool {
+	return strings.Contains(ip, ":")
+}
+
 // enableKillSwitchIptables creates iptables rules for the kill switch.
 func enableKillSwitchIptables(vpnIface string, allowedIPs []string) error {
 	// Create custom chain (ignore error - might already exist)
@@ -195,9 +211,10 @@ func enableKillSwitchIptables(vpnIface string, allowedIPs []string) error {
 		return fmt.Errorf("failed to add VPN interface rule: %w", err)
 	}
 
-	// Allow local networks and VPN server
+	// Allow local networks and VPN server. The chain only sees IPv4; IPv6
+	// is left to the IPv6 protection.
 	for _, ip := range allowedIPs {
-		if ip == "" {
+		if ip == "" || isIPv6(ip) {
 			continue
 		}
 		if err := runCmd("iptables", "-A", KillSwitchChainName, "-d", ip, "-j", "ACCEPT"); err != nil {
@@ -254,13 +271,18 @@ func enableKillSwitchNftables(vpnIface string, allowedIPs []string) error {
 		return fmt.Errorf("failed to add VPN interface rule: %w", err)
 	}
 
-	// Allow specific IPs
+	// Allow specific IPs. The inet table sees both families, so a server
+	// that resolves to IPv6 needs an ip6 rule.
 	for _, ip := range allowedIPs {
 		if ip == "" {
 			continue
 		}
+		family := "ip"
+		if isIPv6(ip) {
+			family = "ip6"
+		}
 		_ = runCmd("nft", "add", "rule", "inet", NftablesTableName, "output",
-			"ip", "daddr", ip, "accept")
+			family, "daddr", ip, "accept")
 	}
 
 	// NOTE: deliberately NO global port-53 accept here. DNS is already covered
@@ -446,11 +468,11 @@ func IsKillSwitchActive() bool {
 // its terminating DROP (e.g. the append failed) would let traffic through while
 // looking "active". This mirrors the nftables policy-drop check.
 func checkIptablesRules() bool {
-	out, err := exec.Command("iptables", "-L", "OUTPUT", "-n").Output()
+	out, err := sysexec.Command("iptables", "-L", "OUTPUT", "-n").Output()
 	if err != nil || !strings.Contains(string(out), KillSwitchChainName) {
 		return false
 	}
-	chain, err := exec.Command("iptables", "-L", KillSwitchChainName, "-n").Output()
+	chain, err := sysexec.Command("iptables", "-L", KillSwitchChainName, "-n").Output()
 	if err != nil {
 		return false
 	}
@@ -463,7 +485,7 @@ func checkIptablesRules() bool {
 // nothing while looking "active". We therefore require the output chain to exist
 // AND have `policy drop`.
 func checkNftablesRules() bool {
-	cmd := exec.Command("nft", "list", "chain", "inet", NftablesTableName, "output")
+	cmd := sysexec.Command("nft", "list", "chain", "inet", NftablesTableName, "output")
 	out, err := cmd.Output()
 	if err != nil {
 		return false
@@ -479,7 +501,7 @@ func checkNftablesRules() bool {
 // Declared as a variable so tests can substitute a recorder and assert the
 // exact rule set that would be applied, without touching the real firewall.
 var runCmd = func(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
+	cmd := sysexec.Command(name, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s %s: %v - %s", name, strings.Join(args, " "), err, string(output))
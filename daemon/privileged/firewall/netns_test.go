@@ -0,0 +1,207 @@
+package firewall
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon/netnstest"
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
+)
+
+// =============================================================================
+// NETWORK NAMESPACE TESTS (opt-in, see package netnstest)
+// =============================================================================
+
+// iptablesOnly runs the host's programs but hides nft, so DetectBackend
+// falls back to iptables.
+type iptablesOnly struct{}
+
+func (iptablesOnly) LookPath(file string) (string, error) {
+	if file == "nft" {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	return exec.LookPath(file)
+}
+
+func (iptablesOnly) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// withBackend runs the kill switch tests once per firewall backend.
+func withBackend(t *testing.T, body func(t *testing.T, n *netnstest.Topology)) {
+	for _, backend := range []FirewallBackend{BackendNftables, BackendIptables} {
+		t.Run(string(backend), func(t *testing.T) {
+			netnstest.Run(t, "wg0", func(t *testing.T, n *netnstest.Topology) {
+				if _, err := exec.LookPath(string(backend)); err != nil {
+					t.Skipf("%s not installed", backend)
+				}
+				if backend == BackendIptables {
+					defer sysexec.SetExecutor(iptablesOnly{})()
+				}
+				if got := DetectBackend(); got != backend {
+					t.Fatalf("DetectBackend() = %s, want %s", got, backend)
+				}
+				body(t, n)
+			})
+		})
+	}
+}
+
+// expectNoKillSwitchRules fails the test if the kill switch left a table or
+// chain behind.
+func expectNoKillSwitchRules(t *testing.T, n *netnstest.Topology) {
+	t.Helper()
+	if DetectBackend() == BackendNftables {
+		if tables := n.Exec(t, n.Client, "nft", "list", "tables"); strings.Contains(tables, NftablesTableName) {
+			t.Errorf("table %s left behind:\n%s", NftablesTableName, tables)
+		}
+		return
+	}
+	if rules := n.Exec(t, n.Client, "iptables", "-S"); strings.Contains(rules, KillSwitchChainName) {
+		t.Errorf("chain %s left behind:\n%s", KillSwitchChainName, rules)
+	}
+}
+
+func TestNetnsKillSwitch(t *testing.T) {
+	withBackend(t, func(t *testing.T, n *netnstest.Topology) {
+		_, err := EnableKillSwitch(KillSwitchParams{
+			VPNInterface: n.Tunnel,
+			VPNServerIP:  netnstest.ServerAddr,
+			AllowLAN:     true,
+			LANRanges:    []string{netnstest.LANNetwork},
+		})
+		if err != nil {
+			t.Fatalf("EnableKillSwitch: %v", err)
+		}
+
+		// Only the tunnel, the VPN server and the LAN exception get out.
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.TunnelPeerAddr), netnstest.TunnelAddr)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.ServerAddr), netnstest.ClientWANAddr)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.LANPeerAddr), netnstest.ClientLANAddr)
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr))
+
+		// DNS goes to the tunnel's resolver and nowhere else.
+		n.ExpectReach(t, n.Client, "udp", netnstest.DNS(netnstest.TunnelPeerAddr), netnstest.TunnelAddr)
+		n.ExpectBlocked(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr))
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.DNS(netnstest.InternetAddr))
+
+		if err := DisableKillSwitch(); err != nil {
+			t.Fatalf("DisableKillSwitch: %v", err)
+		}
+		expectNoKillSwitchRules(t, n)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr), netnstest.ClientWANAddr)
+		n.ExpectReach(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr), netnstest.ClientWANAddr)
+	})
+}
+
+func TestNetnsKillSwitchWithoutLAN(t *testing.T) {
+	withBackend(t, func(t *testing.T, n *netnstest.Topology) {
+		_, err := EnableKillSwitch(KillSwitchParams{
+			VPNInterface: n.Tunnel,
+			VPNServerIP:  netnstest.ServerAddr,
+		})
+		if err != nil {
+			t.Fatalf("EnableKillSwitch: %v", err)
+		}
+
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.TunnelPeerAddr), netnstest.TunnelAddr)
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.Service(netnstest.LANPeerAddr))
+		n.ExpectBlocked(t, n.Client, "udp", netnstest.DNS(netnstest.LANPeerAddr))
+
+		if err := DisableKillSwitch(); err != nil {
+			t.Fatalf("DisableKillSwitch: %v", err)
+		}
+		expectNoKillSwitchRules(t, n)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.LANPeerAddr), netnstest.ClientLANAddr)
+	})
+}
+
+func TestNetnsBlockAll(t *testing.T) {
+	withBackend(t, func(t *testing.T, n *netnstest.Topology) {
+		if _, err := EnableBlockAll(); err != nil {
+			t.Fatalf("EnableBlockAll: %v", err)
+		}
+
+		// Block-all keeps the LAN and nothing beyond it, not even the server.
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.LANPeerAddr), netnstest.ClientLANAddr)
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.Service(netnstest.ServerAddr))
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr))
+		n.ExpectBlocked(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr))
+
+		if err := DisableKillSwitch(); err != nil {
+			t.Fatalf("DisableKillSwitch: %v", err)
+		}
+		expectNoKillSwitchRules(t, n)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr), netnstest.ClientWANAddr)
+	})
+}
+
+func TestNetnsDNSFirewall(t *testing.T) {
+	netnstest.Run(t, "wg0", func(t *testing.T, n *netnstest.Topology) {
+		if _, err := exec.LookPath("iptables"); err != nil {
+			t.Skip("iptables not installed")
+		}
+		if err := EnableDNSFirewall(n.Tunnel); err != nil {
+			t.Fatalf("EnableDNSFirewall: %v", err)
+		}
+
+		// DNS leaves through the tunnel only; other traffic is untouched.
+		n.ExpectReach(t, n.Client, "udp", netnstest.DNS(netnstest.TunnelPeerAddr), netnstest.TunnelAddr)
+		n.ExpectReach(t, n.Client, "tcp", netnstest.DNS(netnstest.TunnelPeerAddr), netnstest.TunnelAddr)
+		n.ExpectBlocked(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr))
+		n.ExpectBlocked(t, n.Client, "tcp", netnstest.DNS(netnstest.InternetAddr))
+		n.ExpectBlocked(t, n.Client, "udp", netnstest.DNS(netnstest.LANPeerAddr))
+		n.ExpectReach(t, n.Client, "tcp", netnstest.Service(netnstest.InternetAddr), netnstest.ClientWANAddr)
+		if !IsDNSFirewallActive() {
+			t.Error("IsDNSFirewallActive() = false while enabled")
+		}
+
+		if err := DisableDNSFirewall(); err != nil {
+			t.Fatalf("DisableDNSFirewall: %v", err)
+		}
+		if rules := n.Exec(t, n.Client, "iptables", "-S"); strings.Contains(rules, DNSFirewallChainName) {
+			t.Errorf("chain %s left behind:\n%s", DNSFirewallChainName, rules)
+		}
+		n.ExpectReach(t, n.Client, "udp", netnstest.DNS(netnstest.InternetAddr), netnstest.ClientWANAddr)
+	})
+}
+
+func TestNetnsLANGateway(t *testing.T) {
+	netnstest.Run(t, TailscaleInterface, func(t *testing.T, n *netnstest.Topology) {
+		if _, err := exec.LookPath("iptables"); err != nil {
+			t.Skip("iptables not installed")
+		}
+		// tailscaled routes exit node traffic through its own table.
+		n.Exec(t, n.Client, "ip", "route", "add", "default", "dev", n.Tunnel, "table", TailscaleRoutingTable)
+
+		// Forwarding is off, so the LAN cannot use the client as a gateway yet.
+		n.ExpectBlocked(t, n.LAN, "tcp", netnstest.Service(netnstest.InternetAddr))
+
+		params := LANGatewayParams{WiFiInterface: n.LANInterface, LANNetwork: netnstest.LANNetwork}
+		if err := EnableLANGateway(params); err != nil {
+			t.Fatalf("EnableLANGateway: %v", err)
+		}
+		// The LAN's traffic leaves through the tunnel, masqueraded as the client.
+		n.ExpectReach(t, n.LAN, "tcp", netnstest.Service(netnstest.InternetAddr), netnstest.TunnelAddr)
+		if !IsLANGatewayActive() {
+			t.Error("IsLANGatewayActive() = false while enabled")
+		}
+
+		if err := DisableLANGateway(params); err != nil {
+			t.Fatalf("DisableLANGateway: %v", err)
+		}
+		if rules := n.Exec(t, n.Client, "ip", "rule", "list"); strings.Contains(rules, "lookup "+TailscaleRoutingTable) {
+			t.Errorf("policy rule left behind:\n%s", rules)
+		}
+		if rules := n.Exec(t, n.Client, "iptables", "-S", "FORWARD"); strings.Contains(rules, n.Tunnel) {
+			t.Errorf("forward rules left behind:\n%s", rules)
+		}
+		if rules := n.Exec(t, n.Client, "iptables", "-t", "nat", "-S", "POSTROUTING"); strings.Contains(rules, "MASQUERADE") {
+			t.Errorf("masquerade rule left behind:\n%s", rules)
+		}
+		// Forwarding stays on, but unmasqueraded LAN traffic has no way back.
+		n.ExpectBlocked(t, n.LAN, "tcp", netnstest.Service(netnstest.InternetAddr))
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/yllada/vpn-manager/daemon"
 	"github.com/yllada/vpn-manager/daemon/privileged/apptunnel"
@@ -14,6 +15,7 @@ import (
 	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 	"github.com/yllada/vpn-manager/daemon/privileged/vpn"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // =============================================================================
@@ -48,6 +50,7 @@ func GetDNSResolver() *dnsresolver.Resolver {
 type dnsResolverOps interface {
 	Apply(vpnInterface string, servers []string, mode string) error
 	Restore() error
+	Verify() error
 	Backend() dnsresolver.Backend
 }
 
@@ -82,6 +85,43 @@ func GetWireGuardManager(logger *log.Logger) *vpn.WireGuardManager {
 	return wireguardManager
 }
 
+// PublishEvents wires the long-lived managers to the daemon's state and event
+// hub, so changes that happen outside any request — OpenVPN reaching
+// "connected" or dropping minutes after openvpn.connect returned — are recorded
+// in the persisted State and reach subscribed clients without them polling
+// openvpn.status. openvpn.connect relies on it to record connections. Call
+// once at startup.
+func PublishEvents(state *daemon.State, events *daemon.EventHub, logger *log.Logger) {
+	GetOpenVPNManager(logger).SetStateChangeHandler(func(status vpn.OpenVPNStatusResult) {
+		recordOpenVPNStatus(state, status)
+		events.Publish(protocol.TopicOpenVPNState, status)
+	})
+}
+
+// recordOpenVPNStatus records a connection state transition. It owns the
+// Status of a tracked connection: openvpn.connect only adds the runtime
+// details, so a transition that lands before the handler returns is not
+// overwritten. The first transition of a new connection, reported while
+// manager.Connect is still running, adds the entry.
+func recordOpenVPNStatus(state *daemon.State, status vpn.OpenVPNStatusResult) {
+	switch status.Status {
+	case vpn.StatusDisconnected, vpn.StatusError:
+		// Both are final: an error is only reported once the process exited.
+		state.RemoveOpenVPNConnection(status.ProfileID)
+		return
+	}
+	update := func(c *daemon.VPNConnectionState) {
+		c.Status = status.Status
+		c.IPAddress = status.IPAddress
+		c.LastError = status.LastError
+	}
+	if !state.UpdateOpenVPNConnection(status.ProfileID, update) {
+		conn := daemon.VPNConnectionState{ProfileID: status.ProfileID}
+		update(&conn)
+		state.SetOpenVPNConnection(status.ProfileID, conn)
+	}
+}
+
 // GetAppTunnelManager returns the app tunnel manager singleton.
 func GetAppTunnelManager() *apptunnel.Manager {
 	appTunnelOnce.Do(func() {
@@ -98,12 +138,41 @@ func GetAppTunnelManager() *apptunnel.Manager {
 type KillSwitchEnableParams struct {
 	VPNInterface string   `json:"vpn_interface"`
 	VPNServerIP  string   `json:"vpn_server_ip,omitempty"`
+	VPNServerIPs []string `json:"vpn_server_ips,omitempty"` // further servers, for profiles with several remotes
 	AllowLAN     bool     `json:"allow_lan"`
 	LANRanges    []string `json:"lan_ranges,omitempty"`
 }
 
+// KillSwitchEnableResult is the result of killswitch.enable and
+// killswitch.block_all.
+type KillSwitchEnableResult struct {
+	Enabled bool   `json:"enabled"`
+	Backend string `json:"backend"`
+	Mode    string `json:"mode,omitempty"` // "block_all" for killswitch.block_all
+}
+
+// KillSwitchStatusResult is the result of killswitch.status.
+type KillSwitchStatusResult struct {
+	Enabled     bool   `json:"enabled"`
+	VPNIface    string `json:"vpn_iface"`
+	AllowLAN    bool   `json:"allow_lan"`
+	Backend     string `json:"backend"`
+	RulesActive bool   `json:"rules_active"` // firewall rules actually present
+}
+
+// EnabledResult is the result of the enable/disable methods that report only
+// the feature's new state.
+type EnabledResult struct {
+	Enabled bool `json:"enabled"`
+}
+
 // KillSwitchEnableHandler returns a handler that enables the kill switch.
 func KillSwitchEnableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, killSwitchEnable(state))
+}
+
+// killSwitchEnable implements killswitch.enable; security.apply reuses it.
+func killSwitchEnable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		var params KillSwitchEnableParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
@@ -116,6 +185,7 @@ func KillSwitchEnableHandler(state *daemon.State) daemon.HandlerFunc {
 		backend, err := firewall.EnableKillSwitch(firewall.KillSwitchParams{
 			VPNInterface: params.VPNInterface,
 			VPNServerIP:  params.VPNServerIP,
+			VPNServerIPs: params.VPNServerIPs,
 			AllowLAN:     params.AllowLAN,
 			LANRanges:    params.LANRanges,
 		})
@@ -125,21 +195,27 @@ func KillSwitchEnableHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetKillSwitch(daemon.KillSwitchState{
-			Enabled:  true,
-			VPNIface: params.VPNInterface,
-			AllowLAN: params.AllowLAN,
-			Backend:  string(backend),
+			Enabled:      true,
+			VPNIface:     params.VPNInterface,
+			AllowLAN:     params.AllowLAN,
+			Backend:      string(backend),
+			VPNServerIP:  params.VPNServerIP,
+			VPNServerIPs: params.VPNServerIPs,
+			LANRanges:    params.LANRanges,
 		})
+		ctx.Events.Publish(protocol.TopicKillSwitch, state.GetKillSwitch())
 
-		return map[string]any{
-			"enabled": true,
-			"backend": string(backend),
-		}, nil
+		return KillSwitchEnableResult{Enabled: true, Backend: string(backend)}, nil
 	}
 }
 
 // KillSwitchDisableHandler returns a handler that disables the kill switch.
 func KillSwitchDisableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, killSwitchDisable(state))
+}
+
+// killSwitchDisable implements killswitch.disable; security.apply reuses it.
+func killSwitchDisable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		ctx.Logger.Printf("Disabling kill switch")
 
@@ -151,8 +227,9 @@ func KillSwitchDisableHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetKillSwitchEnabled(false)
+		ctx.Events.Publish(protocol.TopicKillSwitch, state.GetKillSwitch())
 
-		return map[string]bool{"enabled": false}, nil
+		return EnabledResult{Enabled: false}, nil
 	}
 }
 
@@ -164,12 +241,12 @@ func KillSwitchStatusHandler(state *daemon.State) daemon.HandlerFunc {
 		// Also check if rules are actually active
 		rulesActive := firewall.IsKillSwitchActive()
 
-		return map[string]any{
-			"enabled":      ksState.Enabled,
-			"vpn_iface":    ksState.VPNIface,
-			"allow_lan":    ksState.AllowLAN,
-			"backend":      ksState.Backend,
-			"rules_active": rulesActive,
+		return KillSwitchStatusResult{
+			Enabled:     ksState.Enabled,
+			VPNIface:    ksState.VPNIface,
+			AllowLAN:    ksState.AllowLAN,
+			Backend:     ksState.Backend,
+			RulesActive: rulesActive,
 		}, nil
 	}
 }
@@ -177,6 +254,11 @@ func KillSwitchStatusHandler(state *daemon.State) daemon.HandlerFunc {
 // KillSwitchBlockAllHandler returns a handler that enables a block-all kill switch.
 // This is used when VPN connection fails on an untrusted network.
 func KillSwitchBlockAllHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, killSwitchBlockAll(state))
+}
+
+// killSwitchBlockAll implements killswitch.block_all; security.apply reuses it.
+func killSwitchBlockAll(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		ctx.Logger.Printf("Enabling block-all kill switch mode")
 
@@ -193,12 +275,9 @@ func KillSwitchBlockAllHandler(state *daemon.State) daemon.HandlerFunc {
 			AllowLAN: false,
 			Backend:  string(backend),
 		})
+		ctx.Events.Publish(protocol.TopicKillSwitch, state.GetKillSwitch())
 
-		return map[string]any{
-			"enabled": true,
-			"backend": string(backend),
-			"mode":    "block_all",
-		}, nil
+		return KillSwitchEnableResult{Enabled: true, Backend: string(backend), Mode: "block_all"}, nil
 	}
 }
 
@@ -216,8 +295,25 @@ type DNSEnableParams struct {
 	LeakBlocking bool     `json:"leak_blocking"`
 }
 
+// DNSStatusResult is the result of dns.status.
+type DNSStatusResult struct {
+	Enabled      bool     `json:"enabled"`
+	Servers      []string `json:"servers"`
+	Mode         string   `json:"mode"`
+	Backend      string   `json:"backend"`
+	BlockDoT     bool     `json:"block_dot"`
+	BlockDoH     bool     `json:"block_doh"`
+	LeakBlocking bool     `json:"leak_blocking"`
+	RulesActive  bool     `json:"rules_active"` // firewall rules actually present
+}
+
 // DNSEnableHandler returns a handler that enables DNS protection.
 func DNSEnableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, dnsEnable(state))
+}
+
+// dnsEnable implements dns.enable; security.apply reuses it.
+func dnsEnable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		var params DNSEnableParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
@@ -281,6 +377,7 @@ func DNSEnableHandler(state *daemon.State) daemon.HandlerFunc {
 		// Update state
 		state.SetDNSProtection(daemon.DNSProtectionState{
 			Enabled:      true,
+			VPNIface:     params.VPNInterface,
 			Servers:      params.Servers,
 			Mode:         params.Mode,
 			Backend:      string(resolver.Backend()),
@@ -288,13 +385,19 @@ func DNSEnableHandler(state *daemon.State) daemon.HandlerFunc {
 			BlockDoH:     params.BlockDoH,
 			LeakBlocking: params.LeakBlocking,
 		})
+		ctx.Events.Publish(protocol.TopicDNS, state.GetDNSProtection())
 
-		return map[string]bool{"enabled": true}, nil
+		return EnabledResult{Enabled: true}, nil
 	}
 }
 
 // DNSDisableHandler returns a handler that disables DNS protection.
 func DNSDisableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, dnsDisable(state))
+}
+
+// dnsDisable implements dns.disable; security.apply reuses it.
+func dnsDisable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		ctx.Logger.Printf("Disabling DNS protection")
 
@@ -320,8 +423,9 @@ func DNSDisableHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetDNSProtectionEnabled(false)
+		ctx.Events.Publish(protocol.TopicDNS, state.GetDNSProtection())
 
-		return map[string]bool{"enabled": false}, nil
+		return EnabledResult{Enabled: false}, nil
 	}
 }
 
@@ -333,15 +437,15 @@ func DNSStatusHandler(state *daemon.State) daemon.HandlerFunc {
 		// Check if firewall rules are actually active
 		rulesActive := firewall.IsDNSFirewallActive()
 
-		return map[string]any{
-			"enabled":       dnsState.Enabled,
-			"servers":       dnsState.Servers,
-			"mode":          dnsState.Mode,
-			"backend":       dnsState.Backend,
-			"block_dot":     dnsState.BlockDoT,
-			"block_doh":     dnsState.BlockDoH,
-			"leak_blocking": dnsState.LeakBlocking,
-			"rules_active":  rulesActive,
+		return DNSStatusResult{
+			Enabled:      dnsState.Enabled,
+			Servers:      dnsState.Servers,
+			Mode:         dnsState.Mode,
+			Backend:      dnsState.Backend,
+			BlockDoT:     dnsState.BlockDoT,
+			BlockDoH:     dnsState.BlockDoH,
+			LeakBlocking: dnsState.LeakBlocking,
+			RulesActive:  rulesActive,
 		}, nil
 	}
 }
@@ -358,6 +462,11 @@ type IPv6EnableParams struct {
 
 // IPv6EnableHandler returns a handler that enables IPv6 protection.
 func IPv6EnableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, ipv6Enable(state))
+}
+
+// ipv6Enable implements ipv6.enable; security.apply reuses it.
+func ipv6Enable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		var params IPv6EnableParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
@@ -379,6 +488,18 @@ func IPv6EnableHandler(state *daemon.State) daemon.HandlerFunc {
 			}
 		}
 
+		// Re-enabling over an active protection (a repeated ipv6.enable, or
+		// security.apply repairing drift) must keep the first backup: the values
+		// read now may already be ours, and restoring them would leave IPv6 off.
+		if prev := state.GetIPv6Protection(); prev.Enabled {
+			if originalSysctl == nil {
+				originalSysctl = make(map[string]string, len(prev.OriginalSysctl))
+			}
+			for key, value := range prev.OriginalSysctl {
+				originalSysctl[key] = value
+			}
+		}
+
 		// Update state (store original sysctl for later restore)
 		state.SetIPv6Protection(daemon.IPv6ProtectionState{
 			Enabled:        true,
@@ -386,13 +507,19 @@ func IPv6EnableHandler(state *daemon.State) daemon.HandlerFunc {
 			BlockWebRTC:    params.BlockWebRTC,
 			OriginalSysctl: originalSysctl,
 		})
+		ctx.Events.Publish(protocol.TopicIPv6, state.GetIPv6Protection())
 
-		return map[string]bool{"enabled": true}, nil
+		return EnabledResult{Enabled: true}, nil
 	}
 }
 
 // IPv6DisableHandler returns a handler that disables IPv6 protection.
 func IPv6DisableHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, ipv6Disable(state))
+}
+
+// ipv6Disable implements ipv6.disable; security.apply reuses it.
+func ipv6Disable(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		ctx.Logger.Printf("Disabling IPv6 protection")
 
@@ -413,8 +540,9 @@ func IPv6DisableHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetIPv6ProtectionEnabled(false)
+		ctx.Events.Publish(protocol.TopicIPv6, state.GetIPv6Protection())
 
-		return map[string]bool{"enabled": false}, nil
+		return EnabledResult{Enabled: false}, nil
 	}
 }
 
@@ -440,8 +568,19 @@ type TunnelSetupParams struct {
 	SystemDNS       string   `json:"system_dns,omitempty"`
 }
 
+// TunnelSetupResult is the result of tunnel.setup.
+type TunnelSetupResult apptunnel.EnableResult
+
+// TunnelStatusResult is the result of tunnel.status.
+type TunnelStatusResult apptunnel.Status
+
 // TunnelSetupHandler returns a handler that sets up split tunneling.
 func TunnelSetupHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, tunnelSetup(state))
+}
+
+// tunnelSetup implements tunnel.setup; security.apply reuses it.
+func tunnelSetup(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		var params TunnelSetupParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
@@ -464,20 +603,35 @@ func TunnelSetupHandler(state *daemon.State) daemon.HandlerFunc {
 			return nil, err
 		}
 
-		// Update state
+		// Update state. A re-apply by the daemon itself keeps the owner.
+		owner := ctx.Owner()
+		if owner == nil {
+			owner = state.GetSplitTunnel().Owner
+		}
 		state.SetSplitTunnel(daemon.SplitTunnelState{
-			Enabled:  true,
-			Mode:     params.Mode,
-			Apps:     params.Apps,
-			VPNIface: params.VPNInterface,
+			Enabled:    true,
+			Mode:       params.Mode,
+			Apps:       params.Apps,
+			VPNIface:   params.VPNInterface,
+			VPNGateway: params.VPNGateway,
+			SplitDNS:   params.SplitDNSEnabled,
+			VPNDNS:     params.VPNDNS,
+			SystemDNS:  params.SystemDNS,
+			Owner:      owner,
 		})
+		ctx.Events.Publish(protocol.TopicSplitTunnel, state.GetSplitTunnel())
 
-		return result, nil
+		return TunnelSetupResult(*result), nil
 	}
 }
 
 // TunnelCleanupHandler returns a handler that cleans up split tunneling.
 func TunnelCleanupHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, tunnelCleanup(state))
+}
+
+// tunnelCleanup implements tunnel.cleanup; security.apply reuses it.
+func tunnelCleanup(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		ctx.Logger.Printf("Cleaning up split tunnel")
 
@@ -488,8 +642,9 @@ func TunnelCleanupHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetSplitTunnelEnabled(false)
+		ctx.Events.Publish(protocol.TopicSplitTunnel, state.GetSplitTunnel())
 
-		return map[string]bool{"enabled": false}, nil
+		return EnabledResult{Enabled: false}, nil
 	}
 }
 
@@ -497,14 +652,8 @@ func TunnelCleanupHandler(state *daemon.State) daemon.HandlerFunc {
 func TunnelStatusHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
 		manager := GetAppTunnelManager()
-		status := manager.GetStatus()
 
-		return map[string]any{
-			"enabled":       status.Enabled,
-			"mode":          status.Mode,
-			"vpn_interface": status.VPNInterface,
-			"cgroup_path":   status.CgroupPath,
-		}, nil
+		return TunnelStatusResult(manager.GetStatus()), nil
 	}
 }
 
@@ -519,6 +668,24 @@ type GatewayEnableParams struct {
 	LANNetwork    string `json:"lan_network,omitempty"` // Auto-detect if empty
 }
 
+// GatewayEnableResult is the result of gateway.enable, with the interface and
+// network actually used.
+type GatewayEnableResult struct {
+	Enabled       bool   `json:"enabled"`
+	WiFiInterface string `json:"wifi_interface"`
+	LANNetwork    string `json:"lan_network"`
+	AlreadyActive bool   `json:"already_active,omitempty"`
+}
+
+// GatewayStatusResult is the result of gateway.status.
+type GatewayStatusResult struct {
+	Enabled       bool   `json:"enabled"`
+	WiFiInterface string `json:"wifi_interface"`
+	TailscaleIP   string `json:"tailscale_ip"`
+	LANNetwork    string `json:"lan_network"`
+	RulesActive   bool   `json:"rules_active"` // firewall rules actually present
+}
+
 // GatewayEnableHandler returns a handler that enables LAN gateway.
 func GatewayEnableHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -546,11 +713,11 @@ func GatewayEnableHandler(state *daemon.State) daemon.HandlerFunc {
 		// Check if already active to avoid duplicate rules
 		if firewall.IsLANGatewayActive() {
 			ctx.Logger.Printf("LAN Gateway rules already active, skipping")
-			return map[string]any{
-				"enabled":        true,
-				"wifi_interface": params.WiFiInterface,
-				"lan_network":    params.LANNetwork,
-				"already_active": true,
+			return GatewayEnableResult{
+				Enabled:       true,
+				WiFiInterface: params.WiFiInterface,
+				LANNetwork:    params.LANNetwork,
+				AlreadyActive: true,
 			}, nil
 		}
 
@@ -569,12 +736,14 @@ func GatewayEnableHandler(state *daemon.State) daemon.HandlerFunc {
 			WiFiIface:   params.WiFiInterface,
 			TailscaleIP: params.TailscaleIP,
 			LANNetwork:  params.LANNetwork,
+			Owner:       ctx.Owner(),
 		})
+		ctx.Events.Publish(protocol.TopicGateway, state.GetLANGateway())
 
-		return map[string]any{
-			"enabled":        true,
-			"wifi_interface": params.WiFiInterface,
-			"lan_network":    params.LANNetwork,
+		return GatewayEnableResult{
+			Enabled:       true,
+			WiFiInterface: params.WiFiInterface,
+			LANNetwork:    params.LANNetwork,
 		}, nil
 	}
 }
@@ -612,8 +781,9 @@ func GatewayDisableHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.SetLANGatewayEnabled(false)
+		ctx.Events.Publish(protocol.TopicGateway, state.GetLANGateway())
 
-		return map[string]bool{"enabled": false}, nil
+		return EnabledResult{Enabled: false}, nil
 	}
 }
 
@@ -625,12 +795,12 @@ func GatewayStatusHandler(state *daemon.State) daemon.HandlerFunc {
 		// Check if rules are actually active
 		rulesActive := firewall.IsLANGatewayActive()
 
-		return map[string]any{
-			"enabled":        gwState.Enabled,
-			"wifi_interface": gwState.WiFiIface,
-			"tailscale_ip":   gwState.TailscaleIP,
-			"lan_network":    gwState.LANNetwork,
-			"rules_active":   rulesActive,
+		return GatewayStatusResult{
+			Enabled:       gwState.Enabled,
+			WiFiInterface: gwState.WiFiIface,
+			TailscaleIP:   gwState.TailscaleIP,
+			LANNetwork:    gwState.LANNetwork,
+			RulesActive:   rulesActive,
 		}, nil
 	}
 }
@@ -639,6 +809,17 @@ func GatewayStatusHandler(state *daemon.State) daemon.HandlerFunc {
 // OPENVPN HANDLERS
 // =============================================================================
 
+// OpenVPNProfileParams selects an OpenVPN connection by profile, for
+// openvpn.disconnect and openvpn.status.
+type OpenVPNProfileParams struct {
+	ProfileID string `json:"profile_id"`
+}
+
+// DisconnectResult is the result of openvpn.disconnect and wireguard.disconnect.
+type DisconnectResult struct {
+	Disconnected bool `json:"disconnected"`
+}
+
 // OpenVPNConnectHandler returns a handler that starts an OpenVPN connection.
 func OpenVPNConnectHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -649,17 +830,38 @@ func OpenVPNConnectHandler(state *daemon.State) daemon.HandlerFunc {
 
 		ctx.Logger.Printf("Starting OpenVPN connection for profile %s", params.ProfileID)
 
+		params.CallerUID = callerUID(ctx)
 		manager := GetOpenVPNManager(ctx.Logger)
 		result, err := manager.Connect(ctx.Context, params)
 		if err != nil {
 			return nil, err
 		}
+		if err := ctx.Context.Err(); err != nil {
+			// Cancelled while openvpn was starting: nobody will track this
+			// connection, so stop it instead of leaving it running.
+			ctx.Logger.Printf("OpenVPN connect for profile %s cancelled, stopping it", params.ProfileID)
+			if stopErr := manager.Disconnect(params.ProfileID); stopErr != nil {
+				ctx.Logger.Printf("Failed to stop cancelled OpenVPN connection: %v", stopErr)
+			}
+			return nil, err
+		}
 
-		// Update state
-		state.SetOpenVPNConnection(params.ProfileID, daemon.VPNConnectionState{
-			ProfileID:  params.ProfileID,
-			Status:     vpn.StatusConnecting,
-			ConfigPath: params.ConfigPath,
+		// Add the runtime details that let a restarted daemon re-adopt the
+		// process (see Reconcile) to the entry the state change hook created.
+		// The hook owns Status; if the process already ended it removed the
+		// entry, and a dead connection must not be recorded again.
+		info, ok := manager.ProcessInfo(params.ProfileID)
+		if !ok || info.Status == vpn.StatusDisconnected || info.Status == vpn.StatusError {
+			return result, nil
+		}
+		state.UpdateOpenVPNConnection(params.ProfileID, func(c *daemon.VPNConnectionState) {
+			c.ConfigPath = params.ConfigPath
+			c.Owner = ctx.Owner()
+			c.PID = info.PID
+			c.StagedConfig = info.StagedConfig
+			c.ManagementSocket = info.ManagementSocket
+			c.LogFile = info.LogFile
+			c.StartedAt = info.StartTime.Format(time.RFC3339)
 		})
 
 		return result, nil
@@ -669,9 +871,7 @@ func OpenVPNConnectHandler(state *daemon.State) daemon.HandlerFunc {
 // OpenVPNDisconnectHandler returns a handler that stops an OpenVPN connection.
 func OpenVPNDisconnectHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
-		var params struct {
-			ProfileID string `json:"profile_id"`
-		}
+		var params OpenVPNProfileParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
 			return nil, err
 		}
@@ -686,25 +886,38 @@ func OpenVPNDisconnectHandler(state *daemon.State) daemon.HandlerFunc {
 		// Update state
 		state.RemoveOpenVPNConnection(params.ProfileID)
 
-		return map[string]bool{"disconnected": true}, nil
+		return DisconnectResult{Disconnected: true}, nil
 	}
 }
 
 // OpenVPNStatusHandler returns a handler that reports OpenVPN connection status.
 func OpenVPNStatusHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
-		var params struct {
-			ProfileID string `json:"profile_id"`
-		}
+		var params OpenVPNProfileParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
 			return nil, err
 		}
 
 		manager := GetOpenVPNManager(ctx.Logger)
-		return manager.Status(params.ProfileID)
+		result, err := manager.Status(params.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+		result.AuthToken = manager.HasAuthToken(params.ProfileID, callerUID(ctx))
+		return result, nil
 	}
 }
 
+// callerUID returns the UID of the request's caller, or nil for calls the
+// daemon makes itself.
+func callerUID(ctx *daemon.HandlerContext) *uint32 {
+	if ctx.PID == 0 {
+		return nil
+	}
+	uid := ctx.UID
+	return &uid
+}
+
 // OpenVPNListHandler returns a handler that lists all OpenVPN connections.
 func OpenVPNListHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -713,10 +926,112 @@ func OpenVPNListHandler(state *daemon.State) daemon.HandlerFunc {
 	}
 }
 
+// OpenVPNRespondParams contains parameters for openvpn.respond.
+type OpenVPNRespondParams struct {
+	ProfileID string `json:"profile_id"`
+	// Response answers the connection's Challenge: a one-time code, a PIN,
+	// or empty when the challenge does not require one.
+	Response string `json:"response"`
+}
+
+// OpenVPNRespondHandler returns a handler that answers the two-factor
+// challenge an OpenVPN connection is waiting on. The result is the
+// connection's status once answered; the outcome follows on openvpn.state.
+func OpenVPNRespondHandler(state *daemon.State) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		var params OpenVPNRespondParams
+		if err := ctx.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+
+		manager := GetOpenVPNManager(ctx.Logger)
+		if err := manager.Respond(params.ProfileID, params.Response); err != nil {
+			return nil, err
+		}
+		return manager.Status(params.ProfileID)
+	}
+}
+
+// OpenVPNLogsParams contains parameters for openvpn.logs.
+type OpenVPNLogsParams struct {
+	ProfileID string `json:"profile_id"`
+	// Follow keeps the call open: the matching lines, and every new one,
+	// are streamed to the caller as protocol.TopicOpenVPNLog events until
+	// it cancels the call.
+	Follow bool `json:"follow,omitempty"`
+	// Since drops lines from before it.
+	Since *time.Time `json:"since,omitempty"`
+	// Level drops lines less severe than it: info (the default), warning or
+	// error.
+	Level string `json:"level,omitempty"`
+}
+
+// OpenVPNLogsResult is the result of openvpn.logs.
+type OpenVPNLogsResult struct {
+	ProfileID string `json:"profile_id"`
+	// Lines are the buffered lines that pass the filters, oldest first.
+	// Empty when following: the lines were streamed instead.
+	Lines []vpn.OpenVPNLogLine `json:"lines"`
+}
+
+// OpenVPNLogsHandler returns a handler that reads, or follows, a profile's
+// OpenVPN log. The log spans the profile's recent sessions, so it also
+// explains a connection that has already failed.
+func OpenVPNLogsHandler(state *daemon.State) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		var params OpenVPNLogsParams
+		if err := ctx.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		level, err := vpn.ParseLogLevel(params.Level)
+		if err != nil {
+			return nil, err
+		}
+		filter := vpn.OpenVPNLogFilter{Level: level}
+		if params.Since != nil {
+			filter.Since = *params.Since
+		}
+
+		manager := GetOpenVPNManager(ctx.Logger)
+		result := OpenVPNLogsResult{ProfileID: params.ProfileID, Lines: []vpn.OpenVPNLogLine{}}
+		if !params.Follow {
+			result.Lines = manager.Logs(params.ProfileID, filter)
+			return result, nil
+		}
+
+		lines, next, stop := manager.FollowLogs(params.ProfileID, filter)
+		defer stop()
+		for _, line := range lines {
+			if err := ctx.Notify(protocol.TopicOpenVPNLog, line); err != nil {
+				return nil, err
+			}
+		}
+		for {
+			select {
+			case <-ctx.Context.Done():
+				return nil, ctx.Context.Err()
+			case line := <-next:
+				if !filter.Match(line) {
+					continue
+				}
+				if err := ctx.Notify(protocol.TopicOpenVPNLog, line); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+}
+
 // =============================================================================
 // WIREGUARD HANDLERS
 // =============================================================================
 
+// WireGuardInterfaceParams selects a WireGuard interface, for
+// wireguard.disconnect and wireguard.status.
+type WireGuardInterfaceParams struct {
+	InterfaceName string `json:"interface_name"`
+}
+
 // WireGuardConnectHandler returns a handler that brings up a WireGuard interface.
 func WireGuardConnectHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -740,6 +1055,13 @@ func WireGuardConnectHandler(state *daemon.State) daemon.HandlerFunc {
 			ConfigPath:    params.ConfigPath,
 			InterfaceName: result.InterfaceName,
 			IPAddress:     result.IPAddress,
+			StartedAt:     time.Now().Format(time.RFC3339),
+			Owner:         ctx.Owner(),
+		})
+		ctx.Events.Publish(protocol.TopicWireGuardLink, vpn.WireGuardStatusResult{
+			InterfaceName: result.InterfaceName,
+			Status:        vpn.StatusConnected,
+			IPAddress:     result.IPAddress,
 		})
 
 		return result, nil
@@ -749,9 +1071,7 @@ func WireGuardConnectHandler(state *daemon.State) daemon.HandlerFunc {
 // WireGuardDisconnectHandler returns a handler that brings down a WireGuard interface.
 func WireGuardDisconnectHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
-		var params struct {
-			InterfaceName string `json:"interface_name"`
-		}
+		var params WireGuardInterfaceParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
 			return nil, err
 		}
@@ -765,17 +1085,19 @@ func WireGuardDisconnectHandler(state *daemon.State) daemon.HandlerFunc {
 
 		// Update state
 		state.RemoveWireGuardConnection(params.InterfaceName)
+		ctx.Events.Publish(protocol.TopicWireGuardLink, vpn.WireGuardStatusResult{
+			InterfaceName: params.InterfaceName,
+			Status:        vpn.StatusDisconnected,
+		})
 
-		return map[string]bool{"disconnected": true}, nil
+		return DisconnectResult{Disconnected: true}, nil
 	}
 }
 
 // WireGuardStatusHandler returns a handler that reports WireGuard interface status.
 func WireGuardStatusHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
-		var params struct {
-			InterfaceName string `json:"interface_name"`
-		}
+		var params WireGuardInterfaceParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
 			return nil, err
 		}
@@ -18,6 +18,7 @@ import (
 type fakeResolver struct {
 	applyErr     error
 	restoreErr   error
+	verifyErr    error
 	backend      dnsresolver.Backend
 	applyCalls   int
 	restoreCalls int
@@ -25,6 +26,7 @@ type fakeResolver struct {
 
 func (f *fakeResolver) Apply(string, []string, string) error { f.applyCalls++; return f.applyErr }
 func (f *fakeResolver) Restore() error                       { f.restoreCalls++; return f.restoreErr }
+func (f *fakeResolver) Verify() error                        { return f.verifyErr }
 func (f *fakeResolver) Backend() dnsresolver.Backend         { return f.backend }
 
 // fwSpy records the firewall calls the DNS handlers make.
@@ -0,0 +1,445 @@
+package privileged
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// =============================================================================
+// DECLARATIVE SECURITY POSTURE
+// =============================================================================
+//
+// security.apply takes the complete security configuration a client wants in
+// force, applies it, and records it in the daemon State. A reconcile loop then
+// compares the recorded posture with the live system every
+// securityReconcileInterval and re-applies whatever drifted — e.g. another
+// program flushed the firewall or rewrote resolv.conf — publishing a
+// protocol.TopicSecurityDrift event that says what changed.
+//
+// The imperative methods (killswitch.*, dns.*, ipv6.*, tunnel.*) remain. A
+// client calling one of them has taken back control, so the recorded posture
+// is dropped and drift enforcement stops until the next security.apply;
+// otherwise the loop would undo the call.
+
+// SecurityPosture is the desired security configuration accepted by
+// security.apply. A nil section means the feature should be off.
+type SecurityPosture struct {
+	KillSwitch  *KillSwitchEnableParams `json:"kill_switch,omitempty"`
+	DNS         *DNSEnableParams        `json:"dns,omitempty"`
+	IPv6        *IPv6EnableParams       `json:"ipv6,omitempty"`
+	SplitTunnel *TunnelSetupParams      `json:"split_tunnel,omitempty"`
+}
+
+// Feature names used in apply results and drift reports.
+const (
+	FeatureKillSwitch  = "kill_switch"
+	FeatureDNS         = "dns"
+	FeatureIPv6        = "ipv6"
+	FeatureSplitTunnel = "split_tunnel"
+)
+
+// postureFeatures is the order features are applied in: the kill switch first,
+// so nothing leaks while the rest is set up.
+var postureFeatures = []string{FeatureKillSwitch, FeatureDNS, FeatureIPv6, FeatureSplitTunnel}
+
+// PostureDrift is one difference between the desired posture and the system.
+type PostureDrift struct {
+	Feature string `json:"feature"`
+	Reason  string `json:"reason"`
+}
+
+// SecurityDriftEvent is the payload of protocol.TopicSecurityDrift.
+type SecurityDriftEvent struct {
+	Drift []PostureDrift `json:"drift"`
+	// Errors maps a feature to the error re-applying it. Drifted features not
+	// listed here were repaired.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// SecurityApplyResult is the result of security.apply. Features are applied
+// independently: one failing does not stop the others, and the posture is
+// recorded either way so the reconcile loop keeps retrying.
+type SecurityApplyResult struct {
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// SecurityStatusResult is the result of security.status.
+type SecurityStatusResult struct {
+	// Posture is the recorded desired posture, nil when none is active.
+	Posture *SecurityPosture `json:"posture"`
+	// LastCheck is when the reconcile loop last compared posture and system.
+	LastCheck string `json:"last_check,omitempty"`
+	// Drift is what that check found (empty when in sync).
+	Drift []PostureDrift `json:"drift,omitempty"`
+}
+
+// securityReconcileInterval is how often the recorded posture is checked.
+const securityReconcileInterval = 30 * time.Second
+
+// postureTracker serializes everything that changes the security features —
+// the imperative handlers, security.apply and the reconcile loop — so a
+// reconcile pass can never re-enable a feature a client is disabling.
+type postureTracker struct {
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastDrift []PostureDrift
+
+	// ipv6Baseline records which IPv6 mechanisms were in effect at the first
+	// check after an apply. EnableIPv6Protection succeeds with either one, so
+	// only losing a mechanism that was there counts as drift.
+	ipv6Baseline *firewall.IPv6ProtectionStatus
+}
+
+var posture postureTracker
+
+// Seams for drift detection, alongside the rc* seams in reconcile.go.
+var (
+	rcIPv6Status        = firewall.CheckIPv6Protection
+	rcSplitTunnelActive = func() bool {
+		status := GetAppTunnelManager().GetStatus()
+		if !status.Enabled {
+			return false
+		}
+		_, err := os.Stat(status.CgroupPath)
+		return err == nil
+	}
+)
+
+// imperative wraps a handler of the imperative security API, dropping any
+// recorded posture before it runs (see the overview above).
+func imperative(state *daemon.State, h daemon.HandlerFunc) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		posture.mu.Lock()
+		defer posture.mu.Unlock()
+
+		if len(state.GetSecurityPosture()) > 0 {
+			ctx.Logger.Printf("Imperative security call supersedes the security posture; drift enforcement stopped")
+			state.SetSecurityPosture(nil)
+		}
+		return h(ctx)
+	}
+}
+
+// =============================================================================
+// HANDLERS
+// =============================================================================
+
+// SecurityApplyHandler returns a handler that applies a full security posture
+// and records it for drift enforcement.
+func SecurityApplyHandler(state *daemon.State) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		var desired SecurityPosture
+		if err := ctx.UnmarshalParams(&desired); err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(desired)
+		if err != nil {
+			return nil, err
+		}
+
+		posture.mu.Lock()
+		defer posture.mu.Unlock()
+
+		// The policy only saw security.apply; nothing is applied unless it
+		// allows every method the posture would run.
+		for _, method := range postureMethods(state, &desired) {
+			if !ctx.Allows(method) {
+				return nil, fmt.Errorf("%s: %w", method, protocol.ErrUnauthorized)
+			}
+		}
+
+		ctx.Logger.Printf("Applying security posture: kill_switch=%v dns=%v ipv6=%v split_tunnel=%v",
+			desired.KillSwitch != nil, desired.DNS != nil, desired.IPv6 != nil, desired.SplitTunnel != nil)
+
+		result := &SecurityApplyResult{}
+		for _, feature := range postureFeatures {
+			if err := applyFeature(ctx, state, &desired, feature, false); err != nil {
+				ctx.Logger.Printf("Warning: applying %s failed: %v", feature, err)
+				if result.Errors == nil {
+					result.Errors = make(map[string]string)
+				}
+				result.Errors[feature] = err.Error()
+			}
+		}
+
+		state.SetSecurityPosture(raw)
+		posture.ipv6Baseline = nil
+		posture.lastDrift = nil
+
+		return result, nil
+	}
+}
+
+// SecurityStatusHandler returns a handler that reports the recorded posture
+// and the outcome of the last drift check.
+func SecurityStatusHandler(state *daemon.State) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		posture.mu.Lock()
+		defer posture.mu.Unlock()
+
+		result := &SecurityStatusResult{Drift: posture.lastDrift}
+		if !posture.lastCheck.IsZero() {
+			result.LastCheck = posture.lastCheck.Format(time.RFC3339)
+		}
+		if raw := state.GetSecurityPosture(); len(raw) > 0 {
+			var desired SecurityPosture
+			if err := json.Unmarshal(raw, &desired); err != nil {
+				return nil, fmt.Errorf("recorded posture is unreadable: %w", err)
+			}
+			result.Posture = &desired
+		}
+		return result, nil
+	}
+}
+
+// =============================================================================
+// RECONCILE LOOP
+// =============================================================================
+
+// StartSecurityReconciler runs the drift check every securityReconcileInterval
+// until ctx is cancelled. Call once, after the server has started.
+func StartSecurityReconciler(ctx context.Context, state *daemon.State, events *daemon.EventHub, logger *log.Logger) {
+	go func() {
+		ticker := time.NewTicker(securityReconcileInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ReconcileSecurityPosture(ctx, state, events, logger)
+			}
+		}
+	}()
+}
+
+// ReconcileSecurityPosture compares the recorded posture with the system once,
+// re-applies drifted features and publishes a drift event. It returns the
+// drift found, nil when in sync or when no posture is recorded.
+func ReconcileSecurityPosture(ctx context.Context, state *daemon.State, events *daemon.EventHub, logger *log.Logger) []PostureDrift {
+	posture.mu.Lock()
+	defer posture.mu.Unlock()
+
+	raw := state.GetSecurityPosture()
+	if len(raw) == 0 {
+		return nil
+	}
+	var desired SecurityPosture
+	if err := json.Unmarshal(raw, &desired); err != nil {
+		logger.Printf("[security] Ignoring unreadable security posture: %v", err)
+		return nil
+	}
+
+	drift := detectDrift(state, &desired)
+	posture.lastCheck = time.Now()
+	posture.lastDrift = drift
+	if len(drift) == 0 {
+		return nil
+	}
+
+	hctx := &daemon.HandlerContext{Context: ctx, State: state, Events: events, Logger: logger}
+	event := SecurityDriftEvent{Drift: drift}
+	repaired := make(map[string]bool)
+	for _, d := range drift {
+		logger.Printf("[security] Drift in %s: %s", d.Feature, d.Reason)
+		if repaired[d.Feature] {
+			continue
+		}
+		repaired[d.Feature] = true
+
+		if err := applyFeature(hctx, state, &desired, d.Feature, true); err != nil {
+			logger.Printf("[security] Re-applying %s failed: %v", d.Feature, err)
+			if event.Errors == nil {
+				event.Errors = make(map[string]string)
+			}
+			event.Errors[d.Feature] = err.Error()
+		}
+	}
+	posture.ipv6Baseline = nil
+
+	events.Publish(protocol.TopicSecurityDrift, event)
+	return drift
+}
+
+// detectDrift lists the differences between the desired posture and the live
+// system. The caller must hold posture.mu.
+func detectDrift(state *daemon.State, p *SecurityPosture) []PostureDrift {
+	var drift []PostureDrift
+	add := func(feature, reason string) {
+		drift = append(drift, PostureDrift{Feature: feature, Reason: reason})
+	}
+
+	ksActive := rcKillSwitchActive()
+	switch {
+	case p.KillSwitch != nil && !ksActive:
+		add(FeatureKillSwitch, "kill switch firewall rules are missing")
+	case p.KillSwitch == nil && ksActive:
+		add(FeatureKillSwitch, "kill switch rules are present but the posture has it off")
+	}
+
+	dnsActive := rcDNSFirewallActive()
+	switch {
+	case p.DNS != nil && p.DNS.LeakBlocking && p.DNS.VPNInterface != "" && !dnsActive:
+		add(FeatureDNS, "DNS leak-blocking firewall rules are missing")
+	case p.DNS != nil:
+		if err := getDNSResolver().Verify(); err != nil {
+			add(FeatureDNS, "resolver: "+err.Error())
+		}
+	case dnsActive:
+		add(FeatureDNS, "DNS firewall rules are present but the posture has DNS protection off")
+	}
+
+	// Only a desired-on IPv6 posture is enforced: an administrator may disable
+	// IPv6 system-wide independently of us.
+	if p.IPv6 != nil {
+		status := rcIPv6Status()
+		base := posture.ipv6Baseline
+		switch {
+		case !status.Active():
+			add(FeatureIPv6, "IPv6 is no longer blocked")
+		case base != nil && base.Sysctl && !status.Sysctl:
+			add(FeatureIPv6, "net.ipv6.conf.all.disable_ipv6 was reset")
+		case base != nil && base.Firewall && !status.Firewall:
+			add(FeatureIPv6, "IPv6 firewall rules are missing")
+		case base == nil:
+			posture.ipv6Baseline = &status
+		}
+	}
+
+	if p.SplitTunnel != nil && !rcSplitTunnelActive() {
+		add(FeatureSplitTunnel, "split tunnel cgroup or routing is gone")
+	} else if p.SplitTunnel == nil && state.GetSplitTunnel().Enabled {
+		add(FeatureSplitTunnel, "split tunnel is active but the posture has it off")
+	}
+
+	return drift
+}
+
+// postureMethods lists the methods applying p runs, by the same conditions as
+// applyFeature (without a repair). The caller must hold posture.mu.
+func postureMethods(state *daemon.State, p *SecurityPosture) []string {
+	var methods []string
+	switch {
+	case p.KillSwitch != nil:
+		methods = append(methods, "killswitch.enable")
+	case state.GetKillSwitch().Enabled || rcKillSwitchActive():
+		methods = append(methods, "killswitch.disable")
+	}
+	switch {
+	case p.DNS != nil:
+		methods = append(methods, "dns.enable")
+	case state.GetDNSProtection().Enabled || rcDNSFirewallActive():
+		methods = append(methods, "dns.disable")
+	}
+	switch {
+	case p.IPv6 != nil:
+		methods = append(methods, "ipv6.enable")
+	case state.GetIPv6Protection().Enabled:
+		methods = append(methods, "ipv6.disable")
+	}
+	current := state.GetSplitTunnel()
+	if current.Enabled && (p.SplitTunnel == nil || !sameSplitTunnel(current, p.SplitTunnel)) {
+		methods = append(methods, "tunnel.cleanup")
+	}
+	if p.SplitTunnel != nil && !(current.Enabled && sameSplitTunnel(current, p.SplitTunnel)) {
+		methods = append(methods, "tunnel.setup")
+	}
+	return methods
+}
+
+// applyFeature brings one feature in line with the desired posture by running
+// the same operations as the imperative handlers. repair is set when the
+// reconcile loop found the feature drifted, in which case it is rebuilt even
+// if the daemon believes it is already in place. The caller must hold
+// posture.mu.
+func applyFeature(ctx *daemon.HandlerContext, state *daemon.State, p *SecurityPosture, feature string, repair bool) error {
+	switch feature {
+	case FeatureKillSwitch:
+		if p.KillSwitch != nil {
+			return invoke(ctx, "killswitch.enable", killSwitchEnable(state), p.KillSwitch)
+		}
+		if state.GetKillSwitch().Enabled || rcKillSwitchActive() {
+			return invoke(ctx, "killswitch.disable", killSwitchDisable(state), nil)
+		}
+
+	case FeatureDNS:
+		if p.DNS != nil {
+			return invoke(ctx, "dns.enable", dnsEnable(state), p.DNS)
+		}
+		if state.GetDNSProtection().Enabled || rcDNSFirewallActive() {
+			return invoke(ctx, "dns.disable", dnsDisable(state), nil)
+		}
+
+	case FeatureIPv6:
+		if p.IPv6 != nil {
+			return invoke(ctx, "ipv6.enable", ipv6Enable(state), p.IPv6)
+		}
+		if state.GetIPv6Protection().Enabled {
+			return invoke(ctx, "ipv6.disable", ipv6Disable(state), nil)
+		}
+
+	case FeatureSplitTunnel:
+		current := state.GetSplitTunnel()
+		if p.SplitTunnel == nil {
+			if current.Enabled {
+				return invoke(ctx, "tunnel.cleanup", tunnelCleanup(state), nil)
+			}
+			return nil
+		}
+		// Rebuilding an in-place split tunnel evacuates the apps from its
+		// cgroup, so leave it alone unless it drifted or the parameters changed.
+		if current.Enabled {
+			if !repair && sameSplitTunnel(current, p.SplitTunnel) {
+				return nil
+			}
+			if err := invoke(ctx, "tunnel.cleanup", tunnelCleanup(state), nil); err != nil {
+				return err
+			}
+		}
+		return invoke(ctx, "tunnel.setup", tunnelSetup(state), p.SplitTunnel)
+
+	default:
+		return fmt.Errorf("unknown feature %q", feature)
+	}
+	return nil
+}
+
+// sameSplitTunnel reports whether the recorded split tunnel matches params.
+func sameSplitTunnel(st daemon.SplitTunnelState, params *TunnelSetupParams) bool {
+	return st.Mode == params.Mode &&
+		st.VPNIface == params.VPNInterface &&
+		st.VPNGateway == params.VPNGateway &&
+		st.SplitDNS == params.SplitDNSEnabled &&
+		slices.Equal(st.VPNDNS, params.VPNDNS) &&
+		st.SystemDNS == params.SystemDNS &&
+		slices.Equal(st.Apps, params.Apps)
+}
+
+// invoke runs a feature operation on behalf of ctx as if method had been
+// called with params, so validation, state updates and events are exactly
+// those of the imperative API.
+func invoke(ctx *daemon.HandlerContext, method string, h daemon.HandlerFunc, params any) error {
+	var raw json.RawMessage
+	if params != nil {
+		var err error
+		if raw, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+
+	sub := *ctx
+	sub.Request = &protocol.Request{JSONRPC: protocol.JSONRPCVersion, Method: method, Params: raw}
+	_, err := h(&sub)
+	return err
+}
@@ -0,0 +1,271 @@
+package privileged
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// installPostureSeams scripts the live-system probes used by drift detection
+// on top of the Reconcile and DNS seams, and resets the posture tracker.
+func installPostureSeams(t *testing.T, spy *rcSpy, ipv6 firewall.IPv6ProtectionStatus, splitTunnel bool, r *fakeResolver) *fwSpy {
+	t.Helper()
+	installReconcileSeams(t, spy)
+	fw := installDNSSeams(t, r)
+
+	origIPv6, origSplit := rcIPv6Status, rcSplitTunnelActive
+	rcIPv6Status = func() firewall.IPv6ProtectionStatus { return ipv6 }
+	rcSplitTunnelActive = func() bool { return splitTunnel }
+
+	resetTracker := func() {
+		posture.mu.Lock()
+		posture.lastCheck = time.Time{}
+		posture.lastDrift = nil
+		posture.ipv6Baseline = nil
+		posture.mu.Unlock()
+	}
+	resetTracker()
+	t.Cleanup(func() {
+		rcIPv6Status, rcSplitTunnelActive = origIPv6, origSplit
+		resetTracker()
+	})
+	return fw
+}
+
+func setPosture(t *testing.T, state *daemon.State, p SecurityPosture) {
+	t.Helper()
+	raw, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.SetSecurityPosture(raw)
+}
+
+func driftFeatures(drift []PostureDrift) map[string]bool {
+	features := make(map[string]bool)
+	for _, d := range drift {
+		features[d.Feature] = true
+	}
+	return features
+}
+
+func TestDetectDrift(t *testing.T) {
+	dnsPosture := &DNSEnableParams{VPNInterface: "tun0", Servers: []string{"10.8.0.1"}, Mode: "custom", LeakBlocking: true}
+
+	tests := []struct {
+		name      string
+		posture   SecurityPosture
+		spy       rcSpy
+		ipv6      firewall.IPv6ProtectionStatus
+		split     bool
+		verifyErr error
+		want      []string
+	}{
+		{
+			name:    "in sync",
+			posture: SecurityPosture{KillSwitch: &KillSwitchEnableParams{VPNInterface: "tun0"}, DNS: dnsPosture},
+			spy:     rcSpy{ksActive: true, dnsActive: true},
+		},
+		{
+			name:    "flushed kill switch",
+			posture: SecurityPosture{KillSwitch: &KillSwitchEnableParams{VPNInterface: "tun0"}},
+			want:    []string{FeatureKillSwitch},
+		},
+		{
+			name:    "kill switch rules present while posture has it off",
+			posture: SecurityPosture{},
+			spy:     rcSpy{ksActive: true},
+			want:    []string{FeatureKillSwitch},
+		},
+		{
+			name:    "DNS firewall flushed",
+			posture: SecurityPosture{DNS: dnsPosture},
+			want:    []string{FeatureDNS},
+		},
+		{
+			name:      "resolv.conf rewritten",
+			posture:   SecurityPosture{DNS: dnsPosture},
+			spy:       rcSpy{dnsActive: true},
+			verifyErr: errors.New("rewritten"),
+			want:      []string{FeatureDNS},
+		},
+		{
+			name:    "IPv6 unblocked",
+			posture: SecurityPosture{IPv6: &IPv6EnableParams{Mode: "block"}},
+			want:    []string{FeatureIPv6},
+		},
+		{
+			name:    "IPv6 blocked by firewall only",
+			posture: SecurityPosture{IPv6: &IPv6EnableParams{Mode: "block"}},
+			ipv6:    firewall.IPv6ProtectionStatus{Firewall: true},
+		},
+		{
+			name:    "split tunnel gone",
+			posture: SecurityPosture{SplitTunnel: &TunnelSetupParams{Mode: "include", VPNInterface: "tun0"}},
+			want:    []string{FeatureSplitTunnel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spy := tt.spy
+			installPostureSeams(t, &spy, tt.ipv6, tt.split, &fakeResolver{verifyErr: tt.verifyErr})
+
+			posture.mu.Lock()
+			got := driftFeatures(detectDrift(daemon.NewState(), &tt.posture))
+			posture.mu.Unlock()
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("drift = %v, want %v", got, tt.want)
+			}
+			for _, f := range tt.want {
+				if !got[f] {
+					t.Errorf("drift = %v, missing %s", got, f)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectDriftIPv6Baseline(t *testing.T) {
+	both := firewall.IPv6ProtectionStatus{Sysctl: true, Firewall: true}
+	installPostureSeams(t, &rcSpy{}, both, false, &fakeResolver{})
+	p := &SecurityPosture{IPv6: &IPv6EnableParams{Mode: "block"}}
+	state := daemon.NewState()
+
+	posture.mu.Lock()
+	defer posture.mu.Unlock()
+
+	if drift := detectDrift(state, p); len(drift) != 0 {
+		t.Fatalf("first check drift = %v, want none", drift)
+	}
+
+	// Something (e.g. NetworkManager) resets the sysctl; the firewall still
+	// blocks, but a mechanism that was in place is gone.
+	rcIPv6Status = func() firewall.IPv6ProtectionStatus { return firewall.IPv6ProtectionStatus{Firewall: true} }
+	if drift := detectDrift(state, p); !driftFeatures(drift)[FeatureIPv6] {
+		t.Errorf("drift = %v, want the reset sysctl reported", drift)
+	}
+}
+
+func TestReconcileSecurityPostureRepairsDrift(t *testing.T) {
+	fw := installPostureSeams(t, &rcSpy{}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+	state := daemon.NewState()
+	setPosture(t, state, SecurityPosture{
+		DNS: &DNSEnableParams{VPNInterface: "tun0", Servers: []string{"10.8.0.1"}, Mode: "custom", LeakBlocking: true},
+	})
+
+	drift := ReconcileSecurityPosture(context.Background(), state, nil, log.New(io.Discard, "", 0))
+
+	if !driftFeatures(drift)[FeatureDNS] {
+		t.Fatalf("drift = %v, want dns", drift)
+	}
+	if fw.enableDNS != 1 {
+		t.Errorf("DNS firewall re-applied %d times, want 1", fw.enableDNS)
+	}
+	if !state.GetDNSProtection().Enabled {
+		t.Error("repair should record DNS protection as enabled")
+	}
+	if len(state.GetSecurityPosture()) == 0 {
+		t.Error("repair must keep the posture")
+	}
+}
+
+func TestReconcileSecurityPostureWithoutPosture(t *testing.T) {
+	fw := installPostureSeams(t, &rcSpy{ksActive: true}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+
+	if drift := ReconcileSecurityPosture(context.Background(), daemon.NewState(), nil, log.New(io.Discard, "", 0)); drift != nil {
+		t.Errorf("drift = %v, want nil without a posture", drift)
+	}
+	if fw.enableDNS+fw.disableDNS != 0 {
+		t.Error("nothing should be touched without a posture")
+	}
+}
+
+func TestSecurityApplyRecordsPosture(t *testing.T) {
+	fw := installPostureSeams(t, &rcSpy{}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+	state := daemon.NewState()
+
+	desired := SecurityPosture{
+		DNS: &DNSEnableParams{VPNInterface: "tun0", Servers: []string{"10.8.0.1"}, Mode: "custom", LeakBlocking: true},
+	}
+	res, err := SecurityApplyHandler(state)(dnsCtx(t, state, desired))
+	if err != nil {
+		t.Fatalf("security.apply: %v", err)
+	}
+	if errs := res.(*SecurityApplyResult).Errors; len(errs) != 0 {
+		t.Errorf("apply errors = %v, want none", errs)
+	}
+	if fw.enableDNS != 1 {
+		t.Errorf("DNS firewall enabled %d times, want 1", fw.enableDNS)
+	}
+
+	var recorded SecurityPosture
+	if err := json.Unmarshal(state.GetSecurityPosture(), &recorded); err != nil || recorded.DNS == nil {
+		t.Fatalf("recorded posture = %s (%v), want the DNS section", state.GetSecurityPosture(), err)
+	}
+}
+
+func TestSecurityApplyReportsFeatureErrors(t *testing.T) {
+	fw := installPostureSeams(t, &rcSpy{}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+	fw.enableErr = errors.New("iptables boom")
+	state := daemon.NewState()
+
+	desired := SecurityPosture{DNS: &DNSEnableParams{VPNInterface: "tun0", Mode: "custom", LeakBlocking: true}}
+	res, err := SecurityApplyHandler(state)(dnsCtx(t, state, desired))
+	if err != nil {
+		t.Fatalf("security.apply: %v", err)
+	}
+	if _, ok := res.(*SecurityApplyResult).Errors[FeatureDNS]; !ok {
+		t.Errorf("apply errors = %v, want dns", res.(*SecurityApplyResult).Errors)
+	}
+	if len(state.GetSecurityPosture()) == 0 {
+		t.Error("the posture is recorded even when a feature fails, so the loop retries it")
+	}
+}
+
+func TestSecurityApplyChecksMethodPolicy(t *testing.T) {
+	fw := installPostureSeams(t, &rcSpy{}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+	state := daemon.NewState()
+	state.SetIPv6ProtectionEnabled(true)
+
+	// Leaving ipv6 out of the posture turns it off, which runs ipv6.disable.
+	desired := SecurityPosture{
+		DNS: &DNSEnableParams{VPNInterface: "tun0", Servers: []string{"10.8.0.1"}, Mode: "custom", LeakBlocking: true},
+	}
+	ctx := dnsCtx(t, state, desired)
+	ctx.MethodAllowed = func(method string) bool { return !strings.HasPrefix(method, "ipv6.") }
+
+	_, err := SecurityApplyHandler(state)(ctx)
+	if !errors.Is(err, protocol.ErrUnauthorized) {
+		t.Fatalf("error = %v, want ErrUnauthorized", err)
+	}
+	if fw.enableDNS != 0 || !state.GetIPv6Protection().Enabled {
+		t.Error("nothing may be applied when the policy denies a feature")
+	}
+	if len(state.GetSecurityPosture()) != 0 {
+		t.Error("a refused posture must not be recorded")
+	}
+}
+
+func TestImperativeCallDropsPosture(t *testing.T) {
+	installPostureSeams(t, &rcSpy{}, firewall.IPv6ProtectionStatus{}, false, &fakeResolver{})
+	state := daemon.NewState()
+	setPosture(t, state, SecurityPosture{DNS: &DNSEnableParams{Mode: "custom"}})
+
+	if _, err := DNSDisableHandler(state)(dnsCtx(t, state, nil)); err != nil {
+		t.Fatalf("dns.disable: %v", err)
+	}
+	if len(state.GetSecurityPosture()) != 0 {
+		t.Error("an imperative call should drop the recorded posture")
+	}
+}
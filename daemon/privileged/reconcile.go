@@ -0,0 +1,192 @@
+package privileged
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/daemon/privileged/apptunnel"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/daemon/privileged/vpn"
+)
+
+// =============================================================================
+// RESTART RECONCILIATION
+// =============================================================================
+
+// Seams for Reconcile. Production values probe and tear down the real firewall;
+// tests substitute them to exercise the keep/drop/tear-down decisions without
+// root or iptables.
+var (
+	rcKillSwitchActive  = firewall.IsKillSwitchActive
+	rcDisableKillSwitch = firewall.DisableKillSwitch
+	rcDNSFirewallActive = firewall.IsDNSFirewallActive
+	rcGatewayActive     = firewall.IsLANGatewayActive
+	rcDisableGateway    = firewall.DisableLANGateway
+	rcInterfaceExists   = func(name string) bool {
+		_, err := net.InterfaceByName(name)
+		return err == nil
+	}
+)
+
+// Reconcile brings a State restored from a previous daemon instance back in
+// line with the live system. Call it once at startup, before the server starts
+// accepting requests.
+//
+//   - OpenVPN processes and WireGuard interfaces that are still running are
+//     re-adopted, so status and disconnect keep working; the rest are dropped.
+//   - Firewall features (and IPv6 protection) recorded as enabled whose rules
+//     are gone are marked disabled, so the GUI does not claim a protection
+//     that no longer exists.
+//   - Rules still installed for a feature recorded as disabled are orphans left
+//     by a crash between applying them and saving state, and are torn down.
+//     This only happens when state was actually restored: without a state file
+//     there is no record of what this daemon owns.
+//   - Split tunneling whose VPN interface has gone is torn down.
+//
+// A kill switch whose VPN has died is deliberately kept: blocking traffic in
+// that situation is its purpose.
+func Reconcile(state *daemon.State, logger *log.Logger) {
+	restored := state.Restored()
+
+	reconcileOpenVPN(state, logger)
+	reconcileWireGuard(state, logger)
+
+	// Kill switch
+	ks := state.GetKillSwitch()
+	active := rcKillSwitchActive()
+	switch {
+	case ks.Enabled && !active:
+		logger.Printf("[reconcile] Kill switch rules are gone; marking it disabled")
+		state.SetKillSwitchEnabled(false)
+	case !ks.Enabled && active && restored:
+		logger.Printf("[reconcile] Removing orphaned kill switch rules")
+		if err := rcDisableKillSwitch(); err != nil {
+			logger.Printf("[reconcile] Warning: kill switch cleanup had errors: %v", err)
+		}
+	}
+
+	// DNS protection
+	dnsState := state.GetDNSProtection()
+	active = rcDNSFirewallActive()
+	switch {
+	case dnsState.Enabled && !active:
+		logger.Printf("[reconcile] DNS firewall rules are gone; marking DNS protection disabled")
+		state.SetDNSProtectionEnabled(false)
+	case !dnsState.Enabled && active && restored:
+		logger.Printf("[reconcile] Removing orphaned DNS firewall rules")
+		if err := fwDisableDNS(); err != nil {
+			logger.Printf("[reconcile] Warning: DNS firewall cleanup had errors: %v", err)
+		}
+		if err := fwUnblockDoT(); err != nil {
+			logger.Printf("[reconcile] Warning: DoT unblock had errors: %v", err)
+		}
+		if err := getDNSResolver().Restore(); err != nil {
+			logger.Printf("[reconcile] Warning: resolver restore failed: %v", err)
+		}
+	}
+
+	// LAN gateway
+	gw := state.GetLANGateway()
+	active = rcGatewayActive()
+	switch {
+	case gw.Enabled && !active:
+		logger.Printf("[reconcile] LAN gateway rules are gone; marking it disabled")
+		state.SetLANGatewayEnabled(false)
+	case !gw.Enabled && active && restored && gw.WiFiIface != "" && gw.LANNetwork != "":
+		logger.Printf("[reconcile] Removing orphaned LAN gateway rules")
+		if err := rcDisableGateway(firewall.LANGatewayParams{
+			WiFiInterface: gw.WiFiIface,
+			LANNetwork:    gw.LANNetwork,
+		}); err != nil {
+			logger.Printf("[reconcile] Warning: LAN gateway cleanup had errors: %v", err)
+		}
+	}
+
+	// Split tunnel
+	if st := state.GetSplitTunnel(); st.Enabled {
+		reconcileSplitTunnel(state, st, logger)
+	}
+
+	// IPv6 protection. The sysctl backup is part of the persisted state, so
+	// ipv6.disable restores the original values as before; but a reboot resets
+	// the sysctls and drops the rules. An IPv6 block without a record is not
+	// torn down: the user may have disabled IPv6 themselves.
+	if state.GetIPv6Protection().Enabled && !rcIPv6Status().Active() {
+		logger.Printf("[reconcile] IPv6 is no longer blocked; marking IPv6 protection disabled")
+		state.SetIPv6ProtectionEnabled(false)
+	}
+}
+
+// reconcileOpenVPN re-adopts recorded OpenVPN processes that are still running.
+func reconcileOpenVPN(state *daemon.State, logger *log.Logger) {
+	manager := GetOpenVPNManager(logger)
+
+	for _, conn := range state.ListOpenVPNConnections() {
+		startTime, _ := time.Parse(time.RFC3339, conn.StartedAt)
+		err := manager.Adopt(vpn.ProcessInfo{
+			ProfileID:        conn.ProfileID,
+			PID:              conn.PID,
+			StagedConfig:     conn.StagedConfig,
+			ManagementSocket: conn.ManagementSocket,
+			CredentialsFile:  conn.CredentialsFile,
+			LogFile:          conn.LogFile,
+			StartTime:        startTime,
+			Status:           conn.Status,
+			IPAddress:        conn.IPAddress,
+		})
+		if err != nil {
+			logger.Printf("[reconcile] Dropping OpenVPN connection for profile %s: %v", conn.ProfileID, err)
+			state.RemoveOpenVPNConnection(conn.ProfileID)
+			continue
+		}
+		logger.Printf("[reconcile] Re-adopted OpenVPN connection for profile %s (PID %d)", conn.ProfileID, conn.PID)
+	}
+}
+
+// reconcileWireGuard re-adopts recorded WireGuard interfaces that still exist.
+func reconcileWireGuard(state *daemon.State, logger *log.Logger) {
+	manager := GetWireGuardManager(logger)
+
+	for _, conn := range state.ListWireGuardConnections() {
+		startTime, _ := time.Parse(time.RFC3339, conn.StartedAt)
+		if err := manager.Adopt(conn.InterfaceName, startTime); err != nil {
+			logger.Printf("[reconcile] Dropping WireGuard interface %s: %v", conn.InterfaceName, err)
+			state.RemoveWireGuardConnection(conn.InterfaceName)
+			continue
+		}
+		logger.Printf("[reconcile] Re-adopted WireGuard interface %s", conn.InterfaceName)
+	}
+}
+
+// reconcileSplitTunnel re-adopts split tunneling, or tears it down when its VPN
+// interface no longer exists.
+func reconcileSplitTunnel(state *daemon.State, st daemon.SplitTunnelState, logger *log.Logger) {
+	manager := GetAppTunnelManager()
+	if err := manager.Adopt(apptunnel.EnableParams{
+		Mode:            st.Mode,
+		VPNInterface:    st.VPNIface,
+		VPNGateway:      st.VPNGateway,
+		SplitDNSEnabled: st.SplitDNS,
+		VPNDNS:          st.VPNDNS,
+		SystemDNS:       st.SystemDNS,
+	}); err != nil {
+		// State from an older daemon lacks the gateway; without it the rules
+		// cannot be reconstructed, so just stop claiming they are active.
+		logger.Printf("[reconcile] Cannot re-adopt split tunnel: %v", err)
+		state.SetSplitTunnelEnabled(false)
+		return
+	}
+
+	if rcInterfaceExists(st.VPNIface) {
+		logger.Printf("[reconcile] Re-adopted split tunnel on %s", st.VPNIface)
+		return
+	}
+
+	logger.Printf("[reconcile] Split tunnel interface %s is gone; removing its rules", st.VPNIface)
+	if err := manager.Disable(); err != nil {
+		logger.Printf("[reconcile] Warning: split tunnel cleanup had errors: %v", err)
+	}
+	state.SetSplitTunnelEnabled(false)
+}
@@ -0,0 +1,154 @@
+package privileged
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+)
+
+// rcSpy scripts which firewall rules are live and records teardown calls.
+type rcSpy struct {
+	ksActive, dnsActive, gwActive, ipv6Active bool
+	disableKS, disableGW                      int
+}
+
+// installReconcileSeams swaps the Reconcile seams for the test and restores
+// them afterwards. The DNS teardown goes through the DNS-handler seams.
+func installReconcileSeams(t *testing.T, spy *rcSpy) *fwSpy {
+	t.Helper()
+	origKS, origDisKS, origDNS, origGW, origDisGW, origIface, origIPv6 :=
+		rcKillSwitchActive, rcDisableKillSwitch, rcDNSFirewallActive, rcGatewayActive, rcDisableGateway, rcInterfaceExists, rcIPv6Status
+	rcKillSwitchActive = func() bool { return spy.ksActive }
+	rcDisableKillSwitch = func() error { spy.disableKS++; return nil }
+	rcDNSFirewallActive = func() bool { return spy.dnsActive }
+	rcGatewayActive = func() bool { return spy.gwActive }
+	rcDisableGateway = func(firewall.LANGatewayParams) error { spy.disableGW++; return nil }
+	rcInterfaceExists = func(string) bool { return false }
+	rcIPv6Status = func() firewall.IPv6ProtectionStatus { return firewall.IPv6ProtectionStatus{Sysctl: spy.ipv6Active} }
+	t.Cleanup(func() {
+		rcKillSwitchActive, rcDisableKillSwitch, rcDNSFirewallActive, rcGatewayActive, rcDisableGateway, rcInterfaceExists, rcIPv6Status =
+			origKS, origDisKS, origDNS, origGW, origDisGW, origIface, origIPv6
+	})
+	return installDNSSeams(t, &fakeResolver{})
+}
+
+// restoredState returns a State loaded from a file written by a "previous
+// instance" configured by setup.
+func restoredState(t *testing.T, setup func(s *daemon.State)) *daemon.State {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.state")
+	setup(daemon.LoadState(path))
+	s := daemon.LoadState(path)
+	if !s.Restored() {
+		t.Fatal("state was not restored")
+	}
+	return s
+}
+
+func TestReconcileMarksVanishedRulesDisabled(t *testing.T) {
+	spy := &rcSpy{}
+	fw := installReconcileSeams(t, spy)
+
+	state := restoredState(t, func(s *daemon.State) {
+		s.SetKillSwitch(daemon.KillSwitchState{Enabled: true, VPNIface: "tun0"})
+		s.SetDNSProtection(daemon.DNSProtectionState{Enabled: true})
+		s.SetLANGateway(daemon.LANGatewayState{Enabled: true, WiFiIface: "wlan0", LANNetwork: "192.168.1.0/24"})
+		s.SetIPv6Protection(daemon.IPv6ProtectionState{Enabled: true})
+	})
+
+	Reconcile(state, log.New(io.Discard, "", 0))
+
+	if state.GetKillSwitch().Enabled || state.GetDNSProtection().Enabled || state.GetLANGateway().Enabled || state.GetIPv6Protection().Enabled {
+		t.Error("features whose rules are gone should be marked disabled")
+	}
+	if spy.disableKS+spy.disableGW+fw.disableDNS != 0 {
+		t.Error("nothing should be torn down when the rules are already gone")
+	}
+}
+
+func TestReconcileKeepsLiveRules(t *testing.T) {
+	spy := &rcSpy{ksActive: true, dnsActive: true, ipv6Active: true}
+	fw := installReconcileSeams(t, spy)
+
+	state := restoredState(t, func(s *daemon.State) {
+		s.SetKillSwitch(daemon.KillSwitchState{Enabled: true, VPNIface: "tun0"})
+		s.SetDNSProtection(daemon.DNSProtectionState{Enabled: true})
+		s.SetIPv6Protection(daemon.IPv6ProtectionState{Enabled: true})
+	})
+
+	Reconcile(state, log.New(io.Discard, "", 0))
+
+	if !state.GetKillSwitch().Enabled || !state.GetDNSProtection().Enabled || !state.GetIPv6Protection().Enabled {
+		t.Error("features with live rules should stay enabled")
+	}
+	if spy.disableKS != 0 || fw.disableDNS != 0 {
+		t.Error("live rules owned by the state must not be torn down")
+	}
+}
+
+func TestReconcileTearsDownOrphans(t *testing.T) {
+	spy := &rcSpy{ksActive: true, dnsActive: true, gwActive: true}
+	fw := installReconcileSeams(t, spy)
+
+	state := restoredState(t, func(s *daemon.State) {
+		s.SetLANGateway(daemon.LANGatewayState{WiFiIface: "wlan0", LANNetwork: "192.168.1.0/24"})
+	})
+
+	Reconcile(state, log.New(io.Discard, "", 0))
+
+	if spy.disableKS != 1 {
+		t.Errorf("kill switch teardowns = %d, want 1", spy.disableKS)
+	}
+	if fw.disableDNS != 1 || fw.unblockDoT != 1 {
+		t.Errorf("DNS teardown = %d firewall / %d DoT, want 1 / 1", fw.disableDNS, fw.unblockDoT)
+	}
+	if spy.disableGW != 1 {
+		t.Errorf("LAN gateway teardowns = %d, want 1", spy.disableGW)
+	}
+}
+
+func TestReconcileLeavesRulesAloneWithoutStateFile(t *testing.T) {
+	spy := &rcSpy{ksActive: true, dnsActive: true}
+	fw := installReconcileSeams(t, spy)
+
+	// No state file: the rules may belong to someone else.
+	state := daemon.LoadState(filepath.Join(t.TempDir(), "daemon.state"))
+
+	Reconcile(state, log.New(io.Discard, "", 0))
+
+	if spy.disableKS != 0 || fw.disableDNS != 0 {
+		t.Error("rules must not be torn down when no state was restored")
+	}
+}
+
+func TestReconcileDropsDeadConnections(t *testing.T) {
+	installReconcileSeams(t, &rcSpy{})
+
+	state := restoredState(t, func(s *daemon.State) {
+		// A process the previous instance started that has since died: its
+		// PID no longer runs openvpn with a staged config.
+		s.SetOpenVPNConnection("p1", daemon.VPNConnectionState{ProfileID: "p1", Status: "connected", PID: 999999})
+		s.SetWireGuardConnection("wgreconcile9", daemon.VPNConnectionState{
+			ProfileID:     "wgreconcile9",
+			InterfaceName: "wgreconcile9",
+		})
+		// Split tunnel state without the gateway cannot be re-adopted.
+		s.SetSplitTunnel(daemon.SplitTunnelState{Enabled: true, Mode: "include", VPNIface: "tun0"})
+	})
+
+	Reconcile(state, log.New(io.Discard, "", 0))
+
+	if n := len(state.ListOpenVPNConnections()); n != 0 {
+		t.Errorf("OpenVPN connections = %d, want 0", n)
+	}
+	if n := len(state.ListWireGuardConnections()); n != 0 {
+		t.Errorf("WireGuard connections = %d, want 0", n)
+	}
+	if state.GetSplitTunnel().Enabled {
+		t.Error("split tunnel that cannot be re-adopted should be marked disabled")
+	}
+}
@@ -0,0 +1,141 @@
+package privileged
+
+import (
+	"path/filepath"
+
+	"github.com/yllada/vpn-manager/daemon"
+	dnsresolver "github.com/yllada/vpn-manager/daemon/privileged/dns"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/daemon/privileged/tailscale"
+	"github.com/yllada/vpn-manager/daemon/privileged/vpn"
+)
+
+// RegisterHandlers registers all handlers for privileged operations, with the
+// param and result types system.describe publishes.
+func RegisterHandlers(handlers *daemon.HandlerRegistry, state *daemon.State) {
+	// Kill switch handlers
+	handlers.Register("killswitch.enable", KillSwitchEnableHandler(state),
+		daemon.Params(KillSwitchEnableParams{}), daemon.Result(KillSwitchEnableResult{}),
+		daemon.Summary("Block all traffic that does not go through the VPN interface."))
+	handlers.Register("killswitch.disable", KillSwitchDisableHandler(state),
+		daemon.Result(EnabledResult{}), daemon.Summary("Remove the kill switch rules."))
+	handlers.Register("killswitch.status", KillSwitchStatusHandler(state),
+		daemon.Result(KillSwitchStatusResult{}), daemon.Summary("Report the kill switch state."))
+	handlers.Register("killswitch.block_all", KillSwitchBlockAllHandler(state),
+		daemon.Result(KillSwitchEnableResult{}),
+		daemon.Summary("Block all non-local traffic (no VPN on an untrusted network)."))
+
+	// DNS protection handlers
+	handlers.Register("dns.enable", DNSEnableHandler(state),
+		daemon.Params(DNSEnableParams{}), daemon.Result(EnabledResult{}),
+		daemon.Summary("Route DNS through the VPN's servers and block leaks."))
+	handlers.Register("dns.disable", DNSDisableHandler(state),
+		daemon.Result(EnabledResult{}), daemon.Summary("Restore the system DNS configuration."))
+	handlers.Register("dns.status", DNSStatusHandler(state),
+		daemon.Result(DNSStatusResult{}), daemon.Summary("Report the DNS protection state."))
+
+	// IPv6 protection handlers
+	handlers.Register("ipv6.enable", IPv6EnableHandler(state),
+		daemon.Params(IPv6EnableParams{}), daemon.Result(EnabledResult{}),
+		daemon.Summary("Block IPv6 (and optionally WebRTC) leaks."))
+	handlers.Register("ipv6.disable", IPv6DisableHandler(state),
+		daemon.Result(EnabledResult{}), daemon.Summary("Restore the original IPv6 settings."))
+	handlers.Register("ipv6.status", IPv6StatusHandler(state),
+		daemon.Result(daemon.IPv6ProtectionState{}), daemon.Summary("Report the IPv6 protection state."))
+
+	// Declarative security posture (drift-enforced by StartSecurityReconciler)
+	handlers.Register("security.apply", SecurityApplyHandler(state),
+		daemon.Params(SecurityPosture{}), daemon.Result(SecurityApplyResult{}),
+		daemon.Summary("Apply a complete security posture and keep enforcing it."))
+	handlers.Register("security.status", SecurityStatusHandler(state),
+		daemon.Result(SecurityStatusResult{}), daemon.Summary("Report the recorded posture and the last drift check."))
+
+	// Multi-step changes applied all-or-nothing
+	handlers.Register("tx.commit", TxCommitHandler(state),
+		daemon.Params(TxCommitParams{}), daemon.Result(TxCommitResult{}),
+		daemon.Summary("Apply several privileged steps, or none of them."))
+
+	// Split tunnel handlers
+	handlers.Register("tunnel.setup", TunnelSetupHandler(state),
+		daemon.Params(TunnelSetupParams{}), daemon.Result(TunnelSetupResult{}),
+		daemon.Summary("Route selected applications inside or outside the VPN."))
+	handlers.Register("tunnel.cleanup", TunnelCleanupHandler(state),
+		daemon.Result(EnabledResult{}), daemon.Summary("Remove the split tunnel configuration."))
+	handlers.Register("tunnel.status", TunnelStatusHandler(state),
+		daemon.Result(TunnelStatusResult{}), daemon.Summary("Report the split tunnel state."))
+
+	// LAN gateway handlers
+	handlers.Register("gateway.enable", GatewayEnableHandler(state),
+		daemon.Params(GatewayEnableParams{}), daemon.Result(GatewayEnableResult{}),
+		daemon.Summary("Share the Tailscale connection with the local network."))
+	handlers.Register("gateway.disable", GatewayDisableHandler(state),
+		daemon.Result(EnabledResult{}), daemon.Summary("Stop sharing the connection with the local network."))
+	handlers.Register("gateway.status", GatewayStatusHandler(state),
+		daemon.Result(GatewayStatusResult{}), daemon.Summary("Report the LAN gateway state."))
+
+	// OpenVPN handlers
+	handlers.Register("openvpn.connect", OpenVPNConnectHandler(state),
+		daemon.Params(vpn.OpenVPNConnectParams{}), daemon.Result(vpn.OpenVPNConnectResult{}),
+		daemon.Summary("Start an OpenVPN connection for a profile."))
+	handlers.Register("openvpn.disconnect", OpenVPNDisconnectHandler(state),
+		daemon.Params(OpenVPNProfileParams{}), daemon.Result(DisconnectResult{}),
+		daemon.Summary("Stop a profile's OpenVPN connection."))
+	handlers.Register("openvpn.status", OpenVPNStatusHandler(state),
+		daemon.Params(OpenVPNProfileParams{}), daemon.Result(vpn.OpenVPNStatusResult{}),
+		daemon.Summary("Report a profile's OpenVPN connection."))
+	handlers.Register("openvpn.list", OpenVPNListHandler(state),
+		daemon.Result([]vpn.OpenVPNStatusResult{}), daemon.Summary("List the OpenVPN connections."))
+	handlers.Register("openvpn.respond", OpenVPNRespondHandler(state),
+		daemon.Params(OpenVPNRespondParams{}), daemon.Result(vpn.OpenVPNStatusResult{}),
+		daemon.Summary("Answer the two-factor challenge a profile's OpenVPN server asked."))
+	handlers.Register("openvpn.logs", OpenVPNLogsHandler(state),
+		daemon.Params(OpenVPNLogsParams{}), daemon.Result(OpenVPNLogsResult{}),
+		daemon.Summary("Read or follow a profile's OpenVPN log across its recent sessions."))
+
+	// WireGuard handlers
+	handlers.Register("wireguard.connect", WireGuardConnectHandler(state),
+		daemon.Params(vpn.WireGuardConnectParams{}), daemon.Result(vpn.WireGuardConnectResult{}),
+		daemon.Summary("Bring up a WireGuard interface from a config file."))
+	handlers.Register("wireguard.disconnect", WireGuardDisconnectHandler(state),
+		daemon.Params(WireGuardInterfaceParams{}), daemon.Result(DisconnectResult{}),
+		daemon.Summary("Bring down a WireGuard interface."))
+	handlers.Register("wireguard.status", WireGuardStatusHandler(state),
+		daemon.Params(WireGuardInterfaceParams{}), daemon.Result(vpn.WireGuardStatusResult{}),
+		daemon.Summary("Report a WireGuard interface."))
+	handlers.Register("wireguard.list", WireGuardListHandler(state),
+		daemon.Result([]vpn.WireGuardStatusResult{}), daemon.Summary("List the WireGuard interfaces."))
+
+	// Tailscale handlers
+	handlers.Register("tailscale.up", tailscale.UpHandler(state),
+		daemon.Params(tailscale.UpParams{}), daemon.Result(tailscale.UpResult{}),
+		daemon.Summary("Run tailscale up."))
+	handlers.Register("tailscale.down", tailscale.DownHandler(state),
+		daemon.Result(tailscale.SuccessResult{}), daemon.Summary("Run tailscale down."))
+	handlers.Register("tailscale.set", tailscale.SetHandler(state),
+		daemon.Params(tailscale.SetParams{}), daemon.Result(tailscale.SetResult{}),
+		daemon.Summary("Change Tailscale preferences (unset fields are left alone)."))
+	handlers.Register("tailscale.login", tailscale.LoginHandler(state),
+		daemon.Params(tailscale.LoginParams{}), daemon.Result(tailscale.LoginResult{}),
+		daemon.Summary("Run tailscale login; may return a URL to authenticate in a browser."))
+	handlers.Register("tailscale.logout", tailscale.LogoutHandler(state),
+		daemon.Result(tailscale.SuccessResult{}), daemon.Summary("Run tailscale logout."))
+	handlers.Register("tailscale.set_operator", tailscale.SetOperatorHandler(state),
+		daemon.Params(tailscale.SetOperatorParams{}), daemon.Result(tailscale.SetOperatorResult{}),
+		daemon.Summary("Let a user control tailscaled without root."))
+	handlers.Register("taildrop.send", tailscale.TaildropSendHandler(state),
+		daemon.Params(tailscale.TaildropSendParams{}), daemon.Result(tailscale.TaildropSendResult{}),
+		daemon.Summary("Send a file to a tailnet device."))
+}
+
+// SetRoot moves every host file the privileged handlers write (runtime files,
+// the transaction journal, resolver files, sysctls) under root, keeping their
+// absolute paths. SetRoot("/") restores the defaults. The daemon never calls
+// it; daemontest does, together with a fake sysexec.Executor, so the handlers
+// can run in a test without changing the host. Split tunnel cgroups are not
+// moved.
+func SetRoot(root string) {
+	txJournalPath = filepath.Join(root, DefaultTxJournalPath)
+	vpn.SetRoot(root)
+	dnsresolver.SetRoot(root)
+	firewall.SetRoot(root)
+}
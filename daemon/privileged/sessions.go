@@ -0,0 +1,180 @@
+package privileged
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/yllada/vpn-manager/daemon"
+)
+
+// =============================================================================
+// SESSION POLICY
+// =============================================================================
+//
+// Tunnels, the split tunnel and the LAN gateway change how the whole machine
+// routes, not just their owner's programs. When the user who set them up logs
+// out, or another user takes over the seat, they would otherwise keep
+// carrying the next user's traffic. The handlers record a daemon.SessionOwner
+// with each of them, and the session watcher applies the configured policy to
+// what the departed owner left:
+//
+//   - disconnect: take it all down, as the owner's own disconnect would. If no
+//     tunnel is left, the kill switch, DNS and IPv6 protection go too, so the
+//     next user is not cut off by protections for a tunnel that is gone.
+//   - killswitch: take the tunnels, split tunnel and gateway down but keep the
+//     kill switch and protections, so nothing leaves the machine until someone
+//     connects again or turns them off.
+//   - keep: leave everything up, as before owners were recorded. This is the
+//     default: a tunnel brought up over SSH for a headless machine must not
+//     drop when that SSH session logs out. The other policies are opt-in.
+//
+// An owner has left when its session ends, when its user's last session ends
+// (which covers the session agent, running outside any session), or when
+// another user's session becomes active on a seat. Root-owned things outside
+// a session, and things recorded before owners were, are never touched.
+
+// SessionPolicy says what happens to what a user set up once they have left.
+type SessionPolicy string
+
+const (
+	SessionPolicyDisconnect SessionPolicy = "disconnect"
+	SessionPolicyKillSwitch SessionPolicy = "killswitch"
+	SessionPolicyKeep       SessionPolicy = "keep"
+)
+
+// DefaultSessionPolicy is the policy when none is configured.
+const DefaultSessionPolicy = SessionPolicyKeep
+
+// ParseSessionPolicy validates a policy name.
+func ParseSessionPolicy(s string) (SessionPolicy, error) {
+	switch p := SessionPolicy(s); p {
+	case SessionPolicyDisconnect, SessionPolicyKillSwitch, SessionPolicyKeep:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown session policy %q (want disconnect, killswitch or keep)", s)
+}
+
+// sessionOps are the methods a policy may call. They are the imperative
+// handlers, so state, events and any security posture are updated exactly as
+// if the owner had made the calls.
+var sessionOps = map[string]func(*daemon.State) daemon.HandlerFunc{
+	"openvpn.disconnect":   OpenVPNDisconnectHandler,
+	"wireguard.disconnect": WireGuardDisconnectHandler,
+	"tunnel.cleanup":       TunnelCleanupHandler,
+	"gateway.disable":      GatewayDisableHandler,
+	"killswitch.disable":   KillSwitchDisableHandler,
+	"dns.disable":          DNSDisableHandler,
+	"ipv6.disable":         IPv6DisableHandler,
+}
+
+// StartSessionWatcher applies policy to every logind change until ctx is
+// cancelled. Without logind (containers, no system bus) it logs and returns:
+// nothing is torn down then. Call once, after the server has started.
+func StartSessionWatcher(ctx context.Context, state *daemon.State, events *daemon.EventHub, logger *log.Logger, policy SessionPolicy) {
+	if policy == SessionPolicyKeep {
+		logger.Printf("Session policy: keep, connections outlive their user's session")
+		return
+	}
+	err := daemon.WatchLogind(ctx, func(event daemon.SessionEvent) {
+		ApplySessionPolicy(ctx, state, events, logger, policy, event)
+	})
+	if err != nil {
+		logger.Printf("WARN: session policy not enforced, logind not available: %v", err)
+		return
+	}
+	logger.Printf("Session policy: %s", policy)
+}
+
+// ApplySessionPolicy takes down what event's departed owner left, according to
+// policy. Every step is attempted even if an earlier one fails.
+func ApplySessionPolicy(ctx context.Context, state *daemon.State, events *daemon.EventHub, logger *log.Logger, policy SessionPolicy, event daemon.SessionEvent) {
+	steps := sessionSteps(state, policy, event)
+	if len(steps) == 0 {
+		return
+	}
+	logger.Printf("[session] %s: applying the %s policy", event, policy)
+
+	hctx := &daemon.HandlerContext{Context: ctx, State: state, Events: events, Logger: logger}
+	for _, step := range steps {
+		if err := invokeRaw(hctx, step.Method, sessionOps[step.Method](state), step.Params); err != nil {
+			logger.Printf("[session] %s failed: %v", step.Method, err)
+		}
+	}
+}
+
+// sessionSteps lists the calls policy makes for event, in order: tunnels
+// first, then what was routed through them, then the protections.
+func sessionSteps(state *daemon.State, policy SessionPolicy, event daemon.SessionEvent) []TxStep {
+	if policy == SessionPolicyKeep {
+		return nil
+	}
+
+	var steps []TxStep
+	dropped, remaining := 0, 0
+
+	openvpn := state.ListOpenVPNConnections()
+	sort.Slice(openvpn, func(i, j int) bool { return openvpn[i].ProfileID < openvpn[j].ProfileID })
+	for _, c := range openvpn {
+		if !ownerLeft(c.Owner, event) {
+			remaining++
+			continue
+		}
+		dropped++
+		steps = append(steps, txStep("openvpn.disconnect", OpenVPNProfileParams{ProfileID: c.ProfileID}))
+	}
+
+	wireguard := state.ListWireGuardConnections()
+	sort.Slice(wireguard, func(i, j int) bool { return wireguard[i].InterfaceName < wireguard[j].InterfaceName })
+	for _, c := range wireguard {
+		if !ownerLeft(c.Owner, event) {
+			remaining++
+			continue
+		}
+		dropped++
+		steps = append(steps, txStep("wireguard.disconnect", WireGuardInterfaceParams{InterfaceName: c.InterfaceName}))
+	}
+
+	if st := state.GetSplitTunnel(); st.Enabled && ownerLeft(st.Owner, event) {
+		steps = append(steps, txStep("tunnel.cleanup", nil))
+	}
+	if gw := state.GetLANGateway(); gw.Enabled && ownerLeft(gw.Owner, event) {
+		steps = append(steps, txStep("gateway.disable", nil))
+	}
+
+	// The protections guard the tunnels: they go once the last tunnel does,
+	// and only then.
+	if policy != SessionPolicyDisconnect || dropped == 0 || remaining > 0 {
+		return steps
+	}
+	if state.GetKillSwitch().Enabled {
+		steps = append(steps, txStep("killswitch.disable", nil))
+	}
+	if state.GetDNSProtection().Enabled {
+		steps = append(steps, txStep("dns.disable", nil))
+	}
+	if state.GetIPv6Protection().Enabled {
+		steps = append(steps, txStep("ipv6.disable", nil))
+	}
+	return steps
+}
+
+// ownerLeft reports whether event means owner is gone or no longer in front of
+// the machine.
+func ownerLeft(owner *daemon.SessionOwner, event daemon.SessionEvent) bool {
+	if owner == nil {
+		return false
+	}
+	switch event.Kind {
+	case daemon.SessionRemoved:
+		return owner.Session != "" && owner.Session == event.Session
+	case daemon.UserRemoved:
+		return owner.UID != 0 && owner.UID == event.UID
+	case daemon.SeatSwitched:
+		// Any other user's tunnel carries the new user's traffic, wherever
+		// it was started from.
+		return owner.UID != 0 && owner.UID != event.UID
+	}
+	return false
+}
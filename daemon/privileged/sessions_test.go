@@ -0,0 +1,161 @@
+package privileged
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon"
+)
+
+func TestParseSessionPolicy(t *testing.T) {
+	for _, name := range []string{"disconnect", "killswitch", "keep"} {
+		if p, err := ParseSessionPolicy(name); err != nil || string(p) != name {
+			t.Errorf("ParseSessionPolicy(%q) = %q, %v", name, p, err)
+		}
+	}
+	if _, err := ParseSessionPolicy("logout"); err == nil {
+		t.Error("an unknown policy should be rejected")
+	}
+}
+
+func TestOwnerLeft(t *testing.T) {
+	alice := &daemon.SessionOwner{UID: 1000, Session: "3", Seat: "seat0"}
+	aliceAgent := &daemon.SessionOwner{UID: 1000}
+	rootInSession := &daemon.SessionOwner{UID: 0, Session: "3"}
+	rootService := &daemon.SessionOwner{UID: 0}
+
+	tests := []struct {
+		name  string
+		owner *daemon.SessionOwner
+		event daemon.SessionEvent
+		want  bool
+	}{
+		{"own session ended", alice, daemon.SessionEvent{Kind: daemon.SessionRemoved, Session: "3"}, true},
+		{"other session ended", alice, daemon.SessionEvent{Kind: daemon.SessionRemoved, Session: "4"}, false},
+		{"agent outlives a session", aliceAgent, daemon.SessionEvent{Kind: daemon.SessionRemoved, Session: "3"}, false},
+		{"user logged out", aliceAgent, daemon.SessionEvent{Kind: daemon.UserRemoved, UID: 1000}, true},
+		{"other user logged out", alice, daemon.SessionEvent{Kind: daemon.UserRemoved, UID: 1001}, false},
+		{"seat taken by another user", alice, daemon.SessionEvent{Kind: daemon.SeatSwitched, UID: 1001}, true},
+		{"agent when seat taken", aliceAgent, daemon.SessionEvent{Kind: daemon.SeatSwitched, UID: 1001}, true},
+		{"back to own session", alice, daemon.SessionEvent{Kind: daemon.SeatSwitched, UID: 1000}, false},
+		{"sudo in an ended session", rootInSession, daemon.SessionEvent{Kind: daemon.SessionRemoved, Session: "3"}, true},
+		{"root service on seat switch", rootService, daemon.SessionEvent{Kind: daemon.SeatSwitched, UID: 1001}, false},
+		{"no owner recorded", nil, daemon.SessionEvent{Kind: daemon.UserRemoved, UID: 1000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownerLeft(tt.owner, tt.event); got != tt.want {
+				t.Errorf("ownerLeft() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func stepMethods(steps []TxStep) []string {
+	methods := make([]string, len(steps))
+	for i, s := range steps {
+		methods[i] = s.Method
+	}
+	return methods
+}
+
+func TestSessionSteps(t *testing.T) {
+	alice := &daemon.SessionOwner{UID: 1000, Session: "3"}
+	bob := &daemon.SessionOwner{UID: 1001, Session: "5"}
+	loggedOut := daemon.SessionEvent{Kind: daemon.SessionRemoved, Session: "3"}
+
+	newState := func() *daemon.State {
+		state := daemon.NewState()
+		state.SetOpenVPNConnection("work", daemon.VPNConnectionState{ProfileID: "work", Owner: alice})
+		state.SetWireGuardConnection("wg0", daemon.VPNConnectionState{ProfileID: "wg0", InterfaceName: "wg0", Owner: alice})
+		state.SetSplitTunnel(daemon.SplitTunnelState{Enabled: true, Owner: alice})
+		state.SetLANGateway(daemon.LANGatewayState{Enabled: true, Owner: alice})
+		state.SetKillSwitch(daemon.KillSwitchState{Enabled: true, VPNIface: "tun0"})
+		state.SetDNSProtection(daemon.DNSProtectionState{Enabled: true})
+		return state
+	}
+
+	tests := []struct {
+		name   string
+		policy SessionPolicy
+		setup  func(*daemon.State)
+		want   []string
+	}{
+		{
+			name:   "disconnect takes everything down",
+			policy: SessionPolicyDisconnect,
+			want: []string{"openvpn.disconnect", "wireguard.disconnect", "tunnel.cleanup", "gateway.disable",
+				"killswitch.disable", "dns.disable"},
+		},
+		{
+			name:   "killswitch keeps the protections",
+			policy: SessionPolicyKillSwitch,
+			want:   []string{"openvpn.disconnect", "wireguard.disconnect", "tunnel.cleanup", "gateway.disable"},
+		},
+		{
+			name:   "keep does nothing",
+			policy: SessionPolicyKeep,
+		},
+		{
+			name:   "another user's tunnel keeps the protections",
+			policy: SessionPolicyDisconnect,
+			setup: func(s *daemon.State) {
+				s.SetOpenVPNConnection("home", daemon.VPNConnectionState{ProfileID: "home", Owner: bob})
+			},
+			want: []string{"openvpn.disconnect", "wireguard.disconnect", "tunnel.cleanup", "gateway.disable"},
+		},
+		{
+			name:   "protections without a departed tunnel stay",
+			policy: SessionPolicyDisconnect,
+			setup: func(s *daemon.State) {
+				s.RemoveOpenVPNConnection("work")
+				s.RemoveWireGuardConnection("wg0")
+			},
+			want: []string{"tunnel.cleanup", "gateway.disable"},
+		},
+		{
+			name:   "disabled features are not touched",
+			policy: SessionPolicyKillSwitch,
+			setup: func(s *daemon.State) {
+				s.SetSplitTunnelEnabled(false)
+				s.SetLANGatewayEnabled(false)
+			},
+			want: []string{"openvpn.disconnect", "wireguard.disconnect"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newState()
+			if tt.setup != nil {
+				tt.setup(state)
+			}
+			got := stepMethods(sessionSteps(state, tt.policy, loggedOut))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("steps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionStepsParams(t *testing.T) {
+	state := daemon.NewState()
+	owner := &daemon.SessionOwner{UID: 1000}
+	state.SetOpenVPNConnection("work", daemon.VPNConnectionState{ProfileID: "work", Owner: owner})
+	state.SetWireGuardConnection("wg1", daemon.VPNConnectionState{ProfileID: "wg1", InterfaceName: "wg1", Owner: owner})
+
+	steps := sessionSteps(state, SessionPolicyKillSwitch, daemon.SessionEvent{Kind: daemon.UserRemoved, UID: 1000})
+	if len(steps) != 2 {
+		t.Fatalf("steps = %v, want two disconnects", stepMethods(steps))
+	}
+	if got := string(steps[0].Params); got != `{"profile_id":"work"}` {
+		t.Errorf("openvpn.disconnect params = %s", got)
+	}
+	if got := string(steps[1].Params); got != `{"interface_name":"wg1"}` {
+		t.Errorf("wireguard.disconnect params = %s", got)
+	}
+	for _, step := range steps {
+		if sessionOps[step.Method] == nil {
+			t.Errorf("%s has no handler in sessionOps", step.Method)
+		}
+	}
+}
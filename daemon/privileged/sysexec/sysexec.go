@@ -0,0 +1,81 @@
+// Package sysexec is where the privileged daemon starts external commands
+// (iptables, nft, ip, wg, resolvectl, openvpn, ...). Every command goes through
+// the process-wide Executor, so a test can install one that records the
+// invocations and answers with scripted output instead of changing the host
+// (see daemon/daemontest). The daemon itself never replaces the default, which
+// runs the real programs.
+package sysexec
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// Executor creates the commands the daemon runs.
+type Executor interface {
+	// LookPath reports where a program is installed, like exec.LookPath.
+	LookPath(file string) (string, error)
+
+	// CommandContext returns the command for name and args, like
+	// exec.CommandContext. Callers use the result as usual (pipes, Start,
+	// Wait, Process.Kill), so an Executor that wants to fake a program must
+	// still return a real process.
+	CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd
+}
+
+// hostExecutor runs the programs installed on the host.
+type hostExecutor struct{}
+
+func (hostExecutor) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (hostExecutor) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+var (
+	mu       sync.RWMutex
+	executor Executor = hostExecutor{}
+)
+
+// SetExecutor installs e for every command started from now on and returns a
+// function that puts the previous executor back. A nil e restores the host.
+func SetExecutor(e Executor) (restore func()) {
+	if e == nil {
+		e = hostExecutor{}
+	}
+	mu.Lock()
+	prev := executor
+	executor = e
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		executor = prev
+		mu.Unlock()
+	}
+}
+
+func current() Executor {
+	mu.RLock()
+	defer mu.RUnlock()
+	return executor
+}
+
+// LookPath searches for file through the current executor.
+func LookPath(file string) (string, error) {
+	return current().LookPath(file)
+}
+
+// Command returns the command for name and args. Use it for processes that
+// must outlive the request that starts them (openvpn); everything else should
+// prefer CommandContext.
+func Command(name string, args ...string) *exec.Cmd {
+	return current().CommandContext(context.Background(), name, args...)
+}
+
+// CommandContext returns the command for name and args, killed when ctx is done.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return current().CommandContext(ctx, name, args...)
+}
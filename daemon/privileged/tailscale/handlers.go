@@ -2,13 +2,35 @@
 package tailscale
 
 import (
+	"context"
+	"time"
+
 	"github.com/yllada/vpn-manager/daemon"
 )
 
+// rollbackTimeout bounds the commands that undo a cancelled tailscale.up.
+const rollbackTimeout = 15 * time.Second
+
 // =============================================================================
 // DAEMON HANDLERS
 // =============================================================================
 
+// SuccessResult is the result of tailscale.down and tailscale.logout.
+type SuccessResult struct {
+	Success bool `json:"success"`
+}
+
+// SetOperatorParams contains parameters for tailscale.set_operator.
+type SetOperatorParams struct {
+	Username string `json:"username"`
+}
+
+// SetOperatorResult is the result of tailscale.set_operator.
+type SetOperatorResult struct {
+	Success  bool   `json:"success"`
+	Operator string `json:"operator"`
+}
+
 // UpHandler returns a handler that runs tailscale up.
 func UpHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -28,7 +50,12 @@ func UpHandler(state *daemon.State) daemon.HandlerFunc {
 			return nil, err
 		}
 
+		before := state.GetTailscale()
 		result, err := manager.Up(ctx.Context, params)
+		if ctx.Context.Err() != nil {
+			rollbackUp(ctx, manager, before)
+			return nil, ctx.Context.Err()
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -45,6 +72,27 @@ func UpHandler(state *daemon.State) daemon.HandlerFunc {
 	}
 }
 
+// rollbackUp undoes a tailscale up whose request was cancelled or timed out.
+// Killing the CLI does not stop tailscaled, which may already have applied
+// the new preferences, so put back what the daemon last knew: down if it was
+// not connected, otherwise the previous exit node.
+func rollbackUp(ctx *daemon.HandlerContext, manager *Manager, before daemon.TailscaleState) {
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx.Context), rollbackTimeout)
+	defer cancel()
+
+	ctx.Logger.Printf("tailscale up cancelled, rolling back")
+	var err error
+	if !before.Connected {
+		err = manager.Down(rctx)
+	} else {
+		exitNode, allowLAN := before.ExitNode, before.ExitNodeAllowLANAccess
+		_, err = manager.Set(rctx, SetParams{ExitNode: &exitNode, ExitNodeAllowLANAccess: &allowLAN})
+	}
+	if err != nil {
+		ctx.Logger.Printf("Rollback of cancelled tailscale up failed: %v", err)
+	}
+}
+
 // DownHandler returns a handler that runs tailscale down.
 func DownHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
@@ -62,7 +110,7 @@ func DownHandler(state *daemon.State) daemon.HandlerFunc {
 		// Update state
 		state.SetTailscaleConnected(false)
 
-		return map[string]bool{"success": true}, nil
+		return SuccessResult{Success: true}, nil
 	}
 }
 
@@ -155,16 +203,14 @@ func LogoutHandler(state *daemon.State) daemon.HandlerFunc {
 		// Update state
 		state.SetTailscale(daemon.TailscaleState{})
 
-		return map[string]bool{"success": true}, nil
+		return SuccessResult{Success: true}, nil
 	}
 }
 
 // SetOperatorHandler returns a handler that sets the tailscale operator.
 func SetOperatorHandler(state *daemon.State) daemon.HandlerFunc {
 	return func(ctx *daemon.HandlerContext) (any, error) {
-		var params struct {
-			Username string `json:"username"`
-		}
+		var params SetOperatorParams
 		if err := ctx.UnmarshalParams(&params); err != nil {
 			return nil, err
 		}
@@ -180,10 +226,7 @@ func SetOperatorHandler(state *daemon.State) daemon.HandlerFunc {
 			return nil, err
 		}
 
-		return map[string]any{
-			"success":  true,
-			"operator": params.Username,
-		}, nil
+		return SetOperatorResult{Success: true, Operator: params.Username}, nil
 	}
 }
 
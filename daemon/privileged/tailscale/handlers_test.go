@@ -2,9 +2,17 @@ package tailscale
 
 import (
 	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // TestTaildropSendParams_Validation tests TaildropSendParams validation.
@@ -109,3 +117,71 @@ func TestTaildropSendHandler(t *testing.T) {
 	// Full integration testing would require mock daemon context
 	// For now, we verify the handler can be created
 }
+
+// fakeTailscale puts a tailscale script on PATH that appends its arguments to
+// a log and, for "up", hangs until it is killed. It returns the log path.
+func fakeTailscale(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	script := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n[ \"$1\" = up ] && exec sleep 30\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "tailscale"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+":/usr/bin:/bin")
+	return logPath
+}
+
+func runCancelledUp(t *testing.T, state *daemon.State) (any, error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	req, _ := protocol.NewRequest(1, "tailscale.up", UpParams{ExitNode: "node-b"})
+	return UpHandler(state)(&daemon.HandlerContext{
+		Context: ctx,
+		Request: req,
+		State:   state,
+		Logger:  log.New(io.Discard, "", 0),
+	})
+}
+
+// TestUpHandlerRollsBackWhenCancelled checks that a cancelled tailscale.up
+// takes Tailscale down again when it was not connected before.
+func TestUpHandlerRollsBackWhenCancelled(t *testing.T) {
+	logPath := fakeTailscale(t)
+	state := daemon.NewState()
+
+	result, err := runCancelledUp(t, state)
+	if !errors.Is(err, context.Canceled) || result != nil {
+		t.Fatalf("result, err = %v, %v; want nil, context.Canceled", result, err)
+	}
+
+	calls, _ := os.ReadFile(logPath)
+	if want := "up --exit-node=node-b\ndown\n"; string(calls) != want {
+		t.Errorf("tailscale calls = %q, want %q", calls, want)
+	}
+	if state.GetTailscale().Connected {
+		t.Error("a cancelled up must not be recorded as connected")
+	}
+}
+
+// TestUpHandlerRestoresExitNodeWhenCancelled checks that cancelling up on an
+// already connected Tailscale restores the previous exit node instead.
+func TestUpHandlerRestoresExitNodeWhenCancelled(t *testing.T) {
+	logPath := fakeTailscale(t)
+	state := daemon.NewState()
+	state.SetTailscale(daemon.TailscaleState{Connected: true, ExitNode: "node-a"})
+
+	if _, err := runCancelledUp(t, state); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	calls, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(calls), "set --exit-node=node-a --exit-node-allow-lan-access=false") {
+		t.Errorf("tailscale calls = %q, want the previous exit node restored", calls)
+	}
+	if got := state.GetTailscale().ExitNode; got != "node-a" {
+		t.Errorf("state exit node = %q, want node-a", got)
+	}
+}
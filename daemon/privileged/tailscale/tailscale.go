@@ -8,13 +8,13 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 	"github.com/yllada/vpn-manager/internal/paths"
 )
@@ -80,7 +80,7 @@ func NewManager() (*Manager, error) {
 // findBinary locates the tailscale binary.
 func findBinary() (string, error) {
 	// Check PATH first
-	if path, err := exec.LookPath("tailscale"); err == nil {
+	if path, err := sysexec.LookPath("tailscale"); err == nil {
 		return path, nil
 	}
 
@@ -212,7 +212,7 @@ func (m *Manager) Up(ctx context.Context, params UpParams) (*UpResult, error) {
 		args = append(args, "--operator="+params.Operator)
 	}
 
-	cmd := exec.CommandContext(ctx, m.binaryPath, args...)
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("tailscale up failed: %w: %s", err, string(output))
@@ -230,7 +230,7 @@ func (m *Manager) Up(ctx context.Context, params UpParams) (*UpResult, error) {
 
 // Down runs tailscale down.
 func (m *Manager) Down(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, m.binaryPath, "down")
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, "down")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tailscale down failed: %w: %s", err, string(output))
@@ -324,7 +324,7 @@ func (m *Manager) Set(ctx context.Context, params SetParams) (*SetResult, error)
 		return &SetResult{Success: true, Output: "no settings to apply"}, nil
 	}
 
-	cmd := exec.CommandContext(ctx, m.binaryPath, args...)
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("tailscale set failed: %w: %s", err, string(output))
@@ -381,7 +381,7 @@ func (m *Manager) Login(ctx context.Context, params LoginParams) (*LoginResult,
 		args = append(args, "--login-server="+params.LoginServer)
 	}
 
-	cmd := exec.CommandContext(ctx, m.binaryPath, args...)
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, args...)
 
 	// Use pipes to capture output in real-time (for auth URL)
 	stdout, err := cmd.StdoutPipe()
@@ -445,7 +445,7 @@ func (m *Manager) Login(ctx context.Context, params LoginParams) (*LoginResult,
 
 // Logout runs tailscale logout.
 func (m *Manager) Logout(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, m.binaryPath, "logout")
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, "logout")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tailscale logout failed: %w: %s", err, string(output))
@@ -466,7 +466,7 @@ func (m *Manager) SetOperator(ctx context.Context, username string) error {
 		return fmt.Errorf("username: %w", err)
 	}
 
-	cmd := exec.CommandContext(ctx, m.binaryPath, "set", "--operator="+username)
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, "set", "--operator="+username)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("tailscale set operator failed: %w: %s", err, string(output))
@@ -574,7 +574,7 @@ func (m *Manager) SendFile(ctx context.Context, params TaildropSendParams, calle
 	}
 
 	// tailscale file cp <staged-name> <target>: — fd 3 in the child is our file.
-	cmd := exec.CommandContext(ctx, m.binaryPath, "file", "cp", linkPath, params.Target+":")
+	cmd := sysexec.CommandContext(ctx, m.binaryPath, "file", "cp", linkPath, params.Target+":")
 	cmd.ExtraFiles = []*os.File{f}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,316 @@
+package privileged
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/internal/atomicfile"
+	"github.com/yllada/vpn-manager/internal/paths"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// =============================================================================
+// TRANSACTIONS
+// =============================================================================
+//
+// Connecting with full protection takes several privileged steps (kill switch,
+// DNS, IPv6, split tunnel). Sent as separate RPCs, a failure halfway leaves the
+// machine in a mixed state. tx.commit runs them as one unit: before a step
+// first touches a feature it registers an undo that restores the feature as it
+// was, and if any step fails the undos run in reverse order.
+//
+// The undos are written to a journal on disk before the step they cover runs,
+// so a transaction interrupted by a crash is rolled back by RecoverTransaction
+// when the daemon starts again. The journal is removed once the transaction
+// commits or has been rolled back.
+
+// DefaultTxJournalPath is where an in-flight transaction is journaled.
+const DefaultTxJournalPath = paths.StateDir + "/tx.journal"
+
+// txJournalPath is a seam for tests.
+var txJournalPath = DefaultTxJournalPath
+
+// TxStep is one privileged call in a transaction.
+type TxStep struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// TxCommitParams contains parameters for tx.commit.
+type TxCommitParams struct {
+	Steps []TxStep `json:"steps"`
+}
+
+// TxCommitResult is the result of a successful tx.commit.
+type TxCommitResult struct {
+	Applied int `json:"applied"`
+}
+
+// txOp describes a method that may appear in a transaction: the operation it
+// runs and the feature whose prior state its undo restores.
+type txOp struct {
+	run     func(*daemon.State) daemon.HandlerFunc
+	feature string
+}
+
+// txOps lists the methods allowed in a transaction. Every one changes exactly
+// one feature whose state is fully recorded in daemon.State, which is what
+// makes it undoable.
+var txOps = map[string]txOp{
+	"killswitch.enable":    {killSwitchEnable, FeatureKillSwitch},
+	"killswitch.block_all": {killSwitchBlockAll, FeatureKillSwitch},
+	"killswitch.disable":   {killSwitchDisable, FeatureKillSwitch},
+	"dns.enable":           {dnsEnable, FeatureDNS},
+	"dns.disable":          {dnsDisable, FeatureDNS},
+	"ipv6.enable":          {ipv6Enable, FeatureIPv6},
+	"ipv6.disable":         {ipv6Disable, FeatureIPv6},
+	"tunnel.setup":         {tunnelSetup, FeatureSplitTunnel},
+	"tunnel.cleanup":       {tunnelCleanup, FeatureSplitTunnel},
+}
+
+// txUndo restores one feature to its state before the transaction.
+type txUndo struct {
+	Feature string   `json:"feature"`
+	Steps   []TxStep `json:"steps"`
+}
+
+// txJournal is the on-disk record of an in-flight transaction.
+type txJournal struct {
+	StartedAt time.Time `json:"started_at"`
+	Steps     []TxStep  `json:"steps"`
+	Undo      []txUndo  `json:"undo"`
+}
+
+// TxCommitHandler returns a handler that applies a list of steps atomically:
+// all of them, or none.
+func TxCommitHandler(state *daemon.State) daemon.HandlerFunc {
+	return imperative(state, txCommit(state))
+}
+
+func txCommit(state *daemon.State) daemon.HandlerFunc {
+	return func(ctx *daemon.HandlerContext) (any, error) {
+		var params TxCommitParams
+		if err := ctx.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		if len(params.Steps) == 0 {
+			return nil, fmt.Errorf("transaction has no steps")
+		}
+		for i, step := range params.Steps {
+			if _, ok := txOps[step.Method]; !ok {
+				return nil, fmt.Errorf("step %d: %s cannot be used in a transaction", i, step.Method)
+			}
+			// The policy only saw tx.commit; nothing runs unless it allows
+			// every step.
+			if !ctx.Allows(step.Method) {
+				return nil, fmt.Errorf("step %d: %s: %w", i, step.Method, protocol.ErrUnauthorized)
+			}
+		}
+
+		journal := &txJournal{StartedAt: time.Now(), Steps: params.Steps}
+		touched := make(map[string]bool)
+
+		for i, step := range params.Steps {
+			op := txOps[step.Method]
+			if !touched[op.feature] {
+				touched[op.feature] = true
+				journal.Undo = append(journal.Undo, txUndo{Feature: op.feature, Steps: restoreSteps(state, op.feature)})
+				// Write-ahead: the undo must be on disk before the step can
+				// change anything.
+				if err := writeTxJournal(journal); err != nil {
+					rbErr := rollbackTx(ctx, state, journal.Undo[:len(journal.Undo)-1])
+					return nil, txFailure(i, step.Method, fmt.Errorf("journal: %w", err), rbErr)
+				}
+			}
+
+			err := ctx.Context.Err()
+			if err == nil {
+				err = invokeRaw(ctx, step.Method, op.run(state), step.Params)
+			}
+			if err != nil {
+				// The failed step may have left part of its changes behind, so
+				// its feature is restored too.
+				ctx.Logger.Printf("Transaction step %d (%s) failed: %v; rolling back", i, step.Method, err)
+				rbErr := rollbackTx(ctx, state, journal.Undo)
+				return nil, txFailure(i, step.Method, err, rbErr)
+			}
+		}
+
+		if err := removeTxJournal(); err != nil {
+			ctx.Logger.Printf("Warning: cannot remove transaction journal: %v", err)
+		}
+		return &TxCommitResult{Applied: len(params.Steps)}, nil
+	}
+}
+
+// txFailure builds the error returned by a rolled-back transaction.
+func txFailure(index int, method string, err, rollbackErr error) error {
+	if rollbackErr != nil {
+		return fmt.Errorf("step %d (%s) failed: %w; rollback incomplete: %v", index, method, err, rollbackErr)
+	}
+	return fmt.Errorf("step %d (%s) failed: %w; rolled back", index, method, err)
+}
+
+// rollbackTx runs undos in reverse order and removes the journal. Every undo is
+// attempted even if an earlier one fails; the errors are joined. Rollback is
+// not cut short by the request's deadline: stopping halfway would defeat it.
+func rollbackTx(ctx *daemon.HandlerContext, state *daemon.State, undos []txUndo) error {
+	rb := *ctx
+	rb.Context = context.WithoutCancel(ctx.Context)
+
+	var errs []error
+	for i := len(undos) - 1; i >= 0; i-- {
+		for _, step := range undos[i].Steps {
+			op, ok := txOps[step.Method]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown undo method %s", undos[i].Feature, step.Method))
+				continue
+			}
+			if err := invokeRaw(&rb, step.Method, op.run(state), step.Params); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", undos[i].Feature, err))
+			}
+		}
+	}
+
+	if err := removeTxJournal(); err != nil {
+		errs = append(errs, fmt.Errorf("remove journal: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// restoreSteps returns the calls that bring feature back to its current state.
+func restoreSteps(state *daemon.State, feature string) []TxStep {
+	switch feature {
+	case FeatureKillSwitch:
+		ks := state.GetKillSwitch()
+		switch {
+		case !ks.Enabled:
+			return []TxStep{txStep("killswitch.disable", nil)}
+		case ks.VPNIface == "lo": // block-all marker, see killSwitchBlockAll
+			return []TxStep{txStep("killswitch.block_all", nil)}
+		default:
+			return []TxStep{txStep("killswitch.enable", KillSwitchEnableParams{
+				VPNInterface: ks.VPNIface,
+				VPNServerIP:  ks.VPNServerIP,
+				VPNServerIPs: ks.VPNServerIPs,
+				AllowLAN:     ks.AllowLAN,
+				LANRanges:    ks.LANRanges,
+			})}
+		}
+
+	case FeatureDNS:
+		dns := state.GetDNSProtection()
+		if !dns.Enabled {
+			return []TxStep{txStep("dns.disable", nil)}
+		}
+		return []TxStep{txStep("dns.enable", DNSEnableParams{
+			VPNInterface: dns.VPNIface,
+			Servers:      dns.Servers,
+			Mode:         dns.Mode,
+			BlockDoT:     dns.BlockDoT,
+			BlockDoH:     dns.BlockDoH,
+			LeakBlocking: dns.LeakBlocking,
+		})}
+
+	case FeatureIPv6:
+		ipv6 := state.GetIPv6Protection()
+		if !ipv6.Enabled {
+			return []TxStep{txStep("ipv6.disable", nil)}
+		}
+		return []TxStep{txStep("ipv6.enable", IPv6EnableParams{Mode: ipv6.Mode, BlockWebRTC: ipv6.BlockWebRTC})}
+
+	case FeatureSplitTunnel:
+		st := state.GetSplitTunnel()
+		steps := []TxStep{txStep("tunnel.cleanup", nil)}
+		if st.Enabled {
+			steps = append(steps, txStep("tunnel.setup", TunnelSetupParams{
+				Mode:            st.Mode,
+				Apps:            st.Apps,
+				VPNInterface:    st.VPNIface,
+				VPNGateway:      st.VPNGateway,
+				SplitDNSEnabled: st.SplitDNS,
+				VPNDNS:          st.VPNDNS,
+				SystemDNS:       st.SystemDNS,
+			}))
+		}
+		return steps
+	}
+	return nil
+}
+
+// txStep builds a TxStep. The params types are plain structs, so marshalling
+// cannot fail.
+func txStep(method string, params any) TxStep {
+	step := TxStep{Method: method}
+	if params != nil {
+		step.Params, _ = json.Marshal(params)
+	}
+	return step
+}
+
+// invokeRaw is invoke for parameters that are already encoded.
+func invokeRaw(ctx *daemon.HandlerContext, method string, h daemon.HandlerFunc, params json.RawMessage) error {
+	if len(params) == 0 {
+		return invoke(ctx, method, h, nil)
+	}
+	return invoke(ctx, method, h, params)
+}
+
+func writeTxJournal(j *txJournal) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(txJournalPath), 0700); err != nil {
+		return err
+	}
+	// 0600: the steps may carry DNS servers and interface names; root-only
+	// like the daemon state.
+	return atomicfile.Write(txJournalPath, data, 0600)
+}
+
+func removeTxJournal() error {
+	if err := os.Remove(txJournalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RecoverTransaction rolls back a transaction that was in flight when the
+// daemon last stopped. Call it at startup after Reconcile, before the server
+// accepts requests.
+func RecoverTransaction(state *daemon.State, events *daemon.EventHub, logger *log.Logger) {
+	data, err := os.ReadFile(txJournalPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Printf("[tx] Warning: cannot read transaction journal: %v", err)
+		}
+		return
+	}
+
+	var journal txJournal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		// Nothing can be undone without the record; do not trip on it forever.
+		logger.Printf("[tx] Warning: discarding unreadable transaction journal: %v", err)
+		_ = removeTxJournal()
+		return
+	}
+
+	logger.Printf("[tx] Rolling back transaction interrupted at %s (%d features)",
+		journal.StartedAt.Format(time.RFC3339), len(journal.Undo))
+
+	posture.mu.Lock()
+	defer posture.mu.Unlock()
+
+	ctx := &daemon.HandlerContext{Context: context.Background(), State: state, Events: events, Logger: logger}
+	if err := rollbackTx(ctx, state, journal.Undo); err != nil {
+		logger.Printf("[tx] Warning: rollback had errors: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package privileged
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// installTxJournal points the transaction journal at a temp file.
+func installTxJournal(t *testing.T) string {
+	t.Helper()
+	orig := txJournalPath
+	txJournalPath = filepath.Join(t.TempDir(), "tx.journal")
+	t.Cleanup(func() { txJournalPath = orig })
+	return txJournalPath
+}
+
+var txDNS = DNSEnableParams{VPNInterface: "tun0", Servers: []string{"10.8.0.1"}, Mode: "custom", LeakBlocking: true}
+
+func TestTxCommitAppliesAllSteps(t *testing.T) {
+	journal := installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	// The undo must be on disk before the step changes anything.
+	fwEnableDNS = func(string) error {
+		if _, err := os.Stat(journal); err != nil {
+			t.Errorf("journal missing while a step runs: %v", err)
+		}
+		fw.enableDNS++
+		return nil
+	}
+	state := daemon.NewState()
+
+	res, err := TxCommitHandler(state)(dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+		txStep("tunnel.cleanup", nil),
+	}}))
+	if err != nil {
+		t.Fatalf("tx.commit: %v", err)
+	}
+	if got := res.(*TxCommitResult).Applied; got != 2 {
+		t.Errorf("applied = %d, want 2", got)
+	}
+	if fw.enableDNS != 1 || !state.GetDNSProtection().Enabled {
+		t.Error("dns.enable step did not run")
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal left behind after commit: %v", err)
+	}
+}
+
+func TestTxCommitRollsBackOnFailure(t *testing.T) {
+	journal := installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	state := daemon.NewState()
+
+	_, err := TxCommitHandler(state)(dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+		txStep("tunnel.cleanup", nil),
+		txStep("dns.enable", DNSEnableParams{Mode: "bogus"}),
+	}}))
+	if err == nil {
+		t.Fatal("tx.commit succeeded with an invalid step")
+	}
+	if !strings.Contains(err.Error(), "step 2 (dns.enable)") || !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("error = %v, want the failing step and a rollback note", err)
+	}
+	if state.GetDNSProtection().Enabled {
+		t.Error("DNS protection should be back to disabled")
+	}
+	if fw.disableDNS != 1 {
+		t.Errorf("DNS firewall teardowns = %d, want 1", fw.disableDNS)
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal left behind after rollback: %v", err)
+	}
+}
+
+func TestTxCommitRestoresPreviousSettings(t *testing.T) {
+	installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	state := daemon.NewState()
+	prev := daemon.DNSProtectionState{Enabled: true, VPNIface: "tun1", Servers: []string{"10.9.0.1"}, Mode: "custom", LeakBlocking: true}
+	state.SetDNSProtection(prev)
+
+	var leakIfaces []string
+	fwEnableDNS = func(iface string) error {
+		leakIfaces = append(leakIfaces, iface)
+		return fw.enableErr
+	}
+
+	_, err := TxCommitHandler(state)(dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+		txStep("openvpn.connect", nil),
+	}}))
+	if err == nil {
+		t.Fatal("a non-transactional method must be rejected")
+	}
+	if len(leakIfaces) != 0 {
+		t.Fatal("nothing may run when a step is rejected up front")
+	}
+
+	_, err = TxCommitHandler(state)(dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+		txStep("dns.enable", DNSEnableParams{Mode: "bogus"}),
+	}}))
+	if err == nil {
+		t.Fatal("tx.commit succeeded with an invalid step")
+	}
+	if got := strings.Join(leakIfaces, ","); got != "tun0,tun1" {
+		t.Errorf("leak-block applied on %s, want tun0 then the restored tun1", got)
+	}
+	if got := state.GetDNSProtection(); !got.Enabled || got.VPNIface != "tun1" || got.Servers[0] != "10.9.0.1" {
+		t.Errorf("DNS protection = %+v, want the previous settings", got)
+	}
+}
+
+func TestTxCommitChecksStepsAgainstMethodPolicy(t *testing.T) {
+	journal := installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	state := daemon.NewState()
+	state.SetIPv6ProtectionEnabled(true)
+
+	ctx := dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+		txStep("ipv6.disable", nil),
+	}})
+	ctx.MethodAllowed = func(method string) bool { return !strings.HasPrefix(method, "ipv6.") }
+
+	_, err := TxCommitHandler(state)(ctx)
+	if !errors.Is(err, protocol.ErrUnauthorized) {
+		t.Fatalf("error = %v, want ErrUnauthorized", err)
+	}
+	if fw.enableDNS != 0 || state.GetDNSProtection().Enabled || !state.GetIPv6Protection().Enabled {
+		t.Error("nothing may run when the policy denies a step")
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal written for a refused transaction: %v", err)
+	}
+}
+
+func TestTxCommitReportsIncompleteRollback(t *testing.T) {
+	installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	state := daemon.NewState()
+	state.SetDNSProtection(daemon.DNSProtectionState{Enabled: true, VPNIface: "tun1", Mode: "custom", LeakBlocking: true})
+	fw.enableErr = errors.New("iptables gone")
+
+	_, err := TxCommitHandler(state)(dnsCtx(t, state, TxCommitParams{Steps: []TxStep{
+		txStep("dns.enable", txDNS),
+	}}))
+	if err == nil || !strings.Contains(err.Error(), "rollback incomplete") {
+		t.Errorf("error = %v, want the failed rollback reported", err)
+	}
+}
+
+func TestRecoverTransactionRollsBackJournal(t *testing.T) {
+	journal := installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+
+	// A previous instance crashed after dns.enable ran: the journal holds the
+	// undo and the persisted state shows the half-applied change.
+	state := daemon.NewState()
+	state.SetDNSProtection(daemon.DNSProtectionState{Enabled: true, VPNIface: "tun0", LeakBlocking: true})
+	data, _ := json.Marshal(txJournal{
+		Steps: []TxStep{txStep("dns.enable", txDNS), txStep("ipv6.enable", IPv6EnableParams{Mode: "block"})},
+		Undo:  []txUndo{{Feature: FeatureDNS, Steps: []TxStep{txStep("dns.disable", nil)}}},
+	})
+	if err := os.WriteFile(journal, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	RecoverTransaction(state, nil, log.New(io.Discard, "", 0))
+
+	if state.GetDNSProtection().Enabled || fw.disableDNS != 1 {
+		t.Error("the interrupted transaction was not rolled back")
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Errorf("journal left behind after recovery: %v", err)
+	}
+}
+
+func TestRecoverTransactionDiscardsCorruptJournal(t *testing.T) {
+	journal := installTxJournal(t)
+	fw := installDNSSeams(t, &fakeResolver{})
+	if err := os.WriteFile(journal, []byte("{truncated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	RecoverTransaction(daemon.NewState(), nil, log.New(io.Discard, "", 0))
+
+	if fw.disableDNS != 0 {
+		t.Error("nothing should run from an unreadable journal")
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Error("an unreadable journal should be discarded")
+	}
+}
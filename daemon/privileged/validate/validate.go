@@ -39,6 +39,7 @@ var (
 	ErrDangerousDirective = errors.New("config file contains a directive that can execute code")
 	ErrUnsafeArg          = errors.New("value contains whitespace or a control character")
 	ErrInvalidURL         = errors.New("invalid URL")
+	ErrInvalidHost        = errors.New("invalid host name")
 )
 
 // maxConfigLineBytes caps the length of a single config line we will scan, so a
@@ -93,6 +94,35 @@ func IP(s string) error {
 	return nil
 }
 
+// Host validates a server address: an IP address, or a DNS host name of
+// letters, digits, hyphens and dots, no label starting or ending with a hyphen,
+// at most 253 characters.
+func Host(s string) error {
+	if s == "" {
+		return fmt.Errorf("%w: empty", ErrInvalidHost)
+	}
+	if net.ParseIP(s) != nil {
+		return nil
+	}
+	if len(s) > 253 {
+		return fmt.Errorf("%w: %q exceeds 253 characters", ErrInvalidHost, s)
+	}
+	for label := range strings.SplitSeq(strings.TrimSuffix(s, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("%w: %q", ErrInvalidHost, s)
+		}
+		for _, c := range label {
+			isLower := c >= 'a' && c <= 'z'
+			isUpper := c >= 'A' && c <= 'Z'
+			isDigit := c >= '0' && c <= '9'
+			if !isLower && !isUpper && !isDigit && c != '-' {
+				return fmt.Errorf("%w: %q contains illegal character %q", ErrInvalidHost, s, string(c))
+			}
+		}
+	}
+	return nil
+}
+
 // dnsModes is the allow-list of runtime DNS protection modes the daemon
 // accepts. Any value outside this set is rejected at the boundary: the mode
 // steers which resolver actions the daemon takes (set servers, install the "~."
@@ -235,9 +265,10 @@ func OpenConfig(path string) (*os.File, error) {
 }
 
 // openVPNForbidden lists OpenVPN directives that cause the (root) openvpn process
-// to execute external code. These are rejected outright: the daemon must never run
-// a config that can shell out. Matched case-insensitively against the first token
-// of each directive line.
+// to execute external code, or that would take its management interface away from
+// the daemon. These are rejected outright: the daemon must never run a config that
+// can shell out. Matched case-insensitively against the first token of each
+// directive line.
 var openVPNForbidden = map[string]bool{
 	// `config` pulls in another file that OpenVPN expands recursively at parse time.
 	// The daemon only scans the top-level file, so a nested config could smuggle
@@ -259,6 +290,17 @@ var openVPNForbidden = map[string]bool{
 	"client-disconnect":     true,
 	"learn-address":         true,
 	"plugin":                true,
+	// The daemon drives openvpn through its own root-only management socket.
+	// A config must not open another one (a TCP port would hand the root
+	// process to anyone who can reach it) or change how it is used.
+	"management":                   true,
+	"management-client":            true,
+	"management-client-user":       true,
+	"management-client-group":      true,
+	"management-external-key":      true,
+	"management-external-cert":     true,
+	"management-forget-disconnect": true,
+	"management-signal":            true,
 }
 
 // openVPNForbiddenWithArg lists directives that are harmless bare (they tell
@@ -74,6 +74,30 @@ func TestIP(t *testing.T) {
 	}
 }
 
+func TestHost(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"vpn.example.com", false},
+		{"vpn-2.example.com.", false},
+		{"198.51.100.7", false},
+		{"2001:db8::1", false},
+		{"", true},
+		{"-vpn.example.com", true},
+		{"vpn-.example.com", true},
+		{"vpn..example.com", true},
+		{"vpn.example.com --up /tmp/x", true},
+		{"vpn_1.example.com", true},
+	}
+	for _, tt := range tests {
+		err := Host(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Host(%q) err=%v, wantErr=%v", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
 func TestCIDRAndDefault(t *testing.T) {
 	if err := CIDR("192.168.0.0/24"); err != nil {
 		t.Errorf("CIDR(valid) unexpected err: %v", err)
@@ -168,6 +192,9 @@ func TestOpenVPNConfigSafe(t *testing.T) {
 		{"auth-user-pass-bare", "client\nauth-user-pass\n", false},
 		{"auth-user-pass-file", "client\nauth-user-pass /etc/shadow\n", true},
 		{"askpass-file", "client\naskpass /etc/shadow\n", true},
+		// The management interface belongs to the daemon.
+		{"management-tcp", "client\nmanagement 0.0.0.0 7505\n", true},
+		{"management-external-key", "client\nmanagement-external-key\n", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -5,8 +5,7 @@ package vpn
 import (
 	"bufio"
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,39 +13,109 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 	"github.com/yllada/vpn-manager/internal/paths"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // =============================================================================
 // OPENVPN PROCESS MANAGER
 // =============================================================================
 
+// DefaultMaxOpenVPNProcesses caps the openvpn processes an OpenVPNManager runs
+// at once. Nobody needs more tunnels than this; a client looping connects over
+// different profile IDs must not be able to fork without end.
+const DefaultMaxOpenVPNProcesses = 8
+
 // OpenVPNManager manages OpenVPN process lifecycle.
 type OpenVPNManager struct {
-	mu        sync.RWMutex
-	processes map[string]*OpenVPNProcess // keyed by profile ID
-	logger    *log.Logger
+	mu           sync.RWMutex
+	processes    map[string]*OpenVPNProcess // keyed by profile ID
+	maxProcesses int
+	logger       *log.Logger
+
+	// onStateChange is notified after every status or IP transition. Guarded
+	// by its own lock because transitions fire both with and without mu held.
+	hookMu        sync.RWMutex
+	onStateChange func(OpenVPNStatusResult)
+
+	// Each profile's log, kept across its sessions (see openvpn_logs.go).
+	logsMu sync.Mutex
+	logs   map[string]*profileLog
+
+	// The auth-tokens profiles' servers pushed, in memory only (see
+	// openvpn_authtoken.go).
+	tokensMu   sync.Mutex
+	authTokens map[string]authToken
 }
 
 // OpenVPNProcess represents a running OpenVPN process.
 type OpenVPNProcess struct {
-	ProfileID   string
-	ConfigPath  string
-	Cmd         *exec.Cmd
-	Status      string
-	IPAddress   string
-	StartTime   time.Time
-	LastError   string
-	stopChan    chan struct{}
-	stopOnce    sync.Once // Ensures stopChan is closed exactly once under concurrent Disconnect calls
-	outputLines []string
-	mu          sync.RWMutex
+	ProfileID     string
+	ConfigPath    string
+	Cmd           *exec.Cmd // nil for a process adopted from a previous daemon instance
+	PID           int
+	Status        string
+	State         string // openvpn's own state (CONNECTING, GET_CONFIG, CONNECTED, ...)
+	IPAddress     string
+	RemoteAddress string
+	BytesIn       uint64
+	BytesOut      uint64
+	StartTime     time.Time
+	LastError     string
+	Challenge     *OpenVPNChallenge // The question waiting for Respond, if any
+	stopChan      chan struct{}
+	stopOnce      sync.Once // Ensures stopChan is closed exactly once under concurrent Disconnect calls
+	outputLines   []string
+	log           *profileLog
+	mu            sync.RWMutex
+
+	// username and password answer openvpn's >PASSWORD requests. They are
+	// kept in memory only, so a re-adopted process has none.
+	username  string
+	password  string
+	challenge challengeState
+
+	// uid is the user who started the connection, nil when unknown. authUser
+	// is the username last sent, which a pushed auth-token is issued to, and
+	// usingToken means password is a cached auth-token.
+	uid        *uint32
+	authUser   string
+	usingToken bool
+
+	mgmtSocket string
+	mgmt       *mgmtClient // nil until connected, and after openvpn exits
+	credFile   string      // Only for processes started before the management interface
+	logFile    string
+	exited     chan struct{} // closed once the process has exited
+	logDone    chan struct{} // closed once its output has been drained
+}
+
+// ProcessInfo identifies a running openvpn process well enough for a restarted
+// daemon to re-adopt it (see Adopt).
+type ProcessInfo struct {
+	ProfileID        string
+	PID              int
+	StagedConfig     string
+	ManagementSocket string
+	LogFile          string
+	StartTime        time.Time
+
+	// CredentialsFile is the --auth-user-pass file of a process started
+	// before the management interface; it is removed when the process exits.
+	CredentialsFile string
+
+	// Status and IPAddress are the last known values, used only when there
+	// is no management socket to get the state from.
+	Status    string
+	IPAddress string
 }
 
 // OpenVPNConnectParams contains parameters for connecting.
@@ -58,6 +127,47 @@ type OpenVPNConnectParams struct {
 	SplitTunnelEnable bool     `json:"split_tunnel_enabled"`
 	SplitTunnelMode   string   `json:"split_tunnel_mode"`
 	SplitTunnelRoutes []string `json:"split_tunnel_routes"`
+
+	// UseAuthToken answers the server with the auth-token it pushed to the
+	// profile's last connection instead of Username and Password, so a
+	// profile that requires a one-time code reconnects without a new one.
+	UseAuthToken bool `json:"use_auth_token,omitempty"`
+
+	// CallerUID is the user connecting, set by the handler rather than the
+	// client: auth-tokens are only cached and reused for a known user.
+	CallerUID *uint32 `json:"-"`
+
+	// Remote is the server to try first, ahead of the config's own remotes
+	// (which remain the fallback), e.g. the one with the lowest latency.
+	Remote *OpenVPNRemote `json:"remote,omitempty"`
+}
+
+// OpenVPNRemote is a server for openvpn's --remote option.
+type OpenVPNRemote struct {
+	Host  string `json:"host"`
+	Port  int    `json:"port"`
+	Proto string `json:"proto"`
+}
+
+// remoteProtos are the --remote protocols a client may ask for.
+var remoteProtos = map[string]bool{
+	"udp": true, "udp4": true, "udp6": true,
+	"tcp": true, "tcp4": true, "tcp6": true,
+	"tcp-client": true, "tcp4-client": true, "tcp6-client": true,
+}
+
+// validateRemote checks a client-supplied --remote at the privilege boundary.
+func validateRemote(r *OpenVPNRemote) error {
+	if err := validate.Host(r.Host); err != nil {
+		return fmt.Errorf("remote host: %w", err)
+	}
+	if r.Port < 1 || r.Port > 65535 {
+		return fmt.Errorf("remote port %d is out of range", r.Port)
+	}
+	if !remoteProtos[r.Proto] {
+		return fmt.Errorf("remote protocol %q is not supported", r.Proto)
+	}
+	return nil
 }
 
 // OpenVPNConnectResult contains the result of a connect operation.
@@ -67,14 +177,26 @@ type OpenVPNConnectResult struct {
 	PID       int    `json:"pid"`
 }
 
-// OpenVPNStatusResult contains the status of an OpenVPN connection.
+// OpenVPNStatusResult contains the status of an OpenVPN connection. State is
+// openvpn's own state behind Status, as its management interface reports it,
+// and BytesIn and BytesOut are the session's traffic, updated every few
+// seconds. Challenge is set while the server waits for the answer to a
+// two-factor question (see openvpn.respond). AuthToken reports that the caller
+// could reconnect the profile with UseAuthToken.
 type OpenVPNStatusResult struct {
-	ProfileID   string   `json:"profile_id"`
-	Status      string   `json:"status"`
-	IPAddress   string   `json:"ip_address"`
-	StartTime   string   `json:"start_time,omitempty"`
-	LastError   string   `json:"last_error,omitempty"`
-	OutputLines []string `json:"output_lines,omitempty"`
+	ProfileID     string   `json:"profile_id"`
+	Status        string   `json:"status"`
+	State         string   `json:"state,omitempty"`
+	IPAddress     string   `json:"ip_address"`
+	RemoteAddress string   `json:"remote_address,omitempty"`
+	BytesIn       uint64   `json:"bytes_in,omitempty"`
+	BytesOut      uint64   `json:"bytes_out,omitempty"`
+	StartTime     string   `json:"start_time,omitempty"`
+	LastError     string   `json:"last_error,omitempty"`
+	OutputLines   []string `json:"output_lines,omitempty"`
+
+	Challenge *OpenVPNChallenge `json:"challenge,omitempty"`
+	AuthToken bool              `json:"auth_token,omitempty"`
 }
 
 // Status constants
@@ -92,15 +214,66 @@ func NewOpenVPNManager(logger *log.Logger) *OpenVPNManager {
 		logger = log.Default()
 	}
 	return &OpenVPNManager{
-		processes: make(map[string]*OpenVPNProcess),
-		logger:    logger,
+		processes:    make(map[string]*OpenVPNProcess),
+		maxProcesses: DefaultMaxOpenVPNProcesses,
+		logger:       logger,
+		logs:         make(map[string]*profileLog),
+		authTokens:   make(map[string]authToken),
+	}
+}
+
+// SetStateChangeHandler registers fn to be called after every connection state
+// transition (connecting, connected, error, disconnected) and whenever the
+// tunnel IP is learned. fn receives a snapshot without OutputLines and must not
+// block: it runs on the goroutine that reads openvpn's management interface.
+func (m *OpenVPNManager) SetStateChangeHandler(fn func(OpenVPNStatusResult)) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	m.onStateChange = fn
+}
+
+// notifyStateChange reports proc's current state to the registered handler.
+// Must be called without proc.mu held.
+func (m *OpenVPNManager) notifyStateChange(proc *OpenVPNProcess) {
+	m.hookMu.RLock()
+	fn := m.onStateChange
+	m.hookMu.RUnlock()
+	if fn == nil {
+		return
 	}
+
+	proc.mu.RLock()
+	status := proc.snapshotLocked()
+	proc.mu.RUnlock()
+
+	fn(status)
+}
+
+// snapshotLocked returns proc's status without its output lines. Must be
+// called with proc.mu held.
+func (p *OpenVPNProcess) snapshotLocked() OpenVPNStatusResult {
+	status := OpenVPNStatusResult{
+		ProfileID:     p.ProfileID,
+		Status:        p.Status,
+		State:         p.State,
+		IPAddress:     p.IPAddress,
+		RemoteAddress: p.RemoteAddress,
+		BytesIn:       p.BytesIn,
+		BytesOut:      p.BytesOut,
+		LastError:     p.LastError,
+		Challenge:     p.Challenge,
+	}
+	if !p.StartTime.IsZero() {
+		status.StartTime = p.StartTime.Format(time.RFC3339)
+	}
+	return status
 }
 
 // Connect starts an OpenVPN connection.
-// Note: The context parameter is kept for API compatibility but not used,
-// because OpenVPN processes must outlive the request that starts them.
-func (m *OpenVPNManager) Connect(_ context.Context, params OpenVPNConnectParams) (*OpenVPNConnectResult, error) {
+// Note: ctx only stops Connect before openvpn is started. The process itself
+// must outlive the request that starts it; undoing a start whose request was
+// cancelled afterwards is the caller's job (Disconnect).
+func (m *OpenVPNManager) Connect(ctx context.Context, params OpenVPNConnectParams) (*OpenVPNConnectResult, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -110,6 +283,26 @@ func (m *OpenVPNManager) Connect(_ context.Context, params OpenVPNConnectParams)
 			return nil, fmt.Errorf("profile %s is already connected or connecting", params.ProfileID)
 		}
 	}
+	if running := m.runningLocked(); running >= m.maxProcesses {
+		return nil, fmt.Errorf("%w: %d OpenVPN connections are already running", protocol.ErrRateLimited, running)
+	}
+	// The credentials are sent as management commands, one per line.
+	if strings.ContainsAny(params.Username+params.Password, "\r\n") {
+		return nil, errors.New("username and password must not contain line breaks")
+	}
+	if params.Remote != nil {
+		if err := validateRemote(params.Remote); err != nil {
+			return nil, fmt.Errorf("openvpn: %w", err)
+		}
+	}
+	username, password := params.Username, params.Password
+	if params.UseAuthToken {
+		tok, ok := m.lookupAuthToken(params.ProfileID, params.CallerUID)
+		if !ok {
+			return nil, ErrNoAuthToken
+		}
+		username, password = tok.username, tok.token
+	}
 
 	// SECURITY (C1): revalidate the config at the privilege boundary. Client-side
 	// validation cannot be trusted — an attacker may speak the socket protocol
@@ -130,22 +323,33 @@ func (m *OpenVPNManager) Connect(_ context.Context, params OpenVPNConnectParams)
 		}
 	}()
 
-	// Create credentials file if needed
-	credFile, err := createCredentialsFile(params.Username, params.Password)
+	mgmtSocket, err := createManagementSocketPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management socket: %w", err)
+	}
+
+	logFile, err := createLogFile()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create credentials file: %w", err)
+		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
 
 	// Build OpenVPN arguments. The config is the root-only staged copy, not the
 	// client-supplied path, so the bytes openvpn parses are exactly the bytes we
 	// scanned.
-	args := buildOpenVPNArgs(stagedConfig, credFile, params)
+	args := buildOpenVPNArgs(stagedConfig, mgmtSocket, params)
+
+	// openvpn logs to a file rather than to our stdout pipe. A pipe dies with
+	// the daemon (openvpn would get SIGPIPE on its next log line); the file
+	// lets the process outlive a daemon restart. The daemon reads the log
+	// through the management interface, and the file only if openvpn exits
+	// before opening it.
+	args = append(args, "--log", logFile, "--suppress-timestamps")
 
 	// Create the process
-	// NOTE: We use exec.Command instead of exec.CommandContext because OpenVPN
+	// NOTE: We use sysexec.Command instead of sysexec.CommandContext because OpenVPN
 	// must outlive the RPC request that started it. The process lifecycle is
 	// managed by Disconnect() and the stopChan, not by context cancellation.
-	cmd := exec.Command("openvpn", args...)
+	cmd := sysexec.Command("openvpn", args...)
 
 	proc := &OpenVPNProcess{
 		ProfileID:  params.ProfileID,
@@ -154,38 +358,57 @@ func (m *OpenVPNManager) Connect(_ context.Context, params OpenVPNConnectParams)
 		Status:     StatusConnecting,
 		StartTime:  time.Now(),
 		stopChan:   make(chan struct{}),
+		username:   username,
+		password:   password,
+		uid:        params.CallerUID,
+		usingToken: params.UseAuthToken,
+		mgmtSocket: mgmtSocket,
+		logFile:    logFile,
+		exited:     make(chan struct{}),
+		logDone:    make(chan struct{}),
 	}
 
-	// Setup output capture
+	// Setup output capture. Only messages printed before openvpn opens its
+	// --log file (e.g. option errors) arrive here.
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		cleanupCredentialsFile(credFile)
+		removeOpenVPNLog(logFile)
 		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		cleanupCredentialsFile(credFile)
+		removeOpenVPNLog(logFile)
 		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
+	// Last point where a cancelled request leaves nothing behind
+	if err := ctx.Err(); err != nil {
+		removeOpenVPNLog(logFile)
+		return nil, err
+	}
+
 	// Start the process
 	m.logger.Printf("[openvpn] Starting connection for profile %s", params.ProfileID)
 	if err := cmd.Start(); err != nil {
-		cleanupCredentialsFile(credFile)
+		removeOpenVPNLog(logFile)
 		return nil, fmt.Errorf("failed to start openvpn: %w", err)
 	}
+	proc.PID = cmd.Process.Pid
 
 	m.logger.Printf("[openvpn] Process started with PID %d", cmd.Process.Pid)
 
 	// Store the process
 	m.processes[params.ProfileID] = proc
+	m.startSessionLog(proc)
+	m.notifyStateChange(proc)
 
 	// Start output monitoring
 	go m.monitorOutput(proc, stdout, stderr)
+	go m.followManagement(proc)
 
 	// Start process waiter
-	go m.waitForProcess(proc, credFile)
+	go m.waitForProcess(proc)
 
 	// The process now owns the staged config for its lifetime; waitForProcess
 	// removes it on exit. Prevent the early-exit defer from deleting it.
@@ -198,6 +421,20 @@ func (m *OpenVPNManager) Connect(_ context.Context, params OpenVPNConnectParams)
 	}, nil
 }
 
+// runningLocked counts the tracked openvpn processes that have not exited.
+// Must be called with m.mu held.
+func (m *OpenVPNManager) runningLocked() int {
+	running := 0
+	for _, proc := range m.processes {
+		select {
+		case <-proc.exited:
+		default:
+			running++
+		}
+	}
+	return running
+}
+
 // Disconnect stops an OpenVPN connection.
 func (m *OpenVPNManager) Disconnect(profileID string) error {
 	m.mu.Lock()
@@ -211,18 +448,17 @@ func (m *OpenVPNManager) Disconnect(profileID string) error {
 	proc.mu.Lock()
 	proc.Status = StatusDisconnecting
 	proc.mu.Unlock()
+	m.notifyStateChange(proc)
 
 	m.logger.Printf("[openvpn] Disconnecting profile %s", profileID)
 
 	// Signal stop — sync.Once guarantees exactly one close even under concurrent Disconnect calls.
 	proc.stopOnce.Do(func() { close(proc.stopChan) })
 
-	// Kill the process by PID. killall is intentionally avoided — it would
-	// kill any openvpn process on the system, not just ours.
-	if proc.Cmd != nil && proc.Cmd.Process != nil {
-		if err := proc.Cmd.Process.Kill(); err != nil {
-			m.logger.Printf("[openvpn] Error killing process: %v", err)
-		}
+	// Let openvpn shut down cleanly (tell the server, remove its routes)
+	// and kill it only if that fails.
+	if !m.stopGracefully(proc) {
+		m.killProcess(proc)
 	}
 
 	// Remove from tracking
@@ -233,6 +469,24 @@ func (m *OpenVPNManager) Disconnect(profileID string) error {
 	return nil
 }
 
+// killProcess kills proc's openvpn by PID. killall is intentionally avoided —
+// it would kill any openvpn process on the system, not just ours.
+func (m *OpenVPNManager) killProcess(proc *OpenVPNProcess) {
+	if proc.Cmd != nil && proc.Cmd.Process != nil {
+		if err := proc.Cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			m.logger.Printf("[openvpn] Error killing process: %v", err)
+		}
+	} else if proc.PID > 0 {
+		// Adopted process: not our child, so re-check that the PID still
+		// belongs to this openvpn before signalling it.
+		if err := verifyOpenVPNProcess(proc.PID, proc.ConfigPath); err != nil {
+			m.logger.Printf("[openvpn] Not killing PID %d: %v", proc.PID, err)
+		} else if err := syscall.Kill(proc.PID, syscall.SIGKILL); err != nil {
+			m.logger.Printf("[openvpn] Error killing process: %v", err)
+		}
+	}
+}
+
 // DisconnectAll stops all OpenVPN connections.
 func (m *OpenVPNManager) DisconnectAll() error {
 	m.mu.RLock()
@@ -267,19 +521,9 @@ func (m *OpenVPNManager) Status(profileID string) (*OpenVPNStatusResult, error)
 	proc.mu.RLock()
 	defer proc.mu.RUnlock()
 
-	result := &OpenVPNStatusResult{
-		ProfileID:   profileID,
-		Status:      proc.Status,
-		IPAddress:   proc.IPAddress,
-		LastError:   proc.LastError,
-		OutputLines: proc.outputLines,
-	}
-
-	if !proc.StartTime.IsZero() {
-		result.StartTime = proc.StartTime.Format(time.RFC3339)
-	}
-
-	return result, nil
+	result := proc.snapshotLocked()
+	result.OutputLines = proc.outputLines
+	return &result, nil
 }
 
 // ListConnections returns all active connections.
@@ -290,19 +534,15 @@ func (m *OpenVPNManager) ListConnections() []OpenVPNStatusResult {
 	results := make([]OpenVPNStatusResult, 0, len(m.processes))
 	for _, proc := range m.processes {
 		proc.mu.RLock()
-		results = append(results, OpenVPNStatusResult{
-			ProfileID: proc.ProfileID,
-			Status:    proc.Status,
-			IPAddress: proc.IPAddress,
-			LastError: proc.LastError,
-		})
+		results = append(results, proc.snapshotLocked())
 		proc.mu.RUnlock()
 	}
 
 	return results
 }
 
-// monitorOutput monitors OpenVPN stdout/stderr for connection status.
+// monitorOutput records what openvpn prints to stdout and stderr before it
+// opens its --log file (e.g. option errors).
 func (m *OpenVPNManager) monitorOutput(proc *OpenVPNProcess, stdout, stderr io.ReadCloser) {
 	// Monitor both stdout and stderr
 	go m.readOutput(proc, stdout, "stdout")
@@ -312,70 +552,107 @@ func (m *OpenVPNManager) monitorOutput(proc *OpenVPNProcess, stdout, stderr io.R
 func (m *OpenVPNManager) readOutput(proc *OpenVPNProcess, reader io.ReadCloser, source string) {
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Store last N lines for debugging
-		proc.mu.Lock()
-		if len(proc.outputLines) >= 100 {
-			proc.outputLines = proc.outputLines[1:]
-		}
-		proc.outputLines = append(proc.outputLines, line)
-		proc.mu.Unlock()
-
-		// Parse for connection status
-		m.parseOutputLine(proc, line)
+		m.handleOutputLine(proc, scanner.Text())
 	}
 }
 
-func (m *OpenVPNManager) parseOutputLine(proc *OpenVPNProcess, line string) {
-	// Check for successful connection
-	if strings.Contains(line, "Initialization Sequence Completed") {
-		proc.mu.Lock()
-		proc.Status = StatusConnected
-		proc.mu.Unlock()
-		m.logger.Printf("[openvpn] Profile %s connected", proc.ProfileID)
-		return
+// handleOutputLine records one line of openvpn output read now, outside the
+// management interface.
+func (m *OpenVPNManager) handleOutputLine(proc *OpenVPNProcess, line string) {
+	m.recordOutput(proc, time.Now(), classifyLogLine(line), line)
+}
+
+// recordOutput records one line of openvpn output. The connection state is
+// not guessed from it: that comes from the management interface.
+func (m *OpenVPNManager) recordOutput(proc *OpenVPNProcess, t time.Time, level LogLevel, line string) {
+	// Store last N lines for debugging
+	proc.mu.Lock()
+	if len(proc.outputLines) >= 100 {
+		proc.outputLines = proc.outputLines[1:]
 	}
+	proc.outputLines = append(proc.outputLines, line)
+	proc.mu.Unlock()
 
-	// Check for IP address assignment
-	// OpenVPN 2.6+ uses "net_addr_v4_add: IP/CIDR dev tunX" format
-	// Older versions use "ifconfig IP netmask" in PUSH_REPLY
-	if strings.Contains(line, "net_addr_v4_add:") ||
-		strings.Contains(line, "PUSH: Received control message") ||
-		strings.Contains(line, "ifconfig") {
-		// Try to extract IP
-		if ip := extractIPFromLine(line); ip != "" {
-			proc.mu.Lock()
-			proc.IPAddress = ip
-			proc.mu.Unlock()
-			m.logger.Printf("[openvpn] Profile %s got IP: %s", proc.ProfileID, ip)
-		}
+	if proc.log != nil {
+		proc.log.record(t, level, line)
 	}
+}
+
+// logPollInterval is how often tailLog checks the log for new lines.
+const logPollInterval = 250 * time.Millisecond
 
-	// Check for errors
-	if strings.Contains(line, "AUTH_FAILED") {
-		proc.mu.Lock()
-		proc.Status = StatusError
-		proc.LastError = "Authentication failed"
-		proc.mu.Unlock()
-		m.logger.Printf("[openvpn] Profile %s auth failed", proc.ProfileID)
+// followLog tails the --log file of a process without a management socket
+// (one started by an older daemon) until it exits.
+func (m *OpenVPNManager) followLog(proc *OpenVPNProcess) {
+	defer close(proc.logDone)
+	m.tailLog(proc)
+}
+
+// tailLog reads proc's --log file from the start until the process exits,
+// feeding each line to handleOutputLine.
+func (m *OpenVPNManager) tailLog(proc *OpenVPNProcess) {
+	f, err := os.Open(proc.logFile)
+	if err != nil {
+		m.logger.Printf("[openvpn] Cannot follow log for profile %s: %v", proc.ProfileID, err)
+		return
 	}
+	defer func() { _ = f.Close() }()
+
+	reader := bufio.NewReader(f)
+	var partial strings.Builder
+	exited := false
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial.WriteString(chunk)
+		if err == nil {
+			m.handleOutputLine(proc, strings.TrimRight(partial.String(), "\r\n"))
+			partial.Reset()
+			continue
+		}
+		if err != io.EOF {
+			m.logger.Printf("[openvpn] Log read error for profile %s: %v", proc.ProfileID, err)
+			return
+		}
 
-	if strings.Contains(line, "TLS Error") || strings.Contains(line, "TLS handshake failed") {
-		proc.mu.Lock()
-		proc.Status = StatusError
-		proc.LastError = "TLS handshake failed"
-		proc.mu.Unlock()
+		// At EOF. Once the process has exited and we have read to the end
+		// after noticing, the log is complete.
+		if exited {
+			if partial.Len() > 0 {
+				m.handleOutputLine(proc, strings.TrimRight(partial.String(), "\r\n"))
+			}
+			return
+		}
+		select {
+		case <-proc.exited:
+			exited = true
+		case <-time.After(logPollInterval):
+		}
 	}
 }
 
-func (m *OpenVPNManager) waitForProcess(proc *OpenVPNProcess, credFile string) {
+func (m *OpenVPNManager) waitForProcess(proc *OpenVPNProcess) {
 	// Wait for process to exit
 	err := proc.Cmd.Wait()
+	m.finishProcess(proc, err)
+}
 
-	// Cleanup credentials file and the root-only staged config copy.
-	cleanupCredentialsFile(credFile)
+// finishProcess records the exit of proc's openvpn process: it lets the
+// management connection (or followLog) drain the final log lines, ends the
+// session's log, removes the process's runtime files, sets the final status
+// and eventually stops tracking it.
+func (m *OpenVPNManager) finishProcess(proc *OpenVPNProcess, err error) {
+	close(proc.exited)
+	<-proc.logDone
+	if proc.log != nil {
+		proc.log.endSession(err)
+	}
+
+	// Cleanup the root-only staged config copy, the management socket, the
+	// log and a legacy credentials file.
+	cleanupCredentialsFile(proc.credFile)
 	removeStagedOpenVPNConfig(proc.ConfigPath)
+	removeManagementSocket(proc.mgmtSocket)
+	removeOpenVPNLog(proc.logFile)
 
 	proc.mu.Lock()
 	if proc.Status == StatusConnecting || proc.Status == StatusConnected {
@@ -385,18 +662,174 @@ func (m *OpenVPNManager) waitForProcess(proc *OpenVPNProcess, credFile string) {
 		} else {
 			proc.Status = StatusDisconnected
 		}
+	} else if proc.Status == StatusDisconnecting {
+		proc.Status = StatusDisconnected
 	}
 	proc.mu.Unlock()
 
 	m.logger.Printf("[openvpn] Process for profile %s exited", proc.ProfileID)
+	m.notifyStateChange(proc)
 
-	// Remove from tracking after a delay
+	// Remove from tracking after a delay, unless a new connection for the same
+	// profile has replaced this one meanwhile.
 	time.Sleep(time.Second)
 	m.mu.Lock()
-	delete(m.processes, proc.ProfileID)
+	if m.processes[proc.ProfileID] == proc {
+		delete(m.processes, proc.ProfileID)
+	}
 	m.mu.Unlock()
 }
 
+// =============================================================================
+// RE-ADOPTION AFTER A DAEMON RESTART
+// =============================================================================
+
+// procRoot is the procfs mount used to inspect adopted processes. Package-level
+// var (not const) so tests can point it at a fake tree.
+var procRoot = "/proc"
+
+// adoptPollInterval is how often an adopted process is checked for liveness.
+// It is not our child, so we cannot Wait for it.
+const adoptPollInterval = time.Second
+
+// errAdoptedExited is the exit error recorded for an adopted process, whose
+// real exit status is only visible to its parent.
+var errAdoptedExited = errors.New("openvpn process exited")
+
+// ProcessInfo returns what a restarted daemon needs to re-adopt the connection
+// for profileID, or false if it is not tracked.
+func (m *OpenVPNManager) ProcessInfo(profileID string) (ProcessInfo, bool) {
+	m.mu.RLock()
+	proc, exists := m.processes[profileID]
+	m.mu.RUnlock()
+	if !exists {
+		return ProcessInfo{}, false
+	}
+
+	proc.mu.RLock()
+	defer proc.mu.RUnlock()
+	return ProcessInfo{
+		ProfileID:        proc.ProfileID,
+		PID:              proc.PID,
+		StagedConfig:     proc.ConfigPath,
+		ManagementSocket: proc.mgmtSocket,
+		LogFile:          proc.logFile,
+		CredentialsFile:  proc.credFile,
+		StartTime:        proc.StartTime,
+		Status:           proc.Status,
+		IPAddress:        proc.IPAddress,
+	}, true
+}
+
+// Adopt takes over an openvpn process started by a previous daemon instance.
+// The PID must still run openvpn with the recorded staged config, which rules
+// out a recycled PID. The daemon reconnects to its management socket, whose
+// state and log history recover status and IP, and it is then monitored like
+// a process this instance started. If the process is gone Adopt removes its
+// leftover runtime files and returns an error.
+func (m *OpenVPNManager) Adopt(info ProcessInfo) error {
+	if err := verifyOpenVPNProcess(info.PID, info.StagedConfig); err != nil {
+		cleanupCredentialsFile(info.CredentialsFile)
+		removeStagedOpenVPNConfig(info.StagedConfig)
+		removeManagementSocket(info.ManagementSocket)
+		removeOpenVPNLog(info.LogFile)
+		return err
+	}
+
+	m.mu.Lock()
+	if _, exists := m.processes[info.ProfileID]; exists {
+		m.mu.Unlock()
+		return fmt.Errorf("profile %s is already tracked", info.ProfileID)
+	}
+
+	proc := &OpenVPNProcess{
+		ProfileID:  info.ProfileID,
+		ConfigPath: info.StagedConfig,
+		PID:        info.PID,
+		Status:     StatusConnecting,
+		StartTime:  info.StartTime,
+		stopChan:   make(chan struct{}),
+		credFile:   info.CredentialsFile,
+		logFile:    info.LogFile,
+		exited:     make(chan struct{}),
+		logDone:    make(chan struct{}),
+	}
+	if !strings.HasPrefix(info.LogFile, ovpnLogDir+"/") {
+		proc.logFile = ""
+	}
+	if strings.HasPrefix(info.ManagementSocket, ovpnMgmtDir+"/") {
+		proc.mgmtSocket = info.ManagementSocket
+	} else {
+		// Started by a daemon without the management interface: keep the
+		// last persisted state and only follow the log, if there is one.
+		proc.Status = info.Status
+		proc.IPAddress = info.IPAddress
+	}
+	m.processes[info.ProfileID] = proc
+	m.startSessionLog(proc)
+	m.mu.Unlock()
+
+	m.logger.Printf("[openvpn] Adopted running process PID %d for profile %s", info.PID, info.ProfileID)
+
+	switch {
+	case proc.mgmtSocket != "":
+		go m.followManagement(proc)
+	case proc.logFile != "":
+		go m.followLog(proc)
+	default:
+		close(proc.logDone)
+	}
+	go m.watchAdopted(proc)
+
+	return nil
+}
+
+// watchAdopted polls an adopted process until it exits.
+func (m *OpenVPNManager) watchAdopted(proc *OpenVPNProcess) {
+	ticker := time.NewTicker(adoptPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !processAlive(proc.PID) {
+			m.finishProcess(proc, errAdoptedExited)
+			return
+		}
+	}
+}
+
+// verifyOpenVPNProcess checks that pid is alive and is an openvpn running the
+// given staged config. Staged config names are random, so this also proves the
+// PID was not recycled by an unrelated process.
+func verifyOpenVPNProcess(pid int, stagedConfig string) error {
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid %d", pid)
+	}
+	if !strings.HasPrefix(stagedConfig, ovpnStagingDir+"/") {
+		return fmt.Errorf("config %q is not a staged config", stagedConfig)
+	}
+
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return fmt.Errorf("process %d is gone: %w", pid, err)
+	}
+	argv := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	if len(argv) == 0 || filepath.Base(argv[0]) != "openvpn" {
+		return fmt.Errorf("process %d is not openvpn", pid)
+	}
+	for i := 0; i+1 < len(argv); i++ {
+		if argv[i] == "--config" && argv[i+1] == stagedConfig {
+			return nil
+		}
+	}
+	return fmt.Errorf("process %d does not run config %s", pid, stagedConfig)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
 // =============================================================================
 // CONFIG STAGING (TOCTOU-safe C1 validation)
 // =============================================================================
@@ -450,21 +883,37 @@ func removeStagedOpenVPNConfig(configPath string) {
 // =============================================================================
 
 // buildOpenVPNArgs constructs the openvpn argv. Secrets are NEVER placed in
-// argv (argv is world-readable via /proc): credentials travel only through the
-// 0600 credentials file referenced by --auth-user-pass. The config argument
-// must be the root-only staged copy, never the client-supplied path.
-func buildOpenVPNArgs(stagedConfig, credFile string, params OpenVPNConnectParams) []string {
-	args := []string{
+// argv (argv is world-readable via /proc): openvpn asks for credentials on the
+// root-only management socket, where the daemon answers from memory. The
+// config argument must be the root-only staged copy, never the client-supplied
+// path.
+func buildOpenVPNArgs(stagedConfig, mgmtSocket string, params OpenVPNConnectParams) []string {
+	var args []string
+	if r := params.Remote; r != nil {
+		// openvpn tries remotes in the order it reads them, so this one goes
+		// before the config's.
+		args = append(args, "--remote", r.Host, strconv.Itoa(r.Port), r.Proto)
+	}
+	args = append(args,
 		"--config", stagedConfig,
 		"--verb", "3",
 		// SECURITY (C1): force all script execution off regardless of config
 		// contents. Combined with the directive scan during staging, this is
 		// defense in depth against remote-code-execution via a malicious config.
 		"--script-security", "0",
-	}
-
-	if credFile != "" {
-		args = append(args, "--auth-user-pass", credFile)
+		// Hold until the daemon is connected, so it sees every state from the
+		// start, and keep enough log history to replay after a daemon restart.
+		"--management", mgmtSocket, "unix",
+		"--management-hold",
+		"--management-query-passwords",
+		"--management-log-cache", strconv.Itoa(logBufferLines),
+	)
+
+	if params.Username != "" || params.Password != "" || params.UseAuthToken {
+		// Without a file argument, openvpn asks for them (>PASSWORD), and asks
+		// again after a rejection so a CRV1 challenge can be answered. A
+		// cached auth-token is sent the same way.
+		args = append(args, "--auth-user-pass", "--auth-retry", "interact")
 	}
 
 	// Split tunneling configuration. Both modes are handled here, in OpenVPN's
@@ -506,44 +955,64 @@ func buildOpenVPNArgs(stagedConfig, credFile string, params OpenVPNConnectParams
 	return args
 }
 
-// ovpnCredsDir is the root-only directory under /run (not world-writable /tmp)
-// for transient credential files, so a local attacker cannot pre-create or
-// symlink-swap the parent before the daemon writes the credentials file into
-// it. Package-level var (not const) so tests can redirect it to a temp dir;
-// production code never reassigns it.
+// ovpnCredsDir is where daemons before the management interface wrote the
+// --auth-user-pass file of each process. Only such a file is ever removed.
+// Package-level var (not const) so tests can redirect it to a temp dir.
 var ovpnCredsDir = filepath.Join(paths.RuntimeDir, "ovpn-creds")
 
-func createCredentialsFile(username, password string) (string, error) {
-	if username == "" && password == "" {
-		return "", nil
+// cleanupCredentialsFile removes the credentials file of a process started by
+// an older daemon.
+func cleanupCredentialsFile(path string) {
+	if strings.HasPrefix(path, ovpnCredsDir+"/") {
+		_ = os.Remove(path)
 	}
+}
+
+// ovpnLogDir is the root-only directory holding each openvpn process's --log
+// file. Package-level var (not const) so tests can redirect it to a temp dir;
+// production code never reassigns it.
+var ovpnLogDir = filepath.Join(paths.RuntimeDir, "ovpn-logs")
 
-	if err := os.MkdirAll(ovpnCredsDir, 0700); err != nil {
+// createLogFile creates an empty, root-only log file for openvpn's --log. It is
+// created here (O_EXCL, 0600) rather than by openvpn so the daemon controls its
+// permissions, since the log can contain server addresses and usernames.
+func createLogFile() (string, error) {
+	if err := os.MkdirAll(ovpnLogDir, 0700); err != nil {
 		return "", err
 	}
-
-	// Generate random filename
-	randBytes := make([]byte, 16)
-	if _, err := rand.Read(randBytes); err != nil {
-		return "", fmt.Errorf("failed to generate random filename: %w", err)
+	f, err := os.CreateTemp(ovpnLogDir, "ovpn-*.log")
+	if err != nil {
+		return "", err
 	}
-
-	credFile := filepath.Join(ovpnCredsDir, hex.EncodeToString(randBytes))
-	content := fmt.Sprintf("%s\n%s\n", username, password)
-
-	if err := os.WriteFile(credFile, []byte(content), 0600); err != nil {
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
 		return "", err
 	}
-
-	return credFile, nil
+	return f.Name(), nil
 }
 
-func cleanupCredentialsFile(path string) {
-	if path != "" {
+// removeOpenVPNLog deletes an openvpn log file (best-effort), only if it lives in
+// ovpnLogDir.
+func removeOpenVPNLog(path string) {
+	if strings.HasPrefix(path, ovpnLogDir+"/") {
 		_ = os.Remove(path)
 	}
 }
 
+// SetRoot moves the directories this package writes (staged configs,
+// management sockets, openvpn logs and their archive) under root, keeping
+// their absolute paths: the OpenVPN staging dir becomes
+// root/run/vpn-manager/ovpn. SetRoot("/") restores the defaults. The daemon
+// never calls it; daemontest does, to run the handlers without touching /run.
+func SetRoot(root string) {
+	ovpnStagingDir = filepath.Join(root, paths.RuntimeDir, "ovpn")
+	ovpnCredsDir = filepath.Join(root, paths.RuntimeDir, "ovpn-creds")
+	ovpnMgmtDir = filepath.Join(root, paths.RuntimeDir, "ovpn-mgmt")
+	ovpnLogDir = filepath.Join(root, paths.RuntimeDir, "ovpn-logs")
+	ovpnLogArchiveDir = filepath.Join(root, paths.LogDir, "openvpn")
+	wgStagingDir = filepath.Join(root, paths.RuntimeDir, "wg")
+}
+
 // parseRouteForOpenVPN converts CIDR notation to network/netmask format.
 func parseRouteForOpenVPN(route string) (network, netmask string) {
 	// Handle CIDR notation (e.g., 10.0.0.0/8)
@@ -563,54 +1032,3 @@ func parseRouteForOpenVPN(route string) (network, netmask string) {
 
 	return "", ""
 }
-
-// extractIPFromLine tries to extract an IP address from a log line.
-// ipv4Regex matches IPv4 addresses in various contexts
-var ipv4Regex = regexp.MustCompile(`\b(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})\b`)
-
-// ifconfigIPRegex matches "ifconfig <IP>" pattern in PUSH_REPLY messages
-// Example: "...,ifconfig 10.8.0.6 255.255.255.0,..."
-var ifconfigIPRegex = regexp.MustCompile(`ifconfig\s+(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})`)
-
-// netAddrRegex matches OpenVPN 2.6+ format "net_addr_v4_add: IP/CIDR dev tunX"
-// Example: "net_addr_v4_add: 10.120.100.5/24 dev tun0"
-var netAddrRegex = regexp.MustCompile(`net_addr_v4_add:\s+(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3})`)
-
-func extractIPFromLine(line string) string {
-	// OpenVPN 2.6+ format: "net_addr_v4_add: 10.120.100.5/24 dev tun0"
-	if strings.Contains(line, "net_addr_v4_add:") {
-		if matches := netAddrRegex.FindStringSubmatch(line); len(matches) >= 2 {
-			ip := net.ParseIP(matches[1])
-			if ip != nil && ip.To4() != nil && !ip.IsLoopback() {
-				return ip.String()
-			}
-		}
-	}
-
-	// Legacy format: "ifconfig <IP>" pattern in PUSH_REPLY messages
-	// This handles both comma-separated PUSH_REPLY and standalone ifconfig lines
-	if strings.Contains(line, "ifconfig") {
-		if matches := ifconfigIPRegex.FindStringSubmatch(line); len(matches) >= 2 {
-			ip := net.ParseIP(matches[1])
-			if ip != nil && ip.To4() != nil && !ip.IsLoopback() {
-				return ip.String()
-			}
-		}
-	}
-
-	// Look for "ip addr" patterns or "local" keyword
-	if strings.Contains(line, "ip/") || strings.Contains(line, "local") {
-		// Find all IPv4 addresses in the line
-		matches := ipv4Regex.FindAllStringSubmatch(line, -1)
-		for _, match := range matches {
-			if len(match) >= 2 {
-				ip := net.ParseIP(match[1])
-				if ip != nil && ip.To4() != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
-					return ip.String()
-				}
-			}
-		}
-	}
-
-	return ""
-}
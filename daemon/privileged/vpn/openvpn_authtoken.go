@@ -0,0 +1,97 @@
+package vpn
+
+import (
+	"errors"
+	"time"
+)
+
+// =============================================================================
+// OPENVPN AUTH-TOKENS
+// =============================================================================
+//
+// A server running with auth-gen-token pushes a session token after the first
+// authentication, and openvpn sends it instead of the password whenever it
+// authenticates again, so a renegotiation or a restart after a network blip
+// does not need a new one-time code. openvpn keeps it for the life of the
+// process only: when the connection is restarted from scratch (the health
+// checker reconnecting a dead tunnel), the new process has none, and the user
+// would be asked for a code again.
+//
+// The daemon therefore keeps the last token each profile's server pushed
+// (">PASSWORD:Auth-Token:"), in memory only, and answers a connect that asks
+// for it (UseAuthToken) with the token instead of the password. A token is
+// reused only by the user whose connection received it, and only within
+// authTokenLifetime of the server last pushing it: servers renew tokens on
+// every renegotiation and refuse one that has not been renewed for a while. A
+// token the server rejects is forgotten.
+
+// authTokenLifetime is how long after the server last pushed a token it is
+// still offered: openvpn's default renegotiation interval, after which a
+// server expects a renewed one.
+const authTokenLifetime = time.Hour
+
+// ErrNoAuthToken is returned by Connect when UseAuthToken is set but the
+// caller has no unexpired token for the profile.
+var ErrNoAuthToken = errors.New("no auth-token is cached for this profile")
+
+// authToken is a token a profile's server pushed.
+type authToken struct {
+	username string // the username it was issued with
+	token    string
+	uid      uint32 // the user whose connection received it
+	pushed   time.Time
+}
+
+// usableBy reports whether uid may reuse the token at now.
+func (t authToken) usableBy(uid *uint32, now time.Time) bool {
+	return uid != nil && *uid == t.uid && now.Sub(t.pushed) < authTokenLifetime
+}
+
+// HasAuthToken reports whether uid could connect profileID with UseAuthToken.
+func (m *OpenVPNManager) HasAuthToken(profileID string, uid *uint32) bool {
+	_, ok := m.lookupAuthToken(profileID, uid)
+	return ok
+}
+
+// lookupAuthToken returns the token uid may reuse for profileID, dropping it
+// if it has expired.
+func (m *OpenVPNManager) lookupAuthToken(profileID string, uid *uint32) (authToken, bool) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	tok, ok := m.authTokens[profileID]
+	if !ok {
+		return authToken{}, false
+	}
+	if time.Since(tok.pushed) >= authTokenLifetime {
+		delete(m.authTokens, profileID)
+		return authToken{}, false
+	}
+	return tok, tok.usableBy(uid, time.Now())
+}
+
+// storeAuthToken records the token proc's server pushed. Processes whose user
+// is unknown (adopted after a daemon restart, or started by the daemon itself)
+// do not get theirs cached.
+func (m *OpenVPNManager) storeAuthToken(proc *OpenVPNProcess, token string) {
+	proc.mu.RLock()
+	uid, username := proc.uid, proc.authUser
+	proc.mu.RUnlock()
+	if uid == nil || username == "" {
+		return
+	}
+
+	m.tokensMu.Lock()
+	_, renewed := m.authTokens[proc.ProfileID]
+	m.authTokens[proc.ProfileID] = authToken{username: username, token: token, uid: *uid, pushed: time.Now()}
+	m.tokensMu.Unlock()
+	if !renewed {
+		m.logger.Printf("[openvpn] Server of profile %s pushed an auth-token; reconnects can reuse it", proc.ProfileID)
+	}
+}
+
+// forgetAuthToken drops profileID's token.
+func (m *OpenVPNManager) forgetAuthToken(profileID string) {
+	m.tokensMu.Lock()
+	delete(m.authTokens, profileID)
+	m.tokensMu.Unlock()
+}
@@ -0,0 +1,106 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// tokenSession follows a fake openvpn for profile p1 started by uid 1000, up
+// to the hold release. setup adjusts the process before it is followed.
+func tokenSession(t *testing.T, setup func(*OpenVPNProcess)) (*OpenVPNManager, *fakeMgmt) {
+	t.Helper()
+	useTempMgmtDir(t)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+
+	uid := uint32(1000)
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := &OpenVPNProcess{ProfileID: "p1", Status: StatusConnecting, mgmtSocket: socket, username: "alice", password: "hunter2", uid: &uid}
+	if setup != nil {
+		setup(proc)
+	}
+	m.processes["p1"] = proc
+	followFake(t, m, proc)
+	fake.await("hold release")
+	return m, fake
+}
+
+// TestAuthTokenCached verifies a pushed token is kept for the user whose
+// connection received it, with the username it was issued to, until it
+// expires.
+func TestAuthTokenCached(t *testing.T) {
+	m, fake := tokenSession(t, nil)
+	owner, other := uint32(1000), uint32(1001)
+
+	fake.send(">PASSWORD:Need 'Auth' username/password")
+	fake.await(`password "Auth" "hunter2"`)
+	fake.send(">PASSWORD:Auth-Token:SESS_ID_AT_abc")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !m.HasAuthToken("p1", &owner) {
+		if time.Now().After(deadline) {
+			t.Fatal("the pushed auth-token was not cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if tok, _ := m.lookupAuthToken("p1", &owner); tok.username != "alice" || tok.token != "SESS_ID_AT_abc" {
+		t.Errorf("cached token = %+v", tok)
+	}
+	if m.HasAuthToken("p1", &other) || m.HasAuthToken("p1", nil) {
+		t.Error("the token is offered to another user")
+	}
+	if m.HasAuthToken("p2", &owner) {
+		t.Error("the token is offered for another profile")
+	}
+
+	_, err := m.Connect(context.Background(), OpenVPNConnectParams{ProfileID: "p2", UseAuthToken: true, CallerUID: &owner})
+	if !errors.Is(err, ErrNoAuthToken) {
+		t.Errorf("Connect without a token = %v, want ErrNoAuthToken", err)
+	}
+
+	m.tokensMu.Lock()
+	tok := m.authTokens["p1"]
+	tok.pushed = time.Now().Add(-authTokenLifetime)
+	m.authTokens["p1"] = tok
+	m.tokensMu.Unlock()
+	if m.HasAuthToken("p1", &owner) {
+		t.Error("an expired token is still offered")
+	}
+	if _, ok := m.authTokens["p1"]; ok {
+		t.Error("an expired token was not dropped")
+	}
+}
+
+// TestAuthTokenAnswers verifies a connection started with a token answers the
+// static challenge with it instead of asking, and that a rejected token is
+// forgotten.
+func TestAuthTokenAnswers(t *testing.T) {
+	m, fake := tokenSession(t, func(p *OpenVPNProcess) {
+		p.password = "SESS_ID_AT_abc"
+		p.usingToken = true
+	})
+	owner := uint32(1000)
+	m.authTokens["p1"] = authToken{username: "alice", token: "SESS_ID_AT_abc", uid: owner, pushed: time.Now()}
+
+	fake.send(">PASSWORD:Need 'Auth' username/password SC:0,Enter your token code")
+	fake.await(`password "Auth" "SESS_ID_AT_abc"`)
+	if status, _ := m.Status("p1"); status.Challenge != nil {
+		t.Errorf("a token connection asked %+v", status.Challenge)
+	}
+
+	fake.send(">PASSWORD:Verification Failed: 'Auth'")
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.Status == StatusError })
+	if status.LastError != "Authentication failed" {
+		t.Errorf("LastError = %q", status.LastError)
+	}
+	if m.HasAuthToken("p1", &owner) {
+		t.Error("the rejected token is still offered")
+	}
+}
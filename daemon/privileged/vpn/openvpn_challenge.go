@@ -0,0 +1,234 @@
+package vpn
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// OPENVPN CHALLENGE/RESPONSE
+// =============================================================================
+//
+// Servers with two-factor authentication ask for more than the password, in
+// one of two ways:
+//
+//   - a static challenge: the profile's static-challenge directive makes
+//     openvpn ask for a response together with the password ("SC:" in the
+//     >PASSWORD request), sent back as SCRV1:<base64 password>:<base64
+//     response>;
+//   - a dynamic challenge: the server rejects the password with a CRV1
+//     question ("CRV1:<flags>:<state>:<base64 username>:<text>"). openvpn runs
+//     with --auth-retry interact, so it asks again, and the answer is sent as
+//     the password CRV1::<state>::<response>.
+//
+// Either way the question is published as the connection's Challenge, and the
+// connection waits in "connecting" until a client answers it with Respond.
+
+// Challenge kinds.
+const (
+	ChallengeStatic  = "static"
+	ChallengeDynamic = "dynamic"
+)
+
+// ErrNoChallenge is returned by Respond when the server is not waiting for an
+// answer.
+var ErrNoChallenge = errors.New("no challenge is waiting for a response")
+
+// OpenVPNChallenge is a question the server asked on top of the password.
+type OpenVPNChallenge struct {
+	// Kind is ChallengeStatic or ChallengeDynamic.
+	Kind string `json:"kind"`
+	// Text is the question, as the server worded it.
+	Text string `json:"text"`
+	// Echo means the response may be shown as it is typed (it is not a
+	// secret, like a push confirmation number).
+	Echo bool `json:"echo,omitempty"`
+	// ResponseRequired is false when an empty response is fine, for a
+	// challenge that is answered elsewhere (approving a push notification).
+	ResponseRequired bool `json:"response_required"`
+}
+
+// staticChallenge is the "SC:<flags>,<text>" part of a >PASSWORD request.
+type staticChallenge struct {
+	Text   string
+	Echo   bool // flag 1
+	Concat bool // flag 2: send the password and response concatenated
+}
+
+// crv1Challenge is the CRV1 question of a failed verification.
+type crv1Challenge struct {
+	Flags    string // comma-separated: "R" response required, "E" echo
+	State    string // opaque, sent back with the response
+	Username string // decoded; sent back instead of the profile's
+	Text     string
+}
+
+// challengeState is a connection's progress through a challenge. It lives in
+// OpenVPNProcess and is guarded by its mu.
+type challengeState struct {
+	// question is what was published as the Challenge; nil when none is
+	// waiting for a response.
+	question *OpenVPNChallenge
+	static   *staticChallenge
+	crv1     *crv1Challenge
+
+	// response is the client's answer, until it is sent.
+	response *string
+
+	// authWanted means openvpn is waiting for the Auth credentials.
+	authWanted bool
+
+	// failed means the credentials were rejected without a challenge; later
+	// requests are not answered, as the process is being stopped.
+	failed bool
+}
+
+// parseStaticChallenge parses the tail of "Need 'Auth' username/password
+// SC:<flags>,<text>".
+func parseStaticChallenge(rest string) (*staticChallenge, bool) {
+	_, sc, found := strings.Cut(rest, "SC:")
+	if !found {
+		return nil, false
+	}
+	flagField, text, found := strings.Cut(sc, ",")
+	if !found {
+		return nil, false
+	}
+	flags, err := strconv.Atoi(flagField)
+	if err != nil {
+		return nil, false
+	}
+	return &staticChallenge{Text: text, Echo: flags&1 != 0, Concat: flags&2 != 0}, true
+}
+
+// parseCRV1 parses the tail of "Verification Failed: 'Auth'
+// ['CRV1:<flags>:<state>:<base64 username>:<text>']".
+func parseCRV1(rest string) (*crv1Challenge, bool) {
+	_, quoted, found := strings.Cut(rest, "['")
+	if !found {
+		return nil, false
+	}
+	quoted = strings.TrimSuffix(quoted, "']")
+	body, ok := strings.CutPrefix(quoted, "CRV1:")
+	if !ok {
+		return nil, false
+	}
+	fields := strings.SplitN(body, ":", 4)
+	if len(fields) != 4 || fields[1] == "" {
+		return nil, false
+	}
+	username, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return nil, false
+	}
+	return &crv1Challenge{Flags: fields[0], State: fields[1], Username: string(username), Text: fields[3]}, true
+}
+
+// hasFlag reports whether a CRV1 flag is set.
+func (c *crv1Challenge) hasFlag(flag string) bool {
+	for f := range strings.SplitSeq(c.Flags, ",") {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// staticChallengePassword is the password answering a static challenge.
+func staticChallengePassword(sc *staticChallenge, password, response string) string {
+	if sc.Concat {
+		return password + response
+	}
+	return "SCRV1:" + base64.StdEncoding.EncodeToString([]byte(password)) + ":" +
+		base64.StdEncoding.EncodeToString([]byte(response))
+}
+
+// crv1Password is the password answering a dynamic challenge.
+func crv1Password(c *crv1Challenge, response string) string {
+	return "CRV1::" + c.State + "::" + response
+}
+
+// credentialsLocked works out the username and password to answer openvpn's
+// Auth request with, or reports false while the answer is still waiting on a
+// response. It consumes the response, as every answer is good once. Must be
+// called with proc.mu held.
+func (p *OpenVPNProcess) credentialsLocked() (username, password string, ok bool) {
+	ch := &p.challenge
+	switch {
+	case ch.crv1 != nil:
+		if ch.response == nil {
+			return "", "", false
+		}
+		username, password = ch.crv1.Username, crv1Password(ch.crv1, *ch.response)
+		ch.crv1 = nil
+	case ch.static != nil:
+		if ch.response == nil {
+			return "", "", false
+		}
+		username, password = p.username, staticChallengePassword(ch.static, p.password, *ch.response)
+		ch.static = nil
+	default:
+		username, password = p.username, p.password
+	}
+	ch.response = nil
+	ch.authWanted = false
+	p.authUser = username
+	return username, password, true
+}
+
+// askLocked publishes a question and waits for its response. Must be called
+// with proc.mu held.
+func (p *OpenVPNProcess) askLocked(question *OpenVPNChallenge) {
+	p.challenge.question = question
+	p.challenge.response = nil
+	p.Challenge = question
+}
+
+// Respond answers the challenge a profile's server asked. If openvpn is
+// already waiting for the credentials, they are sent right away; otherwise
+// they are sent when it asks.
+func (m *OpenVPNManager) Respond(profileID, response string) error {
+	if strings.ContainsAny(response, "\r\n") {
+		return errors.New("the response must not contain line breaks")
+	}
+
+	m.mu.RLock()
+	proc, ok := m.processes[profileID]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no OpenVPN connection for profile %s", profileID)
+	}
+
+	proc.mu.Lock()
+	if proc.challenge.question == nil {
+		proc.mu.Unlock()
+		return ErrNoChallenge
+	}
+	if proc.challenge.question.ResponseRequired && response == "" {
+		proc.mu.Unlock()
+		return errors.New("the server requires a response")
+	}
+	proc.challenge.question = nil
+	proc.challenge.response = &response
+	proc.Challenge = nil
+	c := proc.mgmt
+	var username, password string
+	send := false
+	if proc.challenge.authWanted {
+		username, password, send = proc.credentialsLocked()
+	}
+	proc.mu.Unlock()
+
+	m.logger.Printf("[openvpn] Challenge for profile %s answered", profileID)
+	m.notifyStateChange(proc)
+	if send {
+		if c == nil {
+			return errMgmtClosed
+		}
+		go m.sendCredentials(proc, c, "Auth", username, password)
+	}
+	return nil
+}
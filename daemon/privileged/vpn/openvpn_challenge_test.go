@@ -0,0 +1,138 @@
+package vpn
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestParseChallenges(t *testing.T) {
+	req, ok := parseMgmtPassword("Need 'Auth' username/password SC:1,Enter your token code")
+	if !ok || req.Static == nil || req.Static.Text != "Enter your token code" || !req.Static.Echo || req.Static.Concat {
+		t.Errorf("static challenge = %+v, %v", req.Static, ok)
+	}
+	if req, _ := parseMgmtPassword("Need 'Auth' username/password SC:2,PIN"); req.Static == nil || req.Static.Echo || !req.Static.Concat {
+		t.Errorf("flag 2 = %+v, want concatenated and hidden", req.Static)
+	}
+	if req, _ := parseMgmtPassword("Need 'Auth' username/password"); req.Static != nil {
+		t.Errorf("plain request parsed a static challenge %+v", req.Static)
+	}
+
+	req, ok = parseMgmtPassword("Verification Failed: 'Auth' ['CRV1:R,E:Om01u7Fh4LrGBS7uh0SWmzwabUiGiW6l:YWxpY2U=:Token PIN: ']")
+	if !ok || !req.Failed || req.CRV1 == nil {
+		t.Fatalf("CRV1 = %+v, %v", req, ok)
+	}
+	c := req.CRV1
+	if c.State != "Om01u7Fh4LrGBS7uh0SWmzwabUiGiW6l" || c.Username != "alice" || c.Text != "Token PIN: " {
+		t.Errorf("CRV1 = %+v", c)
+	}
+	if !c.hasFlag("R") || !c.hasFlag("E") || c.hasFlag("X") {
+		t.Errorf("flags %q", c.Flags)
+	}
+	for _, payload := range []string{
+		"Verification Failed: 'Auth'",
+		"Verification Failed: 'Auth' ['AUTH_FAILED']",
+		"Verification Failed: 'Auth' ['CRV1:R:state:!!notbase64:text']",
+		"Verification Failed: 'Auth' ['CRV1:R::YWxpY2U=:text']",
+	} {
+		if req, _ := parseMgmtPassword(payload); req.CRV1 != nil {
+			t.Errorf("%q parsed a CRV1 challenge %+v", payload, req.CRV1)
+		}
+	}
+}
+
+func TestChallengePasswords(t *testing.T) {
+	if got := staticChallengePassword(&staticChallenge{}, "hunter2", "123456"); got != "SCRV1:aHVudGVyMg==:MTIzNDU2" {
+		t.Errorf("SCRV1 = %q", got)
+	}
+	if got := staticChallengePassword(&staticChallenge{Concat: true}, "hunter2", "123456"); got != "hunter2123456" {
+		t.Errorf("concatenated = %q", got)
+	}
+	if got := crv1Password(&crv1Challenge{State: "abc"}, "987654"); got != "CRV1::abc::987654" {
+		t.Errorf("CRV1 = %q", got)
+	}
+}
+
+// challengeSession starts following a fake openvpn for profile p1 with
+// credentials, up to the hold release.
+func challengeSession(t *testing.T) (*OpenVPNManager, *fakeMgmt) {
+	t.Helper()
+	useTempMgmtDir(t)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := &OpenVPNProcess{ProfileID: "p1", Status: StatusConnecting, mgmtSocket: socket, username: "alice", password: "hunter2"}
+	m.processes["p1"] = proc
+	followFake(t, m, proc)
+	fake.await("hold release")
+	return m, fake
+}
+
+// TestStaticChallenge walks a static-challenge profile through its prompt:
+// the question is published, and the response goes back as SCRV1.
+func TestStaticChallenge(t *testing.T) {
+	m, fake := challengeSession(t)
+
+	if err := m.Respond("p1", "123456"); !errors.Is(err, ErrNoChallenge) {
+		t.Errorf("Respond before a challenge = %v, want ErrNoChallenge", err)
+	}
+
+	fake.send(">PASSWORD:Need 'Auth' username/password SC:0,Enter your token code")
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.Challenge != nil })
+	want := OpenVPNChallenge{Kind: ChallengeStatic, Text: "Enter your token code", ResponseRequired: true}
+	if *status.Challenge != want || status.Status != StatusConnecting {
+		t.Errorf("status = %+v, challenge %+v; want connecting with %+v", status, status.Challenge, want)
+	}
+	if err := m.Respond("p1", ""); err == nil {
+		t.Error("an empty response to a required challenge was accepted")
+	}
+
+	if err := m.Respond("p1", "123456"); err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	fake.await(`username "Auth" "alice"`)
+	fake.await(`password "Auth" "SCRV1:aHVudGVyMg==:MTIzNDU2"`)
+	if status, _ := m.Status("p1"); status.Challenge != nil {
+		t.Errorf("challenge %+v still published after the response", status.Challenge)
+	}
+	if err := m.Respond("p1", "123456"); !errors.Is(err, ErrNoChallenge) {
+		t.Errorf("second Respond = %v, want ErrNoChallenge", err)
+	}
+}
+
+// TestDynamicChallenge walks a CRV1 exchange: the first password is
+// rejected with a question, openvpn restarts and asks again, and the answer
+// goes back with the server's state and username.
+func TestDynamicChallenge(t *testing.T) {
+	m, fake := challengeSession(t)
+
+	fake.send(">PASSWORD:Need 'Auth' username/password")
+	fake.await(`password "Auth" "hunter2"`)
+
+	fake.send(">PASSWORD:Verification Failed: 'Auth' ['CRV1:R:s1:YWxpY2UtMg==:Approve or enter the code']",
+		">STATE:1700000010,RECONNECTING,auth-failure,,,,,")
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.State == "RECONNECTING" })
+	if status.Status != StatusConnecting || status.Challenge == nil || status.Challenge.Kind != ChallengeDynamic ||
+		status.Challenge.Text != "Approve or enter the code" || status.Challenge.Echo {
+		t.Fatalf("status = %+v, challenge %+v; want connecting with the CRV1 question", status, status.Challenge)
+	}
+
+	// openvpn asks before the user answers: the request waits.
+	fake.send(">PASSWORD:Need 'Auth' username/password")
+	if err := m.Respond("p1", "424242"); err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	fake.await(`username "Auth" "alice-2"`)
+	fake.await(`password "Auth" "CRV1::s1::424242"`)
+
+	fake.send(">STATE:1700000012,CONNECTED,SUCCESS,10.8.0.6,198.51.100.7,1194,,")
+	status = waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.Status == StatusConnected })
+	if status.Challenge != nil || status.LastError != "" {
+		t.Errorf("connected status = %+v", status)
+	}
+}
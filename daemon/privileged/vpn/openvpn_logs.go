@@ -0,0 +1,422 @@
+package vpn
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/paths"
+)
+
+// =============================================================================
+// OPENVPN LOGS
+// =============================================================================
+//
+// openvpn's output is kept per profile, in two places that outlive the process:
+//
+//   - a buffer of the last logBufferLines lines across sessions, which
+//     openvpn.logs reads and follows;
+//   - one file per session under ovpnLogArchiveDir/<profile>/, of which the
+//     last MaxOpenVPNLogSessions are kept. A restarted daemon seeds a
+//     profile's buffer from them, so past sessions survive it too.
+//
+// Lines read from the management interface carry openvpn's own time and level
+// flags, including the --management-log-cache history an adopted process
+// replays. Lines tailed from a legacy process's log (openvpn runs with
+// --suppress-timestamps) are timed when the daemon reads them and classified
+// by their text.
+
+// LogLevel is the severity of a log line, as far as openvpn's text tells.
+type LogLevel string
+
+const (
+	LogLevelInfo    LogLevel = "info"
+	LogLevelWarning LogLevel = "warning"
+	LogLevelError   LogLevel = "error"
+)
+
+// ParseLogLevel validates a level name. The empty name is LogLevelInfo, which
+// lets every line through.
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch l := LogLevel(s); l {
+	case "":
+		return LogLevelInfo, nil
+	case LogLevelInfo, LogLevelWarning, LogLevelError:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown log level %q (want info, warning or error)", s)
+}
+
+// severity orders the levels, info lowest.
+func (l LogLevel) severity() int {
+	switch l {
+	case LogLevelError:
+		return 2
+	case LogLevelWarning:
+		return 1
+	}
+	return 0
+}
+
+// Markers classifyLogLine looks for. openvpn has no levels in its text, but
+// the lines that matter when a connection fails say so in a few stable ways.
+var (
+	errorMarkers   = []string{"ERROR", "Options error", "AUTH_FAILED", "TLS Error", "TLS handshake failed", "fatal error", "FATAL"}
+	warningMarkers = []string{"WARNING", "DEPRECATED", "Cannot resolve", "process restarting", "Inactivity timeout"}
+)
+
+// classifyLogLine guesses the level of one line of openvpn output.
+func classifyLogLine(text string) LogLevel {
+	for _, marker := range errorMarkers {
+		if strings.Contains(text, marker) {
+			return LogLevelError
+		}
+	}
+	for _, marker := range warningMarkers {
+		if strings.Contains(text, marker) {
+			return LogLevelWarning
+		}
+	}
+	return LogLevelInfo
+}
+
+// OpenVPNLogLine is one line of a profile's openvpn log.
+type OpenVPNLogLine struct {
+	// Seq numbers the profile's lines since the daemon started. A follower
+	// that fell behind sees a gap.
+	Seq   uint64    `json:"seq"`
+	Time  time.Time `json:"time"`
+	Level LogLevel  `json:"level"`
+	Text  string    `json:"text"`
+}
+
+// OpenVPNLogFilter selects log lines. The zero value selects all of them.
+type OpenVPNLogFilter struct {
+	// Since drops lines from before it.
+	Since time.Time
+	// Level drops lines less severe than it.
+	Level LogLevel
+}
+
+// Match reports whether line passes the filter.
+func (f OpenVPNLogFilter) Match(line OpenVPNLogLine) bool {
+	if !f.Since.IsZero() && line.Time.Before(f.Since) {
+		return false
+	}
+	return line.Level.severity() >= f.Level.severity()
+}
+
+const (
+	// logBufferLines is how many lines each profile's buffer holds.
+	logBufferLines = 1000
+
+	// MaxOpenVPNLogSessions is how many session files are kept per profile.
+	MaxOpenVPNLogSessions = 5
+
+	// maxSessionLogSize caps one session's file. openvpn at --verb 3 is
+	// quiet once connected; only a connection that keeps failing gets here.
+	maxSessionLogSize = 4 << 20
+
+	// logFollowerQueue is how many lines a follower may fall behind by
+	// before it misses some.
+	logFollowerQueue = 256
+)
+
+// Markers recorded around each session.
+const (
+	sessionStartedText = "--- OpenVPN session started ---"
+	sessionEndedText   = "--- OpenVPN session ended ---"
+)
+
+// ovpnLogArchiveDir is the root-only directory holding the log files of past
+// sessions. Package-level var (not const) so tests can redirect it to a temp
+// dir; production code never reassigns it.
+var ovpnLogArchiveDir = filepath.Join(paths.LogDir, "openvpn")
+
+// profileLog is one profile's log. It is safe for concurrent use.
+type profileLog struct {
+	mu        sync.Mutex
+	lines     []OpenVPNLogLine // oldest first
+	seq       uint64
+	followers map[chan OpenVPNLogLine]struct{}
+
+	// The running session's file, nil between sessions or once it is full.
+	archive     *os.File
+	archiveSize int64
+}
+
+// record adds a line, writes it to the session's file and hands it to the
+// followers. It never blocks on a follower.
+func (l *profileLog) record(t time.Time, level LogLevel, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	line := OpenVPNLogLine{Seq: l.seq, Time: t, Level: level, Text: text}
+	if len(l.lines) >= logBufferLines {
+		l.lines = l.lines[1:]
+	}
+	l.lines = append(l.lines, line)
+	l.writeArchive(line)
+
+	for ch := range l.followers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// writeArchive appends line to the session's file. The file is best effort:
+// on an error, or once it reaches maxSessionLogSize, the rest of the session
+// is only kept in memory. Must be called with l.mu held.
+func (l *profileLog) writeArchive(line OpenVPNLogLine) {
+	if l.archive == nil {
+		return
+	}
+	entry := formatArchiveLine(line)
+	if l.archiveSize+int64(len(entry)) > maxSessionLogSize {
+		entry = formatArchiveLine(OpenVPNLogLine{Time: line.Time, Level: LogLevelWarning, Text: "--- log truncated ---"})
+		_, _ = l.archive.WriteString(entry)
+		l.closeArchive()
+		return
+	}
+	n, err := l.archive.WriteString(entry)
+	l.archiveSize += int64(n)
+	if err != nil {
+		l.closeArchive()
+	}
+}
+
+// closeArchive closes the session's file. Must be called with l.mu held.
+func (l *profileLog) closeArchive() {
+	if l.archive != nil {
+		_ = l.archive.Close()
+		l.archive = nil
+	}
+}
+
+// startSession switches to a new session's file (nil to keep none) and
+// records the start marker.
+func (l *profileLog) startSession(archive *os.File, start time.Time) {
+	l.mu.Lock()
+	l.closeArchive()
+	l.archive, l.archiveSize = archive, 0
+	l.mu.Unlock()
+
+	l.record(start, LogLevelInfo, sessionStartedText)
+}
+
+// endSession records the end marker, with the exit error if any, and closes
+// the session's file.
+func (l *profileLog) endSession(exitErr error) {
+	if exitErr != nil {
+		l.record(time.Now(), LogLevelError, fmt.Sprintf("%s (%v)", sessionEndedText, exitErr))
+	} else {
+		l.record(time.Now(), LogLevelInfo, sessionEndedText)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeArchive()
+}
+
+// snapshot returns the buffered lines that pass filter.
+func (l *profileLog) snapshot(filter OpenVPNLogFilter) []OpenVPNLogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.matchingLocked(filter)
+}
+
+// matchingLocked filters the buffer. Must be called with l.mu held.
+func (l *profileLog) matchingLocked(filter OpenVPNLogFilter) []OpenVPNLogLine {
+	lines := make([]OpenVPNLogLine, 0, len(l.lines))
+	for _, line := range l.lines {
+		if filter.Match(line) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// profileLog returns profileID's log, creating it on first use from the
+// profile's session files except skip (the file of a session being adopted,
+// which is about to be replayed).
+func (m *OpenVPNManager) profileLog(profileID, skip string) *profileLog {
+	m.logsMu.Lock()
+	defer m.logsMu.Unlock()
+
+	if l, ok := m.logs[profileID]; ok {
+		return l
+	}
+	l := &profileLog{followers: make(map[chan OpenVPNLogLine]struct{})}
+	for _, line := range loadArchivedLines(profileID, skip) {
+		l.seq++
+		line.Seq = l.seq
+		l.lines = append(l.lines, line)
+	}
+	m.logs[profileID] = l
+	return l
+}
+
+// startSessionLog gives proc a log and opens the file for its session. Call it
+// before proc's output is read.
+func (m *OpenVPNManager) startSessionLog(proc *OpenVPNProcess) {
+	name := sessionLogName(proc.StartTime, proc.logFile)
+	proc.log = m.profileLog(proc.ProfileID, name)
+
+	var archive *os.File
+	if name != "" {
+		var err error
+		archive, err = openSessionArchive(proc.ProfileID, name)
+		if err != nil {
+			m.logger.Printf("[openvpn] Not keeping this session's log for profile %s: %v", proc.ProfileID, err)
+		}
+	}
+	proc.log.startSession(archive, proc.StartTime)
+}
+
+// Logs returns profileID's buffered log lines that pass filter, oldest first.
+func (m *OpenVPNManager) Logs(profileID string, filter OpenVPNLogFilter) []OpenVPNLogLine {
+	return m.profileLog(profileID, "").snapshot(filter)
+}
+
+// FollowLogs returns what Logs would, and a channel that receives every line
+// of profileID's log recorded from then on, unfiltered. A follower more than
+// logFollowerQueue lines behind misses lines (see OpenVPNLogLine.Seq). stop
+// unregisters the channel, which is never closed.
+func (m *OpenVPNManager) FollowLogs(profileID string, filter OpenVPNLogFilter) (lines []OpenVPNLogLine, next <-chan OpenVPNLogLine, stop func()) {
+	l := m.profileLog(profileID, "")
+	ch := make(chan OpenVPNLogLine, logFollowerQueue)
+
+	l.mu.Lock()
+	lines = l.matchingLocked(filter)
+	l.followers[ch] = struct{}{}
+	l.mu.Unlock()
+
+	return lines, ch, func() {
+		l.mu.Lock()
+		delete(l.followers, ch)
+		l.mu.Unlock()
+	}
+}
+
+// archiveDirName is profileID as a directory name: escaped, so no ID can name
+// a path outside ovpnLogArchiveDir, and reversible, so two IDs never share one.
+func archiveDirName(profileID string) string {
+	return strings.ReplaceAll(url.PathEscape(profileID), ".", "%2E")
+}
+
+// sessionLogName names a session's file after its start (to the second, which
+// is what a restarted daemon knows) and its --log file, which is unique. An
+// adopted session so gets the file its first daemon opened. Empty without a
+// --log file: there is nothing to keep then.
+func sessionLogName(start time.Time, logFile string) string {
+	if logFile == "" {
+		return ""
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(logFile), "ovpn-"), ".log")
+	return start.UTC().Format("20060102T150405Z") + "-" + id + ".log"
+}
+
+// openSessionArchive creates (or, for an adopted session, truncates) a
+// session's file and deletes the profile's oldest files beyond
+// MaxOpenVPNLogSessions. The files are root-only, like the --log they copy.
+func openSessionArchive(profileID, name string) (*os.File, error) {
+	dir := filepath.Join(ovpnLogArchiveDir, archiveDirName(profileID))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	files := sessionArchives(dir)
+	for len(files) > MaxOpenVPNLogSessions {
+		_ = os.Remove(filepath.Join(dir, files[0]))
+		files = files[1:]
+	}
+	return f, nil
+}
+
+// sessionArchives lists a profile's session files, oldest first.
+func sessionArchives(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), ".log") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// loadArchivedLines reads up to logBufferLines of the newest lines from
+// profileID's session files, except skip, oldest first.
+func loadArchivedLines(profileID, skip string) []OpenVPNLogLine {
+	dir := filepath.Join(ovpnLogArchiveDir, archiveDirName(profileID))
+	files := sessionArchives(dir)
+
+	var lines []OpenVPNLogLine
+	for i := len(files) - 1; i >= 0 && len(lines) < logBufferLines; i-- {
+		if files[i] == skip {
+			continue
+		}
+		lines = append(readArchive(filepath.Join(dir, files[i])), lines...)
+	}
+	if len(lines) > logBufferLines {
+		lines = lines[len(lines)-logBufferLines:]
+	}
+	return lines
+}
+
+// readArchive parses a session file, skipping lines it cannot read.
+func readArchive(path string) []OpenVPNLogLine {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []OpenVPNLogLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line, ok := parseArchiveLine(scanner.Text()); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formatArchiveLine writes a line as "<RFC 3339 time> <level> <text>\n".
+func formatArchiveLine(line OpenVPNLogLine) string {
+	return line.Time.UTC().Format(time.RFC3339Nano) + " " + string(line.Level) + " " + line.Text + "\n"
+}
+
+// parseArchiveLine reverses formatArchiveLine.
+func parseArchiveLine(s string) (OpenVPNLogLine, bool) {
+	parts := strings.SplitN(s, " ", 3)
+	if len(parts) != 3 {
+		return OpenVPNLogLine{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return OpenVPNLogLine{}, false
+	}
+	level, err := ParseLogLevel(parts[1])
+	if err != nil {
+		return OpenVPNLogLine{}, false
+	}
+	return OpenVPNLogLine{Time: t, Level: level, Text: parts[2]}, true
+}
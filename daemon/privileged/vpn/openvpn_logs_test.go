@@ -0,0 +1,218 @@
+package vpn
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyLogLine(t *testing.T) {
+	tests := map[string]LogLevel{
+		"OpenVPN 2.6.9 x86_64-pc-linux-gnu":                                 LogLevelInfo,
+		"Initialization Sequence Completed":                                 LogLevelInfo,
+		"WARNING: file 'auth.txt' is group or others accessible":            LogLevelWarning,
+		"DEPRECATED OPTION: --cipher set to 'AES-256-CBC'":                  LogLevelWarning,
+		"SIGUSR1[soft,tls-error] received, process restarting":              LogLevelWarning,
+		"RESOLVE: Cannot resolve host address: vpn.example.com:1194":        LogLevelWarning,
+		"AUTH: Received control message: AUTH_FAILED":                       LogLevelError,
+		"TLS Error: TLS handshake failed":                                   LogLevelError,
+		"Options error: Unrecognized option or missing parameter(s) in x:3": LogLevelError,
+		"Exiting due to fatal error":                                        LogLevelError,
+	}
+	for text, want := range tests {
+		if got := classifyLogLine(text); got != want {
+			t.Errorf("classifyLogLine(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestOpenVPNLogFilter(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	lines := []OpenVPNLogLine{
+		{Time: t0, Level: LogLevelInfo},
+		{Time: t0.Add(time.Second), Level: LogLevelWarning},
+		{Time: t0.Add(2 * time.Second), Level: LogLevelError},
+		{Time: t0.Add(3 * time.Second), Level: LogLevelInfo},
+	}
+	count := func(f OpenVPNLogFilter) int {
+		n := 0
+		for _, line := range lines {
+			if f.Match(line) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := count(OpenVPNLogFilter{}); n != 4 {
+		t.Errorf("zero filter kept %d lines, want 4", n)
+	}
+	if n := count(OpenVPNLogFilter{Level: LogLevelWarning}); n != 2 {
+		t.Errorf("warning filter kept %d lines, want 2", n)
+	}
+	if n := count(OpenVPNLogFilter{Since: t0.Add(2 * time.Second)}); n != 2 {
+		t.Errorf("since filter kept %d lines, want 2", n)
+	}
+	if n := count(OpenVPNLogFilter{Since: t0.Add(time.Second), Level: LogLevelError}); n != 1 {
+		t.Errorf("combined filter kept %d lines, want 1", n)
+	}
+	if _, err := ParseLogLevel("debug"); err == nil {
+		t.Error("an unknown level should be rejected")
+	}
+}
+
+// fakeSession stands in for a process started at start with the given --log
+// file, and gives it a log.
+func fakeSession(m *OpenVPNManager, profileID string, start time.Time, logName string) *OpenVPNProcess {
+	proc := &OpenVPNProcess{
+		ProfileID: profileID,
+		StartTime: start,
+		logFile:   filepath.Join(ovpnLogDir, logName),
+	}
+	m.startSessionLog(proc)
+	return proc
+}
+
+func logTexts(lines []OpenVPNLogLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return texts
+}
+
+func TestSessionLogSurvivesRestart(t *testing.T) {
+	useTempLogDir(t)
+	profile := "../office"
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := fakeSession(m, profile, time.Now(), "ovpn-1.log")
+	m.handleOutputLine(proc, "WARNING: something odd")
+	m.handleOutputLine(proc, "Initialization Sequence Completed")
+	proc.log.endSession(errors.New("exit status 1"))
+
+	files, _ := filepath.Glob(filepath.Join(ovpnLogArchiveDir, "*", "*.log"))
+	if len(files) != 1 || !strings.HasPrefix(files[0], ovpnLogArchiveDir+"/") {
+		t.Fatalf("session files = %v, want one under %s", files, ovpnLogArchiveDir)
+	}
+	if info, err := os.Stat(files[0]); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("session file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	// A restarted daemon reads the session back.
+	m = NewOpenVPNManager(log.New(io.Discard, "", 0))
+	got := m.Logs(profile, OpenVPNLogFilter{})
+	want := []string{sessionStartedText, "WARNING: something odd", "Initialization Sequence Completed", sessionEndedText + " (exit status 1)"}
+	if !slices.Equal(logTexts(got), want) {
+		t.Fatalf("reloaded lines = %q, want %q", logTexts(got), want)
+	}
+	if got[1].Level != LogLevelWarning || got[3].Level != LogLevelError || got[3].Seq != 4 {
+		t.Errorf("reloaded lines lost their level or numbering: %+v", got)
+	}
+}
+
+func TestSessionLogPruned(t *testing.T) {
+	useTempLogDir(t)
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+
+	start := time.Unix(1000, 0)
+	for i := range MaxOpenVPNLogSessions + 2 {
+		proc := fakeSession(m, "office", start.Add(time.Duration(i)*time.Minute), "ovpn-"+string(rune('a'+i))+".log")
+		proc.log.endSession(nil)
+	}
+
+	files := sessionArchives(filepath.Join(ovpnLogArchiveDir, "office"))
+	if len(files) != MaxOpenVPNLogSessions {
+		t.Fatalf("%d session files kept, want %d", len(files), MaxOpenVPNLogSessions)
+	}
+	if oldest := sessionLogName(start.Add(2*time.Minute), "ovpn-c.log"); files[0] != oldest {
+		t.Errorf("oldest kept file = %s, want %s", files[0], oldest)
+	}
+}
+
+// TestAdoptedSessionLogNotDuplicated checks that a session re-adopted after a
+// restart reuses its file: the replay of its --log replaces the copy the
+// previous daemon wrote instead of adding to it.
+func TestAdoptedSessionLogNotDuplicated(t *testing.T) {
+	useTempLogDir(t)
+	start := time.Now().Truncate(time.Second)
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := fakeSession(m, "office", start, "ovpn-1.log")
+	m.handleOutputLine(proc, "Initialization Sequence Completed")
+
+	m = NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc = fakeSession(m, "office", start, "ovpn-1.log")
+	m.handleOutputLine(proc, "Initialization Sequence Completed")
+	proc.log.endSession(nil)
+
+	want := []string{sessionStartedText, "Initialization Sequence Completed", sessionEndedText}
+	if got := logTexts(m.Logs("office", OpenVPNLogFilter{})); !slices.Equal(got, want) {
+		t.Errorf("buffer = %q, want %q", got, want)
+	}
+	got := logTexts(NewOpenVPNManager(nil).Logs("office", OpenVPNLogFilter{}))
+	if !slices.Equal(got, want) {
+		t.Errorf("session file = %q, want %q", got, want)
+	}
+}
+
+func TestLogBufferBounded(t *testing.T) {
+	useTempLogDir(t)
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := fakeSession(m, "office", time.Now(), "ovpn-1.log")
+	for range logBufferLines + 5 {
+		m.handleOutputLine(proc, "data")
+	}
+
+	lines := m.Logs("office", OpenVPNLogFilter{})
+	if len(lines) != logBufferLines {
+		t.Fatalf("buffer holds %d lines, want %d", len(lines), logBufferLines)
+	}
+	if lines[0].Seq != 7 {
+		t.Errorf("oldest buffered line is #%d, want #7", lines[0].Seq)
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	useTempLogDir(t)
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+
+	// Following works before the profile's first session.
+	backlog, next, stop := m.FollowLogs("office", OpenVPNLogFilter{})
+	if len(backlog) != 0 {
+		t.Fatalf("backlog = %q, want none", logTexts(backlog))
+	}
+
+	proc := fakeSession(m, "office", time.Now(), "ovpn-1.log")
+	m.handleOutputLine(proc, "TLS Error: TLS handshake failed")
+	for _, want := range []string{sessionStartedText, "TLS Error: TLS handshake failed"} {
+		select {
+		case line := <-next:
+			if line.Text != want {
+				t.Errorf("followed %q, want %q", line.Text, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no line %q", want)
+		}
+	}
+
+	stop()
+	m.handleOutputLine(proc, "after stop")
+	select {
+	case line := <-next:
+		t.Errorf("stopped follower got %q", line.Text)
+	default:
+	}
+
+	backlog, _, stop = m.FollowLogs("office", OpenVPNLogFilter{Level: LogLevelError})
+	defer stop()
+	if got := logTexts(backlog); !slices.Equal(got, []string{"TLS Error: TLS handshake failed"}) {
+		t.Errorf("filtered backlog = %q", got)
+	}
+}
@@ -0,0 +1,688 @@
+package vpn
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/paths"
+)
+
+// =============================================================================
+// OPENVPN MANAGEMENT INTERFACE
+// =============================================================================
+//
+// Every openvpn the daemon starts listens on a root-only management socket
+// (--management <path> unix) and holds until the daemon connects to it. The
+// daemon then drives the process through it instead of reading its log:
+//
+//   - >STATE notifications are the connection state, with the tunnel and
+//     server addresses (see nextStatus);
+//   - >BYTECOUNT notifications are the traffic counters;
+//   - >PASSWORD requests are answered with the credentials held in memory,
+//     so they never touch the disk, and carry two-factor challenges (see
+//     openvpn_challenge.go);
+//   - >LOG notifications are openvpn's output, with its own time and level;
+//   - "signal SIGTERM" stops the process cleanly.
+//
+// The socket outlives a daemon restart like the process does, so the next
+// instance reconnects to it and gets the history of states and log lines.
+
+// ovpnMgmtDir is the root-only directory holding the management sockets. Only
+// root can connect to a socket in it. Package-level var (not const) so tests
+// can redirect it to a temp dir; production code never reassigns it.
+var ovpnMgmtDir = filepath.Join(paths.RuntimeDir, "ovpn-mgmt")
+
+const (
+	// mgmtDialInterval is how often the daemon tries to connect while
+	// openvpn has not opened its socket yet.
+	mgmtDialInterval = 100 * time.Millisecond
+
+	// mgmtCommandTimeout bounds the wait for openvpn's answer to a command.
+	mgmtCommandTimeout = 10 * time.Second
+
+	// mgmtByteCountInterval is how often, in seconds, openvpn reports the
+	// traffic counters.
+	mgmtByteCountInterval = 5
+
+	// mgmtDrainTimeout is how long the daemon keeps reading the management
+	// socket after openvpn has exited.
+	mgmtDrainTimeout = 2 * time.Second
+
+	// openvpnStopTimeout is how long Disconnect waits for openvpn to exit
+	// after SIGTERM before killing it.
+	openvpnStopTimeout = 5 * time.Second
+)
+
+// errMgmtClosed is returned for commands sent to, or pending on, a management
+// connection that has been closed.
+var errMgmtClosed = errors.New("management connection closed")
+
+// createManagementSocketPath picks a fresh, random socket path in ovpnMgmtDir.
+// openvpn creates the socket; the daemon only makes the directory 0700.
+func createManagementSocketPath() (string, error) {
+	if err := os.MkdirAll(ovpnMgmtDir, 0700); err != nil {
+		return "", err
+	}
+	randBytes := make([]byte, 8)
+	if _, err := rand.Read(randBytes); err != nil {
+		return "", fmt.Errorf("failed to generate random socket name: %w", err)
+	}
+	path := filepath.Join(ovpnMgmtDir, "ovpn-"+hex.EncodeToString(randBytes)+".sock")
+	// sun_path holds 108 bytes including the terminating NUL.
+	if len(path) > 107 {
+		return "", fmt.Errorf("management socket path %s is too long", path)
+	}
+	return path, nil
+}
+
+// removeManagementSocket deletes a management socket (best-effort), only if
+// it lives in ovpnMgmtDir.
+func removeManagementSocket(path string) {
+	if strings.HasPrefix(path, ovpnMgmtDir+"/") {
+		_ = os.Remove(path)
+	}
+}
+
+// mgmtClient is a connection to one openvpn's management interface. One
+// goroutine runs read, which handles notifications in the order openvpn sent
+// them and hands the other lines to the command waiting for them.
+type mgmtClient struct {
+	conn  net.Conn
+	cmdMu sync.Mutex // One command in flight at a time
+
+	mu      sync.Mutex
+	pending *mgmtCommand
+	closed  chan struct{} // Closed once read returns
+}
+
+// mgmtCommand is a command waiting for its answer.
+type mgmtCommand struct {
+	// history, if set, receives the lines between SUCCESS and END that
+	// "state on all" and "log on all" send, on the read goroutine.
+	history func(line string)
+	started bool // SUCCESS seen (history commands only)
+
+	reply string
+	err   error
+	done  chan struct{}
+}
+
+// dialManagement connects to the management socket at path, retrying until
+// openvpn has opened it or ctx is done.
+func dialManagement(ctx context.Context, path string) (*mgmtClient, error) {
+	var d net.Dialer
+	for {
+		conn, err := d.DialContext(ctx, "unix", path)
+		if err == nil {
+			return &mgmtClient{conn: conn, closed: make(chan struct{})}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connect to management socket: %w", err)
+		case <-time.After(mgmtDialInterval):
+		}
+	}
+}
+
+// command sends cmd and returns openvpn's SUCCESS message. An ERROR answer is
+// returned as an error. Errors name only the command's first word: the rest
+// may be a password.
+func (c *mgmtClient) command(cmd string) (string, error) {
+	return c.do(cmd, nil)
+}
+
+// historyCommand sends a "<kind> on all" command, passing each history line
+// to fn on the read goroutine, so that the history is handled before any
+// notification that follows it.
+func (c *mgmtClient) historyCommand(cmd string, fn func(line string)) error {
+	_, err := c.do(cmd, fn)
+	return err
+}
+
+func (c *mgmtClient) do(cmd string, history func(string)) (string, error) {
+	verb, _, _ := strings.Cut(cmd, " ")
+
+	c.cmdMu.Lock()
+	defer c.cmdMu.Unlock()
+
+	p := &mgmtCommand{history: history, done: make(chan struct{})}
+	c.mu.Lock()
+	c.pending = p
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if c.pending == p {
+			c.pending = nil
+		}
+		c.mu.Unlock()
+	}()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(mgmtCommandTimeout))
+	if _, err := io.WriteString(c.conn, cmd+"\n"); err != nil {
+		return "", fmt.Errorf("management command %s: %w", verb, err)
+	}
+
+	timer := time.NewTimer(mgmtCommandTimeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+		if p.err != nil {
+			return "", fmt.Errorf("management command %s: %w", verb, p.err)
+		}
+		return p.reply, nil
+	case <-c.closed:
+		return "", fmt.Errorf("management command %s: %w", verb, errMgmtClosed)
+	case <-timer.C:
+		return "", fmt.Errorf("management command %s: no answer within %s", verb, mgmtCommandTimeout)
+	}
+}
+
+// read reads until the connection closes, passing each notification (without
+// its leading '>') to notify and every other line to the pending command.
+func (c *mgmtClient) read(notify func(kind, payload string)) {
+	defer close(c.closed)
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if rest, ok := strings.CutPrefix(line, ">"); ok {
+			kind, payload, _ := strings.Cut(rest, ":")
+			notify(kind, payload)
+			continue
+		}
+
+		c.mu.Lock()
+		p := c.pending
+		c.mu.Unlock()
+		if p == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "ERROR:"):
+			c.finish(p, "", errors.New(strings.TrimSpace(strings.TrimPrefix(line, "ERROR:"))))
+		case p.history == nil:
+			if reply, ok := strings.CutPrefix(line, "SUCCESS:"); ok {
+				c.finish(p, strings.TrimSpace(reply), nil)
+			}
+		case !p.started:
+			p.started = strings.HasPrefix(line, "SUCCESS:")
+		case line == "END":
+			c.finish(p, "", nil)
+		default:
+			p.history(line)
+		}
+	}
+}
+
+// finish completes p, unless its caller has already given up on it.
+func (c *mgmtClient) finish(p *mgmtCommand, reply string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != p {
+		return
+	}
+	c.pending = nil
+	p.reply, p.err = reply, err
+	close(p.done)
+}
+
+// Close closes the connection; read then returns.
+func (c *mgmtClient) Close() error {
+	return c.conn.Close()
+}
+
+// mgmtQuote quotes s as a management command argument.
+func mgmtQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// mgmtState is one >STATE notification or state history line:
+// "time,state,detail,local_ip,remote_ip,remote_port,...".
+type mgmtState struct {
+	Time       time.Time
+	Name       string
+	Detail     string
+	LocalIP    string
+	RemoteIP   string
+	RemotePort string
+}
+
+func parseMgmtState(payload string) (mgmtState, bool) {
+	fields := strings.Split(payload, ",")
+	if len(fields) < 2 || fields[1] == "" {
+		return mgmtState{}, false
+	}
+	st := mgmtState{Time: parseMgmtTime(fields[0]), Name: fields[1]}
+	for i, dst := range []*string{&st.Detail, &st.LocalIP, &st.RemoteIP, &st.RemotePort} {
+		if len(fields) > i+2 {
+			*dst = fields[i+2]
+		}
+	}
+	return st, true
+}
+
+// parseMgmtLog parses a >LOG notification or log history line:
+// "time,flags,message". The flags say how openvpn rated the message: F is
+// fatal and N a non-fatal error, W a warning. Other messages are classified
+// by their text.
+func parseMgmtLog(payload string) (t time.Time, level LogLevel, text string, ok bool) {
+	fields := strings.SplitN(payload, ",", 3)
+	if len(fields) != 3 {
+		return time.Time{}, "", "", false
+	}
+	t, text = parseMgmtTime(fields[0]), fields[2]
+	switch {
+	case strings.ContainsAny(fields[1], "FN"):
+		level = LogLevelError
+	case strings.Contains(fields[1], "W"):
+		level = LogLevelWarning
+	default:
+		level = classifyLogLine(text)
+	}
+	return t, level, text, true
+}
+
+// parseMgmtByteCount parses a >BYTECOUNT notification: "bytes_in,bytes_out".
+func parseMgmtByteCount(payload string) (in, out uint64, ok bool) {
+	inField, outField, found := strings.Cut(payload, ",")
+	if !found {
+		return 0, 0, false
+	}
+	in, errIn := strconv.ParseUint(inField, 10, 64)
+	out, errOut := strconv.ParseUint(outField, 10, 64)
+	return in, out, errIn == nil && errOut == nil
+}
+
+// parseMgmtTime parses a Unix time field; a missing or bad one is now.
+func parseMgmtTime(field string) time.Time {
+	if secs, err := strconv.ParseInt(field, 10, 64); err == nil && secs > 0 {
+		return time.Unix(secs, 0)
+	}
+	return time.Now()
+}
+
+// mgmtPasswordRequest is a >PASSWORD notification.
+type mgmtPasswordRequest struct {
+	Realm  string // 'Auth', 'Private Key', 'HTTP Proxy', ...
+	Failed bool   // "Verification Failed" rather than "Need"
+
+	Static *staticChallenge // A static challenge asked with the password
+	CRV1   *crv1Challenge   // A dynamic challenge the failure carries
+
+	AuthToken string // The token of an "Auth-Token:" notice, not a request
+}
+
+// parseMgmtPassword parses "Need 'Auth' username/password [SC:...]",
+// "Verification Failed: 'Auth' ['CRV1:...']" and "Auth-Token:<token>".
+func parseMgmtPassword(payload string) (mgmtPasswordRequest, bool) {
+	var req mgmtPasswordRequest
+	if token, ok := strings.CutPrefix(payload, "Auth-Token:"); ok {
+		req.Realm, req.AuthToken = "Auth", token
+		return req, token != ""
+	}
+	rest, ok := strings.CutPrefix(payload, "Need '")
+	if !ok {
+		if rest, ok = strings.CutPrefix(payload, "Verification Failed: '"); !ok {
+			return req, false
+		}
+		req.Failed = true
+	}
+	realm, rest, found := strings.Cut(rest, "'")
+	if !found {
+		return req, false
+	}
+	req.Realm = realm
+	if req.Failed {
+		req.CRV1, _ = parseCRV1(rest)
+	} else {
+		req.Static, _ = parseStaticChallenge(rest)
+	}
+	return req, true
+}
+
+// nextStatus is the connection state machine: the status a connection in
+// status moves to on an openvpn state, and the error to record with it ("" to
+// keep the last one). An error sticks until openvpn connects, and a requested
+// disconnect until the process exits. While challenged (a CRV1 question is
+// waiting for its response), the server's rejection is part of the exchange
+// rather than a failure.
+func nextStatus(status string, st mgmtState, challenged bool) (string, string) {
+	switch st.Name {
+	case "CONNECTED":
+		if status == StatusDisconnecting {
+			return status, ""
+		}
+		return StatusConnected, ""
+	case "RECONNECTING", "EXITING":
+		switch st.Detail {
+		case "auth-failure":
+			if challenged && st.Name == "RECONNECTING" {
+				return StatusConnecting, ""
+			}
+			return StatusError, "Authentication failed"
+		case "tls-error":
+			return StatusError, "TLS handshake failed"
+		}
+		if status == StatusError || status == StatusDisconnecting {
+			return status, ""
+		}
+		if st.Name == "EXITING" {
+			return StatusDisconnecting, ""
+		}
+		return StatusConnecting, ""
+	}
+	// CONNECTING, RESOLVE, TCP_CONNECT, WAIT, AUTH, AUTH_PENDING, GET_CONFIG,
+	// ASSIGN_IP, ADD_ROUTES and any state a later openvpn adds.
+	if status == StatusError || status == StatusDisconnecting {
+		return status, ""
+	}
+	return StatusConnecting, ""
+}
+
+// followManagement connects to proc's management socket, catches up on the
+// state and log history, releases the hold and then handles notifications
+// until openvpn exits. If the process exits before its socket can be reached
+// (an option error, say), the lines it wrote to its --log are read instead.
+func (m *OpenVPNManager) followManagement(proc *OpenVPNProcess) {
+	defer close(proc.logDone)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-proc.exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	c, err := dialManagement(ctx, proc.mgmtSocket)
+	cancel()
+	if err != nil {
+		m.logger.Printf("[openvpn] No management connection for profile %s, reading its log: %v", proc.ProfileID, err)
+		m.tailLog(proc)
+		return
+	}
+	defer func() { _ = c.Close() }()
+
+	// openvpn's exit closes the socket. Should it linger (a stuck child
+	// holding it), stop reading once the rest has had time to arrive.
+	go func() {
+		select {
+		case <-proc.exited:
+		case <-c.closed:
+			return
+		}
+		select {
+		case <-time.After(mgmtDrainTimeout):
+			_ = c.Close()
+		case <-c.closed:
+		}
+	}()
+
+	proc.mu.Lock()
+	proc.mgmt = c
+	proc.mu.Unlock()
+	defer func() {
+		proc.mu.Lock()
+		proc.mgmt = nil
+		proc.mu.Unlock()
+	}()
+
+	go m.startManagement(proc, c)
+	c.read(func(kind, payload string) {
+		m.handleNotification(proc, c, kind, payload)
+	})
+}
+
+// startManagement turns on the notifications the daemon consumes, replaying
+// their history, and lets openvpn go on. A failure leaves the process held, so
+// it is stopped rather than left running unsupervised.
+func (m *OpenVPNManager) startManagement(proc *OpenVPNProcess, c *mgmtClient) {
+	steps := []func() error{
+		func() error {
+			return c.historyCommand("state on all", func(line string) {
+				if st, ok := parseMgmtState(line); ok {
+					m.applyState(proc, st)
+				}
+			})
+		},
+		func() error {
+			return c.historyCommand("log on all", func(line string) {
+				if t, level, text, ok := parseMgmtLog(line); ok {
+					m.recordOutput(proc, t, level, text)
+				}
+			})
+		},
+		func() error {
+			_, err := c.command("bytecount " + strconv.Itoa(mgmtByteCountInterval))
+			return err
+		},
+		func() error {
+			_, err := c.command("hold release")
+			return err
+		},
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			if errors.Is(err, errMgmtClosed) {
+				return
+			}
+			m.logger.Printf("[openvpn] Management setup failed for profile %s, stopping it: %v", proc.ProfileID, err)
+			m.setError(proc, "OpenVPN management interface failed: "+err.Error())
+			m.killProcess(proc)
+			return
+		}
+	}
+}
+
+// handleNotification handles one notification from proc's openvpn. It runs on
+// the read goroutine, so anything that sends a command runs on its own.
+func (m *OpenVPNManager) handleNotification(proc *OpenVPNProcess, c *mgmtClient, kind, payload string) {
+	switch kind {
+	case "STATE":
+		if st, ok := parseMgmtState(payload); ok {
+			m.applyState(proc, st)
+		}
+	case "LOG":
+		if t, level, text, ok := parseMgmtLog(payload); ok {
+			m.recordOutput(proc, t, level, text)
+		}
+	case "BYTECOUNT":
+		if in, out, ok := parseMgmtByteCount(payload); ok {
+			proc.mu.Lock()
+			proc.BytesIn, proc.BytesOut = in, out
+			proc.mu.Unlock()
+		}
+	case "PASSWORD":
+		if req, ok := parseMgmtPassword(payload); ok {
+			m.handlePasswordRequest(proc, c, req)
+		}
+	case "FATAL":
+		m.recordOutput(proc, time.Now(), LogLevelError, payload)
+		m.setError(proc, payload)
+	}
+}
+
+// applyState records an openvpn state and moves proc's status accordingly.
+func (m *OpenVPNManager) applyState(proc *OpenVPNProcess, st mgmtState) {
+	proc.mu.Lock()
+	before := [...]string{proc.Status, proc.State, proc.IPAddress, proc.RemoteAddress, proc.LastError}
+	proc.State = st.Name
+	if st.LocalIP != "" {
+		proc.IPAddress = st.LocalIP
+	}
+	if st.RemoteIP != "" {
+		proc.RemoteAddress = st.RemoteIP
+		if st.RemotePort != "" {
+			proc.RemoteAddress = net.JoinHostPort(st.RemoteIP, st.RemotePort)
+		}
+	}
+	status, lastErr := nextStatus(proc.Status, st, proc.challenge.crv1 != nil)
+	proc.Status = status
+	if lastErr != "" {
+		proc.LastError = lastErr
+	}
+	after := [...]string{proc.Status, proc.State, proc.IPAddress, proc.RemoteAddress, proc.LastError}
+	proc.mu.Unlock()
+
+	if after == before {
+		return
+	}
+	if after[0] != before[0] {
+		m.logger.Printf("[openvpn] Profile %s is %s (%s)", proc.ProfileID, after[0], st.Name)
+	}
+	m.notifyStateChange(proc)
+}
+
+// setError puts proc in the error status with message msg.
+func (m *OpenVPNManager) setError(proc *OpenVPNProcess, msg string) {
+	proc.mu.Lock()
+	proc.Status = StatusError
+	proc.LastError = msg
+	proc.mu.Unlock()
+	m.logger.Printf("[openvpn] Profile %s failed: %s", proc.ProfileID, msg)
+	m.notifyStateChange(proc)
+}
+
+// handlePasswordRequest answers openvpn's request for credentials with the
+// ones given to Connect, or publishes the challenge that must be answered
+// first. A request the daemon cannot answer (no credentials, a private key
+// passphrase, a proxy password) would hold openvpn forever, and with
+// --auth-retry interact so would rejected credentials, so the process is
+// stopped instead. A pushed auth-token is cached for later connections.
+func (m *OpenVPNManager) handlePasswordRequest(proc *OpenVPNProcess, c *mgmtClient, req mgmtPasswordRequest) {
+	if req.AuthToken != "" {
+		m.storeAuthToken(proc, req.AuthToken)
+		return
+	}
+	if req.Failed {
+		if req.Realm == "Auth" && req.CRV1 != nil {
+			proc.mu.Lock()
+			proc.challenge.crv1 = req.CRV1
+			proc.askLocked(&OpenVPNChallenge{
+				Kind:             ChallengeDynamic,
+				Text:             req.CRV1.Text,
+				Echo:             req.CRV1.hasFlag("E"),
+				ResponseRequired: req.CRV1.hasFlag("R"),
+			})
+			proc.mu.Unlock()
+			m.logger.Printf("[openvpn] Profile %s is waiting for a challenge response", proc.ProfileID)
+			m.notifyStateChange(proc)
+			return
+		}
+		proc.mu.Lock()
+		proc.challenge.failed = true
+		usingToken := proc.usingToken
+		proc.mu.Unlock()
+		if req.Realm == "Auth" && usingToken {
+			m.logger.Printf("[openvpn] Server of profile %s rejected its auth-token; forgetting it", proc.ProfileID)
+			m.forgetAuthToken(proc.ProfileID)
+		}
+		if req.Realm == "Auth" {
+			m.setError(proc, "Authentication failed")
+		} else {
+			m.setError(proc, fmt.Sprintf("The %s password was rejected", req.Realm))
+		}
+		go m.signalTerm(proc, c)
+		return
+	}
+
+	proc.mu.Lock()
+	if proc.challenge.failed {
+		proc.mu.Unlock()
+		return
+	}
+	if req.Realm != "Auth" || (proc.username == "" && proc.password == "") {
+		proc.mu.Unlock()
+		if req.Realm == "Auth" {
+			m.setError(proc, "The server requires a username and password")
+		} else {
+			m.setError(proc, fmt.Sprintf("OpenVPN asked for a %s password, which is not supported", req.Realm))
+		}
+		go m.signalTerm(proc, c)
+		return
+	}
+	proc.challenge.authWanted = true
+	asked := false
+	if req.Static != nil && proc.challenge.static == nil && !proc.usingToken {
+		// Every attempt asks again: a one-time code is good once. An
+		// auth-token stands in for both the password and the response.
+		proc.challenge.static = req.Static
+		proc.askLocked(&OpenVPNChallenge{
+			Kind:             ChallengeStatic,
+			Text:             req.Static.Text,
+			Echo:             req.Static.Echo,
+			ResponseRequired: true,
+		})
+		asked = true
+	}
+	username, password, ok := proc.credentialsLocked()
+	proc.mu.Unlock()
+
+	if asked {
+		m.logger.Printf("[openvpn] Profile %s is waiting for a challenge response", proc.ProfileID)
+		m.notifyStateChange(proc)
+	}
+	if ok {
+		go m.sendCredentials(proc, c, req.Realm, username, password)
+	}
+}
+
+// sendCredentials answers openvpn's request for realm's credentials.
+func (m *OpenVPNManager) sendCredentials(proc *OpenVPNProcess, c *mgmtClient, realm, username, password string) {
+	realm = mgmtQuote(realm)
+	if _, err := c.command("username " + realm + " " + mgmtQuote(username)); err != nil {
+		m.logger.Printf("[openvpn] Could not send the username for profile %s: %v", proc.ProfileID, err)
+		return
+	}
+	if _, err := c.command("password " + realm + " " + mgmtQuote(password)); err != nil {
+		m.logger.Printf("[openvpn] Could not send the password for profile %s: %v", proc.ProfileID, err)
+	}
+}
+
+// signalTerm asks proc's openvpn to exit, for a request it cannot be given
+// an answer to.
+func (m *OpenVPNManager) signalTerm(proc *OpenVPNProcess, c *mgmtClient) {
+	if _, err := c.command("signal SIGTERM"); err != nil && !errors.Is(err, errMgmtClosed) {
+		m.logger.Printf("[openvpn] Could not stop profile %s: %v", proc.ProfileID, err)
+	}
+}
+
+// stopGracefully asks proc's openvpn to exit through its management interface
+// and waits for it to. It reports false if the process has no management
+// connection or did not exit in time; the caller then kills it.
+func (m *OpenVPNManager) stopGracefully(proc *OpenVPNProcess) bool {
+	proc.mu.RLock()
+	c := proc.mgmt
+	proc.mu.RUnlock()
+	if c == nil {
+		return false
+	}
+	if _, err := c.command("signal SIGTERM"); err != nil {
+		m.logger.Printf("[openvpn] SIGTERM through the management interface failed: %v", err)
+		return false
+	}
+
+	timer := time.NewTimer(openvpnStopTimeout)
+	defer timer.Stop()
+	select {
+	case <-proc.exited:
+		return true
+	case <-timer.C:
+		m.logger.Printf("[openvpn] Profile %s did not exit within %s of SIGTERM", proc.ProfileID, openvpnStopTimeout)
+		return false
+	}
+}
@@ -0,0 +1,355 @@
+package vpn
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMgmt is an openvpn management interface: it answers the daemon's
+// commands (with the state and log history it was given) and sends the
+// notifications a test asks for.
+type fakeMgmt struct {
+	t      *testing.T
+	states []string // "state on all" history
+	logs   []string // "log on all" history
+
+	// onSignal, if set, runs when "signal SIGTERM" is received.
+	onSignal func()
+
+	mu       sync.Mutex
+	conn     net.Conn
+	commands []string
+	got      chan string // each command, as received
+}
+
+// startFakeMgmt listens on socket like openvpn does. Set its history before
+// the daemon connects.
+func startFakeMgmt(t *testing.T, socket string) *fakeMgmt {
+	t.Helper()
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMgmt{t: t, got: make(chan string, 64)}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		f.mu.Lock()
+		if f.conn != nil {
+			_ = f.conn.Close()
+		}
+		f.mu.Unlock()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeMgmt) serve(conn net.Conn) {
+	f.mu.Lock()
+	f.conn = conn
+	f.mu.Unlock()
+	defer func() { _ = conn.Close() }()
+
+	f.send(">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info")
+	f.send(">HOLD:Waiting for hold release:0")
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		cmd := scanner.Text()
+		f.mu.Lock()
+		f.commands = append(f.commands, cmd)
+		f.mu.Unlock()
+		f.got <- cmd
+
+		switch cmd {
+		case "state on all":
+			f.send("SUCCESS: real-time state notification set to ON")
+			f.send(append(slices.Clone(f.states), "END")...)
+		case "log on all":
+			f.send("SUCCESS: real-time log notification set to ON")
+			f.send(append(slices.Clone(f.logs), "END")...)
+		case "signal SIGTERM":
+			f.send("SUCCESS: signal SIGTERM thrown")
+			if f.onSignal != nil {
+				f.onSignal()
+			}
+		default:
+			f.send("SUCCESS: done")
+		}
+	}
+}
+
+// send writes lines to the connected daemon.
+func (f *fakeMgmt) send(lines ...string) {
+	f.mu.Lock()
+	conn := f.conn
+	f.mu.Unlock()
+	for _, line := range lines {
+		if _, err := io.WriteString(conn, line+"\r\n"); err != nil {
+			return
+		}
+	}
+}
+
+// received returns the commands received so far.
+func (f *fakeMgmt) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.commands)
+}
+
+// await waits for the command want, failing the test if it does not come.
+func (f *fakeMgmt) await(want string) {
+	f.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case cmd := <-f.got:
+			if cmd == want {
+				return
+			}
+		case <-timeout:
+			f.t.Fatalf("command %q not received; got %q", want, f.received())
+		}
+	}
+}
+
+// followFake starts following a process whose management socket fake serves.
+func followFake(t *testing.T, m *OpenVPNManager, proc *OpenVPNProcess) {
+	t.Helper()
+	proc.exited = make(chan struct{})
+	proc.logDone = make(chan struct{})
+	go m.followManagement(proc)
+	t.Cleanup(func() {
+		select {
+		case <-proc.exited:
+		default:
+			close(proc.exited)
+		}
+	})
+}
+
+// waitStatus waits until cond holds for proc's status.
+func waitStatus(t *testing.T, m *OpenVPNManager, profileID string, cond func(OpenVPNStatusResult) bool) OpenVPNStatusResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := m.Status(profileID)
+		if cond(*status) {
+			return *status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagementSession(t *testing.T) {
+	useTempMgmtDir(t)
+	useTempLogDir(t)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+	fake.states = []string{"1700000000,CONNECTING,,,,,,", "1700000001,WAIT,,,,,,"}
+	fake.logs = []string{"1700000000,I,OpenVPN 2.6.9 x86_64-pc-linux-gnu"}
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	var events []string
+	var eventsMu sync.Mutex
+	m.SetStateChangeHandler(func(s OpenVPNStatusResult) {
+		eventsMu.Lock()
+		events = append(events, s.State)
+		eventsMu.Unlock()
+	})
+	proc := &OpenVPNProcess{
+		ProfileID:  "p1",
+		Status:     StatusConnecting,
+		username:   "alice",
+		password:   `p"w\d`,
+		mgmtSocket: socket,
+	}
+	m.processes["p1"] = proc
+	m.startSessionLog(proc)
+	followFake(t, m, proc)
+
+	// The history comes first and the hold is released last.
+	fake.await("hold release")
+	want := []string{"state on all", "log on all", "bytecount 5", "hold release"}
+	if got := fake.received(); !slices.Equal(got, want) {
+		t.Errorf("setup commands = %q, want %q", got, want)
+	}
+
+	fake.send(">PASSWORD:Need 'Auth' username/password")
+	fake.await(`username "Auth" "alice"`)
+	fake.await(`password "Auth" "p\"w\\d"`)
+
+	fake.send(
+		">STATE:1700000005,GET_CONFIG,,,,,,",
+		">LOG:1700000006,W,WARNING: 'link-mtu' is used inconsistently",
+		">STATE:1700000007,CONNECTED,SUCCESS,10.8.0.6,198.51.100.7,1194,,",
+		">BYTECOUNT:1024,2048",
+	)
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.BytesOut == 2048 })
+	if status.Status != StatusConnected || status.IPAddress != "10.8.0.6" || status.RemoteAddress != "198.51.100.7:1194" || status.BytesIn != 1024 {
+		t.Errorf("status = %+v", status)
+	}
+
+	eventsMu.Lock()
+	gotEvents := slices.Clone(events)
+	eventsMu.Unlock()
+	if wantEvents := []string{"CONNECTING", "WAIT", "GET_CONFIG", "CONNECTED"}; !slices.Equal(gotEvents, wantEvents) {
+		t.Errorf("state events = %q, want %q", gotEvents, wantEvents)
+	}
+
+	lines := m.Logs("p1", OpenVPNLogFilter{})
+	if got := logTexts(lines); !slices.Contains(got, "OpenVPN 2.6.9 x86_64-pc-linux-gnu") {
+		t.Errorf("log = %q, want the history", got)
+	}
+	last := lines[len(lines)-1]
+	if last.Level != LogLevelWarning || !last.Time.Equal(time.Unix(1700000006, 0)) {
+		t.Errorf("last line = %+v, want openvpn's time and level", last)
+	}
+
+	// openvpn exiting closes the socket and the session's output is done.
+	close(proc.exited)
+	fake.mu.Lock()
+	_ = fake.conn.Close()
+	fake.mu.Unlock()
+	select {
+	case <-proc.logDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("output not done after the socket closed")
+	}
+}
+
+// TestManagementPasswordWithoutCredentials checks that a password request the
+// daemon cannot answer stops openvpn instead of leaving it waiting.
+func TestManagementPasswordWithoutCredentials(t *testing.T) {
+	useTempMgmtDir(t)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := &OpenVPNProcess{ProfileID: "p1", Status: StatusConnecting, mgmtSocket: socket}
+	m.processes["p1"] = proc
+	followFake(t, m, proc)
+
+	fake.await("hold release")
+	fake.send(">PASSWORD:Need 'Auth' username/password")
+	fake.await("signal SIGTERM")
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.Status == StatusError })
+	if status.LastError != "The server requires a username and password" {
+		t.Errorf("LastError = %q", status.LastError)
+	}
+}
+
+func TestManagementAuthFailed(t *testing.T) {
+	useTempMgmtDir(t)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	proc := &OpenVPNProcess{ProfileID: "p1", Status: StatusConnecting, mgmtSocket: socket, username: "u", password: "p"}
+	m.processes["p1"] = proc
+	followFake(t, m, proc)
+
+	fake.await("hold release")
+	fake.send(">PASSWORD:Verification Failed: 'Auth'")
+	// With --auth-retry interact openvpn would ask again; the daemon stops it.
+	fake.await("signal SIGTERM")
+	fake.send(">STATE:1700000009,EXITING,auth-failure,,,,,")
+	status := waitStatus(t, m, "p1", func(s OpenVPNStatusResult) bool { return s.State == "EXITING" })
+	if status.Status != StatusError || status.LastError != "Authentication failed" {
+		t.Errorf("status = %+v, want an authentication error", status)
+	}
+}
+
+func TestNextStatus(t *testing.T) {
+	tests := []struct {
+		status, state, detail string
+		challenged            bool
+		want, wantErr         string
+	}{
+		{StatusConnecting, "WAIT", "", false, StatusConnecting, ""},
+		{StatusConnecting, "CONNECTED", "SUCCESS", false, StatusConnected, ""},
+		{StatusConnected, "RECONNECTING", "ping-restart", false, StatusConnecting, ""},
+		{StatusConnecting, "RECONNECTING", "tls-error", false, StatusError, "TLS handshake failed"},
+		{StatusConnecting, "EXITING", "auth-failure", false, StatusError, "Authentication failed"},
+		{StatusConnecting, "RECONNECTING", "auth-failure", false, StatusError, "Authentication failed"},
+		{StatusConnecting, "RECONNECTING", "auth-failure", true, StatusConnecting, ""},
+		{StatusConnecting, "EXITING", "auth-failure", true, StatusError, "Authentication failed"},
+		{StatusError, "CONNECTING", "", false, StatusError, ""},
+		{StatusError, "CONNECTED", "SUCCESS", false, StatusConnected, ""},
+		{StatusConnected, "EXITING", "SIGTERM", false, StatusDisconnecting, ""},
+		{StatusDisconnecting, "CONNECTED", "SUCCESS", false, StatusDisconnecting, ""},
+		{StatusConnecting, "SOME_NEW_STATE", "", false, StatusConnecting, ""},
+	}
+	for _, tt := range tests {
+		got, gotErr := nextStatus(tt.status, mgmtState{Name: tt.state, Detail: tt.detail}, tt.challenged)
+		if got != tt.want || gotErr != tt.wantErr {
+			t.Errorf("nextStatus(%s, %s/%s, %v) = %s, %q; want %s, %q",
+				tt.status, tt.state, tt.detail, tt.challenged, got, gotErr, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseMgmtNotifications(t *testing.T) {
+	st, ok := parseMgmtState("1700000007,CONNECTED,SUCCESS,10.8.0.6,198.51.100.7,1194,,")
+	if !ok || st.Name != "CONNECTED" || st.LocalIP != "10.8.0.6" || st.RemotePort != "1194" || st.Time.Unix() != 1700000007 {
+		t.Errorf("parseMgmtState = %+v, %v", st, ok)
+	}
+	if _, ok := parseMgmtState("garbage"); ok {
+		t.Error("a line without a state parsed")
+	}
+
+	tm, level, text, ok := parseMgmtLog("1700000006,N,TLS Error: incoming packet authentication failed, dropping")
+	if !ok || level != LogLevelError || text != "TLS Error: incoming packet authentication failed, dropping" || tm.Unix() != 1700000006 {
+		t.Errorf("parseMgmtLog = %v, %s, %q, %v", tm, level, text, ok)
+	}
+	if _, level, _, _ := parseMgmtLog("1700000006,I,Initialization Sequence Completed"); level != LogLevelInfo {
+		t.Errorf("info line level = %s", level)
+	}
+
+	if in, out, ok := parseMgmtByteCount("1024,2048"); !ok || in != 1024 || out != 2048 {
+		t.Errorf("parseMgmtByteCount = %d, %d, %v", in, out, ok)
+	}
+
+	for payload, want := range map[string]mgmtPasswordRequest{
+		"Need 'Auth' username/password":   {Realm: "Auth"},
+		"Need 'Private Key' password":     {Realm: "Private Key"},
+		"Verification Failed: 'Auth'":     {Realm: "Auth", Failed: true},
+		"Auth-Token:c2Vzc2lvbi10b2tlbg==": {Realm: "Auth", AuthToken: "c2Vzc2lvbi10b2tlbg=="},
+	} {
+		got, _ := parseMgmtPassword(payload)
+		if got != want {
+			t.Errorf("parseMgmtPassword(%q) = %+v, want %+v", payload, got, want)
+		}
+	}
+}
+
+func TestMgmtQuote(t *testing.T) {
+	if got := mgmtQuote(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Errorf("mgmtQuote = %s", got)
+	}
+}
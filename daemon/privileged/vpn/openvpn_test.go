@@ -1,20 +1,29 @@
 // Package vpn tests the pure and verifiable parts of OpenVPN process
 // management: TOCTOU-safe config staging (the validated bytes are the executed
-// bytes), credentials file handling, argv construction (secrets never in
-// argv), cleanup, and output parsing. No openvpn process is ever spawned and
-// no root privileges are required — filesystem locations are redirected to
-// temp dirs through the package-level seams (ovpnStagingDir, ovpnCredsDir).
+// bytes), argv construction (secrets never in argv), cleanup, the management
+// interface and re-adoption after a daemon restart. No openvpn process is ever
+// spawned and no root privileges are required — filesystem locations are
+// redirected to temp dirs through the package-level seams (ovpnStagingDir, ovpnCredsDir, ovpnMgmtDir, ovpnLogDir,
+// ovpnLogArchiveDir, procRoot).
 package vpn
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // useTempStagingDir redirects ovpnStagingDir to a per-test directory for the
@@ -38,6 +47,23 @@ func useTempCredsDir(t *testing.T) string {
 	return dir
 }
 
+// useTempMgmtDir redirects ovpnMgmtDir to a per-test directory. Not under
+// t.TempDir: a socket path must fit in 108 bytes and test names can be long.
+func useTempMgmtDir(t *testing.T) string {
+	t.Helper()
+	orig := ovpnMgmtDir
+	dir, err := os.MkdirTemp("", "mgmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ovpnMgmtDir = dir
+	t.Cleanup(func() {
+		ovpnMgmtDir = orig
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
 // writeClientConfig writes a client-supplied config file and returns its path.
 func writeClientConfig(t *testing.T, content string) string {
 	t.Helper()
@@ -355,89 +381,41 @@ func TestRemoveStagedOpenVPNConfig(t *testing.T) {
 // CREDENTIALS FILE
 // =============================================================================
 
-func TestCreateCredentialsFile(t *testing.T) {
-	t.Run("no credentials means no file", func(t *testing.T) {
-		credsDir := useTempCredsDir(t)
-
-		path, err := createCredentialsFile("", "")
-		if err != nil {
-			t.Fatalf("createCredentialsFile() error = %v", err)
-		}
-		if path != "" {
-			t.Errorf("expected empty path, got %q", path)
-		}
-		if _, err := os.Stat(credsDir); !os.IsNotExist(err) {
-			t.Error("creds dir was created despite no credentials")
-		}
-	})
-
-	t.Run("file has 0600 perms, correct parent and content", func(t *testing.T) {
-		credsDir := useTempCredsDir(t)
-
-		path, err := createCredentialsFile("alice", "s3cret!")
-		if err != nil {
-			t.Fatalf("createCredentialsFile() error = %v", err)
-		}
-		if filepath.Dir(path) != credsDir {
-			t.Errorf("credentials file %q not in creds dir %q", path, credsDir)
-		}
-
-		info, err := os.Stat(path)
-		if err != nil {
-			t.Fatalf("stat credentials file: %v", err)
-		}
-		if perm := info.Mode().Perm(); perm != 0600 {
-			t.Errorf("credentials file permissions = %o, want 0600", perm)
-		}
-
-		dirInfo, err := os.Stat(credsDir)
-		if err != nil {
-			t.Fatalf("stat creds dir: %v", err)
-		}
-		if perm := dirInfo.Mode().Perm(); perm != 0700 {
-			t.Errorf("creds dir permissions = %o, want 0700", perm)
-		}
-
-		got, err := os.ReadFile(path)
-		if err != nil {
-			t.Fatalf("reading credentials file: %v", err)
-		}
-		if string(got) != "alice\ns3cret!\n" {
-			t.Errorf("credentials content = %q, want username then password, newline-terminated", got)
-		}
-	})
-
-	t.Run("filenames are randomized", func(t *testing.T) {
-		useTempCredsDir(t)
-
-		first, err := createCredentialsFile("u", "p")
-		if err != nil {
-			t.Fatalf("first credentials file: %v", err)
-		}
-		second, err := createCredentialsFile("u", "p")
-		if err != nil {
-			t.Fatalf("second credentials file: %v", err)
-		}
-		if first == second {
-			t.Errorf("credentials filenames are not randomized: both %q", first)
-		}
-	})
+// writeLegacyCredentialsFile writes the --auth-user-pass file an older daemon
+// kept for the lifetime of its openvpn process.
+func writeLegacyCredentialsFile(t *testing.T) string {
+	t.Helper()
+	if err := os.MkdirAll(ovpnCredsDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ovpnCredsDir, "0123456789abcdef")
+	if err := os.WriteFile(path, []byte("user\nsecret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
 
-	t.Run("cleanup removes the file and tolerates empty path", func(t *testing.T) {
-		useTempCredsDir(t)
+// TestCleanupLegacyCredentialsFile checks that only a file in ovpnCredsDir is
+// ever removed: the path comes from the persisted state.
+func TestCleanupLegacyCredentialsFile(t *testing.T) {
+	useTempCredsDir(t)
+	path := writeLegacyCredentialsFile(t)
 
-		path, err := createCredentialsFile("u", "p")
-		if err != nil {
-			t.Fatalf("createCredentialsFile() error = %v", err)
-		}
+	outside := filepath.Join(t.TempDir(), "keep")
+	if err := os.WriteFile(outside, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cleanupCredentialsFile(outside)
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("a file outside the creds dir was removed: %v", err)
+	}
 
-		cleanupCredentialsFile(path)
-		if _, err := os.Stat(path); !os.IsNotExist(err) {
-			t.Errorf("credentials file %q still exists after cleanup", path)
-		}
+	cleanupCredentialsFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("credentials file %q still exists after cleanup", path)
+	}
 
-		cleanupCredentialsFile("") // must not panic
-	})
+	cleanupCredentialsFile("") // must not panic
 }
 
 // =============================================================================
@@ -462,8 +440,8 @@ func argvContains(args []string, want ...string) bool {
 }
 
 // TestBuildOpenVPNArgsSecretsNeverInArgv pins that username and password never
-// appear in the openvpn argv (argv is world-readable via /proc); credentials
-// must travel only through the --auth-user-pass file.
+// appear in the openvpn argv (argv is world-readable via /proc); openvpn must
+// ask for them on the management socket instead.
 func TestBuildOpenVPNArgsSecretsNeverInArgv(t *testing.T) {
 	params := OpenVPNConnectParams{
 		ProfileID:  "p1",
@@ -471,7 +449,8 @@ func TestBuildOpenVPNArgsSecretsNeverInArgv(t *testing.T) {
 		Username:   "alice-user",
 		Password:   "hunter2-pass",
 	}
-	args := buildOpenVPNArgs("/run/vpn-manager/ovpn/ovpn-x.conf", "/run/vpn-manager/ovpn-creds/abc", params)
+	socket := "/run/vpn-manager/ovpn-mgmt/ovpn-x.sock"
+	args := buildOpenVPNArgs("/run/vpn-manager/ovpn/ovpn-x.conf", socket, params)
 
 	joined := strings.Join(args, " ")
 	if strings.Contains(joined, params.Username) {
@@ -480,8 +459,14 @@ func TestBuildOpenVPNArgsSecretsNeverInArgv(t *testing.T) {
 	if strings.Contains(joined, params.Password) {
 		t.Errorf("password leaked into argv: %v", args)
 	}
-	if !argvContains(args, "--auth-user-pass", "/run/vpn-manager/ovpn-creds/abc") {
-		t.Errorf("missing --auth-user-pass <credfile>: %v", args)
+	if !argvContains(args, "--auth-user-pass", "--auth-retry", "interact") {
+		t.Errorf("want a bare --auth-user-pass, so openvpn asks for the credentials, and asks again after a challenge: %v", args)
+	}
+	if !argvContains(args, "--management", socket, "unix") || !argvContains(args, "--management-query-passwords") {
+		t.Errorf("missing the management socket or password queries: %v", args)
+	}
+	if !argvContains(args, "--management-hold") {
+		t.Errorf("missing --management-hold (states before the daemon connects would be lost): %v", args)
 	}
 }
 
@@ -506,11 +491,51 @@ func TestBuildOpenVPNArgsScriptSecurityForcedOff(t *testing.T) {
 	}
 }
 
-func TestBuildOpenVPNArgsNoCredFile(t *testing.T) {
+func TestBuildOpenVPNArgsNoCredentials(t *testing.T) {
 	args := buildOpenVPNArgs("/staged.conf", "", OpenVPNConnectParams{})
 	for _, a := range args {
 		if a == "--auth-user-pass" {
-			t.Errorf("--auth-user-pass present without a credentials file: %v", args)
+			t.Errorf("--auth-user-pass present without credentials: %v", args)
+		}
+	}
+
+	// An auth-token connect carries no credentials but answers with the token.
+	args = buildOpenVPNArgs("/staged.conf", "", OpenVPNConnectParams{UseAuthToken: true})
+	if !argvContains(args, "--auth-user-pass", "--auth-retry", "interact") {
+		t.Errorf("an auth-token connect lacks --auth-user-pass: %v", args)
+	}
+}
+
+// TestBuildOpenVPNArgsRemote verifies a chosen remote comes before --config,
+// so openvpn tries it ahead of the config's remotes.
+func TestBuildOpenVPNArgsRemote(t *testing.T) {
+	args := buildOpenVPNArgs("/staged.conf", "", OpenVPNConnectParams{})
+	if slices.Contains(args, "--remote") {
+		t.Errorf("--remote without a chosen remote: %v", args)
+	}
+
+	params := OpenVPNConnectParams{Remote: &OpenVPNRemote{Host: "vpn2.example.com", Port: 443, Proto: "tcp-client"}}
+	args = buildOpenVPNArgs("/staged.conf", "", params)
+	remote, config := slices.Index(args, "--remote"), slices.Index(args, "--config")
+	if !argvContains(args, "--remote", "vpn2.example.com", "443", "tcp-client") || remote > config {
+		t.Errorf("want --remote vpn2.example.com 443 tcp-client before --config: %v", args)
+	}
+}
+
+func TestValidateRemote(t *testing.T) {
+	for _, tt := range []struct {
+		remote  OpenVPNRemote
+		wantErr bool
+	}{
+		{OpenVPNRemote{Host: "vpn.example.com", Port: 1194, Proto: "udp"}, false},
+		{OpenVPNRemote{Host: "198.51.100.7", Port: 443, Proto: "tcp4-client"}, false},
+		{OpenVPNRemote{Host: "--up", Port: 1194, Proto: "udp"}, true},
+		{OpenVPNRemote{Host: "vpn.example.com", Port: 0, Proto: "udp"}, true},
+		{OpenVPNRemote{Host: "vpn.example.com", Port: 70000, Proto: "udp"}, true},
+		{OpenVPNRemote{Host: "vpn.example.com", Port: 1194, Proto: "tcp-server"}, true},
+	} {
+		if err := validateRemote(&tt.remote); (err != nil) != tt.wantErr {
+			t.Errorf("validateRemote(%+v) = %v, wantErr %v", tt.remote, err, tt.wantErr)
 		}
 	}
 }
@@ -612,58 +637,355 @@ func TestParseRouteForOpenVPN(t *testing.T) {
 	}
 }
 
-func TestExtractIPFromLine(t *testing.T) {
+// =============================================================================
+// RE-ADOPTION (fake procfs; the adopted process is a plain `sleep`)
+// =============================================================================
+
+// useTempLogDir redirects ovpnLogDir, and ovpnLogArchiveDir next to it, to a
+// per-test directory.
+func useTempLogDir(t *testing.T) string {
+	t.Helper()
+	origLogs, origArchive := ovpnLogDir, ovpnLogArchiveDir
+	dir := filepath.Join(t.TempDir(), "logs")
+	ovpnLogDir = dir
+	ovpnLogArchiveDir = filepath.Join(filepath.Dir(dir), "archive")
+	t.Cleanup(func() { ovpnLogDir, ovpnLogArchiveDir = origLogs, origArchive })
+	return dir
+}
+
+// useFakeProc points procRoot at a temp tree where each pid's cmdline is the
+// given argv, and returns a function to add entries.
+func useFakeProc(t *testing.T) func(pid int, argv ...string) {
+	t.Helper()
+	orig := procRoot
+	procRoot = filepath.Join(t.TempDir(), "proc")
+	t.Cleanup(func() { procRoot = orig })
+	return func(pid int, argv ...string) {
+		dir := filepath.Join(procRoot, strconv.Itoa(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		cmdline := strings.Join(argv, "\x00") + "\x00"
+		if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyOpenVPNProcess(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	addProc := useFakeProc(t)
+	staged := filepath.Join(stagingDir, "ovpn-1.conf")
+
+	addProc(10, "/usr/sbin/openvpn", "--config", staged, "--verb", "3")
+	addProc(11, "/usr/sbin/openvpn", "--config", filepath.Join(stagingDir, "ovpn-other.conf"))
+	addProc(12, "/usr/bin/python3", "--config", staged)
+
 	tests := []struct {
-		name string
-		line string
-		want string
+		name    string
+		pid     int
+		config  string
+		wantErr bool
 	}{
-		{"openvpn 2.6 net_addr format", "net_addr_v4_add: 10.120.100.5/24 dev tun0", "10.120.100.5"},
-		{"push reply ifconfig format", "PUSH: Received control message: 'PUSH_REPLY,ifconfig 10.8.0.6 255.255.255.0'", "10.8.0.6"},
-		{"loopback rejected", "net_addr_v4_add: 127.0.0.1/8 dev tun0", ""},
-		{"unrelated line", "TLS: soft reset", ""},
+		{"matching process", 10, staged, false},
+		{"different config (recycled pid)", 11, staged, true},
+		{"not openvpn", 12, staged, true},
+		{"gone", 13, staged, true},
+		{"config outside staging dir", 10, "/home/user/client.ovpn", true},
+		{"invalid pid", 0, staged, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := extractIPFromLine(tt.line); got != tt.want {
-				t.Errorf("extractIPFromLine(%q) = %q, want %q", tt.line, got, tt.want)
+			err := verifyOpenVPNProcess(tt.pid, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyOpenVPNProcess(%d, %q) err = %v, wantErr %v", tt.pid, tt.config, err, tt.wantErr)
 			}
 		})
 	}
 }
 
-func TestParseOutputLineStatusTransitions(t *testing.T) {
-	tests := []struct {
-		name       string
-		line       string
-		wantStatus string
-		wantErrMsg string
-		wantIP     string
-	}{
-		{"init completed connects", "Initialization Sequence Completed", StatusConnected, "", ""},
-		{"auth failure errors", "AUTH: Received control message: AUTH_FAILED", StatusError, "Authentication failed", ""},
-		{"tls failure errors", "TLS Error: TLS key negotiation failed", StatusError, "TLS handshake failed", ""},
-		{"noise leaves status alone", "MANAGEMENT: CMD 'state'", StatusConnecting, "", ""},
-		{"ip assignment captured", "net_addr_v4_add: 10.120.100.5/24 dev tun0", StatusConnecting, "", "10.120.100.5"},
+// startSleeper starts a long-running stand-in for openvpn, reaped in the
+// background so it does not linger as a zombie (which would still look alive)
+// once killed. The returned channel is closed when it has exited.
+func startSleeper(t *testing.T) (*exec.Cmd, <-chan struct{}) {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	reaped := make(chan struct{})
+	go func() { _ = cmd.Wait(); close(reaped) }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+	return cmd, reaped
+}
+
+// writeStagedConfig writes a staged config for an adopted process.
+func writeStagedConfig(t *testing.T, stagingDir string) string {
+	t.Helper()
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	staged := filepath.Join(stagingDir, "ovpn-1.conf")
+	if err := os.WriteFile(staged, []byte("client\n"), 0600); err != nil {
+		t.Fatal(err)
 	}
+	return staged
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			m := NewOpenVPNManager(log.New(io.Discard, "", 0))
-			proc := &OpenVPNProcess{ProfileID: "p1", Status: StatusConnecting}
+func TestAdoptReconnectsManagementAndDisconnects(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	mgmtDir := useTempMgmtDir(t)
+	useTempLogDir(t)
+	addProc := useFakeProc(t)
 
-			m.parseOutputLine(proc, tt.line)
+	cmd, reaped := startSleeper(t)
+	staged := writeStagedConfig(t, stagingDir)
+	addProc(cmd.Process.Pid, "openvpn", "--config", staged)
 
-			if proc.Status != tt.wantStatus {
-				t.Errorf("Status = %q, want %q", proc.Status, tt.wantStatus)
-			}
-			if proc.LastError != tt.wantErrMsg {
-				t.Errorf("LastError = %q, want %q", proc.LastError, tt.wantErrMsg)
-			}
-			if proc.IPAddress != tt.wantIP {
-				t.Errorf("IPAddress = %q, want %q", proc.IPAddress, tt.wantIP)
+	socket, err := createManagementSocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := startFakeMgmt(t, socket)
+	fake.states = []string{
+		"1700000000,CONNECTING,,,,,,",
+		"1700000002,CONNECTED,SUCCESS,10.8.0.6,198.51.100.7,1194,,",
+	}
+	// SIGTERM ends the stand-in, as it would end openvpn.
+	fake.onSignal = func() { _ = cmd.Process.Signal(syscall.SIGTERM) }
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	if err := m.Adopt(ProcessInfo{
+		ProfileID:        "p1",
+		PID:              cmd.Process.Pid,
+		StagedConfig:     staged,
+		ManagementSocket: socket,
+		Status:           StatusConnecting,
+	}); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+
+	// The state history restores status and addresses.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := m.Status("p1")
+		if status.Status == StatusConnected && status.IPAddress == "10.8.0.6" {
+			if status.RemoteAddress != "198.51.100.7:1194" || status.State != "CONNECTED" {
+				t.Errorf("status after replay = %+v", status)
 			}
-		})
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status after replay = %+v, want connected with 10.8.0.6", status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := m.Disconnect("p1"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	select {
+	case <-reaped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("adopted process was not stopped")
+	}
+	if !slices.Contains(fake.received(), "signal SIGTERM") {
+		t.Errorf("commands = %q, want a SIGTERM through the management interface", fake.received())
+	}
+
+	// Once the watcher notices the exit, the runtime files are removed.
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		entries := stagingDirEntries(t, stagingDir)
+		sockets := stagingDirEntries(t, mgmtDir)
+		if len(entries) == 0 && len(sockets) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runtime files not cleaned up: staged=%d sockets=%d", len(entries), len(sockets))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestAdoptLegacyProcess checks that a process started before the management
+// interface keeps its persisted state, has its log followed and is killed on
+// disconnect, and that its credentials file goes with it.
+func TestAdoptLegacyProcess(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	useTempCredsDir(t)
+	useTempLogDir(t)
+	addProc := useFakeProc(t)
+
+	cmd, reaped := startSleeper(t)
+	staged := writeStagedConfig(t, stagingDir)
+	credFile := writeLegacyCredentialsFile(t)
+	logFile, err := createLogFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(logFile, []byte("Initialization Sequence Completed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	addProc(cmd.Process.Pid, "openvpn", "--config", staged)
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	if err := m.Adopt(ProcessInfo{
+		ProfileID:       "p1",
+		PID:             cmd.Process.Pid,
+		StagedConfig:    staged,
+		CredentialsFile: credFile,
+		LogFile:         logFile,
+		Status:          StatusConnected,
+		IPAddress:       "10.8.0.6",
+	}); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	if status, _ := m.Status("p1"); status.Status != StatusConnected || status.IPAddress != "10.8.0.6" {
+		t.Errorf("status = %+v, want the persisted one", status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Contains(logTexts(m.Logs("p1", OpenVPNLogFilter{})), "Initialization Sequence Completed") {
+		if time.Now().After(deadline) {
+			t.Fatal("the legacy log was not followed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := m.Disconnect("p1"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	select {
+	case <-reaped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("adopted process was not killed")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		_, credErr := os.Stat(credFile)
+		_, logErr := os.Stat(logFile)
+		if os.IsNotExist(credErr) && os.IsNotExist(logErr) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("runtime files not cleaned up: creds err=%v log err=%v", credErr, logErr)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestAdoptGoneProcessCleansUp(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	useTempLogDir(t)
+	useFakeProc(t)
+
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	staged := filepath.Join(stagingDir, "ovpn-1.conf")
+	if err := os.WriteFile(staged, []byte("client\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := createLogFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	err = m.Adopt(ProcessInfo{ProfileID: "p1", PID: 999999, StagedConfig: staged, LogFile: logFile})
+	if err == nil {
+		t.Fatal("Adopt of a gone process should fail")
+	}
+	if _, ok := m.ProcessInfo("p1"); ok {
+		t.Error("failed adoption must not track the profile")
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("staged config should be removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(logFile); !os.IsNotExist(err) {
+		t.Errorf("log file should be removed, stat err = %v", err)
+	}
+}
+
+// TestConnectCancelledBeforeStart checks that a request cancelled before
+// openvpn starts leaves no process, staged config, socket or log behind.
+func TestConnectCancelledBeforeStart(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	mgmtDir := useTempMgmtDir(t)
+	logDir := useTempLogDir(t)
+	clientPath := writeClientConfig(t, "client\nremote vpn.example.com 1194\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	_, err := m.Connect(ctx, OpenVPNConnectParams{
+		ProfileID:  "p1",
+		ConfigPath: clientPath,
+		Username:   "user",
+		Password:   "secret",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Connect error = %v, want context.Canceled", err)
+	}
+	if _, ok := m.ProcessInfo("p1"); ok {
+		t.Error("a cancelled connect must not track the profile")
+	}
+	for _, dir := range []string{stagingDir, mgmtDir, logDir} {
+		if entries := stagingDirEntries(t, dir); len(entries) != 0 {
+			t.Errorf("%s not cleaned up: %d entries left", dir, len(entries))
+		}
+	}
+}
+
+// TestConnectProcessCap checks that Connect refuses to start openvpn beyond
+// the process cap, before staging anything, and that exited processes do not
+// count against it.
+func TestConnectProcessCap(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	useTempMgmtDir(t)
+	useTempLogDir(t)
+	clientPath := writeClientConfig(t, "client\nremote vpn.example.com 1194\n")
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	m.maxProcesses = 1
+	m.processes["running"] = &OpenVPNProcess{ProfileID: "running", Status: StatusConnected, exited: make(chan struct{})}
+
+	_, err := m.Connect(context.Background(), OpenVPNConnectParams{ProfileID: "p1", ConfigPath: clientPath})
+	if !errors.Is(err, protocol.ErrRateLimited) {
+		t.Fatalf("Connect error = %v, want ErrRateLimited", err)
+	}
+	if entries := stagingDirEntries(t, stagingDir); len(entries) != 0 {
+		t.Errorf("a refused connect staged %d files", len(entries))
+	}
+
+	close(m.processes["running"].exited)
+	if running := m.runningLocked(); running != 0 {
+		t.Errorf("runningLocked() = %d after the process exited, want 0", running)
+	}
+}
+
+// TestConnectRejectsLineBreakInCredentials checks that credentials, which are
+// sent to openvpn as management commands, cannot smuggle in another command.
+func TestConnectRejectsLineBreakInCredentials(t *testing.T) {
+	stagingDir := useTempStagingDir(t)
+	useTempMgmtDir(t)
+	useTempLogDir(t)
+	clientPath := writeClientConfig(t, "client\nremote vpn.example.com 1194\n")
+
+	m := NewOpenVPNManager(log.New(io.Discard, "", 0))
+	_, err := m.Connect(context.Background(), OpenVPNConnectParams{
+		ProfileID:  "p1",
+		ConfigPath: clientPath,
+		Username:   "user",
+		Password:   "secret\nsignal SIGHUP",
+	})
+	if err == nil {
+		t.Fatal("Connect accepted a password with a line break")
+	}
+	if entries := stagingDirEntries(t, stagingDir); len(entries) != 0 {
+		t.Errorf("a refused connect staged %d files", len(entries))
 	}
 }
@@ -8,12 +8,12 @@ import (
 	"log"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/yllada/vpn-manager/daemon/privileged/sysexec"
 	"github.com/yllada/vpn-manager/daemon/privileged/validate"
 )
 
@@ -22,7 +22,7 @@ import (
 // this root-owned copy — not the client-supplied path — is what makes the C1 scan
 // TOCTOU-proof: a same-uid attacker cannot swap the file after it is validated,
 // because they cannot write into this directory.
-const wgStagingDir = "/run/vpn-manager/wg"
+var wgStagingDir = "/run/vpn-manager/wg"
 
 // maxWgConfigBytes caps the size of a WireGuard config we will stage. Real configs
 // are a few hundred bytes; this bounds memory against a pathological input.
@@ -83,7 +83,8 @@ func NewWireGuardManager(logger *log.Logger) *WireGuardManager {
 	}
 }
 
-// Connect brings up a WireGuard interface.
+// Connect brings up a WireGuard interface. If ctx is cancelled before the
+// interface is up and reported, whatever was set up is torn down again.
 func (m *WireGuardManager) Connect(ctx context.Context, params WireGuardConnectParams) (*WireGuardConnectResult, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -149,6 +150,16 @@ func (m *WireGuardManager) Connect(ctx context.Context, params WireGuardConnectP
 		err = fmt.Errorf("neither wg-quick nor wg command found")
 	}
 
+	if ctx.Err() != nil {
+		// Cancelled or timed out, possibly after the interface came up: the
+		// killed wg-quick/ip may have left the interface, routes or DNS half
+		// set up. Nobody is waiting for this tunnel, so take it down rather
+		// than leave it behind.
+		m.rollbackConnect(ifaceName, params.ConfigPath)
+		delete(m.interfaces, ifaceName)
+		removeStagedConfig(params.ConfigPath)
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		iface.mu.Lock()
 		iface.Status = StatusError
@@ -286,6 +297,41 @@ func (m *WireGuardManager) ListInterfaces() []WireGuardStatusResult {
 	return results
 }
 
+// Adopt takes over a WireGuard interface brought up by a previous daemon
+// instance. Interfaces are kernel objects that survive a daemon restart, so it
+// only has to check that the interface still exists and track it again; its
+// staged config (needed by wg-quick down) is still in wgStagingDir. If the
+// interface is gone, the leftover staged config is removed and an error returned.
+func (m *WireGuardManager) Adopt(interfaceName string, startTime time.Time) error {
+	if err := validate.InterfaceName(interfaceName); err != nil {
+		return fmt.Errorf("wireguard: %w", err)
+	}
+
+	staged := filepath.Join(wgStagingDir, interfaceName+".conf")
+	if !m.interfaceExists(interfaceName) {
+		removeStagedConfig(staged)
+		return fmt.Errorf("interface %s no longer exists", interfaceName)
+	}
+	if _, err := os.Stat(staged); err != nil {
+		// Without the staged config Disconnect falls back to deleting the link.
+		staged = ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.interfaces[interfaceName] = &WireGuardInterface{
+		Name:       interfaceName,
+		ConfigPath: staged,
+		Status:     StatusConnected,
+		IPAddress:  m.getInterfaceIP(interfaceName),
+		StartTime:  startTime,
+	}
+	m.logger.Printf("[wireguard] Adopted interface %s", interfaceName)
+
+	return nil
+}
+
 // =============================================================================
 // CONFIG STAGING (TOCTOU-safe C1 validation)
 // =============================================================================
@@ -329,7 +375,7 @@ func removeStagedConfig(configPath string) {
 
 func (m *WireGuardManager) connectWithWgQuick(ctx context.Context, ifaceName, configPath string) error {
 	// wg-quick up <config>
-	cmd := exec.CommandContext(ctx, "wg-quick", "up", configPath)
+	cmd := sysexec.CommandContext(ctx, "wg-quick", "up", configPath)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("wg-quick up failed: %w: %s", err, string(output))
@@ -344,7 +390,7 @@ func (m *WireGuardManager) connectWithWg(ctx context.Context, ifaceName, configP
 	// scan in Connect still protects this path; the scan is simply a no-op concern here.
 
 	// 1. Create interface
-	cmd := exec.CommandContext(ctx, "ip", "link", "add", "dev", ifaceName, "type", "wireguard")
+	cmd := sysexec.CommandContext(ctx, "ip", "link", "add", "dev", ifaceName, "type", "wireguard")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		// Interface might already exist
 		if !strings.Contains(string(output), "exists") {
@@ -353,13 +399,13 @@ func (m *WireGuardManager) connectWithWg(ctx context.Context, ifaceName, configP
 	}
 
 	// 2. Apply configuration
-	cmd = exec.CommandContext(ctx, "wg", "setconf", ifaceName, configPath)
+	cmd = sysexec.CommandContext(ctx, "wg", "setconf", ifaceName, configPath)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to set config: %w: %s", err, string(output))
 	}
 
 	// 3. Bring interface up
-	cmd = exec.CommandContext(ctx, "ip", "link", "set", "up", "dev", ifaceName)
+	cmd = sysexec.CommandContext(ctx, "ip", "link", "set", "up", "dev", ifaceName)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to bring up interface: %w: %s", err, string(output))
 	}
@@ -367,11 +413,26 @@ func (m *WireGuardManager) connectWithWg(ctx context.Context, ifaceName, configP
 	return nil
 }
 
+// rollbackConnect undoes a bring-up that was cut short. wg-quick down also
+// reverts the routes and DNS wg-quick up installed; deleting the link covers the
+// plain wg path and a wg-quick killed before it got that far.
+func (m *WireGuardManager) rollbackConnect(ifaceName, configPath string) {
+	m.logger.Printf("[wireguard] Connect of %s cancelled, rolling back", ifaceName)
+	if checkCommandExists("wg-quick") {
+		if err := m.disconnectWithWgQuick(ifaceName, configPath); err == nil {
+			return
+		}
+	}
+	if err := m.disconnectInterface(ifaceName); err != nil {
+		m.logger.Printf("[wireguard] Rollback of %s: %v", ifaceName, err)
+	}
+}
+
 func (m *WireGuardManager) disconnectWithWgQuick(ifaceName, configPath string) error {
-	cmd := exec.Command("wg-quick", "down", configPath)
+	cmd := sysexec.Command("wg-quick", "down", configPath)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		// Try alternative: wg-quick down <interface>
-		cmd = exec.Command("wg-quick", "down", ifaceName)
+		cmd = sysexec.Command("wg-quick", "down", ifaceName)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("wg-quick down failed: %w: %s", err, string(output))
 		}
@@ -382,7 +443,7 @@ func (m *WireGuardManager) disconnectWithWgQuick(ifaceName, configPath string) e
 
 func (m *WireGuardManager) disconnectInterface(ifaceName string) error {
 	// ip link delete <interface>
-	cmd := exec.Command("ip", "link", "delete", "dev", ifaceName)
+	cmd := sysexec.Command("ip", "link", "delete", "dev", ifaceName)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		// Interface might not exist
@@ -444,6 +505,6 @@ func (m *WireGuardManager) getInterfaceIP(name string) string {
 }
 
 func checkCommandExists(cmd string) bool {
-	_, err := exec.LookPath(cmd)
+	_, err := sysexec.LookPath(cmd)
 	return err == nil
 }
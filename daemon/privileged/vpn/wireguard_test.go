@@ -0,0 +1,54 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWireGuardConnectCancelledRollsBack runs Connect against a fake wg-quick
+// whose "up" hangs, cancels the request and checks the half-made tunnel is
+// taken down with the staged config.
+func TestWireGuardConnectCancelledRollsBack(t *testing.T) {
+	orig := wgStagingDir
+	wgStagingDir = filepath.Join(t.TempDir(), "wg")
+	t.Cleanup(func() { wgStagingDir = orig })
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls.log")
+	script := "#!/bin/sh\necho \"$1\" >> " + calls + "\n[ \"$1\" = up ] && exec sleep 30\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(bin, "wg-quick"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":/usr/bin:/bin")
+
+	config := filepath.Join(t.TempDir(), "wgtest0.conf")
+	if err := os.WriteFile(config, []byte("[Interface]\nPrivateKey = x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	m := NewWireGuardManager(log.New(io.Discard, "", 0))
+	_, err := m.Connect(ctx, WireGuardConnectParams{ConfigPath: config})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Connect error = %v, want context.Canceled", err)
+	}
+
+	got, _ := os.ReadFile(calls)
+	if string(got) != "up\ndown\n" {
+		t.Errorf("wg-quick calls = %q, want up then down", got)
+	}
+	if status, err := m.Status("wgtest0"); err != nil || status.Status != StatusDisconnected {
+		t.Errorf("Status = %+v, %v; want disconnected", status, err)
+	}
+	if entries, _ := os.ReadDir(wgStagingDir); len(entries) != 0 {
+		t.Errorf("staged config left behind: %v", entries)
+	}
+}
@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// This file implements the dispatcher's rate limits. MaxMessageBytes and the
+// connection caps bound what one client can hold open, but not how often it
+// can ask: a buggy or hostile group member looping openvpn.connect or
+// tailscale.login would spawn processes and churn the firewall without end.
+//
+//   - Each UID gets a token bucket per rate class. Queries change nothing and
+//     the UI polls them, so they are not limited; state changes and the
+//     methods that start processes or sign in are, the latter much harder.
+//   - At most maxConcurrentChanges state-changing handlers run at once,
+//     across all callers, so slow handlers cannot pile up. One UID may hold
+//     at most maxConcurrentChangesPerUID of them, and rootReservedChanges are
+//     kept for root, so a user stuck in slow calls (a tailscale.login waiting
+//     out its timeout) cannot lock out the other users or root.
+//
+// Both apply to the socket and D-Bus alike. Root is exempt from the buckets
+// (system scripts, the daemon itself) but not from the concurrency cap, which
+// bounds the daemon's own work. A refused call is answered with
+// protocol.ErrCodeRateLimited and changes nothing.
+
+const (
+	// maxConcurrentChanges caps state-changing handlers running at once.
+	maxConcurrentChanges = 8
+
+	// maxConcurrentChangesPerUID caps those of one non-root UID: one each for
+	// the GUI, the session agent and vpnctl, which all call in turn on their
+	// own connection.
+	maxConcurrentChangesPerUID = 3
+
+	// rootReservedChanges of the maxConcurrentChanges slots only root may
+	// use.
+	rootReservedChanges = 2
+)
+
+// rateClass groups methods that share a token bucket.
+type rateClass int
+
+const (
+	// rateQuery covers read-only methods; they are not limited.
+	rateQuery rateClass = iota
+
+	// rateChange covers methods that change firewall, DNS or routing state.
+	rateChange
+
+	// rateSpawn covers methods that start a process or a sign-in.
+	rateSpawn
+)
+
+// spawnMethods are the rateSpawn methods. Every other state-changing method is
+// rateChange.
+var spawnMethods = map[string]bool{
+	"openvpn.connect":   true,
+	"wireguard.connect": true,
+	"tailscale.up":      true,
+	"tailscale.login":   true,
+	"taildrop.send":     true,
+}
+
+// rateClassOf returns the rate class of a method.
+func rateClassOf(method string) rateClass {
+	switch {
+	case !isPrivilegedMethod(method):
+		return rateQuery
+	case spawnMethods[method]:
+		return rateSpawn
+	default:
+		return rateChange
+	}
+}
+
+// rateLimit is a token bucket's refill rate and size.
+type rateLimit struct {
+	perSecond float64
+	burst     int
+}
+
+// defaultRateLimits allow what the GUI does in bursts (a connect with its kill
+// switch, DNS and IPv6 steps; a few reconnects in a row) but not a loop.
+var defaultRateLimits = map[rateClass]rateLimit{
+	rateChange: {perSecond: 5, burst: 20},
+	rateSpawn:  {perSecond: 0.5, burst: 5},
+}
+
+// bucketKey identifies one caller's bucket for one class.
+type bucketKey struct {
+	uid   uint32
+	class rateClass
+}
+
+// tokenBucket holds up to burst tokens, refilled continuously.
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	limited bool // the previous call was refused, so the next refusal is not logged
+}
+
+// take removes a token if one is available. Otherwise it reports how long
+// until one will be.
+func (b *tokenBucket) take(now time.Time, limit rateLimit) (time.Duration, bool) {
+	b.tokens = min(float64(limit.burst), b.tokens+now.Sub(b.last).Seconds()*limit.perSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := (1 - b.tokens) / limit.perSecond
+	return time.Duration(wait * float64(time.Second)), false
+}
+
+// rateLimitError is a refused call: the reason and when to retry.
+type rateLimitError struct {
+	reason     string
+	retryAfter time.Duration
+}
+
+func (e *rateLimitError) Error() string { return e.reason }
+
+func (e *rateLimitError) Unwrap() error { return protocol.ErrRateLimited }
+
+// rateLimiter applies the limits. It is safe for concurrent use.
+type rateLimiter struct {
+	limits map[rateClass]rateLimit
+	now    func() time.Time // indirection for tests
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*tokenBucket
+
+	// Running state-changing handlers, in total and per UID.
+	changes      int
+	changesByUID map[uint32]int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		limits:       defaultRateLimits,
+		now:          time.Now,
+		buckets:      make(map[bucketKey]*tokenBucket),
+		changesByUID: make(map[uint32]int),
+	}
+}
+
+// admit decides whether uid may call method now. If so, the caller must call
+// release once the handler has returned. first reports whether this refusal
+// starts a new run of them, for logging.
+func (l *rateLimiter) admit(uid uint32, method string) (release func(), first bool, err *rateLimitError) {
+	class := rateClassOf(method)
+	if class == rateQuery {
+		return func() {}, false, nil
+	}
+
+	if uid != 0 {
+		if err, first := l.take(uid, class, method); err != nil {
+			return nil, first, err
+		}
+	}
+
+	if err := l.start(uid); err != nil {
+		return nil, true, err
+	}
+	return func() { l.finish(uid) }, false, nil
+}
+
+// start takes a concurrency slot for uid, if the caps leave one.
+func (l *rateLimiter) start(uid uint32) *rateLimitError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case uid != 0 && l.changesByUID[uid] >= maxConcurrentChangesPerUID:
+		return &rateLimitError{
+			reason: fmt.Sprintf("%d of your operations already in progress", l.changesByUID[uid]),
+		}
+	case uid != 0 && l.changes >= maxConcurrentChanges-rootReservedChanges,
+		l.changes >= maxConcurrentChanges:
+		return &rateLimitError{
+			reason: fmt.Sprintf("%d operations already in progress", l.changes),
+		}
+	}
+	l.changes++
+	l.changesByUID[uid]++
+	return nil
+}
+
+// finish releases a slot taken by start.
+func (l *rateLimiter) finish(uid uint32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.changes--
+	if l.changesByUID[uid]--; l.changesByUID[uid] == 0 {
+		delete(l.changesByUID, uid)
+	}
+}
+
+// take takes a token from uid's bucket for class.
+func (l *rateLimiter) take(uid uint32, class rateClass, method string) (*rateLimitError, bool) {
+	limit := l.limits[class]
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := bucketKey{uid: uid, class: class}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.burst), last: now}
+		l.buckets[key] = b
+	}
+	wait, ok := b.take(now, limit)
+	if ok {
+		b.limited = false
+		return nil, false
+	}
+	first := !b.limited
+	b.limited = true
+	return &rateLimitError{
+		reason:     fmt.Sprintf("too many %s calls", method),
+		retryAfter: wait,
+	}, first
+}
+
+// admitRequest applies the rate limits to an authorized request, logging the
+// first refusal of a run. The returned release must be called after the
+// handler, unless err is set.
+func (s *Server) admitRequest(client *clientConn, method string) (release func(), err *rateLimitError) {
+	release, first, err := s.limiter.admit(client.uid, method)
+	if err != nil && first {
+		s.logger.Printf("Rate limited uid=%d pid=%d: %s (%v)", client.uid, client.pid, method, err)
+	}
+	return release, err
+}
@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func TestRateClassOf(t *testing.T) {
+	tests := map[string]rateClass{
+		"state.get":         rateQuery,
+		"openvpn.status":    rateQuery,
+		"audit.query":       rateQuery,
+		"openvpn.logs":      rateQuery,
+		"killswitch.enable": rateChange,
+		"tx.commit":         rateChange,
+		"openvpn.connect":   rateSpawn,
+		"tailscale.login":   rateSpawn,
+		"new.method":        rateChange,
+	}
+	for method, want := range tests {
+		if got := rateClassOf(method); got != want {
+			t.Errorf("rateClassOf(%q) = %d, want %d", method, got, want)
+		}
+	}
+}
+
+// fakeClock is a settable rateLimiter.now.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	l := newRateLimiter()
+	l.now = clock.now
+	l.limits = map[rateClass]rateLimit{rateSpawn: {perSecond: 0.5, burst: 2}}
+
+	for i := range 2 {
+		release, _, err := l.admit(1000, "openvpn.connect")
+		if err != nil {
+			t.Fatalf("call %d within the burst refused: %v", i, err)
+		}
+		release()
+	}
+
+	_, first, err := l.admit(1000, "openvpn.connect")
+	if err == nil {
+		t.Fatal("a call past the burst should be refused")
+	}
+	if !errors.Is(err, protocol.ErrRateLimited) {
+		t.Errorf("error %v should match ErrRateLimited", err)
+	}
+	if !first {
+		t.Error("the first refusal should be reported for logging")
+	}
+	if err.retryAfter != 2*time.Second {
+		t.Errorf("retryAfter = %v, want 2s", err.retryAfter)
+	}
+	if _, first, _ := l.admit(1000, "openvpn.connect"); first {
+		t.Error("a repeated refusal should not be logged again")
+	}
+
+	// Other users, root and queries have their own budgets.
+	if _, _, err := l.admit(1001, "openvpn.connect"); err != nil {
+		t.Errorf("another UID was limited: %v", err)
+	}
+	if _, _, err := l.admit(0, "openvpn.connect"); err != nil {
+		t.Errorf("root was limited: %v", err)
+	}
+	if _, _, err := l.admit(1000, "openvpn.status"); err != nil {
+		t.Errorf("a query was limited: %v", err)
+	}
+
+	clock.t = clock.t.Add(2 * time.Second)
+	release, _, err := l.admit(1000, "openvpn.connect")
+	if err != nil {
+		t.Fatalf("refill did not allow another call: %v", err)
+	}
+	release()
+}
+
+func TestRateLimiterConcurrencyCap(t *testing.T) {
+	l := newRateLimiter()
+
+	var releases []func()
+	for i := range maxConcurrentChanges {
+		release, _, err := l.admit(0, "killswitch.enable")
+		if err != nil {
+			t.Fatalf("change %d refused: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, _, err := l.admit(0, "dns.enable"); err == nil {
+		t.Fatal("a change past the concurrency cap should be refused")
+	}
+	if _, _, err := l.admit(0, "state.get"); err != nil {
+		t.Errorf("queries are not capped: %v", err)
+	}
+
+	releases[0]()
+	release, _, err := l.admit(0, "dns.enable")
+	if err != nil {
+		t.Fatalf("a released slot was not reused: %v", err)
+	}
+	release()
+}
+
+// TestRateLimiterConcurrencyFairness checks that one user's slow calls leave
+// room for other users, and that users together leave room for root.
+func TestRateLimiterConcurrencyFairness(t *testing.T) {
+	l := newRateLimiter()
+
+	admit := func(uid uint32) (func(), bool) {
+		release, _, err := l.admit(uid, "tailscale.login")
+		return release, err == nil
+	}
+
+	var releases []func()
+	for range maxConcurrentChangesPerUID {
+		release, ok := admit(1000)
+		if !ok {
+			t.Fatal("uid 1000 refused below its own cap")
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := admit(1000); ok {
+		t.Fatal("uid 1000 admitted past its own cap")
+	}
+
+	// Other users fill the slots not reserved for root.
+	for uid := uint32(1001); len(releases) < maxConcurrentChanges-rootReservedChanges; uid++ {
+		release, ok := admit(uid)
+		if !ok {
+			t.Fatalf("uid %d refused with %d changes running", uid, len(releases))
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := admit(2000); ok {
+		t.Fatal("a user was admitted into the slots reserved for root")
+	}
+	for range rootReservedChanges {
+		release, ok := admit(0)
+		if !ok {
+			t.Fatal("root refused while its reserved slots were free")
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := admit(0); ok {
+		t.Fatal("root admitted past the global cap")
+	}
+
+	for _, release := range releases {
+		release()
+	}
+	if l.changes != 0 || len(l.changesByUID) != 0 {
+		t.Errorf("after release: %d changes, by UID %v; want none", l.changes, l.changesByUID)
+	}
+}
+
+// TestProcessRequestRateLimited checks the dispatcher's answers: the refused
+// call never reaches its handler, and a handler's own limit gets the same code.
+func TestProcessRequestRateLimited(t *testing.T) {
+	server := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	server.limiter.limits = map[rateClass]rateLimit{rateChange: {perSecond: 1, burst: 1}}
+	calls := 0
+	server.Handlers().Register("test.enable", func(*HandlerContext) (any, error) {
+		calls++
+		return nil, nil
+	})
+	server.Handlers().Register("test.connect", func(*HandlerContext) (any, error) {
+		return nil, fmt.Errorf("%w: too many tunnels", protocol.ErrRateLimited)
+	})
+	client := &clientConn{uid: 1000, server: server}
+
+	call := func(method string) *protocol.Response {
+		req, _ := protocol.NewRequest(1, method, nil)
+		return server.processRequest(context.Background(), client, req)
+	}
+
+	if resp := call("test.enable"); resp.Error != nil {
+		t.Fatalf("first call: %v", resp.Error)
+	}
+	resp := call("test.enable")
+	if resp.Error == nil || resp.Error.Code != protocol.ErrCodeRateLimited {
+		t.Fatalf("second call = %+v, want ErrCodeRateLimited", resp.Error)
+	}
+	if data, ok := resp.Error.Data.(protocol.RateLimitedData); !ok || data.RetryAfterMS <= 0 {
+		t.Errorf("Data = %#v, want a retry delay", resp.Error.Data)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+
+	server.limiter.limits = defaultRateLimits
+	if resp := call("test.connect"); resp.Error == nil || resp.Error.Code != protocol.ErrCodeRateLimited {
+		t.Errorf("handler limit = %+v, want ErrCodeRateLimited", resp.Error)
+	}
+}
@@ -0,0 +1,209 @@
+package daemon
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// =============================================================================
+// METHOD DESCRIPTIONS (system.describe)
+// =============================================================================
+//
+// Register records the Go types a method's params unmarshal into and its
+// result marshals from. Describe turns them into JSON Schema by reflection,
+// following encoding/json's rules (json tags, omitempty, embedded structs), so
+// the description cannot drift from what the handlers actually read and write.
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Describe returns the description of every registered method, sorted by name.
+func (r *HandlerRegistry) Describe() *protocol.DescribeResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := newSchemaBuilder()
+	methods := make([]protocol.MethodDescription, 0, len(names))
+	for _, name := range names {
+		desc := protocol.MethodDescription{Name: name}
+		if info := r.info[name]; info != nil {
+			desc.Summary = info.summary
+			if info.params != nil {
+				desc.Params = b.schemaFor(info.params)
+			}
+			if info.result != nil {
+				desc.Result = b.schemaFor(info.result)
+			}
+		}
+		methods = append(methods, desc)
+	}
+
+	return &protocol.DescribeResult{
+		Schema:  protocol.JSONSchemaDialect,
+		Methods: methods,
+		Defs:    b.defs,
+	}
+}
+
+// schemaBuilder converts Go types to JSON Schema. Named structs become
+// definitions, referenced by name, so each is described once and recursive
+// types terminate.
+type schemaBuilder struct {
+	defs  map[string]*protocol.Schema
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		defs:  make(map[string]*protocol.Schema),
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]reflect.Type),
+	}
+}
+
+// schemaFor returns the schema of values of type t.
+func (b *schemaBuilder) schemaFor(t reflect.Type) *protocol.Schema {
+	switch t {
+	case rawMessageType:
+		return &protocol.Schema{}
+	case timeType:
+		return &protocol.Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return &protocol.Schema{AnyOf: []*protocol.Schema{b.schemaFor(t.Elem()), {Type: "null"}}}
+	case reflect.Bool:
+		return &protocol.Schema{Type: "boolean"}
+	case reflect.Int:
+		return &protocol.Schema{Type: "integer"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &protocol.Schema{Type: "integer", Format: t.Kind().String()}
+	case reflect.Float32:
+		return &protocol.Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &protocol.Schema{Type: "number"}
+	case reflect.String:
+		return &protocol.Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &protocol.Schema{Type: "string", Format: "byte"} // base64, as encoding/json
+		}
+		return &protocol.Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Array:
+		return &protocol.Schema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &protocol.Schema{Type: "object", AdditionalProperties: b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.objectSchema(t)
+		}
+		return &protocol.Schema{Ref: protocol.DefRef(b.define(t))}
+	default:
+		// Interfaces (and anything encoding/json would reject) accept any value.
+		return &protocol.Schema{}
+	}
+}
+
+// define records the named struct t as a definition and returns its name.
+func (b *schemaBuilder) define(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if other, ok := b.taken[name]; ok && other != t {
+		// Two packages use the same name: qualify the later one.
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = upperFirst(pkg) + name
+	}
+	b.names[t] = name
+	b.taken[name] = t
+
+	schema := b.objectSchema(t)
+	schema.Title = name
+	b.defs[name] = schema
+	return name
+}
+
+// objectSchema describes a struct's JSON fields.
+func (b *schemaBuilder) objectSchema(t reflect.Type) *protocol.Schema {
+	schema := &protocol.Schema{Type: "object", Properties: make(map[string]*protocol.Schema)}
+	b.addFields(schema, t)
+	return schema
+}
+
+// addFields adds t's JSON fields to schema, flattening embedded structs the
+// way encoding/json does. Fields already present (from an outer struct) win.
+func (b *schemaBuilder) addFields(schema *protocol.Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(schema, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := schema.Properties[name]; ok {
+			continue
+		}
+
+		prop := b.schemaFor(f.Type)
+		prop.GoName = f.Name
+		schema.Properties[name] = prop
+		if !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func hasOption(opts, want string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == want {
+			return true
+		}
+	}
+	return false
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
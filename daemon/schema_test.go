@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+type schemaInner struct {
+	Name string `json:"name"`
+}
+
+type schemaBase struct {
+	ID int64 `json:"id"`
+}
+
+type schemaSample struct {
+	schemaBase
+	Text     string            `json:"text"`
+	Optional string            `json:"optional,omitempty"`
+	Flag     *bool             `json:"flag,omitempty"`
+	Inner    schemaInner       `json:"inner"`
+	List     []schemaInner     `json:"list"`
+	Labels   map[string]string `json:"labels"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	When     time.Time         `json:"when"`
+	Blob     []byte            `json:"blob"`
+	NoTag    bool
+	Hidden   string `json:"-"`
+}
+
+// TestSchemaFor pins the encoding/json rules the schema follows: tag names,
+// omitempty for required, embedded structs flattened, named structs as
+// definitions, and Go names kept for generated clients.
+func TestSchemaFor(t *testing.T) {
+	b := newSchemaBuilder()
+	ref := b.schemaFor(reflect.TypeOf(schemaSample{}))
+	if ref.Ref != protocol.DefRef("schemaSample") {
+		t.Fatalf("Ref = %q, want a reference to schemaSample", ref.Ref)
+	}
+	s := b.defs["schemaSample"]
+	if s == nil || s.Type != "object" || s.Title != "schemaSample" {
+		t.Fatalf("definition = %+v, want an object titled schemaSample", s)
+	}
+
+	wantTypes := map[string]string{
+		"id": "integer", "text": "string", "optional": "string", "list": "array",
+		"labels": "object", "raw": "", "when": "string", "blob": "string", "NoTag": "boolean",
+	}
+	for name, typ := range wantTypes {
+		prop, ok := s.Properties[name]
+		if !ok {
+			t.Errorf("property %q missing", name)
+			continue
+		}
+		if prop.Type != typ {
+			t.Errorf("%s: type = %q, want %q", name, prop.Type, typ)
+		}
+	}
+	for _, name := range []string{"Hidden", "schemaBase"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("property %q should not be described", name)
+		}
+	}
+
+	if got := s.Properties["id"].Format; got != "int64" {
+		t.Errorf("id format = %q, want int64", got)
+	}
+	if got := s.Properties["when"].Format; got != "date-time" {
+		t.Errorf("when format = %q, want date-time", got)
+	}
+	if got := s.Properties["text"].GoName; got != "Text" {
+		t.Errorf("text GoName = %q, want Text", got)
+	}
+	if flag := s.Properties["flag"]; len(flag.AnyOf) != 2 || flag.AnyOf[0].Type != "boolean" || flag.AnyOf[1].Type != "null" {
+		t.Errorf("flag = %+v, want anyOf boolean/null", flag)
+	}
+	if got := s.Properties["inner"].Ref; got != protocol.DefRef("schemaInner") {
+		t.Errorf("inner Ref = %q, want schemaInner", got)
+	}
+	if got := s.Properties["list"].Items.Ref; got != protocol.DefRef("schemaInner") {
+		t.Errorf("list items Ref = %q, want schemaInner", got)
+	}
+	if got := s.Properties["labels"].AdditionalProperties; got == nil || got.Type != "string" {
+		t.Errorf("labels values = %+v, want string", got)
+	}
+
+	want := []string{"id", "text", "inner", "list", "labels", "when", "blob", "NoTag"}
+	if !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("Required = %v, want %v", s.Required, want)
+	}
+}
+
+// Event shares its name with protocol.Event.
+type Event struct {
+	Other bool `json:"other"`
+}
+
+func TestSchemaNameCollision(t *testing.T) {
+	b := newSchemaBuilder()
+	first := b.schemaFor(reflect.TypeOf(protocol.Event{}))
+	second := b.schemaFor(reflect.TypeOf(Event{}))
+	if first.Ref != protocol.DefRef("Event") {
+		t.Errorf("first Ref = %q, want Event", first.Ref)
+	}
+	if second.Ref != protocol.DefRef("DaemonEvent") {
+		t.Errorf("second Ref = %q, want DaemonEvent", second.Ref)
+	}
+	if again := b.schemaFor(reflect.TypeOf(Event{})); again.Ref != second.Ref {
+		t.Errorf("same type described twice: %q then %q", second.Ref, again.Ref)
+	}
+}
+
+// TestServerDescribe calls system.describe over the socket and checks it lists
+// the built-in methods and a registered method with its types.
+func TestServerDescribe(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(WithSocketPath(socketPath))
+	server.Handlers().Register("test.echo", func(ctx *HandlerContext) (any, error) {
+		return nil, nil
+	}, Params(schemaInner{}), Result([]schemaInner{}), Summary("Echo."))
+	server.Handlers().Register("test.bare", func(ctx *HandlerContext) (any, error) {
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	codec := protocol.NewCodec(conn)
+
+	req, _ := protocol.NewRequest(1, protocol.MethodDescribe, nil)
+	if err := codec.WriteRequest(req); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	resp, err := codec.ReadResponse()
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if !resp.IsSuccess() {
+		t.Fatalf("system.describe failed: %v", resp.Error)
+	}
+
+	var desc protocol.DescribeResult
+	if err := json.Unmarshal(resp.Result, &desc); err != nil {
+		t.Fatalf("Failed to unmarshal description: %v", err)
+	}
+	if desc.Schema != protocol.JSONSchemaDialect {
+		t.Errorf("$schema = %q, want %q", desc.Schema, protocol.JSONSchemaDialect)
+	}
+
+	methods := map[string]protocol.MethodDescription{}
+	for _, m := range desc.Methods {
+		methods[m.Name] = m
+	}
+	for _, name := range []string{"system.ping", "system.describe", "state.get", "events.subscribe"} {
+		if _, ok := methods[name]; !ok {
+			t.Errorf("built-in %s not described", name)
+		}
+	}
+
+	echo := methods["test.echo"]
+	if echo.Summary != "Echo." || echo.Params == nil || echo.Result == nil {
+		t.Fatalf("test.echo = %+v, want summary, params and result", echo)
+	}
+	if echo.Params.Ref != protocol.DefRef("schemaInner") || desc.Defs["schemaInner"] == nil {
+		t.Errorf("test.echo params = %+v, want a resolvable schemaInner reference", echo.Params)
+	}
+	if echo.Result.Type != "array" {
+		t.Errorf("test.echo result type = %q, want array", echo.Result.Type)
+	}
+	if bare := methods["test.bare"]; bare.Params != nil || bare.Result != nil {
+		t.Errorf("test.bare = %+v, want no params or result", bare)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -30,6 +31,10 @@ const (
 	// maxClientsPerUID caps simultaneous connections from a single UID, so one
 	// user (or one runaway process) cannot consume the whole global budget.
 	maxClientsPerUID = 8
+	// maxQueuedRequests caps requests read ahead of the one being handled on a
+	// connection. Reading ahead is what lets a $/cancelRequest reach a running
+	// handler; past the cap the connection simply waits.
+	maxQueuedRequests = 16
 )
 
 // DefaultSocketGroup is the system group granted access to the daemon socket.
@@ -45,6 +50,8 @@ var methodTimeouts = map[string]time.Duration{
 	"wireguard.connect": 60 * time.Second,  // WireGuard setup
 	"tailscale.up":      60 * time.Second,  // Tailscale connection
 	"tailscale.login":   120 * time.Second, // May require browser auth
+	"tx.commit":         60 * time.Second,  // Several firewall/DNS steps
+	"openvpn.logs":      24 * time.Hour,    // Follow mode streams until cancelled
 }
 
 // getMethodTimeout returns the timeout for a given method.
@@ -62,11 +69,42 @@ type Server struct {
 	socketGroup string
 	listener    net.Listener
 
+	// Set when the listener came from systemd socket activation (see
+	// WithListener): the socket file belongs to the .socket unit.
+	activated bool
+
+	// Owner-only mode (see WithOwnerOnly): the socket is private to ownerUID
+	// and only that UID is authorized.
+	ownerOnly bool
+	ownerUID  uint32
+
 	// Handler registry
 	handlers *HandlerRegistry
 
 	// State management
-	state *State
+	state     *State
+	statePath string
+
+	// Event fan-out to subscribed clients
+	events *EventHub
+
+	// Reported by system.version and system.hello
+	version      string
+	capabilities func() map[string]bool
+
+	// Optional caller-identity verification (nil = disabled)
+	identity       *identityVerifier
+	identityPolicy *IdentityPolicy
+	procRoot       string
+
+	// Optional per-method policy (nil = every method open), swapped on reload
+	policy atomic.Pointer[MethodPolicy]
+
+	// Optional audit trail of privileged calls (nil = log lines only)
+	auditLog *AuditLog
+
+	// Per-UID rate limits and the concurrency cap (see ratelimit.go)
+	limiter *rateLimiter
 
 	// Client tracking
 	clients   map[*clientConn]struct{}
@@ -86,6 +124,34 @@ type clientConn struct {
 	gid    uint32
 	pid    int32
 	server *Server
+
+	// Event subscription (set by events.subscribe)
+	subMu sync.Mutex
+	sub   *eventSubscriber
+
+	// Caller-identity verification result (see identity.go)
+	identity       callerIdentity
+	identityWarned atomic.Bool
+
+	// Primary and supplementary groups, for the method policy (see policy.go)
+	groupsOnce sync.Once
+	gids       []uint32
+
+	// Executable, for the audit trail (see audit.go)
+	exeOnce sync.Once
+	exe     string
+
+	// Requests read but not yet answered, by ID, so $/cancelRequest (or the
+	// client hanging up) can cancel their handlers
+	inflightMu sync.Mutex
+	inflight   map[int]context.CancelFunc
+}
+
+// queuedRequest is a request waiting for, or running in, its connection's
+// handler goroutine.
+type queuedRequest struct {
+	req *protocol.Request
+	ctx context.Context
 }
 
 // ServerOption configures the Server.
@@ -115,6 +181,75 @@ func WithSocketGroup(group string) ServerOption {
 	}
 }
 
+// WithStatePath persists the daemon State to path and restores it from there
+// on startup (see persist.go). Without it, State lives in memory only.
+func WithStatePath(path string) ServerOption {
+	return func(s *Server) {
+		s.statePath = path
+	}
+}
+
+// WithCallerIdentity enables caller-identity verification against the given
+// policy (see identity.go). A nil policy leaves verification disabled.
+func WithCallerIdentity(policy *IdentityPolicy) ServerOption {
+	return func(s *Server) {
+		s.identityPolicy = policy
+	}
+}
+
+// WithMethodPolicy restricts methods to the users and groups named in policy
+// (see policy.go). A nil policy leaves every method open.
+func WithMethodPolicy(policy *MethodPolicy) ServerOption {
+	return func(s *Server) {
+		s.policy.Store(policy)
+	}
+}
+
+// WithOwnerOnly makes the socket private to the user running the server:
+// mode 0600, owned by that user, with no group access, and every request must
+// come from the same UID. It is meant for per-user services (the session
+// agent) that reuse the daemon's RPC machinery without running as root.
+func WithOwnerOnly() ServerOption {
+	return func(s *Server) {
+		s.ownerOnly = true
+		s.ownerUID = uint32(os.Getuid())
+	}
+}
+
+// WithVersion sets the release reported by system.version and system.hello.
+func WithVersion(version string) ServerOption {
+	return func(s *Server) {
+		if version != "" {
+			s.version = version
+		}
+	}
+}
+
+// WithCapabilities sets the probe whose result system.hello reports as the
+// host's capabilities (see the protocol.Cap* flags). It runs on every hello, so
+// a tool installed while the daemon runs shows up on the client's next
+// connection. Without it the server reports none.
+func WithCapabilities(probe func() map[string]bool) ServerOption {
+	return func(s *Server) {
+		s.capabilities = probe
+	}
+}
+
+// WithListener serves on a listener created by someone else, typically the
+// socket systemd passed through socket activation (see SystemdListener).
+// Start then neither creates nor secures the socket, and Stop leaves its file
+// in place: the .socket unit owns both, and keeps accepting connections for
+// the next instance.
+func WithListener(listener net.Listener) ServerOption {
+	return func(s *Server) {
+		s.listener = listener
+		s.activated = true
+		if addr, ok := listener.Addr().(*net.UnixAddr); ok && addr.Name != "" {
+			s.socketPath = addr.Name
+		}
+	}
+}
+
 // NewServer creates a new daemon server with the given options.
 func NewServer(opts ...ServerOption) *Server {
 	s := &Server{
@@ -122,24 +257,48 @@ func NewServer(opts ...ServerOption) *Server {
 		socketGroup: DefaultSocketGroup,
 		handlers:    NewHandlerRegistry(),
 		state:       NewState(),
+		limiter:     newRateLimiter(),
 		clients:     make(map[*clientConn]struct{}),
 		done:        make(chan struct{}),
 		logger:      log.Default(),
+		version:     "1.0.0",
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	if s.statePath != "" {
+		s.state = LoadState(s.statePath)
+	}
+	s.events = NewEventHub(s.logger)
+	if s.identityPolicy != nil {
+		s.identity = newIdentityVerifier(s.identityPolicy, s.procRoot)
+	}
+
+	// Registered up front so system.describe covers them before Start.
+	s.registerBuiltinHandlers()
+
 	return s
 }
 
 // Start begins listening for client connections.
 // It creates the socket directory if needed and removes any stale socket.
 func (s *Server) Start(ctx context.Context) error {
+	if s.activated {
+		s.logger.Printf("Daemon listening on %s (socket activation)", s.socketPath)
+		s.wg.Add(1)
+		go s.acceptLoop(ctx)
+		return nil
+	}
+
 	// Ensure socket directory exists
 	socketDir := filepath.Dir(s.socketPath)
-	if err := os.MkdirAll(socketDir, 0755); err != nil {
+	dirMode := os.FileMode(0755)
+	if s.ownerOnly {
+		dirMode = 0700
+	}
+	if err := os.MkdirAll(socketDir, dirMode); err != nil {
 		return fmt.Errorf("create socket directory: %w", err)
 	}
 
@@ -173,9 +332,6 @@ func (s *Server) Start(ctx context.Context) error {
 
 	s.logger.Printf("Daemon listening on %s", s.socketPath)
 
-	// Register built-in handlers
-	s.registerBuiltinHandlers()
-
 	// Start accepting connections
 	s.wg.Add(1)
 	go s.acceptLoop(ctx)
@@ -192,6 +348,16 @@ func (s *Server) Start(ctx context.Context) error {
 // the daemon stays secure, but the GUI cannot connect until the group is created
 // and the user is added to it. It never falls back to a world-accessible mode.
 func (s *Server) secureSocket() error {
+	// Owner-only: the umask in Start already created the socket 0600 and owned
+	// by us; set the mode explicitly so it does not depend on that.
+	if s.ownerOnly {
+		if err := os.Chmod(s.socketPath, 0600); err != nil {
+			return fmt.Errorf("chmod 0600: %w", err)
+		}
+		s.logger.Printf("Socket secured: %s (uid %d only, mode 0600)", s.socketPath, s.ownerUID)
+		return nil
+	}
+
 	if err := os.Chmod(s.socketPath, 0660); err != nil {
 		return fmt.Errorf("chmod 0660: %w", err)
 	}
@@ -241,9 +407,11 @@ func (s *Server) Stop() error {
 	// Wait for goroutines to finish
 	s.wg.Wait()
 
-	// Remove socket file
-	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
-		s.logger.Printf("Warning: failed to remove socket: %v", err)
+	// Remove socket file, unless the .socket unit owns it
+	if !s.activated {
+		if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+			s.logger.Printf("Warning: failed to remove socket: %v", err)
+		}
 	}
 
 	s.logger.Println("Daemon stopped")
@@ -328,7 +496,9 @@ func (s *Server) registerClient(client *clientConn) bool {
 	if len(s.clients) >= maxConcurrentClients {
 		return false
 	}
-	if client.uid != 0 {
+	// In owner-only mode every client shares one UID, so the per-UID cap
+	// would just be a lower global cap.
+	if client.uid != 0 && !s.ownerOnly {
 		perUID := 0
 		for c := range s.clients {
 			if c.uid == client.uid {
@@ -353,10 +523,36 @@ func (s *Server) handleClient(client *clientConn) {
 		delete(s.clients, client)
 		s.clientsMu.Unlock()
 
+		client.subMu.Lock()
+		if client.sub != nil {
+			s.events.unsubscribe(client.sub)
+			client.sub = nil
+		}
+		client.subMu.Unlock()
+
 		_ = client.conn.Close()
 		s.logger.Printf("Client disconnected: uid=%d pid=%d", client.uid, client.pid)
 	}()
 
+	// Resolve the caller's executable now, while the SO_PEERCRED PID still
+	// refers to the process that connected.
+	if s.identity != nil && client.uid != 0 {
+		client.identity = s.identity.verify(client.pid)
+	}
+
+	// Requests are handled one at a time, in order, by a separate goroutine so
+	// this one can keep reading: a $/cancelRequest for the running request
+	// must not wait behind it.
+	queue := make(chan queuedRequest, maxQueuedRequests)
+	handled := make(chan struct{})
+	go s.serveRequests(client, queue, handled)
+	defer func() {
+		// A client that hangs up is not waiting for its results either.
+		client.cancelAll()
+		close(queue)
+		<-handled
+	}()
+
 	for {
 		select {
 		case <-s.done:
@@ -374,19 +570,93 @@ func (s *Server) handleClient(client *clientConn) {
 			return
 		}
 
-		// Process request
-		resp := s.processRequest(client, req)
+		if req.Method == protocol.MethodCancelRequest {
+			var params protocol.CancelParams
+			if err := req.UnmarshalParams(&params); err == nil {
+				client.cancel(params.ID)
+			}
+			continue
+		}
+
+		queue <- queuedRequest{req: req, ctx: client.track(req.ID)}
+	}
+}
+
+// serveRequests handles a connection's requests in order and writes their
+// responses. It closes handled once queue is closed and drained.
+func (s *Server) serveRequests(client *clientConn, queue <-chan queuedRequest, handled chan<- struct{}) {
+	defer close(handled)
+
+	writeFailed := false
+	for q := range queue {
+		var resp *protocol.Response
+		if q.ctx.Err() != nil {
+			// Cancelled while queued: never started, nothing to undo.
+			resp = protocol.CancelledError(q.req.ID)
+		} else {
+			resp = s.processRequest(q.ctx, client, q.req)
+		}
+		client.untrack(q.req.ID)
 
-		// Send response
+		if writeFailed {
+			continue
+		}
 		if err := client.codec.WriteResponse(resp); err != nil {
 			s.logger.Printf("Write error to uid=%d: %v", client.uid, err)
-			return
+			// Unblock the reader; the remaining requests are cancelled.
+			writeFailed = true
+			_ = client.conn.Close()
 		}
 	}
 }
 
-// processRequest routes a request to the appropriate handler.
-func (s *Server) processRequest(client *clientConn, req *protocol.Request) *protocol.Response {
+// track registers a request as in flight and returns the context its handler
+// runs under, cancelled by cancel or cancelAll.
+func (c *clientConn) track(id int) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if c.inflight == nil {
+		c.inflight = make(map[int]context.CancelFunc)
+	}
+	c.inflight[id] = cancel
+	return ctx
+}
+
+// untrack releases a request once it has been answered.
+func (c *clientConn) untrack(id int) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if cancel, ok := c.inflight[id]; ok {
+		cancel()
+		delete(c.inflight, id)
+	}
+}
+
+// cancel cancels the in-flight request with the given ID. Unknown IDs (the
+// request already finished) are ignored.
+func (c *clientConn) cancel(id int) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	if cancel, ok := c.inflight[id]; ok {
+		c.server.logger.Printf("Request %d cancelled by uid=%d", id, c.uid)
+		cancel()
+	}
+}
+
+// cancelAll cancels every in-flight request of the connection.
+func (c *clientConn) cancelAll() {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+	for _, cancel := range c.inflight {
+		cancel()
+	}
+}
+
+// processRequest routes a request to the appropriate handler. The handler runs
+// under ctx, which the client can cancel.
+func (s *Server) processRequest(ctx context.Context, client *clientConn, req *protocol.Request) *protocol.Response {
 	// Find handler
 	handler, ok := s.handlers.Get(req.Method)
 	if !ok {
@@ -394,11 +664,24 @@ func (s *Server) processRequest(client *clientConn, req *protocol.Request) *prot
 	}
 
 	// Check authorization
+	start := time.Now()
 	if !s.isAuthorized(client, req.Method) {
 		s.logger.Printf("Unauthorized request from uid=%d: %s", client.uid, req.Method)
+		s.recordAudit(client, req, start, errAuditDenied)
+		return protocol.UnauthorizedError(req.ID)
+	}
+	if !s.checkCallerIdentity(client, req.Method) || !s.checkMethodPolicy(client, req.Method) {
+		s.recordAudit(client, req, start, errAuditDenied)
 		return protocol.UnauthorizedError(req.ID)
 	}
 
+	release, limitErr := s.admitRequest(client, req.Method)
+	if limitErr != nil {
+		s.recordAudit(client, req, start, limitErr)
+		return protocol.RateLimitedError(req.ID, limitErr.reason, limitErr.retryAfter)
+	}
+	defer release()
+
 	// Audit trail: record privileged (state-mutating) invocations with caller
 	// identity. This does not gate access — it provides forensics for the residual
 	// "same-user process" risk that the socket-group model cannot eliminate.
@@ -406,28 +689,24 @@ func (s *Server) processRequest(client *clientConn, req *protocol.Request) *prot
 		s.logger.Printf("AUDIT: privileged call %s by uid=%d pid=%d", req.Method, client.uid, client.pid)
 	}
 
-	// Create context with timeout for this request
-	timeout := getMethodTimeout(req.Method)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Create handler context
-	handlerCtx := &HandlerContext{
-		Context: ctx,
-		Request: req,
-		UID:     client.uid,
-		GID:     client.gid,
-		PID:     client.pid,
-		State:   s.state,
-		Logger:  s.logger,
-	}
-
 	// Execute handler
-	result, err := handler(handlerCtx)
+	result, err := s.invoke(ctx, client, handler, req)
+	s.recordAudit(client, req, start, err)
 	if err != nil {
+		if ctx.Err() != nil {
+			return protocol.CancelledError(req.ID)
+		}
+		// A method the handler runs for the caller (tx.commit, security.apply)
+		// was denied by the method policy
+		if errors.Is(err, protocol.ErrUnauthorized) {
+			return protocol.UnauthorizedError(req.ID)
+		}
+		// A handler's own limit, e.g. the cap on openvpn processes
+		if errors.Is(err, protocol.ErrRateLimited) {
+			return protocol.RateLimitedError(req.ID, err.Error(), 0)
+		}
 		// Check if it was a timeout
 		if errors.Is(err, context.DeadlineExceeded) {
-			s.logger.Printf("WARN: Handler timeout for %s after %v (uid=%d)", req.Method, timeout, client.uid)
 			return protocol.NewErrorResponse(req.ID, protocol.ErrCodeTimeout, "Operation timed out", nil)
 		}
 		s.logger.Printf("Handler error for %s: %v", req.Method, err)
@@ -443,6 +722,41 @@ func (s *Server) processRequest(client *clientConn, req *protocol.Request) *prot
 	return resp
 }
 
+// invoke runs an authorized request's handler under the method's timeout,
+// within parent (cancelled when the client cancels the request). It is shared
+// by the socket and the D-Bus front ends.
+func (s *Server) invoke(parent context.Context, client *clientConn, handler HandlerFunc, req *protocol.Request) (any, error) {
+	// Create context with timeout for this request
+	timeout := getMethodTimeout(req.Method)
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	// Create handler context
+	handlerCtx := &HandlerContext{
+		Context: ctx,
+		Request: req,
+		UID:     client.uid,
+		GID:     client.gid,
+		PID:     client.pid,
+		State:   s.state,
+		Events:  s.events,
+		Logger:  s.logger,
+		MethodAllowed: func(method string) bool {
+			return s.checkMethodPolicy(client, method)
+		},
+		client: client,
+	}
+
+	result, err := handler(handlerCtx)
+	switch {
+	case parent.Err() != nil:
+		s.logger.Printf("Handler for %s cancelled by the client (uid=%d)", req.Method, client.uid)
+	case errors.Is(err, context.DeadlineExceeded):
+		s.logger.Printf("WARN: Handler timeout for %s after %v (uid=%d)", req.Method, timeout, client.uid)
+	}
+	return result, err
+}
+
 // isAuthorized applies a per-request UID floor. It is a secondary sanity check;
 // the real, kernel-enforced authorization boundary is the socket's group ownership
 // (root:<group>, mode 0660 — see secureSocket and authz.go), which already limits
@@ -457,7 +771,13 @@ func (s *Server) processRequest(client *clientConn, req *protocol.Request) *prot
 //
 // The method argument is intentionally not used to grant/deny here; per-method
 // classification is used only for audit logging in processRequest.
+//
+// In owner-only mode (WithOwnerOnly) only the owner's UID is allowed — not even
+// root, which has no business driving another user's session agent.
 func (s *Server) isAuthorized(client *clientConn, _ string) bool {
+	if s.ownerOnly {
+		return client.uid == s.ownerUID
+	}
 	if client.uid == 0 {
 		return true
 	}
@@ -470,9 +790,34 @@ func (s *Server) isAuthorized(client *clientConn, _ string) bool {
 // registerBuiltinHandlers registers the built-in system handlers.
 func (s *Server) registerBuiltinHandlers() {
 	// System handlers
-	s.handlers.Register("system.ping", handlePing)
-	s.handlers.Register("system.version", handleVersion)
-	s.handlers.Register("state.get", handleGetState)
+	s.handlers.Register("system.ping", handlePing,
+		Result(""), Summary("Check the daemon is alive; returns \"pong\"."))
+	s.handlers.Register("system.version", s.handleVersion,
+		Result(VersionResult{}), Summary("Report the daemon's name and version."))
+	s.handlers.Register(protocol.MethodHello, s.handleHello,
+		Params(protocol.HelloParams{}), Result(protocol.HelloResult{}),
+		Summary("Negotiate the protocol version and report the host's capabilities."))
+	s.handlers.Register(protocol.MethodDescribe, s.handleDescribe,
+		Result(protocol.DescribeResult{}), Summary("List every method with the JSON Schema of its params and result."))
+	s.handlers.Register("state.get", handleGetState,
+		Result(StateSnapshot{}), Summary("Return a snapshot of the daemon state."))
+	s.handlers.Register(MethodAuditQuery, s.handleAuditQuery,
+		Params(AuditQueryParams{}), Result(AuditQueryResult{}),
+		Summary("Return audit records of privileged calls, newest first, filtered by time, method and UID."))
+
+	// Event subscription handlers
+	s.handlers.Register("events.subscribe", handleSubscribe,
+		Params(protocol.SubscribeParams{}), Result(protocol.SubscribeResult{}),
+		Summary("Stream events on this connection as notifications."))
+	s.handlers.Register("events.unsubscribe", handleUnsubscribe,
+		Result(protocol.SubscribeResult{}), Summary("Stop this connection's event stream."))
+}
+
+// SetMethodPolicy replaces the method policy, e.g. after the file was edited
+// and the daemon got SIGHUP. Requests already running are not affected. A nil
+// policy opens every method.
+func (s *Server) SetMethodPolicy(policy *MethodPolicy) {
+	s.policy.Store(policy)
 }
 
 // Handlers returns the handler registry for registering custom handlers.
@@ -485,6 +830,11 @@ func (s *Server) State() *State {
 	return s.state
 }
 
+// Events returns the event hub used to push state changes to subscribed clients.
+func (s *Server) Events() *EventHub {
+	return s.events
+}
+
 // getPeerCredentials retrieves the credentials of the connected peer.
 func getPeerCredentials(conn *net.UnixConn) (*syscall.Ucred, error) {
 	raw, err := conn.SyscallConn()
@@ -515,13 +865,52 @@ func handlePing(ctx *HandlerContext) (any, error) {
 	return "pong", nil
 }
 
-func handleVersion(ctx *HandlerContext) (any, error) {
-	return map[string]string{
-		"version": "1.0.0",
-		"name":    "vpn-managerd",
+// VersionResult is the result of system.version.
+type VersionResult struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+}
+
+func (s *Server) handleVersion(ctx *HandlerContext) (any, error) {
+	return VersionResult{
+		Version: s.version,
+		Name:    "vpn-managerd",
 	}, nil
 }
 
+// handleHello implements system.hello. The daemon answers every client version
+// and leaves the decision to the client, which knows what it needs; an older
+// client is only logged so skew shows up in bug reports.
+func (s *Server) handleHello(ctx *HandlerContext) (any, error) {
+	var params protocol.HelloParams
+	if err := ctx.UnmarshalParams(&params); err != nil {
+		return nil, err
+	}
+	if params.ProtocolVersion < protocol.MinProtocolVersion {
+		ctx.Logger.Printf("Client %q (uid=%d pid=%d) speaks protocol %d, older than the supported minimum %d",
+			params.Client, ctx.UID, ctx.PID, params.ProtocolVersion, protocol.MinProtocolVersion)
+	}
+
+	capabilities := map[string]bool{}
+	if s.capabilities != nil {
+		for name, ok := range s.capabilities() {
+			capabilities[name] = ok
+		}
+	}
+
+	return protocol.HelloResult{
+		ProtocolVersion:    protocol.ProtocolVersion,
+		MinProtocolVersion: protocol.MinProtocolVersion,
+		Daemon:             "vpn-managerd",
+		Version:            s.version,
+		Capabilities:       capabilities,
+	}, nil
+}
+
+func (s *Server) handleDescribe(ctx *HandlerContext) (any, error) {
+	return s.handlers.Describe(), nil
+}
+
 func handleGetState(ctx *HandlerContext) (any, error) {
 	return ctx.State.Snapshot(), nil
 }
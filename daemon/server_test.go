@@ -3,10 +3,12 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"os"
 	"os/user"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -241,6 +243,38 @@ func TestServerIntegration(t *testing.T) {
 	}
 }
 
+func TestServerOwnerOnly(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	server := NewServer(WithSocketPath(socketPath), WithOwnerOnly())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("socket not created: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("socket mode = %o, want 600", mode)
+	}
+	if dir, _ := os.Stat(filepath.Dir(socketPath)); dir.Mode().Perm() != 0700 {
+		t.Errorf("socket directory mode = %o, want 700", dir.Mode().Perm())
+	}
+
+	client := protocol.NewClient(protocol.WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("owner ping failed: %v", err)
+	}
+}
+
 func TestServerMethodNotFound(t *testing.T) {
 	skipIfSocketNotSecurable(t)
 	tempDir := t.TempDir()
@@ -286,6 +320,170 @@ func TestServerMethodNotFound(t *testing.T) {
 	}
 }
 
+func TestServerHello(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(
+		WithSocketPath(socketPath),
+		WithVersion("2.3.4"),
+		WithCapabilities(func() map[string]bool {
+			return map[string]bool{protocol.CapNftables: true, protocol.CapCgroupV2: false}
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	client := protocol.NewClient(protocol.WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+
+	hello, err := client.Hello(ctx)
+	if err != nil {
+		t.Fatalf("Hello: %v", err)
+	}
+	if hello.ProtocolVersion != protocol.ProtocolVersion || hello.MinProtocolVersion != protocol.MinProtocolVersion {
+		t.Errorf("protocol versions = %d/%d, want %d/%d", hello.ProtocolVersion, hello.MinProtocolVersion,
+			protocol.ProtocolVersion, protocol.MinProtocolVersion)
+	}
+	if hello.Version != "2.3.4" || hello.Daemon != "vpn-managerd" {
+		t.Errorf("daemon = %s %s, want vpn-managerd 2.3.4", hello.Daemon, hello.Version)
+	}
+	if !client.HasCapability(protocol.CapNftables) || client.HasCapability(protocol.CapCgroupV2) {
+		t.Errorf("capabilities = %v", hello.Capabilities)
+	}
+	if _, ok := hello.Capabilities[protocol.CapCgroupV2]; !ok {
+		t.Error("a probed-false capability must be reported, not omitted")
+	}
+}
+
+// TestServerCancelRequest checks that cancelling a call in protocol.Client
+// cancels the handler's context in the daemon, and that a request cancelled
+// while queued behind it never runs.
+func TestServerCancelRequest(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(WithSocketPath(socketPath))
+	started := make(chan struct{}, 1)
+	stopped := make(chan error, 1)
+	server.Handlers().Register("test.block", func(ctx *HandlerContext) (any, error) {
+		started <- struct{}{}
+		<-ctx.Context.Done()
+		stopped <- ctx.Context.Err()
+		return nil, ctx.Context.Err()
+	})
+	var queuedRan atomic.Bool
+	server.Handlers().Register("test.queued", func(ctx *HandlerContext) (any, error) {
+		queuedRan.Store(true)
+		return "ran", nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	codec := protocol.NewCodec(conn)
+
+	for i, method := range []string{"test.block", "test.queued"} {
+		id := i + 1
+		req, _ := protocol.NewRequest(id, method, nil)
+		if err := codec.WriteRequest(req); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+		if id == 1 {
+			<-started
+		}
+	}
+	for _, id := range []int{2, 1} {
+		n, _ := protocol.NewNotification(protocol.MethodCancelRequest, protocol.CancelParams{ID: id})
+		if err := codec.WriteNotification(n); err != nil {
+			t.Fatalf("Failed to write cancel: %v", err)
+		}
+	}
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("handler context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not cancelled")
+	}
+
+	for _, id := range []int{1, 2} {
+		resp, err := codec.ReadResponse()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if resp.ID != id || resp.Error == nil || resp.Error.Code != protocol.ErrCodeCancelled {
+			t.Errorf("response = %+v, want ErrCodeCancelled for request %d", resp, id)
+		}
+	}
+	if queuedRan.Load() {
+		t.Error("a request cancelled while queued must not run")
+	}
+}
+
+// TestClientCancelPropagates checks the client side: cancelling the context
+// passed to Call sends $/cancelRequest for that call.
+func TestClientCancelPropagates(t *testing.T) {
+	skipIfSocketNotSecurable(t)
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	server := NewServer(WithSocketPath(socketPath))
+	started := make(chan struct{}, 1)
+	stopped := make(chan struct{})
+	server.Handlers().Register("test.block", func(ctx *HandlerContext) (any, error) {
+		started <- struct{}{}
+		<-ctx.Context.Done()
+		close(stopped)
+		return nil, ctx.Context.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Stop() }()
+
+	client := protocol.NewClient(protocol.WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+
+	callCtx, callCancel := context.WithCancel(ctx)
+	go func() {
+		<-started
+		callCancel()
+	}()
+	if err := client.Call(callCtx, "test.block", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Call error = %v, want context.Canceled", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon handler kept running after the client cancelled")
+	}
+
+	// The connection stays usable.
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("Ping after cancel: %v", err)
+	}
+}
+
 func TestServerStateHandler(t *testing.T) {
 	skipIfSocketNotSecurable(t)
 	tempDir := t.TempDir()
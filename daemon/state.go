@@ -2,6 +2,7 @@
 package daemon
 
 import (
+	"encoding/json"
 	"sync"
 	"time"
 )
@@ -35,8 +36,17 @@ type State struct {
 	// VPN connections (WireGuard)
 	wireguardConnections map[string]VPNConnectionState
 
+	// Desired security posture set by security.apply, as raw JSON: its schema
+	// belongs to the privileged handlers. Nil when no posture is active.
+	securityPosture json.RawMessage
+
 	// Started timestamp
 	startedAt time.Time
+
+	// Persistence (see persist.go). Empty persistPath keeps state in memory only.
+	persistPath string
+	persistMu   sync.Mutex
+	restored    bool
 }
 
 // KillSwitchState represents kill switch configuration and status.
@@ -46,11 +56,18 @@ type KillSwitchState struct {
 	VPNIface string `json:"vpn_iface,omitempty"`
 	Backend  string `json:"backend,omitempty"` // "iptables", "nftables"
 	AllowLAN bool   `json:"allow_lan"`
+
+	// Remaining enable parameters, kept so the kill switch can be re-applied
+	// exactly as it was (transaction rollback).
+	VPNServerIP  string   `json:"vpn_server_ip,omitempty"`
+	VPNServerIPs []string `json:"vpn_server_ips,omitempty"`
+	LANRanges    []string `json:"lan_ranges,omitempty"`
 }
 
 // DNSProtectionState represents DNS protection configuration and status.
 type DNSProtectionState struct {
 	Enabled      bool     `json:"enabled"`
+	VPNIface     string   `json:"vpn_iface,omitempty"`
 	Servers      []string `json:"servers,omitempty"`
 	Mode         string   `json:"mode,omitempty"`    // "off"/"auto"/"strict"/"custom"
 	Backend      string   `json:"backend,omitempty"` // resolver backend actually used
@@ -69,10 +86,17 @@ type IPv6ProtectionState struct {
 
 // SplitTunnelState represents split tunneling configuration and status.
 type SplitTunnelState struct {
-	Enabled  bool     `json:"enabled"`
-	Mode     string   `json:"mode"` // "include", "exclude"
-	Apps     []string `json:"apps,omitempty"`
-	VPNIface string   `json:"vpn_iface,omitempty"`
+	Enabled    bool     `json:"enabled"`
+	Mode       string   `json:"mode"` // "include", "exclude"
+	Apps       []string `json:"apps,omitempty"`
+	VPNIface   string   `json:"vpn_iface,omitempty"`
+	VPNGateway string   `json:"vpn_gateway,omitempty"`
+	SplitDNS   bool     `json:"split_dns,omitempty"`
+	VPNDNS     []string `json:"vpn_dns,omitempty"`
+	SystemDNS  string   `json:"system_dns,omitempty"`
+
+	// Owner is who set the split tunnel up (see logind.go).
+	Owner *SessionOwner `json:"owner,omitempty"`
 }
 
 // LANGatewayState represents LAN gateway configuration and status.
@@ -81,6 +105,9 @@ type LANGatewayState struct {
 	WiFiIface   string `json:"wifi_iface,omitempty"`
 	TailscaleIP string `json:"tailscale_ip,omitempty"`
 	LANNetwork  string `json:"lan_network,omitempty"`
+
+	// Owner is who enabled the gateway (see logind.go).
+	Owner *SessionOwner `json:"owner,omitempty"`
 }
 
 // TailscaleState represents Tailscale connection state.
@@ -101,6 +128,19 @@ type VPNConnectionState struct {
 	IPAddress     string `json:"ip_address,omitempty"`
 	StartedAt     string `json:"started_at,omitempty"`
 	LastError     string `json:"last_error,omitempty"`
+
+	// Runtime details needed to re-adopt the connection after a daemon
+	// restart. CredentialsFile is only set by daemons that predate the
+	// management socket.
+	PID              int    `json:"pid,omitempty"`
+	StagedConfig     string `json:"staged_config,omitempty"`
+	ManagementSocket string `json:"management_socket,omitempty"`
+	CredentialsFile  string `json:"credentials_file,omitempty"`
+	LogFile          string `json:"log_file,omitempty"`
+
+	// Owner is who started the connection (see logind.go). Nil for
+	// connections recorded before owners were.
+	Owner *SessionOwner `json:"owner,omitempty"`
 }
 
 // StateSnapshot is a read-only snapshot of all state.
@@ -152,6 +192,23 @@ func (s *State) Snapshot() StateSnapshot {
 	}
 }
 
+// Active reports whether the daemon is holding anything up: a tunnel, firewall
+// or routing rules, a protection, or a security posture it enforces. An
+// inactive daemon can exit without changing the system.
+func (s *State) Active() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.openvpnConnections) > 0 ||
+		len(s.wireguardConnections) > 0 ||
+		s.killSwitch.Enabled ||
+		s.dnsProtection.Enabled ||
+		s.ipv6Protection.Enabled ||
+		s.splitTunnel.Enabled ||
+		s.lanGateway.Enabled ||
+		len(s.securityPosture) > 0
+}
+
 // KillSwitch getters and setters
 
 // GetKillSwitch returns a copy of the kill switch state.
@@ -163,6 +220,7 @@ func (s *State) GetKillSwitch() KillSwitchState {
 
 // SetKillSwitch updates the kill switch state.
 func (s *State) SetKillSwitch(state KillSwitchState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.killSwitch = state
@@ -170,6 +228,7 @@ func (s *State) SetKillSwitch(state KillSwitchState) {
 
 // SetKillSwitchEnabled updates the kill switch enabled flag.
 func (s *State) SetKillSwitchEnabled(enabled bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.killSwitch.Enabled = enabled
@@ -186,6 +245,7 @@ func (s *State) GetDNSProtection() DNSProtectionState {
 
 // SetDNSProtection updates the DNS protection state.
 func (s *State) SetDNSProtection(state DNSProtectionState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.dnsProtection = state
@@ -193,6 +253,7 @@ func (s *State) SetDNSProtection(state DNSProtectionState) {
 
 // SetDNSProtectionEnabled updates the DNS protection enabled flag.
 func (s *State) SetDNSProtectionEnabled(enabled bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.dnsProtection.Enabled = enabled
@@ -209,6 +270,7 @@ func (s *State) GetIPv6Protection() IPv6ProtectionState {
 
 // SetIPv6Protection updates the IPv6 protection state.
 func (s *State) SetIPv6Protection(state IPv6ProtectionState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.ipv6Protection = state
@@ -216,6 +278,7 @@ func (s *State) SetIPv6Protection(state IPv6ProtectionState) {
 
 // SetIPv6ProtectionEnabled updates the IPv6 protection enabled flag.
 func (s *State) SetIPv6ProtectionEnabled(enabled bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.ipv6Protection.Enabled = enabled
@@ -232,6 +295,7 @@ func (s *State) GetSplitTunnel() SplitTunnelState {
 
 // SetSplitTunnel updates the split tunnel state.
 func (s *State) SetSplitTunnel(state SplitTunnelState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.splitTunnel = state
@@ -239,6 +303,7 @@ func (s *State) SetSplitTunnel(state SplitTunnelState) {
 
 // SetSplitTunnelEnabled updates the split tunnel enabled flag.
 func (s *State) SetSplitTunnelEnabled(enabled bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.splitTunnel.Enabled = enabled
@@ -255,6 +320,7 @@ func (s *State) GetLANGateway() LANGatewayState {
 
 // SetLANGateway updates the LAN gateway state.
 func (s *State) SetLANGateway(state LANGatewayState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lanGateway = state
@@ -262,6 +328,7 @@ func (s *State) SetLANGateway(state LANGatewayState) {
 
 // SetLANGatewayEnabled updates the LAN gateway enabled flag.
 func (s *State) SetLANGatewayEnabled(enabled bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.lanGateway.Enabled = enabled
@@ -275,6 +342,7 @@ func (s *State) SetLANGatewayEnabled(enabled bool) {
 
 // SetOpenVPNConnection updates or adds an OpenVPN connection state.
 func (s *State) SetOpenVPNConnection(profileID string, state VPNConnectionState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.openvpnConnections == nil {
@@ -283,6 +351,21 @@ func (s *State) SetOpenVPNConnection(profileID string, state VPNConnectionState)
 	s.openvpnConnections[profileID] = state
 }
 
+// UpdateOpenVPNConnection applies fn to a tracked OpenVPN connection. It
+// returns false (without calling fn) when the profile is not tracked.
+func (s *State) UpdateOpenVPNConnection(profileID string, fn func(*VPNConnectionState)) bool {
+	defer s.persist()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.openvpnConnections[profileID]
+	if !ok {
+		return false
+	}
+	fn(&state)
+	s.openvpnConnections[profileID] = state
+	return true
+}
+
 // GetOpenVPNConnection returns the state of an OpenVPN connection.
 func (s *State) GetOpenVPNConnection(profileID string) (VPNConnectionState, bool) {
 	s.mu.RLock()
@@ -293,6 +376,7 @@ func (s *State) GetOpenVPNConnection(profileID string) (VPNConnectionState, bool
 
 // RemoveOpenVPNConnection removes an OpenVPN connection from state.
 func (s *State) RemoveOpenVPNConnection(profileID string) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.openvpnConnections, profileID)
@@ -313,6 +397,7 @@ func (s *State) ListOpenVPNConnections() []VPNConnectionState {
 
 // SetWireGuardConnection updates or adds a WireGuard connection state.
 func (s *State) SetWireGuardConnection(interfaceName string, state VPNConnectionState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.wireguardConnections == nil {
@@ -331,6 +416,7 @@ func (s *State) GetWireGuardConnection(interfaceName string) (VPNConnectionState
 
 // RemoveWireGuardConnection removes a WireGuard connection from state.
 func (s *State) RemoveWireGuardConnection(interfaceName string) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.wireguardConnections, interfaceName)
@@ -360,6 +446,7 @@ func (s *State) GetTailscale() TailscaleState {
 
 // SetTailscale updates the Tailscale state.
 func (s *State) SetTailscale(state TailscaleState) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.tailscale = state
@@ -367,7 +454,27 @@ func (s *State) SetTailscale(state TailscaleState) {
 
 // SetTailscaleConnected updates the Tailscale connected flag.
 func (s *State) SetTailscaleConnected(connected bool) {
+	defer s.persist()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.tailscale.Connected = connected
 }
+
+// =============================================================================
+// SECURITY POSTURE
+// =============================================================================
+
+// GetSecurityPosture returns the desired security posture, or nil if none is set.
+func (s *State) GetSecurityPosture() json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append(json.RawMessage(nil), s.securityPosture...)
+}
+
+// SetSecurityPosture replaces the desired security posture. Nil clears it.
+func (s *State) SetSecurityPosture(posture json.RawMessage) {
+	defer s.persist()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.securityPosture = append(json.RawMessage(nil), posture...)
+}
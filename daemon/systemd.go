@@ -0,0 +1,234 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// listenFDsStart is the first file descriptor systemd passes (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// Supervision intervals.
+const (
+	// defaultSuperviseInterval is how often the liveness check runs when
+	// systemd asked for no watchdog.
+	defaultSuperviseInterval = 10 * time.Second
+
+	// livenessTimeout bounds one liveness check.
+	livenessTimeout = 5 * time.Second
+)
+
+// SystemdListener returns the socket systemd passed to the daemon through
+// socket activation (LISTEN_FDS, see sd_listen_fds(3)), or nil when it was
+// started without one. The activation variables are removed from the
+// environment so child processes do not see them.
+func SystemdListener() (net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	n, err := listenFDs(os.Getenv, os.Getpid())
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("socket activation passed %d sockets, want 1", n)
+	}
+
+	syscall.CloseOnExec(listenFDsStart)
+	f := os.NewFile(listenFDsStart, "vpn-managerd.socket")
+	defer func() { _ = f.Close() }()
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+	if _, ok := listener.(*net.UnixListener); !ok {
+		_ = listener.Close()
+		return nil, fmt.Errorf("socket activation passed a %s socket, want a Unix stream socket", listener.Addr().Network())
+	}
+	return listener, nil
+}
+
+// listenFDs returns how many sockets were passed to process pid. Variables
+// meant for another process (LISTEN_PID mismatch) count as none.
+func listenFDs(getenv func(string) string, pid int) (int, error) {
+	pidStr := getenv("LISTEN_PID")
+	if pidStr == "" {
+		return 0, nil
+	}
+	if p, err := strconv.Atoi(pidStr); err != nil || p != pid {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	return n, nil
+}
+
+// Notify sends state to systemd's notification socket (sd_notify(3)), for
+// example "READY=1" or "STATUS=...". It does nothing when the daemon was not
+// started by systemd with a notify socket.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// A leading '@' names a socket in the abstract namespace.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("notify systemd: %w", err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns the watchdog timeout systemd set for this process
+// (WatchdogSec=, passed as WATCHDOG_USEC), or 0 when there is none.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// CheckLiveness dispatches a state.get through the handler registry, as a
+// client request would be, and reports an error unless it is answered within
+// ctx. A deadlocked state lock or a wedged dispatcher fails the check.
+func (s *Server) CheckLiveness(ctx context.Context) error {
+	handler, ok := s.handlers.Get("state.get")
+	if !ok {
+		return errors.New("state.get is not registered")
+	}
+	req, err := protocol.NewRequest(0, "state.get", nil)
+	if err != nil {
+		return err
+	}
+	self := &clientConn{uid: 0, pid: int32(os.Getpid()), server: s}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.invoke(ctx, self, handler, req)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("state.get not answered: %w", ctx.Err())
+	}
+}
+
+// Idle reports whether no client is connected and the daemon holds nothing
+// up (see State.Active).
+func (s *Server) Idle() bool {
+	s.clientsMu.RLock()
+	clients := len(s.clients)
+	s.clientsMu.RUnlock()
+	return clients == 0 && !s.state.Active()
+}
+
+// Supervise runs the liveness loop until ctx is done. Each round checks the
+// handler dispatcher (CheckLiveness) and, when it answers, pets the systemd
+// watchdog and updates the unit's status line; when it does not, the watchdog
+// is left to fire. With idleTimeout > 0 the returned channel is closed once
+// the daemon has been Idle that long, so a socket-activated daemon can exit
+// and let systemd start it again on the next connection.
+func (s *Server) Supervise(ctx context.Context, idleTimeout time.Duration) <-chan struct{} {
+	interval := defaultSuperviseInterval
+	if wd := WatchdogInterval(); wd > 0 {
+		// Twice per timeout, as sd_watchdog_enabled(3) recommends.
+		interval = wd / 2
+	}
+	return s.supervise(ctx, interval, idleTimeout)
+}
+
+func (s *Server) supervise(ctx context.Context, interval, idleTimeout time.Duration) <-chan struct{} {
+	idle := make(chan struct{})
+	watchdog := WatchdogInterval() > 0
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var idleSince time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+
+			checkCtx, cancel := context.WithTimeout(ctx, min(livenessTimeout, interval))
+			err := s.CheckLiveness(checkCtx)
+			cancel()
+			if err != nil {
+				s.logger.Printf("WARN: liveness check failed: %v", err)
+				_ = Notify("STATUS=Not responding: " + err.Error())
+				continue
+			}
+			status := "STATUS=" + s.statusLine()
+			if watchdog {
+				status = "WATCHDOG=1\n" + status
+			}
+			if err := Notify(status); err != nil {
+				s.logger.Printf("WARN: %v", err)
+			}
+
+			if idleTimeout <= 0 {
+				continue
+			}
+			switch {
+			case !s.Idle():
+				idleSince = time.Time{}
+			case idleSince.IsZero():
+				idleSince = time.Now()
+			case time.Since(idleSince) >= idleTimeout:
+				s.logger.Printf("Idle for %v with nothing active, exiting", idleTimeout)
+				close(idle)
+				return
+			}
+		}
+	}()
+	return idle
+}
+
+// statusLine summarizes the daemon for systemctl status.
+func (s *Server) statusLine() string {
+	s.clientsMu.RLock()
+	clients := len(s.clients)
+	s.clientsMu.RUnlock()
+
+	tunnels := len(s.state.ListOpenVPNConnections()) + len(s.state.ListWireGuardConnections())
+	killSwitch := "off"
+	if s.state.GetKillSwitch().Enabled {
+		killSwitch = "on"
+	}
+	return fmt.Sprintf("%d clients, %d tunnels, kill switch %s", clients, tunnels, killSwitch)
+}
@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenFDs(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	tests := []struct {
+		name    string
+		vars    map[string]string
+		want    int
+		wantErr bool
+	}{
+		{"not activated", nil, 0, false},
+		{"one socket", map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "1"}, 1, false},
+		{"meant for another process", map[string]string{"LISTEN_PID": "43", "LISTEN_FDS": "1"}, 0, false},
+		{"malformed count", map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "one"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := listenFDs(env(tt.vars), 42)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("listenFDs = %d, %v; want %d (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// listenNotify stands in for systemd's notification socket.
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Errorf("Notify without systemd = %v, want a no-op", err)
+	}
+
+	conn := listenNotify(t)
+	if err := Notify("READY=1\nSTATUS=Serving"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := readNotify(t, conn); got != "READY=1\nSTATUS=Serving" {
+		t.Errorf("systemd got %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval = %v, want 30s", got)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("watchdog meant for another process = %v, want 0", got)
+	}
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("no watchdog = %v, want 0", got)
+	}
+}
+
+func TestCheckLiveness(t *testing.T) {
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	if err := s.CheckLiveness(context.Background()); err != nil {
+		t.Fatalf("CheckLiveness: %v", err)
+	}
+
+	// A handler stuck on the state lock fails the check instead of hanging it.
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.CheckLiveness(ctx); err == nil {
+		t.Error("CheckLiveness should fail while state.get cannot run")
+	}
+}
+
+func TestServerIdle(t *testing.T) {
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	if !s.Idle() {
+		t.Fatal("a fresh server should be idle")
+	}
+
+	s.state.SetKillSwitchEnabled(true)
+	if s.Idle() {
+		t.Error("an active kill switch should keep the daemon up")
+	}
+	s.state.SetKillSwitchEnabled(false)
+
+	s.state.SetWireGuardConnection("wg0", VPNConnectionState{Status: "connected"})
+	if s.Idle() {
+		t.Error("a tunnel should keep the daemon up")
+	}
+	s.state.RemoveWireGuardConnection("wg0")
+
+	client := &clientConn{uid: 1000}
+	s.registerClient(client)
+	if s.Idle() {
+		t.Error("a connected client should keep the daemon up")
+	}
+}
+
+// TestSupervise checks one loop round pets the watchdog with a status line,
+// and that the idle channel closes once nothing has been active long enough.
+func TestSupervise(t *testing.T) {
+	conn := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	idle := s.supervise(ctx, 10*time.Millisecond, 30*time.Millisecond)
+
+	msg := readNotify(t, conn)
+	if !strings.HasPrefix(msg, "WATCHDOG=1\n") || !strings.Contains(msg, "STATUS=0 clients, 0 tunnels, kill switch off") {
+		t.Errorf("systemd got %q", msg)
+	}
+
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle daemon did not ask to exit")
+	}
+
+	// Without an idle timeout it never does.
+	never := s.supervise(ctx, 10*time.Millisecond, 0)
+	select {
+	case <-never:
+		t.Error("idle exit without an idle timeout")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestServerWithListener serves on a socket created elsewhere (as systemd
+// does) and leaves its file to the owner on Stop.
+func TestServerWithListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activated.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	s := NewServer(WithLogger(log.New(io.Discard, "", 0)), WithListener(ln))
+	if s.socketPath != path {
+		t.Errorf("socketPath = %q, want the listener's %q", s.socketPath, path)
+	}
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("socket file removed on Stop: %v", err)
+	}
+}
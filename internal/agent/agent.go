@@ -0,0 +1,473 @@
+// Package agent implements the session agent: a headless, per-user service
+// that owns the VPN connections so protection does not depend on the GUI.
+//
+// The agent runs as a systemd user unit. It holds the vpn.Manager and with it
+// the health checker (auto-reconnect), the trust coordinator and its network
+// monitor, and the stats collector; it also acts on each profile's
+// AutoConnect flag. The GUI and vpnctl are thin clients: they find the agent
+// socket (daemon.AgentSocketPath), forward connect and disconnect to it and
+// mirror its connections (see vpn.Manager.UseSessionAgent).
+//
+// The agent speaks the daemon's JSON-RPC protocol on an owner-only socket, so
+// only the user it runs as can reach it. Privileged work still goes through
+// vpn-managerd; the agent is unprivileged.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	rpc "github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/internal/config"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/eventbus"
+	"github.com/yllada/vpn-manager/internal/keyring"
+	"github.com/yllada/vpn-manager/internal/metrics"
+	"github.com/yllada/vpn-manager/internal/notify"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/health"
+)
+
+// Test seams.
+var (
+	keyringGet = keyring.Get
+	loadConfig = config.Load
+)
+
+// Agent is the session agent. Create it with New, then Start it.
+type Agent struct {
+	manager *vpn.Manager
+	server  *rpc.Server
+	logger  *log.Logger
+	version string
+
+	mu  sync.RWMutex
+	cfg *config.Config
+
+	subs []*eventbus.Subscription
+
+	// Metrics outputs; both empty leaves the exporter off.
+	metricsListen   string
+	metricsTextfile string
+	metricsInterval time.Duration
+	exporter        *metrics.Exporter
+}
+
+// Option configures an Agent.
+type Option func(*agentOptions)
+
+type agentOptions struct {
+	socketPath string
+	logger     *log.Logger
+	version    string
+
+	metricsListen   string
+	metricsTextfile string
+	metricsInterval time.Duration
+}
+
+// WithSocketPath overrides the socket path (default daemon.AgentSocketPath).
+func WithSocketPath(path string) Option {
+	return func(o *agentOptions) {
+		o.socketPath = path
+	}
+}
+
+// WithLogger sets the logger for the agent and its RPC server.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *agentOptions) {
+		o.logger = logger
+	}
+}
+
+// WithVersion sets the version reported by agent.status.
+func WithVersion(version string) Option {
+	return func(o *agentOptions) {
+		o.version = version
+	}
+}
+
+// WithMetricsListen serves metrics over HTTP on addr, which must be a
+// loopback address (e.g. "127.0.0.1:9586").
+func WithMetricsListen(addr string) Option {
+	return func(o *agentOptions) {
+		o.metricsListen = addr
+	}
+}
+
+// WithMetricsTextfile writes metrics to path every interval, for
+// node_exporter's textfile collector.
+func WithMetricsTextfile(path string, interval time.Duration) Option {
+	return func(o *agentOptions) {
+		o.metricsTextfile = path
+		o.metricsInterval = interval
+	}
+}
+
+// New creates an agent around manager, using cfg for its settings.
+func New(manager *vpn.Manager, cfg *config.Config, opts ...Option) *Agent {
+	o := agentOptions{
+		socketPath: daemon.AgentSocketPath(),
+		logger:     log.Default(),
+		version:    "dev",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	a := &Agent{
+		manager: manager,
+		logger:  o.logger,
+		version: o.version,
+		cfg:     cfg,
+
+		metricsListen:   o.metricsListen,
+		metricsTextfile: o.metricsTextfile,
+		metricsInterval: o.metricsInterval,
+
+		server: rpc.NewServer(
+			rpc.WithSocketPath(o.socketPath),
+			rpc.WithLogger(o.logger),
+			rpc.WithOwnerOnly(),
+		),
+	}
+	a.registerHandlers()
+	return a
+}
+
+// Start applies the settings, starts serving clients and takes over the
+// background duties: it adopts the tunnels the daemon is already running,
+// starts the health checker, trust management and the certificate expiry
+// warnings, connects the AutoConnect profiles and turns on the metrics outputs
+// that were asked for.
+func (a *Agent) Start(ctx context.Context) error {
+	a.applySecurity(a.config())
+
+	if err := a.server.Start(ctx); err != nil {
+		return err
+	}
+
+	a.forwardEvents()
+
+	// Re-adopt what a previous agent (or the GUI) left running before acting
+	// on AutoConnect, so a live tunnel is not connected twice.
+	a.manager.AdoptRunningConnections()
+	if detected, info := a.manager.DetectOrphanedVPN(); detected {
+		a.logger.Printf("WARN: VPN not managed by vpn-managerd is up: interface=%s, ip=%s", info.Interface, info.IPAddress)
+	}
+
+	a.setupHealthChecker()
+
+	if err := a.manager.InitTrustManagement(); err != nil {
+		a.logger.Printf("WARN: trust management unavailable: %v", err)
+	}
+
+	a.manager.StartExpiryChecker()
+
+	a.autoConnect()
+
+	return a.startMetrics()
+}
+
+// Stop stops serving clients and the background duties. The tunnels stay up:
+// vpn-managerd keeps running them, and the next agent adopts them.
+func (a *Agent) Stop() error {
+	if a.exporter != nil {
+		if err := a.exporter.Stop(); err != nil {
+			a.logger.Printf("WARN: stopping metrics exporter: %v", err)
+		}
+		a.exporter = nil
+	}
+
+	for _, sub := range a.subs {
+		sub.Unsubscribe()
+	}
+	a.subs = nil
+
+	a.manager.StopExpiryChecker()
+	a.manager.StopTrustManagement()
+	a.manager.StopHealthChecker()
+	return a.server.Stop()
+}
+
+// Reload re-reads the configuration and applies it: security settings, the
+// auto-reconnect switch and the trust rules.
+func (a *Agent) Reload() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	a.mu.Lock()
+	a.cfg = cfg
+	a.mu.Unlock()
+
+	a.applySecurity(cfg)
+
+	a.manager.StopHealthChecker()
+	a.setupHealthChecker()
+
+	a.manager.StopTrustManagement()
+	if err := a.manager.InitTrustManagement(); err != nil {
+		a.logger.Printf("WARN: trust management unavailable: %v", err)
+	}
+
+	a.logger.Printf("Configuration reloaded")
+	return nil
+}
+
+func (a *Agent) config() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.cfg
+}
+
+// applySecurity hands the persisted security settings to the manager, as the
+// GUI does at startup.
+func (a *Agent) applySecurity(cfg *config.Config) {
+	a.manager.ApplyKillSwitchConfig(cfg.Security.KillSwitchMode, cfg.Security.KillSwitchLAN)
+	a.manager.ApplyDNSConfig(cfg.Security.DNSMode, cfg.Security.CustomDNS, cfg.Security.BlockDoH, cfg.Security.BlockDoT)
+	a.manager.ApplyIPv6Config(cfg.Security.IPv6Mode)
+}
+
+// =============================================================================
+// BACKGROUND DUTIES
+// =============================================================================
+
+// setupHealthChecker starts auto-reconnect when it is enabled. The callbacks
+// notify the desktop and, for an OTP the agent cannot supply, ask a client
+// for credentials.
+func (a *Agent) setupHealthChecker() {
+	cfg := a.config()
+	if !cfg.AutoReconnect {
+		return
+	}
+	hc := a.manager.HealthChecker()
+	if hc == nil {
+		return
+	}
+
+	hcConfig := health.DefaultConfig()
+	hcConfig.AutoReconnect = cfg.AutoReconnect
+	hc.UpdateConfig(hcConfig)
+
+	hc.SetOnHealthChange(func(profileID string, oldState, newState health.State) {
+		name := a.profileName(profileID)
+		switch {
+		case newState == health.StateUnhealthy:
+			a.notify(func() { notify.ConnectionError(name, "Connection lost - attempting to reconnect...") })
+		case newState == health.StateHealthy && oldState == health.StateUnhealthy:
+			a.notify(func() { notify.Connected(name + " (reconnected)") })
+		}
+	})
+	hc.SetOnReconnecting(func(profileID string, attempt int) {
+		a.logger.Printf("Reconnecting %s (attempt %d)", a.profileName(profileID), attempt)
+	})
+	hc.SetOnReconnectFailed(func(profileID string, err error) {
+		name := a.profileName(profileID)
+		a.logger.Printf("Auto-reconnect of %s failed: %v", name, err)
+		a.notify(func() { notify.ConnectionError(name, "Auto-reconnect failed after multiple attempts") })
+	})
+	hc.SetOnOTPRequired(func(profileID, username, _ string) {
+		a.requestAuth(daemon.AgentAuthRequiredEvent{
+			ProfileID: profileID,
+			Username:  username,
+			NeedsOTP:  true,
+			Reason:    "reconnect",
+		}, "Connection lost - OTP required to reconnect")
+	})
+
+	a.manager.StartHealthChecker()
+}
+
+// startMetrics turns on the metrics outputs that were asked for.
+func (a *Agent) startMetrics() error {
+	if a.metricsListen == "" && a.metricsTextfile == "" {
+		return nil
+	}
+	a.exporter = metrics.New(a.manager, a.logger)
+	if a.metricsListen != "" {
+		if err := a.exporter.Listen(a.metricsListen); err != nil {
+			return fmt.Errorf("metrics: %w", err)
+		}
+		a.logger.Printf("Serving metrics on http://%s/metrics", a.exporter.Addr())
+	}
+	if a.metricsTextfile != "" {
+		if err := a.exporter.StartTextfile(a.metricsTextfile, a.metricsInterval); err != nil {
+			return fmt.Errorf("metrics: %w", err)
+		}
+		a.logger.Printf("Writing metrics to %s", a.metricsTextfile)
+	}
+	return nil
+}
+
+// autoConnect connects every profile marked AutoConnect that is not already
+// up. A profile needing an OTP cannot be connected unattended, so a client is
+// asked for it instead.
+func (a *Agent) autoConnect() {
+	for _, p := range a.manager.ProfileManager().List() {
+		if !p.AutoConnect {
+			continue
+		}
+		if _, up := a.manager.GetConnection(p.ID); up {
+			continue
+		}
+		if p.RequiresOTP {
+			a.requestAuth(daemon.AgentAuthRequiredEvent{
+				ProfileID: p.ID,
+				Username:  p.Username,
+				NeedsOTP:  true,
+				Reason:    "autoconnect",
+			}, "OTP required to auto-connect")
+			continue
+		}
+
+		password := ""
+		if p.SavePassword {
+			password, _ = keyringGet(p.ID)
+		}
+		a.logger.Printf("Auto-connecting %s", p.Name)
+		if err := a.manager.Connect(p.ID, p.Username, password); err != nil {
+			a.logger.Printf("Auto-connect of %s failed: %v", p.Name, err)
+		}
+	}
+}
+
+// forwardEvents republishes the manager's connection and trust events to
+// subscribed clients, and notifies the desktop of expiring certificates.
+func (a *Agent) forwardEvents() {
+	publish := func(event *eventbus.Event) {
+		data, ok := event.Data.(eventbus.ConnectionEventData)
+		if !ok {
+			return
+		}
+		a.server.Events().Publish(daemon.AgentTopicConnection, a.connectionStatus(event.Type, data))
+	}
+	for _, t := range []eventbus.EventType{
+		eventbus.EventConnectionStarting,
+		eventbus.EventConnectionEstablished,
+		eventbus.EventConnectionFailed,
+		eventbus.EventConnectionClosed,
+	} {
+		a.subs = append(a.subs, eventbus.On(t, publish))
+	}
+
+	a.subs = append(a.subs, eventbus.On(eventbus.EventTrustAuthRequired, func(event *eventbus.Event) {
+		data, ok := event.Data.(eventbus.TrustAuthRequiredData)
+		if !ok {
+			return
+		}
+		a.requestAuth(daemon.AgentAuthRequiredEvent{
+			ProfileID: data.ProfileID,
+			Username:  data.Username,
+			NeedsOTP:  data.NeedsOTP,
+			Reason:    "trust",
+			SSID:      data.SSID,
+		}, fmt.Sprintf("Connected to untrusted network '%s' - OTP required", data.SSID))
+	}))
+
+	a.subs = append(a.subs, eventbus.On(eventbus.EventAuthOTPRequired, func(event *eventbus.Event) {
+		data, ok := event.Data.(eventbus.AuthChallengeData)
+		if !ok {
+			return
+		}
+		a.requestAuth(daemon.AgentAuthRequiredEvent{
+			ProfileID: data.ProfileID,
+			NeedsOTP:  true,
+			Reason:    "challenge",
+			Challenge: &daemon.OpenVPNChallenge{
+				Text:             data.Text,
+				Echo:             data.Echo,
+				ResponseRequired: data.ResponseRequired,
+			},
+		}, data.Text)
+	}))
+
+	a.subs = append(a.subs, eventbus.On(eventbus.EventCertificateExpiring, func(event *eventbus.Event) {
+		data, ok := event.Data.(eventbus.CertificateExpiryData)
+		if !ok {
+			return
+		}
+		a.logger.Printf("WARN: %s", data.Message)
+		a.notify(func() { notify.CertificateExpiring(data.Message) })
+	}))
+}
+
+// connectionStatus builds the agent.connection payload for a bus event. The
+// live connection is authoritative; once it is gone the event is all there is.
+func (a *Agent) connectionStatus(t eventbus.EventType, data eventbus.ConnectionEventData) daemon.AgentConnectionStatus {
+	if conn, ok := a.manager.GetConnection(data.ProfileID); ok && t != eventbus.EventConnectionClosed {
+		return vpn.AgentStatus(conn)
+	}
+	st := daemon.AgentConnectionStatus{
+		ProfileID:   data.ProfileID,
+		ProfileName: data.ProfileName,
+		Status:      "disconnected",
+	}
+	if t == eventbus.EventConnectionFailed {
+		st.Status = "error"
+		if data.Error != nil {
+			st.LastError = data.Error.Error()
+		}
+	}
+	return st
+}
+
+// requestAuth publishes an agent.auth_required event for the GUI and shows a
+// desktop notification for sessions without one.
+func (a *Agent) requestAuth(ev daemon.AgentAuthRequiredEvent, message string) {
+	ev.ProfileName = a.profileName(ev.ProfileID)
+	a.logger.Printf("Credentials needed for %s (%s)", ev.ProfileName, ev.Reason)
+	a.server.Events().Publish(daemon.AgentTopicAuthRequired, ev)
+	a.notify(func() { notify.ConnectionError(ev.ProfileName, message) })
+}
+
+// notify runs show when desktop notifications are enabled.
+func (a *Agent) notify(show func()) {
+	if a.config().ShowNotifications {
+		show()
+	}
+}
+
+func (a *Agent) profileName(profileID string) string {
+	if p, err := a.manager.GetProfile(profileID); err == nil {
+		return p.Name
+	}
+	return profileID
+}
+
+// =============================================================================
+// RPC HANDLERS
+// =============================================================================
+
+func (a *Agent) registerHandlers() {
+	h := a.server.Handlers()
+	h.Register("vpn.connect", connectHandler(a.manager),
+		rpc.Params(daemon.AgentConnectParams{}), rpc.Result(daemon.AgentConnectionStatus{}),
+		rpc.Summary("Connect a profile; missing credentials come from the profile and keyring."))
+	h.Register("vpn.disconnect", disconnectHandler(a.manager),
+		rpc.Params(daemon.AgentDisconnectParams{}), rpc.Result(map[string]bool{}),
+		rpc.Summary("Disconnect a profile."))
+	h.Register("agent.status", a.handleStatus,
+		rpc.Result(daemon.AgentStatusResult{}), rpc.Summary("Report the agent and the connections it owns."))
+	h.Register("agent.reload", func(*rpc.HandlerContext) (any, error) {
+		if err := a.Reload(); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"success": true}, nil
+	}, rpc.Result(map[string]bool{}), rpc.Summary("Re-read the configuration and apply it."))
+}
+
+func (a *Agent) handleStatus(*rpc.HandlerContext) (any, error) {
+	res := statusOf(a.manager, a.manager.HealthChecker())
+	res.Version = a.version
+	res.PID = os.Getpid()
+	res.AutoReconnect = a.config().AutoReconnect
+	if tc := a.manager.TrustConfig(); tc != nil {
+		res.TrustEnabled = tc.Enabled
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	rpc "github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/health"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+)
+
+// connectionManager is the part of vpn.Manager the RPC handlers use.
+type connectionManager interface {
+	Connect(profileID, username, password string) error
+	Disconnect(profileID string) error
+	GetConnection(profileID string) (*vpn.Connection, bool)
+	ListConnections() []*vpn.Connection
+	GetProfile(id string) (*profile.Profile, error)
+}
+
+// errProfileRequired is returned when a request names no profile.
+var errProfileRequired = errors.New("profile_id is required")
+
+// connectHandler starts a connection. Without a password in the request it
+// uses the one saved in the keyring, as the GUI does. It returns as soon as
+// the connection is started; clients follow agent.connection for the outcome.
+func connectHandler(m connectionManager) rpc.HandlerFunc {
+	return func(ctx *rpc.HandlerContext) (any, error) {
+		var params daemon.AgentConnectParams
+		if err := ctx.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		if params.ProfileID == "" {
+			return nil, errProfileRequired
+		}
+
+		p, err := m.GetProfile(params.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+		username := params.Username
+		if username == "" {
+			username = p.Username
+		}
+		password := params.Password
+		if password == "" && p.SavePassword {
+			password, _ = keyringGet(p.ID)
+		}
+
+		ctx.Logger.Printf("Connect %s requested by uid=%d pid=%d", p.Name, ctx.UID, ctx.PID)
+		if err := m.Connect(p.ID, username, password); err != nil {
+			return nil, err
+		}
+		conn, ok := m.GetConnection(p.ID)
+		if !ok {
+			return nil, fmt.Errorf("connection for %s vanished while starting", p.Name)
+		}
+		return vpn.AgentStatus(conn), nil
+	}
+}
+
+// disconnectHandler stops a connection.
+func disconnectHandler(m connectionManager) rpc.HandlerFunc {
+	return func(ctx *rpc.HandlerContext) (any, error) {
+		var params daemon.AgentDisconnectParams
+		if err := ctx.UnmarshalParams(&params); err != nil {
+			return nil, err
+		}
+		if params.ProfileID == "" {
+			return nil, errProfileRequired
+		}
+
+		ctx.Logger.Printf("Disconnect %s requested by uid=%d pid=%d", params.ProfileID, ctx.UID, ctx.PID)
+		if err := m.Disconnect(params.ProfileID); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"success": true}, nil
+	}
+}
+
+// statusOf lists the connections the manager owns with their health.
+func statusOf(m connectionManager, hc *health.Checker) *daemon.AgentStatusResult {
+	res := &daemon.AgentStatusResult{Connections: []daemon.AgentConnectionStatus{}}
+	for _, conn := range m.ListConnections() {
+		st := vpn.AgentStatus(conn)
+		if hc != nil {
+			if h, ok := hc.GetHealth(st.ProfileID); ok {
+				st.Health = h.State.String()
+			}
+		}
+		res.Connections = append(res.Connections, st)
+	}
+	return res
+}
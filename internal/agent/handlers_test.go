@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	rpc "github.com/yllada/vpn-manager/daemon"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// fakeManager is a connectionManager that records Connect/Disconnect calls.
+type fakeManager struct {
+	profiles    map[string]*profile.Profile
+	conns       map[string]*vpn.Connection
+	connected   [][3]string // profileID, username, password
+	disconnects []string
+}
+
+func newFakeManager(profiles ...*profile.Profile) *fakeManager {
+	f := &fakeManager{profiles: map[string]*profile.Profile{}, conns: map[string]*vpn.Connection{}}
+	for _, p := range profiles {
+		f.profiles[p.ID] = p
+	}
+	return f
+}
+
+func (f *fakeManager) Connect(profileID, username, password string) error {
+	f.connected = append(f.connected, [3]string{profileID, username, password})
+	f.conns[profileID] = &vpn.Connection{Profile: f.profiles[profileID], Status: vpn.StatusConnecting}
+	return nil
+}
+
+func (f *fakeManager) Disconnect(profileID string) error {
+	if _, ok := f.conns[profileID]; !ok {
+		return vpn.ErrNotConnected
+	}
+	f.disconnects = append(f.disconnects, profileID)
+	delete(f.conns, profileID)
+	return nil
+}
+
+func (f *fakeManager) GetConnection(profileID string) (*vpn.Connection, bool) {
+	c, ok := f.conns[profileID]
+	return c, ok
+}
+
+func (f *fakeManager) ListConnections() []*vpn.Connection {
+	var out []*vpn.Connection
+	for _, c := range f.conns {
+		out = append(out, c)
+	}
+	return out
+}
+
+func (f *fakeManager) GetProfile(id string) (*profile.Profile, error) {
+	if p, ok := f.profiles[id]; ok {
+		return p, nil
+	}
+	return nil, profile.ErrProfileNotFound
+}
+
+func handlerCtx(t *testing.T, params any) *rpc.HandlerContext {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	return &rpc.HandlerContext{
+		Context: context.Background(),
+		Request: &protocol.Request{Params: raw},
+		Logger:  log.New(io.Discard, "", 0),
+	}
+}
+
+func stubKeyring(t *testing.T, secrets map[string]string) {
+	t.Helper()
+	orig := keyringGet
+	keyringGet = func(id string) (string, error) {
+		if s, ok := secrets[id]; ok {
+			return s, nil
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { keyringGet = orig })
+}
+
+// TestConnectHandlerFallsBackToSavedCredentials pins that a bare connect
+// request (vpnctl without --username, a trusted-network rule) uses the
+// profile's username and the password saved in the keyring, as the GUI does.
+func TestConnectHandlerFallsBackToSavedCredentials(t *testing.T) {
+	stubKeyring(t, map[string]string{"p1": "saved"})
+	m := newFakeManager(&profile.Profile{ID: "p1", Name: "Office", Username: "alice", SavePassword: true})
+
+	res, err := connectHandler(m)(handlerCtx(t, daemon.AgentConnectParams{ProfileID: "p1"}))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if len(m.connected) != 1 || m.connected[0] != [3]string{"p1", "alice", "saved"} {
+		t.Errorf("Connect calls = %v, want [[p1 alice saved]]", m.connected)
+	}
+	st, ok := res.(daemon.AgentConnectionStatus)
+	if !ok {
+		t.Fatalf("result type = %T, want daemon.AgentConnectionStatus", res)
+	}
+	if st.ProfileID != "p1" || st.ProfileName != "Office" || st.Status != "connecting" {
+		t.Errorf("result = %+v, want p1/Office/connecting", st)
+	}
+}
+
+// TestConnectHandlerExplicitCredentials checks request credentials win, and
+// the keyring is not consulted for profiles that do not save a password.
+func TestConnectHandlerExplicitCredentials(t *testing.T) {
+	stubKeyring(t, map[string]string{"p1": "saved", "p2": "saved"})
+	m := newFakeManager(
+		&profile.Profile{ID: "p1", Name: "Office", Username: "alice", SavePassword: true},
+		&profile.Profile{ID: "p2", Name: "Lab", Username: "bob"},
+	)
+
+	if _, err := connectHandler(m)(handlerCtx(t, daemon.AgentConnectParams{ProfileID: "p1", Username: "carol", Password: "typed"})); err != nil {
+		t.Fatalf("connect p1: %v", err)
+	}
+	if _, err := connectHandler(m)(handlerCtx(t, daemon.AgentConnectParams{ProfileID: "p2"})); err != nil {
+		t.Fatalf("connect p2: %v", err)
+	}
+	want := [][3]string{{"p1", "carol", "typed"}, {"p2", "bob", ""}}
+	if len(m.connected) != 2 || m.connected[0] != want[0] || m.connected[1] != want[1] {
+		t.Errorf("Connect calls = %v, want %v", m.connected, want)
+	}
+}
+
+func TestConnectHandlerRejectsBadRequests(t *testing.T) {
+	m := newFakeManager()
+
+	if _, err := connectHandler(m)(handlerCtx(t, daemon.AgentConnectParams{})); !errors.Is(err, errProfileRequired) {
+		t.Errorf("empty profile: err = %v, want errProfileRequired", err)
+	}
+	if _, err := connectHandler(m)(handlerCtx(t, daemon.AgentConnectParams{ProfileID: "missing"})); err == nil {
+		t.Error("unknown profile: expected an error")
+	}
+	if len(m.connected) != 0 {
+		t.Errorf("Connect called %d times for bad requests", len(m.connected))
+	}
+}
+
+func TestDisconnectHandler(t *testing.T) {
+	m := newFakeManager(&profile.Profile{ID: "p1", Name: "Office"})
+	m.conns["p1"] = &vpn.Connection{Profile: m.profiles["p1"], Status: vpn.StatusConnected}
+
+	if _, err := disconnectHandler(m)(handlerCtx(t, daemon.AgentDisconnectParams{})); !errors.Is(err, errProfileRequired) {
+		t.Errorf("empty profile: err = %v, want errProfileRequired", err)
+	}
+	if _, err := disconnectHandler(m)(handlerCtx(t, daemon.AgentDisconnectParams{ProfileID: "p1"})); err != nil {
+		t.Fatalf("disconnect: %v", err)
+	}
+	if len(m.disconnects) != 1 || m.disconnects[0] != "p1" {
+		t.Errorf("Disconnect calls = %v, want [p1]", m.disconnects)
+	}
+	if _, err := disconnectHandler(m)(handlerCtx(t, daemon.AgentDisconnectParams{ProfileID: "p1"})); !errors.Is(err, vpn.ErrNotConnected) {
+		t.Errorf("second disconnect: err = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	m := newFakeManager(&profile.Profile{ID: "p1", Name: "Office"})
+	if res := statusOf(m, nil); res.Connections == nil || len(res.Connections) != 0 {
+		t.Errorf("idle status = %+v, want an empty (non-nil) list", res.Connections)
+	}
+
+	m.conns["p1"] = &vpn.Connection{Profile: m.profiles["p1"], Status: vpn.StatusConnected, IPAddress: "10.8.0.2"}
+	res := statusOf(m, nil)
+	if len(res.Connections) != 1 {
+		t.Fatalf("connections = %d, want 1", len(res.Connections))
+	}
+	if got := res.Connections[0]; got.Status != "connected" || got.IPAddress != "10.8.0.2" {
+		t.Errorf("connection = %+v, want connected with 10.8.0.2", got)
+	}
+}
@@ -0,0 +1,226 @@
+// Package daemon provides the client for the per-user session agent.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// =============================================================================
+// SESSION AGENT CLIENT
+// =============================================================================
+
+// The session agent (vpn-manager-agent) is a headless per-user service that
+// owns the VPN connections, the health checker, the trust coordinator and the
+// stats collector, so protection keeps running without the GTK window. It
+// speaks the same JSON-RPC protocol as vpn-managerd on a socket in the user's
+// runtime directory, reachable only by that user.
+
+// AgentSocketName is the agent socket's file name inside AgentRuntimeDir.
+const AgentSocketName = "agent.sock"
+
+// Event topics published by the session agent.
+const (
+	// AgentTopicConnection fires on every connection state change the agent
+	// observes. The payload is an AgentConnectionStatus.
+	AgentTopicConnection = "agent.connection"
+
+	// AgentTopicAuthRequired fires when the agent needs credentials it cannot
+	// supply on its own (an OTP, or a password that is not in the keyring). The
+	// payload is an AgentAuthRequiredEvent.
+	AgentTopicAuthRequired = "agent.auth_required"
+)
+
+var (
+	agentClient *protocol.Client
+	agentMu     sync.Mutex // Protects agentClient
+)
+
+// AgentRuntimeDir returns the directory holding the agent socket:
+// $XDG_RUNTIME_DIR/vpn-manager, or /run/user/<uid>/vpn-manager when the
+// variable is unset (e.g. a bare SSH session without pam_systemd).
+func AgentRuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "vpn-manager")
+	}
+	return filepath.Join("/run/user", strconv.Itoa(os.Getuid()), "vpn-manager")
+}
+
+// AgentSocketPath returns the path of the current user's agent socket.
+func AgentSocketPath() string {
+	return filepath.Join(AgentRuntimeDir(), AgentSocketName)
+}
+
+// IsAgentAvailable reports whether the session agent is accepting connections.
+// Unlike IsDaemonAvailable it dials the socket: the agent runs as the user, so
+// a crash can leave a stale socket file behind that nothing is listening on.
+func IsAgentAvailable() bool {
+	conn, err := net.DialTimeout("unix", AgentSocketPath(), time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// connectToAgent returns the shared agent connection, dialing it if needed.
+func connectToAgent(ctx context.Context) (*protocol.Client, error) {
+	agentMu.Lock()
+	defer agentMu.Unlock()
+
+	if agentClient != nil && agentClient.IsConnected() {
+		return agentClient, nil
+	}
+	if agentClient != nil {
+		_ = agentClient.Close()
+	}
+
+	client := protocol.NewClient(protocol.WithSocketPath(AgentSocketPath()))
+	if err := client.Connect(ctx); err != nil {
+		agentClient = nil
+		return nil, fmt.Errorf("agent not available: %w", protocol.ErrDaemonUnavailable)
+	}
+	agentClient = client
+	return agentClient, nil
+}
+
+// callAgent calls an agent method on the shared connection.
+func callAgent(ctx context.Context, method string, params, result any) error {
+	client, err := connectToAgent(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Call(ctx, method, params, result)
+}
+
+// SubscribeAgentEvents subscribes to the given agent event topics (all topics
+// when none are given). The channel is closed when ctx is cancelled or the
+// agent goes away.
+func SubscribeAgentEvents(ctx context.Context, topics ...string) (<-chan protocol.Event, error) {
+	client, err := connectToAgent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Subscribe(ctx, topics...)
+}
+
+// CloseAgentConnection closes the shared agent connection.
+func CloseAgentConnection() {
+	agentMu.Lock()
+	defer agentMu.Unlock()
+
+	if agentClient != nil {
+		_ = agentClient.Close()
+		agentClient = nil
+	}
+}
+
+// AgentClient provides a client interface for the session agent.
+type AgentClient struct{}
+
+// AgentConnectParams contains parameters for vpn.connect. An empty password
+// makes the agent use the one saved in the keyring.
+type AgentConnectParams struct {
+	ProfileID string `json:"profile_id"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+// AgentDisconnectParams contains parameters for vpn.disconnect.
+type AgentDisconnectParams struct {
+	ProfileID string `json:"profile_id"`
+}
+
+// AgentConnectionStatus describes one connection owned by the agent.
+type AgentConnectionStatus struct {
+	ProfileID   string    `json:"profile_id"`
+	ProfileName string    `json:"profile_name,omitempty"`
+	Status      string    `json:"status"`
+	IPAddress   string    `json:"ip_address,omitempty"`
+	StartTime   time.Time `json:"start_time,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	Health      string    `json:"health,omitempty"`
+}
+
+// AgentStatusResult is the result of agent.status.
+type AgentStatusResult struct {
+	Version       string                  `json:"version"`
+	PID           int                     `json:"pid"`
+	AutoReconnect bool                    `json:"auto_reconnect"`
+	TrustEnabled  bool                    `json:"trust_enabled"`
+	Connections   []AgentConnectionStatus `json:"connections"`
+}
+
+// AgentAuthRequiredEvent is the payload of AgentTopicAuthRequired.
+type AgentAuthRequiredEvent struct {
+	ProfileID   string `json:"profile_id"`
+	ProfileName string `json:"profile_name,omitempty"`
+	Username    string `json:"username,omitempty"`
+	NeedsOTP    bool   `json:"needs_otp"`
+	// Reason is what asked for the connection: "autoconnect", "reconnect"
+	// or "trust"; or "challenge" for a connection waiting on the answer to
+	// Challenge, which goes to the daemon (OpenVPNClient.Respond).
+	Reason string `json:"reason"`
+	// SSID is the untrusted network that triggered a "trust" connection.
+	SSID string `json:"ssid,omitempty"`
+	// Challenge is the server's two-factor question, for "challenge".
+	Challenge *OpenVPNChallenge `json:"challenge,omitempty"`
+}
+
+// Connect asks the agent to connect a profile. It returns once the agent has
+// started the connection; follow AgentTopicConnection (or the daemon's
+// openvpn.state) for the outcome.
+func (c *AgentClient) Connect(profileID, username, password string) error {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.ConnectWithContext(ctx, AgentConnectParams{ProfileID: profileID, Username: username, Password: password})
+}
+
+// ConnectWithContext is like Connect but the caller supplies the context.
+func (c *AgentClient) ConnectWithContext(ctx context.Context, params AgentConnectParams) error {
+	var result AgentConnectionStatus
+	return callAgent(ctx, "vpn.connect", params, &result)
+}
+
+// Disconnect asks the agent to disconnect a profile.
+func (c *AgentClient) Disconnect(profileID string) error {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.DisconnectWithContext(ctx, profileID)
+}
+
+// DisconnectWithContext is like Disconnect but the caller supplies the context.
+func (c *AgentClient) DisconnectWithContext(ctx context.Context, profileID string) error {
+	var result map[string]bool
+	return callAgent(ctx, "vpn.disconnect", AgentDisconnectParams{ProfileID: profileID}, &result)
+}
+
+// Status returns the agent's status and the connections it owns.
+func (c *AgentClient) Status() (*AgentStatusResult, error) {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+
+	var result AgentStatusResult
+	if err := callAgent(ctx, "agent.status", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Reload makes the agent re-read the configuration (auto-reconnect, security
+// settings, trust rules).
+func (c *AgentClient) Reload() error {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+
+	var result map[string]bool
+	return callAgent(ctx, "agent.reload", nil, &result)
+}
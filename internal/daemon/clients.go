@@ -1,11 +1,19 @@
 // Package daemon provides client wrappers for daemon privileged operations.
 // These functions provide a clean API for vpn/* modules to delegate operations
 // to the daemon. The daemon must be running for privileged operations to work.
+//
+// The param and result types are aliases of the ones generated in
+// pkg/protocol/api from the daemon's own description, so they always match
+// what the handlers read and write.
 package daemon
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+	"github.com/yllada/vpn-manager/pkg/protocol/api"
 )
 
 // daemonCtx returns a context carrying the default daemon timeout. It is used
@@ -22,19 +30,11 @@ func daemonCtx() (context.Context, context.CancelFunc) {
 // It delegates to the daemon for privileged operations.
 type KillSwitchClient struct{}
 
-// KillSwitchEnableParams matches daemon/privileged.KillSwitchEnableParams
-type KillSwitchEnableParams struct {
-	VPNInterface string   `json:"vpn_interface"`
-	VPNServerIP  string   `json:"vpn_server_ip,omitempty"`
-	AllowLAN     bool     `json:"allow_lan"`
-	LANRanges    []string `json:"lan_ranges,omitempty"`
-}
+// KillSwitchEnableParams is generated from daemon/privileged.KillSwitchEnableParams
+type KillSwitchEnableParams = api.KillSwitchEnableParams
 
 // KillSwitchEnableResult contains the response from enabling kill switch.
-type KillSwitchEnableResult struct {
-	Enabled bool   `json:"enabled"`
-	Backend string `json:"backend,omitempty"`
-}
+type KillSwitchEnableResult = api.KillSwitchEnableResult
 
 // Enable enables the kill switch via daemon.
 func (c *KillSwitchClient) Enable(params KillSwitchEnableParams) (*KillSwitchEnableResult, error) {
@@ -95,15 +95,8 @@ func (c *KillSwitchClient) EnableBlockAll() (*KillSwitchEnableResult, error) {
 // DNSProtectionClient provides a client interface for DNS protection operations.
 type DNSProtectionClient struct{}
 
-// DNSEnableParams matches daemon/privileged.DNSEnableParams
-type DNSEnableParams struct {
-	VPNInterface string   `json:"vpn_interface"`
-	Servers      []string `json:"servers"`
-	Mode         string   `json:"mode,omitempty"` // "off"/"auto"/"strict"/"custom"
-	BlockDoT     bool     `json:"block_dot"`
-	BlockDoH     bool     `json:"block_doh"`
-	LeakBlocking bool     `json:"leak_blocking"`
-}
+// DNSEnableParams is generated from daemon/privileged.DNSEnableParams
+type DNSEnableParams = api.DNSEnableParams
 
 // Enable enables DNS protection via daemon.
 func (c *DNSProtectionClient) Enable(params DNSEnableParams) error {
@@ -140,11 +133,8 @@ func (c *DNSProtectionClient) Status() (map[string]any, error) {
 // IPv6ProtectionClient provides a client interface for IPv6 protection operations.
 type IPv6ProtectionClient struct{}
 
-// IPv6EnableParams matches daemon/privileged.IPv6EnableParams
-type IPv6EnableParams struct {
-	Mode        string `json:"mode"` // "block", "allow", "auto"
-	BlockWebRTC bool   `json:"block_webrtc"`
-}
+// IPv6EnableParams is generated from daemon/privileged.IPv6EnableParams
+type IPv6EnableParams = api.IPv6EnableParams
 
 // Enable enables IPv6 protection via daemon.
 func (c *IPv6ProtectionClient) Enable(params IPv6EnableParams) error {
@@ -181,20 +171,11 @@ func (c *IPv6ProtectionClient) Status() (map[string]any, error) {
 // LANGatewayClient provides a client interface for LAN gateway operations.
 type LANGatewayClient struct{}
 
-// GatewayEnableParams matches daemon/privileged.GatewayEnableParams
-type GatewayEnableParams struct {
-	WiFiInterface string `json:"wifi_interface,omitempty"` // Auto-detect if empty
-	TailscaleIP   string `json:"tailscale_ip,omitempty"`
-	LANNetwork    string `json:"lan_network,omitempty"` // Auto-detect if empty
-}
+// GatewayEnableParams is generated from daemon/privileged.GatewayEnableParams
+type GatewayEnableParams = api.GatewayEnableParams
 
 // GatewayEnableResult contains the response from enabling LAN gateway.
-type GatewayEnableResult struct {
-	Enabled       bool   `json:"enabled"`
-	WiFiInterface string `json:"wifi_interface"`
-	LANNetwork    string `json:"lan_network"`
-	AlreadyActive bool   `json:"already_active,omitempty"`
-}
+type GatewayEnableResult = api.GatewayEnableResult
 
 // Enable enables LAN gateway via daemon.
 func (c *LANGatewayClient) Enable(params GatewayEnableParams) (*GatewayEnableResult, error) {
@@ -251,23 +232,11 @@ func (c *LANGatewayClient) Status() (map[string]any, error) {
 // SplitTunnelClient provides a client interface for split tunnel operations.
 type SplitTunnelClient struct{}
 
-// TunnelSetupParams matches daemon/privileged.TunnelSetupParams
-type TunnelSetupParams struct {
-	Mode            string   `json:"mode"` // "include", "exclude"
-	Apps            []string `json:"apps"`
-	VPNInterface    string   `json:"vpn_interface"`
-	VPNGateway      string   `json:"vpn_gateway"`
-	SplitDNSEnabled bool     `json:"split_dns_enabled"`
-	VPNDNS          []string `json:"vpn_dns,omitempty"`
-	SystemDNS       string   `json:"system_dns,omitempty"`
-}
+// TunnelSetupParams is generated from daemon/privileged.TunnelSetupParams
+type TunnelSetupParams = api.TunnelSetupParams
 
 // TunnelSetupResult contains the result of tunnel setup.
-type TunnelSetupResult struct {
-	Enabled    bool   `json:"enabled"`
-	CgroupPath string `json:"cgroup_path"`
-	Mode       string `json:"mode"`
-}
+type TunnelSetupResult = api.TunnelSetupResult
 
 // Setup configures split tunneling via daemon.
 func (c *SplitTunnelClient) Setup(params TunnelSetupParams) (*TunnelSetupResult, error) {
@@ -309,6 +278,117 @@ func (c *SplitTunnelClient) Status() (map[string]any, error) {
 	return result, nil
 }
 
+// =============================================================================
+// SECURITY POSTURE CLIENT
+// =============================================================================
+
+// SecurityClient applies a complete security posture in one call. The daemon
+// keeps enforcing it, re-applying features that drift, until an imperative
+// call (KillSwitchClient, DNSProtectionClient, ...) takes over.
+type SecurityClient struct{}
+
+// SecurityPosture is generated from daemon/privileged.SecurityPosture. A nil section
+// turns the feature off.
+type SecurityPosture = api.SecurityPosture
+
+// SecurityApplyResult is generated from daemon/privileged.SecurityApplyResult.
+type SecurityApplyResult = api.SecurityApplyResult
+
+// PostureDrift is generated from daemon/privileged.PostureDrift.
+type PostureDrift = api.PostureDrift
+
+// SecurityStatusResult is generated from daemon/privileged.SecurityStatusResult.
+type SecurityStatusResult = api.SecurityStatusResult
+
+// SecurityDriftEvent is the payload of protocol.TopicSecurityDrift events.
+type SecurityDriftEvent struct {
+	Drift  []PostureDrift    `json:"drift"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Apply sets the desired security posture via daemon.
+func (c *SecurityClient) Apply(posture SecurityPosture) (*SecurityApplyResult, error) {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.ApplyWithContext(ctx, posture)
+}
+
+// ApplyWithContext sets the desired security posture with context support.
+func (c *SecurityClient) ApplyWithContext(ctx context.Context, posture SecurityPosture) (*SecurityApplyResult, error) {
+	var result SecurityApplyResult
+
+	err := CallDaemonWithContext(ctx, "security.apply", posture, &result, func() error {
+		return fmt.Errorf("daemon unavailable, security posture requires daemon for proper operation")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Status returns the recorded posture and the result of the last drift check.
+func (c *SecurityClient) Status() (*SecurityStatusResult, error) {
+	var result SecurityStatusResult
+
+	err := CallDaemon("security.status", nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// =============================================================================
+// TRANSACTION CLIENT
+// =============================================================================
+
+// TransactionClient applies several privileged steps as one unit: if any step
+// fails, the daemon restores every feature the transaction touched.
+type TransactionClient struct{}
+
+// TxStep is generated from daemon/privileged.TxStep. Allowed methods are the
+// killswitch.*, dns.*, ipv6.* and tunnel.* enable/disable calls.
+type TxStep = api.TxStep
+
+// NewTxStep builds a transaction step from a method and its params (one of the
+// *Params types above, or nil).
+func NewTxStep(method string, params any) (TxStep, error) {
+	step := TxStep{Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return TxStep{}, fmt.Errorf("marshal %s params: %w", method, err)
+		}
+		step.Params = raw
+	}
+	return step, nil
+}
+
+// TxCommitResult is generated from daemon/privileged.TxCommitResult.
+type TxCommitResult = api.TxCommitResult
+
+// Commit applies all steps or none of them via daemon.
+func (c *TransactionClient) Commit(steps ...TxStep) (*TxCommitResult, error) {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.CommitWithContext(ctx, steps...)
+}
+
+// CommitWithContext applies all steps or none of them with context support.
+func (c *TransactionClient) CommitWithContext(ctx context.Context, steps ...TxStep) (*TxCommitResult, error) {
+	var result TxCommitResult
+
+	err := CallDaemonWithContext(ctx, "tx.commit", api.TxCommitParams{Steps: steps}, &result, func() error {
+		return fmt.Errorf("daemon unavailable, transactions require daemon for proper operation")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 // =============================================================================
 // OPENVPN CLIENT
 // =============================================================================
@@ -317,32 +397,21 @@ func (c *SplitTunnelClient) Status() (map[string]any, error) {
 type OpenVPNClient struct{}
 
 // OpenVPNConnectParams contains parameters for connecting to OpenVPN.
-type OpenVPNConnectParams struct {
-	ProfileID         string   `json:"profile_id"`
-	ConfigPath        string   `json:"config_path"`
-	Username          string   `json:"username"`
-	Password          string   `json:"password"`
-	SplitTunnelEnable bool     `json:"split_tunnel_enabled"`
-	SplitTunnelMode   string   `json:"split_tunnel_mode"`
-	SplitTunnelRoutes []string `json:"split_tunnel_routes"`
-}
+type OpenVPNConnectParams = api.OpenVPNConnectParams
+
+// OpenVPNRemote is the server an OpenVPN connection tries first (see
+// OpenVPNConnectParams.Remote).
+type OpenVPNRemote = api.OpenVPNRemote
 
 // OpenVPNConnectResult contains the result of an OpenVPN connect operation.
-type OpenVPNConnectResult struct {
-	Success   bool   `json:"success"`
-	ProfileID string `json:"profile_id"`
-	PID       int    `json:"pid"`
-}
+type OpenVPNConnectResult = api.OpenVPNConnectResult
 
 // OpenVPNStatusResult contains the status of an OpenVPN connection.
-type OpenVPNStatusResult struct {
-	ProfileID   string   `json:"profile_id"`
-	Status      string   `json:"status"`
-	IPAddress   string   `json:"ip_address,omitempty"`
-	StartTime   string   `json:"start_time,omitempty"`
-	LastError   string   `json:"last_error,omitempty"`
-	OutputLines []string `json:"output_lines,omitempty"`
-}
+type OpenVPNStatusResult = api.OpenVPNStatusResult
+
+// OpenVPNChallenge is a two-factor question the server asked on top of the
+// password; see OpenVPNClient.Respond.
+type OpenVPNChallenge = api.OpenVPNChallenge
 
 // Connect starts an OpenVPN connection via daemon.
 func (c *OpenVPNClient) Connect(params OpenVPNConnectParams) (*OpenVPNConnectResult, error) {
@@ -403,6 +472,58 @@ func (c *OpenVPNClient) List() ([]OpenVPNStatusResult, error) {
 	return result, nil
 }
 
+// Respond answers the challenge a connection's status shows: a one-time code,
+// a PIN, or "" for a challenge that needs no response. The daemon encodes it
+// as the server expects.
+func (c *OpenVPNClient) Respond(profileID, response string) error {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	var result OpenVPNStatusResult
+	params := api.OpenVPNRespondParams{ProfileID: profileID, Response: response}
+	return CallDaemonWithContext(ctx, "openvpn.respond", params, &result, nil)
+}
+
+// OpenVPNLogsParams selects lines of a profile's OpenVPN log.
+type OpenVPNLogsParams = api.OpenVPNLogsParams
+
+// OpenVPNLogLine is one line of a profile's OpenVPN log.
+type OpenVPNLogLine = api.OpenVPNLogLine
+
+// LogsWithContext returns the buffered lines of a profile's OpenVPN log that
+// pass the filters in params, oldest first. params.Follow is ignored.
+func (c *OpenVPNClient) LogsWithContext(ctx context.Context, params OpenVPNLogsParams) ([]OpenVPNLogLine, error) {
+	var result api.OpenVPNLogsResult
+
+	params.Follow = false
+	if err := CallDaemonWithContext(ctx, "openvpn.logs", params, &result, nil); err != nil {
+		return nil, err
+	}
+
+	return result.Lines, nil
+}
+
+// FollowLogs passes a profile's OpenVPN log to handle, line by line: the
+// buffered lines that pass the filters in params, then every new one, until
+// ctx is cancelled (FollowLogs then returns nil) or the daemon goes away. A
+// follow holds its connection while it runs, so it dials one of its own
+// instead of the shared client's. handle runs on a goroutine of its own.
+func (c *OpenVPNClient) FollowLogs(ctx context.Context, params OpenVPNLogsParams, handle func(OpenVPNLogLine)) error {
+	path := currentSocketPath()
+	if !protocol.IsDaemonAvailableAt(path) {
+		return fmt.Errorf("daemon not available: %w", protocol.ErrDaemonUnavailable)
+	}
+	client := protocol.NewClient(protocol.WithSocketPath(path))
+	defer func() { _ = client.Close() }()
+
+	params.Follow = true
+	return client.Stream(ctx, "openvpn.logs", params, func(ev protocol.Event) {
+		var line OpenVPNLogLine
+		if err := ev.UnmarshalData(&line); err == nil {
+			handle(line)
+		}
+	}, protocol.TopicOpenVPNLog)
+}
+
 // =============================================================================
 // WIREGUARD CLIENT
 // =============================================================================
@@ -411,26 +532,13 @@ func (c *OpenVPNClient) List() ([]OpenVPNStatusResult, error) {
 type WireGuardClient struct{}
 
 // WireGuardConnectParams contains parameters for bringing up a WireGuard interface.
-type WireGuardConnectParams struct {
-	InterfaceName string `json:"interface_name"`
-	ConfigPath    string `json:"config_path"`
-}
+type WireGuardConnectParams = api.WireGuardConnectParams
 
 // WireGuardConnectResult contains the result of a WireGuard connect operation.
-type WireGuardConnectResult struct {
-	Success       bool   `json:"success"`
-	InterfaceName string `json:"interface_name"`
-	IPAddress     string `json:"ip_address,omitempty"`
-}
+type WireGuardConnectResult = api.WireGuardConnectResult
 
 // WireGuardStatusResult contains the status of a WireGuard interface.
-type WireGuardStatusResult struct {
-	InterfaceName string `json:"interface_name"`
-	Status        string `json:"status"`
-	IPAddress     string `json:"ip_address,omitempty"`
-	StartTime     string `json:"start_time,omitempty"`
-	LastError     string `json:"last_error,omitempty"`
-}
+type WireGuardStatusResult = api.WireGuardStatusResult
 
 // Connect brings up a WireGuard interface via daemon.
 func (c *WireGuardClient) Connect(params WireGuardConnectParams) (*WireGuardConnectResult, error) {
@@ -499,69 +607,22 @@ func (c *WireGuardClient) List() ([]WireGuardStatusResult, error) {
 type TailscaleClient struct{}
 
 // TailscaleUpParams contains parameters for tailscale up.
-type TailscaleUpParams struct {
-	// Connection options
-	ExitNode               string `json:"exit_node,omitempty"`
-	ExitNodeAllowLANAccess bool   `json:"exit_node_allow_lan_access"`
-	AcceptRoutes           bool   `json:"accept_routes"`
-	AcceptDNS              bool   `json:"accept_dns"`
-	ShieldsUp              bool   `json:"shields_up"`
-
-	// Authentication
-	AuthKey     string `json:"auth_key,omitempty"`
-	LoginServer string `json:"login_server,omitempty"` // For Headscale
-
-	// Identity
-	Hostname      string   `json:"hostname,omitempty"`
-	AdvertiseTags []string `json:"advertise_tags,omitempty"`
-
-	// Features
-	AdvertiseExitNode bool `json:"advertise_exit_node"`
-	SSH               bool `json:"ssh"`
-	StatefulFiltering bool `json:"stateful_filtering"`
-
-	// Operator
-	Operator string `json:"operator,omitempty"`
-}
+type TailscaleUpParams = api.UpParams
 
 // TailscaleUpResult contains the result of tailscale up.
-type TailscaleUpResult struct {
-	Success bool   `json:"success"`
-	Output  string `json:"output,omitempty"`
-}
+type TailscaleUpResult = api.UpResult
 
 // TailscaleSetParams contains parameters for tailscale set.
-type TailscaleSetParams struct {
-	ShieldsUp              *bool   `json:"shields_up,omitempty"`
-	AcceptRoutes           *bool   `json:"accept_routes,omitempty"`
-	AcceptDNS              *bool   `json:"accept_dns,omitempty"`
-	ExitNode               *string `json:"exit_node,omitempty"`
-	ExitNodeAllowLANAccess *bool   `json:"exit_node_allow_lan_access,omitempty"`
-	AdvertiseExitNode      *bool   `json:"advertise_exit_node,omitempty"`
-	Hostname               *string `json:"hostname,omitempty"`
-	StatefulFiltering      *bool   `json:"stateful_filtering,omitempty"`
-	AutoUpdate             *bool   `json:"auto_update,omitempty"`
-	Operator               *string `json:"operator,omitempty"`
-}
+type TailscaleSetParams = api.SetParams
 
 // TailscaleSetResult contains the result of tailscale set.
-type TailscaleSetResult struct {
-	Success bool   `json:"success"`
-	Output  string `json:"output,omitempty"`
-}
+type TailscaleSetResult = api.SetResult
 
 // TailscaleLoginParams contains parameters for tailscale login.
-type TailscaleLoginParams struct {
-	AuthKey     string `json:"auth_key,omitempty"`
-	LoginServer string `json:"login_server,omitempty"` // For Headscale
-}
+type TailscaleLoginParams = api.LoginParams
 
 // TailscaleLoginResult contains the result of tailscale login.
-type TailscaleLoginResult struct {
-	Success bool   `json:"success"`
-	AuthURL string `json:"auth_url,omitempty"` // URL to open for browser auth
-	Output  string `json:"output,omitempty"`
-}
+type TailscaleLoginResult = api.LoginResult
 
 // Up runs tailscale up via daemon.
 func (c *TailscaleClient) Up(params TailscaleUpParams) (*TailscaleUpResult, error) {
@@ -688,3 +749,67 @@ func (c *TaildropClient) SendWithContext(ctx context.Context, filePath, target s
 
 	return CallDaemonWithContext(ctx, "taildrop.send", params, &result, nil)
 }
+
+// =============================================================================
+// AUDIT CLIENT
+// =============================================================================
+
+// AuditClient reads the daemon's audit trail of privileged calls.
+type AuditClient struct{}
+
+// AuditQueryParams is generated from daemon.AuditQueryParams.
+type AuditQueryParams = api.AuditQueryParams
+
+// AuditQueryResult is generated from daemon.AuditQueryResult.
+type AuditQueryResult = api.AuditQueryResult
+
+// AuditRecord is generated from daemon.AuditRecord.
+type AuditRecord = api.AuditRecord
+
+// Query returns the audit records matching params, newest first.
+func (c *AuditClient) Query(params AuditQueryParams) (*AuditQueryResult, error) {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.QueryWithContext(ctx, params)
+}
+
+// QueryWithContext returns the matching audit records with context support.
+func (c *AuditClient) QueryWithContext(ctx context.Context, params AuditQueryParams) (*AuditQueryResult, error) {
+	var result AuditQueryResult
+
+	err := CallDaemonWithContext(ctx, "audit.query", params, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// =============================================================================
+// STATE CLIENT
+// =============================================================================
+
+// StateClient reads the daemon's state snapshot.
+type StateClient struct{}
+
+// StateSnapshot is generated from daemon.StateSnapshot.
+type StateSnapshot = api.StateSnapshot
+
+// Get returns a snapshot of the daemon state.
+func (c *StateClient) Get() (*StateSnapshot, error) {
+	ctx, cancel := daemonCtx()
+	defer cancel()
+	return c.GetWithContext(ctx)
+}
+
+// GetWithContext returns a snapshot of the daemon state with context support.
+func (c *StateClient) GetWithContext(ctx context.Context) (*StateSnapshot, error) {
+	var result StateSnapshot
+
+	err := CallDaemonWithContext(ctx, "state.get", nil, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -17,9 +17,27 @@ import (
 
 var (
 	daemonClient *protocol.Client
-	daemonMu     sync.Mutex // Protects daemonClient for all access
+	daemonSocket = protocol.DefaultSocketPath
+	daemonMu     sync.Mutex // Protects daemonClient and daemonSocket for all access
 )
 
+// SetSocketPath points the shared client at the daemon listening on path and
+// drops the current connection. Tests use it to talk to a daemontest server;
+// the application always uses protocol.DefaultSocketPath.
+func SetSocketPath(path string) {
+	CloseDaemonConnection()
+	daemonMu.Lock()
+	daemonSocket = path
+	daemonMu.Unlock()
+}
+
+// currentSocketPath returns the socket the shared client connects to.
+func currentSocketPath() string {
+	daemonMu.Lock()
+	defer daemonMu.Unlock()
+	return daemonSocket
+}
+
 // DaemonClient returns the shared daemon client instance.
 // Returns nil if the daemon is not available.
 // Creates a new client if needed — retries after CloseDaemonConnection or
@@ -34,10 +52,11 @@ func DaemonClient() *protocol.Client {
 	daemonMu.Unlock()
 
 	// I/O outside the lock: do not block other goroutines during socket probe.
-	if !protocol.IsDaemonAvailable() {
+	path := currentSocketPath()
+	if !protocol.IsDaemonAvailableAt(path) {
 		return nil
 	}
-	client := protocol.NewClient()
+	client := protocol.NewClient(protocol.WithSocketPath(path))
 
 	// Second check: store only if nobody else raced us.
 	// Close the losing client to avoid leaking socket resources.
@@ -54,7 +73,7 @@ func DaemonClient() *protocol.Client {
 // IsDaemonAvailable returns true if the daemon is available.
 // This is a quick check that doesn't establish a connection.
 func IsDaemonAvailable() bool {
-	return protocol.IsDaemonAvailable()
+	return protocol.IsDaemonAvailableAt(currentSocketPath())
 }
 
 // ConnectToDaemon connects to the daemon if available.
@@ -62,7 +81,7 @@ func IsDaemonAvailable() bool {
 func ConnectToDaemon(ctx context.Context) (*protocol.Client, error) {
 	client := DaemonClient()
 	if client == nil {
-		return nil, fmt.Errorf("daemon not available")
+		return nil, fmt.Errorf("daemon not available: %w", protocol.ErrDaemonUnavailable)
 	}
 
 	if !client.IsConnected() {
@@ -86,6 +105,33 @@ func CloseDaemonConnection() {
 	}
 }
 
+// Hello returns the shared connection's system.hello result: the daemon's
+// protocol version and host capabilities. It is cached per connection (see
+// protocol.Client.Hello), so UI code can call it whenever it builds a view.
+func Hello(ctx context.Context) (*protocol.HelloResult, error) {
+	client, err := ConnectToDaemon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.Hello(ctx)
+}
+
+// HasCapability reports whether the daemon announced the capability (one of
+// the protocol.Cap* flags), performing the hello handshake on first use. It
+// reports false when the daemon is unreachable or predates system.hello, so
+// callers can use it directly to disable a feature.
+func HasCapability(capability string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDaemonTimeout)
+	defer cancel()
+
+	hello, err := Hello(ctx)
+	if err != nil {
+		log.Printf("[daemon] Capability check for %s failed: %v", capability, err)
+		return false
+	}
+	return hello.Has(capability)
+}
+
 // =============================================================================
 // PRIVILEGED OPERATION HELPERS
 // =============================================================================
@@ -125,3 +171,16 @@ func CallDaemonWithContext(ctx context.Context, method string, params, result an
 
 	return nil
 }
+
+// SubscribeEvents subscribes the shared daemon connection to the given event
+// topics (all topics when none are given). The returned channel is closed when
+// ctx is cancelled or the daemon connection drops; callers should then fall
+// back to polling or subscribe again.
+func SubscribeEvents(ctx context.Context, topics ...string) (<-chan protocol.Event, error) {
+	client, err := ConnectToDaemon(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Subscribe(ctx, topics...)
+}
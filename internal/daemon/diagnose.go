@@ -14,8 +14,6 @@ import (
 	"strconv"
 	"syscall"
 	"time"
-
-	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // socketGroupName is the group that grants access to the daemon socket
@@ -124,7 +122,7 @@ func defaultProbes() diagnoseProbes {
 // It performs a real connection attempt, so a ReasonReachable result means the
 // daemon accepted a connection just now.
 func DiagnoseDaemon() Diagnosis {
-	return diagnose(protocol.DefaultSocketPath, defaultProbes())
+	return diagnose(currentSocketPath(), defaultProbes())
 }
 
 // diagnose implements the classification. Checks are ordered from the most
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon/daemontest"
+	"github.com/yllada/vpn-manager/daemon/privileged/firewall"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// useDaemon starts an in-process daemon and points the shared client at it.
+func useDaemon(t *testing.T) *daemontest.Daemon {
+	t.Helper()
+	d := daemontest.Start(t)
+	SetSocketPath(d.SocketPath)
+	t.Cleanup(func() { SetSocketPath(protocol.DefaultSocketPath) })
+	return d
+}
+
+// TestClientsAgainstDaemon runs the GUI-side clients against a real server:
+// an OpenVPN connection followed through its events, the kill switch, and the
+// daemon state reflecting both.
+func TestClientsAgainstDaemon(t *testing.T) {
+	d := useDaemon(t)
+	d.Exec.Script(daemontest.Response{Stdout: "policy drop;\n"},
+		"nft", "list", "chain", "inet", firewall.NftablesTableName, "output")
+
+	if !IsDaemonAvailable() {
+		t.Fatal("daemon not available on the test socket")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hello, err := Hello(ctx)
+	if err != nil || hello.Version != "daemontest" {
+		t.Fatalf("Hello = %+v, %v", hello, err)
+	}
+
+	events, err := SubscribeEvents(ctx, protocol.TopicOpenVPNState)
+	if err != nil {
+		t.Fatalf("SubscribeEvents: %v", err)
+	}
+
+	config := filepath.Join(t.TempDir(), "home.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote vpn.example.com 1194\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	openvpn := &OpenVPNClient{}
+	if _, err := openvpn.ConnectWithContext(ctx, OpenVPNConnectParams{ProfileID: "home", ConfigPath: config}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	for connected := false; !connected; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			var status OpenVPNStatusResult
+			if err := json.Unmarshal(ev.Data, &status); err != nil {
+				t.Fatalf("event data: %v", err)
+			}
+			connected = status.Status == "connected"
+		case <-ctx.Done():
+			t.Fatal("no connected event")
+		}
+	}
+
+	status, err := openvpn.Status("home")
+	if err != nil || status.IPAddress != daemontest.DefaultOpenVPNAddress {
+		t.Fatalf("Status = %+v, %v", status, err)
+	}
+
+	// The log so far, then the same lines followed.
+	lines, err := openvpn.LogsWithContext(ctx, OpenVPNLogsParams{ProfileID: "home"})
+	if err != nil || len(lines) == 0 || lines[len(lines)-1].Text != "Initialization Sequence Completed" {
+		t.Fatalf("Logs = %+v, %v", lines, err)
+	}
+	followCtx, stopFollowing := context.WithCancel(ctx)
+	var followed []OpenVPNLogLine
+	err = openvpn.FollowLogs(followCtx, OpenVPNLogsParams{ProfileID: "home"}, func(line OpenVPNLogLine) {
+		followed = append(followed, line)
+		if len(followed) == len(lines) {
+			stopFollowing()
+		}
+	})
+	if err != nil || len(followed) != len(lines) || followed[0].Seq != lines[0].Seq {
+		t.Errorf("FollowLogs = %d lines, %v; want the %d buffered ones", len(followed), err, len(lines))
+	}
+
+	if _, err := (&KillSwitchClient{}).Enable(KillSwitchEnableParams{VPNInterface: "tun0"}); err != nil {
+		t.Fatalf("kill switch: %v", err)
+	}
+	state, err := (&StateClient{}).GetWithContext(ctx)
+	if err != nil {
+		t.Fatalf("state.get: %v", err)
+	}
+	if !state.KillSwitch.Enabled || state.KillSwitch.VPNIface != "tun0" {
+		t.Errorf("state = %+v", state)
+	}
+
+	if err := openvpn.DisconnectWithContext(ctx, "home"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	if list, err := openvpn.List(); err != nil || len(list) != 0 {
+		t.Errorf("List after disconnect = %+v, %v", list, err)
+	}
+	if n := len(d.Exec.Calls("openvpn")); n != 1 {
+		t.Errorf("openvpn started %d times, want 1", n)
+	}
+}
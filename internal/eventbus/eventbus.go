@@ -39,6 +39,8 @@ const (
 	EventProfileDeleted EventType = "profile.deleted"
 	EventProfileUsed    EventType = "profile.used"
 
+	EventCertificateExpiring EventType = "profile.certificate.expiring"
+
 	// Provider events
 	EventProviderAvailable   EventType = "provider.available"
 	EventProviderUnavailable EventType = "provider.unavailable"
@@ -233,6 +235,42 @@ type TrustAuthRequiredData struct {
 	NeedsOTP bool
 }
 
+// AuthChallengeData contains data for auth OTP required events.
+// Emitted when a connecting OpenVPN server asks a two-factor question (a
+// static-challenge prompt or a CRV1 challenge) that the user must answer.
+type AuthChallengeData struct {
+	// ProfileID is the VPN profile being connected.
+	ProfileID string
+	// ProfileName is the human-readable profile name.
+	ProfileName string
+	// Text is the question, as the server worded it.
+	Text string
+	// Echo indicates the response may be shown as it is typed.
+	Echo bool
+	// ResponseRequired is false when the response may be left empty.
+	ResponseRequired bool
+}
+
+// CertificateExpiryData contains data for certificate expiring events.
+// Emitted when a certificate of a profile's config crosses one of the
+// warning thresholds before its expiry, or has expired.
+type CertificateExpiryData struct {
+	// ProfileID is the profile whose config holds the certificate.
+	ProfileID string
+	// ProfileName is the human-readable profile name.
+	ProfileName string
+	// Certificate names the certificate, e.g. "client certificate CN=alice".
+	Certificate string
+	// Fingerprint is its SHA-256 fingerprint.
+	Fingerprint string
+	// NotAfter is when it expires.
+	NotAfter time.Time
+	// DaysLeft is the number of whole days until then; negative once expired.
+	DaysLeft int
+	// Message is a sentence for a notification.
+	Message string
+}
+
 // ═══════════════════════════════════════════════════════════════════════════
 // EVENT HANDLER
 // ═══════════════════════════════════════════════════════════════════════════
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/atomicfile"
+	"github.com/yllada/vpn-manager/internal/vpn"
+)
+
+// DefaultTextfileInterval is how often the textfile is rewritten.
+const DefaultTextfileInterval = 15 * time.Second
+
+// Exporter serves and writes metrics. Create it with New; Listen and
+// StartTextfile turn on the outputs, Stop turns them off.
+type Exporter struct {
+	gather func(ctx context.Context) Snapshot
+	logger *log.Logger
+
+	mu       sync.Mutex
+	server   *http.Server
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	listener net.Listener
+}
+
+// New creates an exporter reporting on manager.
+func New(manager *vpn.Manager, logger *log.Logger) *Exporter {
+	return &Exporter{
+		gather: func(ctx context.Context) Snapshot { return Gather(ctx, manager) },
+		logger: logger,
+	}
+}
+
+// ServeHTTP answers a scrape. OpenMetrics is served to clients that ask for
+// it, the Prometheus text format to everyone else.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+
+	var buf bytes.Buffer
+	if err := collect(e.gather(r.Context())).encode(&buf, openMetrics); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if openMetrics {
+		w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", ContentTypeText)
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Listen serves /metrics on addr, which must be a loopback address: VPN state
+// says where a user is and what they connect to, and is not for the network.
+func (e *Exporter) Listen(addr string) error {
+	if err := checkLoopback(addr); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	e.mu.Lock()
+	e.server = server
+	e.listener = ln
+	e.mu.Unlock()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.logger.Printf("WARN: metrics listener stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the listener is bound to, or "" when not listening.
+func (e *Exporter) Addr() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.listener == nil {
+		return ""
+	}
+	return e.listener.Addr().String()
+}
+
+// StartTextfile writes the metrics to path now and then every interval, for
+// node_exporter's textfile collector. The file is replaced atomically so the
+// collector never reads half of it.
+func (e *Exporter) StartTextfile(path string, interval time.Duration) error {
+	if !strings.HasSuffix(path, ".prom") {
+		return fmt.Errorf("textfile %s: the textfile collector only reads *.prom files", path)
+	}
+	if interval <= 0 {
+		interval = DefaultTextfileInterval
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("textfile directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mu.Lock()
+	e.cancel = cancel
+	e.mu.Unlock()
+
+	if err := e.writeTextfile(ctx, path); err != nil {
+		e.logger.Printf("WARN: metrics textfile: %v", err)
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := e.writeTextfile(ctx, path); err != nil {
+					e.logger.Printf("WARN: metrics textfile: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (e *Exporter) writeTextfile(ctx context.Context, path string) error {
+	var buf bytes.Buffer
+	if err := collect(e.gather(ctx)).encode(&buf, false); err != nil {
+		return err
+	}
+	return atomicfile.Write(path, buf.Bytes(), 0o644)
+}
+
+// Stop shuts the listener and the textfile writer down.
+func (e *Exporter) Stop() error {
+	e.mu.Lock()
+	server, cancel := e.server, e.cancel
+	e.server, e.cancel, e.listener = nil, nil, nil
+	e.mu.Unlock()
+
+	var err error
+	if cancel != nil {
+		cancel()
+	}
+	if server != nil {
+		ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+		err = server.Shutdown(ctx)
+	}
+	e.wg.Wait()
+	return err
+}
+
+// checkLoopback rejects listen addresses that are not on loopback. A bare
+// ":port" would listen on every interface, so the host must be spelled out.
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("metrics address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("metrics address %q: only loopback addresses are allowed", addr)
+}
@@ -0,0 +1,174 @@
+// Package metrics exports VPN and daemon state in the Prometheus text and
+// OpenMetrics formats, so workstations can be watched from the same
+// dashboards as everything else.
+//
+// The session agent runs the exporter (it owns the connections, the stats
+// collector and the health checker) and adds what vpn-managerd reports over
+// state.get. Two outputs are offered, both optional: an HTTP listener, bound
+// to loopback only, and a textfile writer for node_exporter's textfile
+// collector.
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/eventbus"
+	"github.com/yllada/vpn-manager/internal/vpn"
+	"github.com/yllada/vpn-manager/internal/vpn/health"
+	vpntypes "github.com/yllada/vpn-manager/internal/vpn/types"
+)
+
+// namespace prefixes every metric name.
+const namespace = "vpn_manager_"
+
+// stateTimeout bounds the state.get call of one scrape.
+const stateTimeout = 2 * time.Second
+
+// Snapshot is everything one scrape reports.
+type Snapshot struct {
+	// Daemon is vpn-managerd's state, or nil when it could not be reached.
+	Daemon *daemon.StateSnapshot
+
+	// Connections are the active connections of every provider.
+	Connections []Connection
+
+	// Event bus counters (eventbus.EventBus.Stats).
+	EventsPublished uint64
+	EventsDelivered uint64
+	EventsDropped   uint64
+}
+
+// Connection is one active connection with its traffic and health.
+type Connection struct {
+	ProfileID   string
+	ProfileName string
+	Provider    string // "openvpn", "wireguard" or "tailscale"
+	Status      vpntypes.ConnectionStatus
+	StartTime   time.Time
+
+	// Traffic of the current stats session. HasTraffic is false when the
+	// stats collector is not tracking this connection.
+	HasTraffic bool
+	BytesIn    uint64
+	BytesOut   uint64
+
+	// Health as seen by the health checker; nil when it is not monitored.
+	Health *health.ConnectionHealth
+}
+
+// Gather collects a Snapshot from the manager, the event bus and, over
+// state.get, vpn-managerd.
+func Gather(ctx context.Context, manager *vpn.Manager) Snapshot {
+	var snap Snapshot
+
+	stateCtx, cancel := context.WithTimeout(ctx, stateTimeout)
+	defer cancel()
+	client := &daemon.StateClient{}
+	if state, err := client.GetWithContext(stateCtx); err == nil {
+		snap.Daemon = state
+	}
+
+	session := manager.GetCurrentStats()
+	hc := manager.HealthChecker()
+	for _, ac := range manager.ActiveConnections() {
+		conn := Connection{
+			ProfileID:   ac.ID,
+			ProfileName: ac.Name,
+			Provider:    ac.Protocol,
+			Status:      ac.Status,
+			StartTime:   ac.StartTime,
+		}
+		if session != nil && session.ProfileID == ac.ID {
+			conn.HasTraffic = true
+			conn.BytesIn = session.TotalBytesIn
+			conn.BytesOut = session.TotalBytesOut
+		}
+		if hc != nil {
+			if h, ok := hc.GetHealth(ac.ID); ok {
+				conn.Health = h
+			}
+		}
+		snap.Connections = append(snap.Connections, conn)
+	}
+
+	snap.EventsPublished, snap.EventsDelivered, snap.EventsDropped = eventbus.GetEventBus().Stats()
+	return snap
+}
+
+// collect turns a snapshot into metric families.
+func collect(snap Snapshot) *registry {
+	r := newRegistry()
+
+	if d := snap.Daemon; d != nil {
+		r.gauge(namespace+"daemon_up", "Whether vpn-managerd answered state.get.", nil, 1)
+		r.gauge(namespace+"daemon_uptime_seconds", "Seconds since vpn-managerd started.", nil, float64(d.UptimeSeconds))
+		r.gauge(namespace+"killswitch_enabled", "Whether the kill switch is active.",
+			labels{"mode": d.KillSwitch.Mode}, boolValue(d.KillSwitch.Enabled))
+		r.gauge(namespace+"dns_protection_enabled", "Whether DNS leak protection is active.",
+			labels{"mode": d.DNSProtection.Mode}, boolValue(d.DNSProtection.Enabled))
+		r.gauge(namespace+"ipv6_protection_enabled", "Whether IPv6 leak protection is active.",
+			labels{"mode": d.IPv6Protection.Mode}, boolValue(d.IPv6Protection.Enabled))
+		r.gauge(namespace+"split_tunnel_enabled", "Whether split tunneling is active.",
+			labels{"mode": d.SplitTunnel.Mode}, boolValue(d.SplitTunnel.Enabled))
+		r.gauge(namespace+"lan_gateway_enabled", "Whether the LAN gateway is active.", nil, boolValue(d.LANGateway.Enabled))
+		r.gauge(namespace+"tailscale_connected", "Whether Tailscale is connected according to the daemon.",
+			nil, boolValue(d.Tailscale.Connected))
+	} else {
+		r.gauge(namespace+"daemon_up", "Whether vpn-managerd answered state.get.", nil, 0)
+	}
+
+	for _, c := range snap.Connections {
+		lbls := labels{"profile": c.ProfileID, "profile_name": c.ProfileName, "provider": c.Provider}
+
+		r.gauge(namespace+"connection_up", "Whether the connection is established (0 while connecting or failing).",
+			lbls, boolValue(c.Status == vpntypes.StatusConnected))
+		if !c.StartTime.IsZero() {
+			r.gauge(namespace+"connection_start_time_seconds", "Unix time the connection was started.",
+				lbls, float64(c.StartTime.Unix()))
+		}
+		if c.HasTraffic {
+			r.counter(namespace+"connection_received_bytes_total", "Bytes received in the current session.",
+				lbls, float64(c.BytesIn))
+			r.counter(namespace+"connection_sent_bytes_total", "Bytes sent in the current session.",
+				lbls, float64(c.BytesOut))
+		}
+		if h := c.Health; h != nil {
+			for _, state := range []health.State{health.StateHealthy, health.StateDegraded, health.StateUnhealthy, health.StateUnknown} {
+				r.gauge(namespace+"connection_health_state", "Health checker state of the connection; 1 for the current state.",
+					withLabel(lbls, "state", strings.ToLower(state.String())), boolValue(h.State == state))
+			}
+			r.gauge(namespace+"connection_latency_seconds", "Latency of the last health check (0 when it failed).",
+				lbls, h.Latency.Seconds())
+			r.gauge(namespace+"connection_consecutive_failures", "Health checks failed in a row.",
+				lbls, float64(h.ConsecutiveFails))
+			r.gauge(namespace+"connection_reconnect_attempts", "Auto-reconnect attempts since the connection was last healthy.",
+				lbls, float64(h.ReconnectAttempts))
+		}
+	}
+
+	r.counter(namespace+"events_published_total", "Events published on the internal event bus.", nil, float64(snap.EventsPublished))
+	r.counter(namespace+"events_delivered_total", "Events delivered to event bus subscribers.", nil, float64(snap.EventsDelivered))
+	r.counter(namespace+"events_dropped_total", "Events dropped because the delivery queue was full.", nil, float64(snap.EventsDropped))
+
+	return r
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// withLabel returns a copy of lbls with name set to value.
+func withLabel(lbls labels, name, value string) labels {
+	out := make(labels, len(lbls)+1)
+	for k, v := range lbls {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn/health"
+	vpntypes "github.com/yllada/vpn-manager/internal/vpn/types"
+)
+
+func testSnapshot() Snapshot {
+	snap := Snapshot{
+		Daemon:          &daemon.StateSnapshot{UptimeSeconds: 3600},
+		EventsPublished: 10,
+		EventsDelivered: 9,
+		EventsDropped:   1,
+		Connections: []Connection{
+			{
+				ProfileID:   "work",
+				ProfileName: `Office "HQ"`,
+				Provider:    vpntypes.ProtocolOpenVPN,
+				Status:      vpntypes.StatusConnected,
+				StartTime:   time.Unix(1700000000, 0),
+				HasTraffic:  true,
+				BytesIn:     2048,
+				BytesOut:    512,
+				Health: &health.ConnectionHealth{
+					State:            health.StateDegraded,
+					ConsecutiveFails: 2,
+					Latency:          150 * time.Millisecond,
+				},
+			},
+			{ProfileID: "home", ProfileName: "home", Provider: vpntypes.ProtocolWireGuard, Status: vpntypes.StatusConnecting},
+		},
+	}
+	snap.Daemon.KillSwitch.Enabled = true
+	snap.Daemon.KillSwitch.Mode = "always"
+	return snap
+}
+
+func encodeSnapshot(t *testing.T, snap Snapshot, openMetrics bool) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := collect(snap).encode(&buf, openMetrics); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCollect(t *testing.T) {
+	out := encodeSnapshot(t, testSnapshot(), false)
+
+	work := `profile="work",profile_name="Office \"HQ\"",provider="openvpn"`
+	for _, want := range []string{
+		"# TYPE vpn_manager_daemon_up gauge\nvpn_manager_daemon_up 1\n",
+		"vpn_manager_daemon_uptime_seconds 3600\n",
+		`vpn_manager_killswitch_enabled{mode="always"} 1` + "\n",
+		"# TYPE vpn_manager_connection_received_bytes_total counter\n",
+		"vpn_manager_connection_up{" + work + "} 1\n",
+		"vpn_manager_connection_received_bytes_total{" + work + "} 2048\n",
+		"vpn_manager_connection_sent_bytes_total{" + work + "} 512\n",
+		"vpn_manager_connection_start_time_seconds{" + work + "} 1.7e+09\n",
+		"vpn_manager_connection_latency_seconds{" + work + "} 0.15\n",
+		"vpn_manager_connection_consecutive_failures{" + work + "} 2\n",
+		"vpn_manager_connection_health_state{" + work + `,state="degraded"} 1` + "\n",
+		"vpn_manager_connection_health_state{" + work + `,state="healthy"} 0` + "\n",
+		`vpn_manager_connection_up{profile="home",profile_name="home",provider="wireguard"} 0` + "\n",
+		"vpn_manager_events_dropped_total 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	// A connection without a stats session or health entry reports neither.
+	if strings.Contains(out, `sent_bytes_total{profile="home"`) || strings.Contains(out, `latency_seconds{profile="home"`) {
+		t.Errorf("home has no traffic or health to report:\n%s", out)
+	}
+	if strings.Count(out, "# TYPE vpn_manager_connection_up ") != 1 {
+		t.Errorf("each family should be declared once:\n%s", out)
+	}
+	if strings.Contains(out, "# EOF") {
+		t.Error("the Prometheus text format has no # EOF")
+	}
+
+	// Without the daemon only daemon_up (0) is left of its metrics.
+	down := encodeSnapshot(t, Snapshot{}, false)
+	if !strings.Contains(down, "vpn_manager_daemon_up 0\n") || strings.Contains(down, "killswitch_enabled") {
+		t.Errorf("unreachable daemon reported as:\n%s", down)
+	}
+}
+
+func TestEncodeOpenMetrics(t *testing.T) {
+	out := encodeSnapshot(t, testSnapshot(), true)
+
+	if !strings.Contains(out, "# TYPE vpn_manager_events_published counter\nvpn_manager_events_published_total 10\n") {
+		t.Errorf("counter family should drop _total, samples keep it:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Errorf("OpenMetrics output must end with # EOF:\n%s", out)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	e := &Exporter{
+		gather: func(context.Context) Snapshot { return testSnapshot() },
+		logger: log.New(io.Discard, "", 0),
+	}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		eof         bool
+	}{
+		{"", ContentTypeText, false},
+		{"application/openmetrics-text; version=1.0.0,text/plain;q=0.5", ContentTypeOpenMetrics, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Accept %q: status %d", tt.accept, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if got := strings.HasSuffix(rec.Body.String(), "# EOF\n"); got != tt.eof {
+			t.Errorf("Accept %q: ends with # EOF = %v, want %v", tt.accept, got, tt.eof)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d, want 405", rec.Code)
+	}
+}
+
+func TestListenLoopbackOnly(t *testing.T) {
+	e := &Exporter{
+		gather: func(context.Context) Snapshot { return Snapshot{} },
+		logger: log.New(io.Discard, "", 0),
+	}
+
+	for _, addr := range []string{":9586", "0.0.0.0:9586", "192.168.1.10:9586", "[::]:9586", "9586"} {
+		if err := e.Listen(addr); err == nil {
+			_ = e.Stop()
+			t.Errorf("Listen(%q) should be refused", addr)
+		}
+	}
+
+	if err := e.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer func() { _ = e.Stop() }()
+
+	resp, err := http.Get("http://" + e.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "vpn_manager_daemon_up 0") {
+		t.Errorf("scrape returned:\n%s", body)
+	}
+}
+
+func TestTextfile(t *testing.T) {
+	e := &Exporter{
+		gather: func(context.Context) Snapshot { return testSnapshot() },
+		logger: log.New(io.Discard, "", 0),
+	}
+	dir := filepath.Join(t.TempDir(), "textfile")
+
+	if err := e.StartTextfile(filepath.Join(dir, "vpn.txt"), time.Hour); err == nil {
+		t.Error("a file the collector would ignore should be refused")
+	}
+
+	path := filepath.Join(dir, "vpn_manager.prom")
+	if err := e.StartTextfile(path, time.Hour); err != nil {
+		t.Fatalf("StartTextfile: %v", err)
+	}
+	if err := e.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("textfile not written: %v", err)
+	}
+	if !strings.Contains(string(data), "vpn_manager_events_published_total 10\n") || strings.Contains(string(data), "# EOF") {
+		t.Errorf("textfile should hold the Prometheus text format:\n%s", data)
+	}
+}
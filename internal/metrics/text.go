@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Content types of the two exposition formats.
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Metric types.
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+// family is one metric with its samples. Counter names carry the _total
+// suffix; the OpenMetrics encoding strips it from the TYPE and HELP lines.
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels labels
+	value  float64
+}
+
+// labels is a label set. Encoding sorts it by name.
+type labels map[string]string
+
+// registry accumulates the families of one scrape, in the order they are
+// first added.
+type registry struct {
+	families []*family
+	byName   map[string]*family
+}
+
+func newRegistry() *registry {
+	return &registry{byName: make(map[string]*family)}
+}
+
+// add appends a sample to the named family, creating it on first use.
+func (r *registry) add(name, typ, help string, lbls labels, value float64) {
+	f, ok := r.byName[name]
+	if !ok {
+		f = &family{name: name, help: help, typ: typ}
+		r.byName[name] = f
+		r.families = append(r.families, f)
+	}
+	f.samples = append(f.samples, sample{labels: lbls, value: value})
+}
+
+func (r *registry) gauge(name, help string, lbls labels, value float64) {
+	r.add(name, typeGauge, help, lbls, value)
+}
+
+func (r *registry) counter(name, help string, lbls labels, value float64) {
+	r.add(name, typeCounter, help, lbls, value)
+}
+
+// encode writes the families in the Prometheus text format or, with
+// openMetrics, in OpenMetrics (family names without _total, # EOF at the end).
+func (r *registry) encode(w io.Writer, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range r.families {
+		name := f.name
+		if openMetrics && f.typ == typeCounter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+		bw.WriteString("# HELP " + name + " " + escapeHelp(f.help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + f.typ + "\n")
+		for _, s := range f.samples {
+			bw.WriteString(f.name)
+			writeLabels(bw, s.labels)
+			bw.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+func writeLabels(bw *bufio.Writer, lbls labels) {
+	if len(lbls) == 0 {
+		return
+	}
+	names := make([]string, 0, len(lbls))
+	for name := range lbls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(name + `="` + escapeLabelValue(lbls[name]) + `"`)
+	}
+	bw.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
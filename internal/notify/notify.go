@@ -104,6 +104,17 @@ func Connecting(profileName string) {
 	})
 }
 
+// CertificateExpiring warns that a certificate of a profile expires soon, or
+// has expired; message names the certificate, the profile and the date.
+func CertificateExpiring(message string) {
+	Show(Notification{
+		Title:   "VPN Certificate Expiring",
+		Message: message,
+		Type:    Warning,
+		Icon:    "security-medium-symbolic",
+	})
+}
+
 // ════════════════════════════════════════════════════════════════════════════
 // NETWORK TRUST NOTIFICATIONS
 // ════════════════════════════════════════════════════════════════════════════
@@ -91,6 +91,10 @@ const (
 
 	// DNSStatePath is the full path to the DNS protection state file.
 	DNSStatePath = StateDir + "/dns.state"
+
+	// LogDir is the root-only directory for the daemon's logs: the audit
+	// trail and the output of past OpenVPN sessions.
+	LogDir = "/var/log/vpn-manager"
 )
 
 // =============================================================================
@@ -0,0 +1,261 @@
+// Command rpcgen generates a typed Go client from the daemon's description of
+// its RPC methods: the output of system.describe, or of vpn-managerd --describe.
+//
+// Usage:
+//
+//	rpcgen [-in describe.json] [-out client_gen.go] [-pkg api] [-skip events.]
+//
+// Every definition becomes a struct and every method a Client method (see
+// pkg/protocol/api for the Client type the output expects).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+func main() {
+	in := flag.String("in", "-", "Description to read (- for stdin)")
+	out := flag.String("out", "-", "Go file to write (- for stdout)")
+	pkg := flag.String("pkg", "api", "Package name of the generated file")
+	skip := flag.String("skip", "events.", "Comma-separated method prefixes to leave out (event streams need protocol.Client.Subscribe)")
+	flag.Parse()
+
+	log.SetFlags(0)
+	log.SetPrefix("rpcgen: ")
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var desc protocol.DescribeResult
+	if err := json.NewDecoder(r).Decode(&desc); err != nil {
+		log.Fatalf("read description: %v", err)
+	}
+
+	var skipPrefixes []string
+	if *skip != "" {
+		skipPrefixes = strings.Split(*skip, ",")
+	}
+	src, err := generate(&desc, *pkg, skipPrefixes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(*out, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generator accumulates the generated source and the imports it needs.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+// generate returns the formatted Go source for desc.
+func generate(desc *protocol.DescribeResult, pkg string, skip []string) ([]byte, error) {
+	g := &generator{imports: map[string]bool{}}
+
+	names := make([]string, 0, len(desc.Defs))
+	for name := range desc.Defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&g.buf, "\n// %s mirrors the daemon's %s type.\n", name, name)
+		fmt.Fprintf(&g.buf, "type %s %s\n", name, g.structType(desc.Defs[name]))
+	}
+
+	for _, m := range desc.Methods {
+		if hasAnyPrefix(m.Name, skip) {
+			continue
+		}
+		g.method(m)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by rpcgen from the daemon's system.describe output. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	imports := []string{"context"}
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	out.WriteString("import (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// method writes the Client method calling m.
+func (g *generator) method(m protocol.MethodDescription) {
+	name := goName(m.Name)
+
+	args := "ctx context.Context"
+	params := "nil"
+	if m.Params != nil {
+		args += ", params " + g.goType(m.Params)
+		params = "params"
+	}
+
+	fmt.Fprintf(&g.buf, "\n// %s calls %s.", name, m.Name)
+	if m.Summary != "" {
+		fmt.Fprintf(&g.buf, " %s", m.Summary)
+	}
+	g.buf.WriteString("\n")
+
+	switch {
+	case m.Result == nil:
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(%s) error {\n", name, args)
+		fmt.Fprintf(&g.buf, "\treturn c.caller.Call(ctx, %q, %s, nil)\n}\n", m.Name, params)
+	case m.Result.Ref != "":
+		typ := g.goType(m.Result)
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(%s) (*%s, error) {\n", name, args, typ)
+		fmt.Fprintf(&g.buf, "\tvar result %s\n", typ)
+		fmt.Fprintf(&g.buf, "\tif err := c.caller.Call(ctx, %q, %s, &result); err != nil {\n\t\treturn nil, err\n\t}\n", m.Name, params)
+		g.buf.WriteString("\treturn &result, nil\n}\n")
+	default:
+		typ := g.goType(m.Result)
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(%s) (%s, error) {\n", name, args, typ)
+		fmt.Fprintf(&g.buf, "\tvar result %s\n", typ)
+		fmt.Fprintf(&g.buf, "\terr := c.caller.Call(ctx, %q, %s, &result)\n", m.Name, params)
+		g.buf.WriteString("\treturn result, err\n}\n")
+	}
+}
+
+// structType returns the Go struct for an object schema, fields ordered by
+// their JSON name.
+func (g *generator) structType(s *protocol.Schema) string {
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	required := map[string]bool{}
+	for _, key := range s.Required {
+		required[key] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, key := range keys {
+		prop := s.Properties[key]
+		field := prop.GoName
+		if field == "" {
+			field = goName(key)
+		}
+		tag := key
+		if !required[key] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", field, g.goType(prop), tag)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// goType returns the Go type for values matching s.
+func (g *generator) goType(s *protocol.Schema) string {
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, protocol.DefRef(""))
+	}
+	if len(s.AnyOf) == 2 && s.AnyOf[1].Type == "null" {
+		return "*" + g.goType(s.AnyOf[0])
+	}
+
+	switch s.Type {
+	case "boolean":
+		return "bool"
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format != "" {
+			return s.Format
+		}
+		return "int"
+	case "number":
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		switch {
+		case s.AdditionalProperties != nil:
+			return "map[string]" + g.goType(s.AdditionalProperties)
+		case s.Properties != nil:
+			return g.structType(s)
+		}
+		return "map[string]any"
+	}
+	g.imports["encoding/json"] = true
+	return "json.RawMessage"
+}
+
+// initialisms are the name parts Go spells in capitals.
+var initialisms = map[string]string{
+	"dns": "DNS", "id": "ID", "ip": "IP", "ipv6": "IPv6", "lan": "LAN",
+	"openvpn": "OpenVPN", "pid": "PID", "url": "URL", "vpn": "VPN", "wireguard": "WireGuard",
+}
+
+// goName turns a method or JSON name into an exported Go name:
+// "killswitch.block_all" -> "KillswitchBlockAll", "profile_id" -> "ProfileID".
+func goName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '.' || r == '_' || r == '-' }) {
+		if v, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if p != "" && strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// TestGeneratedClientUpToDate fails when pkg/protocol/api/client_gen.go was
+// edited by hand or not regenerated after describe.json changed.
+func TestGeneratedClientUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "pkg", "protocol", "api")
+	raw, err := os.ReadFile(filepath.Join(dir, "describe.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var desc protocol.DescribeResult
+	if err := json.Unmarshal(raw, &desc); err != nil {
+		t.Fatalf("parse describe.json: %v", err)
+	}
+
+	got, err := generate(&desc, "api", []string{"events."})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "client_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("client_gen.go is stale; run go generate ./pkg/protocol/api")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	desc := &protocol.DescribeResult{
+		Methods: []protocol.MethodDescription{
+			{
+				Name:    "thing.get_all",
+				Summary: "List the things.",
+				Params:  &protocol.Schema{Ref: protocol.DefRef("Query")},
+				Result:  &protocol.Schema{Type: "array", Items: &protocol.Schema{Ref: protocol.DefRef("Thing")}},
+			},
+			{Name: "thing.poke"},
+			{Name: "events.subscribe"},
+		},
+		Defs: map[string]*protocol.Schema{
+			"Query": {Type: "object", Properties: map[string]*protocol.Schema{
+				"profile_id": {Type: "string"},
+				"limit":      {AnyOf: []*protocol.Schema{{Type: "integer", Format: "uint32"}, {Type: "null"}}},
+			}, Required: []string{"profile_id"}},
+			"Thing": {Type: "object", Properties: map[string]*protocol.Schema{
+				"vpn_iface": {Type: "string", GoName: "VPNIface"},
+				"extra":     {},
+				"since":     {Type: "string", Format: "date-time"},
+			}},
+		},
+	}
+
+	src, err := generate(desc, "api", []string{"events."})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	code := string(src)
+
+	for _, want := range []string{
+		"ProfileID string `json:\"profile_id\"`",
+		"Limit *uint32 `json:\"limit,omitempty\"`",
+		"VPNIface string `json:\"vpn_iface,omitempty\"`",
+		"Extra json.RawMessage",
+		"Since time.Time",
+		"func (c *Client) ThingGetAll(ctx context.Context, params Query) ([]Thing, error)",
+		"// ThingGetAll calls thing.get_all. List the things.",
+		"func (c *Client) ThingPoke(ctx context.Context) error",
+		`"encoding/json"`,
+		`"time"`,
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(code), " "), strings.Join(strings.Fields(want), " ")) {
+			t.Errorf("generated code lacks %q:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "EventsSubscribe") {
+		t.Error("skipped method events.subscribe was generated")
+	}
+}
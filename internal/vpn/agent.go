@@ -0,0 +1,325 @@
+// Package vpn provides VPN connection management functionality.
+// This file lets a Manager hand its OpenVPN connections to the session agent.
+package vpn
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/eventbus"
+	"github.com/yllada/vpn-manager/internal/logger"
+	"github.com/yllada/vpn-manager/internal/resilience"
+)
+
+// =============================================================================
+// SESSION AGENT DELEGATION
+// =============================================================================
+//
+// When the session agent (vpn-manager-agent) is running it owns the OpenVPN
+// connections: it applies the kill switch, DNS and IPv6 protection, records
+// stats and reconnects on failure, whether or not a GUI is open. A Manager in
+// agent mode (UseSessionAgent) is a thin client: Connect and Disconnect are
+// forwarded to the agent, and the local registry only mirrors what the agent
+// reports so the GUI and vpnctl can show it. A mirrored connection never
+// touches the security features, the stats database or the network lock —
+// doing so from two processes would fight over the same firewall rules.
+
+// sessionAgent is the part of daemon.AgentClient the Manager uses. It is an
+// interface so tests can substitute a fake agent.
+type sessionAgent interface {
+	Connect(profileID, username, password string) error
+	Disconnect(profileID string) error
+	Status() (*daemon.AgentStatusResult, error)
+}
+
+// agentEvents subscribes to agent events. A package var so tests can feed
+// events without a running agent.
+var agentEvents = daemon.SubscribeAgentEvents
+
+// Agent polling intervals, mirroring the daemon monitor's: the status poll is
+// a safety net while the event stream is up.
+const (
+	agentPollInterval       = 2 * time.Second
+	agentEventsPollInterval = 15 * time.Second
+	agentResubscribeDelay   = 5 * time.Second
+)
+
+// agentStatusNames is the wire form of ConnectionStatus on the agent socket.
+// It uses the daemon's openvpn.status vocabulary.
+var agentStatusNames = map[ConnectionStatus]string{
+	StatusDisconnected:  "disconnected",
+	StatusConnecting:    "connecting",
+	StatusConnected:     "connected",
+	StatusDisconnecting: "disconnecting",
+	StatusError:         "error",
+}
+
+// parseAgentStatus is the inverse of agentStatusNames. Unknown names read as
+// disconnected.
+func parseAgentStatus(name string) ConnectionStatus {
+	for s, n := range agentStatusNames {
+		if n == name {
+			return s
+		}
+	}
+	return StatusDisconnected
+}
+
+// AgentStatus describes conn in the form the session agent reports it.
+func AgentStatus(conn *Connection) daemon.AgentConnectionStatus {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	return daemon.AgentConnectionStatus{
+		ProfileID:   conn.Profile.ID,
+		ProfileName: conn.Profile.Name,
+		Status:      agentStatusNames[conn.Status],
+		IPAddress:   conn.IPAddress,
+		StartTime:   conn.StartTime,
+		LastError:   conn.LastError,
+	}
+}
+
+// UseSessionAgent switches the Manager to agent mode: OpenVPN connections are
+// started and stopped by the session agent, and connections the agent starts
+// on its own (auto-connect, trusted-network rules, reconnects) are mirrored
+// into the local registry. Call it once, right after NewManager, when
+// daemon.IsAgentAvailable reports the agent is running.
+func (m *Manager) UseSessionAgent() {
+	m.useAgent(&daemon.AgentClient{})
+	resilience.SafeGoWithName("vpn-agent-watch", m.watchAgentConnections)
+}
+
+func (m *Manager) useAgent(agent sessionAgent) {
+	m.mu.Lock()
+	m.agent = agent
+	m.mu.Unlock()
+}
+
+// AgentMode reports whether the Manager delegates to the session agent.
+func (m *Manager) AgentMode() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.agent != nil
+}
+
+// runAgentConnection asks the agent to connect and mirrors the result.
+func (m *Manager) runAgentConnection(conn *Connection, username, password string) {
+	if err := m.agent.Connect(conn.Profile.ID, username, password); err != nil {
+		logger.LogError("vpn", "Agent connection failed: %v", err)
+		m.handleConnectionError(conn, err)
+		m.mu.Lock()
+		if m.connections[conn.Profile.ID] == conn {
+			delete(m.connections, conn.Profile.ID)
+		}
+		m.mu.Unlock()
+		return
+	}
+	m.monitorAgentConnection(conn)
+}
+
+// disconnectViaAgent is Disconnect in agent mode. The caller holds m.mu and
+// has already stopped the mirror. The agent tears down the security features
+// once its last connection is gone.
+func (m *Manager) disconnectViaAgent(conn *Connection, profileID string) error {
+	err := m.agent.Disconnect(profileID)
+
+	conn.mu.Lock()
+	conn.Status = StatusDisconnected
+	conn.mu.Unlock()
+	delete(m.connections, profileID)
+
+	eventbus.Emit(eventbus.EventConnectionClosed, "Manager", eventbus.ConnectionEventData{
+		ProfileID: profileID,
+	})
+	return err
+}
+
+// adoptAgentConnections mirrors the agent's live connections that are not in
+// the local registry yet.
+func (m *Manager) adoptAgentConnections() {
+	status, err := m.agent.Status()
+	if err != nil {
+		logger.LogDebug("vpn", "Could not list agent connections to adopt: %v", err)
+		return
+	}
+	for i := range status.Connections {
+		m.adoptAgentConnection(&status.Connections[i])
+	}
+}
+
+// adoptAgentConnection registers one agent connection and starts mirroring it.
+func (m *Manager) adoptAgentConnection(st *daemon.AgentConnectionStatus) {
+	if status := parseAgentStatus(st.Status); status != StatusConnected && status != StatusConnecting {
+		return
+	}
+	prof, err := m.profileManager.Get(st.ProfileID)
+	if err != nil {
+		logger.LogWarn("vpn", "Agent is running unknown profile %s; not adopting", st.ProfileID)
+		return
+	}
+
+	m.mu.Lock()
+	if _, exists := m.connections[st.ProfileID]; exists {
+		m.mu.Unlock()
+		return
+	}
+	startTime := st.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	conn := &Connection{
+		Profile:   prof,
+		Status:    StatusConnecting,
+		StartTime: startTime,
+		stopChan:  make(chan struct{}),
+		ready:     make(chan struct{}),
+	}
+	m.connections[st.ProfileID] = conn
+	m.mu.Unlock()
+
+	logger.LogInfo("vpn", "Mirroring agent connection for profile %s", prof.Name)
+	resilience.SafeGoWithName("vpn-agent-monitor", func() {
+		m.monitorAgentConnection(conn)
+	})
+}
+
+// watchAgentConnections adopts connections the agent starts by itself. It
+// runs for the life of the process and resubscribes if the agent restarts.
+func (m *Manager) watchAgentConnections() {
+	for {
+		m.adoptAgentConnections()
+
+		events, err := agentEvents(context.Background(), daemon.AgentTopicConnection)
+		if err != nil {
+			time.Sleep(agentResubscribeDelay)
+			continue
+		}
+		for ev := range events {
+			var st daemon.AgentConnectionStatus
+			if err := ev.UnmarshalData(&st); err != nil {
+				continue
+			}
+			m.adoptAgentConnection(&st)
+		}
+		time.Sleep(agentResubscribeDelay)
+	}
+}
+
+// monitorAgentConnection keeps a mirrored connection in line with the agent
+// until it goes away. It follows agent.connection events and polls
+// agent.status as a fallback.
+func (m *Manager) monitorAgentConnection(conn *Connection) {
+	profileID := conn.Profile.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interval := agentPollInterval
+	events, err := agentEvents(ctx, daemon.AgentTopicConnection)
+	if err != nil {
+		logger.LogDebug("vpn", "Agent events unavailable, polling status: %v", err)
+	} else {
+		interval = agentEventsPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.stopChan:
+			return
+
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				ticker.Reset(agentPollInterval)
+				continue
+			}
+			var st daemon.AgentConnectionStatus
+			if err := ev.UnmarshalData(&st); err != nil || st.ProfileID != profileID {
+				continue
+			}
+			if m.applyAgentStatus(conn, &st) {
+				return
+			}
+
+		case <-ticker.C:
+			status, err := m.agent.Status()
+			if err != nil {
+				logger.LogDebug("vpn", "Error getting agent status: %v", err)
+				continue
+			}
+			// A connection the agent no longer lists is gone.
+			st := daemon.AgentConnectionStatus{ProfileID: profileID, Status: agentStatusNames[StatusDisconnected]}
+			for _, c := range status.Connections {
+				if c.ProfileID == profileID {
+					st = c
+					break
+				}
+			}
+			if m.applyAgentStatus(conn, &st) {
+				return
+			}
+		}
+	}
+}
+
+// applyAgentStatus mirrors an agent status onto conn and emits the matching
+// bus events. It returns true once the connection is gone.
+func (m *Manager) applyAgentStatus(conn *Connection, st *daemon.AgentConnectionStatus) bool {
+	profileID := conn.Profile.ID
+
+	conn.mu.Lock()
+	prev := conn.Status
+	switch status := parseAgentStatus(st.Status); status {
+	case StatusConnected:
+		conn.Status = StatusConnected
+		conn.IPAddress = st.IPAddress
+		conn.mu.Unlock()
+		if prev != StatusConnected {
+			eventbus.Emit(eventbus.EventConnectionEstablished, "Manager", eventbus.ConnectionEventData{
+				ProfileID:   profileID,
+				ProfileName: conn.Profile.Name,
+				IPAddress:   st.IPAddress,
+			})
+			conn.markReady()
+		}
+		return false
+
+	case StatusConnecting, StatusDisconnecting:
+		conn.Status = status
+		conn.mu.Unlock()
+		return false
+	}
+
+	// Disconnected or failed.
+	conn.Status = StatusDisconnected
+	if st.LastError != "" || parseAgentStatus(st.Status) == StatusError {
+		conn.Status = StatusError
+		conn.LastError = st.LastError
+	}
+	failed := conn.Status == StatusError
+	conn.mu.Unlock()
+
+	m.mu.Lock()
+	if m.connections[profileID] == conn {
+		delete(m.connections, profileID)
+	}
+	m.mu.Unlock()
+
+	if failed {
+		eventbus.Emit(eventbus.EventConnectionFailed, "Manager", eventbus.ConnectionEventData{
+			ProfileID:   profileID,
+			ProfileName: conn.Profile.Name,
+			Error:       stderrors.New(st.LastError),
+		})
+	} else {
+		eventbus.Emit(eventbus.EventConnectionClosed, "Manager", eventbus.ConnectionEventData{
+			ProfileID: profileID,
+		})
+	}
+	return true
+}
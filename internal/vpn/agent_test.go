@@ -0,0 +1,212 @@
+package vpn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// fakeAgent records what a Manager in agent mode asks the session agent.
+type fakeAgent struct {
+	mu           sync.Mutex
+	connects     []string
+	disconnects  []string
+	status       daemon.AgentStatusResult
+	connectErr   error
+	connectCalls chan struct{}
+}
+
+func (f *fakeAgent) Connect(profileID, _, _ string) error {
+	f.mu.Lock()
+	f.connects = append(f.connects, profileID)
+	f.mu.Unlock()
+	if f.connectCalls != nil {
+		f.connectCalls <- struct{}{}
+	}
+	return f.connectErr
+}
+
+func (f *fakeAgent) Disconnect(profileID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disconnects = append(f.disconnects, profileID)
+	return nil
+}
+
+func (f *fakeAgent) Status() (*daemon.AgentStatusResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := f.status
+	return &st, nil
+}
+
+// newAgentModeManager returns a Manager in agent mode with one profile, and
+// the channel its mirrors receive agent.connection events from.
+func newAgentModeManager(t *testing.T, agent *fakeAgent) (*Manager, *profile.Profile, chan protocol.Event) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir()) // isolate profile storage from the real config
+	m, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "office.ovpn")
+	if err := os.WriteFile(cfgPath, []byte("client\ndev tun\nremote vpn.example.com 1194\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	prof := &profile.Profile{Name: "Office", ConfigPath: cfgPath}
+	if err := m.ProfileManager().Add(prof); err != nil {
+		t.Fatalf("Add profile: %v", err)
+	}
+
+	events := make(chan protocol.Event, 4)
+	orig := agentEvents
+	agentEvents = func(context.Context, ...string) (<-chan protocol.Event, error) { return events, nil }
+	t.Cleanup(func() { agentEvents = orig })
+
+	m.useAgent(agent)
+	return m, prof, events
+}
+
+func agentEvent(t *testing.T, st daemon.AgentConnectionStatus) protocol.Event {
+	t.Helper()
+	data, err := json.Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return protocol.Event{Topic: daemon.AgentTopicConnection, Data: data}
+}
+
+// TestAgentModeConnectAndDisconnect pins the thin-client contract: in agent
+// mode Connect and Disconnect go to the session agent, and the local
+// connection only follows what the agent reports.
+func TestAgentModeConnectAndDisconnect(t *testing.T) {
+	agent := &fakeAgent{connectCalls: make(chan struct{}, 1)}
+	m, prof, events := newAgentModeManager(t, agent)
+
+	if !m.AgentMode() {
+		t.Fatal("AgentMode() = false after useAgent")
+	}
+	if err := m.Connect(prof.ID, "alice", "secret"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	select {
+	case <-agent.connectCalls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Connect was not forwarded to the agent")
+	}
+
+	conn, ok := m.GetConnection(prof.ID)
+	if !ok {
+		t.Fatal("connection not registered")
+	}
+	events <- agentEvent(t, daemon.AgentConnectionStatus{ProfileID: prof.ID, Status: "connected", IPAddress: "10.8.0.2"})
+	select {
+	case <-conn.Ready():
+	case <-time.After(2 * time.Second):
+		t.Fatal("mirror did not become ready on the agent's connected event")
+	}
+	if got := conn.GetStatus(); got != StatusConnected {
+		t.Errorf("status = %v, want Connected", got)
+	}
+	if got := conn.GetIPAddress(); got != "10.8.0.2" {
+		t.Errorf("IP = %q, want 10.8.0.2", got)
+	}
+
+	if err := m.Disconnect(prof.ID); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	agent.mu.Lock()
+	disconnects := agent.disconnects
+	agent.mu.Unlock()
+	if len(disconnects) != 1 || disconnects[0] != prof.ID {
+		t.Errorf("agent disconnects = %v, want [%s]", disconnects, prof.ID)
+	}
+	if _, ok := m.GetConnection(prof.ID); ok {
+		t.Error("connection still registered after Disconnect")
+	}
+}
+
+// TestAgentModeConnectFailure checks that an agent refusing the connection
+// leaves nothing behind in the local registry.
+func TestAgentModeConnectFailure(t *testing.T) {
+	agent := &fakeAgent{connectCalls: make(chan struct{}, 1), connectErr: errors.New("no password")}
+	m, prof, _ := newAgentModeManager(t, agent)
+
+	if err := m.Connect(prof.ID, "alice", ""); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	<-agent.connectCalls
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := m.GetConnection(prof.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failed agent connection was not removed from the registry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestApplyAgentStatus covers how agent reports map onto a mirrored
+// connection: in-progress states keep it, a drop or failure removes it.
+func TestApplyAgentStatus(t *testing.T) {
+	m, prof, _ := newAgentModeManager(t, &fakeAgent{})
+
+	newConn := func() *Connection {
+		conn := &Connection{Profile: prof, Status: StatusConnecting, stopChan: make(chan struct{}), ready: make(chan struct{})}
+		m.mu.Lock()
+		m.connections[prof.ID] = conn
+		m.mu.Unlock()
+		return conn
+	}
+
+	conn := newConn()
+	if gone := m.applyAgentStatus(conn, &daemon.AgentConnectionStatus{ProfileID: prof.ID, Status: "connecting"}); gone {
+		t.Error("connecting reported the connection as gone")
+	}
+
+	if gone := m.applyAgentStatus(conn, &daemon.AgentConnectionStatus{ProfileID: prof.ID, Status: "disconnected"}); !gone {
+		t.Error("disconnected did not report the connection as gone")
+	}
+	if got := conn.GetStatus(); got != StatusDisconnected {
+		t.Errorf("status after drop = %v, want Disconnected", got)
+	}
+	if _, ok := m.GetConnection(prof.ID); ok {
+		t.Error("dropped connection still registered")
+	}
+
+	conn = newConn()
+	if gone := m.applyAgentStatus(conn, &daemon.AgentConnectionStatus{ProfileID: prof.ID, Status: "error", LastError: "AUTH_FAILED"}); !gone {
+		t.Error("error did not report the connection as gone")
+	}
+	if got := conn.GetStatus(); got != StatusError {
+		t.Errorf("status after failure = %v, want Error", got)
+	}
+	if conn.LastError != "AUTH_FAILED" {
+		t.Errorf("LastError = %q, want AUTH_FAILED", conn.LastError)
+	}
+}
+
+// TestAgentStatusRoundTrip pins the wire names of ConnectionStatus.
+func TestAgentStatusRoundTrip(t *testing.T) {
+	for status, name := range agentStatusNames {
+		if got := parseAgentStatus(name); got != status {
+			t.Errorf("parseAgentStatus(%q) = %v, want %v", name, got, status)
+		}
+	}
+	if got := parseAgentStatus("bogus"); got != StatusDisconnected {
+		t.Errorf("parseAgentStatus(bogus) = %v, want Disconnected", got)
+	}
+}
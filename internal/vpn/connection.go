@@ -3,12 +3,15 @@
 package vpn
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/yllada/vpn-manager/internal/daemon"
@@ -16,14 +19,43 @@ import (
 	"github.com/yllada/vpn-manager/internal/eventbus"
 	"github.com/yllada/vpn-manager/internal/logger"
 	"github.com/yllada/vpn-manager/internal/resilience"
+	"github.com/yllada/vpn-manager/internal/vpn/network"
 	"github.com/yllada/vpn-manager/internal/vpn/profile"
 	"github.com/yllada/vpn-manager/internal/vpn/security"
 	vpntypes "github.com/yllada/vpn-manager/internal/vpn/types"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // Connect initiates a VPN connection for the specified profile.
 // Returns an error if a connection is already active for this profile.
 func (m *Manager) Connect(profileID string, username string, password string) error {
+	return m.connect(profileID, username, password, false)
+}
+
+// ConnectWithAuthToken connects an OpenVPN profile with the auth-token its
+// server pushed to the last connection, in place of the credentials and any
+// one-time code. Check HasAuthToken first: the daemon refuses the connection
+// when it holds no token for the profile.
+func (m *Manager) ConnectWithAuthToken(profileID string) error {
+	return m.connect(profileID, "", "", true)
+}
+
+// HasAuthToken reports whether the daemon holds an auth-token to reconnect
+// profileID with. Only direct OpenVPN connections use one.
+func (m *Manager) HasAuthToken(profileID string) bool {
+	if m.AgentMode() {
+		return false
+	}
+	if prof, err := m.profileManager.Get(profileID); err != nil || prof.UseNetworkManager {
+		return false
+	}
+	status, err := (&daemon.OpenVPNClient{}).Status(profileID)
+	return err == nil && status.AuthToken
+}
+
+// connect starts a connection; useAuthToken has the daemon answer with the
+// profile's cached auth-token instead of username and password.
+func (m *Manager) connect(profileID string, username string, password string, useAuthToken bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -56,6 +88,7 @@ func (m *Manager) Connect(profileID string, username string, password string) er
 		Status:    StatusConnecting,
 		StartTime: time.Now(),
 		stopChan:  make(chan struct{}),
+		ready:     make(chan struct{}),
 	}
 
 	m.connections[profileID] = conn
@@ -66,15 +99,20 @@ func (m *Manager) Connect(profileID string, username string, password string) er
 		ProfileName: profile.Name,
 	})
 
-	// Use NetworkManager if enabled and available
-	if profile.UseNetworkManager && m.nmBackend.IsAvailable() {
+	// Hand the connection to the session agent when it owns them; otherwise
+	// use NetworkManager if enabled and available
+	if m.agent != nil {
+		resilience.SafeGoWithName("vpn-agent-connection", func() {
+			m.runAgentConnection(conn, username, password)
+		})
+	} else if profile.UseNetworkManager && m.nmBackend.IsAvailable() {
 		resilience.SafeGoWithName("vpn-nm-connection", func() {
 			m.runNMConnection(conn, username, password)
 		})
 	} else {
 		// Start connection in goroutine (direct OpenVPN)
 		resilience.SafeGoWithName("vpn-openvpn-connection", func() {
-			m.runConnection(conn, username, password)
+			m.runConnection(conn, username, password, useAuthToken)
 		})
 	}
 
@@ -116,6 +154,10 @@ func (m *Manager) Disconnect(profileID string) error {
 		close(conn.stopChan)
 	}
 
+	if m.agent != nil {
+		return m.disconnectViaAgent(conn, profileID)
+	}
+
 	// Use NetworkManager to disconnect if that was used for connection
 	if useNM && m.nmBackend.IsAvailable() && nmConnName != "" {
 		if err := m.nmBackend.Disconnect(nmConnName); err != nil {
@@ -231,6 +273,10 @@ func (m *Manager) DisconnectAll() error {
 // and the connection monitor re-applies the security features (kill switch, DNS,
 // IPv6) and arms the drop-lock. Call once at startup.
 func (m *Manager) AdoptRunningConnections() {
+	if m.AgentMode() {
+		m.adoptAgentConnections()
+		return
+	}
 	if !daemon.IsDaemonAvailable() {
 		return
 	}
@@ -278,6 +324,7 @@ func (m *Manager) adoptConnections(active []daemon.OpenVPNStatusResult) {
 			IPAddress: st.IPAddress,
 			StartTime: time.Now(), // best effort; the original start time is not tracked across restarts
 			stopChan:  make(chan struct{}),
+			ready:     make(chan struct{}),
 		}
 		m.connections[st.ProfileID] = conn
 		m.mu.Unlock()
@@ -313,13 +360,21 @@ var monitorStarter = func(m *Manager, conn *Connection) {
 // after a successful VPN connection. Used by both direct OpenVPN and NetworkManager paths.
 func (m *Manager) enablePostConnectionFeatures(conn *Connection) {
 	tunIface := m.detectTunInterface()
-	vpnServerIP := m.getVPNServerIP(conn.Profile)
+	conn.mu.RLock()
+	remoteAddress := conn.remoteAddress
+	conn.mu.RUnlock()
+	vpnServerIPs := m.getVPNServerIPs(conn.Profile)
+	vpnServerIP := connectedServerIP(remoteAddress, vpnServerIPs)
+	if vpnServerIP != "" && !slices.Contains(vpnServerIPs, vpnServerIP) {
+		vpnServerIPs = append(vpnServerIPs, vpnServerIP)
+	}
 
 	// Remember these so the Auto-mode network lock can, on an unexpected drop,
-	// keep the VPN server reachable (otherwise reconnection would be blocked).
+	// keep the VPN servers reachable (otherwise reconnection would be blocked).
 	conn.mu.Lock()
 	conn.tunIface = tunIface
 	conn.serverIP = vpnServerIP
+	conn.serverIPs = vpnServerIPs
 	conn.mu.Unlock()
 
 	// Kill Switch.
@@ -332,7 +387,7 @@ func (m *Manager) enablePostConnectionFeatures(conn *Connection) {
 	if m.killSwitch != nil {
 		switch m.killSwitch.GetMode() {
 		case security.KillSwitchAlways:
-			if err := m.killSwitch.Enable(tunIface, vpnServerIP); err != nil {
+			if err := m.killSwitch.EnableForServers(tunIface, vpnServerIPs); err != nil {
 				logger.LogWarn("killswitch", "failed to enable: %v", err)
 			}
 		case security.KillSwitchAuto:
@@ -377,6 +432,8 @@ func (m *Manager) enablePostConnectionFeatures(conn *Connection) {
 
 	// Stats Collection
 	m.StartStatsCollection(conn.Profile.ID, vpntypes.ProviderOpenVPN, tunIface, vpnServerIP)
+
+	conn.markReady()
 }
 
 // runNMConnection executes VPN connection via NetworkManager.
@@ -464,7 +521,7 @@ func (m *Manager) runNMConnection(conn *Connection, username, password string) {
 
 // runConnection executes the VPN connection via the daemon.
 // The daemon handles all privileged operations (no pkexec needed).
-func (m *Manager) runConnection(conn *Connection, username string, password string) {
+func (m *Manager) runConnection(conn *Connection, username string, password string, useAuthToken bool) {
 	logger.LogDebug("vpn", "Starting connection to %s", conn.Profile.Name)
 	logger.LogDebug("vpn", "Configuration file: %s", conn.Profile.ConfigPath)
 
@@ -486,6 +543,10 @@ func (m *Manager) runConnection(conn *Connection, username string, password stri
 		SplitTunnelEnable: conn.Profile.SplitTunnelEnabled,
 		SplitTunnelMode:   conn.Profile.SplitTunnelMode,
 		SplitTunnelRoutes: conn.Profile.SplitTunnelRoutes,
+		UseAuthToken:      useAuthToken,
+	}
+	if conn.Profile.PreferFastestRemote {
+		params.Remote = fastestRemote(conn.Profile)
 	}
 
 	result, err := client.Connect(params)
@@ -501,96 +562,180 @@ func (m *Manager) runConnection(conn *Connection, username string, password stri
 	m.monitorDaemonConnection(conn)
 }
 
+// Fallback polling intervals for monitorDaemonConnection. While subscribed to
+// daemon events the status poll is only a safety net for dropped events.
+const (
+	daemonPollInterval       = 2 * time.Second
+	daemonEventsPollInterval = 15 * time.Second
+)
+
 // monitorDaemonConnection monitors an OpenVPN connection managed by the daemon.
+// It follows openvpn.state events pushed by the daemon and falls back to
+// polling openvpn.status when the event stream is unavailable.
 func (m *Manager) monitorDaemonConnection(conn *Connection) {
 	client := &daemon.OpenVPNClient{}
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
 	profileID := conn.Profile.ID
 	wasConnected := false
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interval := daemonPollInterval
+	events, err := daemon.SubscribeEvents(ctx, protocol.TopicOpenVPNState)
+	if err != nil {
+		logger.LogDebug("vpn", "Daemon events unavailable, polling status: %v", err)
+	} else {
+		interval = daemonEventsPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// apply reconciles conn with a daemon status; it returns true once the
+	// connection is gone and monitoring should stop.
+	apply := func(status *daemon.OpenVPNStatusResult) bool {
+		m.noteChallenge(conn, status.Challenge)
+		conn.mu.Lock()
+		switch status.Status {
+		case "connected":
+			if !wasConnected {
+				conn.Status = StatusConnected
+				conn.IPAddress = status.IPAddress
+				conn.remoteAddress = status.RemoteAddress
+				wasConnected = true
+				logger.LogInfo("vpn", "Connected via daemon - IP: %s", status.IPAddress)
+
+				// Emit connection established event
+				eventbus.Emit(eventbus.EventConnectionEstablished, "Manager", eventbus.ConnectionEventData{
+					ProfileID:   profileID,
+					ProfileName: conn.Profile.Name,
+					IPAddress:   status.IPAddress,
+				})
+
+				// Enable post-connection features
+				conn.mu.Unlock()
+				m.enablePostConnectionFeatures(conn)
+				conn.mu.Lock()
+			}
+
+		case "disconnected", "error":
+			if wasConnected || status.Status == "error" {
+				conn.Status = StatusDisconnected
+				if status.LastError != "" {
+					conn.LastError = status.LastError
+					conn.Status = StatusError
+				}
+				lockIface := conn.tunIface
+				lockServers := conn.serverIPs
+				conn.mu.Unlock()
+
+				// Network lock: an established tunnel dropped without the user
+				// asking to disconnect. In Auto mode the kill switch stays out
+				// of the way while connected, so engage the block now to stop
+				// traffic leaking in the clear until the VPN comes back. Use
+				// Enable (block everything EXCEPT the tunnel and the VPN server)
+				// — NOT block-all — so the server the VPN must reconnect to stays
+				// reachable; otherwise the lock would strand the user. Always
+				// mode is already blocking; Off does nothing.
+				if m.killSwitch != nil &&
+					shouldEngageNetworkLock(wasConnected, conn.userDisconnect.Load(), m.killSwitch.GetMode()) {
+					if err := m.killSwitch.EnableForServers(lockIface, lockServers); err != nil {
+						logger.LogWarn("killswitch", "failed to engage network lock after drop: %v", err)
+					} else {
+						logger.LogWarn("killswitch", "VPN tunnel dropped — network locked (VPN server still reachable for reconnect; Auto kill switch)")
+					}
+				}
+
+				// Emit disconnection event
+				eventbus.Emit(eventbus.EventConnectionClosed, "Manager", eventbus.ConnectionEventData{
+					ProfileID: profileID,
+				})
+
+				// Clean up
+				m.mu.Lock()
+				delete(m.connections, profileID)
+				m.mu.Unlock()
+				return true
+			}
+
+		case "connecting":
+			conn.Status = StatusConnecting
+		}
+		conn.mu.Unlock()
+		return false
+	}
+
 	for {
 		select {
 		case <-conn.stopChan:
 			// Disconnection requested - just exit, Disconnect() handles the daemon call
 			return
 
+		case ev, ok := <-events:
+			if !ok {
+				// Event stream lost (daemon restarted); poll until we're done
+				events = nil
+				ticker.Reset(daemonPollInterval)
+				continue
+			}
+			var status daemon.OpenVPNStatusResult
+			if err := ev.UnmarshalData(&status); err != nil || status.ProfileID != profileID {
+				continue
+			}
+			if apply(&status) {
+				return
+			}
+
 		case <-ticker.C:
 			status, err := client.Status(profileID)
 			if err != nil {
 				logger.LogDebug("vpn", "Error getting daemon status: %v", err)
 				continue
 			}
+			if apply(status) {
+				return
+			}
+		}
+	}
+}
 
-			conn.mu.Lock()
-			switch status.Status {
-			case "connected":
-				if !wasConnected {
-					conn.Status = StatusConnected
-					conn.IPAddress = status.IPAddress
-					wasConnected = true
-					logger.LogInfo("vpn", "Connected via daemon - IP: %s", status.IPAddress)
-
-					// Emit connection established event
-					eventbus.Emit(eventbus.EventConnectionEstablished, "Manager", eventbus.ConnectionEventData{
-						ProfileID:   profileID,
-						ProfileName: conn.Profile.Name,
-						IPAddress:   status.IPAddress,
-					})
-
-					// Enable post-connection features
-					conn.mu.Unlock()
-					m.enablePostConnectionFeatures(conn)
-					conn.mu.Lock()
-				}
-
-			case "disconnected", "error":
-				if wasConnected || status.Status == "error" {
-					conn.Status = StatusDisconnected
-					if status.LastError != "" {
-						conn.LastError = status.LastError
-						conn.Status = StatusError
-					}
-					lockIface := conn.tunIface
-					lockServer := conn.serverIP
-					conn.mu.Unlock()
-
-					// Network lock: an established tunnel dropped without the user
-					// asking to disconnect. In Auto mode the kill switch stays out
-					// of the way while connected, so engage the block now to stop
-					// traffic leaking in the clear until the VPN comes back. Use
-					// Enable (block everything EXCEPT the tunnel and the VPN server)
-					// — NOT block-all — so the server the VPN must reconnect to stays
-					// reachable; otherwise the lock would strand the user. Always
-					// mode is already blocking; Off does nothing.
-					if m.killSwitch != nil &&
-						shouldEngageNetworkLock(wasConnected, conn.userDisconnect.Load(), m.killSwitch.GetMode()) {
-						if err := m.killSwitch.Enable(lockIface, lockServer); err != nil {
-							logger.LogWarn("killswitch", "failed to engage network lock after drop: %v", err)
-						} else {
-							logger.LogWarn("killswitch", "VPN tunnel dropped — network locked (VPN server still reachable for reconnect; Auto kill switch)")
-						}
-					}
-
-					// Emit disconnection event
-					eventbus.Emit(eventbus.EventConnectionClosed, "Manager", eventbus.ConnectionEventData{
-						ProfileID: profileID,
-					})
+// noteChallenge records the two-factor challenge a daemon status carries and,
+// when it is a new one, asks for the response with EventAuthOTPRequired.
+func (m *Manager) noteChallenge(conn *Connection, challenge *daemon.OpenVPNChallenge) {
+	conn.mu.Lock()
+	prev := conn.challenge
+	conn.challenge = challenge
+	profileID := conn.Profile.ID
+	profileName := conn.Profile.Name
+	conn.mu.Unlock()
 
-					// Clean up
-					m.mu.Lock()
-					delete(m.connections, profileID)
-					m.mu.Unlock()
-					return
-				}
+	if challenge == nil || (prev != nil && *prev == *challenge) {
+		return
+	}
+	logger.LogInfo("vpn", "Server of %s asks for a response: %s", profileName, challenge.Text)
+	eventbus.Emit(eventbus.EventAuthOTPRequired, "Manager", eventbus.AuthChallengeData{
+		ProfileID:        profileID,
+		ProfileName:      profileName,
+		Text:             challenge.Text,
+		Echo:             challenge.Echo,
+		ResponseRequired: challenge.ResponseRequired,
+	})
+}
 
-			case "connecting":
-				conn.Status = StatusConnecting
-			}
-			conn.mu.Unlock()
-		}
+// RespondToChallenge answers the two-factor challenge a connecting OpenVPN
+// profile's server asked (announced by EventAuthOTPRequired). The daemon
+// encodes the response as the server expects, so it is the bare code or PIN.
+func (m *Manager) RespondToChallenge(profileID, response string) error {
+	client := &daemon.OpenVPNClient{}
+	if err := client.Respond(profileID, response); err != nil {
+		return err
+	}
+	if conn, ok := m.GetConnection(profileID); ok {
+		conn.mu.Lock()
+		conn.challenge = nil
+		conn.mu.Unlock()
 	}
+	return nil
 }
 
 // handleConnectionError handles connection errors
@@ -646,6 +791,44 @@ func (c *Connection) GetStatus() ConnectionStatus {
 	return c.Status
 }
 
+// GetLastError returns the last error message, set when the status is StatusError
+func (c *Connection) GetLastError() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LastError
+}
+
+// GetIPAddress returns the address assigned by the VPN server
+func (c *Connection) GetIPAddress() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.IPAddress
+}
+
+// GetChallenge returns the two-factor challenge the server is waiting on an
+// answer to, or nil.
+func (c *Connection) GetChallenge() *daemon.OpenVPNChallenge {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.challenge
+}
+
+// Ready returns a channel that is closed once the connection is established and
+// the post-connection features (kill switch, DNS and IPv6 protection, stats)
+// have been applied. Headless callers wait on it before exiting, so the process
+// does not end halfway through arming the protections.
+func (c *Connection) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// markReady closes the Ready channel. Safe to call more than once (the
+// connection may come back after an adopted reconnect).
+func (c *Connection) markReady() {
+	if c.ready != nil {
+		c.readyOnce.Do(func() { close(c.ready) })
+	}
+}
+
 // GetUptime returns the connection uptime
 func (c *Connection) GetUptime() time.Duration {
 	if c.Status != StatusConnected {
@@ -707,47 +890,130 @@ func (c *Connection) UpdateStats() bool {
 	return true
 }
 
-// getVPNServerIP extracts the VPN server IP from the profile config
-func (m *Manager) getVPNServerIP(prof *profile.Profile) string {
+// getVPNServerIPs resolves every remote of the profile config to all of its
+// addresses, IPv4 and IPv6, in config order and without duplicates: openvpn
+// tries each address a remote resolves to, so the kill switch must allow them
+// all. Remotes that do not resolve are skipped.
+func (m *Manager) getVPNServerIPs(prof *profile.Profile) []string {
 	if prof == nil || prof.ConfigPath == "" {
-		return ""
+		return nil
 	}
 
-	data, err := os.ReadFile(prof.ConfigPath)
+	remotes, err := profile.ParseRemotes(prof.ConfigPath)
 	if err != nil {
 		logger.LogDebug("vpn", "Failed to read config for server IP: %v", err)
-		return ""
-	}
-
-	// Look for "remote <ip/hostname> <port>" directive
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "remote ") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				// parts[1] is the server address (could be IP or hostname)
-				serverAddr := parts[1]
-				// Check if it's already an IP
-				if ip := net.ParseIP(serverAddr); ip != nil {
-					return serverAddr
-				}
-				// Try to resolve hostname
-				ips, err := net.LookupIP(serverAddr)
-				if err == nil && len(ips) > 0 {
-					// Prefer IPv4
-					for _, ip := range ips {
-						if ip.To4() != nil {
-							return ip.String()
-						}
-					}
-					return ips[0].String()
-				}
-				logger.LogDebug("vpn", "Could not resolve server hostname: %s", serverAddr)
-				return serverAddr // Return hostname as fallback
+		return nil
+	}
+
+	var ips []string
+	for _, remote := range remotes.Servers {
+		resolved := resolveServerIPs(remote.Host, net.LookupIP)
+		if len(resolved) == 0 {
+			logger.LogDebug("vpn", "Could not resolve server hostname: %s", remote.Host)
+			continue
+		}
+		for _, ip := range resolved {
+			if !slices.Contains(ips, ip) {
+				ips = append(ips, ip)
 			}
 		}
 	}
+	return ips
+}
 
+// resolveServerIPs returns host if it is an IP, or else every address lookup
+// resolves it to, IPv4 first.
+func resolveServerIPs(host string, lookup func(string) ([]net.IP, error)) []string {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}
+	}
+	resolved, err := lookup(host)
+	if err != nil {
+		return nil
+	}
+	var v4, v6 []string
+	for _, ip := range resolved {
+		if ip.To4() != nil {
+			v4 = append(v4, ip.String())
+		} else {
+			v6 = append(v6, ip.String())
+		}
+	}
+	return append(v4, v6...)
+}
+
+// connectedServerIP returns the IP of the server the tunnel came up on,
+// taken from the daemon's remote address, falling back to the first remote.
+func connectedServerIP(remoteAddress string, serverIPs []string) string {
+	host := remoteAddress
+	if h, _, err := net.SplitHostPort(remoteAddress); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if len(serverIPs) > 0 {
+		return serverIPs[0]
+	}
 	return ""
 }
+
+// fastestRemote picks the remote of prof with the lowest latency, for
+// openvpn to try first. It returns nil, leaving openvpn to its config order,
+// when there is nothing to choose between, when openvpn would not honour the
+// choice (<connection> blocks carry options of their own, remote-random
+// shuffles anyway), or when no remote answers the probe.
+func fastestRemote(prof *profile.Profile) *daemon.OpenVPNRemote {
+	remotes, err := profile.ParseRemotes(prof.ConfigPath)
+	if err != nil || len(remotes.Servers) < 2 || remotes.Random || remotes.ConnectionBlocks {
+		return nil
+	}
+
+	best, latency, ok := selectFastestRemote(remotes.Servers, measureRemoteLatency)
+	if !ok {
+		logger.LogDebug("vpn", "No remote of %s answered the latency probe; using the config order", prof.Name)
+		return nil
+	}
+	logger.LogInfo("vpn", "Fastest remote of %s: %s:%d/%s (%v)", prof.Name, best.Host, best.Port, best.Proto, latency.Round(time.Millisecond))
+	return &daemon.OpenVPNRemote{Host: best.Host, Port: best.Port, Proto: best.Proto}
+}
+
+// selectFastestRemote probes every remote concurrently with measure and
+// returns the one with the lowest latency; ties go to the earlier remote.
+// ok is false if no probe succeeded.
+func selectFastestRemote(remotes []profile.Remote, measure func(profile.Remote) (time.Duration, error)) (best profile.Remote, latency time.Duration, ok bool) {
+	latencies := make([]time.Duration, len(remotes))
+	errs := make([]error, len(remotes))
+	var wg sync.WaitGroup
+	for i, remote := range remotes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latencies[i], errs[i] = measure(remote)
+		}()
+	}
+	wg.Wait()
+
+	for i, remote := range remotes {
+		if errs[i] != nil {
+			logger.LogDebug("vpn", "Latency probe of %s failed: %v", remote.Host, errs[i])
+			continue
+		}
+		if !ok || latencies[i] < latency {
+			best, latency, ok = remote, latencies[i], true
+		}
+	}
+	return best, latency, ok
+}
+
+// measureRemoteLatency measures the round trip to a remote with a TCP
+// connect: to the remote's own port when it speaks TCP, else (and when that
+// fails) to the common ports MeasureLatencyWithFallback tries.
+func measureRemoteLatency(remote profile.Remote) (time.Duration, error) {
+	if strings.HasPrefix(remote.Proto, "tcp") {
+		if latency, err := network.MeasureLatency(remote.Host, remote.Port, RemoteProbeTimeout); err == nil {
+			return latency, nil
+		}
+	}
+	return network.MeasureLatencyWithFallback(remote.Host, RemoteProbeTimeout)
+}
@@ -1,8 +1,13 @@
 package vpn
 
 import (
+	"errors"
+	"net"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
 )
 
 func TestConnectionStatus_String(t *testing.T) {
@@ -110,3 +115,78 @@ func TestConnection_GetStatus(t *testing.T) {
 		t.Errorf("GetStatus() = %v, want %v", conn.GetStatus(), StatusConnected)
 	}
 }
+
+func TestSelectFastestRemote(t *testing.T) {
+	remotes := []profile.Remote{
+		{Host: "vpn1.example.com", Port: 1194, Proto: "udp"},
+		{Host: "vpn2.example.com", Port: 443, Proto: "tcp"},
+		{Host: "vpn3.example.com", Port: 1194, Proto: "udp"},
+		{Host: "vpn4.example.com", Port: 1194, Proto: "udp"},
+	}
+	latencies := map[string]time.Duration{
+		"vpn1.example.com": 80 * time.Millisecond,
+		"vpn2.example.com": 20 * time.Millisecond,
+		"vpn4.example.com": 20 * time.Millisecond,
+	}
+	measure := func(r profile.Remote) (time.Duration, error) {
+		if l, ok := latencies[r.Host]; ok {
+			return l, nil
+		}
+		return 0, errors.New("unreachable")
+	}
+
+	best, latency, ok := selectFastestRemote(remotes, measure)
+	if !ok || best != remotes[1] || latency != 20*time.Millisecond {
+		t.Errorf("selectFastestRemote = %+v, %v, %v; want vpn2 (the earlier of the tie) at 20ms", best, latency, ok)
+	}
+
+	latencies = nil
+	if _, _, ok := selectFastestRemote(remotes, measure); ok {
+		t.Error("selectFastestRemote chose a remote although every probe failed")
+	}
+}
+
+func TestResolveServerIPs(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		if host != "vpn.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, nil
+	}
+
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"vpn.example.com", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}},
+		{"198.51.100.7", []string{"198.51.100.7"}},
+		{"2001:db8::9", []string{"2001:db8::9"}},
+		{"unknown.example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := resolveServerIPs(tt.host, lookup); !slices.Equal(got, tt.want) {
+			t.Errorf("resolveServerIPs(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestConnectedServerIP(t *testing.T) {
+	ips := []string{"198.51.100.1", "198.51.100.2"}
+	tests := []struct {
+		remoteAddress string
+		want          string
+	}{
+		{"198.51.100.2:1194", "198.51.100.2"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"198.51.100.3", "198.51.100.3"},
+		{"", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		if got := connectedServerIP(tt.remoteAddress, ips); got != tt.want {
+			t.Errorf("connectedServerIP(%q) = %q, want %q", tt.remoteAddress, got, tt.want)
+		}
+	}
+	if got := connectedServerIP("", nil); got != "" {
+		t.Errorf("connectedServerIP without remotes = %q, want empty", got)
+	}
+}
@@ -65,6 +65,12 @@ const (
 
 	// StatusCheckInterval is the interval for checking connection status.
 	StatusCheckInterval = 1 * time.Second
+
+	// RemoteProbeTimeout bounds each TCP connect of the latency probe that
+	// picks the fastest remote (Profile.PreferFastestRemote). Remotes are
+	// probed concurrently, so the probe delays a connect by a few of these at
+	// most.
+	RemoteProbeTimeout = 1 * time.Second
 )
 
 // =============================================================================
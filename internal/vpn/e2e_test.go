@@ -0,0 +1,214 @@
+package vpn
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/daemon/daemontest"
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/eventbus"
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
+	"github.com/yllada/vpn-manager/pkg/protocol"
+)
+
+// TestConnectEndToEnd connects a profile through Manager.Connect against an
+// in-process daemon: the daemon stages the config and starts a fake openvpn,
+// and the manager follows it to Connected with the tunnel's address, then
+// disconnects it again.
+func TestConnectEndToEnd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // isolate profile storage from the real config
+	d := daemontest.Start(t)
+	daemon.SetSocketPath(d.SocketPath)
+	t.Cleanup(func() { daemon.SetSocketPath(protocol.DefaultSocketPath) })
+
+	m, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	config := filepath.Join(t.TempDir(), "work.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote 192.0.2.10 1194\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ProfileManager().Add(&profile.Profile{ID: "work", Name: "Work", ConfigPath: config}); err != nil {
+		t.Fatalf("Add profile: %v", err)
+	}
+
+	if err := m.Connect("work", "alice", "secret"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, ok := m.GetConnection("work")
+		if !ok {
+			t.Fatal("connection vanished")
+		}
+		conn.mu.Lock()
+		status, ip, lastErr := conn.Status, conn.IPAddress, conn.LastError
+		conn.mu.Unlock()
+		if status == StatusConnected {
+			if ip != daemontest.DefaultOpenVPNAddress {
+				t.Errorf("IP = %q, want %q", ip, daemontest.DefaultOpenVPNAddress)
+			}
+			break
+		}
+		if status == StatusError || time.Now().After(deadline) {
+			t.Fatalf("not connected: status %v, error %q", status, lastErr)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	calls := d.Exec.Calls("openvpn")
+	if len(calls) != 1 || !slices.Contains(calls[0].Args, "--auth-user-pass") {
+		t.Errorf("openvpn invocations = %v, want one with --auth-user-pass", calls)
+	}
+
+	if err := m.Disconnect("work"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	if _, ok := m.GetConnection("work"); ok {
+		t.Error("connection still registered after Disconnect")
+	}
+}
+
+// TestStaticChallengeEndToEnd connects a static-challenge profile: the fake
+// openvpn asks for the password with a challenge, the manager announces it
+// with EventAuthOTPRequired, and the response reaches openvpn as SCRV1.
+func TestStaticChallengeEndToEnd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	d := daemontest.Start(t)
+	daemon.SetSocketPath(d.SocketPath)
+	t.Cleanup(func() { daemon.SetSocketPath(protocol.DefaultSocketPath) })
+
+	ip := daemontest.DefaultOpenVPNAddress
+	resp := daemontest.OpenVPNConnected(ip)
+	resp.Management = []string{
+		">STATE:,CONNECTING,,,,,,",
+		">PASSWORD:Need 'Auth' username/password SC:0,Enter your token code",
+	}
+	// Only the SCRV1 encoding of "secret" and "123456" connects.
+	resp.Replies = map[string][]string{
+		`password "Auth" "SCRV1:c2VjcmV0:MTIzNDU2"`: {
+			">STATE:,CONNECTED,SUCCESS," + ip + "," + daemontest.OpenVPNServerAddress + ",1194,,",
+		},
+	}
+	d.Exec.Script(resp, "openvpn")
+
+	asked := make(chan eventbus.AuthChallengeData, 1)
+	sub := eventbus.On(eventbus.EventAuthOTPRequired, func(ev *eventbus.Event) {
+		if data, ok := ev.Data.(eventbus.AuthChallengeData); ok && data.ProfileID == "token" {
+			asked <- data
+		}
+	})
+	t.Cleanup(sub.Unsubscribe)
+
+	m, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	config := filepath.Join(t.TempDir(), "token.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote 192.0.2.10 1194\nauth-user-pass\nstatic-challenge \"Enter your token code\" 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ProfileManager().Add(&profile.Profile{ID: "token", Name: "Token", ConfigPath: config}); err != nil {
+		t.Fatalf("Add profile: %v", err)
+	}
+	if err := m.Connect("token", "alice", "secret"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	select {
+	case data := <-asked:
+		if data.Text != "Enter your token code" || !data.ResponseRequired || data.Echo {
+			t.Errorf("challenge = %+v", data)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the challenge was never announced")
+	}
+	if err := m.RespondToChallenge("token", "123456"); err != nil {
+		t.Fatalf("RespondToChallenge: %v", err)
+	}
+
+	conn, _ := m.GetConnection("token")
+	select {
+	case <-conn.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("not connected after the response: status %v, error %q", conn.GetStatus(), conn.GetLastError())
+	}
+	if conn.GetChallenge() != nil {
+		t.Errorf("challenge %+v still pending once connected", conn.GetChallenge())
+	}
+	if err := m.Disconnect("token"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+}
+
+// TestAuthTokenReconnectEndToEnd reconnects an OTP profile the way the health
+// checker does: the server pushed an auth-token on the first connection, so
+// the second one connects with it and no new code.
+func TestAuthTokenReconnectEndToEnd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	d := daemontest.Start(t)
+	daemon.SetSocketPath(d.SocketPath)
+	t.Cleanup(func() { daemon.SetSocketPath(protocol.DefaultSocketPath) })
+
+	ip := daemontest.DefaultOpenVPNAddress
+	connected := ">STATE:,CONNECTED,SUCCESS," + ip + "," + daemontest.OpenVPNServerAddress + ",1194,,"
+	resp := daemontest.OpenVPNConnected(ip)
+	resp.Management = []string{
+		">STATE:,CONNECTING,,,,,,",
+		">PASSWORD:Need 'Auth' username/password",
+	}
+	// The password with the one-time code gets a token; the token connects.
+	resp.Replies = map[string][]string{
+		`password "Auth" "secret123456"`:   {">PASSWORD:Auth-Token:SESS_ID_AT_tok", connected},
+		`password "Auth" "SESS_ID_AT_tok"`: {connected},
+	}
+	d.Exec.Script(resp, "openvpn")
+
+	m, err := NewManager()
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	config := filepath.Join(t.TempDir(), "otp.ovpn")
+	if err := os.WriteFile(config, []byte("client\ndev tun\nremote 192.0.2.10 1194\nauth-user-pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ProfileManager().Add(&profile.Profile{ID: "otp", Name: "OTP", ConfigPath: config, RequiresOTP: true}); err != nil {
+		t.Fatalf("Add profile: %v", err)
+	}
+	if m.HasAuthToken("otp") {
+		t.Error("a token is reported before the server pushed one")
+	}
+
+	waitReady := func() {
+		t.Helper()
+		conn, _ := m.GetConnection("otp")
+		select {
+		case <-conn.Ready():
+		case <-time.After(10 * time.Second):
+			t.Fatalf("not connected: status %v, error %q", conn.GetStatus(), conn.GetLastError())
+		}
+	}
+	if err := m.Connect("otp", "alice", "secret123456"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	waitReady()
+	if !m.HasAuthToken("otp") {
+		t.Fatal("the pushed token is not reported")
+	}
+
+	if err := m.Disconnect("otp"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	if err := m.ConnectWithAuthToken("otp"); err != nil {
+		t.Fatalf("ConnectWithAuthToken: %v", err)
+	}
+	waitReady()
+	if err := m.Disconnect("otp"); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+}
@@ -327,6 +327,16 @@ func (c *Checker) attemptReconnect(profileID string) {
 	profile := conn.Profile
 	password := ""
 
+	// A server that pushed an auth-token takes it instead of the password and
+	// any one-time code or challenge response, so nobody has to be asked.
+	if tokens, ok := c.provider.(AuthTokenProvider); ok && tokens.HasAuthToken(profile.ID) {
+		logger.LogInfo("Reconnecting %s with its auth-token", profile.Name)
+		c.reconnect(profileID, profile.Name, func() error {
+			return tokens.ConnectWithAuthToken(profile.ID)
+		})
+		return
+	}
+
 	// Check if profile requires OTP - cannot auto-reconnect with expired OTP codes
 	if profile.RequiresOTP {
 		logger.LogInfo("Profile %s requires OTP - requesting user input for reconnection", profile.Name)
@@ -380,17 +390,25 @@ func (c *Checker) attemptReconnect(profileID string) {
 		return
 	}
 
+	c.reconnect(profileID, profile.Name, func() error {
+		return c.provider.Connect(profile.ID, profile.Username, password)
+	})
+}
+
+// reconnect disconnects profileID and connects it again with connect,
+// scheduling another attempt if that fails.
+func (c *Checker) reconnect(profileID, profileName string, connect func() error) {
 	// Disconnect first
-	if err := c.provider.Disconnect(profile.ID); err != nil {
+	if err := c.provider.Disconnect(profileID); err != nil {
 		logger.LogError("Failed to disconnect before reconnect: %v", err)
 	}
 
 	// Small delay after disconnect
 	time.Sleep(c.config.PostDisconnectDelay)
 
-	// Attempt to reconnect with retrieved credentials
-	if err := c.provider.Connect(profile.ID, profile.Username, password); err != nil {
-		logger.LogError("Reconnect failed for %s: %v", profile.Name, err)
+	// Attempt to reconnect
+	if err := connect(); err != nil {
+		logger.LogError("Reconnect failed for %s: %v", profileName, err)
 
 		c.mu.Lock()
 		currentHealth, ok := c.connectionHealth[profileID]
@@ -405,11 +423,11 @@ func (c *Checker) attemptReconnect(profileID string) {
 			})
 		} else {
 			if c.onReconnectFailed != nil {
-				c.onReconnectFailed(profile.ID, err)
+				c.onReconnectFailed(profileID, err)
 			}
 		}
 	} else {
-		logger.LogInfo("Reconnect successful for %s", profile.Name)
+		logger.LogInfo("Reconnect successful for %s", profileName)
 	}
 }
 
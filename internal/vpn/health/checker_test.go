@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"github.com/yllada/vpn-manager/internal/vpn/profile"
 )
 
 // recordingProbe captures the host it was asked to probe and always succeeds.
@@ -290,3 +292,66 @@ func (m *mockConnectionProvider) Connect(_, _, _ string) error {
 func (m *mockConnectionProvider) Disconnect(_ string) error {
 	return nil
 }
+
+// tokenProvider is a provider for one connected OTP profile whose server may
+// have pushed an auth-token.
+type tokenProvider struct {
+	mockConnectionProvider
+	hasToken       bool
+	tokenConnects  int
+	plainConnects  int
+	disconnections int
+}
+
+func (p *tokenProvider) GetConnection(id string) (*ConnectionInfo, bool) {
+	return &ConnectionInfo{
+		ProfileID:   id,
+		ProfileName: "Work",
+		Status:      StatusConnected,
+		Profile:     &profile.Profile{ID: id, Name: "Work", RequiresOTP: true},
+	}, true
+}
+
+func (p *tokenProvider) Connect(_, _, _ string) error {
+	p.plainConnects++
+	return nil
+}
+
+func (p *tokenProvider) Disconnect(_ string) error {
+	p.disconnections++
+	return nil
+}
+
+func (p *tokenProvider) HasAuthToken(_ string) bool { return p.hasToken }
+
+func (p *tokenProvider) ConnectWithAuthToken(_ string) error {
+	p.tokenConnects++
+	return nil
+}
+
+// TestChecker_ReconnectWithAuthToken verifies an OTP profile whose server
+// pushed an auth-token is reconnected with it, and that without one the user
+// is asked for a code as before.
+func TestChecker_ReconnectWithAuthToken(t *testing.T) {
+	for _, hasToken := range []bool{true, false} {
+		provider := &tokenProvider{hasToken: hasToken}
+		config := DefaultConfig()
+		config.ReconnectDelay = 0
+		config.PostDisconnectDelay = 0
+		c := NewChecker(provider, config)
+		c.connectionHealth["p1"] = &ConnectionHealth{ProfileID: "p1"}
+		otpRequests := 0
+		c.SetOnOTPRequired(func(string, string, string) { otpRequests++ })
+
+		c.attemptReconnect("p1")
+
+		if hasToken && (provider.tokenConnects != 1 || provider.disconnections != 1 || otpRequests != 0) {
+			t.Errorf("with a token: %d token connects, %d disconnects, %d OTP requests; want 1, 1, 0",
+				provider.tokenConnects, provider.disconnections, otpRequests)
+		}
+		if !hasToken && (provider.tokenConnects != 0 || provider.plainConnects != 0 || otpRequests != 1) {
+			t.Errorf("without a token: %d token connects, %d connects, %d OTP requests; want 0, 0, 1",
+				provider.tokenConnects, provider.plainConnects, otpRequests)
+		}
+	}
+}
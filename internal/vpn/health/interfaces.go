@@ -72,6 +72,17 @@ type ConnectionProvider interface {
 	Disconnect(profileID string) error
 }
 
+// AuthTokenProvider is implemented by providers that can reconnect an
+// OpenVPN profile with the auth-token its server pushed, so a profile that
+// requires OTP comes back without asking the user for a new code.
+type AuthTokenProvider interface {
+	// HasAuthToken reports whether the profile can reconnect with a token.
+	HasAuthToken(profileID string) bool
+
+	// ConnectWithAuthToken initiates a VPN connection using the token.
+	ConnectWithAuthToken(profileID string) error
+}
+
 // State represents the health state of a connection.
 type State int
 
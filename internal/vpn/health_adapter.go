@@ -35,5 +35,18 @@ func (a *HealthAdapter) Disconnect(profileID string) error {
 	return a.manager.Disconnect(profileID)
 }
 
+// HasAuthToken implements health.AuthTokenProvider.
+func (a *HealthAdapter) HasAuthToken(profileID string) bool {
+	return a.manager.HasAuthToken(profileID)
+}
+
+// ConnectWithAuthToken implements health.AuthTokenProvider.
+func (a *HealthAdapter) ConnectWithAuthToken(profileID string) error {
+	return a.manager.ConnectWithAuthToken(profileID)
+}
+
 // Verify interface compliance at compile time.
-var _ health.ConnectionProvider = (*HealthAdapter)(nil)
+var (
+	_ health.ConnectionProvider = (*HealthAdapter)(nil)
+	_ health.AuthTokenProvider  = (*HealthAdapter)(nil)
+)
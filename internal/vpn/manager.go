@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/yllada/vpn-manager/internal/daemon"
 	"github.com/yllada/vpn-manager/internal/errors"
 	"github.com/yllada/vpn-manager/internal/logger"
 	"github.com/yllada/vpn-manager/internal/resilience"
@@ -76,6 +77,19 @@ type Connection struct {
 	// reconnect, stranding the user. (Guarded by mu.)
 	tunIface string
 	serverIP string
+	// serverIPs are the addresses of every remote of the profile, all of them
+	// allowed through the kill switch so openvpn can fail over between them;
+	// serverIP is the one the tunnel came up on. remoteAddress is that
+	// server as the daemon reported it (host:port). (Guarded by mu.)
+	serverIPs     []string
+	remoteAddress string
+	// ready is closed once the connection is up and its post-connection
+	// features are applied (see Ready).
+	ready     chan struct{}
+	readyOnce sync.Once
+	// challenge is the two-factor question the server is waiting on, if any
+	// (see RespondToChallenge). Guarded by mu.
+	challenge *daemon.OpenVPNChallenge
 }
 
 // Manager orchestrates VPN connections.
@@ -88,6 +102,7 @@ type Manager struct {
 	// through Manager.Connect. Unified with connections by ActiveConnections().
 	otherConns    map[string]ActiveConnection
 	healthChecker *health.Checker
+	expiryChecker *profile.ExpiryChecker
 	killSwitch       *security.KillSwitch
 	appTunnel        *tunnel.AppTunnel
 	providerRegistry *vpntypes.ProviderRegistry
@@ -103,6 +118,10 @@ type Manager struct {
 	// Traffic statistics
 	statsManager *stats.StatsManager
 
+	// Session agent that owns the OpenVPN connections (nil = this Manager
+	// owns them; see agent.go)
+	agent sessionAgent
+
 	mu sync.RWMutex
 }
 
@@ -130,6 +149,9 @@ func NewManager() (*Manager, error) {
 	// Use HealthAdapter to implement health.ConnectionProvider interface
 	m.healthChecker = health.NewChecker(NewHealthAdapter(m), health.DefaultConfig())
 
+	// Certificate expiry warnings for the profiles' PKI material
+	m.expiryChecker = profile.NewExpiryChecker(pm)
+
 	// Initialize traffic statistics (non-fatal if it fails)
 	if statsManager, err := stats.NewStatsManager(""); err != nil {
 		logger.LogWarn("vpn", "Failed to initialize stats manager: %v (traffic statistics will be unavailable)", err)
@@ -158,26 +180,40 @@ func (m *Manager) registerShutdownHooks() {
 		return nil
 	})
 
-	// Disconnect all VPNs first
+	// Disconnect all VPNs first. In agent mode the connections and the
+	// protections belong to the session agent and outlive this process, so
+	// this and the restore hooks below leave them alone.
 	sm.Register("vpn-disconnect-all", shutdown.PriorityFirst, func(ctx context.Context) error {
+		if m.AgentMode() {
+			return nil
+		}
 		logger.LogInfo("Shutdown: Disconnecting all VPN connections")
 		return m.DisconnectAll()
 	})
 
 	// Restore DNS settings
 	sm.Register("dns-restore", shutdown.PriorityLow, func(ctx context.Context) error {
+		if m.AgentMode() {
+			return nil
+		}
 		logger.LogInfo("Shutdown: Restoring DNS settings")
 		return m.dnsProtection.Disable()
 	})
 
 	// Restore IPv6 settings
 	sm.Register("ipv6-restore", shutdown.PriorityLow, func(ctx context.Context) error {
+		if m.AgentMode() {
+			return nil
+		}
 		logger.LogInfo("Shutdown: Restoring IPv6 settings")
 		return m.ipv6Protection.Disable()
 	})
 
 	// Disable kill switch
 	sm.Register("killswitch-disable", shutdown.PriorityLow, func(ctx context.Context) error {
+		if m.AgentMode() {
+			return nil
+		}
 		logger.LogInfo("Shutdown: Disabling kill switch")
 		return m.killSwitch.Disable()
 	})
@@ -188,6 +224,12 @@ func (m *Manager) registerShutdownHooks() {
 		return nil
 	})
 
+	// Stop certificate expiry checker
+	sm.Register("expiry-checker-stop", shutdown.PriorityNormal, func(ctx context.Context) error {
+		m.StopExpiryChecker()
+		return nil
+	})
+
 	// Close stats manager
 	sm.Register("stats-close", shutdown.PriorityLow, func(ctx context.Context) error {
 		if m.statsManager != nil {
@@ -227,6 +269,21 @@ func (m *Manager) StopHealthChecker() {
 	}
 }
 
+// StartExpiryChecker starts the periodic check of the profiles' certificates,
+// which emits EventCertificateExpiring as they near their expiry.
+func (m *Manager) StartExpiryChecker() {
+	if m.expiryChecker != nil {
+		m.expiryChecker.Start()
+	}
+}
+
+// StopExpiryChecker stops the certificate expiry checks.
+func (m *Manager) StopExpiryChecker() {
+	if m.expiryChecker != nil {
+		m.expiryChecker.Stop()
+	}
+}
+
 // ProfileManager returns the associated profile manager.
 func (m *Manager) ProfileManager() *profile.ProfileManager {
 	return m.profileManager
@@ -0,0 +1,221 @@
+// Package profile provides VPN connection profile management functionality.
+// This file inspects the PKI material of OpenVPN configs: the CA and client
+// certificates and the tls-crypt key, inline or in referenced files.
+package profile
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pkiDirectives are the config directives whose material is inspected, as
+// inline blocks (<ca>...</ca>) or file arguments (ca ca.crt).
+var pkiDirectives = map[string]bool{"ca": true, "cert": true, "tls-crypt": true}
+
+// Certificate describes a piece of PKI material found in an OpenVPN config.
+type Certificate struct {
+	// Source is the directive it came from: "ca", "cert" or "tls-crypt".
+	Source string `json:"source" yaml:"source"`
+	// File is the referenced file, empty for an inline block.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Subject and Issuer are the certificate's distinguished names.
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer  string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// Fingerprint is the SHA-256 of the DER certificate (or of the static
+	// key), as colon-separated hex.
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"`
+	NotBefore   time.Time `json:"not_before,omitzero" yaml:"not_before,omitempty"`
+	NotAfter    time.Time `json:"not_after,omitzero" yaml:"not_after,omitempty"`
+	// StaticKey marks a tls-crypt key, which has no subject or expiry.
+	StaticKey bool `json:"static_key,omitempty" yaml:"static_key,omitempty"`
+}
+
+// Expires reports whether the material has an expiry date.
+func (c Certificate) Expires() bool {
+	return !c.NotAfter.IsZero()
+}
+
+// Name is a short label for the material, for messages.
+func (c Certificate) Name() string {
+	if c.StaticKey {
+		return c.Source + " key"
+	}
+	what := "client certificate"
+	if c.Source == "ca" {
+		what = "CA certificate"
+	}
+	if c.Subject != "" {
+		return what + " " + c.Subject
+	}
+	return what
+}
+
+// InspectCertificates lists the certificates and keys of an OpenVPN config:
+// every certificate of the <ca> and <cert> blocks or files (a CA file may
+// hold a chain) and the <tls-crypt> key. Files are resolved relative to the
+// config's directory. Material that cannot be read or parsed is skipped, so
+// only a config that cannot be read is an error.
+func InspectCertificates(configPath string) ([]Certificate, error) {
+	return inspectCertificates(configPath, filepath.Dir(configPath))
+}
+
+// inspectCertificates is InspectCertificates with relative files resolved
+// against dir.
+func inspectCertificates(configPath, dir string) ([]Certificate, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		certs []Certificate
+		block string // directive of the inline block being read
+		body  strings.Builder
+	)
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if block != "" {
+			if strings.EqualFold(line, "</"+block+">") {
+				certs = append(certs, parsePKI(block, "", []byte(body.String()))...)
+				block = ""
+				body.Reset()
+				continue
+			}
+			body.WriteString(line + "\n")
+			continue
+		}
+
+		lower := strings.ToLower(line)
+		if name, ok := strings.CutPrefix(lower, "<"); ok && strings.HasSuffix(name, ">") {
+			if name = strings.TrimSuffix(name, ">"); pkiDirectives[name] {
+				block = name
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !pkiDirectives[fields[0]] || fields[1] == "[inline]" {
+			continue
+		}
+		file := firstConfigArg(strings.TrimSpace(strings.TrimPrefix(line, fields[0])))
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if material, err := os.ReadFile(file); err == nil {
+			certs = append(certs, parsePKI(fields[0], file, material)...)
+		}
+	}
+	return certs, nil
+}
+
+// currentCertificates reads the certificates of p's config as they are now,
+// with relative files resolved against the directory it was imported from. ok
+// is false if the config cannot be read.
+func (p *Profile) currentCertificates() (certs []Certificate, ok bool) {
+	dir := p.ImportDir
+	if dir == "" {
+		dir = filepath.Dir(p.ConfigPath)
+	}
+	certs, err := inspectCertificates(p.ConfigPath, dir)
+	return certs, err == nil
+}
+
+// RefreshCertificates inspects the certificates of every profile again, so a
+// renewed certificate file or a replaced config shows its current expiry. A
+// profile whose config cannot be read keeps what was last seen.
+func (pm *ProfileManager) RefreshCertificates() {
+	fresh := make(map[string][]Certificate)
+	for _, p := range pm.List() {
+		if certs, ok := p.currentCertificates(); ok {
+			fresh[p.ID] = certs
+		}
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for i, p := range pm.profiles {
+		if certs, ok := fresh[p.ID]; ok {
+			// Replace rather than modify: callers of Update may still hold p.
+			updated := *p
+			updated.Certificates = certs
+			pm.profiles[i] = &updated
+		}
+	}
+}
+
+// parsePKI parses the material of one directive: the certificates of a PEM
+// bundle, or a static key.
+func parsePKI(source, file string, data []byte) []Certificate {
+	if source == "tls-crypt" {
+		key := staticKeyBytes(data)
+		if key == nil {
+			return nil
+		}
+		return []Certificate{{Source: source, File: file, Fingerprint: fingerprint(key), StaticKey: true}}
+	}
+
+	var certs []Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, Certificate{
+			Source:      source,
+			File:        file,
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			Fingerprint: fingerprint(cert.Raw),
+			NotBefore:   cert.NotBefore,
+			NotAfter:    cert.NotAfter,
+		})
+	}
+}
+
+// staticKeyBytes decodes an OpenVPN static key: hex lines between its BEGIN
+// and END markers. It returns nil if data holds none.
+func staticKeyBytes(data []byte) []byte {
+	var hexKey strings.Builder
+	inKey := false
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN OpenVPN Static key"):
+			inKey = true
+		case strings.HasPrefix(line, "-----END OpenVPN Static key"):
+			key, err := hex.DecodeString(hexKey.String())
+			if err != nil || len(key) == 0 {
+				return nil
+			}
+			return key
+		case inKey:
+			hexKey.WriteString(line)
+		}
+	}
+	return nil
+}
+
+// fingerprint formats the SHA-256 of data as colon-separated upper-case hex,
+// as openssl x509 -fingerprint prints it.
+func fingerprint(data []byte) string {
+	sum := sha256.Sum256(data)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{b}))
+	}
+	return strings.Join(parts, ":")
+}
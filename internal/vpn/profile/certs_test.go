@@ -0,0 +1,158 @@
+package profile
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertPEM returns a self-signed certificate for cn expiring at notAfter.
+func testCertPEM(t *testing.T, cn string, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+const testStaticKey = `#
+# 2048 bit OpenVPN static key
+#
+-----BEGIN OpenVPN Static key V1-----
+0123456789abcdef0123456789abcdef
+fedcba9876543210fedcba9876543210
+-----END OpenVPN Static key V1-----
+`
+
+func TestInspectCertificates(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	caChain := testCertPEM(t, "Root CA", expiry) + testCertPEM(t, "Intermediate CA", expiry)
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), []byte(caChain), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := "client\nremote vpn.example.com\n" +
+		"ca ca.crt\n" +
+		"<cert>\n" + testCertPEM(t, "alice", expiry) + "</cert>\n" +
+		"<key>\nnot inspected\n</key>\n" +
+		"<tls-crypt>\n" + testStaticKey + "</tls-crypt>\n" +
+		"cert missing.crt\n"
+	path := filepath.Join(dir, "client.ovpn")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := InspectCertificates(path)
+	if err != nil {
+		t.Fatalf("InspectCertificates: %v", err)
+	}
+	if len(certs) != 4 {
+		t.Fatalf("got %d items, want 2 CA certificates, the client certificate and the key: %+v", len(certs), certs)
+	}
+
+	for i, cn := range []string{"Root CA", "Intermediate CA"} {
+		if c := certs[i]; c.Source != "ca" || c.Subject != "CN="+cn || c.File != filepath.Join(dir, "ca.crt") {
+			t.Errorf("certs[%d] = %+v, want %s from ca.crt", i, c, cn)
+		}
+	}
+	client := certs[2]
+	if client.Source != "cert" || client.Subject != "CN=alice" || client.Issuer != "CN=alice" || client.File != "" {
+		t.Errorf("client certificate = %+v", client)
+	}
+	if !client.NotAfter.Equal(expiry) || !client.Expires() {
+		t.Errorf("client NotAfter = %v, want %v", client.NotAfter, expiry)
+	}
+	if len(client.Fingerprint) != 95 || strings.ToUpper(client.Fingerprint) != client.Fingerprint {
+		t.Errorf("fingerprint %q is not colon-separated upper-case SHA-256", client.Fingerprint)
+	}
+	if client.Name() != "client certificate CN=alice" {
+		t.Errorf("Name() = %q", client.Name())
+	}
+
+	key := certs[3]
+	if key.Source != "tls-crypt" || !key.StaticKey || key.Expires() || key.Fingerprint == "" {
+		t.Errorf("tls-crypt key = %+v", key)
+	}
+}
+
+// TestRefreshCertificates renews the certificate file an added profile
+// references by relative path and checks the new expiry is picked up, both by
+// RefreshCertificates and by Load.
+func TestRefreshCertificates(t *testing.T) {
+	pm, cleanup := setupTestProfileManager(t)
+	defer cleanup()
+
+	src := t.TempDir()
+	certPath := filepath.Join(src, "client.crt")
+	writeCert := func(notAfter time.Time) {
+		if err := os.WriteFile(certPath, []byte(testCertPEM(t, "alice", notAfter)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expiring := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	renewed := expiring.AddDate(1, 0, 0)
+	writeCert(expiring)
+
+	config := filepath.Join(src, "work.ovpn")
+	if err := os.WriteFile(config, []byte("client\nremote vpn.example.com\ncert client.crt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Profile{Name: "Work", ConfigPath: config}
+	if err := pm.Add(p); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	notAfter := func(pm *ProfileManager) time.Time {
+		t.Helper()
+		got, err := pm.Get(p.ID)
+		if err != nil || len(got.Certificates) != 1 {
+			t.Fatalf("Get = %+v, %v; want the client certificate", got, err)
+		}
+		return got.Certificates[0].NotAfter
+	}
+	if got := notAfter(pm); !got.Equal(expiring) {
+		t.Fatalf("after Add NotAfter = %v, want %v", got, expiring)
+	}
+
+	writeCert(renewed)
+	pm.RefreshCertificates()
+	if got := notAfter(pm); !got.Equal(renewed) {
+		t.Errorf("after RefreshCertificates NotAfter = %v, want %v", got, renewed)
+	}
+
+	// A renewal while the app was not running is seen on the next start,
+	// although the saved profile still has the previous expiry.
+	if err := pm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	renewedAgain := renewed.AddDate(1, 0, 0)
+	writeCert(renewedAgain)
+	loaded := &ProfileManager{configDir: pm.configDir, configFile: pm.configFile}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := notAfter(loaded); !got.Equal(renewedAgain) {
+		t.Errorf("after Load NotAfter = %v, want %v", got, renewedAgain)
+	}
+}
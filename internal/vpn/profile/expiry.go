@@ -0,0 +1,158 @@
+// Package profile provides VPN connection profile management functionality.
+// This file contains the ExpiryChecker, which warns before the certificates
+// of a profile's config expire.
+package profile
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/eventbus"
+	"github.com/yllada/vpn-manager/internal/logger"
+	"github.com/yllada/vpn-manager/internal/resilience"
+)
+
+// ExpiryWarningDays are the days before a certificate expires at which
+// ExpiryChecker warns, once each.
+var ExpiryWarningDays = []int{30, 7, 1}
+
+// ExpiryCheckInterval is how often ExpiryChecker looks at the certificates.
+const ExpiryCheckInterval = 6 * time.Hour
+
+// ExpiryChecker periodically checks the certificates of every profile and
+// emits EventCertificateExpiring when one is within 30, 7 or 1 days of its
+// expiry, and once more when it has expired. Each threshold is announced once
+// per certificate for the life of the checker.
+type ExpiryChecker struct {
+	pm  *ProfileManager
+	now func() time.Time
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+	// warned is the last threshold announced per profile and certificate
+	// fingerprint (0 once expired).
+	warned map[string]int
+}
+
+// NewExpiryChecker creates an expiry checker for the profiles of pm.
+func NewExpiryChecker(pm *ProfileManager) *ExpiryChecker {
+	return &ExpiryChecker{
+		pm:     pm,
+		now:    time.Now,
+		warned: make(map[string]int),
+	}
+}
+
+// Start checks the certificates now and then every ExpiryCheckInterval.
+func (c *ExpiryChecker) Start() {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = true
+	c.stopChan = make(chan struct{})
+	stop := c.stopChan
+	c.mu.Unlock()
+
+	resilience.SafeGoWithName("certificate-expiry-checker", func() {
+		ticker := time.NewTicker(ExpiryCheckInterval)
+		defer ticker.Stop()
+		for {
+			c.Check()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// Stop stops the periodic checks.
+func (c *ExpiryChecker) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return
+	}
+	c.running = false
+	close(c.stopChan)
+}
+
+// Check inspects every certificate again and emits the warnings that are
+// due.
+func (c *ExpiryChecker) Check() {
+	c.pm.RefreshCertificates()
+	now := c.now()
+	for _, p := range c.pm.List() {
+		for _, cert := range p.Certificates {
+			if !cert.Expires() {
+				continue
+			}
+			threshold, due := expiryThreshold(cert.NotAfter.Sub(now))
+			if !due {
+				continue
+			}
+
+			key := p.ID + "/" + cert.Fingerprint
+			c.mu.Lock()
+			last, warned := c.warned[key]
+			if warned && last <= threshold {
+				c.mu.Unlock()
+				continue
+			}
+			c.warned[key] = threshold
+			c.mu.Unlock()
+
+			data := expiryData(p, cert, now)
+			logger.LogWarn("profile", "%s", data.Message)
+			eventbus.Emit(eventbus.EventCertificateExpiring, "ExpiryChecker", data)
+		}
+	}
+}
+
+// expiryThreshold returns the smallest of ExpiryWarningDays that left is
+// within, or 0 once expired. due is false while none is reached.
+func expiryThreshold(left time.Duration) (threshold int, due bool) {
+	if left <= 0 {
+		return 0, true
+	}
+	for _, days := range ExpiryWarningDays {
+		if left <= time.Duration(days)*24*time.Hour && (!due || days < threshold) {
+			threshold, due = days, true
+		}
+	}
+	return threshold, due
+}
+
+// expiryData builds the event for cert of p.
+func expiryData(p *Profile, cert Certificate, now time.Time) eventbus.CertificateExpiryData {
+	daysLeft := int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+	date := cert.NotAfter.Local().Format("2006-01-02")
+
+	var message string
+	switch {
+	case daysLeft < 0:
+		message = fmt.Sprintf("The %s of %s expired on %s. Connections will fail until the profile is updated.", cert.Name(), p.Name, date)
+	case daysLeft == 0:
+		message = fmt.Sprintf("The %s of %s expires today (%s).", cert.Name(), p.Name, date)
+	case daysLeft == 1:
+		message = fmt.Sprintf("The %s of %s expires tomorrow (%s).", cert.Name(), p.Name, date)
+	default:
+		message = fmt.Sprintf("The %s of %s expires in %d days (%s).", cert.Name(), p.Name, daysLeft, date)
+	}
+
+	return eventbus.CertificateExpiryData{
+		ProfileID:   p.ID,
+		ProfileName: p.Name,
+		Certificate: cert.Name(),
+		Fingerprint: cert.Fingerprint,
+		NotAfter:    cert.NotAfter,
+		DaysLeft:    daysLeft,
+		Message:     message,
+	}
+}
@@ -0,0 +1,107 @@
+package profile
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/eventbus"
+)
+
+func TestExpiryThreshold(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		left      time.Duration
+		threshold int
+		due       bool
+	}{
+		{60 * day, 0, false},
+		{30*day + time.Hour, 0, false},
+		{30 * day, 30, true},
+		{8 * day, 30, true},
+		{7 * day, 7, true},
+		{12 * time.Hour, 1, true},
+		{0, 0, true},
+		{-day, 0, true},
+	}
+	for _, tt := range tests {
+		threshold, due := expiryThreshold(tt.left)
+		if threshold != tt.threshold || due != tt.due {
+			t.Errorf("expiryThreshold(%v) = %d, %v; want %d, %v", tt.left, threshold, due, tt.threshold, tt.due)
+		}
+	}
+}
+
+// TestExpiryCheckerWarnsOncePerThreshold walks a certificate towards its
+// expiry and verifies each threshold is announced once.
+func TestExpiryCheckerWarnsOncePerThreshold(t *testing.T) {
+	pm, cleanup := setupTestProfileManager(t)
+	defer cleanup()
+
+	notAfter := time.Date(2030, 6, 30, 12, 0, 0, 0, time.UTC)
+	pm.profiles = append(pm.profiles, &Profile{
+		ID:   "p1",
+		Name: "Work",
+		Certificates: []Certificate{
+			{Source: "cert", Subject: "CN=alice", Fingerprint: "AA:BB", NotAfter: notAfter},
+			{Source: "tls-crypt", Fingerprint: "CC:DD", StaticKey: true},
+		},
+	})
+
+	var (
+		mu     sync.Mutex
+		events []eventbus.CertificateExpiryData
+	)
+	sub := eventbus.On(eventbus.EventCertificateExpiring, func(event *eventbus.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event.Data.(eventbus.CertificateExpiryData))
+	})
+	defer sub.Unsubscribe()
+
+	c := NewExpiryChecker(pm)
+	var wantDays []int
+	for _, step := range []struct {
+		before   time.Duration
+		daysLeft int // of the warning due, if any
+		warns    bool
+	}{
+		{40 * 24 * time.Hour, 0, false},
+		{29 * 24 * time.Hour, 29, true},
+		{20 * 24 * time.Hour, 0, false}, // still within 30 days: already announced
+		{6 * 24 * time.Hour, 6, true},
+		{6 * 24 * time.Hour, 0, false},
+		{2 * time.Hour, 0, true},
+		{-time.Hour, -1, true},
+		{-48 * time.Hour, 0, false},
+	} {
+		now := notAfter.Add(-step.before)
+		c.now = func() time.Time { return now }
+		c.Check()
+		if step.warns {
+			wantDays = append(wantDays, step.daysLeft)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= len(wantDays) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != len(wantDays) {
+		t.Fatalf("got %d warnings, want %d: %+v", len(events), len(wantDays), events)
+	}
+	for i, ev := range events {
+		if ev.DaysLeft != wantDays[i] || ev.ProfileID != "p1" || ev.Certificate != "client certificate CN=alice" || ev.Message == "" {
+			t.Errorf("warning %d = %+v, want %d days left", i, ev, wantDays[i])
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -71,6 +72,26 @@ type Profile struct {
 	// OTPAutoDetected indicates if RequiresOTP was set by auto-detection.
 	// If false, the user manually configured it.
 	OTPAutoDetected bool `json:"otp_auto_detected,omitempty" yaml:"otp_auto_detected,omitempty"`
+	// StaticChallenge is the prompt of the config's static-challenge
+	// directive. The server asks it on every connect and the response is
+	// collected then (see EventAuthOTPRequired), so such a profile does not
+	// ask for an OTP up front.
+	StaticChallenge string `json:"static_challenge,omitempty" yaml:"static_challenge,omitempty"`
+
+	// PreferFastestRemote probes the latency of every remote in the config
+	// before connecting and tries the fastest first, instead of following
+	// the config's order. openvpn still fails over to the others.
+	PreferFastestRemote bool `json:"prefer_fastest_remote,omitempty" yaml:"prefer_fastest_remote,omitempty"`
+
+	// Certificates are the CA and client certificates and the tls-crypt key
+	// of the config (see InspectCertificates), so their expiry can be shown
+	// and watched (see ExpiryChecker). They are inspected again on Load and
+	// by every check, so a renewed certificate replaces the old one.
+	Certificates []Certificate `json:"certificates,omitempty" yaml:"certificates,omitempty"`
+	// ImportDir is the directory the config was imported from. Files it
+	// references by relative path (ca ca.crt) are resolved against it, as the
+	// stored copy of the config lives elsewhere.
+	ImportDir string `json:"import_dir,omitempty" yaml:"import_dir,omitempty"`
 
 	// Split Tunneling Configuration
 	// SplitTunnelEnabled enables split tunneling for this profile.
@@ -159,6 +180,21 @@ func (pm *ProfileManager) Load() error {
 		return fmt.Errorf("failed to parse profiles file: %w", err)
 	}
 
+	// Profiles imported before static challenges were detected had an OTP
+	// appended to the password instead.
+	for _, p := range profiles {
+		if p.OTPAutoDetected && p.StaticChallenge == "" {
+			if prompt, ok := DetectStaticChallenge(p.ConfigPath); ok {
+				p.StaticChallenge = prompt
+				p.RequiresOTP, p.OTPAutoDetected = false, false
+			}
+		}
+		// The certificate files may have been renewed since the last run.
+		if certs, ok := p.currentCertificates(); ok {
+			p.Certificates = certs
+		}
+	}
+
 	pm.mu.Lock()
 	pm.profiles = profiles
 	pm.mu.Unlock()
@@ -210,12 +246,21 @@ func (pm *ProfileManager) Add(profile *Profile) error {
 	profile.Created = time.Now()
 
 	// Auto-detect OTP requirement from config file
-	// Only auto-detect if not already manually configured
-	if !profile.RequiresOTP {
+	// Only auto-detect if not already manually configured. A static challenge
+	// is answered when the server asks it, not appended to the password.
+	profile.StaticChallenge, _ = DetectStaticChallenge(profile.ConfigPath)
+	if !profile.RequiresOTP && profile.StaticChallenge == "" {
 		profile.RequiresOTP = DetectOTPRequirement(profile.ConfigPath)
 		profile.OTPAutoDetected = profile.RequiresOTP
 	}
 
+	// Files the config references are read relative to where it was
+	// imported from, which the copy loses.
+	if abs, err := filepath.Abs(profile.ConfigPath); err == nil {
+		profile.ImportDir = filepath.Dir(abs)
+	}
+	profile.Certificates, _ = InspectCertificates(profile.ConfigPath)
+
 	// Create configs directory
 	configsDir := filepath.Join(pm.configDir, "configs")
 	if err := os.MkdirAll(configsDir, 0700); err != nil {
@@ -435,6 +480,169 @@ func DetectOTPRequirement(configPath string) bool {
 	return false
 }
 
+// DetectStaticChallenge returns the prompt of an OpenVPN config's
+// static-challenge directive (static-challenge "prompt" [echo]), reporting
+// false if it has none.
+func DetectStaticChallenge(configPath string) (string, bool) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", false
+	}
+	for line := range strings.Lines(string(data)) {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), "static-challenge")
+		if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		if prompt := firstConfigArg(strings.TrimSpace(rest)); prompt != "" {
+			return prompt, true
+		}
+	}
+	return "", false
+}
+
+// firstConfigArg returns the first argument of an OpenVPN directive: a double-
+// or single-quoted string (backslash escapes in double quotes), or a word.
+func firstConfigArg(s string) string {
+	if s == "" {
+		return ""
+	}
+	switch quote := s[0]; quote {
+	case '"', '\'':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == quote:
+				return b.String()
+			case quote == '"' && s[i] == '\\' && i+1 < len(s):
+				i++
+			}
+			b.WriteByte(s[i])
+		}
+		return b.String()
+	}
+	word, _, _ := strings.Cut(s, " ")
+	word, _, _ = strings.Cut(word, "\t")
+	return word
+}
+
+// Defaults openvpn applies to a remote that names no port or protocol of its
+// own, when the config has no port or proto directive either.
+const (
+	DefaultRemotePort  = 1194
+	DefaultRemoteProto = "udp"
+)
+
+// Remote is a server of an OpenVPN config (remote host [port] [proto]).
+type Remote struct {
+	Host  string
+	Port  int
+	Proto string
+}
+
+// Remotes are the servers of an OpenVPN config, in the order openvpn tries
+// them.
+type Remotes struct {
+	Servers []Remote
+	// Random is set by remote-random: openvpn shuffles the servers.
+	Random bool
+	// ConnectionBlocks is set when servers come from <connection> blocks,
+	// which can carry options of their own besides the server.
+	ConnectionBlocks bool
+}
+
+// ParseRemotes lists the servers of an OpenVPN config. A remote without a
+// port or protocol gets those of its <connection> block, then the config's
+// port (or rport) and proto directives, then openvpn's defaults. Other inline
+// blocks (<ca>, <tls-crypt>, ...) and comments are skipped.
+func ParseRemotes(configPath string) (Remotes, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return Remotes{}, err
+	}
+
+	type pending struct {
+		Remote
+		block *Remote // the <connection> block's port and proto
+	}
+	var (
+		remotes  []pending
+		global   Remote
+		block    *Remote
+		skipping string // closing tag of the inline block being skipped
+		result   Remotes
+	)
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if skipping != "" {
+			if strings.EqualFold(line, skipping) {
+				skipping = ""
+			}
+			continue
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		switch lower := strings.ToLower(line); {
+		case lower == "<connection>":
+			block = &Remote{}
+			result.ConnectionBlocks = true
+			continue
+		case lower == "</connection>":
+			block = nil
+			continue
+		case strings.HasPrefix(lower, "<") && !strings.HasPrefix(lower, "</"):
+			skipping = "</" + strings.TrimPrefix(lower, "<")
+			continue
+		}
+
+		fields := strings.Fields(line)
+		scope := &global
+		if block != nil {
+			scope = block
+		}
+		switch fields[0] {
+		case "remote":
+			if len(fields) < 2 {
+				continue
+			}
+			r := pending{Remote: Remote{Host: fields[1]}, block: block}
+			if len(fields) > 2 {
+				r.Port, _ = strconv.Atoi(fields[2])
+			}
+			if len(fields) > 3 {
+				r.Proto = fields[3]
+			}
+			remotes = append(remotes, r)
+		case "port", "rport":
+			if len(fields) > 1 {
+				scope.Port, _ = strconv.Atoi(fields[1])
+			}
+		case "proto":
+			if len(fields) > 1 {
+				scope.Proto = fields[1]
+			}
+		case "remote-random":
+			result.Random = true
+		}
+	}
+
+	for _, r := range remotes {
+		for _, fallback := range []*Remote{r.block, &global, {Port: DefaultRemotePort, Proto: DefaultRemoteProto}} {
+			if fallback == nil {
+				continue
+			}
+			if r.Port == 0 {
+				r.Port = fallback.Port
+			}
+			if r.Proto == "" {
+				r.Proto = fallback.Proto
+			}
+		}
+		result.Servers = append(result.Servers, r.Remote)
+	}
+	return result, nil
+}
+
 // copyFile copies a file from src to dst with secure permissions.
 func copyFile(src, dst string) error {
 	data, err := os.ReadFile(src)
@@ -593,6 +801,7 @@ func (pm *ProfileManager) Import(filePath string) (int, error) {
 			Created:            time.Now(),
 			SavePassword:       false, // User must re-enter credentials
 		}
+		profile.Certificates, _ = InspectCertificates(configPath)
 
 		profilesToAdd = append(profilesToAdd, profile)
 	}
@@ -3,6 +3,7 @@ package profile
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -450,6 +451,123 @@ func TestDetectOTPRequirement(t *testing.T) {
 	}
 }
 
+func TestDetectStaticChallenge(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cases := []struct {
+		name    string
+		content string
+		prompt  string
+		found   bool
+	}{
+		{"none", "client\nremote vpn.example.com\nauth-user-pass", "", false},
+		{"quoted", "client\nremote vpn.example.com\nstatic-challenge \"Enter \\\"PIN\\\" code\" 1", `Enter "PIN" code`, true},
+		{"single quoted", "static-challenge 'Token code' 0", "Token code", true},
+		{"word", "\tstatic-challenge Token 1", "Token", true},
+		{"comment", "# static-challenge \"Enter OTP\" 1\nclient", "", false},
+		{"other directive", "static-challenge-x \"Enter OTP\"", "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, strings.ReplaceAll(tc.name, " ", "-")+".ovpn")
+			_ = os.WriteFile(path, []byte(tc.content), 0600)
+
+			prompt, found := DetectStaticChallenge(path)
+			if prompt != tc.prompt || found != tc.found {
+				t.Errorf("DetectStaticChallenge = %q, %v; want %q, %v", prompt, found, tc.prompt, tc.found)
+			}
+		})
+	}
+}
+
+func TestParseRemotes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cases := []struct {
+		name    string
+		content string
+		want    Remotes
+	}{
+		{
+			name:    "defaults",
+			content: "client\nremote vpn1.example.com\nremote 198.51.100.2 443 tcp\n# remote old.example.com\n",
+			want: Remotes{Servers: []Remote{
+				{Host: "vpn1.example.com", Port: 1194, Proto: "udp"},
+				{Host: "198.51.100.2", Port: 443, Proto: "tcp"},
+			}},
+		},
+		{
+			name:    "global port and proto",
+			content: "client\nproto tcp-client\nremote vpn1.example.com\nremote vpn2.example.com 8443\nport 443\nremote-random\n",
+			want: Remotes{Servers: []Remote{
+				{Host: "vpn1.example.com", Port: 443, Proto: "tcp-client"},
+				{Host: "vpn2.example.com", Port: 8443, Proto: "tcp-client"},
+			}, Random: true},
+		},
+		{
+			name: "inline blocks",
+			content: "client\nremote vpn1.example.com\n<ca>\nremote not-a-server\n</ca>\n" +
+				"<connection>\nremote vpn2.example.com\nproto tcp\nport 443\n</connection>\n",
+			want: Remotes{Servers: []Remote{
+				{Host: "vpn1.example.com", Port: 1194, Proto: "udp"},
+				{Host: "vpn2.example.com", Port: 443, Proto: "tcp"},
+			}, ConnectionBlocks: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, strings.ReplaceAll(tc.name, " ", "-")+".ovpn")
+			_ = os.WriteFile(path, []byte(tc.content), 0600)
+
+			got, err := ParseRemotes(path)
+			if err != nil {
+				t.Fatalf("ParseRemotes: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseRemotes = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestProfileManager_AddStaticChallenge verifies a static-challenge profile
+// records its prompt instead of asking for an OTP up front.
+func TestProfileManager_AddStaticChallenge(t *testing.T) {
+	pm, cleanup := setupTestProfileManager(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "token.ovpn")
+	content := "client\nremote vpn.example.com 1194\nauth-user-pass\nstatic-challenge \"Enter OTP\" 1\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &Profile{Name: "Token", ConfigPath: path}
+	if err := pm.Add(p); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if p.StaticChallenge != "Enter OTP" || p.RequiresOTP {
+		t.Errorf("StaticChallenge = %q, RequiresOTP = %v; want the prompt and no up-front OTP", p.StaticChallenge, p.RequiresOTP)
+	}
+
+	// A profile imported before detection is migrated on load.
+	p.StaticChallenge, p.RequiresOTP, p.OTPAutoDetected = "", true, true
+	if err := pm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Load(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := pm.Get(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.StaticChallenge != "Enter OTP" || loaded.RequiresOTP {
+		t.Errorf("after Load: StaticChallenge = %q, RequiresOTP = %v", loaded.StaticChallenge, loaded.RequiresOTP)
+	}
+}
+
 func TestGenerateUUID(t *testing.T) {
 	ids := make(map[string]bool)
 
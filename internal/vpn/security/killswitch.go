@@ -51,8 +51,9 @@ type KillSwitch struct {
 	enabled  bool
 	mode     KillSwitchMode
 	vpnIface string
-	// vpnServerIP is the VPN server's IP address
-	vpnServerIP string
+	// vpnServerIPs are the VPN servers' IP addresses: one per remote of the
+	// profile, so a failover to another remote is not blocked
+	vpnServerIPs []string
 	// allowedIPs contains IPs that bypass the kill switch (e.g., LAN, VPN server)
 	allowedIPs []string
 	// chainName is the iptables chain used for kill switch rules
@@ -131,6 +132,17 @@ func (ks *KillSwitch) IsEnabled() bool {
 // Enable activates the kill switch for the specified VPN interface.
 // Requires the vpn-managerd daemon to be running.
 func (ks *KillSwitch) Enable(vpnInterface string, vpnServerIP string) error {
+	var servers []string
+	if vpnServerIP != "" {
+		servers = []string{vpnServerIP}
+	}
+	return ks.EnableForServers(vpnInterface, servers)
+}
+
+// EnableForServers is Enable for a profile with several remotes: traffic to
+// every one of vpnServerIPs stays allowed, so the VPN client can fail over
+// between them while the kill switch is active.
+func (ks *KillSwitch) EnableForServers(vpnInterface string, vpnServerIPs []string) error {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
 
@@ -143,19 +155,22 @@ func (ks *KillSwitch) Enable(vpnInterface string, vpnServerIP string) error {
 	}
 
 	ks.vpnIface = vpnInterface
-	ks.vpnServerIP = vpnServerIP
+	ks.vpnServerIPs = vpnServerIPs
 
 	// Use daemon for privileged operations
 	if !daemonAvailable() {
 		return fmt.Errorf("vpn-managerd daemon is not running")
 	}
 
-	result, err := killSwitchEnable(daemon.KillSwitchEnableParams{
+	params := daemon.KillSwitchEnableParams{
 		VPNInterface: vpnInterface,
-		VPNServerIP:  vpnServerIP,
 		AllowLAN:     ks.allowLAN,
 		LANRanges:    ks.lanRanges,
-	})
+	}
+	if len(vpnServerIPs) > 0 {
+		params.VPNServerIP, params.VPNServerIPs = vpnServerIPs[0], vpnServerIPs[1:]
+	}
+	result, err := killSwitchEnable(params)
 	if err != nil {
 		return fmt.Errorf("daemon call failed: %w", err)
 	}
@@ -332,6 +347,8 @@ type KillSwitchState struct {
 	VPNIface string `json:"vpn_iface"`
 	// VPNServerIP is the VPN server's IP address.
 	VPNServerIP string `json:"vpn_server_ip,omitempty"`
+	// VPNServerIPs are the IP addresses of the profile's further remotes.
+	VPNServerIPs []string `json:"vpn_server_ips,omitempty"`
 	// AllowLAN indicates whether LAN access is allowed while kill switch is active.
 	AllowLAN bool `json:"allow_lan"`
 	// LANRanges are the IP ranges considered as LAN (RFC1918 by default).
@@ -344,6 +361,14 @@ type KillSwitchState struct {
 	Timestamp int64 `json:"timestamp"`
 }
 
+// ServerIPs returns every VPN server IP the kill switch allowed.
+func (s *KillSwitchState) ServerIPs() []string {
+	if s.VPNServerIP == "" {
+		return nil
+	}
+	return append([]string{s.VPNServerIP}, s.VPNServerIPs...)
+}
+
 // KillSwitchConfig provides configuration options for the kill switch.
 type KillSwitchConfig struct {
 	// Mode is "strict" (block all non-VPN) or "normal" (allow established
@@ -361,15 +386,17 @@ type KillSwitchConfig struct {
 func (ks *KillSwitch) SaveState() error {
 	ks.mu.Lock()
 	state := KillSwitchState{
-		Enabled:     ks.enabled,
-		Mode:        string(ks.mode),
-		VPNIface:    ks.vpnIface,
-		VPNServerIP: ks.vpnServerIP,
-		AllowLAN:    ks.allowLAN,
-		LANRanges:   ks.lanRanges,
-		AllowedIPs:  ks.allowedIPs,
-		Backend:     ks.backend,
-		Timestamp:   time.Now().Unix(),
+		Enabled:    ks.enabled,
+		Mode:       string(ks.mode),
+		VPNIface:   ks.vpnIface,
+		AllowLAN:   ks.allowLAN,
+		LANRanges:  ks.lanRanges,
+		AllowedIPs: ks.allowedIPs,
+		Backend:    ks.backend,
+		Timestamp:  time.Now().Unix(),
+	}
+	if len(ks.vpnServerIPs) > 0 {
+		state.VPNServerIP, state.VPNServerIPs = ks.vpnServerIPs[0], ks.vpnServerIPs[1:]
 	}
 	ks.mu.Unlock()
 
@@ -482,7 +509,7 @@ func (ks *KillSwitch) RecoverState() error {
 		ks.enabled = true
 		ks.mode = KillSwitchMode(state.Mode)
 		ks.vpnIface = state.VPNIface
-		ks.vpnServerIP = state.VPNServerIP
+		ks.vpnServerIPs = state.ServerIPs()
 		ks.allowLAN = state.AllowLAN
 		if len(state.LANRanges) > 0 {
 			ks.lanRanges = state.LANRanges
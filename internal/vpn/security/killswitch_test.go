@@ -6,6 +6,7 @@ package security
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -157,6 +158,25 @@ func TestKillSwitchDisableIdempotent(t *testing.T) {
 	}
 }
 
+// TestKillSwitchEnableForServers pins that every remote of a multi-remote
+// profile reaches the daemon, the first as vpn_server_ip.
+func TestKillSwitchEnableForServers(t *testing.T) {
+	fd := &fakeDaemon{available: true, backend: "iptables"}
+	installFakeDaemon(t, fd)
+
+	ks := newTestKillSwitch("iptables")
+	if err := ks.EnableForServers("tun0", []string{"1.2.3.4", "5.6.7.8", "9.10.11.12"}); err != nil {
+		t.Fatalf("EnableForServers() error = %v", err)
+	}
+	if len(fd.ksEnableParams) != 1 {
+		t.Fatalf("daemon enable called %d times, want 1", len(fd.ksEnableParams))
+	}
+	got := fd.ksEnableParams[0]
+	if got.VPNServerIP != "1.2.3.4" || !reflect.DeepEqual(got.VPNServerIPs, []string{"5.6.7.8", "9.10.11.12"}) {
+		t.Errorf("daemon params = %q + %v, want 1.2.3.4 + [5.6.7.8 9.10.11.12]", got.VPNServerIP, got.VPNServerIPs)
+	}
+}
+
 // TestKillSwitchAlwaysModeRefusesDisable pins the security contract of
 // "always" mode: a plain Disable must NOT tear down the firewall rules.
 func TestKillSwitchAlwaysModeRefusesDisable(t *testing.T) {
@@ -311,7 +331,7 @@ func TestKillSwitchStateSaveLoadRoundTrip(t *testing.T) {
 	ks.enabled = true
 	ks.mode = KillSwitchAuto
 	ks.vpnIface = "wg0"
-	ks.vpnServerIP = "5.6.7.8"
+	ks.vpnServerIPs = []string{"5.6.7.8", "5.6.7.9"}
 	ks.allowLAN = true
 	ks.lanRanges = []string{"192.168.50.0/24"}
 
@@ -333,6 +353,9 @@ func TestKillSwitchStateSaveLoadRoundTrip(t *testing.T) {
 		state.VPNIface != "wg0" || state.VPNServerIP != "5.6.7.8" || !state.AllowLAN {
 		t.Errorf("round-trip state mismatch: %+v", state)
 	}
+	if got := state.ServerIPs(); len(got) != 2 || got[1] != "5.6.7.9" {
+		t.Errorf("ServerIPs() = %v, want [5.6.7.8 5.6.7.9]", got)
+	}
 	if len(state.LANRanges) != 1 || state.LANRanges[0] != "192.168.50.0/24" {
 		t.Errorf("LANRanges = %v, want [192.168.50.0/24]", state.LANRanges)
 	}
@@ -0,0 +1,29 @@
+// Package api is the typed client for the daemon's RPC methods.
+//
+// The param and result types and the Client methods in client_gen.go are
+// generated from the daemon's own description of its methods (system.describe,
+// saved in describe.json), so they cannot drift from what the handlers read
+// and write. After changing a handler's types, run `go generate
+// ./pkg/protocol/api`.
+package api
+
+//go:generate sh -c "go run ../../../cmd/vpn-managerd --describe > describe.json"
+//go:generate go run ../../../internal/tools/rpcgen -in describe.json -out client_gen.go
+
+import "context"
+
+// Caller sends one request and decodes its result. *protocol.Client
+// implements it.
+type Caller interface {
+	Call(ctx context.Context, method string, params, result any) error
+}
+
+// Client calls the daemon's methods with typed params and results.
+type Client struct {
+	caller Caller
+}
+
+// NewClient returns a Client that sends its requests through caller.
+func NewClient(caller Caller) *Client {
+	return &Client{caller: caller}
+}
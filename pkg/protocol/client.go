@@ -3,9 +3,11 @@ package protocol
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,6 +19,16 @@ const DefaultSocketPath = "/var/run/vpn-manager/vpn-managerd.sock"
 // DefaultTimeout is the default timeout for RPC calls.
 const DefaultTimeout = 30 * time.Second
 
+// eventBufferSize is the per-subscription channel capacity. Events that arrive
+// while a subscriber's buffer is full are dropped rather than stalling the
+// reader goroutine, which also delivers RPC responses.
+const eventBufferSize = 32
+
+// streamBufferSize is the channel capacity for a Stream call. A stream is the
+// whole point of its connection and comes in bursts (a log's backlog), so it
+// gets more room than a subscription.
+const streamBufferSize = 1024
+
 // Client communicates with the VPN Manager daemon over Unix socket.
 // It handles connection management, request/response matching, and reconnection.
 // Client is safe for concurrent use from multiple goroutines.
@@ -35,8 +47,32 @@ type Client struct {
 	pending   map[int]chan *Response
 	pendingMu sync.Mutex
 
+	// Event subscriptions fed by daemon notifications
+	subs   map[*subscription]struct{}
+	subsMu sync.Mutex
+
 	// Done channel for shutdown
 	done chan struct{}
+
+	// system.hello result, valid for the connection it was read on
+	hello      *HelloResult
+	helloCodec *Codec
+}
+
+// subscription is one Subscribe caller's view of the event stream.
+type subscription struct {
+	topics map[string]struct{} // empty means every topic
+	ch     chan Event
+	gone   chan struct{} // closed together with ch
+}
+
+// wants reports whether the subscription follows the given topic.
+func (s *subscription) wants(topic string) bool {
+	if len(s.topics) == 0 {
+		return true
+	}
+	_, ok := s.topics[topic]
+	return ok
 }
 
 // ClientOption configures a Client.
@@ -63,6 +99,7 @@ func NewClient(opts ...ClientOption) *Client {
 		socketPath: DefaultSocketPath,
 		timeout:    DefaultTimeout,
 		pending:    make(map[int]chan *Response),
+		subs:       make(map[*subscription]struct{}),
 		done:       make(chan struct{}),
 	}
 
@@ -140,13 +177,29 @@ func (c *Client) Close() error {
 	}
 	c.pendingMu.Unlock()
 
+	c.closeSubscriptions()
+
 	return nil
 }
 
 // Call invokes a remote method and waits for the response.
 // The params and result should be pointers to the appropriate types.
 // Returns error if the call fails or the response contains an error.
+//
+// If ctx is cancelled or the call times out, Call sends MethodCancelRequest so
+// the daemon stops the handler and rolls back its partial work, rather than
+// finishing an operation nobody is waiting for.
 func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	timeout := c.timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return c.call(ctx, method, params, result, time.After(timeout))
+}
+
+// call sends a request and waits for its response until expired fires (never,
+// for a nil channel), ctx is cancelled or the connection closes.
+func (c *Client) call(ctx context.Context, method string, params, result any, expired <-chan time.Time) error {
 	// Ensure we're connected
 	if !c.IsConnected() {
 		if err := c.Connect(ctx); err != nil {
@@ -176,9 +229,10 @@ func (c *Client) Call(ctx context.Context, method string, params, result any) er
 		c.pendingMu.Unlock()
 	}()
 
-	// Send request
+	// Send request. A call registered after the reader failed the pending
+	// calls would never get a response, so check the connection is still up.
 	c.mu.Lock()
-	if c.codec == nil {
+	if c.codec == nil || !c.connected {
 		c.mu.Unlock()
 		return ErrConnectionClosed
 	}
@@ -189,12 +243,6 @@ func (c *Client) Call(ctx context.Context, method string, params, result any) er
 		return fmt.Errorf("write request: %w", err)
 	}
 
-	// Wait for response with timeout
-	timeout := c.timeout
-	if deadline, ok := ctx.Deadline(); ok {
-		timeout = time.Until(deadline)
-	}
-
 	select {
 	case resp, ok := <-respCh:
 		if !ok {
@@ -202,10 +250,12 @@ func (c *Client) Call(ctx context.Context, method string, params, result any) er
 		}
 		return c.handleResponse(resp, result)
 
-	case <-time.After(timeout):
+	case <-expired:
+		c.cancelRequest(id)
 		return ErrTimeout
 
 	case <-ctx.Done():
+		c.cancelRequest(id)
 		return ctx.Err()
 
 	case <-c.done:
@@ -213,6 +263,25 @@ func (c *Client) Call(ctx context.Context, method string, params, result any) er
 	}
 }
 
+// cancelRequest tells the daemon to stop the request with the given ID, which
+// this client has stopped waiting for. Without it the handler would run to
+// completion and change the system after the caller already gave up. Best
+// effort: if the notification cannot be sent, the connection is going away
+// and the daemon cancels the connection's requests when it notices.
+func (c *Client) cancelRequest(id int) {
+	n, err := NewNotification(MethodCancelRequest, CancelParams{ID: id})
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	codec := c.codec
+	c.mu.Unlock()
+	if codec != nil {
+		_ = codec.WriteNotification(n)
+	}
+}
+
 // handleResponse processes a response and extracts the result.
 func (c *Client) handleResponse(resp *Response, result any) error {
 	if resp.Error != nil {
@@ -228,7 +297,145 @@ func (c *Client) handleResponse(resp *Response, result any) error {
 	return nil
 }
 
+// Subscribe asks the daemon to push events for the given topics (all topics when
+// none are given) and returns a channel that receives them. The channel is
+// closed when ctx is cancelled or the connection to the daemon is lost; callers
+// that want to keep following events re-subscribe after reconnecting.
+//
+// Delivery is best effort: if the caller falls behind by more than a small
+// buffer, newer events are dropped. Treat an event as a hint to re-read the
+// authoritative state (e.g. via *.status), not as the state itself.
+func (c *Client) Subscribe(ctx context.Context, topics ...string) (<-chan Event, error) {
+	sub := &subscription{
+		topics: make(map[string]struct{}, len(topics)),
+		ch:     make(chan Event, eventBufferSize),
+		gone:   make(chan struct{}),
+	}
+	for _, t := range topics {
+		sub.topics[t] = struct{}{}
+	}
+
+	// Register before the call: the daemon may push the first event right
+	// behind its response, before Call returns here.
+	c.subsMu.Lock()
+	c.subs[sub] = struct{}{}
+	c.subsMu.Unlock()
+
+	var result map[string]any
+	if err := c.Call(ctx, "events.subscribe", SubscribeParams{Topics: topics}, &result); err != nil {
+		c.removeSubscription(sub)
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.done:
+		case <-sub.gone:
+		}
+		c.removeSubscription(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+// Stream calls a method that pushes events to its caller while it runs, such
+// as openvpn.logs with follow, and passes each event for topics to handle.
+// Unlike Call it has no timeout: the stream ends when ctx is cancelled (Stream
+// then returns nil), when the daemon ends the call, or when the connection
+// drops. handle runs on a goroutine of its own, one event at a time, and has
+// seen every delivered event by the time Stream returns.
+//
+// Events that arrive while handle is more than streamBufferSize events behind
+// are dropped. A streaming call holds its connection until it ends, so give it
+// a Client of its own.
+func (c *Client) Stream(ctx context.Context, method string, params any, handle func(Event), topics ...string) error {
+	sub := &subscription{
+		topics: make(map[string]struct{}, len(topics)),
+		ch:     make(chan Event, streamBufferSize),
+		gone:   make(chan struct{}),
+	}
+	for _, t := range topics {
+		sub.topics[t] = struct{}{}
+	}
+
+	c.subsMu.Lock()
+	c.subs[sub] = struct{}{}
+	c.subsMu.Unlock()
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		for ev := range sub.ch {
+			handle(ev)
+		}
+	}()
+
+	err := c.call(ctx, method, params, nil, nil)
+	c.removeSubscription(sub)
+	<-handled
+
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// removeSubscription unregisters a subscription and closes its channel. It is
+// safe to call more than once; only the first call closes the channel.
+func (c *Client) removeSubscription(sub *subscription) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	if _, ok := c.subs[sub]; ok {
+		delete(c.subs, sub)
+		close(sub.ch)
+		close(sub.gone)
+	}
+}
+
+// closeSubscriptions closes every subscription channel, e.g. when the
+// connection carrying the event stream goes away.
+func (c *Client) closeSubscriptions() {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for sub := range c.subs {
+		delete(c.subs, sub)
+		close(sub.ch)
+		close(sub.gone)
+	}
+}
+
+// dispatchNotification delivers a daemon notification to matching subscribers.
+// Unknown notification methods are ignored so newer daemons can add them.
+func (c *Client) dispatchNotification(n *Notification) {
+	if n.Method != NotifyEvent {
+		return
+	}
+
+	var ev Event
+	if err := n.UnmarshalParams(&ev); err != nil {
+		return
+	}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for sub := range c.subs {
+		if !sub.wants(ev.Topic) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			// Subscriber is behind; drop rather than block responses
+		}
+	}
+}
+
 // readResponses continuously reads responses and dispatches to waiting callers.
+// Notifications interleaved with responses are routed to event subscribers.
 func (c *Client) readResponses() {
 	for {
 		select {
@@ -245,15 +452,28 @@ func (c *Client) readResponses() {
 			return
 		}
 
-		resp, err := codec.ReadResponse()
+		resp, notification, err := codec.ReadMessage()
 		if err != nil {
-			// Connection closed or error - close client
+			// Connection closed or error - close client, and fail the calls
+			// waiting on it (a stream has no timeout to end it otherwise)
 			c.mu.Lock()
 			c.connected = false
+			c.pendingMu.Lock()
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.pendingMu.Unlock()
 			c.mu.Unlock()
+			c.closeSubscriptions()
 			return
 		}
 
+		if notification != nil {
+			c.dispatchNotification(notification)
+			continue
+		}
+
 		// Dispatch to waiting caller
 		c.pendingMu.Lock()
 		if ch, ok := c.pending[resp.ID]; ok {
@@ -273,6 +493,69 @@ func (c *Client) Ping(ctx context.Context) error {
 	return c.Call(ctx, "system.ping", nil, &result)
 }
 
+// Hello performs the system.hello handshake and returns the daemon's protocol
+// version and capabilities. The result is cached for the life of the
+// connection, so UI code may call it freely; after a reconnect (the daemon may
+// have been upgraded) the next call asks again. The returned value is shared
+// and must not be modified.
+//
+// A daemon that predates system.hello answers with ErrCodeMethodNotFound; Hello
+// reports it as ProtocolVersion 0 with no capabilities instead of failing. If
+// the daemon no longer serves this client's ProtocolVersion, Hello returns
+// ErrIncompatibleDaemon.
+func (c *Client) Hello(ctx context.Context) (*HelloResult, error) {
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	codec := c.codec
+	if c.hello != nil && c.helloCodec == codec {
+		hello := c.hello
+		c.mu.Unlock()
+		return hello, nil
+	}
+	c.mu.Unlock()
+
+	params := HelloParams{ProtocolVersion: ProtocolVersion, Client: filepath.Base(os.Args[0])}
+	var result HelloResult
+	err := c.Call(ctx, MethodHello, params, &result)
+	var rpcErr *RPCError
+	switch {
+	case errors.As(err, &rpcErr) && rpcErr.Code == ErrCodeMethodNotFound:
+		result = HelloResult{Capabilities: map[string]bool{}}
+	case err != nil:
+		return nil, err
+	}
+	if result.MinProtocolVersion > ProtocolVersion {
+		return nil, fmt.Errorf("%w: daemon %s %s requires protocol %d, this client speaks %d",
+			ErrIncompatibleDaemon, result.Daemon, result.Version, result.MinProtocolVersion, ProtocolVersion)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Only cache what the current connection said: if it dropped and was
+	// re-established during the call, the new daemon may differ.
+	if c.codec == codec {
+		c.hello = &result
+		c.helloCodec = codec
+	}
+	return &result, nil
+}
+
+// HasCapability reports whether the daemon announced the capability (one of
+// the Cap* constants) in the cached system.hello result. It never blocks on
+// the daemon: before Hello has succeeded on the current connection it reports
+// false.
+func (c *Client) HasCapability(capability string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.connected || c.helloCodec != c.codec {
+		return false
+	}
+	return c.hello.Has(capability)
+}
+
 // IsDaemonAvailable checks if the daemon socket exists.
 // This is a quick check that doesn't establish a connection.
 func IsDaemonAvailable() bool {
@@ -0,0 +1,380 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDaemon accepts a single connection, answers events.subscribe and then
+// pushes the given events before closing the connection.
+func fakeDaemon(t *testing.T, events ...Event) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		codec := NewCodec(conn)
+		defer func() { _ = codec.Close() }()
+
+		req, err := codec.ReadRequest()
+		if err != nil || req.Method != "events.subscribe" {
+			return
+		}
+		resp, _ := NewResponse(req.ID, map[string]bool{"subscribed": true})
+		if err := codec.WriteResponse(resp); err != nil {
+			return
+		}
+		for _, ev := range events {
+			n, _ := NewNotification(NotifyEvent, ev)
+			if err := codec.WriteNotification(n); err != nil {
+				return
+			}
+		}
+	}()
+
+	return socketPath
+}
+
+func TestClientSubscribe(t *testing.T) {
+	socketPath := fakeDaemon(t,
+		Event{Topic: TopicDNS},
+		Event{Topic: TopicKillSwitch},
+	)
+
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(5*time.Second))
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events, err := client.Subscribe(ctx, TopicKillSwitch)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event channel closed before any event")
+		}
+		if ev.Topic != TopicKillSwitch {
+			t.Errorf("Topic = %q, want %q (other topics must be filtered)", ev.Topic, TopicKillSwitch)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for event")
+	}
+
+	// The fake daemon hangs up after its events; the channel must close.
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected channel to close after connection loss")
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for channel close")
+	}
+}
+
+func TestClientSubscribeCancel(t *testing.T) {
+	socketPath := fakeDaemon(t)
+
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(5*time.Second))
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected channel to close after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for channel close")
+	}
+}
+
+// streamDaemon accepts a single connection, pushes events in reply to its
+// first request and then answers it with end (or waits for the cancellation
+// when end is nil). The next message's method is sent on the returned channel.
+func streamDaemon(t *testing.T, end func(req *Request) *Response, events ...Event) (string, <-chan string) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	next := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		codec := NewCodec(conn)
+		defer func() { _ = codec.Close() }()
+
+		req, err := codec.ReadRequest()
+		if err != nil {
+			return
+		}
+		for _, ev := range events {
+			n, _ := NewNotification(NotifyEvent, ev)
+			if err := codec.WriteNotification(n); err != nil {
+				return
+			}
+		}
+		if end != nil {
+			_ = codec.WriteResponse(end(req))
+		}
+		if msg, err := codec.ReadRequest(); err == nil {
+			next <- msg.Method
+		}
+	}()
+
+	return socketPath, next
+}
+
+func TestClientStreamCancel(t *testing.T) {
+	socketPath, next := streamDaemon(t, nil,
+		Event{Topic: TopicOpenVPNLog},
+		Event{Topic: TopicDNS},
+		Event{Topic: TopicOpenVPNLog},
+	)
+
+	// A short client timeout must not end a stream.
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(50*time.Millisecond))
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got atomic.Int32
+	handle := func(ev Event) {
+		if ev.Topic != TopicOpenVPNLog {
+			t.Errorf("Topic = %q, other topics must be filtered", ev.Topic)
+		}
+		if got.Add(1) == 2 {
+			time.AfterFunc(100*time.Millisecond, cancel)
+		}
+	}
+
+	if err := client.Stream(ctx, "openvpn.logs", nil, handle, TopicOpenVPNLog); err != nil {
+		t.Errorf("Stream after cancel = %v, want nil", err)
+	}
+	if n := got.Load(); n != 2 {
+		t.Errorf("handled %d events, want 2", n)
+	}
+	select {
+	case method := <-next:
+		if method != MethodCancelRequest {
+			t.Errorf("after the stream the daemon got %q, want %q", method, MethodCancelRequest)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the daemon was not told to stop the stream")
+	}
+}
+
+func TestClientStreamEnded(t *testing.T) {
+	socketPath, _ := streamDaemon(t, func(req *Request) *Response {
+		return OperationFailedError(req.ID, errors.New("gone"))
+	}, Event{Topic: TopicOpenVPNLog})
+
+	client := NewClient(WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+
+	handled := 0
+	err := client.Stream(context.Background(), "openvpn.logs", nil, func(Event) { handled++ }, TopicOpenVPNLog)
+	if err == nil {
+		t.Error("Stream should return the daemon's error")
+	}
+	if handled != 1 {
+		t.Errorf("handled %d events before the end, want 1", handled)
+	}
+}
+
+func TestClientStreamConnectionDropped(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	// The daemon goes away in the middle of the stream.
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		codec := NewCodec(conn)
+		if _, err := codec.ReadRequest(); err == nil {
+			n, _ := NewNotification(NotifyEvent, Event{Topic: TopicOpenVPNLog})
+			_ = codec.WriteNotification(n)
+		}
+		_ = codec.Close()
+	}()
+
+	client := NewClient(WithSocketPath(socketPath))
+	defer func() { _ = client.Close() }()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Stream(context.Background(), "openvpn.logs", nil, func(Event) {}, TopicOpenVPNLog)
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Errorf("Stream = %v, want ErrConnectionClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stream did not return after the connection dropped")
+	}
+}
+
+// answerDaemon accepts connections and answers every request with reply,
+// counting the requests per method.
+func answerDaemon(t *testing.T, reply func(req *Request) *Response) (string, *sync.Map) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	var calls sync.Map
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				codec := NewCodec(conn)
+				defer func() { _ = codec.Close() }()
+				for {
+					req, err := codec.ReadRequest()
+					if err != nil {
+						return
+					}
+					n, _ := calls.LoadOrStore(req.Method, new(atomic.Int32))
+					n.(*atomic.Int32).Add(1)
+					if err := codec.WriteResponse(reply(req)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return socketPath, &calls
+}
+
+func callCount(calls *sync.Map, method string) int32 {
+	n, ok := calls.Load(method)
+	if !ok {
+		return 0
+	}
+	return n.(*atomic.Int32).Load()
+}
+
+func TestClientHello(t *testing.T) {
+	socketPath, calls := answerDaemon(t, func(req *Request) *Response {
+		var params HelloParams
+		if err := req.UnmarshalParams(&params); err != nil || params.ProtocolVersion != ProtocolVersion {
+			return InvalidParamsError(req.ID, "bad hello params")
+		}
+		resp, _ := NewResponse(req.ID, HelloResult{
+			ProtocolVersion:    ProtocolVersion,
+			MinProtocolVersion: 1,
+			Daemon:             "vpn-managerd",
+			Capabilities:       map[string]bool{CapNftables: true, CapCgroupV2: false},
+		})
+		return resp
+	})
+
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(5*time.Second))
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	if client.HasCapability(CapNftables) {
+		t.Error("HasCapability before Hello must report false")
+	}
+
+	hello, err := client.Hello(ctx)
+	if err != nil {
+		t.Fatalf("Hello failed: %v", err)
+	}
+	if hello.ProtocolVersion != ProtocolVersion || !hello.Has(CapNftables) || hello.Has(CapCgroupV2) {
+		t.Errorf("Hello = %+v", hello)
+	}
+	if !client.HasCapability(CapNftables) || client.HasCapability(CapCgroupV2) || client.HasCapability("unknown") {
+		t.Error("HasCapability does not match the hello result")
+	}
+
+	if _, err := client.Hello(ctx); err != nil {
+		t.Fatalf("second Hello failed: %v", err)
+	}
+	if n := callCount(calls, MethodHello); n != 1 {
+		t.Errorf("system.hello sent %d times, want 1 (cached)", n)
+	}
+}
+
+func TestClientHelloLegacyDaemon(t *testing.T) {
+	socketPath, _ := answerDaemon(t, func(req *Request) *Response {
+		return MethodNotFoundError(req.ID, req.Method)
+	})
+
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(5*time.Second))
+	defer func() { _ = client.Close() }()
+
+	hello, err := client.Hello(context.Background())
+	if err != nil {
+		t.Fatalf("Hello against a daemon without system.hello failed: %v", err)
+	}
+	if hello.ProtocolVersion != 0 || len(hello.Capabilities) != 0 {
+		t.Errorf("Hello = %+v, want protocol 0 and no capabilities", hello)
+	}
+}
+
+func TestClientHelloIncompatible(t *testing.T) {
+	socketPath, _ := answerDaemon(t, func(req *Request) *Response {
+		resp, _ := NewResponse(req.ID, HelloResult{
+			ProtocolVersion:    ProtocolVersion + 2,
+			MinProtocolVersion: ProtocolVersion + 1,
+		})
+		return resp
+	})
+
+	client := NewClient(WithSocketPath(socketPath), WithTimeout(5*time.Second))
+	defer func() { _ = client.Close() }()
+
+	if _, err := client.Hello(context.Background()); !errors.Is(err, ErrIncompatibleDaemon) {
+		t.Errorf("Hello error = %v, want ErrIncompatibleDaemon", err)
+	}
+	if client.HasCapability(CapNftables) {
+		t.Error("an incompatible hello must not be cached")
+	}
+}
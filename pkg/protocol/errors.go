@@ -21,6 +21,16 @@ var (
 	// It caps memory use on the (root) daemon: without it, a client that never
 	// sends a newline could stream gigabytes into a single ReadBytes call.
 	ErrMessageTooLarge = errors.New("message exceeds maximum allowed size")
+
+	// ErrIncompatibleDaemon indicates the daemon no longer serves this client's
+	// protocol version (its MinProtocolVersion is newer than ProtocolVersion).
+	ErrIncompatibleDaemon = errors.New("daemon requires a newer client")
+
+	// ErrRateLimited indicates the daemon refused a call because the caller
+	// exceeded a rate or concurrency limit. Handlers wrap it to have the call
+	// answered with ErrCodeRateLimited, and an RPCError with that code
+	// matches it.
+	ErrRateLimited = errors.New("rate limited")
 )
 
 // IsConnectionError returns true if the error indicates a connection problem.
@@ -0,0 +1,83 @@
+// Package protocol provides the event types pushed by the daemon.
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NotifyEvent is the notification method the daemon uses for every pushed
+// event. The event topic travels inside the params (see Event), so a client
+// needs a single dispatch point regardless of how many topics it follows.
+const NotifyEvent = "events.notify"
+
+// Event topics published by the daemon. A client passes any subset of these to
+// events.subscribe; an empty topic list subscribes to everything.
+const (
+	// TopicOpenVPNState fires on every OpenVPN connection state transition
+	// (connecting, connected, error, disconnected) and when the tunnel IP is learned.
+	TopicOpenVPNState = "openvpn.state"
+
+	// TopicWireGuardLink fires when a WireGuard interface is brought up or down.
+	TopicWireGuardLink = "wireguard.link"
+
+	// TopicKillSwitch fires when the kill switch is enabled, disabled or
+	// switched to block-all.
+	TopicKillSwitch = "killswitch.changed"
+
+	// TopicDNS fires when DNS protection is enabled or disabled.
+	TopicDNS = "dns.changed"
+
+	// TopicIPv6 fires when IPv6 protection is enabled or disabled.
+	TopicIPv6 = "ipv6.changed"
+
+	// TopicGateway fires when the LAN gateway is enabled or disabled.
+	TopicGateway = "gateway.changed"
+
+	// TopicSplitTunnel fires when split tunneling is set up or cleaned up.
+	TopicSplitTunnel = "tunnel.changed"
+
+	// TopicSecurityDrift fires when the daemon finds the system out of line
+	// with the posture set by security.apply. The payload lists what drifted
+	// and which features could not be re-applied.
+	TopicSecurityDrift = "security.drift"
+
+	// TopicOpenVPNLog carries one line of a profile's OpenVPN log. It is not
+	// published to subscribers: only the connection following the log with
+	// openvpn.logs receives it, until that call is cancelled.
+	TopicOpenVPNLog = "openvpn.log"
+)
+
+// Event is the params payload of a NotifyEvent notification.
+type Event struct {
+	// Topic identifies what changed (one of the Topic* constants).
+	Topic string `json:"topic"`
+
+	// Time is when the daemon observed the change.
+	Time time.Time `json:"time"`
+
+	// Data is the topic-specific payload. Its shape mirrors the result of the
+	// matching *.status method, so clients can decode it into the same struct.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// UnmarshalData extracts and unmarshals the event payload into the target struct.
+func (e *Event) UnmarshalData(target any) error {
+	if e.Data == nil {
+		return nil
+	}
+	return json.Unmarshal(e.Data, target)
+}
+
+// SubscribeParams contains parameters for events.subscribe.
+type SubscribeParams struct {
+	// Topics limits delivery to the listed topics. Empty means all topics.
+	Topics []string `json:"topics,omitempty"`
+}
+
+// SubscribeResult is the result of events.subscribe and events.unsubscribe.
+type SubscribeResult struct {
+	Subscribed bool `json:"subscribed"`
+	// Topics echoes the topics requested (empty for all topics).
+	Topics []string `json:"topics,omitempty"`
+}
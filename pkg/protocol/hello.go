@@ -0,0 +1,80 @@
+package protocol
+
+// MethodHello is the handshake a client sends once per connection to learn the
+// daemon's protocol version and what the host it runs on supports.
+const MethodHello = "system.hello"
+
+// ProtocolVersion is the version of the method set this package speaks. Bump
+// it when a method is added or changes shape, so a client can tell an older
+// daemon apart before calling something it does not have.
+const ProtocolVersion = 1
+
+// MinProtocolVersion is the oldest client protocol version the daemon still
+// serves. A client older than the daemon's minimum must not talk to it.
+const MinProtocolVersion = 1
+
+// Capability flags reported by system.hello. A flag that is absent from the
+// result (an older daemon, or a probe the daemon does not run) means the same
+// as false.
+const (
+	// CapNftables: the nft binary is installed; the kill switch uses nftables.
+	CapNftables = "nftables"
+
+	// CapIptables: the iptables binary is installed.
+	CapIptables = "iptables"
+
+	// CapIP6tables: ip6tables is installed, so IPv6 leaks can be blocked.
+	CapIP6tables = "ip6tables"
+
+	// CapCgroupV2: the unified cgroup hierarchy is mounted (app tunneling).
+	CapCgroupV2 = "cgroup_v2"
+
+	// CapWireGuardKernel: the wireguard kernel module is loaded or loadable.
+	CapWireGuardKernel = "wireguard_kernel"
+
+	// CapWireGuardTools: wg and wg-quick are installed.
+	CapWireGuardTools = "wireguard_tools"
+
+	// CapOpenVPN: the openvpn binary is installed.
+	CapOpenVPN = "openvpn"
+
+	// CapTailscale: the tailscale CLI is installed.
+	CapTailscale = "tailscale"
+
+	// CapResolved: systemd-resolved is running and manages DNS.
+	CapResolved = "systemd_resolved"
+
+	// CapNetworkManager: NetworkManager is running.
+	CapNetworkManager = "network_manager"
+)
+
+// HelloParams are the parameters of system.hello.
+type HelloParams struct {
+	// ProtocolVersion is the client's ProtocolVersion.
+	ProtocolVersion int `json:"protocol_version"`
+
+	// Client names the caller (e.g. "vpn-manager", "vpnctl") for the daemon log.
+	Client string `json:"client,omitempty"`
+}
+
+// HelloResult is the result of system.hello.
+type HelloResult struct {
+	// ProtocolVersion is the daemon's ProtocolVersion. 0 means the daemon
+	// predates system.hello (see Client.Hello).
+	ProtocolVersion int `json:"protocol_version"`
+
+	// MinProtocolVersion is the oldest client version the daemon serves.
+	MinProtocolVersion int `json:"min_protocol_version"`
+
+	// Daemon is the daemon's name and Version its release.
+	Daemon  string `json:"daemon"`
+	Version string `json:"version"`
+
+	// Capabilities maps each Cap* flag the daemon probed to its value.
+	Capabilities map[string]bool `json:"capabilities"`
+}
+
+// Has reports whether the daemon reported the capability as present.
+func (r *HelloResult) Has(capability string) bool {
+	return r != nil && r.Capabilities[capability]
+}
@@ -8,6 +8,7 @@ package protocol
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // JSONRPCVersion is the JSON-RPC protocol version we implement.
@@ -46,6 +47,21 @@ type Response struct {
 	ID int `json:"id"`
 }
 
+// Notification represents a JSON-RPC 2.0 notification: a request object
+// without an ID, to which the receiver never replies. The daemon uses them to
+// push events to clients that called events.subscribe.
+// See: https://www.jsonrpc.org/specification#notification
+type Notification struct {
+	// JSONRPC specifies the protocol version. MUST be "2.0".
+	JSONRPC string `json:"jsonrpc"`
+
+	// Method identifies the notification kind (e.g. NotifyEvent).
+	Method string `json:"method"`
+
+	// Params holds the notification payload. Can be nil.
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
 // RPCError represents a JSON-RPC 2.0 error object.
 // See: https://www.jsonrpc.org/specification#error_object
 type RPCError struct {
@@ -67,6 +83,12 @@ func (e *RPCError) Error() string {
 	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
 }
 
+// Is reports whether the error is the sentinel for its code, so callers can
+// test errors.Is(err, ErrRateLimited) without decoding the RPCError.
+func (e *RPCError) Is(target error) bool {
+	return target == ErrRateLimited && e.Code == ErrCodeRateLimited
+}
+
 // Standard JSON-RPC 2.0 error codes.
 // See: https://www.jsonrpc.org/specification#error_object
 const (
@@ -99,8 +121,40 @@ const (
 
 	// ErrCodeNotAvailable indicates a required resource is not available.
 	ErrCodeNotAvailable = -32004
+
+	// ErrCodeCancelled indicates the client cancelled the request (see
+	// MethodCancelRequest) before its handler finished.
+	ErrCodeCancelled = -32005
+
+	// ErrCodeRateLimited indicates the caller exceeded a rate or concurrency
+	// limit; the data is a RateLimitedData saying when to retry.
+	ErrCodeRateLimited = -32006
 )
 
+// RateLimitedData is the data of an ErrCodeRateLimited error.
+type RateLimitedData struct {
+	// Reason says which limit was hit, for display.
+	Reason string `json:"reason"`
+
+	// RetryAfterMS is how long to wait before the same call can succeed, in
+	// milliseconds. Zero means unknown: the limit is on work in progress, so
+	// it depends on when that finishes.
+	RetryAfterMS int64 `json:"retry_after_ms,omitempty"`
+}
+
+// MethodCancelRequest is the notification a client sends to cancel one of its
+// requests that is still running (or still queued) on the same connection.
+// The daemon cancels the handler's context, the handler undoes what it had
+// already changed, and the request is answered with ErrCodeCancelled. A
+// request that already finished is not affected.
+const MethodCancelRequest = "$/cancelRequest"
+
+// CancelParams are the params of MethodCancelRequest.
+type CancelParams struct {
+	// ID is the ID of the request to cancel.
+	ID int `json:"id"`
+}
+
 // NewRequest creates a new JSON-RPC request with the given method and params.
 // Params will be marshaled to JSON. Pass nil for methods without parameters.
 func NewRequest(id int, method string, params any) (*Request, error) {
@@ -187,6 +241,46 @@ func OperationFailedError(id int, err error) *Response {
 	return NewErrorResponse(id, ErrCodeOperationFailed, "Operation failed", err.Error())
 }
 
+// CancelledError creates a request cancelled error response.
+func CancelledError(id int) *Response {
+	return NewErrorResponse(id, ErrCodeCancelled, "Request cancelled", nil)
+}
+
+// RateLimitedError creates a rate limited error response.
+func RateLimitedError(id int, reason string, retryAfter time.Duration) *Response {
+	return NewErrorResponse(id, ErrCodeRateLimited, "Too many requests", RateLimitedData{
+		Reason:       reason,
+		RetryAfterMS: retryAfter.Milliseconds(),
+	})
+}
+
+// NewNotification creates a JSON-RPC notification with the given method and params.
+// Params will be marshaled to JSON. Pass nil for notifications without parameters.
+func NewNotification(method string, params any) (*Notification, error) {
+	n := &Notification{
+		JSONRPC: JSONRPCVersion,
+		Method:  method,
+	}
+
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("marshal params: %w", err)
+		}
+		n.Params = data
+	}
+
+	return n, nil
+}
+
+// UnmarshalParams extracts and unmarshals notification params into the target struct.
+func (n *Notification) UnmarshalParams(target any) error {
+	if n.Params == nil {
+		return nil
+	}
+	return json.Unmarshal(n.Params, target)
+}
+
 // UnmarshalParams extracts and unmarshals request params into the target struct.
 func (r *Request) UnmarshalParams(target any) error {
 	if r.Params == nil {
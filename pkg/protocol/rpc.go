@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 )
 
 // MaxMessageBytes is the maximum size of a single newline-delimited message the
@@ -44,7 +45,7 @@ type Codec struct {
 	writeMu sync.Mutex
 
 	reader *bufio.Reader
-	closed bool
+	closed atomic.Bool
 }
 
 // NewCodec creates a new codec wrapping the given connection.
@@ -60,7 +61,7 @@ func (c *Codec) WriteRequest(req *Request) error {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 
-	if c.closed {
+	if c.closed.Load() {
 		return ErrConnectionClosed
 	}
 
@@ -72,13 +73,27 @@ func (c *Codec) WriteResponse(resp *Response) error {
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
 
-	if c.closed {
+	if c.closed.Load() {
 		return ErrConnectionClosed
 	}
 
 	return c.writeMessage(resp)
 }
 
+// WriteNotification encodes and writes a notification to the connection.
+// It shares the write lock with WriteResponse, so notifications pushed from
+// another goroutine never interleave with a response on the wire.
+func (c *Codec) WriteNotification(n *Notification) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed.Load() {
+		return ErrConnectionClosed
+	}
+
+	return c.writeMessage(n)
+}
+
 // writeMessage marshals and writes a message with newline delimiter.
 // Must be called with writeMu held.
 func (c *Codec) writeMessage(msg any) error {
@@ -103,7 +118,7 @@ func (c *Codec) ReadRequest() (*Request, error) {
 	c.readMu.Lock()
 	defer c.readMu.Unlock()
 
-	if c.closed {
+	if c.closed.Load() {
 		return nil, ErrConnectionClosed
 	}
 
@@ -133,7 +148,7 @@ func (c *Codec) ReadResponse() (*Response, error) {
 	c.readMu.Lock()
 	defer c.readMu.Unlock()
 
-	if c.closed {
+	if c.closed.Load() {
 		return nil, ErrConnectionClosed
 	}
 
@@ -158,26 +173,70 @@ func (c *Codec) ReadResponse() (*Response, error) {
 	return &resp, nil
 }
 
-// Close closes the underlying connection.
+// ReadMessage reads the next server-to-client message, which is either a
+// response to an earlier request or a notification. Exactly one of the returned
+// pointers is non-nil on success. A message carrying a "method" member is a
+// notification; anything else is decoded as a response.
+func (c *Codec) ReadMessage() (*Response, *Notification, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.closed.Load() {
+		return nil, nil, ErrConnectionClosed
+	}
+
+	line, err := readLimitedLine(c.reader, MaxMessageBytes)
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, ErrConnectionClosed
+		}
+		return nil, nil, fmt.Errorf("read message: %w", err)
+	}
+
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+	}
+	if err := json.Unmarshal(line, &probe); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal message: %w", err)
+	}
+
+	// Validate JSON-RPC version
+	if probe.JSONRPC != JSONRPCVersion {
+		return nil, nil, fmt.Errorf("invalid jsonrpc version: %s", probe.JSONRPC)
+	}
+
+	if probe.Method != "" {
+		var n Notification
+		if err := json.Unmarshal(line, &n); err != nil {
+			return nil, nil, fmt.Errorf("unmarshal notification: %w", err)
+		}
+		return nil, &n, nil
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return &resp, nil, nil
+}
+
+// Close closes the underlying connection. It does not wait for a reader
+// blocked in ReadMessage/ReadRequest (which holds the read lock until a line
+// arrives): closing the connection is what unblocks that reader.
 func (c *Codec) Close() error {
 	c.writeMu.Lock()
-	c.readMu.Lock()
 	defer c.writeMu.Unlock()
-	defer c.readMu.Unlock()
 
-	if c.closed {
+	if !c.closed.CompareAndSwap(false, true) {
 		return nil
 	}
-
-	c.closed = true
 	return c.conn.Close()
 }
 
 // IsClosed returns true if the codec has been closed.
 func (c *Codec) IsClosed() bool {
-	c.readMu.Lock()
-	defer c.readMu.Unlock()
-	return c.closed
+	return c.closed.Load()
 }
 
 // Encoder writes JSON-RPC messages to a writer.
@@ -3,9 +3,12 @@ package protocol
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewRequest(t *testing.T) {
@@ -309,6 +312,7 @@ func TestErrorHelpers(t *testing.T) {
 		{"InternalError", func() *Response { return InternalError(1, io.EOF) }, ErrCodeInternal},
 		{"UnauthorizedError", func() *Response { return UnauthorizedError(1) }, ErrCodeUnauthorized},
 		{"OperationFailedError", func() *Response { return OperationFailedError(1, io.EOF) }, ErrCodeOperationFailed},
+		{"RateLimitedError", func() *Response { return RateLimitedError(1, "slow down", time.Second) }, ErrCodeRateLimited},
 	}
 
 	for _, tt := range tests {
@@ -326,6 +330,19 @@ func TestErrorHelpers(t *testing.T) {
 	}
 }
 
+func TestRPCErrorIsRateLimited(t *testing.T) {
+	resp := RateLimitedError(1, "too many connects", 1500*time.Millisecond)
+	if !errors.Is(fmt.Errorf("call: %w", resp.Error), ErrRateLimited) {
+		t.Error("a rate limited RPCError should match ErrRateLimited")
+	}
+	if data, ok := resp.Error.Data.(RateLimitedData); !ok || data.RetryAfterMS != 1500 {
+		t.Errorf("Data = %#v", resp.Error.Data)
+	}
+	if errors.Is(UnauthorizedError(1).Error, ErrRateLimited) {
+		t.Error("only ErrCodeRateLimited should match ErrRateLimited")
+	}
+}
+
 // mockConn implements io.ReadWriteCloser for testing
 type mockConn struct {
 	readBuf  *bytes.Buffer
@@ -512,3 +529,71 @@ func TestEncoderDecoder(t *testing.T) {
 		t.Errorf("Method = %q, want %q", decoded.Method, req.Method)
 	}
 }
+
+func TestCodecReadMessage(t *testing.T) {
+	serverConn := newMockConn()
+	serverCodec := NewCodec(serverConn)
+
+	n, err := NewNotification(NotifyEvent, Event{Topic: TopicKillSwitch, Data: json.RawMessage(`{"enabled":true}`)})
+	if err != nil {
+		t.Fatalf("NewNotification failed: %v", err)
+	}
+	if err := serverCodec.WriteNotification(n); err != nil {
+		t.Fatalf("WriteNotification failed: %v", err)
+	}
+	resp, _ := NewResponse(7, "ok")
+	if err := serverCodec.WriteResponse(resp); err != nil {
+		t.Fatalf("WriteResponse failed: %v", err)
+	}
+
+	clientConn := newMockConn()
+	clientConn.readBuf.Write(serverConn.writeBuf.Bytes())
+	clientCodec := NewCodec(clientConn)
+
+	// First message is the notification
+	gotResp, gotNotif, err := clientCodec.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if gotResp != nil || gotNotif == nil {
+		t.Fatalf("expected notification, got resp=%v notif=%v", gotResp, gotNotif)
+	}
+	if gotNotif.Method != NotifyEvent {
+		t.Errorf("Method = %q, want %q", gotNotif.Method, NotifyEvent)
+	}
+
+	var ev Event
+	if err := gotNotif.UnmarshalParams(&ev); err != nil {
+		t.Fatalf("UnmarshalParams failed: %v", err)
+	}
+	if ev.Topic != TopicKillSwitch {
+		t.Errorf("Topic = %q, want %q", ev.Topic, TopicKillSwitch)
+	}
+	var data struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := ev.UnmarshalData(&data); err != nil || !data.Enabled {
+		t.Errorf("UnmarshalData = %+v, %v; want enabled", data, err)
+	}
+
+	// Second message is the response
+	gotResp, gotNotif, err = clientCodec.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if gotResp == nil || gotNotif != nil {
+		t.Fatalf("expected response, got resp=%v notif=%v", gotResp, gotNotif)
+	}
+	if gotResp.ID != 7 {
+		t.Errorf("Response ID = %d, want 7", gotResp.ID)
+	}
+}
+
+func TestCodecReadMessageInvalidVersion(t *testing.T) {
+	conn := newMockConn()
+	conn.readBuf.WriteString(`{"jsonrpc":"1.0","method":"events.notify"}` + "\n")
+
+	if _, _, err := NewCodec(conn).ReadMessage(); err == nil {
+		t.Error("ReadMessage should reject an invalid jsonrpc version")
+	}
+}
@@ -0,0 +1,63 @@
+package protocol
+
+// MethodDescribe is the built-in method that lists every registered method
+// with the JSON Schema of its params and result.
+const MethodDescribe = "system.describe"
+
+// JSONSchemaDialect is the JSON Schema version system.describe produces.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema the daemon uses to describe its methods.
+// Named Go structs are described once under DescribeResult.Defs and referenced
+// with Ref ("#/$defs/<Name>"), so a Ref resolves against the describe result.
+type Schema struct {
+	Ref   string `json:"$ref,omitempty"`
+	Title string `json:"title,omitempty"`
+
+	// Type is one of "object", "array", "string", "integer", "number",
+	// "boolean" or "null". Empty means any JSON value.
+	Type string `json:"type,omitempty"`
+
+	// Format refines Type: "date-time" and "byte" for strings, the Go width
+	// ("int32", "uint64", ...) for integers, "float" for float32.
+	Format string `json:"format,omitempty"`
+
+	// Properties and Required describe an object's fields; a field is required
+	// unless it is omitted when empty.
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+
+	// AdditionalProperties describes the values of a map.
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+
+	// Items describes the elements of an array.
+	Items *Schema `json:"items,omitempty"`
+
+	// AnyOf is used for optional values: {"anyOf": [<value>, {"type": "null"}]}.
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+
+	// GoName is the Go field name of a property, so generated Go clients keep
+	// the daemon's names (VPNInterface, not VpnInterface).
+	GoName string `json:"x-go-name,omitempty"`
+}
+
+// MethodDescription describes one RPC method. Params is absent for methods
+// that take none; Result is absent for methods whose result is not described.
+type MethodDescription struct {
+	Name    string  `json:"name"`
+	Summary string  `json:"summary,omitempty"`
+	Params  *Schema `json:"params,omitempty"`
+	Result  *Schema `json:"result,omitempty"`
+}
+
+// DescribeResult is the result of system.describe.
+type DescribeResult struct {
+	Schema  string              `json:"$schema"`
+	Methods []MethodDescription `json:"methods"`
+	Defs    map[string]*Schema  `json:"$defs,omitempty"`
+}
+
+// DefRef returns the Ref that points at the named definition.
+func DefRef(name string) string {
+	return "#/$defs/" + name
+}
@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,6 +12,7 @@ import (
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/yllada/vpn-manager/internal/config"
+	"github.com/yllada/vpn-manager/internal/daemon"
 	"github.com/yllada/vpn-manager/internal/eventbus"
 	"github.com/yllada/vpn-manager/internal/keyring"
 	"github.com/yllada/vpn-manager/internal/logger"
@@ -40,6 +42,9 @@ type Application struct {
 
 	// Event subscriptions for cleanup
 	trustAuthSubscription *eventbus.Subscription
+	challengeSubscription *eventbus.Subscription
+	expirySubscription    *eventbus.Subscription
+	agentEventsCancel     context.CancelFunc
 }
 
 // NewApplication creates a new application.
@@ -55,6 +60,13 @@ func NewApplication(appID, version string, startMinimized bool) (*Application, e
 		return nil, fmt.Errorf("failed to create VPN manager: %w", err)
 	}
 
+	// When the session agent is running it owns the OpenVPN connections
+	// (auto-reconnect, trusted networks, stats); the GUI becomes its client.
+	if daemon.IsAgentAvailable() {
+		logger.LogInfo("Session agent detected; delegating connections to vpn-manager-agent")
+		vpnManager.UseSessionAgent()
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -73,7 +85,8 @@ func NewApplication(appID, version string, startMinimized bool) (*Application, e
 
 	// Apply persisted security settings to the runtime — the config is otherwise
 	// only read to populate the Preferences UI, so without this the kill switch
-	// stays Off regardless of what the user configured.
+	// stays Off regardless of what the user configured. In agent mode these only
+	// feed the Preferences UI; the agent arms its own from the same config.
 	vpnManager.ApplyKillSwitchConfig(cfg.Security.KillSwitchMode, cfg.Security.KillSwitchLAN)
 	vpnManager.ApplyDNSConfig(cfg.Security.DNSMode, cfg.Security.CustomDNS, cfg.Security.BlockDoH, cfg.Security.BlockDoT)
 	vpnManager.ApplyIPv6Config(cfg.Security.IPv6Mode)
@@ -151,12 +164,21 @@ func (a *Application) onActivate() {
 		a.tray.Run()
 	})
 
-	// Configure and start health checker if auto-reconnect is enabled
-	a.setupHealthChecker()
+	if a.vpnManager.AgentMode() {
+		// The agent runs the health checker and trust rules; it asks us for
+		// OTP codes when it cannot connect on its own.
+		a.subscribeAgentAuth()
+	} else {
+		// Configure and start health checker if auto-reconnect is enabled
+		a.setupHealthChecker()
+
+		// Initialize trust management system (network-based auto-VPN control)
+		if err := a.vpnManager.InitTrustManagement(); err != nil {
+			logger.LogWarn("Failed to initialize trust management: %v", err)
+		}
 
-	// Initialize trust management system (network-based auto-VPN control)
-	if err := a.vpnManager.InitTrustManagement(); err != nil {
-		logger.LogWarn("Failed to initialize trust management: %v", err)
+		// Warn before profile certificates expire (the agent does in agent mode)
+		a.setupExpiryWarnings()
 	}
 
 	// Subscribe to trust auth required events (OTP needed during auto-connect)
@@ -170,6 +192,13 @@ func (a *Application) onActivate() {
 			logger.LogError("EventTrustAuthRequired: failed to cast data, type=%T", event.Data)
 		}
 	})
+
+	// Subscribe to two-factor challenges asked while connecting
+	a.challengeSubscription = eventbus.On(eventbus.EventAuthOTPRequired, func(event *eventbus.Event) {
+		if data, ok := event.Data.(eventbus.AuthChallengeData); ok {
+			a.handleAuthChallenge(data)
+		}
+	})
 }
 
 // setupAppIcon sets up the application icon
@@ -269,6 +298,19 @@ func (a *Application) Cleanup() {
 		a.trustAuthSubscription.Unsubscribe()
 		a.trustAuthSubscription = nil
 	}
+	if a.challengeSubscription != nil {
+		a.challengeSubscription.Unsubscribe()
+		a.challengeSubscription = nil
+	}
+	if a.expirySubscription != nil {
+		a.expirySubscription.Unsubscribe()
+		a.expirySubscription = nil
+	}
+	a.vpnManager.StopExpiryChecker()
+	if a.agentEventsCancel != nil {
+		a.agentEventsCancel()
+		a.agentEventsCancel = nil
+	}
 
 	if a.window != nil {
 		if a.window.tailscalePanel != nil {
@@ -403,6 +445,93 @@ func (a *Application) setupHealthChecker() {
 	a.vpnManager.StartHealthChecker()
 }
 
+// setupExpiryWarnings notifies the user as the certificates of their profiles
+// near their expiry, and starts the checker that watches them.
+func (a *Application) setupExpiryWarnings() {
+	a.expirySubscription = eventbus.On(eventbus.EventCertificateExpiring, func(event *eventbus.Event) {
+		data, ok := event.Data.(eventbus.CertificateExpiryData)
+		if !ok {
+			return
+		}
+		if a.config.ShowNotifications {
+			notify.CertificateExpiring(data.Message)
+		}
+		glib.IdleAdd(func() {
+			if a.window != nil {
+				a.window.SetStatus(data.Message)
+			}
+		})
+	})
+
+	a.vpnManager.StartExpiryChecker()
+}
+
+// subscribeAgentAuth routes the session agent's requests for credentials
+// (OTP for auto-connect, trusted-network rules or reconnects) to the same
+// OTP dialog the in-process trust manager uses.
+func (a *Application) subscribeAgentAuth() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.agentEventsCancel = cancel
+
+	events, err := daemon.SubscribeAgentEvents(ctx, daemon.AgentTopicAuthRequired)
+	if err != nil {
+		logger.LogWarn("Failed to subscribe to session agent events: %v", err)
+		return
+	}
+	resilience.SafeGoWithName("agent-auth-events", func() {
+		for ev := range events {
+			var req daemon.AgentAuthRequiredEvent
+			if err := ev.UnmarshalData(&req); err != nil {
+				logger.LogError("Invalid agent auth request: %v", err)
+				continue
+			}
+			logger.LogInfo("Session agent needs credentials for %s (%s)", req.ProfileName, req.Reason)
+			if req.Challenge != nil {
+				a.handleAuthChallenge(eventbus.AuthChallengeData{
+					ProfileID:        req.ProfileID,
+					ProfileName:      req.ProfileName,
+					Text:             req.Challenge.Text,
+					Echo:             req.Challenge.Echo,
+					ResponseRequired: req.Challenge.ResponseRequired,
+				})
+				continue
+			}
+			a.handleTrustAuthRequired(eventbus.TrustAuthRequiredData{
+				SSID:        req.SSID,
+				ProfileID:   req.ProfileID,
+				ProfileName: req.ProfileName,
+				Username:    req.Username,
+				NeedsOTP:    req.NeedsOTP,
+			})
+		}
+	})
+}
+
+// handleAuthChallenge asks the user to answer the two-factor question a
+// connecting profile's server asked. The session agent has already sent a
+// notification in agent mode.
+func (a *Application) handleAuthChallenge(data eventbus.AuthChallengeData) {
+	glib.IdleAdd(func() {
+		profile, err := a.vpnManager.ProfileManager().Get(data.ProfileID)
+		if err != nil {
+			logger.LogError("Failed to get profile for challenge: %v", err)
+			return
+		}
+
+		if a.config.ShowNotifications && !a.vpnManager.AgentMode() {
+			notify.ConnectionError(profile.Name, data.Text)
+		}
+
+		if a.window != nil && a.window.openvpnPanel != nil {
+			a.window.SetStatus(fmt.Sprintf("%s is waiting for verification", profile.Name))
+			if pl := a.window.openvpnPanel.GetProfileList(); pl != nil {
+				pl.ShowChallengeDialog(profile, data)
+				a.showWindow()
+			}
+		}
+	})
+}
+
 // handleTrustAuthRequired handles the trust auth required event.
 // Called when auto-connect on untrusted network needs OTP authentication.
 func (a *Application) handleTrustAuthRequired(data eventbus.TrustAuthRequiredData) {
@@ -414,8 +543,8 @@ func (a *Application) handleTrustAuthRequired(data eventbus.TrustAuthRequiredDat
 			return
 		}
 
-		// Show notification to user
-		if a.config.ShowNotifications {
+		// Show notification to user (the session agent has already sent one)
+		if a.config.ShowNotifications && !a.vpnManager.AgentMode() {
 			notify.ConnectionError(profile.Name,
 				fmt.Sprintf("Connected to untrusted network '%s' - OTP required", data.SSID))
 		}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	vpnerrors "github.com/yllada/vpn-manager/internal/errors"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 // errorHint maps a class of failure to a friendly title and an actionable body.
@@ -21,6 +22,8 @@ type errorHint struct {
 	substrings []string
 	// codes matched against a *vpnerrors.VPNError when the error carries one.
 	codes []vpnerrors.ErrorCode
+	// sentinels matched with errors.Is, e.g. protocol errors from the daemon.
+	sentinels []error
 }
 
 // errorHints is the ordered, high-impact set of known failures. It is intentionally
@@ -81,6 +84,15 @@ var errorHints = []errorHint{
 		substrings: []string{"already connected", "already connecting"},
 		codes:      []vpnerrors.ErrorCode{vpnerrors.ErrCodeAlreadyConnected},
 	},
+	{
+		title: "Too many requests",
+		body: "The background service turned this request down because too many arrived in a short time.\n\n" +
+			"Wait a few seconds and try again. If it keeps happening, another program may be calling vpn-managerd in a loop; " +
+			"its log shows which user and process:\n\n" +
+			"    journalctl -u vpn-managerd",
+		substrings: []string{"too many requests", "rate limited"},
+		sentinels:  []error{protocol.ErrRateLimited},
+	},
 }
 
 // ExplainError converts an error into a user-facing (title, body) pair with an
@@ -101,9 +113,17 @@ func ExplainError(fallbackTitle string, err error) (title, body string) {
 	return fallbackTitle, raw
 }
 
-// matchHint finds the first hint matching err, preferring a structured VPNError
-// code over substring matching.
+// matchHint finds the first hint matching err, preferring a sentinel error or a
+// structured VPNError code over substring matching.
 func matchHint(err error, raw string) (errorHint, bool) {
+	for _, h := range errorHints {
+		for _, sentinel := range h.sentinels {
+			if errors.Is(err, sentinel) {
+				return h, true
+			}
+		}
+	}
+
 	var verr *vpnerrors.VPNError
 	if errors.As(err, &verr) {
 		for _, h := range errorHints {
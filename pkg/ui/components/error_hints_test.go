@@ -2,10 +2,12 @@ package components
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
 	vpnerrors "github.com/yllada/vpn-manager/internal/errors"
+	"github.com/yllada/vpn-manager/pkg/protocol"
 )
 
 func TestExplainError_RawSubstringMatch(t *testing.T) {
@@ -66,3 +68,12 @@ func TestExplainError_VPNErrorCodeWins(t *testing.T) {
 		t.Errorf("title = %q, want %q", title, "Sign-in rejected")
 	}
 }
+
+func TestExplainError_RateLimited(t *testing.T) {
+	// The daemon's refusal is matched by its sentinel, however it is worded.
+	err := fmt.Errorf("connect: %w", &protocol.RPCError{Code: protocol.ErrCodeRateLimited, Message: "slow down"})
+	title, _ := ExplainError("Connection error", err)
+	if title != "Too many requests" {
+		t.Errorf("title = %q, want %q", title, "Too many requests")
+	}
+}
@@ -50,12 +50,15 @@ type DiagnosticsConfig struct {
 	Height      int                // Content height (0 → defaultDiagnosticsHeight)
 	Timeout     time.Duration      // Overall run timeout (0 → defaultDiagnosticsTimeout)
 	Probes      func() []ProbeFunc // Produces the probe set for each run
+	FollowLog   LogFollowFunc      // Adds a Log page following this log (nil → no Log page)
 }
 
 // DiagnosticsView is a self-contained diagnostics dialog: it owns the dialog
 // chrome, runs a set of probes concurrently off the GTK main thread, marshals
 // each result back via glib.IdleAdd, and drives the spinner/run-button state.
-// It cancels the in-flight run when the dialog is closed.
+// It cancels the in-flight run when the dialog is closed. Another page lists
+// the daemon's audit trail (see diagnostics_audit.go), and one, if configured,
+// follows a log live (see diagnostics_log.go).
 //
 // All exported/UI methods (Present, run, finish, AddResult, ClearResults) and the
 // running/closed/cancelFunc state are touched ONLY on the GTK main thread; the
@@ -63,6 +66,8 @@ type DiagnosticsConfig struct {
 type DiagnosticsView struct {
 	dialog       *adw.Dialog
 	parent       gtk.Widgetter
+	audit        *auditPage
+	log          *logPage // nil without cfg.FollowLog
 	spinner      *gtk.Spinner
 	resultsGroup *adw.PreferencesGroup
 	runBtn       *gtk.Button
@@ -104,9 +109,16 @@ func NewDiagnosticsView(cfg DiagnosticsConfig, parent gtk.Widgetter) *Diagnostic
 	dialog.SetContentHeight(height)
 	v.dialog = dialog
 
-	// Toolbar view gives a proper header bar with a close button.
+	// Toolbar view gives a proper header bar with a close button; its title
+	// switches between the probes, the log and the audit trail.
+	stack := adw.NewViewStack()
+	switcher := adw.NewViewSwitcher()
+	switcher.SetStack(stack)
+	switcher.SetPolicy(adw.ViewSwitcherPolicyWide)
+	headerBar := adw.NewHeaderBar()
+	headerBar.SetTitleWidget(switcher)
 	toolbarView := adw.NewToolbarView()
-	toolbarView.AddTopBar(adw.NewHeaderBar())
+	toolbarView.AddTopBar(headerBar)
 
 	content := gtk.NewBox(gtk.OrientationVertical, 12)
 	content.SetMarginTop(12)
@@ -128,7 +140,29 @@ func NewDiagnosticsView(cfg DiagnosticsConfig, parent gtk.Widgetter) *Diagnostic
 	v.runBtn.AddCSSClass("suggested-action")
 	content.Append(v.runBtn)
 
-	toolbarView.SetContent(content)
+	stack.AddTitledWithIcon(content, "diagnostics", "Diagnostics", "network-workgroup-symbolic")
+	if cfg.FollowLog != nil {
+		v.log = newLogPage(cfg.FollowLog)
+		stack.AddTitledWithIcon(v.log.widget, "log", "Log", "text-x-generic-symbolic")
+	}
+	v.audit = newAuditPage()
+	stack.AddTitledWithIcon(v.audit.widget, "audit", "Audit", "document-open-recent-symbolic")
+	// The audit trail is read, and the log followed until the dialog closes,
+	// from the first time their page is shown.
+	stack.NotifyProperty("visible-child-name", func() {
+		switch stack.VisibleChildName() {
+		case "audit":
+			if !v.audit.loaded {
+				v.audit.refresh()
+			}
+		case "log":
+			if !v.log.started {
+				v.log.start()
+			}
+		}
+	})
+
+	toolbarView.SetContent(stack)
 	dialog.SetChild(toolbarView)
 
 	v.runBtn.ConnectClicked(v.run)
@@ -136,6 +170,10 @@ func NewDiagnosticsView(cfg DiagnosticsConfig, parent gtk.Widgetter) *Diagnostic
 	// stop promptly and their results are not applied to a dead widget tree.
 	dialog.ConnectClosed(func() {
 		v.closed = true
+		v.audit.closed = true
+		if v.log != nil {
+			v.log.close()
+		}
 		if v.cancelFunc != nil {
 			v.cancelFunc()
 		}
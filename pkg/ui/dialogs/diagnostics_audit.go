@@ -0,0 +1,255 @@
+// Package dialogs provides the graphical user interface for VPN Manager.
+// This file contains the Audit page of the diagnostics dialog: the daemon's
+// record of privileged calls (who connected, who enabled the LAN gateway, and
+// when), read through audit.query.
+package dialogs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/resilience"
+)
+
+// auditQueryTimeout bounds one audit.query round trip.
+const auditQueryTimeout = 10 * time.Second
+
+// auditPageLimit is how many records the page asks for.
+const auditPageLimit = 200
+
+// auditRanges are the time windows offered by the page, in drop-down order.
+// A zero window means no lower bound.
+var auditRanges = []struct {
+	label  string
+	window time.Duration
+}{
+	{"Last hour", time.Hour},
+	{"Last 24 hours", 24 * time.Hour},
+	{"Last 7 days", 7 * 24 * time.Hour},
+	{"All", 0},
+}
+
+// auditQueryFunc fetches audit records. It runs OFF the GTK main thread.
+type auditQueryFunc func(ctx context.Context, params daemon.AuditQueryParams) (*daemon.AuditQueryResult, error)
+
+// auditPage lists audit records with method, time and "only my calls"
+// filters. Like DiagnosticsView, every method and field is touched ONLY on
+// the GTK main thread; the query goroutine hands its result back via
+// glib.IdleAdd.
+type auditPage struct {
+	widget      *gtk.Box
+	methodEntry *gtk.SearchEntry
+	rangeDrop   *gtk.DropDown
+	onlyMine    *gtk.CheckButton
+	refreshBtn  *gtk.Button
+	status      *gtk.Label
+	group       *adw.PreferencesGroup
+	rows        []*adw.ActionRow
+
+	query   auditQueryFunc
+	loaded  bool // A query has been started at least once
+	loading bool // A query is in flight
+	pending bool // The filters changed while loading; query again after
+	closed  bool // The dialog was dismissed
+}
+
+// newAuditPage builds the Audit page. It queries nothing until refresh is
+// first called (when the page is shown).
+func newAuditPage() *auditPage {
+	p := &auditPage{
+		widget:      gtk.NewBox(gtk.OrientationVertical, 12),
+		methodEntry: gtk.NewSearchEntry(),
+		rangeDrop:   gtk.NewDropDownFromStrings(auditRangeLabels()),
+		onlyMine:    gtk.NewCheckButtonWithLabel("Only my calls"),
+		refreshBtn:  gtk.NewButtonFromIconName("view-refresh-symbolic"),
+		status:      gtk.NewLabel(""),
+		group:       adw.NewPreferencesGroup(),
+		query: func(ctx context.Context, params daemon.AuditQueryParams) (*daemon.AuditQueryResult, error) {
+			client := &daemon.AuditClient{}
+			return client.QueryWithContext(ctx, params)
+		},
+	}
+
+	p.widget.SetMarginTop(12)
+	p.widget.SetMarginBottom(24)
+	p.widget.SetMarginStart(24)
+	p.widget.SetMarginEnd(24)
+
+	header := gtk.NewLabel("Privileged operations recorded by the daemon")
+	header.AddCSSClass("dim-label")
+	p.widget.Append(header)
+
+	filters := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	p.methodEntry.SetPlaceholderText("Method, e.g. gateway.*")
+	p.methodEntry.SetHExpand(true)
+	filters.Append(p.methodEntry)
+	p.rangeDrop.SetSelected(1) // Last 24 hours
+	filters.Append(p.rangeDrop)
+	p.refreshBtn.SetTooltipText("Refresh")
+	filters.Append(p.refreshBtn)
+	p.widget.Append(filters)
+	p.widget.Append(p.onlyMine)
+
+	p.status.AddCSSClass("dim-label")
+	p.status.SetWrap(true)
+	p.status.SetVisible(false)
+	p.widget.Append(p.status)
+
+	scrolled := gtk.NewScrolledWindow()
+	scrolled.SetVExpand(true)
+	scrolled.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	scrolled.SetChild(p.group)
+	p.widget.Append(scrolled)
+
+	p.refreshBtn.ConnectClicked(p.refresh)
+	p.methodEntry.ConnectActivate(p.refresh)
+	p.rangeDrop.NotifyProperty("selected", p.refresh)
+	p.onlyMine.ConnectToggled(p.refresh)
+
+	return p
+}
+
+// refresh re-runs the query with the current filters. Main-thread only.
+func (p *auditPage) refresh() {
+	if p.loading {
+		p.pending = true
+		return
+	}
+	p.loading = true
+	p.loaded = true
+	p.refreshBtn.SetSensitive(false)
+
+	params := auditQueryParams(p.methodEntry.Text(), p.rangeDrop.Selected(), p.onlyMine.Active(),
+		uint32(os.Getuid()), time.Now())
+	query := p.query
+
+	resilience.SafeGoWithName("diagnostics-audit-query", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), auditQueryTimeout)
+		defer cancel()
+
+		var (
+			result *daemon.AuditQueryResult
+			err    error
+		)
+		// Deferred so a panicking query still re-enables the page.
+		defer func() {
+			glib.IdleAdd(func() {
+				p.loading = false
+				if p.closed {
+					return
+				}
+				p.refreshBtn.SetSensitive(true)
+				p.show(result, err)
+				if p.pending {
+					p.pending = false
+					p.refresh()
+				}
+			})
+		}()
+		result, err = query(ctx, params)
+	})
+}
+
+// show replaces the listed records with result, or reports err. Main-thread only.
+func (p *auditPage) show(result *daemon.AuditQueryResult, err error) {
+	for _, row := range p.rows {
+		p.group.Remove(row)
+	}
+	p.rows = p.rows[:0]
+
+	switch {
+	case err != nil:
+		p.setStatus(fmt.Sprintf("Could not read the audit trail: %v", err))
+		return
+	case result == nil || len(result.Records) == 0:
+		p.setStatus("No recorded operations match these filters.")
+		return
+	case result.Truncated:
+		p.setStatus(fmt.Sprintf("Showing the %d most recent operations.", len(result.Records)))
+	default:
+		p.setStatus("")
+	}
+
+	for _, rec := range result.Records {
+		title, subtitle := auditRowText(rec)
+		row := adw.NewActionRow()
+		row.SetUseMarkup(false) // errors and paths may contain '<' or '&'
+		row.SetTitle(title)
+		row.SetSubtitle(subtitle)
+		row.SetSubtitleLines(3)
+
+		icon := gtk.NewImage()
+		if rec.Result == "ok" {
+			icon.SetFromIconName("emblem-ok-symbolic")
+		} else {
+			icon.SetFromIconName("dialog-error-symbolic")
+		}
+		icon.SetPixelSize(16)
+		row.AddPrefix(icon)
+
+		p.group.Add(row)
+		p.rows = append(p.rows, row)
+	}
+}
+
+func (p *auditPage) setStatus(text string) {
+	p.status.SetLabel(text)
+	p.status.SetVisible(text != "")
+}
+
+// auditRangeLabels returns the drop-down entries for auditRanges.
+func auditRangeLabels() []string {
+	labels := make([]string, len(auditRanges))
+	for i, r := range auditRanges {
+		labels[i] = r.label
+	}
+	return labels
+}
+
+// auditQueryParams turns the page's filters into audit.query params. An
+// out-of-range selection means no time bound.
+func auditQueryParams(method string, rangeIdx uint, onlyMine bool, uid uint32, now time.Time) daemon.AuditQueryParams {
+	params := daemon.AuditQueryParams{
+		Method: strings.TrimSpace(method),
+		Limit:  auditPageLimit,
+	}
+	if int(rangeIdx) < len(auditRanges) {
+		if window := auditRanges[rangeIdx].window; window > 0 {
+			since := now.Add(-window)
+			params.Since = &since
+		}
+	}
+	if onlyMine {
+		params.UID = &uid
+	}
+	return params
+}
+
+// auditRowText returns the row title ("gateway.enable") and subtitle (when,
+// who, how it ended) for a record.
+func auditRowText(rec daemon.AuditRecord) (string, string) {
+	who := fmt.Sprintf("uid %d", rec.UID)
+	if rec.Exe != "" {
+		who += " · " + rec.Exe
+	}
+	if rec.Transport == "dbus" {
+		who += " (D-Bus)"
+	}
+
+	outcome := rec.Result
+	if rec.Error != "" {
+		outcome += ": " + rec.Error
+	}
+	outcome += fmt.Sprintf(" · %d ms", rec.DurationMS)
+
+	when := rec.Time.Local().Format("2006-01-02 15:04:05")
+	return rec.Method, when + " · " + who + "\n" + outcome
+}
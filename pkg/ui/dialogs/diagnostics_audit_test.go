@@ -0,0 +1,60 @@
+package dialogs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+)
+
+// TestAuditQueryParams verifies the Audit page filters map onto audit.query
+// params: trimmed method glob, a since bound per range, and the UID only when
+// "Only my calls" is ticked.
+func TestAuditQueryParams(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	params := auditQueryParams("  gateway.* ", 1, true, 1000, now)
+	if params.Method != "gateway.*" {
+		t.Errorf("Method = %q, want %q", params.Method, "gateway.*")
+	}
+	if params.Since == nil || !params.Since.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("Since = %v, want 24h before now", params.Since)
+	}
+	if params.UID == nil || *params.UID != 1000 {
+		t.Errorf("UID = %v, want 1000", params.UID)
+	}
+	if params.Limit != auditPageLimit {
+		t.Errorf("Limit = %d, want %d", params.Limit, auditPageLimit)
+	}
+
+	all := auditQueryParams("", uint(len(auditRanges)-1), false, 1000, now)
+	if all.Since != nil || all.UID != nil {
+		t.Errorf("\"All\" without \"Only my calls\" = %+v, want no time or UID bound", all)
+	}
+}
+
+// TestAuditRowText verifies a record's row shows the method as title and when,
+// who and the outcome in the subtitle.
+func TestAuditRowText(t *testing.T) {
+	rec := daemon.AuditRecord{
+		Time:       time.Date(2026, 10, 16, 9, 30, 0, 0, time.Local),
+		UID:        1000,
+		Exe:        "/usr/bin/vpnctl",
+		Transport:  "dbus",
+		Method:     "gateway.enable",
+		Result:     "error",
+		Error:      "no uplink",
+		DurationMS: 42,
+	}
+
+	title, subtitle := auditRowText(rec)
+	if title != "gateway.enable" {
+		t.Errorf("title = %q, want the method", title)
+	}
+	for _, want := range []string{"2026-10-16 09:30:00", "uid 1000", "/usr/bin/vpnctl", "(D-Bus)", "error: no uplink", "42 ms"} {
+		if !strings.Contains(subtitle, want) {
+			t.Errorf("subtitle %q does not contain %q", subtitle, want)
+		}
+	}
+}
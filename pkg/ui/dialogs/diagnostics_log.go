@@ -0,0 +1,252 @@
+// Package dialogs provides the graphical user interface for VPN Manager.
+// This file contains the Log page of the diagnostics dialog: a log followed
+// live while the dialog is open (for OpenVPN, the profile's log through
+// openvpn.logs), so a connection attempt can be watched as it happens.
+package dialogs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+	"github.com/yllada/vpn-manager/internal/resilience"
+)
+
+// logViewLines is how many lines the page shows; older ones scroll away.
+const logViewLines = 2000
+
+// logLevels are the level filters offered by the page, in drop-down order.
+var logLevels = []struct {
+	label string
+	level string
+}{
+	{"All lines", ""},
+	{"Warnings and errors", "warning"},
+	{"Errors only", "error"},
+}
+
+// Colors of warning and error lines.
+const (
+	logWarningColor = "#e5a50a"
+	logErrorColor   = "#e01b24"
+)
+
+// LogFollowFunc follows a log, passing each line at least as severe as level
+// ("" for all of them) to handle, until ctx is cancelled. It runs OFF the GTK
+// main thread, and so does handle.
+type LogFollowFunc func(ctx context.Context, level string, handle func(daemon.OpenVPNLogLine)) error
+
+// logPage shows a followed log. Like DiagnosticsView, every method and field
+// is touched ONLY on the GTK main thread, except the logBatch shared with the
+// follow goroutine.
+type logPage struct {
+	widget    *gtk.Box
+	levelDrop *gtk.DropDown
+	status    *gtk.Label
+	view      *gtk.TextView
+	end       *gtk.TextMark
+
+	follow  LogFollowFunc
+	cancel  context.CancelFunc // Stops the running follow
+	batch   *logBatch          // The running follow's lines; nil when stopped
+	started bool               // A follow has been started at least once
+	closed  bool               // The dialog was dismissed
+}
+
+// logBatch collects the lines a follow receives until the main thread shows
+// them, so a burst (the backlog) costs one IdleAdd instead of one per line.
+type logBatch struct {
+	mu     sync.Mutex
+	lines  []daemon.OpenVPNLogLine
+	queued bool
+}
+
+// add queues line and reports whether the caller must schedule a flush.
+func (b *logBatch) add(line daemon.OpenVPNLogLine) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if b.queued {
+		return false
+	}
+	b.queued = true
+	return true
+}
+
+// take returns the queued lines; the next add schedules a new flush.
+func (b *logBatch) take() []daemon.OpenVPNLogLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lines := b.lines
+	b.lines, b.queued = nil, false
+	return lines
+}
+
+// newLogPage builds the Log page. It follows nothing until start is first
+// called (when the page is shown).
+func newLogPage(follow LogFollowFunc) *logPage {
+	p := &logPage{
+		widget:    gtk.NewBox(gtk.OrientationVertical, 12),
+		levelDrop: gtk.NewDropDownFromStrings(logLevelLabels()),
+		status:    gtk.NewLabel(""),
+		view:      gtk.NewTextView(),
+		follow:    follow,
+	}
+
+	p.widget.SetMarginTop(12)
+	p.widget.SetMarginBottom(24)
+	p.widget.SetMarginStart(24)
+	p.widget.SetMarginEnd(24)
+
+	filters := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	header := gtk.NewLabel("Output of this and recent sessions, live")
+	header.AddCSSClass("dim-label")
+	header.SetHExpand(true)
+	header.SetXAlign(0)
+	filters.Append(header)
+	filters.Append(p.levelDrop)
+	p.widget.Append(filters)
+
+	p.status.AddCSSClass("dim-label")
+	p.status.SetWrap(true)
+	p.status.SetVisible(false)
+	p.widget.Append(p.status)
+
+	p.view.SetEditable(false)
+	p.view.SetCursorVisible(false)
+	p.view.SetMonospace(true)
+	p.view.SetWrapMode(gtk.WrapWordChar)
+	buffer := p.view.Buffer()
+	p.end = buffer.CreateMark("end", buffer.EndIter(), false)
+
+	scrolled := gtk.NewScrolledWindow()
+	scrolled.SetVExpand(true)
+	scrolled.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	scrolled.SetChild(p.view)
+	p.widget.Append(scrolled)
+
+	p.levelDrop.NotifyProperty("selected", p.start)
+
+	return p
+}
+
+// start (re)starts following with the current level filter. The daemon sends
+// the buffered lines first, so the page is rebuilt from scratch. Main-thread
+// only.
+func (p *logPage) start() {
+	p.stop()
+	p.started = true
+	p.view.Buffer().SetText("")
+	p.setStatus("Waiting for output…")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	batch := &logBatch{}
+	p.cancel, p.batch = cancel, batch
+	level := logLevelAt(p.levelDrop.Selected())
+	follow := p.follow
+
+	resilience.SafeGoWithName("diagnostics-log-follow", func() {
+		var err error
+		// Deferred so a panicking follow still reports that it ended.
+		defer func() {
+			glib.IdleAdd(func() {
+				if p.closed || p.batch != batch {
+					return
+				}
+				p.flush(batch)
+				if err != nil {
+					p.setStatus(fmt.Sprintf("The log stopped following: %v", err))
+				}
+			})
+		}()
+		err = follow(ctx, level, func(line daemon.OpenVPNLogLine) {
+			if batch.add(line) {
+				glib.IdleAdd(func() {
+					if !p.closed && p.batch == batch {
+						p.flush(batch)
+					}
+				})
+			}
+		})
+	})
+}
+
+// stop ends the running follow, if any. Main-thread only.
+func (p *logPage) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.batch = nil
+}
+
+// close stops following for good, when the dialog is dismissed.
+func (p *logPage) close() {
+	p.closed = true
+	p.stop()
+}
+
+// flush appends batch's queued lines, drops the oldest beyond logViewLines
+// and keeps the newest in view. Main-thread only.
+func (p *logPage) flush(batch *logBatch) {
+	lines := batch.take()
+	if len(lines) == 0 {
+		return
+	}
+	p.setStatus("")
+
+	buffer := p.view.Buffer()
+	for _, line := range lines {
+		buffer.InsertMarkup(buffer.EndIter(), logLineMarkup(line)+"\n")
+	}
+	if excess := buffer.LineCount() - 1 - logViewLines; excess > 0 {
+		if cut, ok := buffer.IterAtLine(excess); ok {
+			buffer.Delete(buffer.StartIter(), cut)
+		}
+	}
+	p.view.ScrollMarkOnscreen(p.end)
+}
+
+func (p *logPage) setStatus(text string) {
+	p.status.SetLabel(text)
+	p.status.SetVisible(text != "")
+}
+
+// logLevelLabels returns the drop-down entries for logLevels.
+func logLevelLabels() []string {
+	labels := make([]string, len(logLevels))
+	for i, l := range logLevels {
+		labels[i] = l.label
+	}
+	return labels
+}
+
+// logLevelAt returns the level filter of a drop-down entry. An out-of-range
+// selection means every line.
+func logLevelAt(idx uint) string {
+	if int(idx) < len(logLevels) {
+		return logLevels[idx].level
+	}
+	return ""
+}
+
+// logLineText is a line as the page shows it: local time, then the text.
+func logLineText(line daemon.OpenVPNLogLine) string {
+	return line.Time.Local().Format("15:04:05") + "  " + line.Text
+}
+
+// logLineMarkup is logLineText, colored by level.
+func logLineMarkup(line daemon.OpenVPNLogLine) string {
+	text := glib.MarkupEscapeText(logLineText(line))
+	switch line.Level {
+	case "error":
+		return `<span foreground="` + logErrorColor + `">` + text + `</span>`
+	case "warning":
+		return `<span foreground="` + logWarningColor + `">` + text + `</span>`
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+package dialogs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
+)
+
+// TestLogLevelAt verifies the Log page's drop-down maps onto openvpn.logs
+// levels, every line being the fallback.
+func TestLogLevelAt(t *testing.T) {
+	for idx, want := range []string{"", "warning", "error"} {
+		if got := logLevelAt(uint(idx)); got != want {
+			t.Errorf("logLevelAt(%d) = %q, want %q", idx, got, want)
+		}
+	}
+	if got := logLevelAt(uint(len(logLevels))); got != "" {
+		t.Errorf("out-of-range selection = %q, want every line", got)
+	}
+	if labels := logLevelLabels(); len(labels) != len(logLevels) || labels[0] != "All lines" {
+		t.Errorf("labels = %q", labels)
+	}
+}
+
+// TestLogLineText verifies a line shows its local time before the text.
+func TestLogLineText(t *testing.T) {
+	line := daemon.OpenVPNLogLine{
+		Time:  time.Date(2026, 10, 16, 9, 30, 5, 0, time.Local),
+		Level: "error",
+		Text:  "AUTH: Received control message: AUTH_FAILED",
+	}
+	got := logLineText(line)
+	if !strings.HasPrefix(got, "09:30:05") || !strings.HasSuffix(got, line.Text) {
+		t.Errorf("logLineText() = %q", got)
+	}
+}
+
+// TestLogBatch verifies a burst of lines schedules a single flush, and that
+// the next line after a flush schedules another.
+func TestLogBatch(t *testing.T) {
+	var b logBatch
+	flushes := 0
+	for i := range 3 {
+		if b.add(daemon.OpenVPNLogLine{Seq: uint64(i + 1)}) {
+			flushes++
+		}
+	}
+	if flushes != 1 {
+		t.Errorf("a burst scheduled %d flushes, want 1", flushes)
+	}
+	if lines := b.take(); len(lines) != 3 || lines[2].Seq != 3 {
+		t.Errorf("take() = %+v, want the three lines in order", lines)
+	}
+	if lines := b.take(); len(lines) != 0 {
+		t.Errorf("second take() = %+v, want none", lines)
+	}
+	if !b.add(daemon.OpenVPNLogLine{Seq: 4}) {
+		t.Error("a line after a flush should schedule another")
+	}
+}
@@ -4,17 +4,21 @@
 package dialogs
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	"github.com/yllada/vpn-manager/internal/daemon"
 )
 
 // openvpnProbeTarget is the generic connectivity target for OpenVPN probes.
 const openvpnProbeTarget = "1.1.1.1:53"
 
 // NewOpenVPNDiagnosticsDialog creates an OpenVPN diagnostics dialog running TCP
-// and HTTP connectivity probes. profileName is shown in the title only.
-func NewOpenVPNDiagnosticsDialog(profileName string, parent gtk.Widgetter) *DiagnosticsView {
+// and HTTP connectivity probes, with a Log page following the profile's
+// OpenVPN log. profileName is shown in the title only.
+func NewOpenVPNDiagnosticsDialog(profileID, profileName string, parent gtk.Widgetter) *DiagnosticsView {
 	return NewDiagnosticsView(DiagnosticsConfig{
 		Title:       fmt.Sprintf("OpenVPN Diagnostics - %s", profileName),
 		Description: "Network connectivity diagnostics for OpenVPN",
@@ -24,5 +28,14 @@ func NewOpenVPNDiagnosticsDialog(profileName string, parent gtk.Widgetter) *Diag
 				httpProbe(),
 			}
 		},
+		FollowLog: openvpnLogFollower(profileID),
 	}, parent)
 }
+
+// openvpnLogFollower follows profileID's OpenVPN log through the daemon.
+func openvpnLogFollower(profileID string) LogFollowFunc {
+	return func(ctx context.Context, level string, handle func(daemon.OpenVPNLogLine)) error {
+		client := &daemon.OpenVPNClient{}
+		return client.FollowLogs(ctx, daemon.OpenVPNLogsParams{ProfileID: profileID, Level: level}, handle)
+	}
+}
@@ -4,7 +4,9 @@
 package dialogs
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
@@ -29,6 +31,9 @@ type SplitTunnelDialog struct {
 	quickAddRow *adw.ActionRow   // Track the Quick Add row for proper ordering
 	routes      []string
 
+	// Servers (profiles with several remotes only)
+	fastestRow *adw.SwitchRow
+
 	// System integration
 	useNMRow *adw.SwitchRow
 }
@@ -113,6 +118,41 @@ func (std *SplitTunnelDialog) build() {
 
 	std.prefsPage.Add(authGroup)
 
+	// ═══════════════════════════════════════════════════════════════════
+	// SERVERS SECTION (only for configs with several remotes)
+	// ═══════════════════════════════════════════════════════════════════
+	if remotes, err := profile.ParseRemotes(std.profile.ConfigPath); err == nil && len(remotes.Servers) > 1 {
+		serversGroup := adw.NewPreferencesGroup()
+		serversGroup.SetTitle("Servers")
+		serversGroup.SetDescription(fmt.Sprintf("This configuration lists %d servers; OpenVPN falls back to the next one when a server does not answer.", len(remotes.Servers)))
+
+		std.fastestRow = adw.NewSwitchRow()
+		std.fastestRow.SetTitle("Prefer Fastest Server")
+		std.fastestRow.SetActive(std.profile.PreferFastestRemote)
+		if remotes.Random || remotes.ConnectionBlocks {
+			std.fastestRow.SetSubtitle("Not available: the configuration orders its servers itself")
+			std.fastestRow.SetSensitive(false)
+		} else {
+			std.fastestRow.SetSubtitle("Measure latency before connecting and try the fastest server first")
+		}
+		serversGroup.Add(std.fastestRow)
+
+		std.prefsPage.Add(serversGroup)
+	}
+
+	// ═══════════════════════════════════════════════════════════════════
+	// CERTIFICATES SECTION (PKI material found in the configuration)
+	// ═══════════════════════════════════════════════════════════════════
+	if len(std.profile.Certificates) > 0 {
+		certsGroup := adw.NewPreferencesGroup()
+		certsGroup.SetTitle("Certificates")
+		certsGroup.SetDescription("Certificates and keys in the configuration file, as found when it was imported")
+		for _, cert := range std.profile.Certificates {
+			certsGroup.Add(newCertificateRow(cert))
+		}
+		std.prefsPage.Add(certsGroup)
+	}
+
 	// ═══════════════════════════════════════════════════════════════════
 	// SPLIT TUNNELING SECTION
 	// ═══════════════════════════════════════════════════════════════════
@@ -228,6 +268,50 @@ func (std *SplitTunnelDialog) build() {
 	std.dialog.SetChild(toolbarView)
 }
 
+// newCertificateRow builds the row of a certificate or key: its expiry in the
+// summary, and its subject, issuer and fingerprint when expanded.
+func newCertificateRow(cert profile.Certificate) *adw.ExpanderRow {
+	row := adw.NewExpanderRow()
+	row.SetTitle(cert.Name())
+
+	icon := "security-high-symbolic"
+	switch {
+	case cert.StaticKey:
+		row.SetSubtitle("Static key, does not expire")
+	case time.Now().After(cert.NotAfter):
+		row.SetSubtitle("Expired on " + cert.NotAfter.Local().Format("Jan 2, 2006"))
+		icon = "dialog-error-symbolic"
+	default:
+		days := int(time.Until(cert.NotAfter).Hours() / 24)
+		row.SetSubtitle(fmt.Sprintf("Expires on %s (in %d days)", cert.NotAfter.Local().Format("Jan 2, 2006"), days))
+		if days < profile.ExpiryWarningDays[0] {
+			icon = "dialog-warning-symbolic"
+		}
+	}
+	row.AddPrefix(components.CreateRowIcon(icon))
+
+	details := [][2]string{
+		{"Subject", cert.Subject},
+		{"Issuer", cert.Issuer},
+		{"SHA-256 Fingerprint", cert.Fingerprint},
+		{"File", cert.File},
+	}
+	if !cert.NotBefore.IsZero() {
+		details = append(details, [2]string{"Valid From", cert.NotBefore.Local().Format("Jan 2, 2006 15:04")})
+	}
+	for _, d := range details {
+		if d[1] == "" {
+			continue
+		}
+		detail := adw.NewActionRow()
+		detail.SetTitle(d[0])
+		detail.SetSubtitle(d[1])
+		detail.SetSubtitleSelectable(true)
+		row.AddRow(detail)
+	}
+	return row
+}
+
 // Show displays the dialog.
 func (std *SplitTunnelDialog) Show() {
 	std.dialog.Present(std.host.GetWindow())
@@ -380,6 +464,10 @@ func (std *SplitTunnelDialog) saveSettings() {
 
 	std.profile.SplitTunnelRoutes = std.routes
 
+	if std.fastestRow != nil {
+		std.profile.PreferFastestRemote = std.fastestRow.Active()
+	}
+
 	// Save NetworkManager setting
 	if std.useNMRow != nil {
 		std.profile.UseNetworkManager = std.useNMRow.Active()
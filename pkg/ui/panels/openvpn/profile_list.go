@@ -10,6 +10,7 @@ import (
 	"github.com/diamondburned/gotk4-adwaita/pkg/adw"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/yllada/vpn-manager/internal/eventbus"
 	"github.com/yllada/vpn-manager/internal/keyring"
 	"github.com/yllada/vpn-manager/internal/logger"
 	"github.com/yllada/vpn-manager/internal/notify"
@@ -180,7 +181,7 @@ func (pl *ProfileList) LoadProfiles() {
 func (pl *ProfileList) addProfileRow(profile *profilepkg.Profile) {
 	// Build subtitle with status and features
 	subtitle := "Disconnected"
-	if profile.RequiresOTP {
+	if profile.RequiresOTP || profile.StaticChallenge != "" {
 		subtitle += " • 2FA"
 	}
 	if profile.SplitTunnelEnabled {
@@ -253,7 +254,7 @@ func (pl *ProfileList) onConfigClicked(profile *profilepkg.Profile) {
 // Task 4.4: Wire button to open OpenVPNDiagnosticsDialog.
 // Satisfies REQ-DIAG-001 (diagnostics button when provider available).
 func (pl *ProfileList) onDiagnosticsClicked(profile *profilepkg.Profile) {
-	dialog := dialogs.NewOpenVPNDiagnosticsDialog(profile.Name, pl.host.GetWindow())
+	dialog := dialogs.NewOpenVPNDiagnosticsDialog(profile.ID, profile.Name, pl.host.GetWindow())
 	dialog.Present()
 }
 
@@ -381,6 +382,93 @@ func (pl *ProfileList) ShowOTPDialog(profile *profilepkg.Profile, username, pass
 	dialog.Present(pl.host.GetWindow())
 }
 
+// ShowChallengeDialog shows an AdwDialog with the two-factor question a
+// connecting profile's server asked (a static-challenge prompt or a CRV1
+// challenge) and sends the answer to the daemon. Cancelling disconnects, as
+// the server will not let the connection go on without an answer.
+func (pl *ProfileList) ShowChallengeDialog(profile *profilepkg.Profile, challenge eventbus.AuthChallengeData) {
+	dialog := adw.NewDialog()
+	dialog.SetTitle("Verification")
+	dialog.SetContentWidth(380)
+	dialog.SetContentHeight(280)
+
+	toolbarView := adw.NewToolbarView()
+
+	headerBar := adw.NewHeaderBar()
+	headerBar.SetShowEndTitleButtons(false)
+	headerBar.SetShowStartTitleButtons(false)
+
+	answered := false
+	cancelBtn := components.NewLabelButton("Cancel")
+	cancelBtn.ConnectClicked(func() {
+		dialog.Close()
+	})
+	headerBar.PackStart(cancelBtn)
+
+	continueBtn := components.NewLabelButtonWithStyle("Continue", components.ButtonSuggested)
+	headerBar.PackEnd(continueBtn)
+
+	toolbarView.AddTopBar(headerBar)
+
+	prefsPage := adw.NewPreferencesPage()
+
+	headerGroup := adw.NewPreferencesGroup()
+	statusPage := adw.NewStatusPage()
+	statusPage.SetIconName("security-high-symbolic")
+	statusPage.SetTitle(profile.Name)
+	statusPage.SetDescription(challenge.Text)
+	headerGroup.Add(statusPage)
+	prefsPage.Add(headerGroup)
+
+	// A hidden answer (a PIN) gets a password row; an echoed one, a plain row.
+	responseGroup := adw.NewPreferencesGroup()
+	responseRow := adw.NewEntryRow()
+	if !challenge.Echo {
+		responseRow = &adw.NewPasswordEntryRow().EntryRow
+	}
+	responseRow.SetTitle("Response")
+	responseGroup.Add(responseRow)
+	if !challenge.ResponseRequired {
+		responseGroup.SetDescription("Leave empty once you have approved the sign-in elsewhere.")
+	}
+	prefsPage.Add(responseGroup)
+
+	continueBtn.ConnectClicked(func() {
+		response := responseRow.Text()
+		if challenge.ResponseRequired && response == "" {
+			return
+		}
+		answered = true
+		dialog.Close()
+		resilience.SafeGoWithName("openvpn-challenge-response", func() {
+			if err := pl.host.VPNManager().RespondToChallenge(profile.ID, response); err != nil {
+				glib.IdleAdd(func() {
+					title, body := components.ExplainError("Could not send the response", err)
+					pl.host.ShowError(title, body)
+				})
+			}
+		})
+	})
+	responseRow.ConnectEntryActivated(func() {
+		continueBtn.Activate()
+	})
+
+	dialog.ConnectClosed(func() {
+		if answered {
+			return
+		}
+		resilience.SafeGoWithName("openvpn-challenge-cancel", func() {
+			if err := pl.host.VPNManager().Disconnect(profile.ID); err != nil {
+				logger.LogDebug("openvpn_panel", "Disconnect after a cancelled challenge failed: %v", err)
+			}
+		})
+	})
+
+	toolbarView.SetContent(prefsPage)
+	dialog.SetChild(toolbarView)
+	dialog.Present(pl.host.GetWindow())
+}
+
 // showPasswordDialog shows an AdwDialog to enter username and password.
 // After validation, shows the OTP dialog.
 func (pl *ProfileList) showPasswordDialog(profile *profilepkg.Profile) {
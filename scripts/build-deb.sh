@@ -18,6 +18,8 @@ PREBUILT_BINARY="${2:-}"
 PREBUILT_DAEMON="${3:-}"
 PKG_NAME="vpn-manager"
 DAEMON_NAME="vpn-managerd"
+CLI_NAME="vpnctl"
+AGENT_NAME="vpn-manager-agent"
 ARCH="amd64"
 PKG_DIR="${PKG_NAME}_${VERSION}_${ARCH}"
 
@@ -41,6 +43,9 @@ mkdir -p usr/share/icons/hicolor/scalable/apps
 mkdir -p usr/share/icons/hicolor/256x256/apps
 mkdir -p usr/share/doc/${PKG_NAME}
 mkdir -p lib/systemd/system
+mkdir -p usr/lib/systemd/user
+mkdir -p usr/share/dbus-1/system.d
+mkdir -p usr/share/polkit-1/actions
 
 # Get or build the binaries
 cd "${PROJECT_DIR}"
@@ -116,6 +121,27 @@ else
         ./cmd/vpn-managerd
 fi
 
+# Build command-line client (pure Go, no GTK)
+echo "📦 Compiling ${CLI_NAME}..."
+GO_BIN=$(find_go)
+if [[ -z "$GO_BIN" || ! -x "$GO_BIN" ]]; then
+    echo "❌ Error: Go not found. ${CLI_NAME} is always built from source."
+    exit 1
+fi
+CGO_ENABLED=0 "$GO_BIN" build \
+    -trimpath \
+    -ldflags="-s -w -X main.appVersion=${VERSION}" \
+    -o "${BUILD_DIR}/${PKG_DIR}/usr/bin/${CLI_NAME}" \
+    ./cmd/vpnctl
+
+# Build the session agent (pure Go, no GTK)
+echo "📦 Compiling ${AGENT_NAME}..."
+CGO_ENABLED=0 "$GO_BIN" build \
+    -trimpath \
+    -ldflags="-s -w -X main.Version=${VERSION}" \
+    -o "${BUILD_DIR}/${PKG_DIR}/usr/bin/${AGENT_NAME}" \
+    ./cmd/vpn-manager-agent
+
 # Copy files
 echo "📄 Copying files..."
 cp "${PROJECT_DIR}/assets/com.vpnmanager.app.desktop" "${BUILD_DIR}/${PKG_DIR}/usr/share/applications/"
@@ -132,6 +158,12 @@ fi
 
 # Copy systemd service file
 cp "${PROJECT_DIR}/build/systemd/vpn-managerd.service" "${BUILD_DIR}/${PKG_DIR}/lib/systemd/system/"
+cp "${PROJECT_DIR}/build/systemd/vpn-managerd.socket" "${BUILD_DIR}/${PKG_DIR}/lib/systemd/system/"
+cp "${PROJECT_DIR}/build/systemd/vpn-manager-agent.service" "${BUILD_DIR}/${PKG_DIR}/usr/lib/systemd/user/"
+
+# D-Bus system service policy and its polkit actions
+cp "${PROJECT_DIR}/build/dbus/com.vpnmanager.Daemon1.conf" "${BUILD_DIR}/${PKG_DIR}/usr/share/dbus-1/system.d/"
+cp "${PROJECT_DIR}/build/polkit/com.vpnmanager.daemon.policy" "${BUILD_DIR}/${PKG_DIR}/usr/share/polkit-1/actions/"
 
 # Documentation
 cp "${PROJECT_DIR}/README.md" "${BUILD_DIR}/${PKG_DIR}/usr/share/doc/${PKG_NAME}/"
@@ -156,7 +188,8 @@ Description: Modern GTK4 VPN Manager for Linux
  secure credential storage, system tray integration, traffic statistics,
  kill switch, DNS/IPv6 leak protection, and split tunneling.
  .
- Includes vpn-managerd daemon for privileged operations.
+ Includes vpn-managerd daemon for privileged operations, the
+ vpn-manager-agent session service and the vpnctl command-line client.
 EOF
 
 # Post-installation script
@@ -191,8 +224,12 @@ fi
 DAEMON_STARTED=0
 if [ -d /run/systemd/system ]; then
     systemctl daemon-reload 2>/dev/null || true
+    # Also enables vpn-managerd.socket (Also= in the unit)
     systemctl enable vpn-managerd 2>/dev/null || true
     systemctl start vpn-managerd 2>/dev/null && DAEMON_STARTED=1 || true
+    # Start the session agent in every user session (user units are enabled
+    # globally; each user's manager starts it at next login).
+    systemctl --global enable vpn-manager-agent.service 2>/dev/null || true
 fi
 
 echo "✅ VPN Manager installed successfully"
@@ -217,13 +254,16 @@ cat > "${BUILD_DIR}/${PKG_DIR}/DEBIAN/prerm" << 'EOF'
 set -e
 
 if [ "$1" = "remove" ] || [ "$1" = "upgrade" ]; then
-    # Stop the daemon before removal
+    # Stop the daemon before removal, the socket first so a client cannot
+    # start it again
+    systemctl stop vpn-managerd.socket 2>/dev/null || true
     if systemctl is-active --quiet vpn-managerd 2>/dev/null; then
         systemctl stop vpn-managerd 2>/dev/null || true
     fi
     
     if [ "$1" = "remove" ]; then
-        systemctl disable vpn-managerd 2>/dev/null || true
+        systemctl disable vpn-managerd vpn-managerd.socket 2>/dev/null || true
+        systemctl --global disable vpn-manager-agent.service 2>/dev/null || true
     fi
 fi
 EOF
@@ -255,7 +295,11 @@ chmod 755 "${BUILD_DIR}/${PKG_DIR}/DEBIAN/postrm"
 # Set correct permissions
 chmod 755 "${BUILD_DIR}/${PKG_DIR}/usr/bin/${PKG_NAME}"
 chmod 755 "${BUILD_DIR}/${PKG_DIR}/usr/bin/${DAEMON_NAME}"
+chmod 755 "${BUILD_DIR}/${PKG_DIR}/usr/bin/${CLI_NAME}"
+chmod 755 "${BUILD_DIR}/${PKG_DIR}/usr/bin/${AGENT_NAME}"
 chmod 644 "${BUILD_DIR}/${PKG_DIR}/lib/systemd/system/vpn-managerd.service"
+chmod 644 "${BUILD_DIR}/${PKG_DIR}/lib/systemd/system/vpn-managerd.socket"
+chmod 644 "${BUILD_DIR}/${PKG_DIR}/usr/lib/systemd/user/vpn-manager-agent.service"
 find "${BUILD_DIR}/${PKG_DIR}" -type d -exec chmod 755 {} \;
 find "${BUILD_DIR}/${PKG_DIR}/usr/share" -type f -exec chmod 644 {} \;
 
@@ -276,7 +320,13 @@ echo ""
 echo "Contents:"
 echo "  - /usr/bin/vpn-manager (GUI/CLI application)"
 echo "  - /usr/bin/vpn-managerd (privileged operations daemon)"
+echo "  - /usr/bin/vpnctl (command-line client)"
+echo "  - /usr/bin/vpn-manager-agent (per-user session agent)"
 echo "  - /lib/systemd/system/vpn-managerd.service"
+echo "  - /lib/systemd/system/vpn-managerd.socket"
+echo "  - /usr/lib/systemd/user/vpn-manager-agent.service"
+echo "  - /usr/share/dbus-1/system.d/com.vpnmanager.Daemon1.conf"
+echo "  - /usr/share/polkit-1/actions/com.vpnmanager.daemon.policy"
 echo ""
 echo "Para instalar:"
 echo "  sudo dpkg -i ${PKG_DIR}.deb"
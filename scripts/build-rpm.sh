@@ -21,6 +21,8 @@ PREBUILT_BINARY="${2:-}"
 PREBUILT_DAEMON="${3:-}"
 PKG_NAME="vpn-manager"
 DAEMON_NAME="vpn-managerd"
+CLI_NAME="vpnctl"
+AGENT_NAME="vpn-manager-agent"
 ARCH="x86_64"
 RELEASE="1"
 
@@ -99,6 +101,27 @@ else
         ./cmd/vpn-managerd
 fi
 
+# Build command-line client (pure Go, no GTK)
+echo "📦 Compiling ${CLI_NAME}..."
+GO_BIN=$(find_go)
+if [[ -z "$GO_BIN" || ! -x "$GO_BIN" ]]; then
+    echo "❌ Error: Go not found. ${CLI_NAME} is always built from source."
+    exit 1
+fi
+CGO_ENABLED=0 "$GO_BIN" build \
+    -trimpath \
+    -ldflags="-s -w -X main.appVersion=${VERSION}" \
+    -o "${SOURCE_DIR}/${CLI_NAME}" \
+    ./cmd/vpnctl
+
+# Build the session agent (pure Go, no GTK)
+echo "📦 Compiling ${AGENT_NAME}..."
+CGO_ENABLED=0 "$GO_BIN" build \
+    -trimpath \
+    -ldflags="-s -w -X main.Version=${VERSION}" \
+    -o "${SOURCE_DIR}/${AGENT_NAME}" \
+    ./cmd/vpn-manager-agent
+
 # Copy assets to source directory
 echo "📄 Copying files..."
 cp "${PROJECT_DIR}/assets/com.vpnmanager.app.desktop" "${SOURCE_DIR}/"
@@ -110,6 +133,12 @@ cp "${PROJECT_DIR}/LICENSE" "${SOURCE_DIR}/" 2>/dev/null || \
 
 # Copy systemd service file
 cp "${PROJECT_DIR}/build/systemd/vpn-managerd.service" "${SOURCE_DIR}/"
+cp "${PROJECT_DIR}/build/systemd/vpn-managerd.socket" "${SOURCE_DIR}/"
+cp "${PROJECT_DIR}/build/systemd/vpn-manager-agent.service" "${SOURCE_DIR}/"
+
+# D-Bus system service policy and its polkit actions
+cp "${PROJECT_DIR}/build/dbus/com.vpnmanager.Daemon1.conf" "${SOURCE_DIR}/"
+cp "${PROJECT_DIR}/build/polkit/com.vpnmanager.daemon.policy" "${SOURCE_DIR}/"
 
 # Copy hicolor icons if they exist
 if [ -d "${PROJECT_DIR}/assets/icons/hicolor" ]; then
@@ -154,7 +183,8 @@ OpenVPN, WireGuard, and Tailscale. Features include profile management,
 secure credential storage, system tray integration, traffic statistics,
 kill switch, DNS/IPv6 leak protection, and split tunneling.
 
-Includes vpn-managerd daemon for privileged operations.
+Includes vpn-managerd daemon for privileged operations, the
+vpn-manager-agent session service and the vpnctl command-line client.
 
 %prep
 %setup -q
@@ -173,8 +203,20 @@ install -Dm755 %{name} %{buildroot}%{_bindir}/%{name}
 # Daemon binary
 install -Dm755 ${DAEMON_NAME} %{buildroot}%{_bindir}/${DAEMON_NAME}
 
+# Command-line client
+install -Dm755 ${CLI_NAME} %{buildroot}%{_bindir}/${CLI_NAME}
+
+# Session agent
+install -Dm755 ${AGENT_NAME} %{buildroot}%{_bindir}/${AGENT_NAME}
+
 # Systemd service (use explicit path, not macro in install target)
 install -Dm644 ${DAEMON_NAME}.service %{buildroot}/usr/lib/systemd/system/${DAEMON_NAME}.service
+install -Dm644 ${DAEMON_NAME}.socket %{buildroot}/usr/lib/systemd/system/${DAEMON_NAME}.socket
+install -Dm644 ${AGENT_NAME}.service %{buildroot}/usr/lib/systemd/user/${AGENT_NAME}.service
+
+# D-Bus system service policy and polkit actions
+install -Dm644 com.vpnmanager.Daemon1.conf %{buildroot}%{_datadir}/dbus-1/system.d/com.vpnmanager.Daemon1.conf
+install -Dm644 com.vpnmanager.daemon.policy %{buildroot}%{_datadir}/polkit-1/actions/com.vpnmanager.daemon.policy
 
 # Desktop file (named after the app id so desktop environments can
 # associate the running window with its launcher entry)
@@ -218,10 +260,12 @@ if [ -x /usr/bin/update-desktop-database ]; then
 fi
 
 # Enable and start daemon
-%systemd_post ${DAEMON_NAME}.service
+%systemd_post ${DAEMON_NAME}.socket ${DAEMON_NAME}.service
+%systemd_user_post ${AGENT_NAME}.service
 
 %preun
-%systemd_preun ${DAEMON_NAME}.service
+%systemd_preun ${DAEMON_NAME}.socket ${DAEMON_NAME}.service
+%systemd_user_preun ${AGENT_NAME}.service
 
 %postun
 # Update icon cache on uninstall
@@ -232,13 +276,20 @@ if [ \$1 -eq 0 ]; then
 fi
 
 %systemd_postun_with_restart ${DAEMON_NAME}.service
+%systemd_postun ${DAEMON_NAME}.socket
 
 %files
 %license LICENSE
 %doc README.md
 %{_bindir}/%{name}
 %{_bindir}/${DAEMON_NAME}
+%{_bindir}/${CLI_NAME}
+%{_bindir}/${AGENT_NAME}
 /usr/lib/systemd/system/${DAEMON_NAME}.service
+/usr/lib/systemd/system/${DAEMON_NAME}.socket
+/usr/lib/systemd/user/${AGENT_NAME}.service
+%{_datadir}/dbus-1/system.d/com.vpnmanager.Daemon1.conf
+%{_datadir}/polkit-1/actions/com.vpnmanager.daemon.policy
 %{_datadir}/applications/com.vpnmanager.app.desktop
 %{_datadir}/metainfo/com.vpnmanager.app.metainfo.xml
 %{_datadir}/icons/hicolor/scalable/apps/%{name}.svg
@@ -277,7 +328,13 @@ if [[ -n "$RPM_FILE" && -f "$RPM_FILE" ]]; then
     echo "Contents:"
     echo "  - /usr/bin/vpn-manager (GUI/CLI application)"
     echo "  - /usr/bin/vpn-managerd (privileged operations daemon)"
+    echo "  - /usr/bin/vpnctl (command-line client)"
+    echo "  - /usr/bin/vpn-manager-agent (per-user session agent)"
     echo "  - /usr/lib/systemd/system/vpn-managerd.service"
+    echo "  - /usr/lib/systemd/system/vpn-managerd.socket"
+    echo "  - /usr/lib/systemd/user/vpn-manager-agent.service"
+    echo "  - /usr/share/dbus-1/system.d/com.vpnmanager.Daemon1.conf"
+    echo "  - /usr/share/polkit-1/actions/com.vpnmanager.daemon.policy"
     echo ""
     echo "Para instalar:"
     echo "  sudo dnf install ./${FINAL_NAME}"